@@ -0,0 +1,263 @@
+// Command routecheck explains which route of a gateway an HTTP request
+// matches, and the path parameters it binds, with the routing of
+// runtime.ServeMux.
+//
+// The routes are loaded from descriptor sets, as written by
+//
+//	protoc --include_imports -o api.pb path/to/api.proto
+//
+// or from the route manifests written by protoc-gen-grpc-gateway with
+// generate_route_manifest, and registered in the order of the generated code:
+//
+//	routecheck -descriptor_set api.pb GET /v1/users/42:activate
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	options "google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+var (
+	descriptorSets = flag.String("descriptor_set", "", "comma-separated paths of FileDescriptorSets to load the HTTP rules of the methods from")
+	routeManifests = flag.String("route_manifest", "", "comma-separated paths of route manifests written by protoc-gen-grpc-gateway with generate_route_manifest")
+	jsonOutput     = flag.Bool("json", false, "print the result as JSON")
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] METHOD PATH\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 2 || (*descriptorSets == "" && *routeManifests == "") {
+		usage()
+		os.Exit(2)
+	}
+
+	var routes []route
+	for _, path := range splitList(*descriptorSets) {
+		rs, err := loadDescriptorSet(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		routes = append(routes, rs...)
+	}
+	for _, path := range splitList(*routeManifests) {
+		rs, err := loadRouteManifest(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		routes = append(routes, rs...)
+	}
+
+	res, err := explain(routes, flag.Arg(0), flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *jsonOutput {
+		b, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Println(string(b))
+	} else {
+		res.print(os.Stdout)
+	}
+	if res.Route == nil {
+		os.Exit(1)
+	}
+}
+
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+// route is a route of the gateway.
+type route struct {
+	// Method is the fully qualified name of the method, e.g. "/example.Echo/Echo".
+	Method       string `json:"method"`
+	HTTPMethod   string `json:"httpMethod"`
+	PathTemplate string `json:"pathTemplate"`
+}
+
+// loadDescriptorSet returns the routes of the HTTP rules of the methods in
+// the FileDescriptorSet at "path".
+func loadDescriptorSet(path string) ([]route, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	var routes []route
+	for _, file := range set.GetFile() {
+		for _, svc := range file.GetService() {
+			name := svc.GetName()
+			if pkg := file.GetPackage(); pkg != "" {
+				name = pkg + "." + name
+			}
+			for _, meth := range svc.GetMethod() {
+				if meth.Options == nil || !proto.HasExtension(meth.Options, options.E_Http) {
+					continue
+				}
+				rule := proto.GetExtension(meth.Options, options.E_Http).(*options.HttpRule)
+				method := "/" + name + "/" + meth.GetName()
+				for _, r := range append([]*options.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+					httpMethod, template := httpRuleRoute(r)
+					if template == "" {
+						continue
+					}
+					routes = append(routes, route{Method: method, HTTPMethod: httpMethod, PathTemplate: template})
+				}
+			}
+		}
+	}
+	return routes, nil
+}
+
+func httpRuleRoute(rule *options.HttpRule) (method, template string) {
+	switch pattern := rule.GetPattern().(type) {
+	case *options.HttpRule_Get:
+		return "GET", pattern.Get
+	case *options.HttpRule_Put:
+		return "PUT", pattern.Put
+	case *options.HttpRule_Post:
+		return "POST", pattern.Post
+	case *options.HttpRule_Delete:
+		return "DELETE", pattern.Delete
+	case *options.HttpRule_Patch:
+		return "PATCH", pattern.Patch
+	case *options.HttpRule_Custom:
+		return pattern.Custom.GetKind(), pattern.Custom.GetPath()
+	}
+	return "", ""
+}
+
+// loadRouteManifest returns the routes of the route manifest at "path".
+func loadRouteManifest(path string) ([]route, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest struct {
+		Services []struct {
+			Routes []route `json:"routes"`
+		} `json:"services"`
+	}
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	var routes []route
+	for _, svc := range manifest.Services {
+		routes = append(routes, svc.Routes...)
+	}
+	return routes, nil
+}
+
+// result is the explanation of the routing of a request.
+type result struct {
+	HTTPMethod string `json:"httpMethod"`
+	Path       string `json:"path"`
+	// Status is the status code of the routing, 200 when a route matched.
+	Status int `json:"status"`
+	// Route is the matched route, or nil if none matched.
+	Route      *route            `json:"route,omitempty"`
+	PathParams map[string]string `json:"pathParams,omitempty"`
+	// AllowedMethods are the HTTP methods of the routes matching the path,
+	// when no route of the HTTP method of the request matched.
+	AllowedMethods []string `json:"allowedMethods,omitempty"`
+}
+
+func (res *result) print(w io.Writer) {
+	if res.Route == nil {
+		fmt.Fprintf(w, "%s %s matches no route (%d %s)\n", res.HTTPMethod, res.Path, res.Status, http.StatusText(res.Status))
+		if len(res.AllowedMethods) > 0 {
+			fmt.Fprintf(w, "  routes of the path: %s\n", strings.Join(res.AllowedMethods, ", "))
+		}
+		return
+	}
+	fmt.Fprintf(w, "%s %s matches %s %s\n", res.HTTPMethod, res.Path, res.Route.HTTPMethod, res.Route.PathTemplate)
+	fmt.Fprintf(w, "  method: %s\n", res.Route.Method)
+	if len(res.PathParams) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "  path params:")
+	var names []string
+	for name := range res.PathParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "    %s = %s\n", name, res.PathParams[name])
+	}
+}
+
+// explain routes a request of "httpMethod" and "path" with a
+// runtime.ServeMux serving "routes", registered in their order.
+func explain(routes []route, httpMethod, path string) (*result, error) {
+	res, err := serve(routes, httpMethod, path)
+	if err != nil || res.Route != nil {
+		return res, err
+	}
+	methods := make(map[string]bool)
+	for _, r := range routes {
+		if methods[r.HTTPMethod] || r.HTTPMethod == res.HTTPMethod {
+			continue
+		}
+		methods[r.HTTPMethod] = true
+		if other, err := serve(routes, r.HTTPMethod, path); err == nil && other.Route != nil {
+			res.AllowedMethods = append(res.AllowedMethods, r.HTTPMethod)
+		}
+	}
+	sort.Strings(res.AllowedMethods)
+	return res, nil
+}
+
+func serve(routes []route, httpMethod, path string) (*result, error) {
+	res := &result{HTTPMethod: strings.ToUpper(httpMethod), Path: path}
+	mux := runtime.NewServeMux(runtime.WithRoutingErrorHandler(func(_ context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, _ http.ResponseWriter, _ *http.Request, status int) {
+		res.Status = status
+	}))
+	for i := range routes {
+		r := routes[i]
+		err := mux.HandlePath(r.HTTPMethod, r.PathTemplate, func(w http.ResponseWriter, _ *http.Request, pathParams map[string]string) {
+			res.Status = http.StatusOK
+			res.Route = &r
+			res.PathParams = pathParams
+		})
+		if err != nil {
+			return nil, fmt.Errorf("route %s %s of %s: %v", r.HTTPMethod, r.PathTemplate, r.Method, err)
+		}
+	}
+	req, err := http.NewRequest(res.HTTPMethod, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+	return res, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	options "google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "routecheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func methodWithRule(name string, rule *options.HttpRule) *descriptorpb.MethodDescriptorProto {
+	opts := &descriptorpb.MethodOptions{}
+	proto.SetExtension(opts, options.E_Http, rule)
+	return &descriptorpb.MethodDescriptorProto{Name: proto.String(name), Options: opts}
+}
+
+func writeDescriptorSet(t *testing.T) string {
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			{
+				Name:    proto.String("user.proto"),
+				Package: proto.String("example"),
+				Service: []*descriptorpb.ServiceDescriptorProto{
+					{
+						Name: proto.String("UserService"),
+						Method: []*descriptorpb.MethodDescriptorProto{
+							methodWithRule("GetUser", &options.HttpRule{
+								Pattern: &options.HttpRule_Get{Get: "/v1/users/{id}"},
+								AdditionalBindings: []*options.HttpRule{
+									{Pattern: &options.HttpRule_Get{Get: "/v1/{name=users/*}/profile"}},
+								},
+							}),
+							methodWithRule("ActivateUser", &options.HttpRule{
+								Pattern: &options.HttpRule_Post{Post: "/v1/users/{id}:activate"},
+								Body:    "*",
+							}),
+							methodWithRule("HeadUser", &options.HttpRule{
+								Pattern: &options.HttpRule_Custom{Custom: &options.CustomHttpPattern{Kind: "HEAD", Path: "/v1/users/{id}"}},
+							}),
+							{Name: proto.String("Unbound")},
+						},
+					},
+				},
+			},
+		},
+	}
+	b, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(tempDir(t), "api.pb")
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExplainDescriptorSet(t *testing.T) {
+	routes, err := loadDescriptorSet(writeDescriptorSet(t))
+	if err != nil {
+		t.Fatalf("loadDescriptorSet(...) failed with %v; want success", err)
+	}
+	if len(routes) != 4 {
+		t.Fatalf("loadDescriptorSet(...) = %v; want 4 routes", routes)
+	}
+
+	for _, spec := range []struct {
+		method, path string
+		want         result
+	}{
+		{
+			method: "GET",
+			path:   "/v1/users/42",
+			want: result{
+				HTTPMethod: "GET",
+				Path:       "/v1/users/42",
+				Status:     200,
+				Route:      &route{Method: "/example.UserService/GetUser", HTTPMethod: "GET", PathTemplate: "/v1/users/{id}"},
+				PathParams: map[string]string{"id": "42"},
+			},
+		},
+		{
+			method: "get",
+			path:   "/v1/users/42/profile",
+			want: result{
+				HTTPMethod: "GET",
+				Path:       "/v1/users/42/profile",
+				Status:     200,
+				Route:      &route{Method: "/example.UserService/GetUser", HTTPMethod: "GET", PathTemplate: "/v1/{name=users/*}/profile"},
+				PathParams: map[string]string{"name": "users/42"},
+			},
+		},
+		{
+			method: "POST",
+			path:   "/v1/users/42:activate",
+			want: result{
+				HTTPMethod: "POST",
+				Path:       "/v1/users/42:activate",
+				Status:     200,
+				Route:      &route{Method: "/example.UserService/ActivateUser", HTTPMethod: "POST", PathTemplate: "/v1/users/{id}:activate"},
+				PathParams: map[string]string{"id": "42"},
+			},
+		},
+		{
+			method: "DELETE",
+			path:   "/v1/users/42",
+			want: result{
+				HTTPMethod:     "DELETE",
+				Path:           "/v1/users/42",
+				Status:         405,
+				AllowedMethods: []string{"GET", "HEAD"},
+			},
+		},
+		{
+			method: "GET",
+			path:   "/v2/users",
+			want:   result{HTTPMethod: "GET", Path: "/v2/users", Status: 404},
+		},
+	} {
+		got, err := explain(routes, spec.method, spec.path)
+		if err != nil {
+			t.Errorf("explain(%q, %q) failed with %v; want success", spec.method, spec.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, spec.want) {
+			t.Errorf("explain(%q, %q) = %+v; want %+v", spec.method, spec.path, *got, spec.want)
+		}
+	}
+}
+
+func TestExplainRouteManifest(t *testing.T) {
+	path := filepath.Join(tempDir(t), "user.routes.json")
+	manifest := `{
+  "source": "user.proto",
+  "services": [
+    {
+      "name": "example.UserService",
+      "routes": [
+        {"method": "/example.UserService/GetUser", "httpMethod": "GET", "pathTemplate": "/v1/users/{id}", "streaming": "unary"},
+        {"method": "/example.UserService/GetMe", "httpMethod": "GET", "pathTemplate": "/v1/users/me", "streaming": "unary"}
+      ]
+    }
+  ]
+}`
+	if err := ioutil.WriteFile(path, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	routes, err := loadRouteManifest(path)
+	if err != nil {
+		t.Fatalf("loadRouteManifest(%q) failed with %v; want success", path, err)
+	}
+	// The route registered last takes precedence, as in the generated code.
+	res, err := explain(routes, "GET", "/v1/users/me")
+	if err != nil {
+		t.Fatalf("explain(...) failed with %v; want success", err)
+	}
+	var out bytes.Buffer
+	res.print(&out)
+	want := "GET /v1/users/me matches GET /v1/users/me\n  method: /example.UserService/GetMe\n"
+	if got := out.String(); got != want {
+		t.Errorf("res.print(...) = %q; want %q", got, want)
+	}
+}
+
+func TestExplainInvalidTemplate(t *testing.T) {
+	_, err := explain([]route{{Method: "/example.UserService/GetUser", HTTPMethod: "GET", PathTemplate: "v1/users"}}, "GET", "/v1/users")
+	if err == nil || !strings.Contains(err.Error(), "/example.UserService/GetUser") {
+		t.Errorf("explain(...) failed with %v; want an error naming the route", err)
+	}
+}
@@ -0,0 +1,61 @@
+package descriptor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// nameFilter matches fully-qualified names of services and methods, without
+// the leading dot, e.g. "example.ExampleService.Echo".
+type nameFilter []*regexp.Regexp
+
+// newNameFilter returns a filter matching the names matched by any of
+// "patterns". A pattern enclosed in slashes is a regular expression, which
+// must match the whole name. Any other pattern is a glob, in which "*"
+// matches any sequence of characters but dots, "**" any sequence of
+// characters and "?" any character but a dot.
+func newNameFilter(patterns []string) (nameFilter, error) {
+	var f nameFilter
+	for _, pattern := range patterns {
+		expr := pattern
+		if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			expr = pattern[1 : len(pattern)-1]
+		} else {
+			expr = globToRegexp(pattern)
+		}
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %w", pattern, err)
+		}
+		f = append(f, re)
+	}
+	return f, nil
+}
+
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString(`[^.]*`)
+		case c == '?':
+			b.WriteString(`[^.]`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+func (f nameFilter) match(name string) bool {
+	for _, re := range f {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -109,6 +109,45 @@ type Registry struct {
 
 	// omitPackageDoc, if false, causes a package comment to be included in the generated code.
 	omitPackageDoc bool
+
+	// openAPIVersion is the version of the OpenAPI specification the OpenAPI
+	// documents are generated for, either "2.0" or "3.1".
+	openAPIVersion string
+
+	// openAPIOutputFormat is the format of the generated OpenAPI documents,
+	// either "json" or "yaml".
+	openAPIOutputFormat string
+
+	// includeServices, if not empty, restricts the loaded services to the
+	// ones it matches.
+	includeServices nameFilter
+
+	// excludeMethods excludes the methods it matches from the loaded services.
+	excludeMethods nameFilter
+
+	// bodyAllowedMethods permits the GET and DELETE bindings of the methods it
+	// matches to have a body, regardless of allowDeleteBody.
+	bodyAllowedMethods nameFilter
+
+	// bodyRejectedMethods makes the bindings without a body of the methods it
+	// matches reject HTTP requests carrying one.
+	bodyRejectedMethods nameFilter
+
+	// allowedCustomVerbs, if not nil, is the set of HTTP methods permitted in
+	// custom patterns of `google.api.http` annotation option.
+	allowedCustomVerbs map[string]bool
+
+	// omitDeprecatedMethods excludes the methods marked deprecated, or of
+	// services marked deprecated, from the loaded services.
+	omitDeprecatedMethods bool
+
+	// validationConstraints derives the constraints of the OpenAPI schemas of
+	// fields from their protoc-gen-validate and protovalidate rules.
+	validationConstraints bool
+
+	// definitionsFile, if not empty, is the file the schemas of the
+	// generated OpenAPI documents are moved to.
+	definitionsFile string
 }
 
 type repeatedFieldSeparator struct {
@@ -134,6 +173,9 @@ func NewRegistry() *Registry {
 		messageOptions: make(map[string]*options.Schema),
 		serviceOptions: make(map[string]*options.Tag),
 		fieldOptions:   make(map[string]*options.JSONSchema),
+
+		openAPIVersion:      "2.0",
+		openAPIOutputFormat: "json",
 	}
 }
 
@@ -180,13 +222,18 @@ func (r *Registry) loadFile(filePath string, file *protogen.File) {
 		Path: string(file.GoImportPath),
 		Name: string(file.GoPackageName),
 	}
+	name := pkg.Name
 	if r.standalone {
-		pkg.Alias = "ext" + strings.Title(pkg.Name)
+		// Standalone gateway code refers to the types of every package
+		// with an alias, which must be unique among the packages of the
+		// many API modules a gateway can consume, e.g. named "v1".
+		name = "ext" + strings.Title(pkg.Name)
+		pkg.Alias = name
 	}
 
-	if err := r.ReserveGoPackageAlias(pkg.Name, pkg.Path); err != nil {
+	if err := r.ReserveGoPackageAlias(name, pkg.Path); err != nil {
 		for i := 0; ; i++ {
-			alias := fmt.Sprintf("%s_%d", pkg.Name, i)
+			alias := fmt.Sprintf("%s_%d", name, i)
 			if err := r.ReserveGoPackageAlias(alias, pkg.Path); err == nil {
 				pkg.Alias = alias
 				break
@@ -554,6 +601,40 @@ func (r *Registry) GetOmitPackageDoc() bool {
 	return r.omitPackageDoc
 }
 
+// SetOpenAPIVersion sets the version of the OpenAPI specification the OpenAPI
+// documents are generated for. Allowed versions are '2.0' and '3.1'.
+func (r *Registry) SetOpenAPIVersion(version string) error {
+	switch version {
+	case "2.0", "3.1":
+	default:
+		return fmt.Errorf("unknown OpenAPI version: %s", version)
+	}
+	r.openAPIVersion = version
+	return nil
+}
+
+// GetOpenAPIVersion returns openAPIVersion
+func (r *Registry) GetOpenAPIVersion() string {
+	return r.openAPIVersion
+}
+
+// SetOpenAPIOutputFormat sets the format of the generated OpenAPI documents.
+// Allowed formats are 'json' and 'yaml'.
+func (r *Registry) SetOpenAPIOutputFormat(format string) error {
+	switch format {
+	case "json", "yaml":
+	default:
+		return fmt.Errorf("unknown OpenAPI output format: %s", format)
+	}
+	r.openAPIOutputFormat = format
+	return nil
+}
+
+// GetOpenAPIOutputFormat returns openAPIOutputFormat
+func (r *Registry) GetOpenAPIOutputFormat() string {
+	return r.openAPIOutputFormat
+}
+
 // RegisterOpenAPIOptions registers OpenAPI options
 func (r *Registry) RegisterOpenAPIOptions(opts *openapiconfig.OpenAPIOptions) error {
 	if opts == nil {
@@ -649,3 +730,106 @@ func (r *Registry) GetOpenAPIFieldOption(qualifiedField string) (*options.JSONSc
 	opt, ok := r.fieldOptions[qualifiedField]
 	return opt, ok
 }
+
+// SetIncludeServices restricts the services loaded from the files to generate
+// to the ones whose fully-qualified name matches any of "patterns". See
+// newNameFilter for the syntax of patterns. All services are loaded if
+// "patterns" is empty.
+func (r *Registry) SetIncludeServices(patterns []string) error {
+	f, err := newNameFilter(patterns)
+	if err != nil {
+		return err
+	}
+	r.includeServices = f
+	return nil
+}
+
+// SetExcludeMethods excludes the methods whose fully-qualified name matches
+// any of "patterns" from the services loaded from the files to generate.
+// See newNameFilter for the syntax of patterns.
+func (r *Registry) SetExcludeMethods(patterns []string) error {
+	f, err := newNameFilter(patterns)
+	if err != nil {
+		return err
+	}
+	r.excludeMethods = f
+	return nil
+}
+
+// SetOmitDeprecatedMethods excludes the methods marked deprecated, or of
+// services marked deprecated, from the services loaded from the files to
+// generate.
+func (r *Registry) SetOmitDeprecatedMethods(omit bool) {
+	r.omitDeprecatedMethods = omit
+}
+
+// SetValidationConstraints sets validationConstraints
+func (r *Registry) SetValidationConstraints(use bool) {
+	r.validationConstraints = use
+}
+
+// GetValidationConstraints returns validationConstraints
+func (r *Registry) GetValidationConstraints() bool {
+	return r.validationConstraints
+}
+
+// SetDefinitionsFile sets definitionsFile
+func (r *Registry) SetDefinitionsFile(name string) {
+	r.definitionsFile = name
+}
+
+// GetDefinitionsFile returns definitionsFile
+func (r *Registry) GetDefinitionsFile() string {
+	return r.definitionsFile
+}
+
+// isFilteredOut reports whether "meth" of "svc" is excluded by
+// includeServices, excludeMethods or omitDeprecatedMethods.
+func (r *Registry) isFilteredOut(svc *Service, meth *descriptorpb.MethodDescriptorProto) bool {
+	if r.omitDeprecatedMethods && (&Method{Service: svc, MethodDescriptorProto: meth}).IsDeprecated() {
+		return true
+	}
+	fqsn := strings.TrimPrefix(svc.FQSN(), ".")
+	if len(r.includeServices) > 0 && !r.includeServices.match(fqsn) {
+		return true
+	}
+	return r.excludeMethods.match(fqsn + "." + meth.GetName())
+}
+
+// SetBodyAllowedMethods permits the GET and DELETE bindings of the methods
+// whose fully-qualified name matches any of "patterns" to have a body. See
+// newNameFilter for the syntax of patterns.
+func (r *Registry) SetBodyAllowedMethods(patterns []string) error {
+	f, err := newNameFilter(patterns)
+	if err != nil {
+		return err
+	}
+	r.bodyAllowedMethods = f
+	return nil
+}
+
+// SetBodyRejectedMethods makes the bindings without a body of the methods
+// whose fully-qualified name matches any of "patterns" reject HTTP requests
+// carrying one. See newNameFilter for the syntax of patterns.
+func (r *Registry) SetBodyRejectedMethods(patterns []string) error {
+	f, err := newNameFilter(patterns)
+	if err != nil {
+		return err
+	}
+	r.bodyRejectedMethods = f
+	return nil
+}
+
+// SetAllowedCustomVerbs restricts the HTTP methods of custom patterns of
+// `google.api.http` annotation option to "verbs". Any HTTP method is allowed
+// if "verbs" is empty.
+func (r *Registry) SetAllowedCustomVerbs(verbs []string) {
+	if len(verbs) == 0 {
+		r.allowedCustomVerbs = nil
+		return
+	}
+	r.allowedCustomVerbs = make(map[string]bool)
+	for _, verb := range verbs {
+		r.allowedCustomVerbs[strings.ToUpper(verb)] = true
+	}
+}
@@ -571,6 +571,33 @@ func TestLoadWithStandalone(t *testing.T) {
 	}
 }
 
+func TestLoadWithStandaloneAliasConflict(t *testing.T) {
+	reg := NewRegistry()
+	reg.SetStandalone(true)
+	loadFileWithCodeGeneratorRequest(t, reg, &pluginpb.CodeGeneratorRequest{}, `
+		name: 'a/v1/a.proto'
+		package: 'a.v1'
+		options < go_package: 'example.com/a/v1' >
+	`, `
+		name: 'b/v1/b.proto'
+		package: 'b.v1'
+		options < go_package: 'example.com/b/v1' >
+	`)
+	for name, want := range map[string]GoPackage{
+		"a/v1/a.proto": {Path: "example.com/a/v1", Name: "v1", Alias: "extV1"},
+		"b/v1/b.proto": {Path: "example.com/b/v1", Name: "v1", Alias: "extV1_0"},
+	} {
+		file := reg.files[name]
+		if file == nil {
+			t.Errorf("reg.files[%q] = nil; want non-nil", name)
+			continue
+		}
+		if got := file.GoPkg; got != want {
+			t.Errorf("reg.files[%q].GoPkg = %#v; want %#v", name, got, want)
+		}
+	}
+}
+
 func TestUnboundExternalHTTPRules(t *testing.T) {
 	reg := NewRegistry()
 	methodName := ".example.ExampleService.Echo"
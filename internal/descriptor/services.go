@@ -35,6 +35,10 @@ func (r *Registry) loadServices(file *File) error {
 			if opts != nil {
 				optsList = append(optsList, opts)
 			}
+			if r.isFilteredOut(svc, md) {
+				glog.V(1).Infof("Skipping filtered out method: %s.%s", svc.GetName(), md.GetName())
+				continue
+			}
 			if len(optsList) == 0 {
 				if r.generateUnboundMethods {
 					defaultOpts, err := defaultAPIOptions(svc, md)
@@ -83,6 +87,8 @@ func (r *Registry) newMethod(svc *Service, md *descriptorpb.MethodDescriptorProt
 		ResponseType:          responseType,
 	}
 
+	fqmn := strings.TrimPrefix(svc.FQSN(), ".") + "." + md.GetName()
+	bodyAllowed := r.bodyAllowedMethods.match(fqmn)
 	newBinding := func(opts *options.HttpRule, idx int) (*Binding, error) {
 		var (
 			httpMethod   string
@@ -92,7 +98,7 @@ func (r *Registry) newMethod(svc *Service, md *descriptorpb.MethodDescriptorProt
 		case opts.GetGet() != "":
 			httpMethod = "GET"
 			pathTemplate = opts.GetGet()
-			if opts.Body != "" {
+			if opts.Body != "" && !bodyAllowed {
 				return nil, fmt.Errorf("must not set request body when http method is GET: %s", md.GetName())
 			}
 
@@ -107,7 +113,7 @@ func (r *Registry) newMethod(svc *Service, md *descriptorpb.MethodDescriptorProt
 		case opts.GetDelete() != "":
 			httpMethod = "DELETE"
 			pathTemplate = opts.GetDelete()
-			if opts.Body != "" && !r.allowDeleteBody {
+			if opts.Body != "" && !r.allowDeleteBody && !bodyAllowed {
 				return nil, fmt.Errorf("must not set request body when http method is DELETE except allow_delete_body option is true: %s", md.GetName())
 			}
 
@@ -119,6 +125,9 @@ func (r *Registry) newMethod(svc *Service, md *descriptorpb.MethodDescriptorProt
 			custom := opts.GetCustom()
 			httpMethod = custom.Kind
 			pathTemplate = custom.Path
+			if r.allowedCustomVerbs != nil && !r.allowedCustomVerbs[strings.ToUpper(httpMethod)] {
+				return nil, fmt.Errorf("custom http method %q is not allowed: %s", httpMethod, md.GetName())
+			}
 
 		default:
 			glog.V(1).Infof("No pattern specified in google.api.HttpRule: %s", md.GetName())
@@ -156,6 +165,7 @@ func (r *Registry) newMethod(svc *Service, md *descriptorpb.MethodDescriptorProt
 		if err != nil {
 			return nil, err
 		}
+		b.RejectBody = b.Body == nil && r.bodyRejectedMethods.match(fqmn)
 
 		b.ResponseBody, err = r.newResponse(meth, opts.ResponseBody)
 		if err != nil {
@@ -357,6 +357,177 @@ func TestExtractServicesGenerateUnboundMethods(t *testing.T) {
 	testExtractServicesWithRegistry(t, reg, []*descriptorpb.FileDescriptorProto{&fd}, "path/to/example.proto", file.Services)
 }
 
+func TestExtractServicesFiltered(t *testing.T) {
+	src := `
+		name: "path/to/example.proto",
+		package: "example.v1"
+		message_type <
+			name: "StringMessage"
+		>
+		service <
+			name: "PublicService"
+			method <
+				name: "Echo"
+				input_type: "StringMessage"
+				output_type: "StringMessage"
+			>
+			method <
+				name: "InternalEcho"
+				input_type: "StringMessage"
+				output_type: "StringMessage"
+			>
+		>
+		service <
+			name: "AdminService"
+			method <
+				name: "Echo"
+				input_type: "StringMessage"
+				output_type: "StringMessage"
+			>
+		>
+	`
+	var fd descriptorpb.FileDescriptorProto
+	if err := prototext.Unmarshal([]byte(src), &fd); err != nil {
+		t.Fatalf("prototext.Unmarshal (%s, &fd) failed with %v; want success", src, err)
+	}
+	for _, spec := range []struct {
+		includeServices []string
+		excludeMethods  []string
+		want            []string
+	}{
+		{
+			want: []string{"PublicService.Echo", "PublicService.InternalEcho", "AdminService.Echo"},
+		},
+		{
+			includeServices: []string{"example.*.Public*"},
+			want:            []string{"PublicService.Echo", "PublicService.InternalEcho"},
+		},
+		{
+			includeServices: []string{"example.*"},
+			want:            nil,
+		},
+		{
+			includeServices: []string{"example.**"},
+			excludeMethods:  []string{"/.*\\.Internal.*/"},
+			want:            []string{"PublicService.Echo", "AdminService.Echo"},
+		},
+		{
+			excludeMethods: []string{"example.v1.AdminService.*", "example.v1.PublicService.Internal?cho"},
+			want:           []string{"PublicService.Echo"},
+		},
+	} {
+		reg := NewRegistry()
+		reg.SetGenerateUnboundMethods(true)
+		if err := reg.SetIncludeServices(spec.includeServices); err != nil {
+			t.Fatalf("reg.SetIncludeServices(%q) failed with %v; want success", spec.includeServices, err)
+		}
+		if err := reg.SetExcludeMethods(spec.excludeMethods); err != nil {
+			t.Fatalf("reg.SetExcludeMethods(%q) failed with %v; want success", spec.excludeMethods, err)
+		}
+		reg.loadFile(fd.GetName(), &protogen.File{
+			Proto: &fd,
+		})
+		file := reg.files[fd.GetName()]
+		if err := reg.loadServices(file); err != nil {
+			t.Fatalf("loadServices(%q) failed with %v; want success", fd.GetName(), err)
+		}
+		var got []string
+		for _, svc := range file.Services {
+			for _, meth := range svc.Methods {
+				got = append(got, svc.GetName()+"."+meth.GetName())
+			}
+		}
+		if !reflect.DeepEqual(got, spec.want) {
+			t.Errorf("methods with includeServices %q and excludeMethods %q = %q; want %q", spec.includeServices, spec.excludeMethods, got, spec.want)
+		}
+	}
+
+	if err := NewRegistry().SetExcludeMethods([]string{"/(/"}); err == nil {
+		t.Errorf("reg.SetExcludeMethods(%q) succeeded; want error", "/(/")
+	}
+}
+
+func TestExtractServicesOmitDeprecated(t *testing.T) {
+	src := `
+		name: "path/to/example.proto",
+		package: "example.v1"
+		message_type <
+			name: "StringMessage"
+		>
+		service <
+			name: "PublicService"
+			method <
+				name: "Echo"
+				input_type: "StringMessage"
+				output_type: "StringMessage"
+			>
+			method <
+				name: "OldEcho"
+				input_type: "StringMessage"
+				output_type: "StringMessage"
+				options <
+					deprecated: true
+				>
+			>
+		>
+		service <
+			name: "OldService"
+			method <
+				name: "Echo"
+				input_type: "StringMessage"
+				output_type: "StringMessage"
+			>
+			options <
+				deprecated: true
+			>
+		>
+	`
+	var fd descriptorpb.FileDescriptorProto
+	if err := prototext.Unmarshal([]byte(src), &fd); err != nil {
+		t.Fatalf("prototext.Unmarshal (%s, &fd) failed with %v; want success", src, err)
+	}
+	for _, spec := range []struct {
+		omitDeprecatedMethods bool
+		want                  []string
+		wantDeprecated        []string
+	}{
+		{
+			want:           []string{"PublicService.Echo", "PublicService.OldEcho", "OldService.Echo"},
+			wantDeprecated: []string{"PublicService.OldEcho", "OldService.Echo"},
+		},
+		{
+			omitDeprecatedMethods: true,
+			want:                  []string{"PublicService.Echo"},
+		},
+	} {
+		reg := NewRegistry()
+		reg.SetGenerateUnboundMethods(true)
+		reg.SetOmitDeprecatedMethods(spec.omitDeprecatedMethods)
+		reg.loadFile(fd.GetName(), &protogen.File{
+			Proto: &fd,
+		})
+		file := reg.files[fd.GetName()]
+		if err := reg.loadServices(file); err != nil {
+			t.Fatalf("loadServices(%q) failed with %v; want success", fd.GetName(), err)
+		}
+		var got, gotDeprecated []string
+		for _, svc := range file.Services {
+			for _, meth := range svc.Methods {
+				got = append(got, svc.GetName()+"."+meth.GetName())
+				if meth.IsDeprecated() {
+					gotDeprecated = append(gotDeprecated, svc.GetName()+"."+meth.GetName())
+				}
+			}
+		}
+		if !reflect.DeepEqual(got, spec.want) {
+			t.Errorf("methods with omitDeprecatedMethods %v = %q; want %q", spec.omitDeprecatedMethods, got, spec.want)
+		}
+		if !reflect.DeepEqual(gotDeprecated, spec.wantDeprecated) {
+			t.Errorf("deprecated methods with omitDeprecatedMethods %v = %q; want %q", spec.omitDeprecatedMethods, gotDeprecated, spec.wantDeprecated)
+		}
+	}
+}
+
 func TestExtractServicesCrossPackage(t *testing.T) {
 	srcs := []string{
 		`
@@ -1248,6 +1419,119 @@ func TestResolveFieldPath(t *testing.T) {
 	}
 }
 
+func TestExtractServicesWithBodyAndCustomVerbControls(t *testing.T) {
+	src := `
+		name: "path/to/example.proto",
+		package: "example"
+		message_type <
+			name: "StringMessage"
+			field <
+				name: "string"
+				number: 1
+				label: LABEL_OPTIONAL
+				type: TYPE_STRING
+			>
+		>
+		service <
+			name: "ExampleService"
+			method <
+				name: "Search"
+				input_type: "StringMessage"
+				output_type: "StringMessage"
+				options <
+					[google.api.http] <
+						get: "/v1/example/search"
+						body: "*"
+					>
+				>
+			>
+			method <
+				name: "Probe"
+				input_type: "StringMessage"
+				output_type: "StringMessage"
+				options <
+					[google.api.http] <
+						custom <
+							kind: "HEAD"
+							path: "/v1/example/probe"
+						>
+					>
+				>
+			>
+			method <
+				name: "Get"
+				input_type: "StringMessage"
+				output_type: "StringMessage"
+				options <
+					[google.api.http] <
+						get: "/v1/example/{string}"
+					>
+				>
+			>
+		>
+	`
+	var fd descriptorpb.FileDescriptorProto
+	if err := prototext.Unmarshal([]byte(src), &fd); err != nil {
+		t.Fatalf("prototext.Unmarshal (%s, &fd) failed with %v; want success", src, err)
+	}
+	for _, spec := range []struct {
+		bodyAllowedMethods  []string
+		bodyRejectedMethods []string
+		allowedCustomVerbs  []string
+		wantErr             bool
+		wantRejectBody      bool
+	}{
+		{
+			wantErr: true,
+		},
+		{
+			bodyAllowedMethods: []string{"example.ExampleService.Search"},
+		},
+		{
+			bodyAllowedMethods: []string{"example.ExampleService.Search"},
+			allowedCustomVerbs: []string{"options"},
+			wantErr:            true,
+		},
+		{
+			bodyAllowedMethods:  []string{"example.ExampleService.Search"},
+			bodyRejectedMethods: []string{"example.ExampleService.*"},
+			allowedCustomVerbs:  []string{"head"},
+			wantRejectBody:      true,
+		},
+	} {
+		reg := NewRegistry()
+		if err := reg.SetBodyAllowedMethods(spec.bodyAllowedMethods); err != nil {
+			t.Fatalf("reg.SetBodyAllowedMethods(%q) failed with %v; want success", spec.bodyAllowedMethods, err)
+		}
+		if err := reg.SetBodyRejectedMethods(spec.bodyRejectedMethods); err != nil {
+			t.Fatalf("reg.SetBodyRejectedMethods(%q) failed with %v; want success", spec.bodyRejectedMethods, err)
+		}
+		reg.SetAllowedCustomVerbs(spec.allowedCustomVerbs)
+		reg.loadFile(fd.GetName(), &protogen.File{
+			Proto: &fd,
+		})
+		file := reg.files[fd.GetName()]
+		err := reg.loadServices(file)
+		if spec.wantErr {
+			if err == nil {
+				t.Errorf("loadServices(%q) with %+v succeeded; want an error", fd.GetName(), spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("loadServices(%q) with %+v failed with %v; want success", fd.GetName(), spec, err)
+			continue
+		}
+		for _, meth := range file.Services[0].Methods {
+			b := meth.Bindings[0]
+			want := spec.wantRejectBody && b.Body == nil
+			if b.RejectBody != want {
+				t.Errorf("%s.Bindings[0].RejectBody = %v with %+v; want %v", meth.GetName(), b.RejectBody, spec, want)
+			}
+		}
+	}
+}
+
 func TestExtractServicesWithDeleteBody(t *testing.T) {
 	for _, spec := range []struct {
 		allowDeleteBody bool
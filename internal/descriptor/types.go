@@ -217,6 +217,12 @@ func (m *Method) FQMN() string {
 	return strings.Join(components, ".")
 }
 
+// IsDeprecated reports whether this method, or its service, is marked
+// deprecated with the `deprecated` option.
+func (m *Method) IsDeprecated() bool {
+	return m.GetOptions().GetDeprecated() || m.Service.GetOptions().GetDeprecated()
+}
+
 // Binding describes how an HTTP endpoint is bound to a gRPC method.
 type Binding struct {
 	// Method is the method which the endpoint is bound to.
@@ -233,6 +239,8 @@ type Binding struct {
 	Body *Body
 	// ResponseBody describes field in response struct to marshal in HTTP response body.
 	ResponseBody *Body
+	// RejectBody is true if HTTP requests must not carry a body, as Body is nil.
+	RejectBody bool
 }
 
 // ExplicitParams returns a list of explicitly bound parameters of "b",
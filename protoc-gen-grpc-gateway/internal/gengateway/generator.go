@@ -24,13 +24,26 @@ type generator struct {
 	registerFuncSuffix string
 	allowPatchFeature  bool
 	standalone         bool
+	poolRequest        bool
+	registerDynamic    bool
+	routeManifest      bool
+	exportPatterns     bool
+	httpClient         bool
+	serverStreamingSSE string
+	bidiWebSocket      string
+	templateDir        string
+	validateRequests   bool
+	requestDefaults    bool
+	fakes              bool
+	deprecationHeaders bool
+	packageMap         string
 }
 
 // New returns a new generator which generates grpc gateway files.
 func New(reg *descriptor.Registry, useRequestContext bool, registerFuncSuffix string,
-	allowPatchFeature, standalone bool) gen.Generator {
-	var imports []descriptor.GoPackage
-	for _, pkgpath := range []string{
+	allowPatchFeature, standalone, poolRequest, registerDynamic, routeManifest, exportPatterns, httpClient bool,
+	serverStreamingSSE, bidiWebSocket, templateDir string, validateRequests, requestDefaults, fakes, deprecationHeaders bool, packageMap string) gen.Generator {
+	pkgpaths := []string{
 		"context",
 		"io",
 		"net/http",
@@ -42,7 +55,12 @@ func New(reg *descriptor.Registry, useRequestContext bool, registerFuncSuffix st
 		"google.golang.org/grpc/grpclog",
 		"google.golang.org/grpc/metadata",
 		"google.golang.org/grpc/status",
-	} {
+	}
+	if fakes {
+		pkgpaths = append(pkgpaths, "net/http/httptest")
+	}
+	var imports []descriptor.GoPackage
+	for _, pkgpath := range pkgpaths {
 		pkg := descriptor.GoPackage{
 			Path: pkgpath,
 			Name: path.Base(pkgpath),
@@ -67,15 +85,61 @@ func New(reg *descriptor.Registry, useRequestContext bool, registerFuncSuffix st
 		registerFuncSuffix: registerFuncSuffix,
 		allowPatchFeature:  allowPatchFeature,
 		standalone:         standalone,
+		poolRequest:        poolRequest,
+		registerDynamic:    registerDynamic,
+		routeManifest:      routeManifest,
+		exportPatterns:     exportPatterns,
+		httpClient:         httpClient,
+		serverStreamingSSE: serverStreamingSSE,
+		bidiWebSocket:      bidiWebSocket,
+		templateDir:        templateDir,
+		validateRequests:   validateRequests,
+		requestDefaults:    requestDefaults,
+		fakes:              fakes,
+		deprecationHeaders: deprecationHeaders,
+		packageMap:         packageMap,
 	}
 }
 
 func (g *generator) Generate(targets []*descriptor.File) ([]*descriptor.ResponseFile, error) {
+	var overrides *templateOverrides
+	if g.templateDir != "" {
+		var err error
+		if overrides, err = loadTemplateOverrides(g.templateDir); err != nil {
+			return nil, err
+		}
+	}
+	var packages standalonePackages
+	if g.standalone && g.packageMap != "" {
+		var err error
+		if packages, err = loadStandalonePackageMap(g.packageMap); err != nil {
+			return nil, err
+		}
+	}
 	var files []*descriptor.ResponseFile
 	for _, file := range targets {
 		glog.V(1).Infof("Processing %s", file.GetName())
 
-		code, err := g.generate(file)
+		goPkg, filenamePrefix := file.GoPkg, file.GeneratedFilenamePrefix
+		if pkg, ok := packages.lookup(file); ok {
+			goPkg, filenamePrefix = pkg, standaloneFilenamePrefix(file, pkg)
+		}
+
+		// The manifest is built first, as the code generation rewrites
+		// the names of services and methods into Go identifiers.
+		var manifest *routeManifest
+		if g.routeManifest {
+			manifest = newRouteManifest(file)
+		}
+
+		var tmpls templateSet
+		if overrides != nil {
+			var err error
+			if tmpls, err = overrides.forFile(file.GetName()); err != nil {
+				return nil, err
+			}
+		}
+		code, err := g.generate(file, goPkg, tmpls)
 		if err == errNoTargetService {
 			glog.V(1).Infof("%s: %v", file.GetName(), err)
 			continue
@@ -89,17 +153,31 @@ func (g *generator) Generate(targets []*descriptor.File) ([]*descriptor.Response
 			return nil, err
 		}
 		files = append(files, &descriptor.ResponseFile{
-			GoPkg: file.GoPkg,
+			GoPkg: goPkg,
 			CodeGeneratorResponse_File: &pluginpb.CodeGeneratorResponse_File{
-				Name:    proto.String(file.GeneratedFilenamePrefix + ".pb.gw.go"),
+				Name:    proto.String(filenamePrefix + ".pb.gw.go"),
 				Content: proto.String(string(formatted)),
 			},
 		})
+		if manifest != nil {
+			b, err := manifest.marshal()
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, &descriptor.ResponseFile{
+				GoPkg: goPkg,
+				CodeGeneratorResponse_File: &pluginpb.CodeGeneratorResponse_File{
+					Name:    proto.String(filenamePrefix + ".routes.json"),
+					Content: proto.String(string(b)),
+				},
+			})
+		}
 	}
 	return files, nil
 }
 
-func (g *generator) generate(file *descriptor.File) (string, error) {
+// generate returns the gateway code of "file", in the Go package "goPkg".
+func (g *generator) generate(file *descriptor.File, goPkg descriptor.GoPackage, tmpls templateSet) (string, error) {
 	pkgSeen := make(map[string]bool)
 	var imports []descriptor.GoPackage
 	for _, pkg := range g.baseImports {
@@ -122,6 +200,18 @@ func (g *generator) generate(file *descriptor.File) (string, error) {
 			pkgSeen[pkg.Path] = true
 			imports = append(imports, pkg)
 		}
+		if g.httpClient || g.fakes {
+			// The typed HTTP client and the fake server name the response types too.
+			for _, m := range svc.Methods {
+				pkg := m.ResponseType.File.GoPkg
+				if len(m.Bindings) == 0 || m.GetClientStreaming() || m.GetServerStreaming() ||
+					pkg == file.GoPkg || pkgSeen[pkg.Path] {
+					continue
+				}
+				pkgSeen[pkg.Path] = true
+				imports = append(imports, pkg)
+			}
+		}
 	}
 	params := param{
 		File:               file,
@@ -129,6 +219,24 @@ func (g *generator) generate(file *descriptor.File) (string, error) {
 		UseRequestContext:  g.useRequestContext,
 		RegisterFuncSuffix: g.registerFuncSuffix,
 		AllowPatchFeature:  g.allowPatchFeature,
+		PoolRequest:        g.poolRequest,
+		RegisterDynamic:    g.registerDynamic,
+		ExportPatterns:     g.exportPatterns,
+		HTTPClient:         g.httpClient,
+		ServerStreamingSSE: g.serverStreamingSSE,
+		BidiWebSocket:      g.bidiWebSocket,
+		ValidateRequests:   g.validateRequests,
+		RequestDefaults:    g.requestDefaults,
+		Fakes:              g.fakes,
+		DeprecationHeaders: g.deprecationHeaders,
+		templates:          tmpls,
+	}
+	if goPkg != file.GoPkg {
+		// The standalone gateway code lives in its own package, and
+		// refers to every type with the alias of its package.
+		out := *file
+		out.GoPkg = goPkg
+		params.File = &out
 	}
 	if g.reg != nil {
 		params.OmitPackageDoc = g.reg.GetOmitPackageDoc()
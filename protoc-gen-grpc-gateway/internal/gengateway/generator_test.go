@@ -1,6 +1,11 @@
 package gengateway
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/descriptor"
@@ -95,3 +100,221 @@ func TestGenerator_Generate(t *testing.T) {
 		t.Fatalf("invalid name %q, expected %q", gotName, expectedName)
 	}
 }
+
+func TestGenerator_GenerateRouteManifest(t *testing.T) {
+	file := crossLinkFixture(newExampleFileDescriptorWithGoPkg(&descriptor.GoPackage{
+		Path: "example.com/path/to/example",
+		Name: "example_pb",
+	}, "path/to/example"))
+	file.Services[0].Methods[0].Bindings[0].PathTmpl.Template = "/v1/example"
+	g := &generator{routeManifest: true}
+	result, err := g.Generate([]*descriptor.File{file})
+	if err != nil {
+		t.Fatalf("failed to generate stubs: %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("expected to generate two files, got: %d", len(result))
+	}
+	expectedName := "path/to/example.routes.json"
+	if gotName := result[1].GetName(); gotName != expectedName {
+		t.Fatalf("invalid name %q, expected %q", gotName, expectedName)
+	}
+	expected := `{
+  "source": "example.proto",
+  "services": [
+    {
+      "name": "example.ExampleService",
+      "routes": [
+        {
+          "method": "/example.ExampleService/Example",
+          "httpMethod": "GET",
+          "pathTemplate": "/v1/example",
+          "body": "*",
+          "streaming": "unary"
+        }
+      ]
+    }
+  ]
+}
+`
+	if got := result[1].GetContent(); got != expected {
+		t.Errorf("invalid manifest %s, expected %s", got, expected)
+	}
+}
+
+func TestGenerator_GenerateTemplateOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, text := range map[string]string{
+		"header.tmpl": "//go:build custom\n\n{{template \"default\" .}}",
+		"handler.tmpl": `{{define "client-rpc-request-func"}}
+// custom request func of {{.Method.Service.GetName}}.{{.Method.GetName}}
+{{end}}`,
+		"example.proto/trailer.tmpl": "{{template \"default\" .}}\n// trailer of example.proto\n",
+		"other.proto/trailer.tmpl":   "// trailer of other.proto\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g := &generator{templateDir: dir}
+	result, err := g.Generate([]*descriptor.File{
+		crossLinkFixture(newExampleFileDescriptorWithGoPkg(&descriptor.GoPackage{
+			Path: "example.com/path/to/example",
+			Name: "example_pb",
+		}, "path/to/example")),
+	})
+	if err != nil {
+		t.Fatalf("failed to generate stubs: %v", err)
+	}
+	got := result[0].GetContent()
+	if !strings.HasPrefix(got, "//go:build custom\n\n// Code generated by protoc-gen-grpc-gateway. DO NOT EDIT.") {
+		t.Errorf("generated file does not start with the header override: %s", got)
+	}
+	for _, want := range []string{
+		"// custom request func of ExampleService.Example\n",
+		"func local_request_ExampleService_Example_0(",
+		"func RegisterExampleServiceClient(",
+		"// trailer of example.proto\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("generated file = %s; want to contain %s", got, want)
+		}
+	}
+	for _, notWant := range []string{
+		"func request_ExampleService_Example_0(",
+		"// trailer of other.proto",
+	} {
+		if strings.Contains(got, notWant) {
+			t.Errorf("generated file = %s; want not to contain %s", got, notWant)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "handlers.tmpl"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Generate(nil); err == nil {
+		t.Errorf("g.Generate() succeeded with an unknown template section; want error")
+	}
+}
+
+func TestGenerator_GenerateStandalonePackageMap(t *testing.T) {
+	f, err := ioutil.TempFile("", "packages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(`
+# Gateway packages of the API modules.
+example.com/path/to/example = example.com/gateway/example;examplegw
+other.proto=example.com/gateway/other-pkg
+`); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, spec := range []struct {
+		filenamePrefix string
+		wantName       string
+	}{
+		{
+			filenamePrefix: "example.com/path/to/example/example",
+			wantName:       "example.com/gateway/example/example.pb.gw.go",
+		},
+		{
+			filenamePrefix: "path/to/example",
+			wantName:       "path/to/example.pb.gw.go",
+		},
+	} {
+		g := &generator{standalone: true, packageMap: f.Name()}
+		result, err := g.Generate([]*descriptor.File{
+			crossLinkFixture(newExampleFileDescriptorWithGoPkg(&descriptor.GoPackage{
+				Path:  "example.com/path/to/example",
+				Name:  "example_pb",
+				Alias: "extExample_pb",
+			}, spec.filenamePrefix)),
+		})
+		if err != nil {
+			t.Fatalf("failed to generate stubs: %v", err)
+		}
+		if got := result[0].GetName(); got != spec.wantName {
+			t.Errorf("invalid name %q, expected %q", got, spec.wantName)
+		}
+		if got, want := result[0].GoPkg, (descriptor.GoPackage{Path: "example.com/gateway/example", Name: "examplegw"}); got != want {
+			t.Errorf("result[0].GoPkg = %#v; want %#v", got, want)
+		}
+		got := result[0].GetContent()
+		for _, want := range []string{
+			"\npackage examplegw\n",
+			"\textExample_pb \"example.com/path/to/example\"\n",
+		} {
+			if !strings.Contains(got, want) {
+				t.Errorf("generated file = %s; want to contain %s", got, want)
+			}
+		}
+	}
+}
+
+func TestLoadStandalonePackageMap(t *testing.T) {
+	for _, spec := range []struct {
+		content string
+		want    standalonePackages
+		wantErr bool
+	}{
+		{
+			content: "a.proto=example.com/gw/a;agw\n\n# comment\nexample.com/api/b-api = example.com/gw/b-api\n",
+			want: standalonePackages{
+				"a.proto":               {Path: "example.com/gw/a", Name: "agw"},
+				"example.com/api/b-api": {Path: "example.com/gw/b-api", Name: "b_api"},
+			},
+		},
+		{
+			content: "a.proto example.com/gw/a\n",
+			wantErr: true,
+		},
+		{
+			content: "a.proto=;agw\n",
+			wantErr: true,
+		},
+		{
+			content: "a.proto=example.com/gw/a\na.proto=example.com/gw/b\n",
+			wantErr: true,
+		},
+	} {
+		f, err := ioutil.TempFile("", "packages")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString(spec.content); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+		got, err := loadStandalonePackageMap(f.Name())
+		if spec.wantErr {
+			if err == nil {
+				t.Errorf("loadStandalonePackageMap(%q) succeeded; want an error", spec.content)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("loadStandalonePackageMap(%q) failed with %v; want success", spec.content, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, spec.want) {
+			t.Errorf("loadStandalonePackageMap(%q) = %v; want %v", spec.content, got, spec.want)
+		}
+	}
+}
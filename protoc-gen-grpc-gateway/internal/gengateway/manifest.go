@@ -0,0 +1,93 @@
+package gengateway
+
+import (
+	"encoding/json"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/descriptor"
+)
+
+// routeManifest is the machine-readable description of the routes generated
+// for a file, written next to the gateway code when route manifests are
+// enabled.
+type routeManifest struct {
+	Source   string            `json:"source"`
+	Services []manifestService `json:"services"`
+}
+
+type manifestService struct {
+	// Name is the fully qualified name of the service, e.g. "example.Echo".
+	Name   string          `json:"name"`
+	Routes []manifestRoute `json:"routes"`
+}
+
+type manifestRoute struct {
+	// Method is the fully qualified name of the method, e.g. "/example.Echo/Echo".
+	Method       string `json:"method"`
+	HTTPMethod   string `json:"httpMethod"`
+	PathTemplate string `json:"pathTemplate"`
+	// Body is the field path the request body is mapped to, "*" for the whole
+	// request message, or empty if requests have no body.
+	Body string `json:"body,omitempty"`
+	// ResponseBody is the field path the response body is mapped from, or
+	// empty for the whole response message.
+	ResponseBody string `json:"responseBody,omitempty"`
+	// Streaming is one of "unary", "server", "client" and "bidi".
+	Streaming string `json:"streaming"`
+}
+
+// newRouteManifest returns the manifest of the routes of the services of
+// file, or nil if none of its methods are bound to HTTP routes.
+func newRouteManifest(file *descriptor.File) *routeManifest {
+	m := &routeManifest{Source: file.GetName()}
+	for _, svc := range file.Services {
+		s := manifestService{Name: svc.FQSN()[1:]}
+		for _, meth := range svc.Methods {
+			for _, b := range meth.Bindings {
+				r := manifestRoute{
+					Method:       "/" + s.Name + "/" + meth.GetName(),
+					HTTPMethod:   b.HTTPMethod,
+					PathTemplate: b.PathTmpl.Template,
+					Streaming:    streamingKind(meth),
+				}
+				if b.Body != nil {
+					r.Body = "*"
+					if len(b.Body.FieldPath) > 0 {
+						r.Body = b.Body.FieldPath.String()
+					}
+				}
+				if b.ResponseBody != nil {
+					r.ResponseBody = b.ResponseBody.FieldPath.String()
+				}
+				s.Routes = append(s.Routes, r)
+			}
+		}
+		if len(s.Routes) > 0 {
+			m.Services = append(m.Services, s)
+		}
+	}
+	if len(m.Services) == 0 {
+		return nil
+	}
+	return m
+}
+
+func streamingKind(meth *descriptor.Method) string {
+	switch {
+	case meth.GetClientStreaming() && meth.GetServerStreaming():
+		return "bidi"
+	case meth.GetClientStreaming():
+		return "client"
+	case meth.GetServerStreaming():
+		return "server"
+	}
+	return "unary"
+}
+
+// marshal returns the indented JSON encoding of m.
+func (m *routeManifest) marshal() ([]byte, error) {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,102 @@
+package gengateway
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/descriptor"
+)
+
+// standalonePackages maps proto files, or the Go import paths of the
+// packages of their types, to the Go packages their standalone gateway code
+// is generated in.
+type standalonePackages map[string]descriptor.GoPackage
+
+// loadStandalonePackageMap parses the mapping file "filename". Every line
+// maps a proto file name, e.g. "example/v1/echo.proto", or the Go import path
+// of the types of proto files, e.g. "example.com/api/example/v1", to a Go
+// package written like go_package options, e.g.
+//
+//	example.com/api/example/v1=example.com/gateway/example/v1;examplegw
+//
+// The package name defaults to the last element of the import path. Empty
+// lines and lines starting with "#" are ignored.
+func loadStandalonePackageMap(filename string) (standalonePackages, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := make(standalonePackages)
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("%s:%d: want <proto file or Go import path>=<Go package>, got %q", filename, n, line)
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		pkg := descriptor.GoPackage{Path: value}
+		if j := strings.Index(value, ";"); j >= 0 {
+			pkg.Path, pkg.Name = value[:j], value[j+1:]
+		} else {
+			pkg.Name = goPackageName(path.Base(value))
+		}
+		if pkg.Path == "" || pkg.Name == "" {
+			return nil, fmt.Errorf("%s:%d: invalid Go package %q", filename, n, value)
+		}
+		if _, ok := m[key]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate mapping of %q", filename, n, key)
+		}
+		m[key] = pkg
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// lookup returns the Go package the gateway code of "file" is generated in,
+// preferring the mapping of the proto file to the one of its Go package.
+func (m standalonePackages) lookup(file *descriptor.File) (descriptor.GoPackage, bool) {
+	if pkg, ok := m[file.GetName()]; ok {
+		return pkg, true
+	}
+	pkg, ok := m[file.GoPkg.Path]
+	return pkg, ok
+}
+
+// standaloneFilenamePrefix returns the prefix of the names of the files
+// generated for "file" in the Go package "pkg". The files generated with
+// paths=import are moved to the directory of "pkg", while the ones generated
+// with paths=source_relative keep the path of the proto file.
+func standaloneFilenamePrefix(file *descriptor.File, pkg descriptor.GoPackage) string {
+	prefix := file.GeneratedFilenamePrefix
+	if path.Dir(prefix) != file.GoPkg.Path {
+		return prefix
+	}
+	return path.Join(pkg.Path, path.Base(prefix))
+}
+
+// goPackageName sanitizes "name" into a Go package name, as protoc-gen-go
+// does for import paths without an explicit package name.
+func goPackageName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	if name != "" && unicode.IsDigit(rune(name[0])) {
+		name = "_" + name
+	}
+	return name
+}
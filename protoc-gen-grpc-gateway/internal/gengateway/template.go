@@ -20,12 +20,36 @@ type param struct {
 	RegisterFuncSuffix string
 	AllowPatchFeature  bool
 	OmitPackageDoc     bool
+	PoolRequest        bool
+	RegisterDynamic    bool
+	ExportPatterns     bool
+	HTTPClient         bool
+	ServerStreamingSSE string
+	BidiWebSocket      string
+	ValidateRequests   bool
+	RequestDefaults    bool
+	Fakes              bool
+	DeprecationHeaders bool
+
+	templates templateSet
 }
 
 type binding struct {
 	*descriptor.Binding
 	Registry          *descriptor.Registry
 	AllowPatchFeature bool
+	PoolRequest       bool
+	ValidateRequests  bool
+	RequestDefaults   bool
+}
+
+// RequestRef returns the expression of a pointer to the request message
+// protoReq, which is a pointer itself if it is taken from a pool.
+func (b binding) RequestRef() string {
+	if b.PoolRequest {
+		return "protoReq"
+	}
+	return "&protoReq"
 }
 
 // GetBodyFieldPath returns the binding body's fieldpath.
@@ -142,15 +166,92 @@ func (f queryParamFilter) String() string {
 	return fmt.Sprintf("&utilities.DoubleArray{Encoding: map[string]int{%s}, Base: %#v, Check: %#v}", e, f.Base, f.Check)
 }
 
+// httpClientService is a service for which a typed HTTP client is generated.
+type httpClientService struct {
+	*descriptor.Service
+	Methods []httpClientMethod
+}
+
+// httpClientMethod is a method of a typed HTTP client, which calls the first
+// binding of the method.
+type httpClientMethod struct {
+	*descriptor.Method
+	Binding binding
+}
+
+// BodyExpr returns the expression of the request body of the client method
+// for the request "in", or "nil" if the binding has no body.
+func (m httpClientMethod) BodyExpr() string {
+	b := m.Binding.Body
+	if b == nil {
+		return "nil"
+	}
+	expr := "in"
+	for _, c := range b.FieldPath {
+		expr += ".Get" + c.AssignableExpr() + "()"
+	}
+	return expr
+}
+
+// ResponseExpr returns the expression the response body is decoded into for
+// the response "out".
+func (m httpClientMethod) ResponseExpr() (string, error) {
+	rb := m.Binding.ResponseBody
+	if rb == nil {
+		return "out", nil
+	}
+	expr := "&out"
+	for i, c := range rb.FieldPath {
+		if c.Target.OneofIndex != nil && !c.Target.GetProto3Optional() {
+			return "", fmt.Errorf("%s: response_body %q is a oneof member, which the HTTP client does not support", m.GetName(), rb.FieldPath.String())
+		}
+		if i < len(rb.FieldPath)-1 {
+			return "", fmt.Errorf("%s: response_body %q is not a top-level field, which the HTTP client does not support", m.GetName(), rb.FieldPath.String())
+		}
+		expr += "." + c.AssignableExpr()
+	}
+	return expr, nil
+}
+
+func httpClientServices(services []*descriptor.Service, reg *descriptor.Registry) []httpClientService {
+	var clients []httpClientService
+	for _, svc := range services {
+		client := httpClientService{Service: svc}
+		for _, m := range svc.Methods {
+			if len(m.Bindings) == 0 || m.GetClientStreaming() || m.GetServerStreaming() {
+				continue
+			}
+			client.Methods = append(client.Methods, httpClientMethod{
+				Method:  m,
+				Binding: binding{Binding: m.Bindings[0], Registry: reg},
+			})
+		}
+		if len(client.Methods) > 0 {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
 type trailerParams struct {
 	Services           []*descriptor.Service
 	UseRequestContext  bool
 	RegisterFuncSuffix string
+	PoolRequest        bool
+	RegisterDynamic    bool
+	ExportPatterns     bool
+	ServerStreamingSSE string
+	BidiWebSocket      string
+	DeprecationHeaders bool
 }
 
 func applyTemplate(p param, reg *descriptor.Registry) (string, error) {
+	tmpls := p.templates
+	if tmpls == nil {
+		tmpls = defaultTemplates
+	}
 	w := bytes.NewBuffer(nil)
-	if err := headerTemplate.Execute(w, p); err != nil {
+	if err := tmpls["header"].Execute(w, p); err != nil {
 		return "", err
 	}
 	var targetServices []*descriptor.Service
@@ -171,19 +272,25 @@ func applyTemplate(p param, reg *descriptor.Registry) (string, error) {
 			meth.Name = &methName
 			for _, b := range meth.Bindings {
 				methodWithBindingsSeen = true
-				if err := handlerTemplate.Execute(w, binding{
+				if err := tmpls["handler"].Execute(w, binding{
 					Binding:           b,
 					Registry:          reg,
 					AllowPatchFeature: p.AllowPatchFeature,
+					PoolRequest:       p.PoolRequest,
+					ValidateRequests:  p.ValidateRequests,
+					RequestDefaults:   p.RequestDefaults,
 				}); err != nil {
 					return "", err
 				}
 
 				// Local
-				if err := localHandlerTemplate.Execute(w, binding{
+				if err := tmpls["local-handler"].Execute(w, binding{
 					Binding:           b,
 					Registry:          reg,
 					AllowPatchFeature: p.AllowPatchFeature,
+					PoolRequest:       p.PoolRequest,
+					ValidateRequests:  p.ValidateRequests,
+					RequestDefaults:   p.RequestDefaults,
 				}); err != nil {
 					return "", err
 				}
@@ -201,15 +308,39 @@ func applyTemplate(p param, reg *descriptor.Registry) (string, error) {
 		Services:           targetServices,
 		UseRequestContext:  p.UseRequestContext,
 		RegisterFuncSuffix: p.RegisterFuncSuffix,
+		PoolRequest:        p.PoolRequest,
+		RegisterDynamic:    p.RegisterDynamic,
+		ExportPatterns:     p.ExportPatterns,
+		ServerStreamingSSE: p.ServerStreamingSSE,
+		BidiWebSocket:      p.BidiWebSocket,
+		DeprecationHeaders: p.DeprecationHeaders,
 	}
 	// Local
-	if err := localTrailerTemplate.Execute(w, tp); err != nil {
+	if err := tmpls["local-trailer"].Execute(w, tp); err != nil {
 		return "", err
 	}
 
-	if err := trailerTemplate.Execute(w, tp); err != nil {
+	if err := tmpls["trailer"].Execute(w, tp); err != nil {
 		return "", err
 	}
+
+	if p.RegisterDynamic {
+		if err := tmpls["dynamic-trailer"].Execute(w, tp); err != nil {
+			return "", err
+		}
+	}
+
+	if p.HTTPClient {
+		if err := tmpls["http-client"].Execute(w, httpClientServices(targetServices, reg)); err != nil {
+			return "", err
+		}
+	}
+
+	if p.Fakes {
+		if err := tmpls["fake"].Execute(w, tp); err != nil {
+			return "", err
+		}
+	}
 	return w.String(), nil
 }
 
@@ -275,6 +406,16 @@ func request_{{.Method.Service.GetName}}_{{.Method.GetName}}_{{.Index}}(ctx cont
 			grpclog.Infof("Failed to decode request: %v", err)
 			return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", err)
 		}
+		{{- if .RequestDefaults}}
+		if err := runtime.PopulateRequestDefaults(ctx, &protoReq); err != nil {
+			return nil, metadata, err
+		}
+		{{- end}}
+		{{- if .ValidateRequests}}
+		if err := runtime.ValidateRequest(ctx, &protoReq); err != nil {
+			return nil, metadata, err
+		}
+		{{- end}}
 		if err = stream.Send(&protoReq); err != nil {
 			if err == io.EOF {
 				break
@@ -312,14 +453,24 @@ var (
 )
 {{end}}
 {{template "request-func-signature" .}} {
+{{- if .PoolRequest}}
+	protoReq := pool_{{.Method.Service.GetName}}_{{.Method.GetName}}.Get().(*{{.Method.RequestType.GoType .Method.Service.File.GoPkg.Path}})
+	defer pool_{{.Method.Service.GetName}}_{{.Method.GetName}}.Put(protoReq)
+{{- else}}
 	var protoReq {{.Method.RequestType.GoType .Method.Service.File.GoPkg.Path}}
+{{- end}}
 	var metadata runtime.ServerMetadata
+{{if .RejectBody}}
+	if err := runtime.RejectRequestBody(req); err != nil {
+		return nil, metadata, err
+	}
+{{end}}
 {{if .Body}}
 	newReader, berr := utilities.IOReaderFactory(req.Body)
 	if berr != nil {
 		return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", berr)
 	}
-	if err := marshaler.NewDecoder(newReader()).Decode(&{{.Body.AssignableExpr "protoReq"}}); err != nil && err != io.EOF  {
+	if err := marshaler.NewDecoder(newReader()).Decode({{if and .PoolRequest (eq "*" .GetBodyFieldPath)}}protoReq{{else}}&{{.Body.AssignableExpr "protoReq"}}{{end}}); err != nil && err != io.EOF  {
 		return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", err)
 	}
 	{{- if and $AllowPatchFeature (eq (.HTTPMethod) "PATCH") (.FieldMaskField) (not (eq "*" .GetBodyFieldPath)) }}
@@ -353,7 +504,7 @@ var (
 		return nil, metadata, status.Errorf(codes.InvalidArgument, "missing parameter %s", {{$param | printf "%q"}})
 	}
 {{if $param.IsNestedProto3}}
-	err = runtime.PopulateFieldFromPath(&protoReq, {{$param | printf "%q"}}, val)
+	err = runtime.PopulateFieldFromPath({{$binding.RequestRef}}, {{$param | printf "%q"}}, val)
 	if err != nil {
 		return nil, metadata, status.Errorf(codes.InvalidArgument, "type mismatch, parameter: %s, error: %v", {{$param | printf "%q"}}, err)
 	}
@@ -389,12 +540,21 @@ var (
 	if err := req.ParseForm(); err != nil {
 		return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", err)
 	}
-	if err := runtime.PopulateQueryParameters(&protoReq, req.Form, filter_{{.Method.Service.GetName}}_{{.Method.GetName}}_{{.Index}}); err != nil {
+	if err := runtime.PopulateQueryParameters({{.RequestRef}}, req.Form, filter_{{.Method.Service.GetName}}_{{.Method.GetName}}_{{.Index}}); err != nil {
 		return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", err)
 	}
 {{end}}
+{{if .RequestDefaults}}
+	if err := runtime.PopulateRequestDefaults(ctx, {{.RequestRef}}); err != nil {
+		return nil, metadata, err
+	}
+{{end}}{{if .ValidateRequests}}
+	if err := runtime.ValidateRequest(ctx, {{.RequestRef}}); err != nil {
+		return nil, metadata, err
+	}
+{{end}}
 {{if .Method.GetServerStreaming}}
-	stream, err := client.{{.Method.GetName}}(ctx, &protoReq)
+	stream, err := client.{{.Method.GetName}}(ctx, {{.RequestRef}})
 	if err != nil {
 		return nil, metadata, err
 	}
@@ -405,7 +565,7 @@ var (
 	metadata.HeaderMD = header
 	return stream, metadata, nil
 {{else}}
-	msg, err := client.{{.Method.GetName}}(ctx, &protoReq, grpc.Header(&metadata.HeaderMD), grpc.Trailer(&metadata.TrailerMD))
+	msg, err := client.{{.Method.GetName}}(ctx, {{.RequestRef}}, grpc.Header(&metadata.HeaderMD), grpc.Trailer(&metadata.TrailerMD))
 	return msg, metadata, err
 {{end}}
 }`))
@@ -429,6 +589,16 @@ var (
 			grpclog.Infof("Failed to decode request: %v", err)
 			return err
 		}
+		{{- if .RequestDefaults}}
+		if err := runtime.PopulateRequestDefaults(ctx, &protoReq); err != nil {
+			return err
+		}
+		{{- end}}
+		{{- if .ValidateRequests}}
+		if err := runtime.ValidateRequest(ctx, &protoReq); err != nil {
+			return err
+		}
+		{{- end}}
 		if err := stream.Send(&protoReq); err != nil {
 			grpclog.Infof("Failed to send request: %v", err)
 			return err
@@ -482,14 +652,24 @@ func local_request_{{.Method.Service.GetName}}_{{.Method.GetName}}_{{.Index}}(ct
 	_ = template.Must(localHandlerTemplate.New("local-client-rpc-request-func").Parse(`
 {{$AllowPatchFeature := .AllowPatchFeature}}
 {{template "local-request-func-signature" .}} {
+{{- if .PoolRequest}}
+	protoReq := pool_{{.Method.Service.GetName}}_{{.Method.GetName}}.Get().(*{{.Method.RequestType.GoType .Method.Service.File.GoPkg.Path}})
+	defer pool_{{.Method.Service.GetName}}_{{.Method.GetName}}.Put(protoReq)
+{{- else}}
 	var protoReq {{.Method.RequestType.GoType .Method.Service.File.GoPkg.Path}}
+{{- end}}
 	var metadata runtime.ServerMetadata
+{{if .RejectBody}}
+	if err := runtime.RejectRequestBody(req); err != nil {
+		return nil, metadata, err
+	}
+{{end}}
 {{if .Body}}
 	newReader, berr := utilities.IOReaderFactory(req.Body)
 	if berr != nil {
 		return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", berr)
 	}
-	if err := marshaler.NewDecoder(newReader()).Decode(&{{.Body.AssignableExpr "protoReq"}}); err != nil && err != io.EOF  {
+	if err := marshaler.NewDecoder(newReader()).Decode({{if and .PoolRequest (eq "*" .GetBodyFieldPath)}}protoReq{{else}}&{{.Body.AssignableExpr "protoReq"}}{{end}}); err != nil && err != io.EOF  {
 		return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", err)
 	}
 	{{- if and $AllowPatchFeature (eq (.HTTPMethod) "PATCH") (.FieldMaskField) (not (eq "*" .GetBodyFieldPath)) }}
@@ -523,7 +703,7 @@ func local_request_{{.Method.Service.GetName}}_{{.Method.GetName}}_{{.Index}}(ct
 		return nil, metadata, status.Errorf(codes.InvalidArgument, "missing parameter %s", {{$param | printf "%q"}})
 	}
 {{if $param.IsNestedProto3}}
-	err = runtime.PopulateFieldFromPath(&protoReq, {{$param | printf "%q"}}, val)
+	err = runtime.PopulateFieldFromPath({{$binding.RequestRef}}, {{$param | printf "%q"}}, val)
 	if err != nil {
 		return nil, metadata, status.Errorf(codes.InvalidArgument, "type mismatch, parameter: %s, error: %v", {{$param | printf "%q"}}, err)
 	}
@@ -560,14 +740,23 @@ func local_request_{{.Method.Service.GetName}}_{{.Method.GetName}}_{{.Index}}(ct
 	if err := req.ParseForm(); err != nil {
 		return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", err)
 	}
-	if err := runtime.PopulateQueryParameters(&protoReq, req.Form, filter_{{.Method.Service.GetName}}_{{.Method.GetName}}_{{.Index}}); err != nil {
+	if err := runtime.PopulateQueryParameters({{.RequestRef}}, req.Form, filter_{{.Method.Service.GetName}}_{{.Method.GetName}}_{{.Index}}); err != nil {
 		return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", err)
 	}
 {{end}}
+{{if .RequestDefaults}}
+	if err := runtime.PopulateRequestDefaults(ctx, {{.RequestRef}}); err != nil {
+		return nil, metadata, err
+	}
+{{end}}{{if .ValidateRequests}}
+	if err := runtime.ValidateRequest(ctx, {{.RequestRef}}); err != nil {
+		return nil, metadata, err
+	}
+{{end}}
 {{if .Method.GetServerStreaming}}
 	// TODO
 {{else}}
-	msg, err := server.{{.Method.GetName}}(ctx, &protoReq)
+	msg, err := server.{{.Method.GetName}}(ctx, {{.RequestRef}})
 	return msg, metadata, err
 {{end}}
 }`))
@@ -605,6 +794,9 @@ func Register{{$svc.GetName}}{{$.RegisterFuncSuffix}}Server(ctx context.Context,
 			runtime.HTTPError(ctx, mux, outboundMarshaler, w, req, err)
 			return
 		}
+		{{- if and $.DeprecationHeaders $m.IsDeprecated}}
+		runtime.MarkDeprecated(rctx, mux, w, req)
+		{{- end}}
 		resp, md, err := local_request_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}}(rctx, inboundMarshaler, server, req, pathParams)
 		md.HeaderMD, md.TrailerMD = metadata.Join(md.HeaderMD, stream.Header()), metadata.Join(md.TrailerMD, stream.Trailer())
 		ctx = runtime.NewServerMetadataContext(ctx, md)
@@ -668,7 +860,8 @@ func Register{{$svc.GetName}}{{$.RegisterFuncSuffix}}(ctx context.Context, mux *
 func Register{{$svc.GetName}}{{$.RegisterFuncSuffix}}Client(ctx context.Context, mux *runtime.ServeMux, client {{$svc.InstanceName}}Client) error {
 	{{range $m := $svc.Methods}}
 	{{range $b := $m.Bindings}}
-	mux.Handle({{$b.HTTPMethod | printf "%q"}}, pattern_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}}, func(w http.ResponseWriter, req *http.Request, pathParams map[string]string) {
+	{{- $webSocket := "" }}{{ if and $m.GetClientStreaming $m.GetServerStreaming }}{{ $webSocket = $.BidiWebSocket }}{{ end }}
+	{{if eq $webSocket "alongside"}}mux.HandleWithWebSocket({{$b.HTTPMethod | printf "%q"}}, {{else if eq $webSocket "replace"}}mux.HandleWebSocket({{else}}mux.Handle({{$b.HTTPMethod | printf "%q"}}, {{end}}pattern_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}}, func(w http.ResponseWriter, req *http.Request, pathParams map[string]string) {
 	{{- if $UseRequestContext }}
 		ctx, cancel := context.WithCancel(req.Context())
 	{{- else -}}
@@ -681,6 +874,9 @@ func Register{{$svc.GetName}}{{$.RegisterFuncSuffix}}Client(ctx context.Context,
 			runtime.HTTPError(ctx, mux, outboundMarshaler, w, req, err)
 			return
 		}
+		{{- if and $.DeprecationHeaders $m.IsDeprecated}}
+		runtime.MarkDeprecated(rctx, mux, w, req)
+		{{- end}}
 		resp, md, err := request_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}}(rctx, inboundMarshaler, client, req, pathParams)
 		ctx = runtime.NewServerMetadataContext(ctx, md)
 		if err != nil {
@@ -732,12 +928,192 @@ var (
 	{{end}}
 )
 
+{{if $.ExportPatterns}}
 var (
 	{{range $m := $svc.Methods}}
 	{{range $b := $m.Bindings}}
-	forward_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}} = {{if $m.GetServerStreaming}}runtime.ForwardResponseStream{{else}}runtime.ForwardResponseMessage{{end}}
+	// Pattern_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}} is the path pattern of the route {{$b.HTTPMethod}} {{$b.PathTmpl.Template}} of {{$svc.GetName}}.{{$m.GetName}},
+	// e.g. to deregister it from a runtime.ServeMuxDynamic with HandlerDeregister.
+	Pattern_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}} = pattern_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}}
+	{{end}}
+	{{end}}
+)
+
+const (
+	{{range $m := $svc.Methods}}
+	{{range $b := $m.Bindings}}
+	// Method_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}} is the HTTP method of the route matching Pattern_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}}.
+	Method_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}} = {{$b.HTTPMethod | printf "%q"}}
 	{{end}}
 	{{end}}
 )
+{{end}}
+
+{{if $.PoolRequest}}
+var (
+	{{range $m := $svc.Methods}}
+	{{if and $m.Bindings (not $m.GetClientStreaming)}}
+	pool_{{$svc.GetName}}_{{$m.GetName}} = runtime.NewMessagePool(func() proto.Message { return new({{$m.RequestType.GoType $m.Service.File.GoPkg.Path}}) })
+	{{end}}
+	{{end}}
+)
+{{end}}
+
+var (
+	{{range $m := $svc.Methods}}
+	{{range $b := $m.Bindings}}
+	forward_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}} = {{if $m.GetServerStreaming}}{{if $m.GetClientStreaming}}runtime.ForwardResponseStream{{else if eq $.ServerStreamingSSE "alongside"}}runtime.ForwardResponseStreamOrSSE{{else if eq $.ServerStreamingSSE "replace"}}runtime.ForwardResponseStreamSSE{{else}}runtime.ForwardResponseStream{{end}}{{else}}runtime.ForwardResponseMessage{{end}}
+	{{end}}
+	{{end}}
+)
+{{end}}`))
+
+	dynamicTrailerTemplate = template.Must(template.New("dynamic-trailer").Parse(`
+{{$UseRequestContext := .UseRequestContext}}
+{{range $svc := .Services}}
+// Register{{$svc.GetName}}{{$.RegisterFuncSuffix}}Dynamic registers the http handlers for service {{$svc.GetName}} to the dynamic "mux",
+// like Register{{$svc.GetName}}{{$.RegisterFuncSuffix}}. The returned "deregister" removes exactly these handlers from "mux"
+// again; it does not close "conn".
+func Register{{$svc.GetName}}{{$.RegisterFuncSuffix}}Dynamic(ctx context.Context, mux *runtime.ServeMuxDynamic, conn *grpc.ClientConn, opts ...runtime.RouteOption) (deregister func(), err error) {
+	return Register{{$svc.GetName}}{{$.RegisterFuncSuffix}}ClientDynamic(ctx, mux, {{$svc.ClientConstructorName}}(conn), opts...)
+}
+
+// Register{{$svc.GetName}}{{$.RegisterFuncSuffix}}ClientDynamic registers the http handlers for service {{$svc.GetName}}
+// to the dynamic "mux", like Register{{$svc.GetName}}{{$.RegisterFuncSuffix}}Client. The route options "opts" apply to
+// every handler. The returned "deregister" removes exactly these handlers from "mux" again.
+func Register{{$svc.GetName}}{{$.RegisterFuncSuffix}}ClientDynamic(ctx context.Context, mux *runtime.ServeMuxDynamic, client {{$svc.InstanceName}}Client, opts ...runtime.RouteOption) (deregister func(), err error) {
+	var deregisters []func()
+	{{range $m := $svc.Methods}}
+	{{range $b := $m.Bindings}}
+	deregisters = append(deregisters, mux.HandleWithDeregister({{$b.HTTPMethod | printf "%q"}}, pattern_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}}, func(w http.ResponseWriter, req *http.Request, pathParams map[string]string) {
+	{{- if $UseRequestContext }}
+		ctx, cancel := context.WithCancel(req.Context())
+	{{- else -}}
+		ctx, cancel := context.WithCancel(ctx)
+	{{- end }}
+		defer cancel()
+		inboundMarshaler, outboundMarshaler := runtime.MarshalerForRequest(mux.ServeMux, req)
+		rctx, err := runtime.AnnotateContext(ctx, mux.ServeMux, req, "/{{$svc.File.GetPackage}}.{{$svc.GetName}}/{{$m.GetName}}")
+		if err != nil {
+			runtime.HTTPError(ctx, mux.ServeMux, outboundMarshaler, w, req, err)
+			return
+		}
+		{{- if and $.DeprecationHeaders $m.IsDeprecated}}
+		runtime.MarkDeprecated(rctx, mux.ServeMux, w, req)
+		{{- end}}
+		resp, md, err := request_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}}(rctx, inboundMarshaler, client, req, pathParams)
+		ctx = runtime.NewServerMetadataContext(ctx, md)
+		if err != nil {
+			runtime.HTTPError(ctx, mux.ServeMux, outboundMarshaler, w, req, err)
+			return
+		}
+		{{if $m.GetServerStreaming}}
+		{{ if $b.ResponseBody }}
+		forward_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}}(ctx, mux.ServeMux, outboundMarshaler, w, req, func() (proto.Message, error) {
+			res, err := resp.Recv()
+			return response_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}}{res}, err
+		}, mux.GetForwardResponseOptions()...)
+		{{ else }}
+		forward_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}}(ctx, mux.ServeMux, outboundMarshaler, w, req, func() (proto.Message, error) { return resp.Recv() }, mux.GetForwardResponseOptions()...)
+		{{end}}
+		{{else}}
+		{{ if $b.ResponseBody }}
+		forward_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}}(ctx, mux.ServeMux, outboundMarshaler, w, req, response_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}}{resp}, mux.GetForwardResponseOptions()...)
+		{{ else }}
+		forward_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}}(ctx, mux.ServeMux, outboundMarshaler, w, req, resp, mux.GetForwardResponseOptions()...)
+		{{end}}
+		{{end}}
+	}, opts...))
+	{{end}}
+	{{end}}
+	return func() {
+		for _, deregister := range deregisters {
+			deregister()
+		}
+	}, nil
+}
+{{end}}`))
+
+	httpClientTemplate = template.Must(template.New("http-client").Parse(`
+{{range $svc := .}}
+// {{$svc.GetName}}HTTPClient is the client API for {{$svc.GetName}} service over the REST API its gateway exposes.
+// Methods call the first HTTP rule of their RPC; streaming RPCs are not part of it.
+type {{$svc.GetName}}HTTPClient interface {
+	{{range $m := $svc.Methods}}
+	{{$m.GetName}}(ctx context.Context, in *{{$m.RequestType.GoType $m.Service.File.GoPkg.Path}}) (*{{$m.ResponseType.GoType $m.Service.File.GoPkg.Path}}, error)
+	{{end}}
+}
+
+type httpClient_{{$svc.GetName}} struct {
+	client *runtime.HTTPClient
+}
+
+// New{{$svc.GetName}}HTTPClient returns a {{$svc.GetName}}HTTPClient sending requests through "client".
+func New{{$svc.GetName}}HTTPClient(client *runtime.HTTPClient) {{$svc.GetName}}HTTPClient {
+	return &httpClient_{{$svc.GetName}}{client: client}
+}
+
+{{range $m := $svc.Methods}}
+{{$b := $m.Binding}}
+func (c *httpClient_{{$svc.GetName}}) {{$m.GetName}}(ctx context.Context, in *{{$m.RequestType.GoType $m.Service.File.GoPkg.Path}}) (*{{$m.ResponseType.GoType $m.Service.File.GoPkg.Path}}, error) {
+	path, err := runtime.ExpandPathTemplate({{$b.PathTmpl.Template | printf "%q"}}, in)
+	if err != nil {
+		return nil, err
+	}
+	{{- if $b.HasQueryParam}}
+	query, err := runtime.QueryParametersFromMessage(in, filter_{{$svc.GetName}}_{{$m.GetName}}_{{$b.Index}})
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	{{- end}}
+	out := new({{$m.ResponseType.GoType $m.Service.File.GoPkg.Path}})
+	if err := c.client.Do(ctx, {{$b.HTTPMethod | printf "%q"}}, path, {{if $b.HasQueryParam}}query{{else}}nil{{end}}, {{$m.BodyExpr}}, {{$m.ResponseExpr}}); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+{{end}}
+{{end}}`))
+
+	fakeTemplate = template.Must(template.New("fake").Parse(`
+{{range $svc := .Services}}
+// {{$svc.GetName}}Fake is a fake {{$svc.GetName}}Server for testing clients of the gateway of {{$svc.GetName}} service.
+// It records the request messages of its unary methods and replies with canned responses, see runtime.FakeMethod.
+// Other methods reply codes.Unimplemented.
+type {{$svc.GetName}}Fake struct {
+	{{if $svc.ForcePrefixedName}}{{$svc.File.Pkg}}.{{end}}Unimplemented{{$svc.GetName}}Server
+	{{range $m := $svc.Methods}}
+	{{if and $m.Bindings (not $m.GetClientStreaming) (not $m.GetServerStreaming)}}
+	{{$m.GetName}}Calls runtime.FakeMethod
+	{{end}}
+	{{end}}
+}
+
+{{range $m := $svc.Methods}}
+{{if and $m.Bindings (not $m.GetClientStreaming) (not $m.GetServerStreaming)}}
+func (f *{{$svc.GetName}}Fake) {{$m.GetName}}(ctx context.Context, in *{{$m.RequestType.GoType $m.Service.File.GoPkg.Path}}) (*{{$m.ResponseType.GoType $m.Service.File.GoPkg.Path}}, error) {
+	resp, err := f.{{$m.GetName}}Calls.Call(ctx, in, func() proto.Message { return new({{$m.ResponseType.GoType $m.Service.File.GoPkg.Path}}) })
+	if err != nil {
+		return nil, err
+	}
+	out, ok := resp.(*{{$m.ResponseType.GoType $m.Service.File.GoPkg.Path}})
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "fake response of {{$m.GetName}} is %T; want *{{$m.ResponseType.GoType $m.Service.File.GoPkg.Path}}", resp)
+	}
+	return out, nil
+}
+{{end}}
+{{end}}
+
+// New{{$svc.GetName}}FakeGateway starts an httptest.Server serving the gateway of {{$svc.GetName}} service
+// with "fake" as the server, as registered by Register{{$svc.GetName}}{{$.RegisterFuncSuffix}}Server.
+// The caller should close the server when done.
+func New{{$svc.GetName}}FakeGateway(fake *{{$svc.GetName}}Fake, opts ...runtime.ServeMuxOption) (*httptest.Server, error) {
+	mux := runtime.NewServeMux(opts...)
+	if err := Register{{$svc.GetName}}{{$.RegisterFuncSuffix}}Server(context.Background(), mux, fake); err != nil {
+		return nil, err
+	}
+	return httptest.NewServer(mux), nil
+}
 {{end}}`))
 )
@@ -1,6 +1,7 @@
 package gengateway
 
 import (
+	"go/format"
 	"strings"
 	"testing"
 
@@ -661,6 +662,643 @@ func TestAllowPatchFeature(t *testing.T) {
 	}
 }
 
+func TestPoolRequest(t *testing.T) {
+	msgdesc := &descriptorpb.DescriptorProto{
+		Name: proto.String("ExampleMessage"),
+	}
+	meth := &descriptorpb.MethodDescriptorProto{
+		Name:       proto.String("Example"),
+		InputType:  proto.String("ExampleMessage"),
+		OutputType: proto.String("ExampleMessage"),
+	}
+	svc := &descriptorpb.ServiceDescriptorProto{
+		Name:   proto.String("ExampleService"),
+		Method: []*descriptorpb.MethodDescriptorProto{meth},
+	}
+	msg := &descriptor.Message{
+		DescriptorProto: msgdesc,
+	}
+	file := descriptor.File{
+		FileDescriptorProto: &descriptorpb.FileDescriptorProto{
+			Name:        proto.String("example.proto"),
+			Package:     proto.String("example"),
+			MessageType: []*descriptorpb.DescriptorProto{msgdesc},
+			Service:     []*descriptorpb.ServiceDescriptorProto{svc},
+		},
+		GoPkg: descriptor.GoPackage{
+			Path: "example.com/path/to/example/example.pb",
+			Name: "example_pb",
+		},
+		Messages: []*descriptor.Message{msg},
+		Services: []*descriptor.Service{
+			{
+				ServiceDescriptorProto: svc,
+				Methods: []*descriptor.Method{
+					{
+						MethodDescriptorProto: meth,
+						RequestType:           msg,
+						ResponseType:          msg,
+						Bindings: []*descriptor.Binding{
+							{
+								HTTPMethod: "POST",
+								Body:       &descriptor.Body{FieldPath: nil},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, spec := range []struct {
+		poolRequest bool
+		want        []string
+	}{
+		{
+			poolRequest: false,
+			want: []string{
+				"var protoReq ExampleMessage\n",
+				"marshaler.NewDecoder(newReader()).Decode(&protoReq)",
+				"client.Example(ctx, &protoReq, ",
+				"server.Example(ctx, &protoReq)",
+			},
+		},
+		{
+			poolRequest: true,
+			want: []string{
+				"protoReq := pool_ExampleService_Example.Get().(*ExampleMessage)\n",
+				"defer pool_ExampleService_Example.Put(protoReq)\n",
+				"marshaler.NewDecoder(newReader()).Decode(protoReq)",
+				"client.Example(ctx, protoReq, ",
+				"server.Example(ctx, protoReq)",
+				"pool_ExampleService_Example = runtime.NewMessagePool(func() proto.Message { return new(ExampleMessage) })",
+			},
+		},
+	} {
+		got, err := applyTemplate(param{File: crossLinkFixture(&file), RegisterFuncSuffix: "Handler", PoolRequest: spec.poolRequest}, descriptor.NewRegistry())
+		if err != nil {
+			t.Errorf("applyTemplate(%#v) failed with %v; want success", file, err)
+			return
+		}
+		for _, want := range spec.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("applyTemplate(%#v) with PoolRequest %v = %s; want to contain %s", file, spec.poolRequest, got, want)
+			}
+		}
+		if _, err := format.Source([]byte(got)); err != nil {
+			t.Errorf("format.Source(applyTemplate(%#v)) with PoolRequest %v failed with %v; want success", file, spec.poolRequest, err)
+		}
+	}
+}
+
+func TestRegisterDynamic(t *testing.T) {
+	msgdesc := &descriptorpb.DescriptorProto{
+		Name: proto.String("ExampleMessage"),
+	}
+	meth := &descriptorpb.MethodDescriptorProto{
+		Name:       proto.String("Example"),
+		InputType:  proto.String("ExampleMessage"),
+		OutputType: proto.String("ExampleMessage"),
+	}
+	streamMeth := &descriptorpb.MethodDescriptorProto{
+		Name:            proto.String("ExampleStream"),
+		InputType:       proto.String("ExampleMessage"),
+		OutputType:      proto.String("ExampleMessage"),
+		ServerStreaming: proto.Bool(true),
+	}
+	svc := &descriptorpb.ServiceDescriptorProto{
+		Name:   proto.String("ExampleService"),
+		Method: []*descriptorpb.MethodDescriptorProto{meth, streamMeth},
+	}
+	msg := &descriptor.Message{
+		DescriptorProto: msgdesc,
+	}
+	file := descriptor.File{
+		FileDescriptorProto: &descriptorpb.FileDescriptorProto{
+			Name:        proto.String("example.proto"),
+			Package:     proto.String("example"),
+			MessageType: []*descriptorpb.DescriptorProto{msgdesc},
+			Service:     []*descriptorpb.ServiceDescriptorProto{svc},
+		},
+		GoPkg: descriptor.GoPackage{
+			Path: "example.com/path/to/example/example.pb",
+			Name: "example_pb",
+		},
+		Messages: []*descriptor.Message{msg},
+		Services: []*descriptor.Service{
+			{
+				ServiceDescriptorProto: svc,
+				Methods: []*descriptor.Method{
+					{
+						MethodDescriptorProto: meth,
+						RequestType:           msg,
+						ResponseType:          msg,
+						Bindings: []*descriptor.Binding{
+							{
+								HTTPMethod: "POST",
+								Body:       &descriptor.Body{FieldPath: nil},
+							},
+						},
+					},
+					{
+						MethodDescriptorProto: streamMeth,
+						RequestType:           msg,
+						ResponseType:          msg,
+						Bindings: []*descriptor.Binding{
+							{
+								HTTPMethod: "GET",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	want := []string{
+		"func RegisterExampleServiceHandlerDynamic(ctx context.Context, mux *runtime.ServeMuxDynamic, conn *grpc.ClientConn, opts ...runtime.RouteOption) (deregister func(), err error) {",
+		"return RegisterExampleServiceHandlerClientDynamic(ctx, mux, NewExampleServiceClient(conn), opts...)",
+		"func RegisterExampleServiceHandlerClientDynamic(ctx context.Context, mux *runtime.ServeMuxDynamic, client ExampleServiceClient, opts ...runtime.RouteOption) (deregister func(), err error) {",
+		`deregisters = append(deregisters, mux.HandleWithDeregister("POST", pattern_ExampleService_Example_0, func(`,
+		`deregisters = append(deregisters, mux.HandleWithDeregister("GET", pattern_ExampleService_ExampleStream_0, func(`,
+		"forward_ExampleService_Example_0(ctx, mux.ServeMux, outboundMarshaler, w, req, resp, mux.GetForwardResponseOptions()...)",
+		"forward_ExampleService_ExampleStream_0(ctx, mux.ServeMux, outboundMarshaler, w, req, func() (proto.Message, error) { return resp.Recv() }, mux.GetForwardResponseOptions()...)",
+	}
+	for _, registerDynamic := range []bool{false, true} {
+		got, err := applyTemplate(param{File: crossLinkFixture(&file), RegisterFuncSuffix: "Handler", RegisterDynamic: registerDynamic}, descriptor.NewRegistry())
+		if err != nil {
+			t.Errorf("applyTemplate(%#v) failed with %v; want success", file, err)
+			return
+		}
+		for _, want := range want {
+			if strings.Contains(got, want) != registerDynamic {
+				t.Errorf("applyTemplate(%#v) with RegisterDynamic %v = %s; want to contain %s: %v", file, registerDynamic, got, want, registerDynamic)
+			}
+		}
+		if _, err := format.Source([]byte(got)); err != nil {
+			t.Errorf("format.Source(applyTemplate(%#v)) with RegisterDynamic %v failed with %v; want success", file, registerDynamic, err)
+		}
+	}
+}
+
+func TestExportPatterns(t *testing.T) {
+	msgdesc := &descriptorpb.DescriptorProto{
+		Name: proto.String("ExampleMessage"),
+	}
+	meth := &descriptorpb.MethodDescriptorProto{
+		Name:       proto.String("Example"),
+		InputType:  proto.String("ExampleMessage"),
+		OutputType: proto.String("ExampleMessage"),
+	}
+	svc := &descriptorpb.ServiceDescriptorProto{
+		Name:   proto.String("ExampleService"),
+		Method: []*descriptorpb.MethodDescriptorProto{meth},
+	}
+	msg := &descriptor.Message{
+		DescriptorProto: msgdesc,
+	}
+	file := descriptor.File{
+		FileDescriptorProto: &descriptorpb.FileDescriptorProto{
+			Name:        proto.String("example.proto"),
+			Package:     proto.String("example"),
+			MessageType: []*descriptorpb.DescriptorProto{msgdesc},
+			Service:     []*descriptorpb.ServiceDescriptorProto{svc},
+		},
+		GoPkg: descriptor.GoPackage{
+			Path: "example.com/path/to/example/example.pb",
+			Name: "example_pb",
+		},
+		Messages: []*descriptor.Message{msg},
+		Services: []*descriptor.Service{
+			{
+				ServiceDescriptorProto: svc,
+				Methods: []*descriptor.Method{
+					{
+						MethodDescriptorProto: meth,
+						RequestType:           msg,
+						ResponseType:          msg,
+						Bindings: []*descriptor.Binding{
+							{
+								HTTPMethod: "GET",
+							},
+							{
+								Index:      1,
+								HTTPMethod: "POST",
+								Body:       &descriptor.Body{FieldPath: nil},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	want := []string{
+		"Pattern_ExampleService_Example_0 = pattern_ExampleService_Example_0\n",
+		"Pattern_ExampleService_Example_1 = pattern_ExampleService_Example_1\n",
+		`Method_ExampleService_Example_0 = "GET"`,
+		`Method_ExampleService_Example_1 = "POST"`,
+	}
+	for _, exportPatterns := range []bool{false, true} {
+		got, err := applyTemplate(param{File: crossLinkFixture(&file), RegisterFuncSuffix: "Handler", ExportPatterns: exportPatterns}, descriptor.NewRegistry())
+		if err != nil {
+			t.Errorf("applyTemplate(%#v) failed with %v; want success", file, err)
+			return
+		}
+		for _, want := range want {
+			if strings.Contains(got, want) != exportPatterns {
+				t.Errorf("applyTemplate(%#v) with ExportPatterns %v = %s; want to contain %s: %v", file, exportPatterns, got, want, exportPatterns)
+			}
+		}
+		if _, err := format.Source([]byte(got)); err != nil {
+			t.Errorf("format.Source(applyTemplate(%#v)) with ExportPatterns %v failed with %v; want success", file, exportPatterns, err)
+		}
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	nesteddesc := &descriptorpb.DescriptorProto{
+		Name: proto.String("NestedMessage"),
+	}
+	msgdesc := &descriptorpb.DescriptorProto{
+		Name: proto.String("ExampleMessage"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			{
+				Name:   proto.String("id"),
+				Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Number: proto.Int32(1),
+			},
+			{
+				Name:     proto.String("payload"),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String("NestedMessage"),
+				Number:   proto.Int32(2),
+			},
+		},
+	}
+	meth := &descriptorpb.MethodDescriptorProto{
+		Name:       proto.String("Example"),
+		InputType:  proto.String("ExampleMessage"),
+		OutputType: proto.String("ExampleMessage"),
+	}
+	streamMeth := &descriptorpb.MethodDescriptorProto{
+		Name:            proto.String("Watch"),
+		InputType:       proto.String("ExampleMessage"),
+		OutputType:      proto.String("ExampleMessage"),
+		ServerStreaming: proto.Bool(true),
+	}
+	svc := &descriptorpb.ServiceDescriptorProto{
+		Name:   proto.String("ExampleService"),
+		Method: []*descriptorpb.MethodDescriptorProto{meth, streamMeth},
+	}
+	nested := &descriptor.Message{
+		DescriptorProto: nesteddesc,
+	}
+	msg := &descriptor.Message{
+		DescriptorProto: msgdesc,
+	}
+	for _, f := range msgdesc.Field {
+		msg.Fields = append(msg.Fields, &descriptor.Field{Message: msg, FieldDescriptorProto: f})
+	}
+	payload := descriptor.FieldPath{descriptor.FieldPathComponent{Name: "payload", Target: msg.Fields[1]}}
+	file := descriptor.File{
+		FileDescriptorProto: &descriptorpb.FileDescriptorProto{
+			Name:        proto.String("example.proto"),
+			Package:     proto.String("example"),
+			MessageType: []*descriptorpb.DescriptorProto{msgdesc, nesteddesc},
+			Service:     []*descriptorpb.ServiceDescriptorProto{svc},
+		},
+		GoPkg: descriptor.GoPackage{
+			Path: "example.com/path/to/example/example.pb",
+			Name: "example_pb",
+		},
+		Messages: []*descriptor.Message{msg, nested},
+		Services: []*descriptor.Service{
+			{
+				ServiceDescriptorProto: svc,
+				Methods: []*descriptor.Method{
+					{
+						MethodDescriptorProto: meth,
+						RequestType:           msg,
+						ResponseType:          msg,
+						Bindings: []*descriptor.Binding{
+							{
+								HTTPMethod: "POST",
+								PathTmpl: httprule.Template{
+									Version:  1,
+									OpCodes:  []int{0, 0},
+									Template: "/v1/example",
+								},
+								Body:         &descriptor.Body{FieldPath: payload},
+								ResponseBody: &descriptor.Body{FieldPath: payload},
+							},
+							{
+								Index:      1,
+								HTTPMethod: "PUT",
+								Body:       &descriptor.Body{FieldPath: nil},
+							},
+						},
+					},
+					{
+						MethodDescriptorProto: streamMeth,
+						RequestType:           msg,
+						ResponseType:          msg,
+						Bindings: []*descriptor.Binding{
+							{
+								HTTPMethod: "GET",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	want := []string{
+		"type ExampleServiceHTTPClient interface {\n\tExample(ctx context.Context, in *ExampleMessage) (*ExampleMessage, error)\n}",
+		"func NewExampleServiceHTTPClient(client *runtime.HTTPClient) ExampleServiceHTTPClient {",
+		`path, err := runtime.ExpandPathTemplate("/v1/example", in)`,
+		"query, err := runtime.QueryParametersFromMessage(in, filter_ExampleService_Example_0)",
+		`c.client.Do(ctx, "POST", path, query, in.GetPayload(), &out.Payload)`,
+	}
+	for _, httpClient := range []bool{false, true} {
+		got, err := applyTemplate(param{File: crossLinkFixture(&file), RegisterFuncSuffix: "Handler", HTTPClient: httpClient}, descriptor.NewRegistry())
+		if err != nil {
+			t.Errorf("applyTemplate(%#v) failed with %v; want success", file, err)
+			return
+		}
+		formatted, err := format.Source([]byte(got))
+		if err != nil {
+			t.Errorf("format.Source(applyTemplate(%#v)) with HTTPClient %v failed with %v; want success", file, httpClient, err)
+			continue
+		}
+		for _, want := range want {
+			if strings.Contains(string(formatted), want) != httpClient {
+				t.Errorf("applyTemplate(%#v) with HTTPClient %v = %s; want to contain %s: %v", file, httpClient, formatted, want, httpClient)
+			}
+		}
+	}
+}
+
+func TestApplyTemplateStreamTransports(t *testing.T) {
+	msgdesc := &descriptorpb.DescriptorProto{
+		Name: proto.String("ExampleMessage"),
+	}
+	watchMeth := &descriptorpb.MethodDescriptorProto{
+		Name:            proto.String("Watch"),
+		InputType:       proto.String("ExampleMessage"),
+		OutputType:      proto.String("ExampleMessage"),
+		ServerStreaming: proto.Bool(true),
+	}
+	chatMeth := &descriptorpb.MethodDescriptorProto{
+		Name:            proto.String("Chat"),
+		InputType:       proto.String("ExampleMessage"),
+		OutputType:      proto.String("ExampleMessage"),
+		ClientStreaming: proto.Bool(true),
+		ServerStreaming: proto.Bool(true),
+	}
+	svc := &descriptorpb.ServiceDescriptorProto{
+		Name:   proto.String("ExampleService"),
+		Method: []*descriptorpb.MethodDescriptorProto{watchMeth, chatMeth},
+	}
+	msg := &descriptor.Message{
+		DescriptorProto: msgdesc,
+	}
+	file := descriptor.File{
+		FileDescriptorProto: &descriptorpb.FileDescriptorProto{
+			Name:        proto.String("example.proto"),
+			Package:     proto.String("example"),
+			MessageType: []*descriptorpb.DescriptorProto{msgdesc},
+			Service:     []*descriptorpb.ServiceDescriptorProto{svc},
+		},
+		GoPkg: descriptor.GoPackage{
+			Path: "example.com/path/to/example/example.pb",
+			Name: "example_pb",
+		},
+		Messages: []*descriptor.Message{msg},
+		Services: []*descriptor.Service{
+			{
+				ServiceDescriptorProto: svc,
+				Methods: []*descriptor.Method{
+					{
+						MethodDescriptorProto: watchMeth,
+						RequestType:           msg,
+						ResponseType:          msg,
+						Bindings: []*descriptor.Binding{
+							{
+								HTTPMethod: "GET",
+							},
+						},
+					},
+					{
+						MethodDescriptorProto: chatMeth,
+						RequestType:           msg,
+						ResponseType:          msg,
+						Bindings: []*descriptor.Binding{
+							{
+								HTTPMethod: "POST",
+								Body:       &descriptor.Body{FieldPath: nil},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, spec := range []struct {
+		serverStreamingSSE string
+		bidiWebSocket      string
+		want               []string
+	}{
+		{
+			want: []string{
+				"forward_ExampleService_Watch_0 = runtime.ForwardResponseStream\n",
+				"forward_ExampleService_Chat_0 = runtime.ForwardResponseStream\n",
+				`mux.Handle("POST", pattern_ExampleService_Chat_0, func(`,
+			},
+		},
+		{
+			serverStreamingSSE: "alongside",
+			bidiWebSocket:      "alongside",
+			want: []string{
+				"forward_ExampleService_Watch_0 = runtime.ForwardResponseStreamOrSSE\n",
+				"forward_ExampleService_Chat_0 = runtime.ForwardResponseStream\n",
+				`mux.HandleWithWebSocket("POST", pattern_ExampleService_Chat_0, func(`,
+			},
+		},
+		{
+			serverStreamingSSE: "replace",
+			bidiWebSocket:      "replace",
+			want: []string{
+				"forward_ExampleService_Watch_0 = runtime.ForwardResponseStreamSSE\n",
+				"forward_ExampleService_Chat_0 = runtime.ForwardResponseStream\n",
+				`mux.HandleWebSocket(pattern_ExampleService_Chat_0, func(`,
+				`mux.Handle("GET", pattern_ExampleService_Watch_0, func(`,
+			},
+		},
+	} {
+		got, err := applyTemplate(param{File: crossLinkFixture(&file), RegisterFuncSuffix: "Handler", ServerStreamingSSE: spec.serverStreamingSSE, BidiWebSocket: spec.bidiWebSocket}, descriptor.NewRegistry())
+		if err != nil {
+			t.Errorf("applyTemplate(%#v) failed with %v; want success", file, err)
+			return
+		}
+		formatted, err := format.Source([]byte(got))
+		if err != nil {
+			t.Errorf("format.Source(applyTemplate(%#v)) failed with %v; want success", file, err)
+			continue
+		}
+		for _, want := range spec.want {
+			if !strings.Contains(string(formatted), want) {
+				t.Errorf("applyTemplate(%#v) with ServerStreamingSSE %q and BidiWebSocket %q = %s; want to contain %s", file, spec.serverStreamingSSE, spec.bidiWebSocket, formatted, want)
+			}
+		}
+	}
+}
+
+func TestApplyTemplateValidateRequests(t *testing.T) {
+	want := []string{
+		"if err := runtime.ValidateRequest(ctx, &protoReq); err != nil {\n\t\treturn nil, metadata, err\n\t}\n\n\tmsg, err := client.Example(",
+		"if err := runtime.ValidateRequest(ctx, &protoReq); err != nil {\n\t\treturn nil, metadata, err\n\t}\n\n\tmsg, err := server.Example(",
+	}
+	for _, validateRequests := range []bool{false, true} {
+		file := crossLinkFixture(newExampleFileDescriptorWithGoPkg(&descriptor.GoPackage{
+			Path: "example.com/path/to/example",
+			Name: "example_pb",
+		}, "path/to/example"))
+		got, err := applyTemplate(param{File: file, RegisterFuncSuffix: "Handler", ValidateRequests: validateRequests}, descriptor.NewRegistry())
+		if err != nil {
+			t.Errorf("applyTemplate(%#v) failed with %v; want success", file, err)
+			return
+		}
+		formatted, err := format.Source([]byte(got))
+		if err != nil {
+			t.Errorf("format.Source(applyTemplate(%#v)) failed with %v; want success", file, err)
+			continue
+		}
+		for _, want := range want {
+			if strings.Contains(string(formatted), want) != validateRequests {
+				t.Errorf("applyTemplate(%#v) with ValidateRequests %v = %s; want to contain %s: %v", file, validateRequests, formatted, want, validateRequests)
+			}
+		}
+	}
+}
+
+func TestApplyTemplateFakes(t *testing.T) {
+	want := []string{
+		"type ExampleServiceFake struct {\n\tUnimplementedExampleServiceServer\n\n\tExampleCalls runtime.FakeMethod\n}",
+		"func (f *ExampleServiceFake) Example(ctx context.Context, in *ExampleMessage) (*ExampleMessage, error) {",
+		"func NewExampleServiceFakeGateway(fake *ExampleServiceFake, opts ...runtime.ServeMuxOption) (*httptest.Server, error) {",
+		"if err := RegisterExampleServiceHandlerServer(context.Background(), mux, fake); err != nil {",
+	}
+	for _, fakes := range []bool{false, true} {
+		file := crossLinkFixture(newExampleFileDescriptorWithGoPkg(&descriptor.GoPackage{
+			Path: "example.com/path/to/example",
+			Name: "example_pb",
+		}, "path/to/example"))
+		got, err := applyTemplate(param{File: file, RegisterFuncSuffix: "Handler", Fakes: fakes}, descriptor.NewRegistry())
+		if err != nil {
+			t.Errorf("applyTemplate(%#v) failed with %v; want success", file, err)
+			return
+		}
+		formatted, err := format.Source([]byte(got))
+		if err != nil {
+			t.Errorf("format.Source(applyTemplate(%#v)) failed with %v; want success", file, err)
+			continue
+		}
+		for _, want := range want {
+			if strings.Contains(string(formatted), want) != fakes {
+				t.Errorf("applyTemplate(%#v) with Fakes %v = %s; want to contain %s: %v", file, fakes, formatted, want, fakes)
+			}
+		}
+	}
+}
+
+func TestApplyTemplateDeprecationHeaders(t *testing.T) {
+	want := "\trctx, err := runtime.AnnotateContext(ctx, mux, req, \"/example.ExampleService/Example\")\n\t\tif err != nil {\n\t\t\truntime.HTTPError(ctx, mux, outboundMarshaler, w, req, err)\n\t\t\treturn\n\t\t}\n\t\truntime.MarkDeprecated(rctx, mux, w, req)\n"
+	for _, spec := range []struct {
+		deprecationHeaders bool
+		deprecated         bool
+	}{
+		{deprecationHeaders: false, deprecated: true},
+		{deprecationHeaders: true, deprecated: false},
+		{deprecationHeaders: true, deprecated: true},
+	} {
+		file := crossLinkFixture(newExampleFileDescriptorWithGoPkg(&descriptor.GoPackage{
+			Path: "example.com/path/to/example",
+			Name: "example_pb",
+		}, "path/to/example"))
+		file.Services[0].Methods[0].Options = &descriptorpb.MethodOptions{Deprecated: proto.Bool(spec.deprecated)}
+		got, err := applyTemplate(param{File: file, RegisterFuncSuffix: "Handler", DeprecationHeaders: spec.deprecationHeaders}, descriptor.NewRegistry())
+		if err != nil {
+			t.Errorf("applyTemplate(%#v) failed with %v; want success", file, err)
+			return
+		}
+		formatted, err := format.Source([]byte(got))
+		if err != nil {
+			t.Errorf("format.Source(applyTemplate(%#v)) failed with %v; want success", file, err)
+			continue
+		}
+		wantDeprecated := spec.deprecationHeaders && spec.deprecated
+		if got := strings.Count(string(formatted), "runtime.MarkDeprecated("); got != 0 && !wantDeprecated || got != 2 && wantDeprecated {
+			t.Errorf("applyTemplate(%#v) with %+v = %s; want runtime.MarkDeprecated in both handlers: %v", file, spec, formatted, wantDeprecated)
+		}
+		if wantDeprecated && !strings.Contains(string(formatted), want) {
+			t.Errorf("applyTemplate(%#v) with %+v = %s; want to contain %s", file, spec, formatted, want)
+		}
+	}
+}
+
+func TestApplyTemplateRejectBody(t *testing.T) {
+	want := "if err := runtime.RejectRequestBody(req); err != nil {\n\t\treturn nil, metadata, err\n\t}"
+	for _, rejectBody := range []bool{false, true} {
+		file := crossLinkFixture(newExampleFileDescriptorWithGoPkg(&descriptor.GoPackage{
+			Path: "example.com/path/to/example",
+			Name: "example_pb",
+		}, "path/to/example"))
+		b := file.Services[0].Methods[0].Bindings[0]
+		b.Body, b.RejectBody = nil, rejectBody
+		got, err := applyTemplate(param{File: file, RegisterFuncSuffix: "Handler"}, descriptor.NewRegistry())
+		if err != nil {
+			t.Errorf("applyTemplate(%#v) failed with %v; want success", file, err)
+			return
+		}
+		formatted, err := format.Source([]byte(got))
+		if err != nil {
+			t.Errorf("format.Source(applyTemplate(%#v)) failed with %v; want success", file, err)
+			continue
+		}
+		if got := strings.Count(string(formatted), want); got != 0 && !rejectBody || got != 2 && rejectBody {
+			t.Errorf("applyTemplate(%#v) with RejectBody %v = %s; want to contain %s twice: %v", file, rejectBody, formatted, want, rejectBody)
+		}
+	}
+}
+
+func TestApplyTemplateRequestDefaults(t *testing.T) {
+	want := []string{
+		"if err := runtime.PopulateRequestDefaults(ctx, &protoReq); err != nil {\n\t\treturn nil, metadata, err\n\t}\n\n\tif err := runtime.ValidateRequest(ctx, &protoReq); err != nil {\n\t\treturn nil, metadata, err\n\t}\n\n\tmsg, err := client.Example(",
+		"if err := runtime.PopulateRequestDefaults(ctx, &protoReq); err != nil {\n\t\treturn nil, metadata, err\n\t}\n\n\tif err := runtime.ValidateRequest(ctx, &protoReq); err != nil {\n\t\treturn nil, metadata, err\n\t}\n\n\tmsg, err := server.Example(",
+	}
+	for _, requestDefaults := range []bool{false, true} {
+		file := crossLinkFixture(newExampleFileDescriptorWithGoPkg(&descriptor.GoPackage{
+			Path: "example.com/path/to/example",
+			Name: "example_pb",
+		}, "path/to/example"))
+		got, err := applyTemplate(param{File: file, RegisterFuncSuffix: "Handler", RequestDefaults: requestDefaults, ValidateRequests: true}, descriptor.NewRegistry())
+		if err != nil {
+			t.Errorf("applyTemplate(%#v) failed with %v; want success", file, err)
+			return
+		}
+		formatted, err := format.Source([]byte(got))
+		if err != nil {
+			t.Errorf("format.Source(applyTemplate(%#v)) failed with %v; want success", file, err)
+			continue
+		}
+		for _, want := range want {
+			if strings.Contains(string(formatted), want) != requestDefaults {
+				t.Errorf("applyTemplate(%#v) with RequestDefaults %v = %s; want to contain %s: %v", file, requestDefaults, formatted, want, requestDefaults)
+			}
+		}
+	}
+}
+
 func TestIdentifierCapitalization(t *testing.T) {
 	msgdesc1 := &descriptorpb.DescriptorProto{
 		Name: proto.String("Exam_pleRequest"),
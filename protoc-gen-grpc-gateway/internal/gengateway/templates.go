@@ -0,0 +1,140 @@
+package gengateway
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// templateSet maps the sections of a generated file to the templates they
+// are generated with.
+//
+// The sections are, in the order they are generated:
+//
+//	header          executed with the file, declaring the package and imports
+//	handler         executed with every binding, declaring request_* funcs
+//	local-handler   executed with every binding, declaring local_request_* funcs
+//	local-trailer   executed with the services, declaring Register*Server
+//	trailer         executed with the services, declaring Register*Client and the patterns
+//	dynamic-trailer executed with the services, declaring Register*Dynamic
+//	http-client     executed with the services, declaring the typed HTTP clients
+//	fake            executed with the services, declaring the fake servers
+type templateSet map[string]*template.Template
+
+var defaultTemplates = templateSet{
+	"header":          headerTemplate,
+	"handler":         handlerTemplate,
+	"local-handler":   localHandlerTemplate,
+	"local-trailer":   localTrailerTemplate,
+	"trailer":         trailerTemplate,
+	"dynamic-trailer": dynamicTrailerTemplate,
+	"http-client":     httpClientTemplate,
+	"fake":            fakeTemplate,
+}
+
+// templateOverrideSuffix is the suffix of the files overriding sections.
+const templateOverrideSuffix = ".tmpl"
+
+// loadTemplateOverrides returns the default templates, with the sections
+// which have a "<section>.tmpl" file in dir replaced by it. Overrides of
+// sections for a single proto file are looked up in the "<file name>"
+// subdirectory of dir by forFile.
+//
+// An override is parsed along with the default template of its section, so
+// that it can execute the default as {{template "default" .}}, and redefine
+// any of its named templates, e.g. "client-rpc-request-func". An override
+// only made of such definitions keeps the default body of its section.
+func loadTemplateOverrides(dir string) (*templateOverrides, error) {
+	global, err := overrideTemplates(dir, true)
+	if err != nil {
+		return nil, err
+	}
+	return &templateOverrides{dir: dir, global: global}, nil
+}
+
+type templateOverrides struct {
+	dir    string
+	global templateSet
+}
+
+// forFile returns the templates to generate the file "name" of with.
+func (o *templateOverrides) forFile(name string) (templateSet, error) {
+	perFile, err := overrideTemplates(filepath.Join(o.dir, filepath.FromSlash(name)), false)
+	if err != nil {
+		return nil, err
+	}
+	if len(perFile) == 0 {
+		return o.global, nil
+	}
+	set := make(templateSet)
+	for section, t := range o.global {
+		set[section] = t
+	}
+	for section, t := range perFile {
+		set[section] = t
+	}
+	return set, nil
+}
+
+// overrideTemplates parses the override files in dir. If withDefaults is
+// true, the returned set also contains the default templates of the other
+// sections. Subdirectories are skipped, and a missing dir has no overrides.
+func overrideTemplates(dir string, withDefaults bool) (templateSet, error) {
+	set := make(templateSet)
+	if withDefaults {
+		for section, t := range defaultTemplates {
+			set[section] = t
+		}
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) && !withDefaults {
+		return set, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), templateOverrideSuffix) {
+			continue
+		}
+		section := strings.TrimSuffix(entry.Name(), templateOverrideSuffix)
+		def, ok := defaultTemplates[section]
+		if !ok {
+			return nil, fmt.Errorf("unknown template section %q in %s", section, dir)
+		}
+		path := filepath.Join(dir, entry.Name())
+		text, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		t, err := overrideTemplate(def, entry.Name(), string(text))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		set[section] = t
+	}
+	return set, nil
+}
+
+func overrideTemplate(def *template.Template, name, text string) (*template.Template, error) {
+	t, err := def.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := t.AddParseTree("default", def.Tree); err != nil {
+		return nil, err
+	}
+	override, err := t.New(name).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if override.Tree == nil || parse.IsEmptyTree(override.Tree.Root) {
+		return t.Lookup("default"), nil
+	}
+	return override, nil
+}
@@ -31,9 +31,28 @@ var (
 	allowPatchFeature          = flag.Bool("allow_patch_feature", true, "determines whether to use PATCH feature involving update masks (using google.protobuf.FieldMask).")
 	omitPackageDoc             = flag.Bool("omit_package_doc", false, "if true, no package comment will be included in the generated code")
 	standalone                 = flag.Bool("standalone", false, "generates a standalone gateway package, which imports the target service package")
+	standalonePackageMap       = flag.String("standalone_package_map", "", "path to a file mapping proto files, or the Go import paths of their types, to the Go packages their standalone gateway code is generated in, one `<proto file or Go import path>=<import path>[;<package name>]` mapping per line. Requires standalone")
 	versionFlag                = flag.Bool("version", false, "print the current version")
 	warnOnUnboundMethods       = flag.Bool("warn_on_unbound_methods", false, "emit a warning message if an RPC method has no HttpRule annotation")
 	generateUnboundMethods     = flag.Bool("generate_unbound_methods", false, "generate proxy methods even for RPC methods that have no HttpRule annotation")
+	poolRequestMessages        = flag.Bool("pool_request_messages", false, "reuse request messages of unary and server streaming methods across calls. Server implementations registered with Register*Server must not retain request messages after returning")
+	registerDynamic            = flag.Bool("register_dynamic", false, "also generate Register*Dynamic functions, which register the handlers of a service to a runtime.ServeMuxDynamic and return a function deregistering them")
+	routeManifest              = flag.Bool("generate_route_manifest", false, "also generate a <file>.routes.json manifest per file, listing the method, HTTP verb, path template, body mappings and streaming kind of every route")
+	exportPatterns             = flag.Bool("export_patterns", false, "also generate exported Pattern_<Service>_<Method>_<N> variables and Method_<Service>_<Method>_<N> constants for the routes, e.g. to deregister them from a runtime.ServeMuxDynamic")
+	httpClient                 = flag.Bool("http_client", false, "also generate a typed <Service>HTTPClient per service, calling the first HTTP rule of each unary method through a runtime.HTTPClient")
+	serverStreamingSSE         = flag.String("server_streaming_sse", "", "serve server streaming methods as Server-Sent Events. `alongside` serves them to clients accepting text/event-stream and chunked JSON to the others, `replace` always serves them")
+	bidiWebSocket              = flag.String("bidi_websocket", "", "serve bidirectional streaming methods over WebSocket. `alongside` registers a WebSocket handler in addition to the chunked JSON one, `replace` registers only the WebSocket handler. Register*Dynamic functions keep registering the chunked JSON handler")
+	includeServices            = flag.String("include_services", "", "semicolon-separated patterns of the fully-qualified names of the services to generate, e.g. `example.v1.*`. Patterns are globs, in which * does not match dots, or regular expressions enclosed in slashes. All services are generated if empty")
+	excludeMethods             = flag.String("exclude_methods", "", "semicolon-separated patterns of the fully-qualified names of the methods not to generate, e.g. `example.v1.AdminService.*`, with the syntax of include_services")
+	validateRequests           = flag.Bool("validate_requests", false, "validate request messages with runtime.ValidateRequest once populated from the HTTP request, replying codes.InvalidArgument errors for invalid ones. Messages generated by protoc-gen-validate are validated by default, see runtime.WithRequestValidator for other validators")
+	requestDefaults            = flag.Bool("request_defaults", false, "populate the request fields left unset by the HTTP request with runtime.PopulateRequestDefaults, i.e. with the defaults configured by runtime.WithRequestDefaults and runtime.WithRouteRequestDefaults, before they are validated")
+	allowBodyMethods           = flag.String("allow_body_methods", "", "semicolon-separated patterns of the fully-qualified names of the methods whose GET and DELETE HTTP rules may set a request body, with the syntax of include_services")
+	rejectBodyMethods          = flag.String("reject_body_methods", "", "semicolon-separated patterns of the fully-qualified names of the methods whose HTTP rules without a request body reject HTTP requests carrying one, with the syntax of include_services")
+	allowedCustomVerbs         = flag.String("allowed_custom_verbs", "", "semicolon-separated HTTP methods allowed in custom HTTP rules, e.g. `HEAD;OPTIONS`. All HTTP methods are allowed if empty")
+	omitDeprecatedMethods      = flag.Bool("omit_deprecated_methods", false, "do not generate the routes of the methods marked deprecated, or of services marked deprecated, with the `deprecated` option")
+	deprecationHeaders         = flag.Bool("deprecation_headers", false, "reply Deprecation headers, and the Sunset and Link headers configured with runtime.WithDeprecation, from the routes of the methods marked deprecated, and report the requests to them with the hook set by runtime.WithDeprecatedMethodHook")
+	generateFakes              = flag.Bool("generate_fakes", false, "also generate a fake <Service>Fake server per service, recording the requests of its unary methods and replying with canned responses, and a New<Service>FakeGateway function serving it through the gateway with an httptest.Server")
+	templateDir                = flag.String("template_dir", "", "directory of templates overriding sections of the generated files, named <section>.tmpl after the sections header, handler, local-handler, local-trailer, trailer, dynamic-trailer, http-client and fake. Overrides in the <proto file name> subdirectory only apply to that file. An override can execute the default template of its section as {{template \"default\" .}}")
 )
 
 // Variables set by goreleaser at build time
@@ -64,7 +83,7 @@ func main() {
 
 		codegenerator.SetSupportedFeaturesOnPluginGen(gen)
 
-		generator := gengateway.New(reg, *useRequestContext, *registerFuncSuffix, *allowPatchFeature, *standalone)
+		generator := gengateway.New(reg, *useRequestContext, *registerFuncSuffix, *allowPatchFeature, *standalone, *poolRequestMessages, *registerDynamic, *routeManifest, *exportPatterns, *httpClient, *serverStreamingSSE, *bidiWebSocket, *templateDir, *validateRequests, *requestDefaults, *generateFakes, *deprecationHeaders, *standalonePackageMap)
 
 		glog.V(1).Infof("Parsing code generator request")
 
@@ -136,11 +155,57 @@ func applyFlags(reg *descriptor.Registry) error {
 	if *warnOnUnboundMethods && *generateUnboundMethods {
 		glog.Warningf("Option warn_on_unbound_methods has no effect when generate_unbound_methods is used.")
 	}
+	if *omitDeprecatedMethods && *deprecationHeaders {
+		glog.Warningf("Option deprecation_headers has no effect when omit_deprecated_methods is used.")
+	}
 	reg.SetStandalone(*standalone)
+	if *standalonePackageMap != "" && !*standalone {
+		return fmt.Errorf("option standalone_package_map requires standalone")
+	}
 	reg.SetAllowDeleteBody(*allowDeleteBody)
 	reg.SetAllowRepeatedFieldsInBody(*allowRepeatedFieldsInBody)
 	reg.SetOmitPackageDoc(*omitPackageDoc)
 	reg.SetWarnOnUnboundMethods(*warnOnUnboundMethods)
 	reg.SetGenerateUnboundMethods(*generateUnboundMethods)
+	if err := reg.SetIncludeServices(splitPatterns(*includeServices)); err != nil {
+		return err
+	}
+	if err := reg.SetExcludeMethods(splitPatterns(*excludeMethods)); err != nil {
+		return err
+	}
+	if err := reg.SetBodyAllowedMethods(splitPatterns(*allowBodyMethods)); err != nil {
+		return err
+	}
+	if err := reg.SetBodyRejectedMethods(splitPatterns(*rejectBodyMethods)); err != nil {
+		return err
+	}
+	reg.SetAllowedCustomVerbs(splitPatterns(*allowedCustomVerbs))
+	reg.SetOmitDeprecatedMethods(*omitDeprecatedMethods)
+	if err := validateStreamTransport("server_streaming_sse", *serverStreamingSSE); err != nil {
+		return err
+	}
+	if err := validateStreamTransport("bidi_websocket", *bidiWebSocket); err != nil {
+		return err
+	}
 	return reg.SetRepeatedPathParamSeparator(*repeatedPathParamSeparator)
 }
+
+func validateStreamTransport(name, mode string) error {
+	switch mode {
+	case "", "alongside", "replace":
+		return nil
+	}
+	return fmt.Errorf("unknown value %q of %s: must be either `alongside` or `replace`", mode, name)
+}
+
+// splitPatterns splits the semicolon-separated "patterns", as protoc
+// parameters cannot contain commas.
+func splitPatterns(patterns string) []string {
+	var split []string
+	for _, p := range strings.Split(patterns, ";") {
+		if p = strings.TrimSpace(p); p != "" {
+			split = append(split, p)
+		}
+	}
+	return split
+}
@@ -0,0 +1,77 @@
+package genopenapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/descriptor"
+)
+
+// callbackExtensions are the extensions documenting the requests the server
+// initiates: "x-callbacks" of operations, an object of callback objects by
+// name, and "x-webhooks" of documents, an object of path items by name. They
+// are written like OpenAPI 2.0 path items, and converted into the callbacks of
+// operations and the webhooks of OpenAPI 3.1 documents.
+//
+// The schemas in them may reference proto messages and enums by their fully
+// qualified names, e.g. {"$ref": ".example.v1.BookCreated"}, which are
+// rendered as definitions like the types of methods.
+var callbackExtensions = map[string]bool{
+	"x-callbacks": true,
+	"x-webhooks":  true,
+}
+
+// resolveCallbackRefs rewrites the references to proto types in the callback
+// extensions of "exts" into references to their definitions, and adds the
+// types to "refs".
+func resolveCallbackRefs(exts []extension, reg *descriptor.Registry, refs refMap) error {
+	for i, ext := range exts {
+		if !callbackExtensions[ext.key] {
+			continue
+		}
+		v, err := decodeOrdered(ext.value)
+		if err != nil {
+			return err
+		}
+		if err := resolveProtoRefs(v, reg, refs); err != nil {
+			return fmt.Errorf("%s: %v", ext.key, err)
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		exts[i].value = value
+	}
+	return nil
+}
+
+func resolveProtoRefs(v interface{}, reg *descriptor.Registry, refs refMap) error {
+	switch v := v.(type) {
+	case *orderedObject:
+		for i, kv := range *v {
+			if ref, ok := kv.Value.(string); ok && kv.Key == "$ref" {
+				if !strings.HasPrefix(ref, ".") {
+					continue
+				}
+				name, ok := fullyQualifiedNameToOpenAPIName(ref, reg)
+				if !ok {
+					return fmt.Errorf("unknown proto type %q", ref)
+				}
+				(*v)[i].Value = "#/definitions/" + name
+				refs[ref] = struct{}{}
+				continue
+			}
+			if err := resolveProtoRefs(kv.Value, reg, refs); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := resolveProtoRefs(item, reg, refs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
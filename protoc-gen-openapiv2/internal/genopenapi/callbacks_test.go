@@ -0,0 +1,172 @@
+package genopenapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/descriptor"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/httprule"
+	openapi_options "github.com/grpc-ecosystem/grpc-gateway/v2/protoc-gen-openapiv2/options"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestApplyTemplateCallbacks(t *testing.T) {
+	callbacks, err := structpb.NewValue(map[string]interface{}{
+		"bookCreated": map[string]interface{}{
+			"{$request.body#/callback_url}": map[string]interface{}{
+				"post": map[string]interface{}{
+					"parameters": []interface{}{
+						map[string]interface{}{
+							"name":     "body",
+							"in":       "body",
+							"required": true,
+							"schema":   map[string]interface{}{"$ref": ".example.BookCreated"},
+						},
+					},
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{"description": "The event was received."},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	methOpts := &descriptorpb.MethodOptions{}
+	proto.SetExtension(methOpts, openapi_options.E_Openapiv2Operation, &openapi_options.Operation{
+		Extensions: map[string]*structpb.Value{"x-callbacks": callbacks},
+	})
+
+	msgdesc := &descriptorpb.DescriptorProto{Name: proto.String("ExampleMessage")}
+	eventdesc := &descriptorpb.DescriptorProto{
+		Name: proto.String("BookCreated"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			{
+				Name:   proto.String("name"),
+				Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Number: proto.Int32(1),
+			},
+		},
+	}
+	meth := &descriptorpb.MethodDescriptorProto{
+		Name:       proto.String("Echo"),
+		InputType:  proto.String("ExampleMessage"),
+		OutputType: proto.String("ExampleMessage"),
+		Options:    methOpts,
+	}
+	svc := &descriptorpb.ServiceDescriptorProto{
+		Name:   proto.String("ExampleService"),
+		Method: []*descriptorpb.MethodDescriptorProto{meth},
+	}
+	msg := &descriptor.Message{DescriptorProto: msgdesc}
+	event := &descriptor.Message{DescriptorProto: eventdesc}
+	file := descriptor.File{
+		FileDescriptorProto: &descriptorpb.FileDescriptorProto{
+			SourceCodeInfo: &descriptorpb.SourceCodeInfo{},
+			Name:           proto.String("example.proto"),
+			Package:        proto.String("example"),
+			MessageType:    []*descriptorpb.DescriptorProto{msgdesc, eventdesc},
+			Service:        []*descriptorpb.ServiceDescriptorProto{svc},
+			Options: &descriptorpb.FileOptions{
+				GoPackage: proto.String(".;example"),
+			},
+		},
+		GoPkg: descriptor.GoPackage{
+			Path: "example.com/path/to/example/example.pb",
+			Name: "example_pb",
+		},
+		Messages: []*descriptor.Message{msg, event},
+		Services: []*descriptor.Service{
+			{
+				ServiceDescriptorProto: svc,
+				Methods: []*descriptor.Method{
+					{
+						MethodDescriptorProto: meth,
+						RequestType:           msg,
+						ResponseType:          msg,
+						Bindings: []*descriptor.Binding{
+							{
+								HTTPMethod: "POST",
+								PathTmpl: httprule.Template{
+									Version:  1,
+									OpCodes:  []int{0, 0},
+									Template: "/v1/books",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	reg := descriptor.NewRegistry()
+	if err := AddErrorDefs(reg); err != nil {
+		t.Fatalf("AddErrorDefs(%#v) failed with %v; want success", reg, err)
+	}
+	if err := reg.Load(&pluginpb.CodeGeneratorRequest{
+		ProtoFile: []*descriptorpb.FileDescriptorProto{file.FileDescriptorProto},
+	}); err != nil {
+		t.Fatalf("failed to load code generator request: %v", err)
+	}
+	result, err := applyTemplate(param{File: crossLinkFixture(&file), reg: reg})
+	if err != nil {
+		t.Fatalf("applyTemplate(%#v) failed with %v; want success", file, err)
+	}
+	if _, ok := result.Definitions["exampleBookCreated"]; !ok {
+		t.Errorf("applyTemplate(%#v).Definitions = %v; want exampleBookCreated", file, result.Definitions)
+	}
+	exts := result.Paths["/v1/books"].Post.extensions
+	if len(exts) != 1 || exts[0].key != "x-callbacks" {
+		t.Fatalf("applyTemplate(%#v).Paths[%q].Post.extensions = %v; want x-callbacks", file, "/v1/books", exts)
+	}
+	if !strings.Contains(string(exts[0].value), `"$ref":"#/definitions/exampleBookCreated"`) {
+		t.Errorf("x-callbacks = %s; want a reference to exampleBookCreated", exts[0].value)
+	}
+
+	converted, err := convertToOpenAPI31(result)
+	if err != nil {
+		t.Fatalf("convertToOpenAPI31(...) failed with %v; want success", err)
+	}
+	b, err := json.Marshal(converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Callbacks map[string]map[string]map[string]struct {
+				RequestBody struct {
+					Content map[string]struct {
+						Schema struct {
+							Ref string `json:"$ref"`
+						} `json:"schema"`
+					} `json:"content"`
+				} `json:"requestBody"`
+			} `json:"callbacks"`
+			XCallbacks interface{} `json:"x-callbacks"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	op := doc.Paths["/v1/books"]["post"]
+	if op.XCallbacks != nil {
+		t.Errorf("converted operation has x-callbacks; want callbacks")
+	}
+	content := op.Callbacks["bookCreated"]["{$request.body#/callback_url}"]["post"].RequestBody.Content
+	if got, want := content["application/json"].Schema.Ref, "#/components/schemas/exampleBookCreated"; got != want {
+		t.Errorf("callback request body schema = %q; want %q (%s)", got, want, b)
+	}
+}
+
+func TestResolveCallbackRefsUnknownType(t *testing.T) {
+	exts := []extension{{key: "x-webhooks", value: json.RawMessage(`{"created":{"post":{"parameters":[{"in":"body","schema":{"$ref":".example.Missing"}}]}}}`)}}
+	if err := resolveCallbackRefs(exts, descriptor.NewRegistry(), refMap{}); err == nil {
+		t.Errorf("resolveCallbackRefs(%s) succeeded; want failure", exts[0].value)
+	}
+}
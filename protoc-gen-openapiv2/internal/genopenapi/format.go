@@ -0,0 +1,235 @@
+package genopenapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// orderedObject is a JSON object which keeps the order of its members, so
+// that documents can be rewritten, e.g. into OpenAPI 3.1 or YAML, without
+// reordering the properties of schemas. Its values are *orderedObject,
+// []interface{}, string, json.Number, bool or nil.
+type orderedObject []keyVal
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	return openapiSchemaObjectProperties(o).MarshalJSON()
+}
+
+// get returns the value of the member key.
+func (o *orderedObject) get(key string) (interface{}, bool) {
+	for _, kv := range *o {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// getObject returns the value of the member key if it is an object.
+func (o *orderedObject) getObject(key string) (*orderedObject, bool) {
+	v, _ := o.get(key)
+	obj, ok := v.(*orderedObject)
+	return obj, ok
+}
+
+// getString returns the value of the member key if it is a string.
+func (o *orderedObject) getString(key string) (string, bool) {
+	v, _ := o.get(key)
+	s, ok := v.(string)
+	return s, ok
+}
+
+// set sets the value of the member key, appending it if there is none.
+func (o *orderedObject) set(key string, value interface{}) {
+	for i, kv := range *o {
+		if kv.Key == key {
+			(*o)[i].Value = value
+			return
+		}
+	}
+	*o = append(*o, keyVal{Key: key, Value: value})
+}
+
+// del deletes the member key and returns its value.
+func (o *orderedObject) del(key string) (interface{}, bool) {
+	for i, kv := range *o {
+		if kv.Key == key {
+			*o = append((*o)[:i], (*o)[i+1:]...)
+			return kv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// decodeOrdered decodes JSON into values with ordered objects.
+func decodeOrdered(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return decodeOrderedValue(dec)
+}
+
+func decodeOrderedValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+	switch delim {
+	case '{':
+		obj := &orderedObject{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrderedValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			*obj = append(*obj, keyVal{Key: key.(string), Value: value})
+		}
+		_, err = dec.Token()
+		return obj, err
+	case '[':
+		arr := []interface{}{}
+		for dec.More() {
+			value, err := decodeOrderedValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		_, err = dec.Token()
+		return arr, err
+	}
+	return nil, fmt.Errorf("unexpected JSON delimiter %v", delim)
+}
+
+// encodeJSON returns the indented JSON encoding of v.
+func encodeJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// plainYAMLScalar matches strings which can be written as plain YAML scalars.
+var plainYAMLScalar = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+
+// yamlKeywords are plain scalars which YAML resolves to other types than strings.
+var yamlKeywords = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"y": true, "n": true, "null": true, "~": true,
+}
+
+// encodeYAML returns the YAML encoding of v, a value decoded by decodeOrdered,
+// keeping the order of its objects.
+func encodeYAML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeYAML(&buf, v, 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeYAML(buf *bytes.Buffer, v interface{}, indent int) error {
+	pad := strings.Repeat("  ", indent)
+	switch v := v.(type) {
+	case *orderedObject:
+		if len(*v) == 0 {
+			buf.WriteString("{}\n")
+			return nil
+		}
+		for _, kv := range *v {
+			buf.WriteString(pad)
+			buf.WriteString(yamlScalar(kv.Key))
+			buf.WriteString(":")
+			if err := writeYAMLMember(buf, kv.Value, indent+1); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteString("[]\n")
+			return nil
+		}
+		for _, item := range v {
+			if obj, ok := item.(*orderedObject); ok && len(*obj) > 0 {
+				// Objects start on the line of their dash, as in "- name: value".
+				var member bytes.Buffer
+				if err := writeYAML(&member, obj, indent+1); err != nil {
+					return err
+				}
+				buf.WriteString(pad)
+				buf.WriteString("- ")
+				buf.Write(member.Bytes()[len(pad)+2:])
+				continue
+			}
+			buf.WriteString(pad)
+			buf.WriteString("-")
+			if err := writeYAMLMember(buf, item, indent+1); err != nil {
+				return err
+			}
+		}
+	default:
+		s, err := yamlValue(v)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(pad)
+		buf.WriteString(s)
+		buf.WriteString("\n")
+	}
+	return nil
+}
+
+// writeYAMLMember writes v after the key or dash it is the value of.
+func writeYAMLMember(buf *bytes.Buffer, v interface{}, indent int) error {
+	switch c := v.(type) {
+	case *orderedObject:
+		if len(*c) > 0 {
+			buf.WriteString("\n")
+			return writeYAML(buf, v, indent)
+		}
+	case []interface{}:
+		if len(c) > 0 {
+			buf.WriteString("\n")
+			return writeYAML(buf, v, indent)
+		}
+	}
+	buf.WriteString(" ")
+	return writeYAML(buf, v, 0)
+}
+
+func yamlValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return yamlScalar(v), nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	case nil:
+		return "null", nil
+	}
+	return "", fmt.Errorf("unexpected value %#v", v)
+}
+
+// yamlScalar returns s as a plain scalar if it is one, and as a double quoted
+// scalar, whose escapes are those of JSON strings, otherwise.
+func yamlScalar(s string) string {
+	if plainYAMLScalar.MatchString(s) && !yamlKeywords[strings.ToLower(s)] {
+		return s
+	}
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -1,7 +1,6 @@
 package genopenapi
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -138,21 +137,49 @@ func extensionMarshalJSON(so interface{}, extensions []extension) ([]byte, error
 }
 
 // encodeOpenAPI converts OpenAPI file obj to pluginpb.CodeGeneratorResponse_File
-func encodeOpenAPI(file *wrapper) (*descriptor.ResponseFile, error) {
-	var formatted bytes.Buffer
-	enc := json.NewEncoder(&formatted)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(*file.swagger); err != nil {
-		return nil, err
+// in the OpenAPI version and output format configured in reg. The schemas of
+// the document are moved to the definitions file of "splitter" unless it is
+// nil.
+func encodeOpenAPI(file *wrapper, reg *descriptor.Registry, splitter *definitionsSplitter) (*descriptor.ResponseFile, error) {
+	var doc interface{} = *file.swagger
+	suffix := "swagger"
+	if reg.GetOpenAPIVersion() == "3.1" {
+		converted, err := convertToOpenAPI31(file.swagger)
+		if err != nil {
+			return nil, err
+		}
+		doc, suffix = converted, "openapi"
 	}
 	name := file.fileName
 	ext := filepath.Ext(name)
 	base := strings.TrimSuffix(name, ext)
-	output := fmt.Sprintf("%s.swagger.json", base)
+	output := fmt.Sprintf("%s.%s.%s", base, suffix, reg.GetOpenAPIOutputFormat())
+
+	formatted, err := encodeJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	if splitter != nil || reg.GetOpenAPIOutputFormat() == "yaml" {
+		ordered, err := decodeOrdered(formatted)
+		if err != nil {
+			return nil, err
+		}
+		if splitter != nil {
+			splitter.split(output, ordered.(*orderedObject))
+		}
+		if reg.GetOpenAPIOutputFormat() == "yaml" {
+			formatted, err = encodeYAML(ordered)
+		} else {
+			formatted, err = encodeJSON(ordered)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
 	return &descriptor.ResponseFile{
 		CodeGeneratorResponse_File: &pluginpb.CodeGeneratorResponse_File{
 			Name:    proto.String(output),
-			Content: proto.String(formatted.String()),
+			Content: proto.String(string(formatted)),
 		},
 	}, nil
 }
@@ -200,9 +227,10 @@ func (g *generator) Generate(targets []*descriptor.File) ([]*descriptor.Response
 		})
 	}
 
+	splitter := newDefinitionsSplitter(g.reg)
 	if g.reg.IsAllowMerge() {
 		targetOpenAPI := mergeTargetFile(openapis, g.reg.GetMergeFileName())
-		f, err := encodeOpenAPI(targetOpenAPI)
+		f, err := encodeOpenAPI(targetOpenAPI, g.reg, splitter)
 		if err != nil {
 			return nil, fmt.Errorf("failed to encode OpenAPI for %s: %s", g.reg.GetMergeFileName(), err)
 		}
@@ -210,7 +238,7 @@ func (g *generator) Generate(targets []*descriptor.File) ([]*descriptor.Response
 		glog.V(1).Infof("New OpenAPI file will emit")
 	} else {
 		for _, file := range openapis {
-			f, err := encodeOpenAPI(file)
+			f, err := encodeOpenAPI(file, g.reg, splitter)
 			if err != nil {
 				return nil, fmt.Errorf("failed to encode OpenAPI for %s: %s", file.fileName, err)
 			}
@@ -218,6 +246,13 @@ func (g *generator) Generate(targets []*descriptor.File) ([]*descriptor.Response
 			glog.V(1).Infof("New OpenAPI file will emit")
 		}
 	}
+	if splitter != nil && len(openapis) > 0 {
+		f, err := splitter.encode(g.reg.GetOpenAPIOutputFormat())
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode OpenAPI definitions for %s: %s", g.reg.GetDefinitionsFile(), err)
+		}
+		files = append(files, f)
+	}
 	return files, nil
 }
 
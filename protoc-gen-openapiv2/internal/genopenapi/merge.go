@@ -0,0 +1,252 @@
+package genopenapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// mergeConflicts lists the conflicts found merging OpenAPI documents.
+type mergeConflicts []string
+
+func (c mergeConflicts) Error() string {
+	return fmt.Sprintf("%d conflict(s) merging OpenAPI documents:\n\t%s", len(c), strings.Join(c, "\n\t"))
+}
+
+// operationMethods are the members of path items which are operations.
+var operationMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// MergeDocuments merges the OpenAPI documents "docs", generated from different
+// proto packages, into one document in "format", either "json" or "yaml".
+// The documents are in JSON, or in YAML if their names in "names" have a
+// ".yaml" or ".yml" extension.
+//
+// The paths, definitions, components, security definitions and tags of the
+// documents are merged, and identical definitions are only kept once. Other
+// members, e.g. info, are taken from the first document which has them. The
+// operations of the services are tagged with the names of their services,
+// which are added to the tags of the merged document. Members defined
+// differently by several documents, operation IDs used by several operations
+// and documents of different OpenAPI versions are conflicts, which are all
+// reported in the returned error.
+func MergeDocuments(names []string, docs [][]byte, format string) ([]byte, error) {
+	if format != "json" && format != "yaml" {
+		return nil, fmt.Errorf("unknown output format %q, want %q or %q", format, "json", "yaml")
+	}
+	m := &documentMerger{merged: &orderedObject{}, sources: make(map[string]string)}
+	for i, name := range names {
+		data := docs[i]
+		if ext := filepath.Ext(name); ext == ".yaml" || ext == ".yml" {
+			var err error
+			if data, err = yaml.YAMLToJSON(data); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %v", name, err)
+			}
+		}
+		decoded, err := decodeOrdered(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", name, err)
+		}
+		doc, ok := decoded.(*orderedObject)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an OpenAPI document", name)
+		}
+		m.add(name, doc)
+	}
+	m.checkOperations()
+	if len(m.conflicts) > 0 {
+		return nil, m.conflicts
+	}
+	if format == "yaml" {
+		return encodeYAML(m.merged)
+	}
+	return encodeJSON(m.merged)
+}
+
+type documentMerger struct {
+	merged *orderedObject
+	// sources maps the locations of the members of the merged document to
+	// the names of the documents they are taken from.
+	sources   map[string]string
+	conflicts mergeConflicts
+}
+
+func (m *documentMerger) add(name string, doc *orderedObject) {
+	for _, kv := range *doc {
+		switch kv.Key {
+		case "swagger", "openapi":
+			other := "openapi"
+			if kv.Key == other {
+				other = "swagger"
+			}
+			if _, ok := m.merged.get(other); ok {
+				m.conflicts = append(m.conflicts, fmt.Sprintf("%s of %s differs from the %s of %s", kv.Key, name, other, m.sources[other]))
+				continue
+			}
+			m.merge(m.merged, kv.Key, kv.Value, kv.Key, name, 0)
+		case "paths", "components":
+			m.merge(m.merged, kv.Key, kv.Value, kv.Key, name, 2)
+		case "definitions", "securityDefinitions", "parameters", "responses":
+			m.merge(m.merged, kv.Key, kv.Value, kv.Key, name, 1)
+		case "tags":
+			m.mergeTags(kv.Value, name)
+		case "security":
+			existing, _ := m.merged.get(kv.Key)
+			m.merged.set(kv.Key, appendMissing(existing, kv.Value))
+		default:
+			if _, ok := m.merged.get(kv.Key); !ok {
+				m.merged.set(kv.Key, kv.Value)
+			}
+		}
+	}
+}
+
+// merge merges "value" into the member "key" of "dst", at "location" of the
+// merged document, recursing "depth" levels into the members of objects.
+func (m *documentMerger) merge(dst *orderedObject, key string, value interface{}, location, name string, depth int) {
+	existing, ok := dst.get(key)
+	if !ok {
+		dst.set(key, value)
+		m.record(location, value, name, depth)
+		return
+	}
+	if depth > 0 {
+		dstObj, dstOK := existing.(*orderedObject)
+		srcObj, srcOK := value.(*orderedObject)
+		if dstOK && srcOK {
+			for _, kv := range *srcObj {
+				m.merge(dstObj, kv.Key, kv.Value, location+"."+kv.Key, name, depth-1)
+			}
+			return
+		}
+	}
+	if !sameJSON(existing, value) {
+		m.conflicts = append(m.conflicts, fmt.Sprintf("%s of %s differs from %s", location, name, m.sources[location]))
+	}
+}
+
+// record records "name" as the source of "value" at "location", and of its
+// members down to "depth" levels.
+func (m *documentMerger) record(location string, value interface{}, name string, depth int) {
+	m.sources[location] = name
+	if obj, ok := value.(*orderedObject); ok && depth > 0 {
+		for _, kv := range *obj {
+			m.record(location+"."+kv.Key, kv.Value, name, depth-1)
+		}
+	}
+}
+
+func (m *documentMerger) mergeTags(value interface{}, name string) {
+	existing, _ := m.merged.get("tags")
+	tags, _ := existing.([]interface{})
+	more, _ := value.([]interface{})
+	for _, tag := range more {
+		obj, ok := tag.(*orderedObject)
+		if !ok {
+			continue
+		}
+		tagName, _ := obj.getString("name")
+		location := "tags." + tagName
+		if idx := findTag(tags, tagName); idx >= 0 {
+			if !sameJSON(tags[idx], tag) {
+				m.conflicts = append(m.conflicts, fmt.Sprintf("%s of %s differs from %s", location, name, m.sources[location]))
+			}
+			continue
+		}
+		m.sources[location] = name
+		tags = append(tags, tag)
+	}
+	if len(tags) > 0 {
+		m.merged.set("tags", tags)
+	}
+}
+
+// checkOperations reports the operation IDs used by several operations of
+// the merged document, and adds the tags of its operations missing from its
+// tags.
+func (m *documentMerger) checkOperations() {
+	paths, ok := m.merged.getObject("paths")
+	if !ok {
+		return
+	}
+	existing, _ := m.merged.get("tags")
+	tags, _ := existing.([]interface{})
+	operations := make(map[string]string)
+	for _, item := range *paths {
+		itemObj, ok := item.Value.(*orderedObject)
+		if !ok {
+			continue
+		}
+		for _, method := range operationMethods {
+			op, ok := itemObj.getObject(method)
+			if !ok {
+				continue
+			}
+			location := "paths." + item.Key + "." + method
+			if id, ok := op.getString("operationId"); ok {
+				if other, ok := operations[id]; ok {
+					m.conflicts = append(m.conflicts, fmt.Sprintf("operationId %q of %s (%s) is also used by %s (%s)", id, location, m.sources[location], other, m.sources[other]))
+				} else {
+					operations[id] = location
+				}
+			}
+			opTags, _ := op.get("tags")
+			list, _ := opTags.([]interface{})
+			for _, tag := range list {
+				if tagName, ok := tag.(string); ok && findTag(tags, tagName) < 0 {
+					tags = append(tags, &orderedObject{{Key: "name", Value: tagName}})
+				}
+			}
+		}
+	}
+	if len(tags) > 0 {
+		m.merged.set("tags", tags)
+	}
+}
+
+func findTag(tags []interface{}, name string) int {
+	for i, tag := range tags {
+		if obj, ok := tag.(*orderedObject); ok {
+			if n, _ := obj.getString("name"); n == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// appendMissing appends the elements of the array "value" which are not in
+// the array "existing".
+func appendMissing(existing, value interface{}) []interface{} {
+	list, _ := existing.([]interface{})
+	more, _ := value.([]interface{})
+outer:
+	for _, v := range more {
+		for _, e := range list {
+			if sameJSON(e, v) {
+				continue outer
+			}
+		}
+		list = append(list, v)
+	}
+	return list
+}
+
+// sameJSON reports whether "a" and "b" encode the same JSON values,
+// regardless of the order of the members of their objects.
+func sameJSON(a, b interface{}) bool {
+	var decoded [2]interface{}
+	for i, v := range []interface{}{a, b} {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return false
+		}
+		if err := json.Unmarshal(buf, &decoded[i]); err != nil {
+			return false
+		}
+	}
+	return reflect.DeepEqual(decoded[0], decoded[1])
+}
@@ -0,0 +1,115 @@
+package genopenapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	mergeEchoDoc = `{
+  "swagger": "2.0",
+  "info": {"title": "echo.proto", "version": "1.0"},
+  "tags": [{"name": "EchoService"}],
+  "paths": {
+    "/v1/echo": {"post": {"operationId": "EchoService_Echo", "tags": ["EchoService"]}}
+  },
+  "definitions": {
+    "rpcStatus": {"type": "object", "properties": {"code": {"type": "integer"}}},
+    "v1EchoMessage": {"type": "object"}
+  }
+}`
+	mergeBookDoc = `{
+  "swagger": "2.0",
+  "info": {"title": "book.proto", "version": "2.0"},
+  "paths": {
+    "/v1/books": {"get": {"operationId": "BookService_ListBooks", "tags": ["BookService"]}},
+    "/v1/echo": {"get": {"operationId": "BookService_GetEcho", "tags": ["BookService"]}}
+  },
+  "definitions": {
+    "rpcStatus": {"properties": {"code": {"type": "integer"}}, "type": "object"},
+    "v1Book": {"type": "object"}
+  }
+}`
+)
+
+func TestMergeDocuments(t *testing.T) {
+	merged, err := MergeDocuments([]string{"echo.swagger.json", "book.swagger.json"}, [][]byte{[]byte(mergeEchoDoc), []byte(mergeBookDoc)}, "json")
+	if err != nil {
+		t.Fatalf("MergeDocuments(...) failed with %v; want success", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(merged, &got); err != nil {
+		t.Fatalf("json.Unmarshal(%s) failed with %v; want success", merged, err)
+	}
+	var want map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+  "swagger": "2.0",
+  "info": {"title": "echo.proto", "version": "1.0"},
+  "tags": [{"name": "EchoService"}, {"name": "BookService"}],
+  "paths": {
+    "/v1/echo": {
+      "post": {"operationId": "EchoService_Echo", "tags": ["EchoService"]},
+      "get": {"operationId": "BookService_GetEcho", "tags": ["BookService"]}
+    },
+    "/v1/books": {"get": {"operationId": "BookService_ListBooks", "tags": ["BookService"]}}
+  },
+  "definitions": {
+    "rpcStatus": {"type": "object", "properties": {"code": {"type": "integer"}}},
+    "v1EchoMessage": {"type": "object"},
+    "v1Book": {"type": "object"}
+  }
+}`), &want); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("MergeDocuments(...) differed (-want +got):\n%s", diff)
+	}
+}
+
+func TestMergeDocumentsYAML(t *testing.T) {
+	yamlDoc := "swagger: \"2.0\"\npaths:\n  /v1/books:\n    get:\n      operationId: BookService_ListBooks\n"
+	merged, err := MergeDocuments([]string{"echo.swagger.json", "book.swagger.yaml"}, [][]byte{[]byte(mergeEchoDoc), []byte(yamlDoc)}, "yaml")
+	if err != nil {
+		t.Fatalf("MergeDocuments(...) failed with %v; want success", err)
+	}
+	for _, want := range []string{`"/v1/echo":`, `"/v1/books":`, "operationId: BookService_ListBooks"} {
+		if !strings.Contains(string(merged), want) {
+			t.Errorf("MergeDocuments(...) = %s; want it to contain %q", merged, want)
+		}
+	}
+}
+
+func TestMergeDocumentsConflicts(t *testing.T) {
+	conflicting := `{
+  "swagger": "2.0",
+  "paths": {
+    "/v1/echo": {"post": {"operationId": "OtherService_Echo"}},
+    "/v2/echo": {"post": {"operationId": "EchoService_Echo"}}
+  },
+  "definitions": {"v1EchoMessage": {"type": "string"}}
+}`
+	_, err := MergeDocuments([]string{"echo.swagger.json", "other.swagger.json", "v3.json"}, [][]byte{[]byte(mergeEchoDoc), []byte(conflicting), []byte(`{"openapi": "3.1.0"}`)}, "json")
+	if err == nil {
+		t.Fatalf("MergeDocuments(...) succeeded; want failure")
+	}
+	for _, want := range []string{
+		"paths./v1/echo.post of other.swagger.json differs from echo.swagger.json",
+		"definitions.v1EchoMessage of other.swagger.json differs from echo.swagger.json",
+		"openapi of v3.json differs from the swagger of echo.swagger.json",
+		`operationId "EchoService_Echo" of paths./v2/echo.post (other.swagger.json) is also used by paths./v1/echo.post (echo.swagger.json)`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("MergeDocuments(...) failed with %v; want it to report %q", err, want)
+		}
+	}
+}
+
+func TestMergeDocumentsVersionConflict(t *testing.T) {
+	_, err := MergeDocuments([]string{"a.swagger.json", "b.openapi.json"}, [][]byte{[]byte(`{"swagger": "2.0"}`), []byte(`{"swagger": "3.0"}`)}, "json")
+	if err == nil || !strings.Contains(err.Error(), "swagger of b.openapi.json differs from a.swagger.json") {
+		t.Errorf("MergeDocuments(...) failed with %v; want a version conflict", err)
+	}
+}
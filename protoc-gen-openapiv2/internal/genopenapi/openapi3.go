@@ -0,0 +1,525 @@
+package genopenapi
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// openAPI31RootKeys is the order of the members of OpenAPI 3.1 documents;
+// other members, i.e. extensions, follow them.
+var openAPI31RootKeys = []string{"openapi", "info", "servers", "paths", "webhooks", "components", "security", "tags", "externalDocs"}
+
+// oauth2Flows maps the OpenAPI 2.0 OAuth2 flows to their OpenAPI 3.1 names.
+var oauth2Flows = map[string]string{
+	"implicit":    "implicit",
+	"password":    "password",
+	"application": "clientCredentials",
+	"accessCode":  "authorizationCode",
+}
+
+// openAPI31Converter converts OpenAPI 2.0 documents into OpenAPI 3.1.
+type openAPI31Converter struct {
+	definitions openapiDefinitionsObject
+	consumes    []string
+	produces    []string
+}
+
+// convertToOpenAPI31 returns the OpenAPI 3.1 document describing the same API
+// as swagger: definitions become components, body parameters request bodies,
+// and the oneofs, proto3 optional fields and wrapper fields of messages are
+// mapped to oneOf and null types. Webhooks are taken from the "x-webhooks"
+// extension of the OpenAPI 2.0 document, an object of path items by name, and
+// the callbacks of operations from their "x-callbacks" extensions, see
+// callbackExtensions.
+func convertToOpenAPI31(swagger *openapiSwaggerObject) (*orderedObject, error) {
+	b, err := json.Marshal(swagger)
+	if err != nil {
+		return nil, err
+	}
+	v, err := decodeOrdered(b)
+	if err != nil {
+		return nil, err
+	}
+	doc := v.(*orderedObject)
+	c := &openAPI31Converter{definitions: swagger.Definitions}
+	if v, ok := doc.del("consumes"); ok {
+		c.consumes = stringsOf(v)
+	}
+	if v, ok := doc.del("produces"); ok {
+		c.produces = stringsOf(v)
+	}
+	doc.del("swagger")
+	doc.set("openapi", "3.1.0")
+
+	if servers := convertServers(doc); len(servers) > 0 {
+		doc.set("servers", servers)
+	}
+	if paths, ok := doc.getObject("paths"); ok {
+		for i, kv := range *paths {
+			(*paths)[i].Value = c.pathItem(kv.Value)
+		}
+	}
+	if webhooks, ok := doc.del("x-webhooks"); ok {
+		if webhooks, ok := webhooks.(*orderedObject); ok {
+			for i, kv := range *webhooks {
+				(*webhooks)[i].Value = c.pathItem(kv.Value)
+			}
+			doc.set("webhooks", webhooks)
+		}
+	}
+
+	components := &orderedObject{}
+	if definitions, ok := doc.del("definitions"); ok {
+		if schemas, ok := definitions.(*orderedObject); ok && len(*schemas) > 0 {
+			for _, kv := range *schemas {
+				c.schema(kv.Value)
+				c.messageSchema(kv.Key, kv.Value)
+			}
+			components.set("schemas", schemas)
+		}
+	}
+	if definitions, ok := doc.del("securityDefinitions"); ok {
+		if schemes, ok := definitions.(*orderedObject); ok && len(*schemes) > 0 {
+			for i, kv := range *schemes {
+				(*schemes)[i].Value = convertSecurityScheme(kv.Value)
+			}
+			components.set("securitySchemes", schemes)
+		}
+	}
+	if len(*components) > 0 {
+		doc.set("components", components)
+	}
+
+	rewriteRefs(doc)
+	return reorder(doc, openAPI31RootKeys), nil
+}
+
+// convertServers removes host, basePath and schemes from doc and returns the
+// equivalent servers.
+func convertServers(doc *orderedObject) []interface{} {
+	host, _ := doc.del("host")
+	basePath, _ := doc.del("basePath")
+	schemes, _ := doc.del("schemes")
+	h, _ := host.(string)
+	p, _ := basePath.(string)
+	if h == "" && p == "" {
+		return nil
+	}
+	if h == "" {
+		return []interface{}{&orderedObject{{Key: "url", Value: p}}}
+	}
+	ss := stringsOf(schemes)
+	if len(ss) == 0 {
+		ss = []string{"https"}
+	}
+	var servers []interface{}
+	for _, s := range ss {
+		servers = append(servers, &orderedObject{{Key: "url", Value: s + "://" + h + p}})
+	}
+	return servers
+}
+
+func (c *openAPI31Converter) pathItem(v interface{}) interface{} {
+	item, ok := v.(*orderedObject)
+	if !ok {
+		return v
+	}
+	for i, kv := range *item {
+		if strings.HasPrefix(kv.Key, "x-") {
+			continue
+		}
+		if op, ok := kv.Value.(*orderedObject); ok {
+			(*item)[i].Value = c.operation(op)
+		}
+	}
+	return item
+}
+
+func (c *openAPI31Converter) operation(op *orderedObject) *orderedObject {
+	produces := c.produces
+	if v, ok := op.del("produces"); ok {
+		produces = stringsOf(v)
+	}
+	out := &orderedObject{}
+	for _, kv := range *op {
+		switch kv.Key {
+		case "parameters":
+			var params []interface{}
+			var requestBody *orderedObject
+			for _, p := range kv.Value.([]interface{}) {
+				param, ok := p.(*orderedObject)
+				if !ok {
+					continue
+				}
+				if in, _ := param.getString("in"); in == "body" {
+					requestBody = c.requestBody(param)
+					continue
+				}
+				params = append(params, convertParameter(param))
+			}
+			if len(params) > 0 {
+				out.set("parameters", params)
+			}
+			if requestBody != nil {
+				out.set("requestBody", requestBody)
+			}
+		case "x-callbacks":
+			if callbacks, ok := kv.Value.(*orderedObject); ok {
+				for _, callback := range *callbacks {
+					if expressions, ok := callback.Value.(*orderedObject); ok {
+						for i, kv := range *expressions {
+							(*expressions)[i].Value = c.pathItem(kv.Value)
+						}
+					}
+				}
+				out.set("callbacks", callbacks)
+			}
+		case "responses":
+			responses := kv.Value.(*orderedObject)
+			for i, r := range *responses {
+				if resp, ok := r.Value.(*orderedObject); ok {
+					(*responses)[i].Value = c.response(resp, produces)
+				}
+			}
+			out.set("responses", responses)
+		default:
+			out.set(kv.Key, kv.Value)
+		}
+	}
+	return out
+}
+
+func (c *openAPI31Converter) requestBody(param *orderedObject) *orderedObject {
+	body := &orderedObject{}
+	if desc, ok := param.getString("description"); ok {
+		body.set("description", desc)
+	}
+	schema, _ := param.get("schema")
+	c.schema(schema)
+	body.set("content", content(c.consumes, schema, nil))
+	if required, ok := param.get("required"); ok {
+		body.set("required", required)
+	}
+	return body
+}
+
+func (c *openAPI31Converter) response(resp *orderedObject, produces []string) *orderedObject {
+	out := &orderedObject{}
+	examples, _ := resp.getObject("examples")
+	for _, kv := range *resp {
+		switch kv.Key {
+		case "schema":
+			if schema, ok := kv.Value.(*orderedObject); ok && len(*schema) > 0 {
+				c.schema(schema)
+				out.set("content", content(produces, schema, examples))
+			}
+		case "examples":
+		case "headers":
+			headers := kv.Value.(*orderedObject)
+			for i, h := range *headers {
+				if header, ok := h.Value.(*orderedObject); ok {
+					(*headers)[i].Value = convertHeader(header)
+				}
+			}
+			out.set("headers", headers)
+		default:
+			out.set(kv.Key, kv.Value)
+		}
+	}
+	return out
+}
+
+// content returns the content object of schema in the media types, with the
+// example of each media type in examples.
+func content(mediaTypes []string, schema interface{}, examples *orderedObject) *orderedObject {
+	if len(mediaTypes) == 0 {
+		mediaTypes = []string{"application/json"}
+	}
+	out := &orderedObject{}
+	for _, mt := range mediaTypes {
+		media := &orderedObject{{Key: "schema", Value: schema}}
+		if examples != nil {
+			if example, ok := examples.get(mt); ok {
+				media.set("example", example)
+			}
+		}
+		out.set(mt, media)
+	}
+	return out
+}
+
+// schemaKeys are the members of OpenAPI 2.0 parameters and headers which
+// move into their schema in OpenAPI 3.1.
+var schemaKeys = map[string]bool{
+	"type": true, "format": true, "items": true, "enum": true, "default": true,
+	"minItems": true, "pattern": true,
+}
+
+func convertParameter(param *orderedObject) *orderedObject {
+	out := &orderedObject{}
+	schema := &orderedObject{}
+	in, _ := param.getString("in")
+	for _, kv := range *param {
+		switch {
+		case kv.Key == "collectionFormat":
+			if in != "query" {
+				continue
+			}
+			switch kv.Value {
+			case "csv", "tsv":
+				out.set("explode", false)
+			case "pipes":
+				out.set("style", "pipeDelimited")
+				out.set("explode", false)
+			case "ssv":
+				out.set("style", "spaceDelimited")
+				out.set("explode", false)
+			}
+		case schemaKeys[kv.Key]:
+			schema.set(kv.Key, kv.Value)
+		case kv.Key == "schema":
+			if s, ok := kv.Value.(*orderedObject); ok {
+				schema = s
+			}
+		default:
+			out.set(kv.Key, kv.Value)
+		}
+	}
+	convertSchema(schema)
+	out.set("schema", schema)
+	return out
+}
+
+func convertHeader(header *orderedObject) *orderedObject {
+	out := &orderedObject{}
+	schema := &orderedObject{}
+	for _, kv := range *header {
+		if schemaKeys[kv.Key] {
+			schema.set(kv.Key, kv.Value)
+			continue
+		}
+		out.set(kv.Key, kv.Value)
+	}
+	out.set("schema", schema)
+	return out
+}
+
+func convertSecurityScheme(v interface{}) interface{} {
+	scheme, ok := v.(*orderedObject)
+	if !ok {
+		return v
+	}
+	typ, _ := scheme.getString("type")
+	switch typ {
+	case "basic":
+		out := &orderedObject{{Key: "type", Value: "http"}, {Key: "scheme", Value: "basic"}}
+		for _, kv := range *scheme {
+			if kv.Key != "type" {
+				out.set(kv.Key, kv.Value)
+			}
+		}
+		return out
+	case "apiKey":
+		// OpenAPI 2.0 has no bearer scheme, so bearer tokens are documented
+		// as API keys with the x-bearer-format extension, e.g. "JWT".
+		format, ok := scheme.get("x-bearer-format")
+		if !ok {
+			return scheme
+		}
+		out := &orderedObject{{Key: "type", Value: "http"}, {Key: "scheme", Value: "bearer"}}
+		if format, ok := format.(string); ok && format != "" {
+			out.set("bearerFormat", format)
+		}
+		for _, kv := range *scheme {
+			switch kv.Key {
+			case "type", "name", "in", "x-bearer-format":
+			default:
+				out.set(kv.Key, kv.Value)
+			}
+		}
+		return out
+	case "oauth2":
+		out := &orderedObject{{Key: "type", Value: "oauth2"}}
+		flow := &orderedObject{}
+		var flowName string
+		for _, kv := range *scheme {
+			switch kv.Key {
+			case "type":
+			case "flow":
+				name, _ := kv.Value.(string)
+				flowName = oauth2Flows[name]
+			case "authorizationUrl", "tokenUrl":
+				flow.set(kv.Key, kv.Value)
+			case "scopes":
+			default:
+				out.set(kv.Key, kv.Value)
+			}
+		}
+		scopes, ok := scheme.get("scopes")
+		if !ok {
+			scopes = &orderedObject{}
+		}
+		flow.set("scopes", scopes)
+		if flowName != "" {
+			out.set("flows", &orderedObject{{Key: flowName, Value: flow}})
+		}
+		return out
+	}
+	return scheme
+}
+
+// schema converts the OpenAPI 2.0 schema v, and its subschemas, in place.
+func (c *openAPI31Converter) schema(v interface{}) {
+	if s, ok := v.(*orderedObject); ok {
+		convertSchema(s)
+	}
+}
+
+func convertSchema(s *orderedObject) {
+	for _, bound := range []string{"Maximum", "Minimum"} {
+		exclusive, ok := s.get("exclusive" + bound)
+		if !ok {
+			continue
+		}
+		s.del("exclusive" + bound)
+		limit, hasLimit := s.get(strings.ToLower(bound))
+		if exclusive == true && hasLimit {
+			s.del(strings.ToLower(bound))
+			s.set("exclusive"+bound, limit)
+		}
+	}
+	if nullable, ok := s.del("x-nullable"); ok && nullable == true {
+		makeNullable(s)
+	}
+	for _, key := range []string{"items", "additionalProperties", "not"} {
+		if sub, ok := s.getObject(key); ok {
+			convertSchema(sub)
+		}
+	}
+	if props, ok := s.getObject("properties"); ok {
+		for _, kv := range *props {
+			if sub, ok := kv.Value.(*orderedObject); ok {
+				convertSchema(sub)
+			}
+		}
+	}
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		subs, _ := s.get(key)
+		list, _ := subs.([]interface{})
+		for _, sub := range list {
+			if sub, ok := sub.(*orderedObject); ok {
+				convertSchema(sub)
+			}
+		}
+	}
+}
+
+// messageSchema adds the null types and oneOf constraints of the message
+// rendered as the definition name to its schema.
+func (c *openAPI31Converter) messageSchema(name string, v interface{}) {
+	schema, ok := v.(*orderedObject)
+	if !ok {
+		return
+	}
+	def, ok := c.definitions[name]
+	if !ok {
+		return
+	}
+	if props, ok := schema.getObject("properties"); ok {
+		for _, prop := range def.nullable {
+			if p, ok := props.getObject(prop); ok {
+				makeNullable(p)
+			}
+		}
+	}
+	var groups []interface{}
+	for _, oneof := range def.oneofs {
+		if len(oneof) == 0 {
+			continue
+		}
+		groups = append(groups, oneOfMembers(oneof))
+	}
+	switch len(groups) {
+	case 0:
+	case 1:
+		schema.set("oneOf", groups[0])
+	default:
+		allOf := make([]interface{}, len(groups))
+		for i, g := range groups {
+			allOf[i] = &orderedObject{{Key: "oneOf", Value: g}}
+		}
+		schema.set("allOf", allOf)
+	}
+}
+
+// oneOfMembers returns the alternatives of a oneof of the properties members:
+// exactly one of them, or none, is set.
+func oneOfMembers(members []string) []interface{} {
+	var alternatives, set []interface{}
+	for _, m := range members {
+		alternatives = append(alternatives, &orderedObject{{Key: "required", Value: []interface{}{m}}})
+		set = append(set, &orderedObject{{Key: "required", Value: []interface{}{m}}})
+	}
+	none := &orderedObject{{Key: "not", Value: &orderedObject{{Key: "anyOf", Value: set}}}}
+	return append(alternatives, none)
+}
+
+// makeNullable makes the schema s accept null.
+func makeNullable(s *orderedObject) {
+	if typ, ok := s.getString("type"); ok {
+		s.set("type", []interface{}{typ, "null"})
+		return
+	}
+	if ref, ok := s.del("$ref"); ok {
+		s.set("oneOf", []interface{}{
+			&orderedObject{{Key: "$ref", Value: ref}},
+			&orderedObject{{Key: "type", Value: "null"}},
+		})
+	}
+}
+
+// rewriteRefs rewrites the references to definitions in v into references to
+// component schemas.
+func rewriteRefs(v interface{}) {
+	switch v := v.(type) {
+	case *orderedObject:
+		for i, kv := range *v {
+			if ref, ok := kv.Value.(string); ok && kv.Key == "$ref" {
+				(*v)[i].Value = strings.Replace(ref, "#/definitions/", "#/components/schemas/", 1)
+				continue
+			}
+			rewriteRefs(kv.Value)
+		}
+	case []interface{}:
+		for _, item := range v {
+			rewriteRefs(item)
+		}
+	}
+}
+
+// reorder returns obj with the members keys first, in their order.
+func reorder(obj *orderedObject, keys []string) *orderedObject {
+	out := &orderedObject{}
+	for _, key := range keys {
+		if v, ok := obj.get(key); ok {
+			out.set(key, v)
+		}
+	}
+	for _, kv := range *obj {
+		if _, ok := out.get(kv.Key); !ok {
+			out.set(kv.Key, kv.Value)
+		}
+	}
+	return out
+}
+
+func stringsOf(v interface{}) []string {
+	list, _ := v.([]interface{})
+	var ss []string
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
@@ -0,0 +1,215 @@
+package genopenapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestConvertToOpenAPI31(t *testing.T) {
+	swagger := &openapiSwaggerObject{
+		Swagger:  "2.0",
+		Info:     openapiInfoObject{Title: "example", Version: "1.0"},
+		Host:     "example.com",
+		BasePath: "/api",
+		Schemes:  []string{"https"},
+		Consumes: []string{"application/json"},
+		Produces: []string{"application/json"},
+		Paths: openapiPathsObject{
+			"/v1/messages": openapiPathItemObject{
+				Post: &openapiOperationObject{
+					OperationID: "Messages_Create",
+					Parameters: openapiParametersObject{
+						{
+							Name:     "body",
+							In:       "body",
+							Required: true,
+							Schema:   &openapiSchemaObject{schemaCore: schemaCore{Ref: "#/definitions/Message"}},
+						},
+						{
+							Name:             "tags",
+							In:               "query",
+							Type:             "array",
+							Items:            &openapiItemsObject{Type: "string"},
+							CollectionFormat: "multi",
+						},
+						{
+							Name:             "ids",
+							In:               "query",
+							Type:             "array",
+							Items:            &openapiItemsObject{Type: "string"},
+							CollectionFormat: "pipes",
+						},
+					},
+					Responses: openapiResponsesObject{
+						"200": openapiResponseObject{
+							Description: "A successful response.",
+							Schema:      openapiSchemaObject{schemaCore: schemaCore{Ref: "#/definitions/Message"}},
+						},
+					},
+				},
+			},
+		},
+		Definitions: openapiDefinitionsObject{
+			"Message": openapiSchemaObject{
+				schemaCore: schemaCore{Type: "object"},
+				Properties: &openapiSchemaObjectProperties{
+					{Key: "id", Value: openapiSchemaObject{schemaCore: schemaCore{Type: "string"}}},
+					{Key: "note", Value: openapiSchemaObject{schemaCore: schemaCore{Type: "string"}}},
+					{Key: "parent", Value: openapiSchemaObject{schemaCore: schemaCore{Ref: "#/definitions/Message"}}},
+					{Key: "text", Value: openapiSchemaObject{schemaCore: schemaCore{Type: "string"}}},
+					{Key: "image", Value: openapiSchemaObject{schemaCore: schemaCore{Type: "string", Format: "byte"}}},
+				},
+				nullable: []string{"note", "parent"},
+				oneofs:   [][]string{{"text", "image"}},
+			},
+		},
+		SecurityDefinitions: openapiSecurityDefinitionsObject{
+			"BasicAuth": openapiSecuritySchemeObject{Type: "basic"},
+			"BearerAuth": openapiSecuritySchemeObject{
+				Type:        "apiKey",
+				Description: "Access token",
+				Name:        "Authorization",
+				In:          "header",
+				extensions:  []extension{{key: "x-bearer-format", value: json.RawMessage(`"JWT"`)}},
+			},
+			"OAuth2": openapiSecuritySchemeObject{
+				Type:             "oauth2",
+				Flow:             "accessCode",
+				AuthorizationURL: "https://example.com/oauth/authorize",
+				TokenURL:         "https://example.com/oauth/token",
+				Scopes:           openapiScopesObject{"read": "Grants read access"},
+			},
+		},
+		extensions: []extension{
+			{key: "x-webhooks", value: json.RawMessage(`{"newMessage":{"post":{"operationId":"NewMessage","responses":{"200":{"description":"OK","schema":{"$ref":"#/definitions/Message"}}}}}}`)},
+		},
+	}
+
+	converted, err := convertToOpenAPI31(swagger)
+	if err != nil {
+		t.Fatalf("convertToOpenAPI31(%#v) failed with %v; want success", swagger, err)
+	}
+	var keys []string
+	for _, kv := range *converted {
+		keys = append(keys, kv.Key)
+	}
+	if want := []string{"openapi", "info", "servers", "paths", "webhooks", "components"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v; want %v", keys, want)
+	}
+
+	b, err := json.Marshal(converted)
+	if err != nil {
+		t.Fatalf("json.Marshal(%#v) failed with %v; want success", converted, err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("json.Unmarshal(%s) failed with %v; want success", b, err)
+	}
+
+	for _, spec := range []struct {
+		path []string
+		want string
+	}{
+		{
+			path: []string{"servers"},
+			want: `[{"url":"https://example.com/api"}]`,
+		},
+		{
+			path: []string{"paths", "/v1/messages", "post", "requestBody"},
+			want: `{"required":true,"content":{"application/json":{"schema":{"$ref":"#/components/schemas/Message"}}}}`,
+		},
+		{
+			path: []string{"paths", "/v1/messages", "post", "parameters"},
+			want: `[{"name":"tags","in":"query","required":false,"schema":{"type":"array","items":{"type":"string"}}},{"name":"ids","in":"query","required":false,"style":"pipeDelimited","explode":false,"schema":{"type":"array","items":{"type":"string"}}}]`,
+		},
+		{
+			path: []string{"paths", "/v1/messages", "post", "responses", "200"},
+			want: `{"description":"A successful response.","content":{"application/json":{"schema":{"$ref":"#/components/schemas/Message"}}}}`,
+		},
+		{
+			path: []string{"webhooks", "newMessage", "post", "responses", "200", "content"},
+			want: `{"application/json":{"schema":{"$ref":"#/components/schemas/Message"}}}`,
+		},
+		{
+			path: []string{"components", "schemas", "Message", "properties", "note"},
+			want: `{"type":["string","null"]}`,
+		},
+		{
+			path: []string{"components", "schemas", "Message", "properties", "parent"},
+			want: `{"oneOf":[{"$ref":"#/components/schemas/Message"},{"type":"null"}]}`,
+		},
+		{
+			path: []string{"components", "schemas", "Message", "oneOf"},
+			want: `[{"required":["text"]},{"required":["image"]},{"not":{"anyOf":[{"required":["text"]},{"required":["image"]}]}}]`,
+		},
+		{
+			path: []string{"components", "securitySchemes", "BasicAuth"},
+			want: `{"type":"http","scheme":"basic"}`,
+		},
+		{
+			path: []string{"components", "securitySchemes", "BearerAuth"},
+			want: `{"type":"http","scheme":"bearer","bearerFormat":"JWT","description":"Access token"}`,
+		},
+		{
+			path: []string{"components", "securitySchemes", "OAuth2"},
+			want: `{"type":"oauth2","flows":{"authorizationCode":{"authorizationUrl":"https://example.com/oauth/authorize","tokenUrl":"https://example.com/oauth/token","scopes":{"read":"Grants read access"}}}}`,
+		},
+	} {
+		var got interface{} = doc
+		for _, key := range spec.path {
+			m, ok := got.(map[string]interface{})
+			if !ok {
+				got = nil
+				break
+			}
+			got = m[key]
+		}
+		var want interface{}
+		if err := json.Unmarshal([]byte(spec.want), &want); err != nil {
+			t.Fatalf("json.Unmarshal(%q) failed with %v; want success", spec.want, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			gotJSON, _ := json.Marshal(got)
+			t.Errorf("%v = %s; want %s", spec.path, gotJSON, spec.want)
+		}
+	}
+}
+
+func TestEncodeYAML(t *testing.T) {
+	doc, err := decodeOrdered([]byte(`{
+		"swagger": "2.0",
+		"paths": {"/v1/{name}": {"get": {"parameters": [{"name": "name", "required": true}], "tags": []}}},
+		"definitions": {"Empty": {"type": "object", "properties": {}}},
+		"x-values": ["yes", "1.5", "a: b", 1.5, null]
+	}`))
+	if err != nil {
+		t.Fatalf("decodeOrdered failed with %v; want success", err)
+	}
+	got, err := encodeYAML(doc)
+	if err != nil {
+		t.Fatalf("encodeYAML(%#v) failed with %v; want success", doc, err)
+	}
+	want := `swagger: "2.0"
+paths:
+  "/v1/{name}":
+    get:
+      parameters:
+        - name: name
+          required: true
+      tags: []
+definitions:
+  Empty:
+    type: object
+    properties: {}
+x-values:
+  - "yes"
+  - "1.5"
+  - "a: b"
+  - 1.5
+  - null
+`
+	if string(got) != want {
+		t.Errorf("encodeYAML(%#v) = %s; want %s", doc, got, want)
+	}
+}
@@ -0,0 +1,116 @@
+package genopenapi
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/descriptor"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// definitionsSplitter moves the schemas of the generated OpenAPI documents to
+// a shared definitions file, which the documents reference with $ref, see
+// Registry.SetDefinitionsFile.
+type definitionsSplitter struct {
+	file    string
+	version string
+	schemas orderedObject
+}
+
+func newDefinitionsSplitter(reg *descriptor.Registry) *definitionsSplitter {
+	if reg.GetDefinitionsFile() == "" {
+		return nil
+	}
+	return &definitionsSplitter{file: reg.GetDefinitionsFile(), version: reg.GetOpenAPIVersion()}
+}
+
+// localRefPrefix returns the prefix of the references to schemas within a
+// document.
+func (s *definitionsSplitter) localRefPrefix() string {
+	if s.version == "3.1" {
+		return "#/components/schemas/"
+	}
+	return "#/definitions/"
+}
+
+// split moves the schemas of "doc", generated into the file "name", to the
+// definitions file, keeping the first of the schemas of the same name, and
+// rewrites the references to them.
+func (s *definitionsSplitter) split(name string, doc *orderedObject) {
+	var schemas interface{}
+	if s.version == "3.1" {
+		if components, ok := doc.getObject("components"); ok {
+			schemas, _ = components.del("schemas")
+			if len(*components) == 0 {
+				doc.del("components")
+			}
+		}
+	} else {
+		schemas, _ = doc.del("definitions")
+	}
+	if schemas, ok := schemas.(*orderedObject); ok {
+		for _, kv := range *schemas {
+			if _, ok := s.schemas.get(kv.Key); !ok {
+				s.schemas.set(kv.Key, kv.Value)
+			}
+		}
+	}
+
+	// The definitions file is named relative to the output directory, as
+	// the generated documents are.
+	rel, err := filepath.Rel(filepath.Dir(name), s.file)
+	if err != nil {
+		rel = s.file
+	}
+	prefixRefs(doc, s.localRefPrefix(), filepath.ToSlash(rel))
+}
+
+// prefixRefs prefixes the references of "v" starting with "local" with the
+// file name "file".
+func prefixRefs(v interface{}, local, file string) {
+	switch v := v.(type) {
+	case *orderedObject:
+		for i, kv := range *v {
+			if ref, ok := kv.Value.(string); ok && kv.Key == "$ref" {
+				if strings.HasPrefix(ref, local) {
+					(*v)[i].Value = file + ref
+				}
+				continue
+			}
+			prefixRefs(kv.Value, local, file)
+		}
+	case []interface{}:
+		for _, item := range v {
+			prefixRefs(item, local, file)
+		}
+	}
+}
+
+// encode returns the definitions file in "format", "json" or "yaml".
+func (s *definitionsSplitter) encode(format string) (*descriptor.ResponseFile, error) {
+	doc := &orderedObject{}
+	if s.version == "3.1" {
+		doc.set("components", &orderedObject{{Key: "schemas", Value: &s.schemas}})
+	} else {
+		doc.set("definitions", &s.schemas)
+	}
+	var (
+		formatted []byte
+		err       error
+	)
+	if format == "yaml" {
+		formatted, err = encodeYAML(doc)
+	} else {
+		formatted, err = encodeJSON(doc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &descriptor.ResponseFile{
+		CodeGeneratorResponse_File: &pluginpb.CodeGeneratorResponse_File{
+			Name:    proto.String(s.file),
+			Content: proto.String(string(formatted)),
+		},
+	}, nil
+}
@@ -0,0 +1,144 @@
+package genopenapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/descriptor"
+)
+
+func newSplitTestSwagger(definition string) *openapiSwaggerObject {
+	return &openapiSwaggerObject{
+		Swagger: "2.0",
+		Info:    openapiInfoObject{Title: "example", Version: "1.0"},
+		Paths: openapiPathsObject{
+			"/v1/" + definition: openapiPathItemObject{
+				Get: &openapiOperationObject{
+					OperationID: "Get" + definition,
+					Responses: openapiResponsesObject{
+						"200": openapiResponseObject{
+							Description: "A successful response.",
+							Schema:      openapiSchemaObject{schemaCore: schemaCore{Ref: "#/definitions/" + definition}},
+						},
+						"default": openapiResponseObject{
+							Description: "An unexpected error response.",
+							Schema:      openapiSchemaObject{schemaCore: schemaCore{Ref: "#/definitions/rpcStatus"}},
+						},
+					},
+				},
+			},
+		},
+		Definitions: openapiDefinitionsObject{
+			definition:  openapiSchemaObject{schemaCore: schemaCore{Type: "object"}},
+			"rpcStatus": openapiSchemaObject{schemaCore: schemaCore{Type: "object"}},
+		},
+	}
+}
+
+func TestEncodeOpenAPISplitDefinitions(t *testing.T) {
+	for _, spec := range []struct {
+		version     string
+		schemasPath []string
+		refPrefixes []string
+	}{
+		{
+			version:     "2.0",
+			schemasPath: []string{"definitions"},
+			refPrefixes: []string{
+				"../apidocs.definitions.json#/definitions/",
+				"apidocs.definitions.json#/definitions/",
+			},
+		},
+		{
+			version:     "3.1",
+			schemasPath: []string{"components", "schemas"},
+			refPrefixes: []string{
+				"../apidocs.definitions.json#/components/schemas/",
+				"apidocs.definitions.json#/components/schemas/",
+			},
+		},
+	} {
+		t.Run(spec.version, func(t *testing.T) {
+			reg := descriptor.NewRegistry()
+			reg.SetDefinitionsFile("apidocs.definitions.json")
+			if err := reg.SetOpenAPIVersion(spec.version); err != nil {
+				t.Fatal(err)
+			}
+			splitter := newDefinitionsSplitter(reg)
+
+			for i, file := range []*wrapper{
+				{fileName: "book/book.proto", swagger: newSplitTestSwagger("v1Book")},
+				{fileName: "shelf.proto", swagger: newSplitTestSwagger("v1Shelf")},
+			} {
+				f, err := encodeOpenAPI(file, reg, splitter)
+				if err != nil {
+					t.Fatalf("encodeOpenAPI(%q) failed with %v; want success", file.fileName, err)
+				}
+				var doc map[string]interface{}
+				if err := json.Unmarshal([]byte(f.GetContent()), &doc); err != nil {
+					t.Fatalf("json.Unmarshal(%s) failed with %v; want success", f.GetContent(), err)
+				}
+				if _, ok := doc[spec.schemasPath[0]]; ok {
+					t.Errorf("encodeOpenAPI(%q) = %s; want no %s", file.fileName, f.GetContent(), spec.schemasPath[0])
+				}
+				var refs []string
+				collectRefs(doc, &refs)
+				if len(refs) != 2 {
+					t.Errorf("encodeOpenAPI(%q) references %q; want 2 references", file.fileName, refs)
+				}
+				for _, ref := range refs {
+					if !strings.HasPrefix(ref, spec.refPrefixes[i]) {
+						t.Errorf("encodeOpenAPI(%q) references %q; want references starting with %q", file.fileName, ref, spec.refPrefixes[i])
+					}
+				}
+			}
+
+			f, err := splitter.encode("json")
+			if err != nil {
+				t.Fatalf("splitter.encode(%q) failed with %v; want success", "json", err)
+			}
+			if got, want := f.GetName(), "apidocs.definitions.json"; got != want {
+				t.Errorf("f.GetName() = %q; want %q", got, want)
+			}
+			var defs map[string]interface{}
+			if err := json.Unmarshal([]byte(f.GetContent()), &defs); err != nil {
+				t.Fatalf("json.Unmarshal(%s) failed with %v; want success", f.GetContent(), err)
+			}
+			var schemas interface{} = defs
+			for _, key := range spec.schemasPath {
+				schemas = schemas.(map[string]interface{})[key]
+			}
+			var names []string
+			for name := range schemas.(map[string]interface{}) {
+				names = append(names, name)
+			}
+			if len(names) != 3 {
+				t.Errorf("schemas of the definitions file = %v; want v1Book, v1Shelf and rpcStatus", names)
+			}
+		})
+	}
+}
+
+func collectRefs(v interface{}, refs *[]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if ref, ok := value.(string); ok && key == "$ref" {
+				*refs = append(*refs, ref)
+				continue
+			}
+			collectRefs(value, refs)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectRefs(item, refs)
+		}
+	}
+}
+
+func TestNewDefinitionsSplitterDisabled(t *testing.T) {
+	if got := newDefinitionsSplitter(descriptor.NewRegistry()); got != nil {
+		t.Errorf("newDefinitionsSplitter(...) = %v; want nil without a definitions file", got)
+	}
+}
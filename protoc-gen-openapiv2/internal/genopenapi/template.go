@@ -94,6 +94,19 @@ var wktSchemas = map[string]schemaCore{
 	},
 }
 
+// wktWrappers are the well-known wrapper types, whose fields accept null.
+var wktWrappers = map[string]struct{}{
+	".google.protobuf.StringValue": {},
+	".google.protobuf.BytesValue":  {},
+	".google.protobuf.Int32Value":  {},
+	".google.protobuf.UInt32Value": {},
+	".google.protobuf.Int64Value":  {},
+	".google.protobuf.UInt64Value": {},
+	".google.protobuf.FloatValue":  {},
+	".google.protobuf.DoubleValue": {},
+	".google.protobuf.BoolValue":   {},
+}
+
 func listEnumNames(enum *descriptor.Enum) (names []string) {
 	for _, value := range enum.GetValue() {
 		names = append(names, value.GetName())
@@ -429,6 +442,15 @@ func renderMessagesAsDefinition(messages messageMap, d openapiDefinitionsObject,
 			} else {
 				kv.Key = f.GetName()
 			}
+			if isNullableField(f) {
+				schema.nullable = append(schema.nullable, kv.Key)
+			}
+			if f.OneofIndex != nil && !f.GetProto3Optional() {
+				for int(f.GetOneofIndex()) >= len(schema.oneofs) {
+					schema.oneofs = append(schema.oneofs, nil)
+				}
+				schema.oneofs[f.GetOneofIndex()] = append(schema.oneofs[f.GetOneofIndex()], kv.Key)
+			}
 			if schema.Properties == nil {
 				schema.Properties = &openapiSchemaObjectProperties{}
 			}
@@ -438,6 +460,19 @@ func renderMessagesAsDefinition(messages messageMap, d openapiDefinitionsObject,
 	}
 }
 
+// isNullableField reports whether f accepts and renders JSON null, i.e. it is
+// a proto3 optional field or a well-known wrapper type.
+func isNullableField(f *descriptor.Field) bool {
+	if f.GetProto3Optional() {
+		return true
+	}
+	if f.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		return false
+	}
+	_, ok := wktWrappers[f.GetTypeName()]
+	return ok
+}
+
 // schemaOfField returns a OpenAPI Schema Object for a protobuf field.
 func schemaOfField(f *descriptor.Field, reg *descriptor.Registry, refs refMap) openapiSchemaObject {
 	const (
@@ -524,6 +559,10 @@ func schemaOfField(f *descriptor.Field, reg *descriptor.Registry, refs refMap) o
 		updateSwaggerObjectFromFieldBehavior(&ret, j, f)
 	}
 
+	if reg.GetValidationConstraints() {
+		updateSwaggerObjectFromValidationRules(&ret, f)
+	}
+
 	return ret
 }
 
@@ -1010,18 +1049,25 @@ func renderServices(services []*descriptor.Service, paths openapiPathsObject, re
 				}
 				if meth.GetServerStreaming() {
 					desc += "(streaming responses)"
+					result := openapiSchemaObject{
+						schemaCore: schemaCore{
+							Ref: responseSchema.Ref,
+						},
+					}
+					if b.ResponseBody != nil && len(b.ResponseBody.FieldPath) != 0 {
+						// Every streamed message is unwrapped to its response body,
+						// which is not necessarily a message.
+						result = responseSchema
+						responseSchema = openapiSchemaObject{}
+					}
 					responseSchema.Type = "object"
 					swgRef, _ := fullyQualifiedNameToOpenAPIName(meth.ResponseType.FQMN(), reg)
 					responseSchema.Title = "Stream result of " + swgRef
 
 					props := openapiSchemaObjectProperties{
 						keyVal{
-							Key: "result",
-							Value: openapiSchemaObject{
-								schemaCore: schemaCore{
-									Ref: responseSchema.Ref,
-								},
-							},
+							Key:   "result",
+							Value: result,
 						},
 					}
 					statusDef, hasStatus := fullyQualifiedNameToOpenAPIName(".google.rpc.Status", reg)
@@ -1089,14 +1135,14 @@ func renderServices(services []*descriptor.Service, paths openapiPathsObject, re
 					panic(err)
 				}
 
+				operationObject.Deprecated = meth.IsDeprecated()
 				opts, err := getMethodOpenAPIOption(reg, meth)
 				if opts != nil {
 					if err != nil {
 						panic(err)
 					}
 					operationObject.ExternalDocs = protoExternalDocumentationToOpenAPIExternalDocumentation(opts.ExternalDocs, reg, meth)
-					// TODO(ivucica): this would be better supported by looking whether the method is deprecated in the proto file
-					operationObject.Deprecated = opts.Deprecated
+					operationObject.Deprecated = operationObject.Deprecated || opts.Deprecated
 
 					if opts.Summary != "" {
 						operationObject.Summary = opts.Summary
@@ -1170,6 +1216,9 @@ func renderServices(services []*descriptor.Service, paths openapiPathsObject, re
 						if err != nil {
 							return err
 						}
+						if err := resolveCallbackRefs(exts, reg, customRefs); err != nil {
+							return fmt.Errorf("%s: %v", meth.FQMN(), err)
+						}
 						operationObject.extensions = exts
 					}
 
@@ -1464,6 +1513,9 @@ func applyTemplate(p param) (*openapiSwaggerObject, error) {
 			if err != nil {
 				return nil, err
 			}
+			if err := resolveCallbackRefs(exts, p.reg, customRefs); err != nil {
+				return nil, fmt.Errorf("%s: %v", p.File.GetName(), err)
+			}
 			s.extensions = exts
 		}
 
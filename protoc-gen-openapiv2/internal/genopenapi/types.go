@@ -259,6 +259,12 @@ type openapiSchemaObject struct {
 	MaxProperties    uint64   `json:"maxProperties,omitempty"`
 	MinProperties    uint64   `json:"minProperties,omitempty"`
 	Required         []string `json:"required,omitempty"`
+
+	// oneofs are the names of the properties of the members of each oneof of
+	// the message, and nullable those of the properties accepting null. They
+	// are only rendered in OpenAPI 3.1 documents, see convertToOpenAPI31.
+	oneofs   [][]string
+	nullable []string
 }
 
 // http://swagger.io/specification/#definitionsObject
@@ -0,0 +1,301 @@
+package genopenapi
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/descriptor"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The numbers of the extensions of google.protobuf.FieldOptions holding the
+// validation rules of fields, validate.rules of protoc-gen-validate and
+// buf.validate.field of protovalidate. Their Go types are not linked in, so
+// the rules are read from the unknown fields of the options.
+const (
+	pgvRulesExtension           protowire.Number = 1071
+	protovalidateRulesExtension protowire.Number = 1159
+)
+
+// The numbers of the rules in validate.FieldRules and
+// buf.validate.FieldConstraints, which mostly agree.
+const (
+	stringRulesField             protowire.Number = 14
+	pgvMessageRulesField         protowire.Number = 17
+	repeatedRulesField           protowire.Number = 18
+	mapRulesField                protowire.Number = 19
+	protovalidateRequiredField   protowire.Number = 25
+	pgvMessageRulesRequiredField protowire.Number = 2
+)
+
+// numberRules are the numeric rules of validate.FieldRules and
+// buf.validate.FieldConstraints, by number, with the decoding of their const,
+// lt, lte, gt and gte values.
+var numberRules = []struct {
+	field  protowire.Number
+	decode func(uint64) float64
+}{
+	{1, func(v uint64) float64 { return float64(math.Float32frombits(uint32(v))) }},
+	{2, math.Float64frombits},
+	{3, func(v uint64) float64 { return float64(int64(v)) }},
+	{4, func(v uint64) float64 { return float64(int64(v)) }},
+	{5, func(v uint64) float64 { return float64(v) }},
+	{6, func(v uint64) float64 { return float64(v) }},
+	{7, func(v uint64) float64 { return float64(protowire.DecodeZigZag(v)) }},
+	{8, func(v uint64) float64 { return float64(protowire.DecodeZigZag(v)) }},
+	{9, func(v uint64) float64 { return float64(v) }},
+	{10, func(v uint64) float64 { return float64(v) }},
+	{11, func(v uint64) float64 { return float64(int32(v)) }},
+	{12, func(v uint64) float64 { return float64(int64(v)) }},
+}
+
+// stringFormats maps the numbers of the well-known string rules to the
+// formats of the schemas of the strings they validate.
+var stringFormats = []struct {
+	field  protowire.Number
+	format string
+}{
+	{12, "email"},
+	{13, "hostname"},
+	{15, "ipv4"},
+	{16, "ipv6"},
+	{17, "uri"},
+	{18, "uri-reference"},
+	{22, "uuid"},
+}
+
+// updateSwaggerObjectFromValidationRules sets the constraints of the schema
+// "s" of "field" which its protoc-gen-validate or protovalidate rules imply,
+// unless they are already set, e.g. by the openapiv2_field option of the
+// field.
+func updateSwaggerObjectFromValidationRules(s *openapiSchemaObject, field *descriptor.Field) {
+	// Per the JSON Reference syntax: Any members other than "$ref" in a JSON Reference object SHALL be ignored.
+	// https://tools.ietf.org/html/draft-pbryan-zyp-json-ref-03#section-3
+	if s.Ref != "" || field.Options == nil {
+		return
+	}
+	opts := parseWireMessage(field.Options.ProtoReflect().GetUnknown())
+	if rules := opts.message(pgvRulesExtension); rules != nil {
+		if rules.message(pgvMessageRulesField).bool(pgvMessageRulesRequiredField) {
+			addRequired(s, field.GetName())
+		}
+		applyValidationRules(s, rules)
+	}
+	if rules := opts.message(protovalidateRulesExtension); rules != nil {
+		if rules.bool(protovalidateRequiredField) {
+			addRequired(s, field.GetName())
+		}
+		applyValidationRules(s, rules)
+	}
+}
+
+func addRequired(s *openapiSchemaObject, name string) {
+	if find(s.Required, name) == -1 {
+		s.Required = append(s.Required, name)
+	}
+}
+
+func applyValidationRules(s *openapiSchemaObject, rules wireMessage) {
+	switch s.Type {
+	case "array":
+		if r := rules.message(repeatedRulesField); r != nil {
+			if v, ok := r.number(1); ok && s.MinItems == 0 {
+				s.MinItems = v
+			}
+			if v, ok := r.number(2); ok && s.MaxItems == 0 {
+				s.MaxItems = v
+			}
+			if r.bool(3) {
+				s.UniqueItems = true
+			}
+		}
+		return
+	case "object":
+		if r := rules.message(mapRulesField); r != nil {
+			if v, ok := r.number(1); ok && s.MinProperties == 0 {
+				s.MinProperties = v
+			}
+			if v, ok := r.number(2); ok && s.MaxProperties == 0 {
+				s.MaxProperties = v
+			}
+		}
+		return
+	}
+	if r := rules.message(stringRulesField); r != nil {
+		applyStringRules(s, r)
+		return
+	}
+	for _, nr := range numberRules {
+		if r := rules.message(nr.field); r != nil {
+			applyNumberRules(s, r, nr.decode)
+			return
+		}
+	}
+}
+
+func applyStringRules(s *openapiSchemaObject, r wireMessage) {
+	if v, ok := r.string(1); ok && s.Example == nil {
+		s.Example, _ = json.Marshal(v)
+	}
+	minLen, hasMin := r.number(2)
+	maxLen, hasMax := r.number(3)
+	if v, ok := r.number(19); ok {
+		minLen, hasMin, maxLen, hasMax = v, true, v, true
+	}
+	if hasMin && s.MinLength == 0 {
+		s.MinLength = minLen
+	}
+	if hasMax && s.MaxLength == 0 {
+		s.MaxLength = maxLen
+	}
+	if v, ok := r.string(6); ok && s.Pattern == "" {
+		s.Pattern = v
+	}
+	if in := r.strings(10); len(in) > 0 && len(s.Enum) == 0 {
+		s.Enum = in
+	}
+	if s.Format == "" {
+		for _, sf := range stringFormats {
+			if r.bool(sf.field) {
+				s.Format = sf.format
+				break
+			}
+		}
+	}
+}
+
+func applyNumberRules(s *openapiSchemaObject, r wireMessage, decode func(uint64) float64) {
+	if v, ok := r.number(1); ok && s.Example == nil {
+		// 64-bit integers are rendered as JSON strings.
+		example := strconv.FormatFloat(decode(v), 'f', -1, 64)
+		if s.Type == "string" {
+			example = strconv.Quote(example)
+		}
+		s.Example = json.RawMessage(example)
+	}
+	upper, exclusiveMax, hasMax := decodeBound(r, 2, 3, decode)
+	lower, exclusiveMin, hasMin := decodeBound(r, 4, 5, decode)
+	if hasMax && hasMin && upper < lower {
+		// The rules exclude the range between the bounds, which
+		// OpenAPI schemas cannot describe.
+		return
+	}
+	if hasMax && s.Maximum == 0 && !s.ExclusiveMaximum {
+		s.Maximum, s.ExclusiveMaximum = upper, exclusiveMax
+	}
+	if hasMin && s.Minimum == 0 && !s.ExclusiveMinimum {
+		s.Minimum, s.ExclusiveMinimum = lower, exclusiveMin
+	}
+}
+
+// decodeBound returns the bound of the exclusive rule "exclusive" or else of
+// the inclusive rule "inclusive" of "r".
+func decodeBound(r wireMessage, exclusive, inclusive protowire.Number, decode func(uint64) float64) (float64, bool, bool) {
+	if v, ok := r.number(exclusive); ok {
+		return decode(v), true, true
+	}
+	if v, ok := r.number(inclusive); ok {
+		return decode(v), false, true
+	}
+	return 0, false, false
+}
+
+// wireMessage holds the values of the fields of a message in the wire
+// format, by number, in the order they occur.
+type wireMessage map[protowire.Number][]wireValue
+
+type wireValue struct {
+	typ protowire.Type
+	// num is the value of varint, fixed32 and fixed64 fields.
+	num uint64
+	// raw is the value of length-delimited fields.
+	raw []byte
+}
+
+// parseWireMessage parses "b" into a wireMessage, ignoring anything following
+// malformed data.
+func parseWireMessage(b []byte) wireMessage {
+	m := make(wireMessage)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return m
+		}
+		b = b[n:]
+		v := wireValue{typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			v.num, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var x uint32
+			x, n = protowire.ConsumeFixed32(b)
+			v.num = uint64(x)
+		case protowire.Fixed64Type:
+			v.num, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			v.raw, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return m
+		}
+		b = b[n:]
+		m[num] = append(m[num], v)
+	}
+	return m
+}
+
+// message returns the message field "num", merging its occurrences, or nil if
+// it is not set.
+func (m wireMessage) message(num protowire.Number) wireMessage {
+	var b []byte
+	found := false
+	for _, v := range m[num] {
+		if v.typ == protowire.BytesType {
+			b = append(b, v.raw...)
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return parseWireMessage(b)
+}
+
+// number returns the last value of the scalar field "num".
+func (m wireMessage) number(num protowire.Number) (uint64, bool) {
+	vs := m[num]
+	for i := len(vs) - 1; i >= 0; i-- {
+		if vs[i].typ != protowire.BytesType && vs[i].typ != protowire.StartGroupType {
+			return vs[i].num, true
+		}
+	}
+	return 0, false
+}
+
+func (m wireMessage) bool(num protowire.Number) bool {
+	v, ok := m.number(num)
+	return ok && v != 0
+}
+
+// string returns the last value of the string field "num".
+func (m wireMessage) string(num protowire.Number) (string, bool) {
+	ss := m.strings(num)
+	if len(ss) == 0 {
+		return "", false
+	}
+	return ss[len(ss)-1], true
+}
+
+// strings returns the values of the repeated string field "num".
+func (m wireMessage) strings(num protowire.Number) []string {
+	var ss []string
+	for _, v := range m[num] {
+		if v.typ == protowire.BytesType {
+			ss = append(ss, string(v.raw))
+		}
+	}
+	return ss
+}
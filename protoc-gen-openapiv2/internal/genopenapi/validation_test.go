@@ -0,0 +1,169 @@
+package genopenapi
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/descriptor"
+	openapi_options "github.com/grpc-ecosystem/grpc-gateway/v2/protoc-gen-openapiv2/options"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// appendMessage appends the message field "num" made of "fields" to "b".
+func appendMessage(b []byte, num protowire.Number, fields ...[]byte) []byte {
+	var m []byte
+	for _, f := range fields {
+		m = append(m, f...)
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+func varintField(num protowire.Number, v uint64) []byte {
+	return protowire.AppendVarint(protowire.AppendTag(nil, num, protowire.VarintType), v)
+}
+
+func stringField(num protowire.Number, s string) []byte {
+	return protowire.AppendString(protowire.AppendTag(nil, num, protowire.BytesType), s)
+}
+
+func doubleField(num protowire.Number, v float64) []byte {
+	return protowire.AppendFixed64(protowire.AppendTag(nil, num, protowire.Fixed64Type), math.Float64bits(v))
+}
+
+func TestSchemaOfFieldValidationRules(t *testing.T) {
+	for _, spec := range []struct {
+		name     string
+		typ      descriptorpb.FieldDescriptorProto_Type
+		label    descriptorpb.FieldDescriptorProto_Label
+		rules    []byte
+		jsonOpt  *openapi_options.JSONSchema
+		expected openapiSchemaObject
+	}{
+		{
+			name: "pgv string",
+			typ:  descriptorpb.FieldDescriptorProto_TYPE_STRING,
+			rules: appendMessage(nil, pgvRulesExtension,
+				appendMessage(nil, 14, varintField(2, 3), varintField(3, 64), stringField(6, "^[a-z]+$"), varintField(12, 1)),
+				appendMessage(nil, 17, varintField(2, 1)),
+			),
+			expected: openapiSchemaObject{
+				schemaCore: schemaCore{Type: "string", Format: "email"},
+				MinLength:  3,
+				MaxLength:  64,
+				Pattern:    "^[a-z]+$",
+				Required:   []string{"field"},
+			},
+		},
+		{
+			name: "protovalidate string",
+			typ:  descriptorpb.FieldDescriptorProto_TYPE_STRING,
+			rules: appendMessage(nil, protovalidateRulesExtension,
+				appendMessage(nil, 14, stringField(1, "fixed"), varintField(19, 5), stringField(10, "a"), stringField(10, "b")),
+				varintField(25, 1),
+			),
+			expected: openapiSchemaObject{
+				schemaCore: schemaCore{Type: "string", Example: json.RawMessage(`"fixed"`), Enum: []string{"a", "b"}},
+				MinLength:  5,
+				MaxLength:  5,
+				Required:   []string{"field"},
+			},
+		},
+		{
+			name: "int32 bounds",
+			typ:  descriptorpb.FieldDescriptorProto_TYPE_INT32,
+			rules: appendMessage(nil, protovalidateRulesExtension,
+				appendMessage(nil, 3, varintField(2, 100), varintField(5, math.MaxUint64-4)), // gte: -5
+			),
+			expected: openapiSchemaObject{
+				schemaCore:       schemaCore{Type: "integer", Format: "int32"},
+				Maximum:          100,
+				ExclusiveMaximum: true,
+				Minimum:          -5,
+			},
+		},
+		{
+			name: "int64 const",
+			typ:  descriptorpb.FieldDescriptorProto_TYPE_INT64,
+			rules: appendMessage(nil, pgvRulesExtension,
+				appendMessage(nil, 4, varintField(1, 42)),
+			),
+			expected: openapiSchemaObject{
+				schemaCore: schemaCore{Type: "string", Format: "int64", Example: json.RawMessage(`"42"`)},
+			},
+		},
+		{
+			name: "double exclusive range",
+			typ:  descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+			rules: appendMessage(nil, pgvRulesExtension,
+				appendMessage(nil, 2, doubleField(2, 1), doubleField(4, 10)),
+			),
+			expected: openapiSchemaObject{
+				schemaCore: schemaCore{Type: "number", Format: "double"},
+			},
+		},
+		{
+			name:  "repeated",
+			typ:   descriptorpb.FieldDescriptorProto_TYPE_STRING,
+			label: descriptorpb.FieldDescriptorProto_LABEL_REPEATED,
+			rules: appendMessage(nil, pgvRulesExtension,
+				appendMessage(nil, 18, varintField(1, 1), varintField(2, 10), varintField(3, 1)),
+			),
+			expected: openapiSchemaObject{
+				schemaCore:  schemaCore{Type: "array", Items: &openapiItemsObject{Type: "string"}},
+				MinItems:    1,
+				MaxItems:    10,
+				UniqueItems: true,
+			},
+		},
+		{
+			name: "openapiv2_field takes precedence",
+			typ:  descriptorpb.FieldDescriptorProto_TYPE_STRING,
+			rules: appendMessage(nil, pgvRulesExtension,
+				appendMessage(nil, 14, varintField(3, 64), stringField(6, "^[a-z]+$")),
+			),
+			jsonOpt: &openapi_options.JSONSchema{MaxLength: 10},
+			expected: openapiSchemaObject{
+				schemaCore: schemaCore{Type: "string"},
+				MaxLength:  10,
+				Pattern:    "^[a-z]+$",
+			},
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			opts := new(descriptorpb.FieldOptions)
+			if spec.jsonOpt != nil {
+				proto.SetExtension(opts, openapi_options.E_Openapiv2Field, spec.jsonOpt)
+			}
+			opts.ProtoReflect().SetUnknown(spec.rules)
+			field := &descriptor.Field{
+				Message: &descriptor.Message{
+					File:            &descriptor.File{FileDescriptorProto: &descriptorpb.FileDescriptorProto{Package: proto.String("example")}},
+					DescriptorProto: &descriptorpb.DescriptorProto{Name: proto.String("Message")},
+				},
+				FieldDescriptorProto: &descriptorpb.FieldDescriptorProto{
+					Name:    proto.String("field"),
+					Type:    spec.typ.Enum(),
+					Label:   spec.label.Enum(),
+					Options: opts,
+				},
+			}
+
+			reg := descriptor.NewRegistry()
+			if got := schemaOfField(field, reg, nil); got.Required != nil || got.Pattern != "" {
+				t.Errorf("schemaOfField(%q) applied validation rules without validation_constraints: %+v", spec.name, got)
+			}
+
+			reg.SetValidationConstraints(true)
+			got := schemaOfField(field, reg, nil)
+			if diff := cmp.Diff(spec.expected, got, cmpopts.IgnoreUnexported(openapiSchemaObject{})); diff != "" {
+				t.Errorf("schemaOfField(%q) differed (-want +got):\n%s", spec.name, diff)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strings"
 
@@ -33,6 +34,12 @@ var (
 	simpleOperationIDs         = flag.Bool("simple_operation_ids", false, "whether to remove the service prefix in the operationID generation. Can introduce duplicate operationIDs, use with caution.")
 	openAPIConfiguration       = flag.String("openapi_configuration", "", "path to file which describes the OpenAPI Configuration in YAML format")
 	generateUnboundMethods     = flag.Bool("generate_unbound_methods", false, "generate swagger metadata even for RPC methods that have no HttpRule annotation")
+	omitDeprecatedMethods      = flag.Bool("omit_deprecated_methods", false, "do not generate the operations of the methods marked deprecated, or of services marked deprecated, with the `deprecated` option")
+	validationConstraints      = flag.Bool("validation_constraints", false, "if set, the minimum, maximum, length, pattern, format, enum, example and required constraints of the schemas of fields are derived from their protoc-gen-validate and protovalidate rules, unless set by openapiv2_field options")
+	openAPIVersion             = flag.String("openapi_version", "2.0", "version of the OpenAPI specification to generate documents for. Allowed values are `2.0` and `3.1`")
+	outputFormat               = flag.String("output_format", "json", "format of the generated OpenAPI documents. Allowed values are `json` and `yaml`")
+	definitionsFile            = flag.String("definitions_file", "", "if set, the schemas of the definitions of the generated OpenAPI documents are written once to this file, e.g. `apidocs.definitions.json`, and referenced by the documents with `$ref`")
+	mergeOutput                = flag.String("merge_output", "", "if set, the OpenAPI documents named by the arguments, e.g. generated from different proto packages, are merged into this file in `output_format`, instead of processing a code generator request. Conflicting definitions in the documents are reported and fail the merge")
 )
 
 // Variables set by goreleaser at build time
@@ -51,6 +58,14 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *mergeOutput != "" {
+		if err := mergeDocuments(*mergeOutput, flag.Args()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	reg := descriptor.NewRegistry()
 
 	glog.V(1).Info("Processing code generator request")
@@ -89,10 +104,21 @@ func main() {
 	reg.SetDisableDefaultErrors(*disableDefaultErrors)
 	reg.SetSimpleOperationIDs(*simpleOperationIDs)
 	reg.SetGenerateUnboundMethods(*generateUnboundMethods)
+	reg.SetOmitDeprecatedMethods(*omitDeprecatedMethods)
+	reg.SetValidationConstraints(*validationConstraints)
+	reg.SetDefinitionsFile(*definitionsFile)
 	if err := reg.SetRepeatedPathParamSeparator(*repeatedPathParamSeparator); err != nil {
 		emitError(err)
 		return
 	}
+	if err := reg.SetOpenAPIVersion(*openAPIVersion); err != nil {
+		emitError(err)
+		return
+	}
+	if err := reg.SetOpenAPIOutputFormat(*outputFormat); err != nil {
+		emitError(err)
+		return
+	}
 	for k, v := range pkgMap {
 		reg.AddPkgMap(k, v)
 	}
@@ -141,6 +167,25 @@ func main() {
 	emitFiles(out)
 }
 
+// mergeDocuments merges the OpenAPI documents "inputs" into the file "output".
+func mergeDocuments(output string, inputs []string) error {
+	if len(inputs) == 0 {
+		return fmt.Errorf("no OpenAPI documents to merge into %s", output)
+	}
+	docs := make([][]byte, len(inputs))
+	for i, input := range inputs {
+		var err error
+		if docs[i], err = ioutil.ReadFile(input); err != nil {
+			return err
+		}
+	}
+	merged, err := genopenapi.MergeDocuments(inputs, docs, *outputFormat)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(output, merged, 0644)
+}
+
 func emitFiles(out []*descriptor.ResponseFile) {
 	files := make([]*pluginpb.CodeGeneratorResponse_File, len(out))
 	for idx, item := range out {
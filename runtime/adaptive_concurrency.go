@@ -0,0 +1,144 @@
+package runtime
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AdaptiveConcurrencyConfig configures the adaptive concurrency limits of
+// WithAdaptiveConcurrency.
+type AdaptiveConcurrencyConfig struct {
+	// InitialLimit is the concurrency limit of a route before it is
+	// adjusted. It defaults to 20.
+	InitialLimit int
+	// MinLimit and MaxLimit bound the concurrency limits. They default to 1
+	// and 1000.
+	MinLimit int
+	MaxLimit int
+	// LatencyThreshold is the latency above which a request is a sign that
+	// its route is overloaded. It defaults to 1 second.
+	LatencyThreshold time.Duration
+	// BackoffRatio is the ratio the limit of a route is multiplied by when
+	// it is overloaded, between 0 and 1. It defaults to 0.9.
+	BackoffRatio float64
+	// RetryAfter is the value of the Retry-After header of the requests
+	// shed. It defaults to 1 second.
+	RetryAfter time.Duration
+}
+
+// WithAdaptiveConcurrency returns a ServeMuxOption limiting the number of
+// concurrent requests of each route with a limit adjusted to the latency of
+// its requests, additively increasing it while the requests are faster than
+// config.LatencyThreshold and multiplicatively decreasing it otherwise
+// (AIMD). It suits backends of unknown capacity, e.g. the ones of routes
+// added dynamically, better than static limits, see WithAdmissionControl.
+// The limit only grows while the route uses at least half of it. The requests
+// above the limit are shed with codes.Unavailable and a Retry-After header.
+func WithAdaptiveConcurrency(config AdaptiveConcurrencyConfig) ServeMuxOption {
+	if config.InitialLimit <= 0 {
+		config.InitialLimit = 20
+	}
+	if config.MinLimit <= 0 {
+		config.MinLimit = 1
+	}
+	if config.MaxLimit <= 0 {
+		config.MaxLimit = 1000
+	}
+	if config.LatencyThreshold <= 0 {
+		config.LatencyThreshold = time.Second
+	}
+	if config.BackoffRatio <= 0 || config.BackoffRatio >= 1 {
+		config.BackoffRatio = 0.9
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = time.Second
+	}
+	return func(mux *ServeMux) {
+		mux.adaptiveConcurrency = &adaptiveConcurrency{config: config, limiters: make(map[string]*aimdLimiter)}
+	}
+}
+
+// adaptiveConcurrency holds the limiters of the routes of a ServeMux.
+type adaptiveConcurrency struct {
+	config AdaptiveConcurrencyConfig
+
+	mu       sync.Mutex
+	limiters map[string]*aimdLimiter
+}
+
+// aimdLimiter is the concurrency limiter of a route.
+type aimdLimiter struct {
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+}
+
+// ConcurrencyLimits returns the current adaptive concurrency limits of the
+// routes, by method and path template, e.g. "GET /v1/{name=books/*}", see
+// WithAdaptiveConcurrency.
+func (s *ServeMux) ConcurrencyLimits() map[string]int {
+	limits := make(map[string]int)
+	if s.adaptiveConcurrency == nil {
+		return limits
+	}
+	s.adaptiveConcurrency.mu.Lock()
+	defer s.adaptiveConcurrency.mu.Unlock()
+	for route, l := range s.adaptiveConcurrency.limiters {
+		l.mu.Lock()
+		limits[route] = int(l.limit)
+		l.mu.Unlock()
+	}
+	return limits
+}
+
+// limiter returns the limiter of the route "route", creating it if needed.
+func (c *adaptiveConcurrency) limiter(route string) *aimdLimiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.limiters[route]
+	if !ok {
+		l = &aimdLimiter{limit: float64(c.config.InitialLimit)}
+		c.limiters[route] = l
+	}
+	return l
+}
+
+// acquireConcurrency admits r to the route h within its concurrency limit, and returns
+// a function adjusting the limit to the latency of r once served. If the
+// limit is reached, it sheds r and returns false.
+func (s *ServeMux) acquireConcurrency(w http.ResponseWriter, r *http.Request, h handler) (done func(), ok bool) {
+	c := s.adaptiveConcurrency
+	if c == nil {
+		return func() {}, true
+	}
+	l := c.limiter(r.Method + " " + h.pat.String())
+	l.mu.Lock()
+	if l.inflight >= int(l.limit) {
+		l.mu.Unlock()
+		s.shed(w, r, c.config.RetryAfter)
+		return nil, false
+	}
+	l.inflight++
+	l.mu.Unlock()
+
+	start := time.Now()
+	return func() {
+		latency := time.Since(start)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		switch {
+		case latency > c.config.LatencyThreshold:
+			l.limit *= c.config.BackoffRatio
+			if l.limit < float64(c.config.MinLimit) {
+				l.limit = float64(c.config.MinLimit)
+			}
+		case float64(l.inflight*2) >= l.limit:
+			l.limit++
+			if l.limit > float64(c.config.MaxLimit) {
+				l.limit = float64(c.config.MaxLimit)
+			}
+		}
+		l.inflight--
+	}, true
+}
@@ -0,0 +1,65 @@
+package runtime_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	mux := runtime.NewServeMuxDynamic(runtime.WithAdaptiveConcurrency(runtime.AdaptiveConcurrencyConfig{
+		InitialLimit:     2,
+		LatencyThreshold: 50 * time.Millisecond,
+		BackoffRatio:     0.5,
+	}))
+	started, unblock := make(chan struct{}), make(chan struct{})
+	if err := mux.HandlePath("GET", "/v1/{delay}", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		switch params["delay"] {
+		case "slow":
+			time.Sleep(100 * time.Millisecond)
+		case "blocking":
+			started <- struct{}{}
+			<-unblock
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	limit := func() int {
+		return mux.ConcurrencyLimits()["GET /v1/{delay=*}"]
+	}
+
+	// A fast request using half of the limit increases it.
+	if w := get("/v1/fast"); w.Code != http.StatusOK {
+		t.Fatalf("GET /v1/fast replied %d; want %d", w.Code, http.StatusOK)
+	}
+	if got := limit(); got != 3 {
+		t.Errorf("limit = %d after a fast request; want 3", got)
+	}
+	// A slow request decreases it.
+	get("/v1/slow")
+	if got := limit(); got != 1 {
+		t.Errorf("limit = %d after a slow request; want 1", got)
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- get("/v1/blocking") }()
+	<-started
+	if w := get("/v1/fast"); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("GET /v1/fast replied %d with Retry-After %q above the limit; want %d with 1", w.Code, w.Header().Get("Retry-After"), http.StatusServiceUnavailable)
+	}
+	close(unblock)
+	if w := <-done; w.Code != http.StatusOK {
+		t.Errorf("GET /v1/blocking replied %d; want %d", w.Code, http.StatusOK)
+	}
+	if w := get("/v1/fast"); w.Code != http.StatusOK {
+		t.Errorf("GET /v1/fast replied %d once the route is free; want %d", w.Code, http.StatusOK)
+	}
+}
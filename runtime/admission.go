@@ -0,0 +1,138 @@
+package runtime
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AdmissionClass is a class of routes of WithAdmissionControl, which share a
+// bounded number of concurrent requests and a bounded queue.
+type AdmissionClass struct {
+	// Tag selects the routes of the class, see WithRouteTags. The class
+	// with an empty Tag applies to the routes of no other class.
+	Tag string
+	// MaxConcurrent is the maximum number of requests of the class served
+	// concurrently. The class is unlimited if it is not positive.
+	MaxConcurrent int
+	// MaxQueue is the maximum number of requests of the class waiting for
+	// one of the requests being served to complete.
+	MaxQueue int
+	// MaxWait is the maximum time a request of the class waits in the
+	// queue. Requests wait until their context is done if it is zero.
+	MaxWait time.Duration
+	// RetryAfter is the value of the Retry-After header of the requests
+	// shed. It defaults to 1 second.
+	RetryAfter time.Duration
+}
+
+// WithAdmissionControl returns a ServeMuxOption admitting the requests to
+// the routes of "classes" once they are authenticated and authorized: when
+// the maximum number of requests of its class are being served, a request
+// waits in the queue of the class until one of them completes. The requests
+// which find the queue full, or wait longer than its MaxWait, are shed with
+// codes.Unavailable and a Retry-After header, so that a slow backend cannot
+// exhaust the goroutines and file descriptors of the gateway. The first class
+// applying to a route is used.
+func WithAdmissionControl(classes ...AdmissionClass) ServeMuxOption {
+	return func(mux *ServeMux) {
+		for _, c := range classes {
+			if c.RetryAfter <= 0 {
+				c.RetryAfter = time.Second
+			}
+			a := &admissionController{class: c}
+			if c.MaxConcurrent > 0 {
+				a.slots = make(chan struct{}, c.MaxConcurrent)
+			}
+			mux.admission = append(mux.admission, a)
+		}
+	}
+}
+
+// admissionController admits the requests of a class.
+type admissionController struct {
+	class AdmissionClass
+	slots chan struct{}
+
+	mu      sync.Mutex
+	waiting int
+}
+
+// admissionFor returns the admission controller of the route h, or nil.
+func (s *ServeMux) admissionFor(h handler) *admissionController {
+	var fallback *admissionController
+	for _, a := range s.admission {
+		if a.class.Tag == "" {
+			if fallback == nil {
+				fallback = a
+			}
+			continue
+		}
+		if h.opts != nil {
+			for _, tag := range h.opts.tags {
+				if tag == a.class.Tag {
+					return a
+				}
+			}
+		}
+	}
+	return fallback
+}
+
+// admit waits for r to be admitted to the route h and returns a function
+// releasing its admission once served. If r is shed, it replies with
+// codes.Unavailable and returns false.
+func (s *ServeMux) admit(w http.ResponseWriter, r *http.Request, h handler) (release func(), ok bool) {
+	a := s.admissionFor(h)
+	if a == nil || a.slots == nil {
+		return func() {}, true
+	}
+	release = func() { <-a.slots }
+	select {
+	case a.slots <- struct{}{}:
+		return release, true
+	default:
+	}
+
+	a.mu.Lock()
+	if a.waiting >= a.class.MaxQueue {
+		a.mu.Unlock()
+		s.shed(w, r, a.class.RetryAfter)
+		return nil, false
+	}
+	a.waiting++
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		a.waiting--
+		a.mu.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if a.class.MaxWait > 0 {
+		timer := time.NewTimer(a.class.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case a.slots <- struct{}{}:
+		return release, true
+	case <-timeout:
+	case <-r.Context().Done():
+	}
+	s.shed(w, r, a.class.RetryAfter)
+	return nil, false
+}
+
+// shed replies to r, which was not admitted, with codes.Unavailable and a
+// Retry-After header of "retryAfter".
+func (s *ServeMux) shed(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	_, outboundMarshaler := MarshalerForRequest(s, r)
+	s.errorHandler(r.Context(), s, outboundMarshaler, w, r, status.Error(codes.Unavailable, "the server is overloaded"))
+}
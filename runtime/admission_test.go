@@ -0,0 +1,77 @@
+package runtime_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestAdmissionControl(t *testing.T) {
+	mux := runtime.NewServeMuxDynamic(runtime.WithAdmissionControl(
+		runtime.AdmissionClass{Tag: "unqueued", MaxConcurrent: 1, RetryAfter: 2 * time.Second},
+		runtime.AdmissionClass{Tag: "impatient", MaxConcurrent: 1, MaxQueue: 1, MaxWait: 20 * time.Millisecond},
+		runtime.AdmissionClass{Tag: "patient", MaxConcurrent: 1, MaxQueue: 1, MaxWait: time.Minute},
+		runtime.AdmissionClass{MaxConcurrent: 100},
+	))
+	started := make(chan string)
+	unblock := map[string]chan struct{}{
+		"/v1/unqueued":  make(chan struct{}),
+		"/v1/impatient": make(chan struct{}),
+		"/v1/patient":   make(chan struct{}),
+	}
+	blocking := func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		started <- r.URL.Path
+		<-unblock[r.URL.Path]
+	}
+	for _, tag := range []string{"unqueued", "impatient", "patient"} {
+		if err := mux.HandlePath("GET", "/v1/"+tag, blocking, runtime.WithRouteTags(tag)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mux.HandlePath("GET", "/v1/other", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	// Occupy the single slot of every class.
+	done := make(chan *httptest.ResponseRecorder)
+	for _, path := range []string{"/v1/unqueued", "/v1/impatient", "/v1/patient"} {
+		go func(path string) { done <- get(path) }(path)
+		<-started
+	}
+
+	if w := get("/v1/unqueued"); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("GET /v1/unqueued replied %d with Retry-After %q; want %d with 2", w.Code, w.Header().Get("Retry-After"), http.StatusServiceUnavailable)
+	}
+	if w := get("/v1/impatient"); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("GET /v1/impatient replied %d with Retry-After %q once its wait is over; want %d with 1", w.Code, w.Header().Get("Retry-After"), http.StatusServiceUnavailable)
+	}
+	if w := get("/v1/other"); w.Code != http.StatusOK {
+		t.Errorf("GET /v1/other replied %d; want %d, its class having free slots", w.Code, http.StatusOK)
+	}
+
+	// A patient request waits for the slot of its class.
+	go func() { done <- get("/v1/patient") }()
+	unblock["/v1/patient"] <- struct{}{}
+	if w := <-done; w.Code != http.StatusOK {
+		t.Errorf("GET /v1/patient replied %d; want %d", w.Code, http.StatusOK)
+	}
+	if path := <-started; path != "/v1/patient" {
+		t.Fatalf("%s started; want the queued /v1/patient", path)
+	}
+	for _, c := range unblock {
+		close(c)
+	}
+	for i := 0; i < 3; i++ {
+		if w := <-done; w.Code != http.StatusOK {
+			t.Errorf("GET replied %d; want %d", w.Code, http.StatusOK)
+		}
+	}
+}
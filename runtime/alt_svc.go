@@ -0,0 +1,40 @@
+package runtime
+
+import (
+	"net/http"
+)
+
+// WithAltSvc returns a ServeMuxOption advertising alternative services for
+// the gateway in the Alt-Svc header of the responses to requests not already
+// served over HTTP/3, e.g. `h3=":443"; ma=86400` when it is also served over
+// HTTP/3 on port 443, e.g. with ListenAndServeHTTP3, so that browsers switch
+// to it.
+func WithAltSvc(altSvc string) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.altSvc = altSvc
+	}
+}
+
+// advertiseAltSvc sets the Alt-Svc header of the response to "r".
+func (s *ServeMux) advertiseAltSvc(w http.ResponseWriter, r *http.Request) {
+	if s.altSvc != "" && r.ProtoMajor < 3 {
+		w.Header().Set("Alt-Svc", s.altSvc)
+	}
+}
+
+// flusherOf returns the http.Flusher of w, looking through the
+// ResponseWriters it wraps with an Unwrap method, as http.ResponseController
+// does, so that streams are flushed through middleware wrapping the
+// ResponseWriters of HTTP/1, HTTP/2 and HTTP/3 servers alike.
+func flusherOf(w http.ResponseWriter) (http.Flusher, bool) {
+	for {
+		if f, ok := w.(http.Flusher); ok {
+			return f, true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil, false
+		}
+		w = u.Unwrap()
+	}
+}
@@ -0,0 +1,64 @@
+package runtime_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	pb "github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAltSvc(t *testing.T) {
+	mux := runtime.NewServeMux(runtime.WithAltSvc(`h3=":443"; ma=86400`))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/unknown", nil))
+	if got, want := w.Header().Get("Alt-Svc"), `h3=":443"; ma=86400`; got != want {
+		t.Errorf("Alt-Svc = %q; want %q", got, want)
+	}
+
+	r := httptest.NewRequest("GET", "/v1/unknown", nil)
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/3.0", 3, 0
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if got := w.Header().Get("Alt-Svc"); got != "" {
+		t.Errorf("Alt-Svc = %q; want none over HTTP/3", got)
+	}
+}
+
+// unwrappingResponseWriter wraps a ResponseWriter without implementing
+// http.Flusher, like middleware relying on http.ResponseController.
+type unwrappingResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w unwrappingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestForwardResponseStreamUnwrapsFlusher(t *testing.T) {
+	msgs := []proto.Message{&pb.SimpleMessage{Id: "One"}, &pb.SimpleMessage{Id: "Two"}}
+	recv := func() (proto.Message, error) {
+		if len(msgs) == 0 {
+			return nil, io.EOF
+		}
+		msg := msgs[0]
+		msgs = msgs[1:]
+		return msg, nil
+	}
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	rec := httptest.NewRecorder()
+	marshaler := &runtime.JSONPb{}
+	runtime.ForwardResponseStream(ctx, runtime.NewServeMux(), marshaler, unwrappingResponseWriter{rec}, httptest.NewRequest("GET", "/", nil), recv)
+
+	if rec.Code != http.StatusOK || !rec.Flushed {
+		t.Errorf("response = %d, flushed %v; want %d flushed through Unwrap", rec.Code, rec.Flushed, http.StatusOK)
+	}
+	if got, want := rec.Body.String(), "{\"result\":{\"id\":\"One\"}}\n{\"result\":{\"id\":\"Two\"}}\n"; got != want {
+		t.Errorf("body = %q; want %q", got, want)
+	}
+}
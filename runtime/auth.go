@@ -0,0 +1,84 @@
+package runtime
+
+import (
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// authenticator authenticates the caller of a request before it is dispatched
+// to the handler of its route. It returns the request, with the identity of
+// the caller in its context, or an error with a gRPC status, which is replied
+// with the error handler of the mux.
+type authenticator func(w http.ResponseWriter, r *http.Request) (*http.Request, error)
+
+// authenticate drops the headers forging the metadata forwarded by the
+// gateway, resolves the IP address of the client of r and runs the
+// authenticators of s, and then those of the route h r was dispatched to, on
+// r. If one of them fails, it emits a security event, replies with its error
+// and returns false.
+func (s *ServeMux) authenticate(w http.ResponseWriter, r *http.Request, h handler) (*http.Request, bool) {
+	r = s.dropForgedMetadata(r, h)
+	if s.clientIP != nil {
+		r = s.clientIP.withClientIP(r)
+	}
+	r, ok := s.runAuthenticators(w, r, h, s.authenticators)
+	if !ok {
+		return nil, false
+	}
+	if h.opts != nil {
+		return s.runAuthenticators(w, r, h, h.opts.authenticators)
+	}
+	return r, true
+}
+
+func (s *ServeMux) runAuthenticators(w http.ResponseWriter, r *http.Request, h handler, authenticators []authenticator) (*http.Request, bool) {
+	for _, a := range authenticators {
+		authenticated, err := a(w, r)
+		if err != nil {
+			s.emitSecurityEvent(r, authenticationEventType(err), h.routeInfo(r.Method), err)
+			_, outboundMarshaler := MarshalerForRequest(s, r)
+			s.errorHandler(r.Context(), s, outboundMarshaler, w, r, err)
+			return nil, false
+		}
+		r = authenticated
+	}
+	return r, true
+}
+
+// dropForgedMetadata returns r without the headers which the incoming header
+// matcher maps onto the metadata forwarded by the gateway itself, e.g. the
+// claims of WithJWTAuth, so that clients cannot forge it with headers such
+// as Grpc-Metadata-X-User-Id. The metadata forwarded for the route h is
+// dropped too.
+func (s *ServeMux) dropForgedMetadata(r *http.Request, h handler) *http.Request {
+	keys := s.gatewayMetadata
+	if h.opts != nil && len(h.opts.gatewayMetadata) > 0 {
+		keys = append(keys[:len(keys):len(keys)], h.opts.gatewayMetadata...)
+	}
+	if len(keys) == 0 {
+		return r
+	}
+	matcher := s.incomingHeaderMatcherFor(r.Context())
+	var forged []string
+	for key := range r.Header {
+		h, ok := matcher(textproto.CanonicalMIMEHeaderKey(key))
+		if !ok {
+			continue
+		}
+		for _, k := range keys {
+			if strings.EqualFold(h, k) {
+				forged = append(forged, key)
+				break
+			}
+		}
+	}
+	if len(forged) == 0 {
+		return r
+	}
+	r = r.Clone(r.Context())
+	for _, key := range forged {
+		delete(r.Header, key)
+	}
+	return r
+}
@@ -0,0 +1,213 @@
+package runtime
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultAPIKeyHeader            = "X-API-Key"
+	defaultAPIKeyPrincipalMetadata = "x-api-key-principal"
+	// maxAPIKeyCacheEntries bounds the number of cached API keys.
+	maxAPIKeyCacheEntries = 10000
+)
+
+// APIKeyValidator resolves API keys into the principals they identify.
+type APIKeyValidator interface {
+	// ValidateAPIKey returns the principal identified by key. Its error is
+	// replied with the error handler; errors without a gRPC status are
+	// replied as codes.Unauthenticated. A key which identifies a principal
+	// which is not allowed to call the gateway should be rejected with
+	// codes.PermissionDenied.
+	ValidateAPIKey(ctx context.Context, key string) (principal string, err error)
+}
+
+// APIKeyValidatorFunc is an adapter to use ordinary functions as APIKeyValidators.
+type APIKeyValidatorFunc func(ctx context.Context, key string) (string, error)
+
+// ValidateAPIKey returns f(ctx, key).
+func (f APIKeyValidatorFunc) ValidateAPIKey(ctx context.Context, key string) (string, error) {
+	return f(ctx, key)
+}
+
+// APIKeyConfig configures the authentication of requests with API keys, see
+// WithAPIKeyAuth.
+type APIKeyConfig struct {
+	// Header is the request header carrying the API key. It defaults to
+	// "X-API-Key" if QueryParam is empty.
+	Header string
+	// QueryParam is the query parameter carrying the API key, if the header
+	// is not set.
+	QueryParam string
+	// Validator resolves API keys into principals.
+	Validator APIKeyValidator
+	// CacheTTL is how long the principals of valid API keys are cached.
+	// Keys are validated on every request if it is zero.
+	CacheTTL time.Duration
+	// PrincipalMetadata is the key of the gRPC metadata the principal is
+	// forwarded in. It defaults to "x-api-key-principal". Request headers
+	// forwarded in this key are dropped, so that clients cannot forge it.
+	PrincipalMetadata string
+	// SkipTags are tags of routes which are served without an API key, see
+	// WithRouteTags.
+	SkipTags []string
+}
+
+// WithAPIKeyAuth returns a ServeMuxOption which requires requests to carry an
+// API key, validated with config.Validator. Requests without a key are
+// rejected with codes.Unauthenticated, requests with a key rejected by the
+// validator with the error of the validator, through the error handler.
+//
+// The principal identified by the key is forwarded as gRPC metadata and is
+// available to handlers with APIKeyPrincipalFromContext.
+func WithAPIKeyAuth(config APIKeyConfig) ServeMuxOption {
+	return func(mux *ServeMux) {
+		a := newAPIKeyAuth(config)
+		mux.authenticators = append(mux.authenticators, a.authenticate)
+		mux.metadataAnnotators = append(mux.metadataAnnotators, a.metadata)
+		mux.gatewayMetadata = append(mux.gatewayMetadata, a.config.PrincipalMetadata)
+	}
+}
+
+// WithRouteAPIKeyAuth returns a RouteOption which requires requests to this
+// route to carry an API key, in addition to the authentication configured
+// for the mux. See WithAPIKeyAuth.
+func WithRouteAPIKeyAuth(config APIKeyConfig) RouteOption {
+	return func(o *routeOptions) {
+		a := newAPIKeyAuth(config)
+		o.authenticators = append(o.authenticators, a.authenticate)
+		o.metadataAnnotators = append(o.metadataAnnotators, a.metadata)
+		o.gatewayMetadata = append(o.gatewayMetadata, a.config.PrincipalMetadata)
+	}
+}
+
+type apiKeyPrincipalKey struct{}
+
+// apiKeyPrincipal is the principal a request was authenticated as by auth.
+type apiKeyPrincipal struct {
+	principal string
+	auth      *apiKeyAuth
+}
+
+// APIKeyPrincipalFromContext returns the principal identified by the API key
+// the request was authenticated with by WithAPIKeyAuth or WithRouteAPIKeyAuth.
+func APIKeyPrincipalFromContext(ctx context.Context) (string, bool) {
+	p, ok := ctx.Value(apiKeyPrincipalKey{}).(apiKeyPrincipal)
+	return p.principal, ok
+}
+
+type apiKeyAuth struct {
+	config APIKeyConfig
+	cache  *apiKeyCache
+}
+
+func newAPIKeyAuth(config APIKeyConfig) *apiKeyAuth {
+	if config.Header == "" && config.QueryParam == "" {
+		config.Header = defaultAPIKeyHeader
+	}
+	if config.PrincipalMetadata == "" {
+		config.PrincipalMetadata = defaultAPIKeyPrincipalMetadata
+	}
+	a := &apiKeyAuth{config: config}
+	if config.CacheTTL > 0 {
+		a.cache = &apiKeyCache{ttl: config.CacheTTL, entries: make(map[string]apiKeyCacheEntry)}
+	}
+	return a
+}
+
+func (a *apiKeyAuth) authenticate(_ http.ResponseWriter, r *http.Request) (*http.Request, error) {
+	if routeHasTag(r.Context(), a.config.SkipTags) {
+		return r, nil
+	}
+	key := a.key(r)
+	if key == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing API key")
+	}
+	principal, ok := a.cache.get(key)
+	if !ok {
+		var err error
+		if principal, err = a.config.Validator.ValidateAPIKey(r.Context(), key); err != nil {
+			if _, ok := status.FromError(err); ok {
+				return nil, err
+			}
+			return nil, status.Errorf(codes.Unauthenticated, "invalid API key: %v", err)
+		}
+		a.cache.put(key, principal)
+	}
+	return r.WithContext(context.WithValue(r.Context(), apiKeyPrincipalKey{}, apiKeyPrincipal{principal: principal, auth: a})), nil
+}
+
+// key returns the API key of r.
+func (a *apiKeyAuth) key(r *http.Request) string {
+	if a.config.Header != "" {
+		if key := r.Header.Get(a.config.Header); key != "" {
+			return key
+		}
+	}
+	if a.config.QueryParam != "" {
+		return r.URL.Query().Get(a.config.QueryParam)
+	}
+	return ""
+}
+
+// metadata forwards the principal of the request if it was authenticated by a.
+func (a *apiKeyAuth) metadata(_ context.Context, req *http.Request) metadata.MD {
+	p, ok := req.Context().Value(apiKeyPrincipalKey{}).(apiKeyPrincipal)
+	if !ok || p.auth != a {
+		return nil
+	}
+	return metadata.Pairs(a.config.PrincipalMetadata, p.principal)
+}
+
+// apiKeyCache caches the principals of valid API keys. A nil cache caches
+// nothing.
+type apiKeyCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]apiKeyCacheEntry
+}
+
+type apiKeyCacheEntry struct {
+	principal string
+	expires   time.Time
+}
+
+func (c *apiKeyCache) get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return "", false
+	}
+	return e.principal, true
+}
+
+func (c *apiKeyCache) put(key, principal string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxAPIKeyCacheEntries {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxAPIKeyCacheEntries {
+			c.entries = make(map[string]apiKeyCacheEntry)
+		}
+	}
+	c.entries[key] = apiKeyCacheEntry{principal: principal, expires: now.Add(c.ttl)}
+}
@@ -0,0 +1,200 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	var validations int
+	validator := runtime.APIKeyValidatorFunc(func(ctx context.Context, key string) (string, error) {
+		validations++
+		switch key {
+		case "key-1":
+			return "service-1", nil
+		case "revoked":
+			return "", status.Error(codes.PermissionDenied, "revoked API key")
+		}
+		return "", errors.New("unknown API key")
+	})
+	mux := runtime.NewServeMuxDynamic(runtime.WithAPIKeyAuth(runtime.APIKeyConfig{
+		Header:     "X-API-Key",
+		QueryParam: "api_key",
+		Validator:  validator,
+		CacheTTL:   time.Minute,
+		SkipTags:   []string{"public"},
+	}))
+	var principal string
+	var md metadata.MD
+	handler := func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		principal, _ = runtime.APIKeyPrincipalFromContext(r.Context())
+		ctx, err := runtime.AnnotateContext(r.Context(), mux.ServeMux, r, "/example.Example/Get")
+		if err != nil {
+			t.Fatalf("runtime.AnnotateContext(...) failed with %v; want success", err)
+		}
+		md, _ = metadata.FromOutgoingContext(ctx)
+	}
+	mux.Handle("GET", runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"private"}, "")), handler)
+	mux.Handle("GET", runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"public"}, "")), handler, runtime.WithRouteTags("public"))
+
+	for _, spec := range []struct {
+		name      string
+		url       string
+		header    string
+		status    int
+		principal string
+	}{
+		{
+			name:      "header",
+			url:       "/private",
+			header:    "key-1",
+			status:    http.StatusOK,
+			principal: "service-1",
+		},
+		{
+			name:      "query parameter",
+			url:       "/private?api_key=key-1",
+			status:    http.StatusOK,
+			principal: "service-1",
+		},
+		{
+			name:   "missing key",
+			url:    "/private",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "unknown key",
+			url:    "/private",
+			header: "key-2",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "revoked key",
+			url:    "/private",
+			header: "revoked",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "skipped route",
+			url:    "/public",
+			status: http.StatusOK,
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			principal, md = "", nil
+			r := httptest.NewRequest("GET", spec.url, nil)
+			if spec.header != "" {
+				r.Header.Set("X-API-Key", spec.header)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != spec.status {
+				t.Fatalf("w.Code = %d; want %d, body: %s", w.Code, spec.status, w.Body)
+			}
+			if principal != spec.principal {
+				t.Errorf("runtime.APIKeyPrincipalFromContext(...) = %q; want %q", principal, spec.principal)
+			}
+			if spec.principal != "" {
+				if got := md.Get("x-api-key-principal"); len(got) != 1 || got[0] != spec.principal {
+					t.Errorf(`md.Get("x-api-key-principal") = %q; want [%q]`, got, spec.principal)
+				}
+			}
+		})
+	}
+
+	// key-1 is validated once and then served from the cache.
+	if want := 3; validations != want {
+		t.Errorf("validations = %d; want %d", validations, want)
+	}
+}
+
+func TestRouteAPIKeyAuth(t *testing.T) {
+	validator := runtime.APIKeyValidatorFunc(func(ctx context.Context, key string) (string, error) {
+		if key == "admin-key" {
+			return "admin", nil
+		}
+		return "", errors.New("unknown API key")
+	})
+	mux := runtime.NewServeMuxDynamic()
+	handler := func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {}
+	mux.Handle("GET", runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"open"}, "")), handler)
+	mux.Handle("GET", runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"admin"}, "")), handler,
+		runtime.WithRouteAPIKeyAuth(runtime.APIKeyConfig{Header: "X-Admin-Key", Validator: validator}))
+
+	for _, spec := range []struct {
+		path   string
+		key    string
+		status int
+	}{
+		{path: "/open", status: http.StatusOK},
+		{path: "/admin", status: http.StatusUnauthorized},
+		{path: "/admin", key: "other-key", status: http.StatusUnauthorized},
+		{path: "/admin", key: "admin-key", status: http.StatusOK},
+	} {
+		r := httptest.NewRequest("GET", spec.path, nil)
+		if spec.key != "" {
+			r.Header.Set("X-Admin-Key", spec.key)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != spec.status {
+			t.Errorf("GET %s with key %q: w.Code = %d; want %d", spec.path, spec.key, w.Code, spec.status)
+		}
+	}
+}
+
+func TestAPIKeyAuthForgedMetadata(t *testing.T) {
+	validator := runtime.APIKeyValidatorFunc(func(ctx context.Context, key string) (string, error) {
+		if key == "key-1" {
+			return "service-1", nil
+		}
+		return "", errors.New("unknown API key")
+	})
+	mux := runtime.NewServeMuxDynamic(runtime.WithAPIKeyAuth(runtime.APIKeyConfig{
+		Header:    "X-API-Key",
+		Validator: validator,
+		SkipTags:  []string{"public"},
+	}))
+	var md metadata.MD
+	handler := func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		ctx, err := runtime.AnnotateContext(r.Context(), mux.ServeMux, r, "/example.Example/Get")
+		if err != nil {
+			t.Fatalf("runtime.AnnotateContext(...) failed with %v; want success", err)
+		}
+		md, _ = metadata.FromOutgoingContext(ctx)
+	}
+	mux.Handle("GET", runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"private"}, "")), handler)
+	mux.Handle("GET", runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"public"}, "")), handler, runtime.WithRouteTags("public"))
+	mux.Handle("GET", runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"admin"}, "")), handler,
+		runtime.WithRouteTags("public"),
+		runtime.WithRouteAPIKeyAuth(runtime.APIKeyConfig{Header: "X-API-Key", Validator: validator, PrincipalMetadata: "x-admin"}))
+
+	for _, spec := range []struct {
+		path string
+		key  string
+		want []string
+	}{
+		{path: "/private", key: "x-api-key-principal", want: []string{"service-1"}},
+		{path: "/public", key: "x-api-key-principal"},
+		{path: "/admin", key: "x-admin", want: []string{"service-1"}},
+	} {
+		md = nil
+		r := httptest.NewRequest("GET", spec.path, nil)
+		r.Header.Set("X-API-Key", "key-1")
+		r.Header.Set("Grpc-Metadata-"+spec.key, "admin")
+		mux.ServeHTTP(httptest.NewRecorder(), r)
+		if got := md.Get(spec.key); !reflect.DeepEqual(got, spec.want) {
+			t.Errorf("GET %s: %s = %q; want %q", spec.path, spec.key, got, spec.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package runtime
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultHMACSignatureHeader = "X-Signature"
+	defaultHMACMaxClockSkew    = 5 * time.Minute
+	defaultHMACMaxBodySize     = 10 << 20
+)
+
+// HMACConfig configures the verification of HMAC request signatures, see
+// WithRouteHMACAuth.
+//
+// The signature is computed over the signed components of a request joined by
+// newlines. Components are "method", "path", "query" (the raw query string),
+// "timestamp", "body" and "header:<name>" for the value of a header. E.g. the
+// signatures of GitHub webhooks are verified with
+//
+//	HMACConfig{
+//		Key:              secret,
+//		SignatureHeader:  "X-Hub-Signature-256",
+//		SignaturePrefix:  "sha256=",
+//		SignedComponents: []string{"body"},
+//	}
+type HMACConfig struct {
+	// Key is the shared secret of the signatures.
+	Key []byte
+	// Hash is the hash function of the HMAC. It defaults to SHA-256.
+	Hash func() hash.Hash
+	// SignatureHeader is the header carrying the hex or base64 encoded
+	// signature. It defaults to "X-Signature".
+	SignatureHeader string
+	// SignaturePrefix is a prefix of the signature header, e.g. "sha256=".
+	SignaturePrefix string
+	// TimestampHeader is the header carrying the time a request was signed
+	// at, in seconds since the Unix epoch. Timestamps are not checked if it
+	// is empty.
+	TimestampHeader string
+	// MaxClockSkew is how far the timestamp of a request may be from the
+	// current time. It defaults to five minutes.
+	MaxClockSkew time.Duration
+	// SignedComponents are the signed components of requests. They default to
+	// "method", "path", "query" and "body", preceded by "timestamp" if
+	// TimestampHeader is set.
+	SignedComponents []string
+	// MaxBodySize is the size of the largest body which is verified. It
+	// defaults to 10 MiB.
+	MaxBodySize int64
+}
+
+// WithHMACAuth returns a ServeMuxOption which requires all requests to be
+// signed as configured by config, see WithRouteHMACAuth.
+func WithHMACAuth(config HMACConfig) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.authenticators = append(mux.authenticators, newHMACVerifier(config).authenticate)
+	}
+}
+
+// WithRouteHMACAuth returns a RouteOption which requires requests to this
+// route, e.g. a webhook, to be signed with an HMAC as configured by config.
+// The body of requests is read to verify their signature, so requests with
+// a missing or invalid signature are rejected with codes.Unauthenticated
+// through the error handler before anything is forwarded.
+func WithRouteHMACAuth(config HMACConfig) RouteOption {
+	return func(o *routeOptions) {
+		o.authenticators = append(o.authenticators, newHMACVerifier(config).authenticate)
+	}
+}
+
+type hmacVerifier struct {
+	config HMACConfig
+}
+
+func newHMACVerifier(config HMACConfig) *hmacVerifier {
+	if config.Hash == nil {
+		config.Hash = sha256.New
+	}
+	if config.SignatureHeader == "" {
+		config.SignatureHeader = defaultHMACSignatureHeader
+	}
+	if config.MaxClockSkew <= 0 {
+		config.MaxClockSkew = defaultHMACMaxClockSkew
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = defaultHMACMaxBodySize
+	}
+	if len(config.SignedComponents) == 0 {
+		if config.TimestampHeader != "" {
+			config.SignedComponents = append(config.SignedComponents, "timestamp")
+		}
+		config.SignedComponents = append(config.SignedComponents, "method", "path", "query", "body")
+	}
+	return &hmacVerifier{config: config}
+}
+
+func (v *hmacVerifier) authenticate(_ http.ResponseWriter, r *http.Request) (*http.Request, error) {
+	r, err := v.verify(r)
+	if err != nil {
+		return nil, &securityEventError{
+			typ: SecurityEventSignatureMismatch,
+			err: status.Errorf(codes.Unauthenticated, "invalid request signature: %v", err),
+		}
+	}
+	return r, nil
+}
+
+// verify verifies the signature of r and returns r with its body restored.
+func (v *hmacVerifier) verify(r *http.Request) (*http.Request, error) {
+	header := r.Header.Get(v.config.SignatureHeader)
+	if header == "" {
+		return nil, errors.New("missing signature")
+	}
+	if !strings.HasPrefix(header, v.config.SignaturePrefix) {
+		return nil, errors.New("malformed signature")
+	}
+	sig, err := decodeHMACSignature(header[len(v.config.SignaturePrefix):])
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+
+	if v.config.TimestampHeader != "" {
+		ts, err := strconv.ParseInt(r.Header.Get(v.config.TimestampHeader), 10, 64)
+		if err != nil {
+			return nil, errors.New("missing or malformed timestamp")
+		}
+		skew := time.Since(time.Unix(ts, 0))
+		if skew > v.config.MaxClockSkew || skew < -v.config.MaxClockSkew {
+			return nil, errors.New("timestamp is outside of the allowed clock skew")
+		}
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		body, err = ioutil.ReadAll(io.LimitReader(r.Body, v.config.MaxBodySize+1))
+		r.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read body: %v", err)
+		}
+		if int64(len(body)) > v.config.MaxBodySize {
+			return nil, fmt.Errorf("body exceeds %d bytes", v.config.MaxBodySize)
+		}
+		r = r.WithContext(r.Context())
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	mac := hmac.New(v.config.Hash, v.config.Key)
+	for i, component := range v.config.SignedComponents {
+		if i > 0 {
+			mac.Write([]byte{'\n'})
+		}
+		switch {
+		case component == "method":
+			io.WriteString(mac, r.Method)
+		case component == "path":
+			io.WriteString(mac, r.URL.EscapedPath())
+		case component == "query":
+			io.WriteString(mac, r.URL.RawQuery)
+		case component == "timestamp":
+			io.WriteString(mac, r.Header.Get(v.config.TimestampHeader))
+		case component == "body":
+			mac.Write(body)
+		case strings.HasPrefix(component, "header:"):
+			io.WriteString(mac, r.Header.Get(component[len("header:"):]))
+		default:
+			return nil, fmt.Errorf("unknown signed component %q", component)
+		}
+	}
+	if !hmac.Equal(mac.Sum(nil), sig) {
+		return nil, errors.New("signature mismatch")
+	}
+	return r, nil
+}
+
+// decodeHMACSignature decodes a hex or base64 encoded signature.
+func decodeHMACSignature(s string) ([]byte, error) {
+	if sig, err := hex.DecodeString(s); err == nil {
+		return sig, nil
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
@@ -0,0 +1,150 @@
+package runtime_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func hmacHex(key []byte, message string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestRouteHMACAuth(t *testing.T) {
+	key := []byte("secret")
+	mux := runtime.NewServeMuxDynamic()
+	var body string
+	handler := func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("ioutil.ReadAll(r.Body) failed with %v; want success", err)
+		}
+		body = string(b)
+	}
+	mux.Handle("POST", mustPattern(t, "/v1/hooks/payments"), handler, runtime.WithRouteHMACAuth(runtime.HMACConfig{
+		Key:             key,
+		TimestampHeader: "X-Signature-Timestamp",
+	}))
+	mux.Handle("POST", mustPattern(t, "/v1/hooks/github"), handler, runtime.WithRouteHMACAuth(runtime.HMACConfig{
+		Key:              key,
+		SignatureHeader:  "X-Hub-Signature-256",
+		SignaturePrefix:  "sha256=",
+		SignedComponents: []string{"body"},
+	}))
+	mux.Handle("POST", mustPattern(t, "/v1/open"), handler)
+
+	const payload = `{"amount": 42}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	for _, spec := range []struct {
+		name    string
+		path    string
+		body    string
+		headers map[string]string
+		status  int
+	}{
+		{
+			name: "valid",
+			path: "/v1/hooks/payments",
+			body: payload,
+			headers: map[string]string{
+				"X-Signature-Timestamp": now,
+				"X-Signature":           hmacHex(key, now+"\nPOST\n/v1/hooks/payments\n\n"+payload),
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "tampered body",
+			path: "/v1/hooks/payments",
+			body: `{"amount": 4200}`,
+			headers: map[string]string{
+				"X-Signature-Timestamp": now,
+				"X-Signature":           hmacHex(key, now+"\nPOST\n/v1/hooks/payments\n\n"+payload),
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "signed query",
+			path: "/v1/hooks/payments?attempt=2",
+			body: payload,
+			headers: map[string]string{
+				"X-Signature-Timestamp": now,
+				"X-Signature":           hmacHex(key, now+"\nPOST\n/v1/hooks/payments\nattempt=2\n"+payload),
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "tampered query",
+			path: "/v1/hooks/payments?attempt=3",
+			body: payload,
+			headers: map[string]string{
+				"X-Signature-Timestamp": now,
+				"X-Signature":           hmacHex(key, now+"\nPOST\n/v1/hooks/payments\nattempt=2\n"+payload),
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "stale timestamp",
+			path: "/v1/hooks/payments",
+			body: payload,
+			headers: map[string]string{
+				"X-Signature-Timestamp": stale,
+				"X-Signature":           hmacHex(key, stale+"\nPOST\n/v1/hooks/payments\n\n"+payload),
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "missing signature",
+			path:   "/v1/hooks/payments",
+			body:   payload,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:    "prefixed signature of the body",
+			path:    "/v1/hooks/github",
+			body:    payload,
+			headers: map[string]string{"X-Hub-Signature-256": "sha256=" + hmacHex(key, payload)},
+			status:  http.StatusOK,
+		},
+		{
+			name:    "signature with the wrong key",
+			path:    "/v1/hooks/github",
+			body:    payload,
+			headers: map[string]string{"X-Hub-Signature-256": "sha256=" + hmacHex([]byte("other"), payload)},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:   "unsigned route",
+			path:   "/v1/open",
+			body:   payload,
+			status: http.StatusOK,
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			body = ""
+			r := httptest.NewRequest("POST", spec.path, strings.NewReader(spec.body))
+			for k, v := range spec.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != spec.status {
+				t.Fatalf("w.Code = %d; want %d, body: %s", w.Code, spec.status, w.Body)
+			}
+			if spec.status == http.StatusOK && body != spec.body {
+				t.Errorf("handler read body %q; want %q", body, spec.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultIntrospectionCacheTTL = time.Minute
+	// maxIntrospectionCacheEntries bounds the number of cached introspection
+	// responses.
+	maxIntrospectionCacheEntries = 10000
+)
+
+// IntrospectionConfig configures the validation of opaque OAuth 2.0 access
+// tokens, see WithTokenIntrospection.
+type IntrospectionConfig struct {
+	// Endpoint is the URL of the token introspection endpoint.
+	Endpoint string
+	// ClientID and ClientSecret authenticate the gateway at the endpoint
+	// with HTTP Basic authentication, if ClientID is not empty.
+	ClientID     string
+	ClientSecret string
+	// HTTPClient calls the endpoint. It defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// CacheTTL is how long the introspection of an active token is cached,
+	// at most until the token expires. It defaults to one minute; a negative
+	// TTL disables caching.
+	CacheTTL time.Duration
+	// SubjectMetadata and ScopeMetadata are the keys of the gRPC metadata the
+	// subject and the scopes of tokens are forwarded in, if they are not empty.
+	// Request headers forwarded in these keys are dropped, so that clients
+	// cannot forge them.
+	SubjectMetadata string
+	ScopeMetadata   string
+	// SkipTags are tags of routes which are served without a token, see
+	// WithRouteTags.
+	SkipTags []string
+}
+
+// TokenIntrospection is the response of a token introspection endpoint for an
+// active token, as specified by RFC 7662.
+type TokenIntrospection struct {
+	// Scopes are the scopes granted to the token.
+	Scopes []string
+	// Subject is the "sub" member of the response.
+	Subject string
+	// ClientID is the "client_id" member of the response.
+	ClientID string
+	// Username is the "username" member of the response.
+	Username string
+	// Expiry is the time the token expires at, or the zero time.
+	Expiry time.Time
+	// Claims are all members of the response.
+	Claims map[string]interface{}
+}
+
+// HasScopes reports whether the token is granted all of scopes.
+func (ti *TokenIntrospection) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		found := false
+		for _, s := range ti.Scopes {
+			if s == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// WithTokenIntrospection returns a ServeMuxOption which requires requests to
+// carry an OAuth 2.0 access token in their "Authorization: Bearer" header,
+// which is validated by calling the token introspection endpoint of
+// config.Endpoint, as specified by RFC 7662.
+//
+// Requests without a token or with an inactive token are rejected with
+// codes.Unauthenticated, requests with a token which is not granted the
+// scopes required by the route, see WithRouteRequiredScopes, with
+// codes.PermissionDenied, through the error handler. The introspection of
+// the token is available to handlers with TokenIntrospectionFromContext.
+func WithTokenIntrospection(config IntrospectionConfig) ServeMuxOption {
+	return func(mux *ServeMux) {
+		if config.CacheTTL == 0 {
+			config.CacheTTL = defaultIntrospectionCacheTTL
+		}
+		if config.HTTPClient == nil {
+			config.HTTPClient = http.DefaultClient
+		}
+		ti := &tokenIntrospector{config: config, cache: make(map[string]introspectionCacheEntry)}
+		mux.authenticators = append(mux.authenticators, ti.authenticate)
+		if config.SubjectMetadata != "" || config.ScopeMetadata != "" {
+			mux.metadataAnnotators = append(mux.metadataAnnotators, ti.metadata)
+		}
+		for _, key := range []string{config.SubjectMetadata, config.ScopeMetadata} {
+			if key != "" {
+				mux.gatewayMetadata = append(mux.gatewayMetadata, key)
+			}
+		}
+	}
+}
+
+type tokenIntrospectionKey struct{}
+
+// TokenIntrospectionFromContext returns the introspection of the access token
+// the request was authenticated with by WithTokenIntrospection.
+func TokenIntrospectionFromContext(ctx context.Context) (*TokenIntrospection, bool) {
+	ti, ok := ctx.Value(tokenIntrospectionKey{}).(*TokenIntrospection)
+	return ti, ok
+}
+
+type tokenIntrospector struct {
+	config IntrospectionConfig
+
+	mu    sync.Mutex
+	cache map[string]introspectionCacheEntry
+}
+
+type introspectionCacheEntry struct {
+	introspection *TokenIntrospection
+	expires       time.Time
+}
+
+func (ti *tokenIntrospector) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, error) {
+	ctx := r.Context()
+	if routeHasTag(ctx, ti.config.SkipTags) {
+		return r, nil
+	}
+	token := bearerToken(r)
+	if token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	introspection, err := ti.introspect(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if introspection == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		return nil, status.Error(codes.Unauthenticated, "invalid token: token is not active")
+	}
+	if o := routeOptionsFromContext(ctx); o != nil && !introspection.HasScopes(o.requiredScopes...) {
+		scope := strings.Join(o.requiredScopes, " ")
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scope))
+		return nil, status.Errorf(codes.PermissionDenied, "token is not granted the scopes %q", scope)
+	}
+	return r.WithContext(context.WithValue(ctx, tokenIntrospectionKey{}, introspection)), nil
+}
+
+// introspect returns the introspection of token, or nil if it is not active.
+func (ti *tokenIntrospector) introspect(ctx context.Context, token string) (*TokenIntrospection, error) {
+	now := time.Now()
+	ti.mu.Lock()
+	e, ok := ti.cache[token]
+	ti.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.introspection, nil
+	}
+
+	introspection, err := ti.call(ctx, token)
+	if err != nil || introspection == nil || ti.config.CacheTTL < 0 {
+		return introspection, err
+	}
+	expires := now.Add(ti.config.CacheTTL)
+	if !introspection.Expiry.IsZero() && introspection.Expiry.Before(expires) {
+		expires = introspection.Expiry
+	}
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	if len(ti.cache) >= maxIntrospectionCacheEntries {
+		for token, e := range ti.cache {
+			if now.After(e.expires) {
+				delete(ti.cache, token)
+			}
+		}
+		if len(ti.cache) >= maxIntrospectionCacheEntries {
+			ti.cache = make(map[string]introspectionCacheEntry)
+		}
+	}
+	ti.cache[token] = introspectionCacheEntry{introspection: introspection, expires: expires}
+	return introspection, nil
+}
+
+// call calls the introspection endpoint for token.
+func (ti *tokenIntrospector) call(ctx context.Context, token string) (*TokenIntrospection, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequest(http.MethodPost, ti.config.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to introspect token: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if ti.config.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(ti.config.ClientID), url.QueryEscape(ti.config.ClientSecret))
+	}
+	resp, err := ti.config.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to introspect token: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, status.Errorf(codes.Unavailable, "failed to introspect token: %s", resp.Status)
+	}
+
+	d := json.NewDecoder(resp.Body)
+	d.UseNumber()
+	var claims map[string]interface{}
+	if err := d.Decode(&claims); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "malformed token introspection: %v", err)
+	}
+	if active, _ := claims["active"].(bool); !active {
+		return nil, nil
+	}
+	introspection := &TokenIntrospection{Claims: claims}
+	if scope, ok := claims["scope"].(string); ok {
+		introspection.Scopes = strings.Fields(scope)
+	}
+	introspection.Subject, _ = claims["sub"].(string)
+	introspection.ClientID, _ = claims["client_id"].(string)
+	introspection.Username, _ = claims["username"].(string)
+	if exp, ok := claims["exp"]; ok {
+		if introspection.Expiry, err = jwtTime(exp); err != nil {
+			return nil, status.Errorf(codes.Unavailable, "malformed token introspection: invalid exp: %v", err)
+		}
+	}
+	return introspection, nil
+}
+
+// metadata forwards the subject and the scopes of the token of req.
+func (ti *tokenIntrospector) metadata(_ context.Context, req *http.Request) metadata.MD {
+	introspection, ok := TokenIntrospectionFromContext(req.Context())
+	if !ok {
+		return nil
+	}
+	md := metadata.MD{}
+	if ti.config.SubjectMetadata != "" && introspection.Subject != "" {
+		md.Set(ti.config.SubjectMetadata, introspection.Subject)
+	}
+	if ti.config.ScopeMetadata != "" && len(introspection.Scopes) > 0 {
+		md.Set(ti.config.ScopeMetadata, introspection.Scopes...)
+	}
+	return md
+}
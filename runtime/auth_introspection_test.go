@@ -0,0 +1,123 @@
+package runtime_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTokenIntrospection(t *testing.T) {
+	calls := make(map[string]int)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, ok := r.BasicAuth(); !ok || id != "gateway" || secret != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		token := r.PostFormValue("token")
+		calls[token]++
+		resp := map[string]interface{}{"active": false}
+		switch token {
+		case "read-write":
+			resp = map[string]interface{}{
+				"active": true,
+				"scope":  "books.read books.write",
+				"sub":    "user-1",
+				"exp":    time.Now().Add(time.Hour).Unix(),
+			}
+		case "read-only":
+			resp = map[string]interface{}{
+				"active": true,
+				"scope":  "books.read",
+				"sub":    "user-2",
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer endpoint.Close()
+
+	mux := runtime.NewServeMuxDynamic(runtime.WithTokenIntrospection(runtime.IntrospectionConfig{
+		Endpoint:        endpoint.URL,
+		ClientID:        "gateway",
+		ClientSecret:    "secret",
+		SubjectMetadata: "x-subject",
+		ScopeMetadata:   "x-scopes",
+		SkipTags:        []string{"public"},
+	}))
+	var md metadata.MD
+	handler := func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		ctx, err := runtime.AnnotateContext(r.Context(), mux.ServeMux, r, "/example.Example/Books")
+		if err != nil {
+			t.Fatalf("runtime.AnnotateContext(...) failed with %v; want success", err)
+		}
+		md, _ = metadata.FromOutgoingContext(ctx)
+	}
+	books := mustPattern(t, "/v1/books")
+	mux.Handle("GET", books, handler, runtime.WithRouteRequiredScopes("books.read"))
+	mux.Handle("DELETE", books, handler, runtime.WithRouteRequiredScopes("books.write"))
+	mux.Handle("GET", mustPattern(t, "/v1/health"), handler, runtime.WithRouteTags("public"))
+
+	for _, spec := range []struct {
+		method string
+		path   string
+		token  string
+		status int
+		md     metadata.MD
+	}{
+		{
+			method: "GET",
+			path:   "/v1/books",
+			token:  "read-write",
+			status: http.StatusOK,
+			md:     metadata.MD{"x-subject": {"user-1"}, "x-scopes": {"books.read", "books.write"}},
+		},
+		{
+			method: "GET",
+			path:   "/v1/books",
+			token:  "read-write",
+			status: http.StatusOK,
+			md:     metadata.MD{"x-subject": {"user-1"}, "x-scopes": {"books.read", "books.write"}},
+		},
+		{method: "DELETE", path: "/v1/books", token: "read-write", status: http.StatusOK},
+		{method: "GET", path: "/v1/books", token: "read-only", status: http.StatusOK},
+		{method: "DELETE", path: "/v1/books", token: "read-only", status: http.StatusForbidden},
+		{method: "GET", path: "/v1/books", token: "revoked", status: http.StatusUnauthorized},
+		{method: "GET", path: "/v1/books", status: http.StatusUnauthorized},
+		{
+			method: "GET",
+			path:   "/v1/health",
+			status: http.StatusOK,
+			md:     metadata.MD{"x-subject": nil, "x-scopes": nil},
+		},
+	} {
+		md = nil
+		r := httptest.NewRequest(spec.method, spec.path, nil)
+		if spec.token != "" {
+			r.Header.Set("Authorization", "Bearer "+spec.token)
+		}
+		r.Header.Set("Grpc-Metadata-X-Subject", "admin")
+		r.Header.Set("Grpc-Metadata-X-Scopes", "books.admin")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != spec.status {
+			t.Errorf("%s %s with token %q: w.Code = %d; want %d, body: %s", spec.method, spec.path, spec.token, w.Code, spec.status, w.Body)
+			continue
+		}
+		for key, want := range spec.md {
+			if got := md.Get(key); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s %s with token %q: md.Get(%q) = %q; want %q", spec.method, spec.path, spec.token, key, got, want)
+			}
+		}
+	}
+
+	// Active tokens are introspected once, inactive ones on every request.
+	want := map[string]int{"read-write": 1, "read-only": 1, "revoked": 1}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v; want %v", calls, want)
+	}
+}
@@ -0,0 +1,509 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // Registers crypto.SHA256.
+	_ "crypto/sha512" // Registers crypto.SHA384 and crypto.SHA512.
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultJWKSCacheTTL = time.Hour
+	// jwksMinRefreshInterval rate limits the fetches of a key set caused by
+	// tokens signed with unknown keys.
+	jwksMinRefreshInterval = time.Minute
+	// jwksFetchTimeout bounds the fetches of a key set.
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// JWTConfig configures the validation of JSON Web Tokens, see WithJWTAuth.
+type JWTConfig struct {
+	// JWKSURL is the URL of the JSON Web Key Set with the public keys tokens
+	// are signed with.
+	JWKSURL string
+	// JWKSCacheTTL is how long a fetched key set is used before it is fetched
+	// again. It defaults to one hour. The key set is also fetched again, at
+	// most once a minute, when a token is signed with an unknown key.
+	JWKSCacheTTL time.Duration
+	// HTTPClient fetches the key set. It defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Issuer is the "iss" claim tokens must have, if it is not empty.
+	Issuer string
+	// Audience must be in the "aud" claim of tokens, if it is not empty.
+	Audience string
+	// Leeway is the clock skew tolerated when checking the "exp" and "nbf"
+	// claims.
+	Leeway time.Duration
+	// ClaimMetadata maps names of claims to the keys of the gRPC metadata
+	// their values are forwarded in, e.g. {"sub": "x-user-id"}. Request
+	// headers forwarded in these keys, e.g. Grpc-Metadata-X-User-Id, are
+	// dropped, so that clients cannot forge them.
+	ClaimMetadata map[string]string
+	// SkipTags are tags of routes which are served without a token, see
+	// WithRouteTags.
+	SkipTags []string
+}
+
+// WithJWTAuth returns a ServeMuxOption which requires requests to carry a
+// JSON Web Token signed with one of the keys of config.JWKSURL in their
+// "Authorization: Bearer" header. Tokens signed with RS256, RS384, RS512,
+// ES256, ES384 or ES512 are accepted.
+//
+// Requests with a missing or invalid token are rejected with
+// codes.Unauthenticated through the error handler. The claims of valid
+// tokens are available to handlers with JWTClaimsFromContext.
+func WithJWTAuth(config JWTConfig) ServeMuxOption {
+	return func(mux *ServeMux) {
+		a := newJWTAuth(config)
+		mux.authenticators = append(mux.authenticators, a.authenticate)
+		if len(config.ClaimMetadata) > 0 {
+			mux.metadataAnnotators = append(mux.metadataAnnotators, a.metadata)
+			for _, key := range config.ClaimMetadata {
+				mux.gatewayMetadata = append(mux.gatewayMetadata, key)
+			}
+		}
+	}
+}
+
+type jwtClaimsKey struct{}
+
+// JWTClaimsFromContext returns the claims of the token the request was
+// authenticated with by WithJWTAuth.
+func JWTClaimsFromContext(ctx context.Context) (map[string]interface{}, bool) {
+	claims, ok := ctx.Value(jwtClaimsKey{}).(map[string]interface{})
+	return claims, ok
+}
+
+type jwtAuth struct {
+	config JWTConfig
+	keys   *jwksCache
+}
+
+func newJWTAuth(config JWTConfig) *jwtAuth {
+	ttl := config.JWKSCacheTTL
+	if ttl <= 0 {
+		ttl = defaultJWKSCacheTTL
+	}
+	client := config.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &jwtAuth{
+		config: config,
+		keys:   &jwksCache{url: config.JWKSURL, client: client, ttl: ttl},
+	}
+}
+
+func (a *jwtAuth) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, error) {
+	if routeHasTag(r.Context(), a.config.SkipTags) {
+		return r, nil
+	}
+	token := bearerToken(r)
+	if token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	claims, err := a.verify(r.Context(), token)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+	}
+	return r.WithContext(context.WithValue(r.Context(), jwtClaimsKey{}, claims)), nil
+}
+
+// bearerToken returns the bearer token in the Authorization header of r.
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < len("Bearer ") || !strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(auth[len("Bearer "):])
+}
+
+// verify checks the signature and the claims of token and returns its claims.
+func (a *jwtAuth) verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %v", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %v", err)
+	}
+	key, err := a.keys.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWTSignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %v", err)
+	}
+	if err := a.checkClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (a *jwtAuth) checkClaims(claims map[string]interface{}, now time.Time) error {
+	if exp, ok := claims["exp"]; ok {
+		t, err := jwtTime(exp)
+		if err != nil {
+			return fmt.Errorf("invalid exp claim: %v", err)
+		}
+		if now.After(t.Add(a.config.Leeway)) {
+			return errors.New("token is expired")
+		}
+	}
+	if nbf, ok := claims["nbf"]; ok {
+		t, err := jwtTime(nbf)
+		if err != nil {
+			return fmt.Errorf("invalid nbf claim: %v", err)
+		}
+		if now.Before(t.Add(-a.config.Leeway)) {
+			return errors.New("token is not valid yet")
+		}
+	}
+	if a.config.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != a.config.Issuer {
+			return fmt.Errorf("unexpected issuer %q", iss)
+		}
+	}
+	if a.config.Audience != "" && !jwtHasAudience(claims["aud"], a.config.Audience) {
+		return fmt.Errorf("token is not issued for %q", a.config.Audience)
+	}
+	return nil
+}
+
+// metadata forwards the claims of the token of req as configured by
+// JWTConfig.ClaimMetadata.
+func (a *jwtAuth) metadata(_ context.Context, req *http.Request) metadata.MD {
+	claims, ok := JWTClaimsFromContext(req.Context())
+	if !ok {
+		return nil
+	}
+	md := metadata.MD{}
+	for claim, key := range a.config.ClaimMetadata {
+		if v, ok := claims[claim]; ok {
+			md.Append(key, jwtClaimValues(v)...)
+		}
+	}
+	return md
+}
+
+// jwtClaimValues returns the metadata values of the claim v.
+func jwtClaimValues(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case json.Number:
+		return []string{v.String()}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			values = append(values, jwtClaimValues(item)...)
+		}
+		return values
+	case nil:
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return []string{string(b)}
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// jwtTime converts v, a NumericDate claim, into a time.
+func jwtTime(v interface{}) (time.Time, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%v is not a number", v)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	seconds := int64(f)
+	return time.Unix(seconds, int64((f-float64(seconds))*1e9)), nil
+}
+
+// jwtHasAudience reports whether the "aud" claim aud, a string or an array of
+// strings, contains audience.
+func jwtHasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verifyJWTSignature verifies the signature sig of signed with key and the
+// signing algorithm alg.
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			break
+		}
+		if err := rsa.VerifyPKCS1v15(key, hash, digest, sig); err != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") || key.Curve != jwtCurves[alg] {
+			break
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("key does not support signing algorithm %q", alg)
+}
+
+var jwtCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+// jwksCache caches the keys of a JSON Web Key Set by key ID.
+type jwksCache struct {
+	url    string
+	client *http.Client
+	ttl    time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetched   time.Time
+	attempted time.Time
+	// refreshed is closed once the fetch in flight, if any, completes, and
+	// err is the error of the last fetch.
+	refreshed chan struct{}
+	err       error
+}
+
+// key returns the key with the ID kid, fetching the key set if it is not
+// cached or expired. The key set is fetched once for all the concurrent
+// requests, without holding the lock, and independently of their contexts so
+// that a cancelled request does not fail the fetch for the others. Requests
+// for a cached key do not wait for the fetch of an expired key set.
+func (c *jwksCache) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	now := time.Now()
+	key, known := c.keys[kid]
+	stale := c.keys == nil || now.Sub(c.fetched) > c.ttl
+	if (!stale && known) || (c.refreshed == nil && now.Sub(c.attempted) < jwksMinRefreshInterval) {
+		defer c.mu.Unlock()
+		return c.lookup(kid)
+	}
+	refreshed := c.refreshed
+	if refreshed == nil {
+		refreshed = make(chan struct{})
+		c.refreshed, c.attempted = refreshed, now
+		go c.refresh(refreshed)
+	}
+	c.mu.Unlock()
+	if known {
+		return key, nil
+	}
+
+	select {
+	case <-refreshed:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lookup(kid)
+}
+
+// lookup returns the cached key with the ID kid. c.mu must be held.
+func (c *jwksCache) lookup(kid string) (crypto.PublicKey, error) {
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+	if c.keys == nil && c.err != nil {
+		return nil, c.err
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// refresh fetches the key set, and closes refreshed once it is cached.
+func (c *jwksCache) refresh(refreshed chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+	keys, err := c.fetch(ctx)
+
+	c.mu.Lock()
+	defer close(refreshed)
+	defer c.mu.Unlock()
+	c.refreshed, c.err = nil, err
+	switch {
+	case err == nil:
+		c.keys, c.fetched = keys, time.Now()
+	case c.keys != nil:
+		grpclog.Infof("Failed to refresh the JSON Web Key Set, using cached keys: %v", err)
+	}
+}
+
+func (c *jwksCache) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequest(http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the JSON Web Key Set: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch the JSON Web Key Set: %s", resp.Status)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("malformed JSON Web Key Set: %v", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			grpclog.Infof("Ignoring key %q of the JSON Web Key Set: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+// jsonWebKey is a public key of a JSON Web Key Set, as specified by RFC 7517.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+	// Elliptic curve keys.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("unsupported exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("invalid point")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
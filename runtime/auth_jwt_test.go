@@ -0,0 +1,335 @@
+package runtime_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+type jwtSigner struct {
+	kid string
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+// paddedBytes returns the big-endian bytes of n, left-padded to size.
+func paddedBytes(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	return append(make([]byte, size-len(b)), b...)
+}
+
+func (s jwtSigner) jwk() map[string]string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	if s.rsa != nil {
+		return map[string]string{
+			"kty": "RSA",
+			"kid": s.kid,
+			"n":   b64(s.rsa.N.Bytes()),
+			"e":   b64(big.NewInt(int64(s.rsa.E)).Bytes()),
+		}
+	}
+	return map[string]string{
+		"kty": "EC",
+		"kid": s.kid,
+		"crv": "P-256",
+		"x":   b64(paddedBytes(s.ec.X, 32)),
+		"y":   b64(paddedBytes(s.ec.Y, 32)),
+	}
+}
+
+func (s jwtSigner) sign(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	alg := "RS256"
+	if s.ec != nil {
+		alg = "ES256"
+	}
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": s.kid, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	if s.rsa != nil {
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, s.rsa, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		r, ss, err := ecdsa.Sign(rand.Reader, s.ec, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = append(paddedBytes(r, 32), paddedBytes(ss, 32)...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTAuth(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSigner := jwtSigner{kid: "rsa", rsa: rsaKey}
+	ecSigner := jwtSigner{kid: "ec", ec: ecKey}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgedSigner := jwtSigner{kid: "rsa", rsa: otherKey}
+
+	var fetches int32
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []interface{}{rsaSigner.jwk(), ecSigner.jwk()},
+		})
+	}))
+	defer jwks.Close()
+
+	mux := runtime.NewServeMuxDynamic(runtime.WithJWTAuth(runtime.JWTConfig{
+		JWKSURL:       jwks.URL,
+		Issuer:        "https://issuer.example.com",
+		Audience:      "gateway",
+		ClaimMetadata: map[string]string{"sub": "x-user-id", "roles": "x-roles"},
+		SkipTags:      []string{"public"},
+	}))
+	var md metadata.MD
+	handler := func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		ctx, err := runtime.AnnotateContext(r.Context(), mux.ServeMux, r, "/example.Example/Get")
+		if err != nil {
+			t.Fatalf("runtime.AnnotateContext(...) failed with %v; want success", err)
+		}
+		md, _ = metadata.FromOutgoingContext(ctx)
+	}
+	mux.Handle("GET", runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"private"}, "")), handler)
+	mux.Handle("GET", runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"public"}, "")), handler, runtime.WithRouteTags("public"))
+
+	now := time.Now().Unix()
+	valid := map[string]interface{}{
+		"iss":   "https://issuer.example.com",
+		"aud":   []string{"gateway", "other"},
+		"sub":   "user-1",
+		"roles": []string{"admin", "reader"},
+		"exp":   now + 60,
+		"nbf":   now - 60,
+	}
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := make(map[string]interface{})
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[key] = value
+		return claims
+	}
+
+	for _, spec := range []struct {
+		name   string
+		path   string
+		auth   string
+		status int
+		md     metadata.MD
+	}{
+		{
+			name:   "RS256",
+			path:   "/private",
+			auth:   "Bearer " + rsaSigner.sign(t, valid),
+			status: http.StatusOK,
+			md:     metadata.MD{"x-user-id": {"user-1"}, "x-roles": {"admin", "reader"}},
+		},
+		{
+			name:   "ES256",
+			path:   "/private",
+			auth:   "bearer " + ecSigner.sign(t, valid),
+			status: http.StatusOK,
+			md:     metadata.MD{"x-user-id": {"user-1"}, "x-roles": {"admin", "reader"}},
+		},
+		{
+			name:   "missing token",
+			path:   "/private",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "forged signature",
+			path:   "/private",
+			auth:   "Bearer " + forgedSigner.sign(t, valid),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "unknown key",
+			path:   "/private",
+			auth:   "Bearer " + jwtSigner{kid: "unknown", rsa: otherKey}.sign(t, valid),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "expired",
+			path:   "/private",
+			auth:   "Bearer " + rsaSigner.sign(t, with("exp", now-60)),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "not valid yet",
+			path:   "/private",
+			auth:   "Bearer " + rsaSigner.sign(t, with("nbf", now+60)),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "wrong issuer",
+			path:   "/private",
+			auth:   "Bearer " + rsaSigner.sign(t, with("iss", "https://evil.example.com")),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "wrong audience",
+			path:   "/private",
+			auth:   "Bearer " + rsaSigner.sign(t, with("aud", "other")),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "skipped route",
+			path:   "/public",
+			status: http.StatusOK,
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			md = nil
+			r := httptest.NewRequest("GET", spec.path, nil)
+			if spec.auth != "" {
+				r.Header.Set("Authorization", spec.auth)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != spec.status {
+				t.Fatalf("w.Code = %d; want %d, body: %s", w.Code, spec.status, w.Body)
+			}
+			if spec.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("WWW-Authenticate header is missing")
+			}
+			for key, want := range spec.md {
+				if got := md.Get(key); !reflect.DeepEqual(got, want) {
+					t.Errorf("md.Get(%q) = %q; want %q", key, got, want)
+				}
+			}
+		})
+	}
+
+	// Unknown keys do not refetch the key set within a minute of a fetch.
+	if got := atomic.LoadInt32(&fetches); got != 1 {
+		t.Errorf("fetches = %d; want 1", got)
+	}
+}
+
+func TestJWTAuthSlowJWKS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := jwtSigner{kid: "ec", ec: key}
+	release := make(chan struct{})
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []interface{}{signer.jwk()}})
+	}))
+	defer jwks.Close()
+
+	mux := runtime.NewServeMuxDynamic(runtime.WithJWTAuth(runtime.JWTConfig{JWKSURL: jwks.URL}))
+	mux.Handle("GET", runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"private"}, "")),
+		func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {})
+	token := signer.sign(t, map[string]interface{}{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()})
+	serve := func(ctx context.Context) int {
+		r := httptest.NewRequest("GET", "/private", nil).WithContext(ctx)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// A request cancelled while the key set is fetched does not fail the
+	// fetch for the requests waiting for it.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if code := serve(ctx); code == http.StatusOK {
+		t.Errorf("cancelled request: code = %d; want failure", code)
+	}
+	waiting := make(chan int)
+	go func() { waiting <- serve(context.Background()) }()
+	close(release)
+	if code := <-waiting; code != http.StatusOK {
+		t.Errorf("waiting request: code = %d; want %d", code, http.StatusOK)
+	}
+	if code := serve(context.Background()); code != http.StatusOK {
+		t.Errorf("cached request: code = %d; want %d", code, http.StatusOK)
+	}
+}
+
+func TestJWTAuthForgedMetadata(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := jwtSigner{kid: "ec", ec: key}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []interface{}{signer.jwk()}})
+	}))
+	defer jwks.Close()
+
+	mux := runtime.NewServeMuxDynamic(runtime.WithJWTAuth(runtime.JWTConfig{
+		JWKSURL:       jwks.URL,
+		ClaimMetadata: map[string]string{"sub": "x-user-id"},
+		SkipTags:      []string{"public"},
+	}))
+	var md metadata.MD
+	handler := func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		ctx, err := runtime.AnnotateContext(r.Context(), mux.ServeMux, r, "/example.Example/Get")
+		if err != nil {
+			t.Fatalf("runtime.AnnotateContext(...) failed with %v; want success", err)
+		}
+		md, _ = metadata.FromOutgoingContext(ctx)
+	}
+	mux.Handle("GET", runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"private"}, "")), handler)
+	mux.Handle("GET", runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"public"}, "")), handler, runtime.WithRouteTags("public"))
+
+	token := signer.sign(t, map[string]interface{}{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()})
+	for path, want := range map[string][]string{
+		"/private": {"user-1"},
+		"/public":  nil,
+	} {
+		md = nil
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		r.Header.Set("Grpc-Metadata-X-User-Id", "admin")
+		mux.ServeHTTP(httptest.NewRecorder(), r)
+		if got := md.Get("x-user-id"); !reflect.DeepEqual(got, want) {
+			t.Errorf("GET %s: x-user-id = %q; want %q", path, got, want)
+		}
+	}
+}
+
+func TestJWTClaimsFromContext(t *testing.T) {
+	if _, ok := runtime.JWTClaimsFromContext(context.Background()); ok {
+		t.Errorf("runtime.JWTClaimsFromContext(context.Background()) = _, true; want false")
+	}
+}
@@ -0,0 +1,207 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/httprule"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RouteInfo describes the route a request was dispatched to.
+type RouteInfo struct {
+	// Method is the HTTP method of the route.
+	Method string
+	// Pattern is the path template of the route, e.g. "/v1/{name=books/*}".
+	Pattern string
+	// Tags are the tags of the route, see WithRouteTags.
+	Tags []string
+	// Upstreams are the upstreams of the route, see WithRouteUpstream.
+	Upstreams []string
+	// Owner is the owner of the route, see WithRouteOwner.
+	Owner string
+}
+
+// HasTag reports whether the route is tagged with tag.
+func (ri RouteInfo) HasTag(tag string) bool {
+	for _, t := range ri.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// An Authorizer decides whether the caller of a request is allowed to call the
+// route the request was dispatched to. It runs after authentication, so the
+// identity of the caller is in ctx, e.g. see JWTClaimsFromContext.
+type Authorizer interface {
+	// Authorize returns nil if the request is allowed. Its error is replied
+	// with the error handler; errors without a gRPC status are replied as
+	// codes.PermissionDenied.
+	Authorize(ctx context.Context, route RouteInfo, r *http.Request) error
+}
+
+// AuthorizerFunc is an adapter to use ordinary functions as Authorizers.
+type AuthorizerFunc func(ctx context.Context, route RouteInfo, r *http.Request) error
+
+// Authorize returns f(ctx, route, r).
+func (f AuthorizerFunc) Authorize(ctx context.Context, route RouteInfo, r *http.Request) error {
+	return f(ctx, route, r)
+}
+
+// WithAuthorizer returns a ServeMuxOption which authorizes every request with
+// a before it is forwarded, see NewRuleAuthorizer for a rule based Authorizer.
+func WithAuthorizer(a Authorizer) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.authorizer = a
+	}
+}
+
+// routeInfo returns the RouteInfo of h for requests with the given method.
+func (h handler) routeInfo(method string) RouteInfo {
+	route := RouteInfo{Method: method, Pattern: h.pat.String()}
+	if h.opts != nil {
+		route.Tags = h.opts.tags
+		route.Upstreams = h.opts.upstreams
+		route.Owner = h.opts.owner
+	}
+	return route
+}
+
+// authorize runs the authorizer of s on r, dispatched to h. If r is not
+// authorized, it emits a security event, replies with the error of the
+// authorizer and returns false.
+func (s *ServeMux) authorize(w http.ResponseWriter, r *http.Request, h handler) bool {
+	if s.authorizer == nil {
+		return true
+	}
+	route := h.routeInfo(r.Method)
+	err := s.authorizer.Authorize(r.Context(), route, r)
+	if err == nil {
+		return true
+	}
+	if _, ok := status.FromError(err); !ok {
+		err = status.Errorf(codes.PermissionDenied, "%v", err)
+	}
+	s.emitSecurityEvent(r, SecurityEventAuthzDenial, route, err)
+	_, outboundMarshaler := MarshalerForRequest(s, r)
+	s.errorHandler(r.Context(), s, outboundMarshaler, w, r, err)
+	return false
+}
+
+// RolesFunc returns the roles of the caller of a request.
+type RolesFunc func(ctx context.Context, r *http.Request) []string
+
+// JWTClaimRoles returns a RolesFunc reading the roles of callers from the
+// claim of their JSON Web Token, see WithJWTAuth. The claim is an array of
+// roles or a string of space separated roles, like the "scope" claim.
+func JWTClaimRoles(claim string) RolesFunc {
+	return func(ctx context.Context, _ *http.Request) []string {
+		claims, ok := JWTClaimsFromContext(ctx)
+		if !ok {
+			return nil
+		}
+		switch v := claims[claim].(type) {
+		case string:
+			return strings.Fields(v)
+		case []interface{}:
+			var roles []string
+			for _, role := range v {
+				if role, ok := role.(string); ok {
+					roles = append(roles, role)
+				}
+			}
+			return roles
+		}
+		return nil
+	}
+}
+
+// AuthorizationRule is a rule of the Authorizer returned by
+// NewRuleAuthorizer. It applies to the routes matching all of Method, Pattern
+// and Tag which are not empty.
+type AuthorizationRule struct {
+	// Method is the HTTP method of the routes the rule applies to.
+	Method string
+	// Pattern is the path template of the routes the rule applies to, as
+	// registered, e.g. "/v1/{name=books/*}".
+	Pattern string
+	// Tag is a tag of the routes the rule applies to, see WithRouteTags.
+	Tag string
+	// Roles are the roles which are allowed to call the routes. Any caller
+	// is allowed if it is empty.
+	Roles []string
+	// Condition further restricts the requests which are allowed, if it is
+	// not nil.
+	Condition func(ctx context.Context, route RouteInfo, r *http.Request) bool
+}
+
+type ruleAuthorizer struct {
+	roles RolesFunc
+	rules []AuthorizationRule
+}
+
+// NewRuleAuthorizer returns an Authorizer which allows a request if the first
+// of rules which applies to its route allows it, and denies requests to
+// routes no rule applies to. roles returns the roles of callers.
+func NewRuleAuthorizer(roles RolesFunc, rules ...AuthorizationRule) (Authorizer, error) {
+	a := &ruleAuthorizer{roles: roles, rules: make([]AuthorizationRule, len(rules))}
+	for i, rule := range rules {
+		if rule.Pattern != "" {
+			// Normalize the template into the form of Pattern.String.
+			pattern, err := parsePattern(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern of authorization rule %d: %v", i, err)
+			}
+			rule.Pattern = pattern.String()
+		}
+		a.rules[i] = rule
+	}
+	return a, nil
+}
+
+func parsePattern(template string) (Pattern, error) {
+	compiler, err := httprule.Parse(template)
+	if err != nil {
+		return Pattern{}, err
+	}
+	tp := compiler.Compile()
+	return NewPattern(tp.Version, tp.OpCodes, tp.Pool, tp.Verb)
+}
+
+func (a *ruleAuthorizer) Authorize(ctx context.Context, route RouteInfo, r *http.Request) error {
+	for _, rule := range a.rules {
+		if !rule.appliesTo(route) {
+			continue
+		}
+		if len(rule.Roles) > 0 && !hasAnyRole(a.roles(ctx, r), rule.Roles) {
+			return status.Errorf(codes.PermissionDenied, "%s %s requires one of the roles %s", route.Method, route.Pattern, strings.Join(rule.Roles, ", "))
+		}
+		if rule.Condition != nil && !rule.Condition(ctx, route, r) {
+			return status.Errorf(codes.PermissionDenied, "%s %s is not allowed", route.Method, route.Pattern)
+		}
+		return nil
+	}
+	return status.Errorf(codes.PermissionDenied, "%s %s is not allowed", route.Method, route.Pattern)
+}
+
+func (rule AuthorizationRule) appliesTo(route RouteInfo) bool {
+	return (rule.Method == "" || rule.Method == route.Method) &&
+		(rule.Pattern == "" || rule.Pattern == route.Pattern) &&
+		(rule.Tag == "" || route.HasTag(rule.Tag))
+}
+
+func hasAnyRole(roles, required []string) bool {
+	for _, role := range roles {
+		for _, r := range required {
+			if role == r {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/protoc-gen-openapiv2/options"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type openAPISecurityAuthorizer struct {
+	scopes RolesFunc
+	// routes maps the HTTP methods and patterns of the routes to the
+	// alternative sets of scopes allowed to call them, or to nil for routes
+	// without security requirements.
+	routes map[string][][]string
+}
+
+// NewOpenAPISecurityAuthorizer returns an Authorizer enforcing the security
+// requirements the openapiv2_operation options of the methods of "files", or
+// else the openapiv2_swagger options of the files, declare for the routes of
+// their google.api.http options. The same annotations document the security
+// of the methods in the OpenAPI documents of protoc-gen-openapiv2.
+//
+// A request is allowed if the caller has all the scopes of any of the
+// requirements of its route, or if the route has no requirement. scopes
+// returns the scopes of callers, e.g. JWTClaimRoles("scope"). Requirements
+// without scopes, e.g. of API key schemes, only need the caller to be
+// authenticated, which is left to the authentication options of the mux.
+// Requests to the routes of other methods, e.g. bound by an external
+// configuration, are denied.
+func NewOpenAPISecurityAuthorizer(scopes RolesFunc, files ...protoreflect.FileDescriptor) (Authorizer, error) {
+	a := &openAPISecurityAuthorizer{scopes: scopes, routes: make(map[string][][]string)}
+	for _, file := range files {
+		var fileRequirements [][]string
+		if swagger, ok := proto.GetExtension(file.Options(), options.E_Openapiv2Swagger).(*options.Swagger); ok {
+			fileRequirements = requiredScopes(swagger.GetSecurity())
+		}
+		services := file.Services()
+		for i := 0; i < services.Len(); i++ {
+			methods := services.Get(i).Methods()
+			for j := 0; j < methods.Len(); j++ {
+				if err := a.addMethod(methods.Get(j), fileRequirements); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return a, nil
+}
+
+func (a *openAPISecurityAuthorizer) addMethod(md protoreflect.MethodDescriptor, fileRequirements [][]string) error {
+	rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+	if !ok || rule == nil {
+		return nil
+	}
+	requirements := fileRequirements
+	if op, ok := proto.GetExtension(md.Options(), options.E_Openapiv2Operation).(*options.Operation); ok && op.GetSecurity() != nil {
+		requirements = requiredScopes(op.GetSecurity())
+	}
+	for _, rule := range append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+		method, template := httpRuleRoute(rule)
+		if method == "" {
+			continue
+		}
+		pattern, err := parsePattern(template)
+		if err != nil {
+			return fmt.Errorf("invalid pattern of %s: %v", md.FullName(), err)
+		}
+		a.routes[method+" "+pattern.String()] = requirements
+	}
+	return nil
+}
+
+// httpRuleRoute returns the HTTP method and path template of "rule".
+func httpRuleRoute(rule *annotations.HttpRule) (method, template string) {
+	switch p := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return "GET", p.Get
+	case *annotations.HttpRule_Put:
+		return "PUT", p.Put
+	case *annotations.HttpRule_Post:
+		return "POST", p.Post
+	case *annotations.HttpRule_Delete:
+		return "DELETE", p.Delete
+	case *annotations.HttpRule_Patch:
+		return "PATCH", p.Patch
+	case *annotations.HttpRule_Custom:
+		return p.Custom.GetKind(), p.Custom.GetPath()
+	}
+	return "", ""
+}
+
+// requiredScopes returns the sets of scopes of "requirements", which is nil
+// if there is none. The scopes of the schemes of a requirement are all
+// required.
+func requiredScopes(requirements []*options.SecurityRequirement) [][]string {
+	var sets [][]string
+	for _, req := range requirements {
+		scopes := []string{}
+		for _, value := range req.GetSecurityRequirement() {
+			scopes = append(scopes, value.GetScope()...)
+		}
+		sort.Strings(scopes)
+		sets = append(sets, scopes)
+	}
+	return sets
+}
+
+func (a *openAPISecurityAuthorizer) Authorize(ctx context.Context, route RouteInfo, r *http.Request) error {
+	requirements, ok := a.routes[route.Method+" "+route.Pattern]
+	if !ok {
+		return status.Errorf(codes.PermissionDenied, "%s %s is not allowed", route.Method, route.Pattern)
+	}
+	if len(requirements) == 0 {
+		return nil
+	}
+	scopes := make(map[string]bool)
+	for _, scope := range a.scopes(ctx, r) {
+		scopes[scope] = true
+	}
+	for _, required := range requirements {
+		if hasAllScopes(scopes, required) {
+			return nil
+		}
+	}
+	alternatives := make([]string, len(requirements))
+	for i, required := range requirements {
+		alternatives[i] = strings.Join(required, " ")
+	}
+	return status.Errorf(codes.PermissionDenied, "%s %s requires the scopes %q", route.Method, route.Pattern, alternatives)
+}
+
+func hasAllScopes(scopes map[string]bool, required []string) bool {
+	for _, scope := range required {
+		if !scopes[scope] {
+			return false
+		}
+	}
+	return true
+}
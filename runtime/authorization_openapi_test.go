@@ -0,0 +1,134 @@
+package runtime_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/protoc-gen-openapiv2/options"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+)
+
+func newSecuredFile(t *testing.T) *descriptorpb.FileDescriptorProto {
+	t.Helper()
+	requirement := func(scopes map[string][]string) *options.SecurityRequirement {
+		req := &options.SecurityRequirement{SecurityRequirement: make(map[string]*options.SecurityRequirement_SecurityRequirementValue)}
+		for scheme, scopes := range scopes {
+			req.SecurityRequirement[scheme] = &options.SecurityRequirement_SecurityRequirementValue{Scope: scopes}
+		}
+		return req
+	}
+	method := func(name string, rule *annotations.HttpRule, security ...*options.SecurityRequirement) *descriptorpb.MethodDescriptorProto {
+		opts := &descriptorpb.MethodOptions{}
+		proto.SetExtension(opts, annotations.E_Http, rule)
+		if security != nil {
+			proto.SetExtension(opts, options.E_Openapiv2Operation, &options.Operation{Security: security})
+		}
+		return &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(name),
+			InputType:  proto.String(".google.protobuf.Empty"),
+			OutputType: proto.String(".google.protobuf.Empty"),
+			Options:    opts,
+		}
+	}
+	fileOpts := &descriptorpb.FileOptions{}
+	proto.SetExtension(fileOpts, options.E_Openapiv2Swagger, &options.Swagger{
+		Security: []*options.SecurityRequirement{
+			requirement(map[string][]string{"OAuth2": {"read"}}),
+		},
+	})
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("secured.proto"),
+		Package:    proto.String("example.secured"),
+		Dependency: []string{"google/protobuf/empty.proto"},
+		Syntax:     proto.String("proto3"),
+		Options:    fileOpts,
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("BookService"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("GetBook", &annotations.HttpRule{
+					Pattern: &annotations.HttpRule_Get{Get: "/v1/{name=books/*}"},
+					AdditionalBindings: []*annotations.HttpRule{{
+						Pattern: &annotations.HttpRule_Custom{Custom: &annotations.CustomHttpPattern{Kind: "HEAD", Path: "/v1/{name=books/*}"}},
+					}},
+				}),
+				method("DeleteBook", &annotations.HttpRule{
+					Pattern: &annotations.HttpRule_Delete{Delete: "/v1/{name=books/*}"},
+				},
+					requirement(map[string][]string{"OAuth2": {"write"}, "ApiKeyAuth": nil}),
+					requirement(map[string][]string{"OAuth2": {"admin"}}),
+				),
+				method("ListBooks", &annotations.HttpRule{
+					Pattern: &annotations.HttpRule_Get{Get: "/v1/books"},
+				}, requirement(nil)),
+			},
+		}},
+	}
+}
+
+func TestOpenAPISecurityAuthorizer(t *testing.T) {
+	file, err := protodesc.NewFile(newSecuredFile(t), protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile(...) failed with %v; want success", err)
+	}
+	scopes := func(ctx context.Context, r *http.Request) []string {
+		return strings.Fields(r.Header.Get("X-Scopes"))
+	}
+	authorizer, err := runtime.NewOpenAPISecurityAuthorizer(scopes, file)
+	if err != nil {
+		t.Fatalf("runtime.NewOpenAPISecurityAuthorizer(...) failed with %v; want success", err)
+	}
+	mux := runtime.NewServeMux(runtime.WithAuthorizer(authorizer))
+	for _, route := range []struct {
+		method   string
+		template string
+	}{
+		{method: "GET", template: "/v1/{name=books/*}"},
+		{method: "HEAD", template: "/v1/{name=books/*}"},
+		{method: "DELETE", template: "/v1/{name=books/*}"},
+		{method: "GET", template: "/v1/books"},
+		{method: "GET", template: "/v1/authors"},
+	} {
+		if err := mux.HandlePath(route.method, route.template, func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {}); err != nil {
+			t.Fatalf("mux.HandlePath(%q, %q, ...) failed with %v; want success", route.method, route.template, err)
+		}
+	}
+
+	for _, spec := range []struct {
+		method string
+		path   string
+		scopes string
+		status int
+	}{
+		// GetBook has the requirements of the file, as its additional
+		// binding does.
+		{method: "GET", path: "/v1/books/1", status: http.StatusForbidden},
+		{method: "GET", path: "/v1/books/1", scopes: "read", status: http.StatusOK},
+		{method: "HEAD", path: "/v1/books/1", status: http.StatusForbidden},
+		{method: "HEAD", path: "/v1/books/1", scopes: "read", status: http.StatusOK},
+		// DeleteBook requires either "write" or "admin".
+		{method: "DELETE", path: "/v1/books/1", scopes: "read", status: http.StatusForbidden},
+		{method: "DELETE", path: "/v1/books/1", scopes: "read write", status: http.StatusOK},
+		{method: "DELETE", path: "/v1/books/1", scopes: "admin", status: http.StatusOK},
+		// ListBooks overrides the requirements of the file with an empty one.
+		{method: "GET", path: "/v1/books", status: http.StatusOK},
+		// Routes of other methods are denied.
+		{method: "GET", path: "/v1/authors", scopes: "read write admin", status: http.StatusForbidden},
+	} {
+		r := httptest.NewRequest(spec.method, spec.path, nil)
+		r.Header.Set("X-Scopes", spec.scopes)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != spec.status {
+			t.Errorf("%s %s with scopes %q: w.Code = %d; want %d", spec.method, spec.path, spec.scopes, w.Code, spec.status)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/httprule"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestRuleAuthorizer(t *testing.T) {
+	roles := func(ctx context.Context, r *http.Request) []string {
+		return strings.Fields(r.Header.Get("X-Roles"))
+	}
+	authorizer, err := runtime.NewRuleAuthorizer(roles,
+		runtime.AuthorizationRule{Method: "GET", Pattern: "/v1/books/{id}"},
+		runtime.AuthorizationRule{Tag: "admin", Roles: []string{"admin"}},
+		runtime.AuthorizationRule{
+			Method: "POST",
+			Roles:  []string{"writer", "admin"},
+			Condition: func(ctx context.Context, route runtime.RouteInfo, r *http.Request) bool {
+				return r.Header.Get("X-Tenant") == "example"
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("runtime.NewRuleAuthorizer(...) failed with %v; want success", err)
+	}
+	mux := runtime.NewServeMuxDynamic(runtime.WithAuthorizer(authorizer))
+	handler := func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {}
+	for _, route := range []struct {
+		method   string
+		template string
+		opts     []runtime.RouteOption
+	}{
+		{method: "GET", template: "/v1/books/{id}"},
+		{method: "GET", template: "/v1/authors/{id}"},
+		{method: "POST", template: "/v1/books"},
+		{method: "DELETE", template: "/v1/books/{id}", opts: []runtime.RouteOption{runtime.WithRouteTags("admin")}},
+	} {
+		mux.Handle(route.method, mustPattern(t, route.template), handler, route.opts...)
+	}
+
+	for _, spec := range []struct {
+		method string
+		path   string
+		roles  string
+		tenant string
+		status int
+	}{
+		{method: "GET", path: "/v1/books/1", status: http.StatusOK},
+		{method: "GET", path: "/v1/authors/1", roles: "admin", status: http.StatusForbidden},
+		{method: "DELETE", path: "/v1/books/1", roles: "reader", status: http.StatusForbidden},
+		{method: "DELETE", path: "/v1/books/1", roles: "reader admin", status: http.StatusOK},
+		{method: "POST", path: "/v1/books", roles: "writer", status: http.StatusForbidden},
+		{method: "POST", path: "/v1/books", roles: "writer", tenant: "example", status: http.StatusOK},
+		{method: "POST", path: "/v1/books", tenant: "example", status: http.StatusForbidden},
+	} {
+		r := httptest.NewRequest(spec.method, spec.path, nil)
+		r.Header.Set("X-Roles", spec.roles)
+		r.Header.Set("X-Tenant", spec.tenant)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != spec.status {
+			t.Errorf("%s %s with roles %q and tenant %q: w.Code = %d; want %d", spec.method, spec.path, spec.roles, spec.tenant, w.Code, spec.status)
+		}
+	}
+}
+
+func TestNewRuleAuthorizer_InvalidPattern(t *testing.T) {
+	if _, err := runtime.NewRuleAuthorizer(nil, runtime.AuthorizationRule{Pattern: "/v1/{"}); err == nil {
+		t.Errorf("runtime.NewRuleAuthorizer(...) succeeded; want an error for an invalid pattern")
+	}
+}
+
+func TestAuthorizerFunc(t *testing.T) {
+	var got runtime.RouteInfo
+	mux := runtime.NewServeMuxDynamic(runtime.WithAuthorizer(runtime.AuthorizerFunc(func(ctx context.Context, route runtime.RouteInfo, r *http.Request) error {
+		got = route
+		return errors.New("denied")
+	})))
+	mux.Handle("GET", mustPattern(t, "/v1/{name=books/*}"), func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		t.Errorf("handler called for an unauthorized request")
+	}, runtime.WithRouteTags("books"))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/books/1", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("w.Code = %d; want %d", w.Code, http.StatusForbidden)
+	}
+	want := runtime.RouteInfo{Method: "GET", Pattern: "/v1/{name=books/*}", Tags: []string{"books"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("route = %#v; want %#v", got, want)
+	}
+}
+
+func mustPattern(t *testing.T, template string) runtime.Pattern {
+	t.Helper()
+	compiler, err := httprule.Parse(template)
+	if err != nil {
+		t.Fatalf("httprule.Parse(%q) failed with %v; want success", template, err)
+	}
+	tp := compiler.Compile()
+	return runtime.MustPattern(runtime.NewPattern(tp.Version, tp.OpCodes, tp.Pool, tp.Verb))
+}
@@ -0,0 +1,249 @@
+package runtime
+
+import (
+	"context"
+	"hash/fnv"
+	"net/http"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Backend is a replica of the upstream gRPC server of a BackendGroup.
+type Backend struct {
+	// Name identifies the replica in the group, e.g. its address. It is the
+	// value of the affinity cookie of the clients pinned to the replica.
+	Name string
+	// Conn is the connection to the replica.
+	Conn grpc.ClientConnInterface
+}
+
+// BackendGroup is a grpc.ClientConnInterface spreading the calls across the
+// replicas of an upstream gRPC server, e.g. to serve the routes of the
+// handlers registered with a client of the group.
+//
+// The requests to the routes registered with WithRouteBackendGroup can be
+// pinned to a replica, so that the subsequent requests of a client hit the
+// same replica, see WithAffinityCookie and WithAffinityHeader. Other calls
+// are spread round-robin. A request pinned to a replica which was ejected
+// fails over to another replica.
+type BackendGroup struct {
+	backends []Backend
+	cookie   string
+	header   string
+	// conns are the connections dialed by DialBackendGroup.
+	conns []*grpc.ClientConn
+
+	mu      sync.RWMutex
+	ejected map[string]bool
+	next    int
+}
+
+// BackendGroupOption is an option that can be given to a BackendGroup on
+// construction.
+type BackendGroupOption func(*BackendGroup)
+
+// WithAffinityCookie returns a BackendGroupOption pinning clients to a
+// replica with the cookie "name", which holds the name of the replica. The
+// cookie is set on the responses of the requests without a valid one.
+func WithAffinityCookie(name string) BackendGroupOption {
+	return func(g *BackendGroup) {
+		g.cookie = name
+	}
+}
+
+// WithAffinityHeader returns a BackendGroupOption pinning the requests with
+// the same value of the header "name", e.g. a session ID, to a replica. The
+// replica is chosen by rendezvous hashing of the value, so that ejecting a
+// replica only moves the requests which were pinned to it. It takes
+// precedence over WithAffinityCookie.
+func WithAffinityHeader(name string) BackendGroupOption {
+	return func(g *BackendGroup) {
+		g.header = http.CanonicalHeaderKey(name)
+	}
+}
+
+// NewBackendGroup returns a BackendGroup of backends.
+func NewBackendGroup(backends []Backend, opts ...BackendGroupOption) *BackendGroup {
+	g := &BackendGroup{
+		backends: append([]Backend(nil), backends...),
+		ejected:  make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Eject stops sending calls to the replica "name", e.g. once its health
+// checks fail. The clients pinned to it fail over to other replicas.
+func (g *BackendGroup) Eject(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.ejected[name] = true
+}
+
+// Restore resumes sending calls to the replica "name" once ejected.
+func (g *BackendGroup) Restore(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.ejected, name)
+}
+
+// Close closes the connections to the replicas dialed by DialBackendGroup.
+func (g *BackendGroup) Close() error {
+	var err error
+	for _, conn := range g.conns {
+		if cerr := conn.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Invoke performs a unary RPC on a replica of g, with the call options of
+// WithRouteCallOptions.
+func (g *BackendGroup) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	b, err := g.backendFor(ctx)
+	if err != nil {
+		return err
+	}
+	markUpstreamTarget(ctx, b.Name)
+	return b.Conn.Invoke(ctx, method, args, reply, routeCallOptions(ctx, opts)...)
+}
+
+// NewStream begins a streaming RPC on a replica of g, with the call options
+// of WithRouteCallOptions.
+func (g *BackendGroup) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	b, err := g.backendFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	markUpstreamTarget(ctx, b.Name)
+	return b.Conn.NewStream(ctx, desc, method, routeCallOptions(ctx, opts)...)
+}
+
+type backendAffinityKey struct {
+	g *BackendGroup
+}
+
+// backendFor returns the replica the call of ctx is pinned to, or the next
+// replica if it is not pinned or its replica was ejected.
+func (g *BackendGroup) backendFor(ctx context.Context) (Backend, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if name, ok := ctx.Value(backendAffinityKey{g}).(string); ok {
+		if b, ok := g.lookup(name); ok {
+			return b, nil
+		}
+	}
+	return g.pick()
+}
+
+// lookup returns the replica "name" if it is not ejected.
+func (g *BackendGroup) lookup(name string) (Backend, bool) {
+	if g.ejected[name] {
+		return Backend{}, false
+	}
+	for _, b := range g.backends {
+		if b.Name == name {
+			return b, true
+		}
+	}
+	return Backend{}, false
+}
+
+// pick returns the next replica which is not ejected, round-robin.
+func (g *BackendGroup) pick() (Backend, error) {
+	for range g.backends {
+		b := g.backends[g.next%len(g.backends)]
+		g.next++
+		if !g.ejected[b.Name] {
+			return b, nil
+		}
+	}
+	return Backend{}, status.Error(codes.Unavailable, "no backend available")
+}
+
+// hashed returns the replica with the highest rendezvous hash of key among
+// the replicas which are not ejected.
+func (g *BackendGroup) hashed(key string) (Backend, error) {
+	var (
+		best  Backend
+		score uint64
+		found bool
+	)
+	for _, b := range g.backends {
+		if g.ejected[b.Name] {
+			continue
+		}
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(b.Name))
+		if s := h.Sum64(); !found || s > score {
+			best, score, found = b, s, true
+		}
+	}
+	if !found {
+		return Backend{}, status.Error(codes.Unavailable, "no backend available")
+	}
+	return best, nil
+}
+
+// pin returns r pinned to a replica of g, following the affinity of g. In
+// cookie mode, it sets the affinity cookie on w if r had no valid one.
+func (g *BackendGroup) pin(w http.ResponseWriter, r *http.Request) *http.Request {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var (
+		b   Backend
+		err error
+	)
+	switch {
+	case g.header != "" && r.Header.Get(g.header) != "":
+		b, err = g.hashed(r.Header.Get(g.header))
+	case g.cookie != "":
+		if c, cerr := r.Cookie(g.cookie); cerr == nil {
+			if pinned, ok := g.lookup(c.Value); ok {
+				b = pinned
+				break
+			}
+		}
+		if b, err = g.pick(); err == nil {
+			http.SetCookie(w, &http.Cookie{Name: g.cookie, Value: b.Name, Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode})
+		}
+	default:
+		return r
+	}
+	if err != nil {
+		// The calls fail with the error once made.
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), backendAffinityKey{g}, b.Name))
+}
+
+// WithRouteBackendGroup returns a RouteOption pinning the requests to this
+// route to a replica of g, following the affinity of g, when the route calls
+// the upstream server through g.
+func WithRouteBackendGroup(g *BackendGroup) RouteOption {
+	return func(o *routeOptions) {
+		o.backendGroups = append(o.backendGroups, g)
+	}
+}
+
+// pinBackends returns r pinned to a replica of each backend group of the
+// route h.
+func pinBackends(w http.ResponseWriter, r *http.Request, h handler) *http.Request {
+	if h.opts == nil {
+		return r
+	}
+	for _, g := range h.opts.backendGroups {
+		r = g.pin(w, r)
+	}
+	return r
+}
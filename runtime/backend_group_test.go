@@ -0,0 +1,148 @@
+package runtime_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recordingConn records the calls to a replica.
+type recordingConn struct {
+	name  string
+	calls *[]string
+}
+
+func (c recordingConn) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	*c.calls = append(*c.calls, c.name)
+	return nil
+}
+
+func (c recordingConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	*c.calls = append(*c.calls, c.name)
+	return nil, nil
+}
+
+func newBackendGroupTestMux(t *testing.T, opts ...runtime.BackendGroupOption) (*runtime.ServeMuxDynamic, *runtime.BackendGroup, *[]string) {
+	t.Helper()
+	var calls []string
+	g := runtime.NewBackendGroup([]runtime.Backend{
+		{Name: "a", Conn: recordingConn{name: "a", calls: &calls}},
+		{Name: "b", Conn: recordingConn{name: "b", calls: &calls}},
+		{Name: "c", Conn: recordingConn{name: "c", calls: &calls}},
+	}, opts...)
+	mux := runtime.NewServeMuxDynamic()
+	call := func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		if err := g.Invoke(r.Context(), "/example.Example/Get", nil, nil); err != nil {
+			w.WriteHeader(runtime.HTTPStatusFromCode(status.Code(err)))
+		}
+	}
+	if err := mux.HandlePath("GET", "/v1/pinned", call, runtime.WithRouteBackendGroup(g)); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.HandlePath("GET", "/v1/spread", call); err != nil {
+		t.Fatal(err)
+	}
+	return mux, g, &calls
+}
+
+func TestBackendGroup_AffinityCookie(t *testing.T) {
+	mux, g, calls := newBackendGroupTestMux(t, runtime.WithAffinityCookie("backend"))
+	get := func(cookie string) (string, string) {
+		r := httptest.NewRequest("GET", "/v1/pinned", nil)
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: "backend", Value: cookie})
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		var set string
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "backend" {
+				set = c.Value
+			}
+		}
+		return (*calls)[len(*calls)-1], set
+	}
+
+	got, set := get("")
+	if got != "a" || set != "a" {
+		t.Errorf("new client called %q and was pinned to %q; want a and a", got, set)
+	}
+	for i := 0; i < 3; i++ {
+		if got, set := get("b"); got != "b" || set != "" {
+			t.Errorf("client pinned to b called %q and was pinned to %q; want b and no new cookie", got, set)
+		}
+	}
+	if got, set := get("unknown"); got != "b" || set != "b" {
+		t.Errorf("client pinned to an unknown backend called %q and was pinned to %q; want b and b", got, set)
+	}
+
+	g.Eject("b")
+	if got, set := get("b"); got != "c" || set != "c" {
+		t.Errorf("client pinned to an ejected backend called %q and was pinned to %q; want c and c", got, set)
+	}
+	g.Restore("b")
+	if got, _ := get("b"); got != "b" {
+		t.Errorf("client pinned to a restored backend called %q; want b", got)
+	}
+}
+
+func TestBackendGroup_AffinityHeader(t *testing.T) {
+	mux, g, calls := newBackendGroupTestMux(t, runtime.WithAffinityHeader("X-Session-Id"))
+	get := func(session string) string {
+		r := httptest.NewRequest("GET", "/v1/pinned", nil)
+		r.Header.Set("X-Session-Id", session)
+		mux.ServeHTTP(httptest.NewRecorder(), r)
+		return (*calls)[len(*calls)-1]
+	}
+
+	pinned := map[string]string{}
+	for _, session := range []string{"s1", "s2", "s3", "s4", "s5", "s6"} {
+		pinned[session] = get(session)
+		for i := 0; i < 3; i++ {
+			if got := get(session); got != pinned[session] {
+				t.Errorf("session %s called %q; want %q", session, got, pinned[session])
+			}
+		}
+	}
+
+	g.Eject(pinned["s1"])
+	for session, backend := range pinned {
+		got := get(session)
+		switch {
+		case backend == pinned["s1"] && got == backend:
+			t.Errorf("session %s called the ejected backend %q", session, got)
+		case backend != pinned["s1"] && got != backend:
+			t.Errorf("session %s called %q once another backend was ejected; want %q", session, got, backend)
+		}
+	}
+}
+
+func TestBackendGroup_RoundRobin(t *testing.T) {
+	mux, g, calls := newBackendGroupTestMux(t, runtime.WithAffinityCookie("backend"))
+	g.Eject("b")
+	for i := 0; i < 4; i++ {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/spread", nil))
+		if len(w.Result().Cookies()) != 0 {
+			t.Errorf("request to a route without backend group set cookies %v", w.Result().Cookies())
+		}
+	}
+	if diff := cmp.Diff([]string{"a", "c", "a", "c"}, *calls); diff != "" {
+		t.Errorf("calls differ (-want +got):\n%s", diff)
+	}
+
+	g.Eject("a")
+	g.Eject("c")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/pinned", nil))
+	if w.Code != runtime.HTTPStatusFromCode(codes.Unavailable) {
+		t.Errorf("code = %d once all backends were ejected; want %d", w.Code, runtime.HTTPStatusFromCode(codes.Unavailable))
+	}
+}
@@ -0,0 +1,71 @@
+package runtime
+
+import (
+	"io"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A BodyTransformer returns the body the handler of a request reads instead
+// of r.Body, e.g. a reader decrypting r.Body, stripping a legacy envelope or
+// converting a CSV upload to JSON. It may update the headers of r to describe
+// the new body, e.g. its Content-Type. Its error is replied with the error
+// handler; errors without a gRPC status are replied as
+// codes.InvalidArgument.
+type BodyTransformer func(r *http.Request) (io.ReadCloser, error)
+
+// WithBodyTransformer returns a ServeMuxOption transforming the body of every
+// request with t before it is unmarshaled by its handler. Transformers run in
+// the order they are registered, after authentication and authorization, so
+// that request signatures are verified on the body sent by the client.
+func WithBodyTransformer(t BodyTransformer) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.bodyTransformers = append(mux.bodyTransformers, t)
+	}
+}
+
+// WithRouteBodyTransformer returns a RouteOption transforming the body of the
+// requests to this route with t, after the mux-wide transformers registered
+// with WithBodyTransformer.
+func WithRouteBodyTransformer(t BodyTransformer) RouteOption {
+	return func(o *routeOptions) {
+		o.bodyTransformers = append(o.bodyTransformers, t)
+	}
+}
+
+// transformBody returns r with its body transformed by the transformers of
+// s and h. If a transformer fails, it replies with its error and returns
+// false.
+func (s *ServeMux) transformBody(w http.ResponseWriter, r *http.Request, h handler) (*http.Request, bool) {
+	transformers := s.bodyTransformers
+	if h.opts != nil && len(h.opts.bodyTransformers) > 0 {
+		transformers = append(transformers[:len(transformers):len(transformers)], h.opts.bodyTransformers...)
+	}
+	if len(transformers) == 0 {
+		return r, true
+	}
+
+	// The transformers may update the headers, which must not leak to the
+	// request of the caller of ServeHTTP.
+	r = r.Clone(r.Context())
+	for _, t := range transformers {
+		body, err := t(r)
+		if err != nil {
+			if _, ok := status.FromError(err); !ok {
+				err = status.Errorf(codes.InvalidArgument, "%v", err)
+			}
+			_, outboundMarshaler := MarshalerForRequest(s, r)
+			s.errorHandler(r.Context(), s, outboundMarshaler, w, r, err)
+			return nil, false
+		}
+		if body != r.Body {
+			r.Body = body
+			// The length of the transformed body is unknown.
+			r.ContentLength = -1
+			r.Header.Del("Content-Length")
+		}
+	}
+	return r, true
+}
@@ -0,0 +1,113 @@
+package runtime_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestBodyTransformer(t *testing.T) {
+	// stripEnvelope strips the "legacy:" prefix some clients send.
+	stripEnvelope := func(r *http.Request) (io.ReadCloser, error) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.HasPrefix(body, []byte("legacy:")) {
+			return nil, errors.New("missing legacy envelope")
+		}
+		return ioutil.NopCloser(bytes.NewReader(bytes.TrimPrefix(body, []byte("legacy:")))), nil
+	}
+	// csvToJSON converts a CSV upload of name/title records to JSON.
+	csvToJSON := func(r *http.Request) (io.ReadCloser, error) {
+		records, err := csv.NewReader(r.Body).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		var books []map[string]string
+		for _, rec := range records {
+			books = append(books, map[string]string{"name": rec[0], "title": rec[1]})
+		}
+		body, err := json.Marshal(books)
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Content-Type", "application/json")
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	mux := runtime.NewServeMuxDynamic(runtime.WithBodyTransformer(stripEnvelope))
+	echo := func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("ioutil.ReadAll(r.Body) failed with %v; want success", err)
+		}
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.Write(body)
+	}
+	if err := mux.HandlePath("POST", "/v1/books", echo); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.HandlePath("POST", "/v1/books:import", echo, runtime.WithRouteBodyTransformer(csvToJSON)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, spec := range []struct {
+		path            string
+		body            string
+		wantCode        int
+		wantBody        string
+		wantContentType string
+	}{
+		{
+			path:            "/v1/books",
+			body:            `legacy:{"name": "books/1"}`,
+			wantCode:        http.StatusOK,
+			wantBody:        `{"name": "books/1"}`,
+			wantContentType: "text/csv",
+		},
+		{
+			path:            "/v1/books:import",
+			body:            "legacy:books/1,Dune\nbooks/2,Emma\n",
+			wantCode:        http.StatusOK,
+			wantBody:        `[{"name":"books/1","title":"Dune"},{"name":"books/2","title":"Emma"}]`,
+			wantContentType: "application/json",
+		},
+		{
+			path:     "/v1/books",
+			body:     `{"name": "books/1"}`,
+			wantCode: http.StatusBadRequest,
+		},
+	} {
+		r := httptest.NewRequest("POST", spec.path, strings.NewReader(spec.body))
+		r.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+
+		if w.Code != spec.wantCode {
+			t.Errorf("POST %s %q: code = %d; want %d", spec.path, spec.body, w.Code, spec.wantCode)
+			continue
+		}
+		if r.Header.Get("Content-Type") != "text/csv" {
+			t.Errorf("POST %s: the request of the caller was modified: Content-Type = %q", spec.path, r.Header.Get("Content-Type"))
+		}
+		if spec.wantCode != http.StatusOK {
+			continue
+		}
+		if got := w.Body.String(); got != spec.wantBody {
+			t.Errorf("POST %s: body = %s; want %s", spec.path, got, spec.wantBody)
+		}
+		if got := w.Header().Get("Content-Type"); got != spec.wantContentType {
+			t.Errorf("POST %s: Content-Type = %q; want %q", spec.path, got, spec.wantContentType)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package runtime
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultMaxPooledBufferSize is the default capacity above which buffers are
+// not returned to the pool, see SetMaxPooledBufferSize.
+const defaultMaxPooledBufferSize = 64 << 10
+
+var (
+	bufferPool = sync.Pool{
+		New: func() interface{} {
+			atomic.AddUint64(&bufferPoolCounters.allocations, 1)
+			return new(bytes.Buffer)
+		},
+	}
+	maxPooledBufferSize int64 = defaultMaxPooledBufferSize
+
+	bufferPoolCounters struct {
+		gets, puts, allocations, discards uint64
+	}
+)
+
+// BufferPoolStats are statistics of the pool of buffers the forwarders marshal
+// responses into, see MarshalBufferPoolStats.
+type BufferPoolStats struct {
+	// Gets is the number of buffers taken from the pool.
+	Gets uint64
+	// Puts is the number of buffers returned to the pool.
+	Puts uint64
+	// Allocations is the number of buffers allocated because the pool was empty.
+	Allocations uint64
+	// Discards is the number of buffers which were dropped instead of being
+	// returned to the pool because they had grown beyond the maximum size.
+	Discards uint64
+}
+
+// MarshalBufferPoolStats returns the statistics of the pool of marshaling
+// buffers since the process started. A high ratio of Allocations to Gets
+// means that buffers are not reused, a high ratio of Discards to Gets that
+// the maximum size of pooled buffers is too low for the responses.
+func MarshalBufferPoolStats() BufferPoolStats {
+	return BufferPoolStats{
+		Gets:        atomic.LoadUint64(&bufferPoolCounters.gets),
+		Puts:        atomic.LoadUint64(&bufferPoolCounters.puts),
+		Allocations: atomic.LoadUint64(&bufferPoolCounters.allocations),
+		Discards:    atomic.LoadUint64(&bufferPoolCounters.discards),
+	}
+}
+
+// SetMaxPooledBufferSize sets the capacity above which marshaling buffers are
+// not returned to the pool, so that a few large responses do not pin memory.
+// It defaults to 64 KiB, a size of 0 disables pooling.
+func SetMaxPooledBufferSize(size int) {
+	atomic.StoreInt64(&maxPooledBufferSize, int64(size))
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	atomic.AddUint64(&bufferPoolCounters.gets, 1)
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns buf to the pool. buf must not be used afterwards.
+func putBuffer(buf *bytes.Buffer) {
+	if int64(buf.Cap()) > atomic.LoadInt64(&maxPooledBufferSize) {
+		atomic.AddUint64(&bufferPoolCounters.discards, 1)
+		return
+	}
+	atomic.AddUint64(&bufferPoolCounters.puts, 1)
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// bufferMarshaler is implemented by marshalers which can marshal a value
+// directly into a buffer, without allocating the marshaled bytes.
+type bufferMarshaler interface {
+	// marshalBuffer appends the marshaled form of v to buf.
+	marshalBuffer(buf *bytes.Buffer, v interface{}) error
+}
+
+// marshalToBuffer appends the marshaled form of v to buf.
+func marshalToBuffer(m Marshaler, buf *bytes.Buffer, v interface{}) error {
+	if bm, ok := m.(bufferMarshaler); ok {
+		return bm.marshalBuffer(buf, v)
+	}
+	b, err := m.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
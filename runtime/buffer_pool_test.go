@@ -0,0 +1,54 @@
+package runtime_test
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestForwardResponseMessageBufferPool(t *testing.T) {
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	mux := runtime.NewServeMux()
+	forward := func(marshaler runtime.Marshaler, msg proto.Message) []byte {
+		req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+		resp := httptest.NewRecorder()
+		runtime.ForwardResponseMessage(ctx, mux, marshaler, resp, req, msg)
+		return resp.Body.Bytes()
+	}
+
+	msg := &examplepb.SimpleMessage{Id: strings.Repeat("x", 100)}
+	want, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("proto.Marshal(%v) failed with %v; want success", msg, err)
+	}
+	before := runtime.MarshalBufferPoolStats()
+	for i := 0; i < 3; i++ {
+		if got := forward(&runtime.ProtoMarshaller{}, msg); !bytes.Equal(got, want) {
+			t.Errorf("ForwardResponseMessage(%v) = %q; want %q", msg, got, want)
+		}
+	}
+	after := runtime.MarshalBufferPoolStats()
+	if got := after.Gets - before.Gets; got != 3 {
+		t.Errorf("Gets increased by %d; want 3", got)
+	}
+	if got := after.Puts - before.Puts; got != 3 {
+		t.Errorf("Puts increased by %d; want 3", got)
+	}
+
+	runtime.SetMaxPooledBufferSize(16)
+	defer runtime.SetMaxPooledBufferSize(64 << 10)
+	before = runtime.MarshalBufferPoolStats()
+	if got, want := string(forward(&runtime.JSONPb{}, msg)), `{"id":"`+msg.Id+`"}`; got != want {
+		t.Errorf("ForwardResponseMessage(%v) = %s; want %s", msg, got, want)
+	}
+	after = runtime.MarshalBufferPoolStats()
+	if got := after.Discards - before.Discards; got != 1 {
+		t.Errorf("Discards increased by %d; want 1", got)
+	}
+}
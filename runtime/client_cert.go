@@ -0,0 +1,180 @@
+package runtime
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const defaultClientCertMetadata = "x-forwarded-client-cert"
+
+// ClientIdentity is the identity of a client established by the client
+// certificate it presented in a mutual TLS handshake.
+type ClientIdentity struct {
+	// Certificate is the client certificate.
+	Certificate *x509.Certificate
+	// Subject is the distinguished name of the subject of the certificate.
+	Subject string
+	// DNSNames, URIs, EmailAddresses and IPAddresses are the subject
+	// alternative names of the certificate.
+	DNSNames       []string
+	URIs           []string
+	EmailAddresses []string
+	IPAddresses    []string
+	// Fingerprint is the hex encoded SHA-256 hash of the DER encoding of the
+	// certificate.
+	Fingerprint string
+}
+
+// ClientCertConfig configures the forwarding of client identities, see
+// WithClientCertForwarding.
+type ClientCertConfig struct {
+	// Metadata is the key of the gRPC metadata the identity is forwarded in.
+	// It defaults to "x-forwarded-client-cert".
+	Metadata string
+	// Required rejects requests without a client certificate with
+	// codes.Unauthenticated.
+	Required bool
+	// Verify is called with the identity of clients, and the chains the
+	// client certificate was verified with by the TLS server. Requests it
+	// returns an error for are rejected with the error through the error
+	// handler; errors without a gRPC status are rejected with
+	// codes.PermissionDenied.
+	Verify func(id *ClientIdentity, verifiedChains [][]*x509.Certificate) error
+}
+
+// WithClientCertForwarding returns a ServeMuxOption which forwards the
+// identity of clients, established by the client certificate they presented
+// to an HTTP server terminating mutual TLS, to the gRPC server. The identity
+// is forwarded in the format of the x-forwarded-client-cert header of Envoy:
+//
+//	Hash=<fingerprint>;Subject="<subject>";URI=<uri>;DNS=<dns name>
+//
+// Incoming headers mapped onto the same metadata key are dropped, so clients
+// cannot forge an identity. The identity is available to handlers with
+// ClientIdentityFromContext.
+func WithClientCertForwarding(config ClientCertConfig) ServeMuxOption {
+	return func(mux *ServeMux) {
+		if config.Metadata == "" {
+			config.Metadata = defaultClientCertMetadata
+		}
+		f := &clientCertForwarder{mux: mux, config: config}
+		mux.authenticators = append(mux.authenticators, f.authenticate)
+		mux.metadataAnnotators = append(mux.metadataAnnotators, f.metadata)
+	}
+}
+
+type clientIdentityKey struct{}
+
+// ClientIdentityFromContext returns the identity of the client established by
+// WithClientCertForwarding.
+func ClientIdentityFromContext(ctx context.Context) (*ClientIdentity, bool) {
+	id, ok := ctx.Value(clientIdentityKey{}).(*ClientIdentity)
+	return id, ok
+}
+
+type clientCertForwarder struct {
+	mux    *ServeMux
+	config ClientCertConfig
+}
+
+func (f *clientCertForwarder) authenticate(_ http.ResponseWriter, r *http.Request) (*http.Request, error) {
+	r = f.dropForgedHeaders(r)
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		if f.config.Required {
+			return nil, status.Error(codes.Unauthenticated, "missing client certificate")
+		}
+		return r, nil
+	}
+	id := newClientIdentity(r.TLS.PeerCertificates[0])
+	if f.config.Verify != nil {
+		if err := f.config.Verify(id, r.TLS.VerifiedChains); err != nil {
+			if _, ok := status.FromError(err); ok {
+				return nil, err
+			}
+			return nil, status.Errorf(codes.PermissionDenied, "client certificate rejected: %v", err)
+		}
+	}
+	return r.WithContext(context.WithValue(r.Context(), clientIdentityKey{}, id)), nil
+}
+
+// dropForgedHeaders returns r without the headers which the incoming header
+// matcher maps onto the metadata key of the identity.
+func (f *clientCertForwarder) dropForgedHeaders(r *http.Request) *http.Request {
+	matcher := f.mux.incomingHeaderMatcherFor(r.Context())
+	var forged []string
+	for key := range r.Header {
+		if h, ok := matcher(textproto.CanonicalMIMEHeaderKey(key)); ok && strings.EqualFold(h, f.config.Metadata) {
+			forged = append(forged, key)
+		}
+	}
+	if len(forged) == 0 {
+		return r
+	}
+	r = r.Clone(r.Context())
+	for _, key := range forged {
+		delete(r.Header, key)
+	}
+	return r
+}
+
+func (f *clientCertForwarder) metadata(_ context.Context, req *http.Request) metadata.MD {
+	id, ok := ClientIdentityFromContext(req.Context())
+	if !ok {
+		return nil
+	}
+	return metadata.Pairs(f.config.Metadata, id.String())
+}
+
+func newClientIdentity(cert *x509.Certificate) *ClientIdentity {
+	hash := sha256.Sum256(cert.Raw)
+	id := &ClientIdentity{
+		Certificate:    cert,
+		Subject:        cert.Subject.String(),
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		Fingerprint:    hex.EncodeToString(hash[:]),
+	}
+	for _, u := range cert.URIs {
+		id.URIs = append(id.URIs, u.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		id.IPAddresses = append(id.IPAddresses, ip.String())
+	}
+	return id
+}
+
+// String returns id in the format of the x-forwarded-client-cert header of Envoy.
+func (id *ClientIdentity) String() string {
+	elements := []string{"Hash=" + id.Fingerprint, "Subject=" + quoteXFCCValue(id.Subject)}
+	for _, uri := range id.URIs {
+		elements = append(elements, "URI="+quoteXFCCValue(uri))
+	}
+	for _, name := range id.DNSNames {
+		elements = append(elements, "DNS="+quoteXFCCValue(name))
+	}
+	for _, email := range id.EmailAddresses {
+		elements = append(elements, "Email="+quoteXFCCValue(email))
+	}
+	for _, ip := range id.IPAddresses {
+		elements = append(elements, "IP="+ip)
+	}
+	return strings.Join(elements, ";")
+}
+
+// quoteXFCCValue quotes v if it contains characters separating the elements
+// of an x-forwarded-client-cert header.
+func quoteXFCCValue(v string) string {
+	if !strings.ContainsAny(v, `,;="`) {
+		return v
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+}
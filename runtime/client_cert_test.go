@@ -0,0 +1,145 @@
+package runtime_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func newClientCertificate(t *testing.T) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spiffeID, err := url.Parse("spiffe://example.com/client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client", Organization: []string{"Example"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"client.example.com"},
+		URIs:         []*url.URL{spiffeID},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestClientCertForwarding(t *testing.T) {
+	cert := newClientCertificate(t)
+	hash := sha256.Sum256(cert.Raw)
+	wantXFCC := "Hash=" + hex.EncodeToString(hash[:]) + `;Subject="CN=client,O=Example";URI=spiffe://example.com/client;DNS=client.example.com`
+
+	for _, spec := range []struct {
+		name   string
+		config runtime.ClientCertConfig
+		cert   *x509.Certificate
+		forged string
+		status int
+		xfcc   []string
+	}{
+		{
+			name:   "forwarded",
+			cert:   cert,
+			status: http.StatusOK,
+			xfcc:   []string{wantXFCC},
+		},
+		{
+			name:   "forged header",
+			cert:   cert,
+			forged: "Hash=forged",
+			status: http.StatusOK,
+			xfcc:   []string{wantXFCC},
+		},
+		{
+			name:   "forged header without certificate",
+			forged: "Hash=forged",
+			status: http.StatusOK,
+		},
+		{
+			name:   "required",
+			config: runtime.ClientCertConfig{Required: true},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "rejected",
+			config: runtime.ClientCertConfig{
+				Verify: func(id *runtime.ClientIdentity, _ [][]*x509.Certificate) error {
+					if id.URIs[0] != "spiffe://example.com/admin" {
+						return errors.New("unexpected SPIFFE ID")
+					}
+					return nil
+				},
+			},
+			cert:   cert,
+			status: http.StatusForbidden,
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			mux := runtime.NewServeMux(runtime.WithClientCertForwarding(spec.config))
+			var md metadata.MD
+			var id *runtime.ClientIdentity
+			err := mux.HandlePath("GET", "/whoami", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+				id, _ = runtime.ClientIdentityFromContext(r.Context())
+				ctx, err := runtime.AnnotateContext(context.Background(), mux, r, "/example.Example/WhoAmI")
+				if err != nil {
+					t.Fatalf("runtime.AnnotateContext(...) failed with %v; want success", err)
+				}
+				md, _ = metadata.FromOutgoingContext(ctx)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest("GET", "/whoami", nil)
+			if spec.cert != nil {
+				r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{spec.cert}}
+			}
+			if spec.forged != "" {
+				r.Header.Set("Grpc-Metadata-X-Forwarded-Client-Cert", spec.forged)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != spec.status {
+				t.Fatalf("w.Code = %d; want %d, body: %s", w.Code, spec.status, w.Body)
+			}
+			if spec.status != http.StatusOK {
+				return
+			}
+			if got := md.Get("x-forwarded-client-cert"); !reflect.DeepEqual(got, spec.xfcc) {
+				t.Errorf(`md.Get("x-forwarded-client-cert") = %q; want %q`, got, spec.xfcc)
+			}
+			if spec.cert != nil && (id == nil || id.Certificate != spec.cert) {
+				t.Errorf("runtime.ClientIdentityFromContext(...) = %v; want the identity of the client certificate", id)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const defaultClientIPMetadata = "x-client-ip"
+
+// ParseCIDRs parses CIDR blocks, e.g. "10.0.0.0/8", and single IP addresses
+// into networks.
+func ParseCIDRs(cidrs ...string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIPConfig configures the resolution of the IP addresses of clients, see
+// WithClientIP.
+type ClientIPConfig struct {
+	// TrustedProxies are the networks of the proxies in front of the
+	// gateway whose ForwardedHeader is trusted.
+	TrustedProxies []*net.IPNet
+	// ForwardedHeader is the header the trusted proxies record the address
+	// they received a request from in: "X-Forwarded-For", the default,
+	// "Forwarded" or another header with a comma-separated list of
+	// addresses, e.g. "X-Real-IP". Only this header is trusted: the others
+	// are passed through unchanged by most proxies, so they are set by the
+	// clients.
+	ForwardedHeader string
+	// Metadata is the key of the gRPC metadata the IP address of the client
+	// is forwarded in. It defaults to "x-client-ip". Request headers
+	// forwarded in this key are dropped, so that clients cannot forge it.
+	Metadata string
+}
+
+// WithClientIP returns a ServeMuxOption resolving the IP address of the
+// client of every request. It is the remote address of the request, unless
+// that is a trusted proxy: then it is the address the last trusted proxy
+// received the request from, as recorded in the ForwardedHeader of config.
+//
+// The address is forwarded as gRPC metadata, is available to handlers with
+// ClientIPFromContext and is the address filtered by WithIPFilter.
+func WithClientIP(config ClientIPConfig) ServeMuxOption {
+	return func(mux *ServeMux) {
+		if config.ForwardedHeader == "" {
+			config.ForwardedHeader = "X-Forwarded-For"
+		}
+		if config.Metadata == "" {
+			config.Metadata = defaultClientIPMetadata
+		}
+		mux.clientIP = &config
+		mux.metadataAnnotators = append(mux.metadataAnnotators, config.metadata)
+		mux.gatewayMetadata = append(mux.gatewayMetadata, config.Metadata)
+	}
+}
+
+type clientIPKey struct{}
+
+// ClientIPFromContext returns the IP address of the client resolved by
+// WithClientIP.
+func ClientIPFromContext(ctx context.Context) (net.IP, bool) {
+	ip, ok := ctx.Value(clientIPKey{}).(net.IP)
+	return ip, ok
+}
+
+// withClientIP returns r with the IP address of its client in its context.
+func (c *ClientIPConfig) withClientIP(r *http.Request) *http.Request {
+	ip := c.resolve(r)
+	if ip == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip))
+}
+
+func (c *ClientIPConfig) resolve(r *http.Request) net.IP {
+	ip := remoteIP(r)
+	if ip == nil || !containsIP(c.TrustedProxies, ip) {
+		return ip
+	}
+	var hops []string
+	if http.CanonicalHeaderKey(c.ForwardedHeader) == "Forwarded" {
+		hops = forwardedFor(r.Header.Values("Forwarded"))
+	} else {
+		for _, v := range r.Header.Values(c.ForwardedHeader) {
+			for _, hop := range strings.Split(v, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+	}
+	// Walk the hops back from the gateway to the first untrusted one.
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := parseForwardedIP(hops[i])
+		if hop == nil {
+			// Unknown or obfuscated hops cannot be trusted further.
+			return ip
+		}
+		ip = hop
+		if !containsIP(c.TrustedProxies, ip) {
+			return ip
+		}
+	}
+	return ip
+}
+
+func (c *ClientIPConfig) metadata(_ context.Context, req *http.Request) metadata.MD {
+	ip, ok := ClientIPFromContext(req.Context())
+	if !ok {
+		return nil
+	}
+	return metadata.Pairs(c.Metadata, ip.String())
+}
+
+// remoteIP returns the IP address r was received from.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// forwardedFor returns the "for" parameters of Forwarded headers, as
+// specified by RFC 7239.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				pair = strings.TrimSpace(pair)
+				if len(pair) > 4 && strings.EqualFold(pair[:4], "for=") {
+					hops = append(hops, strings.Trim(pair[4:], `"`))
+				}
+			}
+		}
+	}
+	return hops
+}
+
+// parseForwardedIP parses a node of a Forwarded or X-Forwarded-For header,
+// which may have a port and brackets around an IPv6 address.
+func parseForwardedIP(node string) net.IP {
+	if ip := net.ParseIP(node); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(node, "["), "]"))
+}
+
+// IPFilterConfig configures the filtering of requests by the IP address of
+// their clients, see WithIPFilter.
+type IPFilterConfig struct {
+	// Allow are the networks clients are allowed from. Clients from any
+	// network are allowed if it is empty.
+	Allow []*net.IPNet
+	// Deny are the networks clients are denied from, even if they are in
+	// Allow.
+	Deny []*net.IPNet
+}
+
+// WithIPFilter returns a ServeMuxOption rejecting requests from clients whose
+// IP address, see WithClientIP, is not allowed by config with
+// codes.PermissionDenied through the error handler.
+func WithIPFilter(config IPFilterConfig) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.authenticators = append(mux.authenticators, config.authenticate)
+	}
+}
+
+// WithRouteIPFilter returns a RouteOption rejecting requests to this route
+// from clients whose IP address is not allowed by config, in addition to the
+// filters of the mux. See WithIPFilter.
+func WithRouteIPFilter(config IPFilterConfig) RouteOption {
+	return func(o *routeOptions) {
+		o.authenticators = append(o.authenticators, config.authenticate)
+	}
+}
+
+func (c IPFilterConfig) authenticate(_ http.ResponseWriter, r *http.Request) (*http.Request, error) {
+	ip, ok := ClientIPFromContext(r.Context())
+	if !ok {
+		ip = remoteIP(r)
+	}
+	if ip == nil || containsIP(c.Deny, ip) || (len(c.Allow) > 0 && !containsIP(c.Allow, ip)) {
+		return nil, status.Errorf(codes.PermissionDenied, "client address %s is not allowed", ip)
+	}
+	return r, nil
+}
@@ -0,0 +1,190 @@
+package runtime_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func mustParseCIDRs(t *testing.T, cidrs ...string) []*net.IPNet {
+	t.Helper()
+	nets, err := runtime.ParseCIDRs(cidrs...)
+	if err != nil {
+		t.Fatalf("runtime.ParseCIDRs(%q) failed with %v; want success", cidrs, err)
+	}
+	return nets
+}
+
+func TestParseCIDRs(t *testing.T) {
+	nets := mustParseCIDRs(t, "10.0.0.0/8", "192.0.2.1", "2001:db8::/32", "2001:db8::1")
+	for _, spec := range []struct {
+		net  int
+		ip   string
+		want bool
+	}{
+		{net: 0, ip: "10.1.2.3", want: true},
+		{net: 0, ip: "11.1.2.3", want: false},
+		{net: 1, ip: "192.0.2.1", want: true},
+		{net: 1, ip: "192.0.2.2", want: false},
+		{net: 2, ip: "2001:db8:1::1", want: true},
+		{net: 3, ip: "2001:db8::2", want: false},
+	} {
+		if got := nets[spec.net].Contains(net.ParseIP(spec.ip)); got != spec.want {
+			t.Errorf("%v.Contains(%s) = %v; want %v", nets[spec.net], spec.ip, got, spec.want)
+		}
+	}
+	if _, err := runtime.ParseCIDRs("10.0.0.0/33"); err == nil {
+		t.Errorf(`runtime.ParseCIDRs("10.0.0.0/33") succeeded; want an error`)
+	}
+	if _, err := runtime.ParseCIDRs("example.com"); err == nil {
+		t.Errorf(`runtime.ParseCIDRs("example.com") succeeded; want an error`)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	var ip net.IP
+	var md metadata.MD
+	newMux := func(forwardedHeader string) *runtime.ServeMux {
+		mux := runtime.NewServeMux(runtime.WithClientIP(runtime.ClientIPConfig{
+			TrustedProxies:  mustParseCIDRs(t, "10.0.0.0/8"),
+			ForwardedHeader: forwardedHeader,
+		}))
+		err := mux.HandlePath("GET", "/v1/ip", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+			ip, _ = runtime.ClientIPFromContext(r.Context())
+			ctx, err := runtime.AnnotateContext(context.Background(), mux, r, "/example.Example/IP")
+			if err != nil {
+				t.Fatalf("runtime.AnnotateContext(...) failed with %v; want success", err)
+			}
+			md, _ = metadata.FromOutgoingContext(ctx)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mux
+	}
+
+	for _, spec := range []struct {
+		name            string
+		forwardedHeader string
+		remoteAddr      string
+		headers         map[string]string
+		want            string
+	}{
+		{
+			name:       "direct",
+			remoteAddr: "198.51.100.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "192.0.2.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "X-Forwarded-For",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "192.0.2.1, 198.51.100.1, 10.0.0.2"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "client Forwarded",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string]string{
+				"Forwarded":       "for=10.0.0.5",
+				"X-Forwarded-For": "198.51.100.1",
+			},
+			want: "198.51.100.1",
+		},
+		{
+			name:            "Forwarded",
+			forwardedHeader: "Forwarded",
+			remoteAddr:      "10.0.0.1:1234",
+			headers: map[string]string{
+				"Forwarded":       `for=192.0.2.1;proto=https, for="[2001:db8::1]:4711", for=10.0.0.2`,
+				"X-Forwarded-For": "198.51.100.1",
+			},
+			want: "2001:db8::1",
+		},
+		{
+			name:            "client X-Forwarded-For",
+			forwardedHeader: "Forwarded",
+			remoteAddr:      "10.0.0.1:1234",
+			headers:         map[string]string{"X-Forwarded-For": "10.0.0.5"},
+			want:            "10.0.0.1",
+		},
+		{
+			name:       "only trusted proxies",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			want:       "10.0.0.3",
+		},
+		{
+			name:            "obfuscated hop",
+			forwardedHeader: "Forwarded",
+			remoteAddr:      "10.0.0.1:1234",
+			headers:         map[string]string{"Forwarded": "for=192.0.2.1, for=_hidden"},
+			want:            "10.0.0.1",
+		},
+		{
+			name:       "forged metadata",
+			remoteAddr: "198.51.100.1:1234",
+			headers:    map[string]string{"Grpc-Metadata-X-Client-Ip": "10.0.0.5"},
+			want:       "198.51.100.1",
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			mux := newMux(spec.forwardedHeader)
+			r := httptest.NewRequest("GET", "/v1/ip", nil)
+			r.RemoteAddr = spec.remoteAddr
+			for k, v := range spec.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("w.Code = %d; want %d", w.Code, http.StatusOK)
+			}
+			if !ip.Equal(net.ParseIP(spec.want)) {
+				t.Errorf("runtime.ClientIPFromContext(...) = %v; want %s", ip, spec.want)
+			}
+			if got := md.Get("x-client-ip"); len(got) != 1 || got[0] != spec.want {
+				t.Errorf(`md.Get("x-client-ip") = %q; want [%q]`, got, spec.want)
+			}
+		})
+	}
+}
+
+func TestIPFilter(t *testing.T) {
+	mux := runtime.NewServeMuxDynamic(
+		runtime.WithClientIP(runtime.ClientIPConfig{TrustedProxies: mustParseCIDRs(t, "10.0.0.0/8")}),
+		runtime.WithIPFilter(runtime.IPFilterConfig{Deny: mustParseCIDRs(t, "203.0.113.0/24")}),
+	)
+	handler := func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {}
+	mux.Handle("GET", mustPattern(t, "/v1/public"), handler)
+	mux.Handle("GET", mustPattern(t, "/v1/admin"), handler, runtime.WithRouteIPFilter(runtime.IPFilterConfig{
+		Allow: mustParseCIDRs(t, "192.0.2.0/24"),
+		Deny:  mustParseCIDRs(t, "192.0.2.13"),
+	}))
+
+	for _, spec := range []struct {
+		path      string
+		forwarded string
+		status    int
+	}{
+		{path: "/v1/public", forwarded: "198.51.100.1", status: http.StatusOK},
+		{path: "/v1/public", forwarded: "203.0.113.7", status: http.StatusForbidden},
+		{path: "/v1/admin", forwarded: "192.0.2.1", status: http.StatusOK},
+		{path: "/v1/admin", forwarded: "192.0.2.13", status: http.StatusForbidden},
+		{path: "/v1/admin", forwarded: "198.51.100.1", status: http.StatusForbidden},
+	} {
+		r := httptest.NewRequest("GET", spec.path, nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-For", spec.forwarded)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != spec.status {
+			t.Errorf("GET %s from %s: w.Code = %d; want %d", spec.path, spec.forwarded, w.Code, spec.status)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package runtime_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// The conformance suite sends the same requests to gateways serving the same
+// routes, and reports the differences of their responses from the ServeMux,
+// so that the ServeMuxDynamic keeps the routing semantics of the ServeMux.
+//
+// Setting GRPC_GATEWAY_CONFORMANCE_URL to the URL of another gateway, e.g. one
+// built with the upstream runtime package, adds it to the compared gateways.
+// It must serve conformanceRoutes with conformanceHandler.
+
+// conformanceRoute is a route of the gateways under test.
+type conformanceRoute struct {
+	method  string
+	pattern string
+}
+
+var conformanceRoutes = []conformanceRoute{
+	{method: "GET", pattern: "/v1/shelves"},
+	{method: "POST", pattern: "/v1/shelves"},
+	{method: "GET", pattern: "/v1/shelves/{shelf}"},
+	{method: "DELETE", pattern: "/v1/shelves/{shelf}"},
+	{method: "GET", pattern: "/v1/shelves/{shelf}/books/{book}"},
+	{method: "GET", pattern: "/v1/{name=shelves/*/books/*}:read"},
+	{method: "POST", pattern: "/v1/shelves/{shelf}:archive"},
+	{method: "GET", pattern: "/v1/files/{path=**}"},
+	{method: "GET", pattern: "/v1/users/{user}:verb:with:colons"},
+	// Registered last, so that it takes precedence over the route of the
+	// same pattern above.
+	{method: "GET", pattern: "/v1/shelves/{id}"},
+}
+
+// conformanceHandler replies with the route and the path parameters of the
+// request.
+func conformanceHandler(route conformanceRoute) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"route":  route.method + " " + route.pattern,
+			"method": r.Method,
+			"params": pathParams,
+		})
+	}
+}
+
+// conformanceRequest is a request of the suite.
+type conformanceRequest struct {
+	name   string
+	method string
+	path   string
+	header http.Header
+	body   string
+}
+
+var conformanceRequests = []conformanceRequest{
+	{name: "literal", method: "GET", path: "/v1/shelves"},
+	{name: "literal other method", method: "POST", path: "/v1/shelves"},
+	{name: "path parameter", method: "GET", path: "/v1/shelves/fiction"},
+	{name: "escaped path parameter", method: "GET", path: "/v1/shelves/sci%2Dfi"},
+	{name: "path parameters", method: "GET", path: "/v1/shelves/fiction/books/dune"},
+	{name: "verb", method: "GET", path: "/v1/shelves/fiction/books/dune:read"},
+	{name: "verb of a path parameter", method: "POST", path: "/v1/shelves/fiction:archive"},
+	{name: "empty verb", method: "POST", path: "/v1/shelves/fiction:"},
+	{name: "verb with colons", method: "GET", path: "/v1/users/alice:verb:with:colons"},
+	{name: "deep wildcard", method: "GET", path: "/v1/files/a/b/c.txt"},
+	{name: "trailing slash", method: "GET", path: "/v1/shelves/"},
+	{name: "unknown path", method: "GET", path: "/v2/shelves"},
+	{name: "method not allowed", method: "PATCH", path: "/v1/shelves/fiction"},
+	{name: "delete", method: "DELETE", path: "/v1/shelves/fiction"},
+	{
+		name:   "path length fallback",
+		method: "POST",
+		path:   "/v1/shelves/fiction/books/dune",
+		header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+		body:   "filter=long",
+	},
+	{
+		name:   "method override",
+		method: "POST",
+		path:   "/v1/shelves/fiction",
+		header: http.Header{
+			"Content-Type":           {"application/x-www-form-urlencoded"},
+			"X-Http-Method-Override": {"delete"},
+		},
+	},
+	{name: "accept", method: "GET", path: "/v1/shelves", header: http.Header{"Accept": {"application/json"}}},
+}
+
+// conformanceResponse is the part of the responses compared by the suite.
+type conformanceResponse struct {
+	Code        int
+	ContentType string
+	Body        interface{}
+}
+
+func newConformanceResponse(t *testing.T, code int, header http.Header, body []byte) conformanceResponse {
+	t.Helper()
+
+	resp := conformanceResponse{Code: code, ContentType: header.Get("Content-Type")}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &resp.Body); err != nil {
+			resp.Body = string(body)
+		}
+	}
+	return resp
+}
+
+// conformanceTarget is a gateway under test.
+type conformanceTarget struct {
+	name string
+	do   func(t *testing.T, req conformanceRequest) conformanceResponse
+}
+
+func handlerTarget(name string, h http.Handler) conformanceTarget {
+	return conformanceTarget{
+		name: name,
+		do: func(t *testing.T, req conformanceRequest) conformanceResponse {
+			r := httptest.NewRequest(req.method, req.path, strings.NewReader(req.body))
+			for key, values := range req.header {
+				r.Header[key] = values
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			return newConformanceResponse(t, w.Code, w.Header(), w.Body.Bytes())
+		},
+	}
+}
+
+func urlTarget(name, base string) conformanceTarget {
+	return conformanceTarget{
+		name: name,
+		do: func(t *testing.T, req conformanceRequest) conformanceResponse {
+			r, err := http.NewRequest(req.method, base+req.path, strings.NewReader(req.body))
+			if err != nil {
+				t.Fatalf("http.NewRequest(%q, %q) failed with %v; want success", req.method, req.path, err)
+			}
+			for key, values := range req.header {
+				r.Header[key] = values
+			}
+			resp, err := http.DefaultClient.Do(r)
+			if err != nil {
+				t.Fatalf("%s %s failed with %v; want success", req.method, r.URL, err)
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read the response of %s %s: %v", req.method, r.URL, err)
+			}
+			return newConformanceResponse(t, resp.StatusCode, resp.Header, body)
+		},
+	}
+}
+
+func conformanceTargets(t *testing.T, opts ...runtime.ServeMuxOption) (conformanceTarget, []conformanceTarget) {
+	mux := runtime.NewServeMux(opts...)
+	dynamic := runtime.NewServeMuxDynamic(opts...)
+	deregistered := runtime.NewServeMuxDynamic(opts...)
+	for _, route := range conformanceRoutes {
+		if err := mux.HandlePath(route.method, route.pattern, conformanceHandler(route)); err != nil {
+			t.Fatalf("mux.HandlePath(%q, %q) failed with %v; want success", route.method, route.pattern, err)
+		}
+		if err := dynamic.HandlePath(route.method, route.pattern, conformanceHandler(route)); err != nil {
+			t.Fatalf("dynamic.HandlePath(%q, %q) failed with %v; want success", route.method, route.pattern, err)
+		}
+		// Registering and deregistering other handlers must not change the
+		// routing of the remaining ones.
+		pat := runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"removed"}, ""))
+		remove := deregistered.HandleWithDeregister(route.method, pat, conformanceHandler(route))
+		if err := deregistered.HandlePath(route.method, route.pattern, conformanceHandler(route)); err != nil {
+			t.Fatalf("deregistered.HandlePath(%q, %q) failed with %v; want success", route.method, route.pattern, err)
+		}
+		remove()
+	}
+
+	targets := []conformanceTarget{
+		handlerTarget("ServeMuxDynamic", dynamic),
+		handlerTarget("ServeMuxDynamic with deregistered routes", deregistered),
+	}
+	if base := os.Getenv("GRPC_GATEWAY_CONFORMANCE_URL"); base != "" {
+		if _, err := url.Parse(base); err != nil {
+			t.Fatalf("GRPC_GATEWAY_CONFORMANCE_URL=%q is not a URL: %v", base, err)
+		}
+		targets = append(targets, urlTarget(base, strings.TrimSuffix(base, "/")))
+	}
+	return handlerTarget("ServeMux", mux), targets
+}
+
+func TestConformance(t *testing.T) {
+	for _, spec := range []struct {
+		name string
+		opts []runtime.ServeMuxOption
+	}{
+		{name: "default"},
+		{name: "route cache", opts: []runtime.ServeMuxOption{runtime.WithRouteCache(16)}},
+		{name: "no path length fallback", opts: []runtime.ServeMuxOption{runtime.WithDisablePathLengthFallback()}},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			reference, targets := conformanceTargets(t, spec.opts...)
+			for _, req := range conformanceRequests {
+				t.Run(req.name, func(t *testing.T) {
+					// The requests are sent twice to compare the responses
+					// served from the route cache.
+					for i := 0; i < 2; i++ {
+						want := reference.do(t, req)
+						for _, target := range targets {
+							got := target.do(t, req)
+							if diff := cmp.Diff(want, got); diff != "" {
+								t.Errorf("%s %s to %s differed from %s (-%s +%s):\n%s", req.method, req.path, target.name, reference.name, reference.name, target.name, diff)
+							}
+						}
+					}
+				})
+			}
+		})
+	}
+}
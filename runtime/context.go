@@ -86,6 +86,12 @@ func AnnotateIncomingContext(ctx context.Context, mux *ServeMux, req *http.Reque
 
 func annotateContext(ctx context.Context, mux *ServeMux, req *http.Request, rpcMethodName string) (context.Context, metadata.MD, error) {
 	ctx = withRPCMethod(ctx, rpcMethodName)
+	if mux.requestValidator != nil {
+		ctx = withRequestValidator(ctx, mux.requestValidator)
+	}
+	if mux.requestDefaults != nil {
+		ctx = withRequestDefaults(ctx, mux.requestDefaults)
+	}
 	var pairs []string
 	timeout := DefaultContextTimeout
 	if tm := req.Header.Get(metadataGrpcTimeout); tm != "" {
@@ -96,6 +102,7 @@ func annotateContext(ctx context.Context, mux *ServeMux, req *http.Request, rpcM
 		}
 	}
 
+	incomingHeaderMatcher := mux.incomingHeaderMatcherFor(ctx)
 	for key, vals := range req.Header {
 		key = textproto.CanonicalMIMEHeaderKey(key)
 		for _, val := range vals {
@@ -103,7 +110,7 @@ func annotateContext(ctx context.Context, mux *ServeMux, req *http.Request, rpcM
 			if key == "Authorization" {
 				pairs = append(pairs, "authorization", val)
 			}
-			if h, ok := mux.incomingHeaderMatcher(key); ok {
+			if h, ok := incomingHeaderMatcher(key); ok {
 				// Handles "-bin" metadata in grpc, since grpc will do another base64
 				// encode before sending to server, we need to decode it first.
 				if strings.HasSuffix(key, metadataHeaderBinarySuffix) {
@@ -145,6 +152,11 @@ func annotateContext(ctx context.Context, mux *ServeMux, req *http.Request, rpcM
 	for _, mda := range mux.metadataAnnotators {
 		md = metadata.Join(md, mda(ctx, req))
 	}
+	if o := routeOptionsFromContext(ctx); o != nil {
+		for _, mda := range o.metadataAnnotators {
+			md = metadata.Join(md, mda(ctx, req))
+		}
+	}
 	return ctx, md, nil
 }
 
@@ -0,0 +1,144 @@
+package runtime
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultCSRFCookieName = "csrf_token"
+	defaultCSRFHeaderName = "X-CSRF-Token"
+	// defaultCSRFTag is the default tag of the routes CSRF protection applies to.
+	defaultCSRFTag = "browser"
+)
+
+// CSRFMode is how requests prove that they are not cross-site request
+// forgeries, see CSRFConfig.
+type CSRFMode int
+
+const (
+	// CSRFDoubleSubmitCookie requires requests to repeat the value of the
+	// CSRF cookie in the CSRF header. Cross-site requests cannot read the
+	// cookie. The cookie is set by the mux on responses to requests without
+	// one.
+	CSRFDoubleSubmitCookie CSRFMode = iota
+	// CSRFCustomHeader requires requests to carry the CSRF header with any
+	// value. Cross-site requests cannot set custom headers without passing a
+	// CORS preflight.
+	CSRFCustomHeader
+)
+
+// CSRFConfig configures the protection against cross-site request forgery,
+// see WithCSRFProtection.
+type CSRFConfig struct {
+	// Mode is how requests are protected.
+	Mode CSRFMode
+	// CookieName is the name of the CSRF cookie. It defaults to "csrf_token".
+	CookieName string
+	// HeaderName is the name of the CSRF header. It defaults to "X-CSRF-Token".
+	HeaderName string
+	// Tags are the tags of the routes which are protected, see
+	// WithRouteTags. They default to "browser".
+	Tags []string
+}
+
+// WithCSRFProtection returns a ServeMuxOption which protects the routes tagged
+// with one of config.Tags against cross-site request forgery. Requests to
+// them with unsafe methods, all but GET, HEAD, OPTIONS and TRACE, are
+// rejected with codes.PermissionDenied through the error handler unless they
+// pass the check of config.Mode.
+//
+// CORS preflight requests are never rejected, so they are answered the same
+// way for protected and unprotected routes.
+func WithCSRFProtection(config CSRFConfig) ServeMuxOption {
+	return func(mux *ServeMux) {
+		if len(config.Tags) == 0 {
+			config.Tags = []string{defaultCSRFTag}
+		}
+		c := newCSRFProtection(config)
+		mux.authenticators = append(mux.authenticators, c.authenticate)
+	}
+}
+
+type csrfProtection struct {
+	config CSRFConfig
+}
+
+func newCSRFProtection(config CSRFConfig) *csrfProtection {
+	if config.CookieName == "" {
+		config.CookieName = defaultCSRFCookieName
+	}
+	if config.HeaderName == "" {
+		config.HeaderName = defaultCSRFHeaderName
+	}
+	return &csrfProtection{config: config}
+}
+
+func (c *csrfProtection) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, error) {
+	if !routeHasTag(r.Context(), c.config.Tags) {
+		return r, nil
+	}
+	var cookie string
+	if c.config.Mode == CSRFDoubleSubmitCookie {
+		if ck, err := r.Cookie(c.config.CookieName); err == nil && ck.Value != "" {
+			cookie = ck.Value
+		} else if err := c.setCookie(w, r); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to issue CSRF token: %v", err)
+		}
+	}
+	if isSafeMethod(r.Method) {
+		return r, nil
+	}
+	if err := c.verify(r, cookie); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// verify checks that r passes the check of the mode of c, with the value
+// "cookie" of its CSRF cookie.
+func (c *csrfProtection) verify(r *http.Request, cookie string) error {
+	header := r.Header.Get(c.config.HeaderName)
+	switch c.config.Mode {
+	case CSRFCustomHeader:
+		if header == "" {
+			return status.Errorf(codes.PermissionDenied, "missing %s header", c.config.HeaderName)
+		}
+	default:
+		if cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+			return status.Errorf(codes.PermissionDenied, "%s header does not match the CSRF cookie", c.config.HeaderName)
+		}
+	}
+	return nil
+}
+
+// setCookie issues a new CSRF token in the CSRF cookie.
+func (c *csrfProtection) setCookie(w http.ResponseWriter, r *http.Request) error {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.config.CookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(token),
+		Path:     "/",
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
+
+// isSafeMethod reports whether method is a safe HTTP method, which does not
+// change the state of the server.
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
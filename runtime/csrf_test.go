@@ -0,0 +1,91 @@
+package runtime_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func newCSRFTestMux(t *testing.T, config runtime.CSRFConfig) *runtime.ServeMuxDynamic {
+	mux := runtime.NewServeMuxDynamic(runtime.WithCSRFProtection(config))
+	handler := func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {}
+	books := mustPattern(t, "/v1/books")
+	mux.Handle("GET", books, handler, runtime.WithRouteTags("browser"))
+	mux.Handle("OPTIONS", books, handler, runtime.WithRouteTags("browser"))
+	mux.Handle("POST", books, handler, runtime.WithRouteTags("browser"))
+	mux.Handle("POST", mustPattern(t, "/v1/internal"), handler)
+	return mux
+}
+
+func TestCSRFDoubleSubmitCookie(t *testing.T) {
+	mux := newCSRFTestMux(t, runtime.CSRFConfig{Mode: runtime.CSRFDoubleSubmitCookie})
+
+	// A safe request is issued a token.
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/books", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET: w.Code = %d; want %d", w.Code, http.StatusOK)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "csrf_token" || cookies[0].Value == "" {
+		t.Fatalf("GET: cookies = %v; want a csrf_token cookie", cookies)
+	}
+	token := cookies[0]
+
+	for _, spec := range []struct {
+		name   string
+		method string
+		path   string
+		cookie bool
+		header string
+		status int
+	}{
+		{name: "matching header", method: "POST", path: "/v1/books", cookie: true, header: token.Value, status: http.StatusOK},
+		{name: "mismatching header", method: "POST", path: "/v1/books", cookie: true, header: "forged", status: http.StatusForbidden},
+		{name: "missing header", method: "POST", path: "/v1/books", cookie: true, status: http.StatusForbidden},
+		{name: "missing cookie", method: "POST", path: "/v1/books", header: token.Value, status: http.StatusForbidden},
+		{name: "preflight", method: "OPTIONS", path: "/v1/books", status: http.StatusOK},
+		{name: "untagged route", method: "POST", path: "/v1/internal", status: http.StatusOK},
+	} {
+		r := httptest.NewRequest(spec.method, spec.path, nil)
+		if spec.cookie {
+			r.AddCookie(token)
+		}
+		if spec.header != "" {
+			r.Header.Set("X-CSRF-Token", spec.header)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != spec.status {
+			t.Errorf("%s: w.Code = %d; want %d", spec.name, w.Code, spec.status)
+		}
+	}
+}
+
+func TestCSRFCustomHeader(t *testing.T) {
+	mux := newCSRFTestMux(t, runtime.CSRFConfig{Mode: runtime.CSRFCustomHeader, HeaderName: "X-Requested-With"})
+	for _, spec := range []struct {
+		method string
+		header string
+		status int
+	}{
+		{method: "POST", header: "XMLHttpRequest", status: http.StatusOK},
+		{method: "POST", status: http.StatusForbidden},
+		{method: "GET", status: http.StatusOK},
+	} {
+		r := httptest.NewRequest(spec.method, "/v1/books", nil)
+		if spec.header != "" {
+			r.Header.Set("X-Requested-With", spec.header)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != spec.status {
+			t.Errorf("%s with header %q: w.Code = %d; want %d", spec.method, spec.header, w.Code, spec.status)
+		}
+		if cookies := w.Result().Cookies(); len(cookies) != 0 {
+			t.Errorf("%s with header %q: cookies = %v; want none", spec.method, spec.header, cookies)
+		}
+	}
+}
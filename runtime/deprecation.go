@@ -0,0 +1,80 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// Deprecation describes the deprecation of a method, replied in the headers of
+// the responses of its routes, see WithDeprecation.
+type Deprecation struct {
+	// Date is when the method was deprecated, replied as the Deprecation
+	// header of RFC 9745. The header is "true" if Date is zero.
+	Date time.Time
+	// Sunset is when the method is expected to stop responding, replied as
+	// the Sunset header of RFC 8594 unless it is zero.
+	Sunset time.Time
+	// Link is the URL of documentation on the deprecation, e.g. a migration
+	// guide, replied as a Link header with the "deprecation" relation type
+	// unless it is empty.
+	Link string
+}
+
+// DeprecatedMethodHook is called for every request to a deprecated method,
+// with the full name of the method, e.g. "/example.v1.Service/Method".
+type DeprecatedMethodHook func(ctx context.Context, req *http.Request, rpcMethod string)
+
+// WithDeprecation returns a ServeMuxOption replying the deprecation "d" of the
+// method "rpcMethod", e.g. "/example.v1.Service/Method", in the headers of its
+// routes. It applies to the methods marked deprecated in their proto files,
+// with the handlers generated with deprecation_headers.
+func WithDeprecation(rpcMethod string, d Deprecation) ServeMuxOption {
+	return func(mux *ServeMux) {
+		if mux.deprecations == nil {
+			mux.deprecations = make(map[string]Deprecation)
+		}
+		mux.deprecations[rpcMethod] = d
+	}
+}
+
+// WithDeprecatedMethodHook returns a ServeMuxOption calling hook for every
+// request to a deprecated method, instead of logging a warning, e.g. to count
+// the remaining callers. A nil hook disables the warnings.
+func WithDeprecatedMethodHook(hook DeprecatedMethodHook) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.deprecatedMethodHook = hook
+	}
+}
+
+// DefaultDeprecatedMethodHook logs a warning for every request to a deprecated
+// method.
+func DefaultDeprecatedMethodHook(ctx context.Context, req *http.Request, rpcMethod string) {
+	grpclog.Warningf("Deprecated method %s called with %s %s by %s", rpcMethod, req.Method, req.URL.Path, req.UserAgent())
+}
+
+// MarkDeprecated sets the deprecation headers of the response of a request to
+// the deprecated method of "ctx", and calls the deprecated method hook of
+// "mux". The handlers generated with deprecation_headers call it.
+func MarkDeprecated(ctx context.Context, mux *ServeMux, w http.ResponseWriter, req *http.Request) {
+	rpcMethod, _ := RPCMethod(ctx)
+	d := mux.deprecations[rpcMethod]
+	if d.Date.IsZero() {
+		w.Header().Set("Deprecation", "true")
+	} else {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(d.Date.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=%q", d.Link, "deprecation"))
+	}
+	if mux.deprecatedMethodHook != nil {
+		mux.deprecatedMethodHook(ctx, req, rpcMethod)
+	}
+}
@@ -0,0 +1,89 @@
+package runtime_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestMarkDeprecated(t *testing.T) {
+	const rpcMethod = "/example.v1.Service/Method"
+	for _, spec := range []struct {
+		name       string
+		opts       []runtime.ServeMuxOption
+		wantHeader http.Header
+	}{
+		{
+			name: "no deprecation",
+			wantHeader: http.Header{
+				"Deprecation": []string{"true"},
+			},
+		},
+		{
+			name: "deprecation of another method",
+			opts: []runtime.ServeMuxOption{
+				runtime.WithDeprecation("/example.v1.Service/Other", runtime.Deprecation{Link: "https://example.com/other"}),
+			},
+			wantHeader: http.Header{
+				"Deprecation": []string{"true"},
+			},
+		},
+		{
+			name: "full deprecation",
+			opts: []runtime.ServeMuxOption{
+				runtime.WithDeprecation(rpcMethod, runtime.Deprecation{
+					Date:   time.Date(2023, 6, 30, 23, 59, 59, 0, time.UTC),
+					Sunset: time.Date(2024, 1, 1, 0, 0, 0, 0, time.FixedZone("CET", 3600)),
+					Link:   "https://example.com/migration",
+				}),
+			},
+			wantHeader: http.Header{
+				"Deprecation": []string{"@1688169599"},
+				"Sunset":      []string{"Sun, 31 Dec 2023 23:00:00 GMT"},
+				"Link":        []string{`<https://example.com/migration>; rel="deprecation"`},
+			},
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			var calls []string
+			opts := append(spec.opts, runtime.WithDeprecatedMethodHook(func(ctx context.Context, req *http.Request, rpcMethod string) {
+				calls = append(calls, rpcMethod)
+			}))
+			mux := runtime.NewServeMux(opts...)
+			req := httptest.NewRequest("GET", "http://example.com/v1/method", nil)
+			ctx, err := runtime.AnnotateContext(context.Background(), mux, req, rpcMethod)
+			if err != nil {
+				t.Fatalf("runtime.AnnotateContext() failed with %v; want success", err)
+			}
+			w := httptest.NewRecorder()
+			runtime.MarkDeprecated(ctx, mux, w, req)
+			for key, want := range spec.wantHeader {
+				if got := w.Header()[key]; len(got) != 1 || got[0] != want[0] {
+					t.Errorf("w.Header()[%q] = %q; want %q", key, got, want)
+				}
+			}
+			for _, key := range []string{"Sunset", "Link"} {
+				if _, ok := spec.wantHeader[key]; !ok && w.Header().Get(key) != "" {
+					t.Errorf("w.Header().Get(%q) = %q; want none", key, w.Header().Get(key))
+				}
+			}
+			if len(calls) != 1 || calls[0] != rpcMethod {
+				t.Errorf("hook called with %q; want once with %q", calls, rpcMethod)
+			}
+		})
+	}
+}
+
+func TestMarkDeprecatedWithoutHook(t *testing.T) {
+	mux := runtime.NewServeMux(runtime.WithDeprecatedMethodHook(nil))
+	req := httptest.NewRequest("GET", "http://example.com/v1/method", nil)
+	w := httptest.NewRecorder()
+	runtime.MarkDeprecated(context.Background(), mux, w, req)
+	if got, want := w.Header().Get("Deprecation"), "true"; got != want {
+		t.Errorf(`w.Header().Get("Deprecation") = %q; want %q`, got, want)
+	}
+}
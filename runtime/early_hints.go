@@ -0,0 +1,40 @@
+package runtime
+
+import (
+	"net/http"
+)
+
+// WithRouteEarlyHints returns a RouteOption sending a "103 Early Hints"
+// response with a Link header of each of "links" before the route is
+// handled, so that browsers can preconnect to origins and preload resources
+// while the upstream call is in progress, e.g.
+//
+//	WithRouteEarlyHints("<https://cdn.example.com>; rel=preconnect", "</app.css>; rel=preload; as=style")
+//
+// The Link headers are also sent with the final response.
+func WithRouteEarlyHints(links ...string) RouteOption {
+	return func(o *routeOptions) {
+		o.earlyHints = append(o.earlyHints, links...)
+	}
+}
+
+// WriteEarlyHints sends a "103 Early Hints" response with a Link header of
+// each of "links", which handlers may call before the final response is
+// written. It does nothing if "links" is empty, or if the program is built
+// with a version of Go older than 1.19, whose net/http cannot send
+// informational responses.
+func WriteEarlyHints(w http.ResponseWriter, links ...string) {
+	if len(links) == 0 {
+		return
+	}
+	for _, link := range links {
+		w.Header().Add("Link", link)
+	}
+	writeInformational(w, http.StatusEarlyHints)
+}
+
+// isInformational reports whether "code" is the status of an informational
+// response which is followed by the final response.
+func isInformational(code int) bool {
+	return code >= 100 && code < 200 && code != http.StatusSwitchingProtocols
+}
@@ -0,0 +1,60 @@
+//go:build go1.19
+// +build go1.19
+
+package runtime_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"reflect"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestRouteEarlyHints(t *testing.T) {
+	links := []string{"<https://cdn.example.com>; rel=preconnect", "</app.css>; rel=preload; as=style"}
+	mux := runtime.NewServeMuxDynamic(runtime.WithServerTiming())
+	err := mux.HandlePath("GET", "/v1/slow", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		w.Write([]byte("{}"))
+	}, runtime.WithRouteEarlyHints(links...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var hints []textproto.MIMEHeader
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = append(hints, header)
+			}
+			return nil
+		},
+	}
+	req, err := http.NewRequest("GET", srv.URL+"/v1/slow", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(hints) != 1 {
+		t.Fatalf("got %d early hints responses; want 1", len(hints))
+	}
+	if got := hints[0]["Link"]; !reflect.DeepEqual(got, links) {
+		t.Errorf("early hints Link = %q; want %q", got, links)
+	}
+	if hints[0].Get("Server-Timing") != "" {
+		t.Errorf("early hints Server-Timing = %q; want none", hints[0].Get("Server-Timing"))
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Server-Timing") == "" {
+		t.Errorf("response = %d %v; want %d with a Server-Timing header", resp.StatusCode, resp.Header, http.StatusOK)
+	}
+}
@@ -2,6 +2,7 @@ package runtime
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
@@ -67,19 +68,46 @@ func HTTPStatusFromCode(code codes.Code) int {
 
 // HTTPError uses the mux-configured error handler.
 func HTTPError(ctx context.Context, mux *ServeMux, marshaler Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	mux.recordUpstreamError(ctx, err)
 	mux.errorHandler(ctx, mux, marshaler, w, r, err)
 }
 
+// HTTPStatusError is the error to use when needing to provide a different HTTP status code for an error
+// passed to the DefaultRoutingErrorHandler.
+type HTTPStatusError struct {
+	HTTPStatus int
+	Err        error
+}
+
+func (e *HTTPStatusError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *HTTPStatusError) Unwrap() error {
+	return e.Err
+}
+
 // DefaultHTTPErrorHandler is the default error handler.
 // If "err" is a gRPC Status, the function replies with the status code mapped by HTTPStatusFromCode.
+// If "err" is a HTTPStatusError, the function replies with the status code provide by that struct. This is
+// intended to allow passing through of specific statuses via the function set via WithRoutingErrorHandler
+// for the ServeMux constructor to handle edge cases which the standard mappings in HTTPStatusFromCode
+// are insufficient for.
 // If otherwise, it replies with http.StatusInternalServerError.
 //
-// The response body written by this function is a Status message marshaled by the Marshaler.
+// The response body written by this function is a Status message marshaled by the Marshaler,
+// redacted by the Redactor configured with WithLogRedaction.
 func DefaultHTTPErrorHandler(ctx context.Context, mux *ServeMux, marshaler Marshaler, w http.ResponseWriter, r *http.Request, err error) {
 	// return Internal when Marshal failed
 	const fallback = `{"code": 13, "message": "failed to marshal error message"}`
 
-	s := status.Convert(err)
+	var customStatus *HTTPStatusError
+	if errors.As(err, &customStatus) {
+		err = customStatus.Err
+	}
+
+	s := mux.logRedaction.Status(status.Convert(err))
 	pb := s.Proto()
 
 	w.Header().Del("Trailer")
@@ -97,13 +125,14 @@ func DefaultHTTPErrorHandler(ctx context.Context, mux *ServeMux, marshaler Marsh
 		}
 		return
 	}
+	buf = mux.withTraceIDField(ctx, buf, contentType)
 
 	md, ok := ServerMetadataFromContext(ctx)
 	if !ok {
 		grpclog.Infof("Failed to extract ServerMetadata from context")
 	}
 
-	handleForwardResponseServerMetadata(w, mux, md)
+	handleForwardResponseServerMetadata(ctx, w, mux, md)
 
 	// RFC 7230 https://tools.ietf.org/html/rfc7230#section-4.1.2
 	// Unless the request includes a TE header field indicating "trailers"
@@ -114,18 +143,21 @@ func DefaultHTTPErrorHandler(ctx context.Context, mux *ServeMux, marshaler Marsh
 
 	if te := r.Header.Get("TE"); strings.Contains(strings.ToLower(te), "trailers") {
 		wantsTrailers = true
-		handleForwardResponseTrailerHeader(w, md)
+		handleForwardResponseTrailerHeader(ctx, w, mux, md)
 		w.Header().Set("Transfer-Encoding", "chunked")
 	}
 
 	st := HTTPStatusFromCode(s.Code())
+	if customStatus != nil {
+		st = customStatus.HTTPStatus
+	}
 	w.WriteHeader(st)
 	if _, err := w.Write(buf); err != nil {
 		grpclog.Infof("Failed to write response: %v", err)
 	}
 
 	if wantsTrailers {
-		handleForwardResponseTrailer(w, md)
+		handleForwardResponseTrailer(ctx, w, mux, md)
 	}
 }
 
@@ -138,6 +170,7 @@ func DefaultStreamErrorHandler(_ context.Context, err error) *status.Status {
 //   NotFound -> grpc.NotFound
 //   StatusBadRequest -> grpc.InvalidArgument
 //   MethodNotAllowed -> grpc.Unimplemented
+//   NotAcceptable -> grpc.InvalidArgument, replying with http.StatusNotAcceptable
 //   Other -> grpc.Internal, method is not expecting to be called for anything else
 func DefaultRoutingErrorHandler(ctx context.Context, mux *ServeMux, marshaler Marshaler, w http.ResponseWriter, r *http.Request, httpStatus int) {
 	sterr := status.Error(codes.Internal, "Unexpected routing error")
@@ -148,6 +181,11 @@ func DefaultRoutingErrorHandler(ctx context.Context, mux *ServeMux, marshaler Ma
 		sterr = status.Error(codes.Unimplemented, http.StatusText(httpStatus))
 	case http.StatusNotFound:
 		sterr = status.Error(codes.NotFound, http.StatusText(httpStatus))
+	case http.StatusNotAcceptable:
+		sterr = &HTTPStatusError{
+			HTTPStatus: httpStatus,
+			Err:        status.Error(codes.InvalidArgument, http.StatusText(httpStatus)),
+		}
 	}
 	mux.errorHandler(ctx, mux, marshaler, w, r, sterr)
 }
@@ -0,0 +1,30 @@
+package runtime
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WithExpectContinue returns a ServeMuxOption replying "100 Continue" to the
+// requests with an "Expect: 100-continue" header as soon as they are routed,
+// authenticated and authorized, before their handler is called, so that
+// clients start sending large bodies, e.g. to client-streaming or HttpBody
+// routes, while the upstream call is being set up.
+//
+// Requests rejected before are replied with their final status without "100
+// Continue", so their bodies are never sent. Without this option, net/http
+// replies "100 Continue" when the handler first reads the body, which also
+// happens after these checks. It requires Go 1.19 or later.
+func WithExpectContinue() ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.expectContinue = true
+	}
+}
+
+// sendContinue replies "100 Continue" to "r" if it expects it and
+// WithExpectContinue is enabled.
+func (s *ServeMux) sendContinue(w http.ResponseWriter, r *http.Request) {
+	if s.expectContinue && r.ProtoAtLeast(1, 1) && strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
+		writeInformational(w, http.StatusContinue)
+	}
+}
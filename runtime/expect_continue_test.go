@@ -0,0 +1,84 @@
+//go:build go1.19
+// +build go1.19
+
+package runtime_test
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestExpectContinue(t *testing.T) {
+	mux := runtime.NewServeMuxDynamic(runtime.WithExpectContinue())
+	upload := func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("ioutil.ReadAll(r.Body) failed with %v; want success", err)
+		}
+		w.Write(body)
+	}
+	if err := mux.HandlePath("POST", "/v1/uploads", upload); err != nil {
+		t.Fatal(err)
+	}
+	deny := runtime.WithRouteIPFilter(runtime.IPFilterConfig{Deny: []*net.IPNet{{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)}}})
+	if err := mux.HandlePath("POST", "/v1/denied", upload, deny); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	send := func(path string) (*bufio.Reader, net.Conn) {
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		req := "POST " + path + " HTTP/1.1\r\nHost: example.com\r\nContent-Length: 5\r\nExpect: 100-continue\r\n\r\n"
+		if _, err := conn.Write([]byte(req)); err != nil {
+			t.Fatal(err)
+		}
+		return bufio.NewReader(conn), conn
+	}
+
+	br, conn := send("/v1/uploads")
+	defer conn.Close()
+	line, err := br.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "HTTP/1.1 100 ") {
+		t.Fatalf("first response line = %q, %v; want 100 Continue", line, err)
+	}
+	for line != "\r\n" {
+		if line, err = br.ReadString('\n'); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Errorf("response = %d %q; want %d %q", resp.StatusCode, body, http.StatusOK, "hello")
+	}
+
+	// The body of the rejected request is never sent.
+	br, conn = send("/v1/denied")
+	defer conn.Close()
+	resp, err = http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("resp.StatusCode = %d; want %d without 100 Continue", resp.StatusCode, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,154 @@
+package runtime
+
+import (
+	"context"
+	"hash/fnv"
+	"net/http"
+)
+
+// Experiment is an A/B experiment of a route, see WithRouteExperiment.
+type Experiment struct {
+	// Name is the name of the experiment, e.g. "checkout-v2".
+	Name string
+	// Variants are the variants of the experiment. The first one is the
+	// control, which requests not assigned to another variant are
+	// dispatched to.
+	Variants []Variant
+	// Header is the name of a request header whose value is the name of the
+	// variant to dispatch to, e.g. to force a variant while testing.
+	Header string
+	// Cookie is the name of a request cookie whose value is the name of the
+	// variant to dispatch to, if Header is empty or the request does not
+	// carry a variant in it.
+	Cookie string
+	// ClientID returns a stable identifier of the client of a request, e.g.
+	// a user ID, whose hash assigns the requests carrying no variant in
+	// Header or Cookie to a variant, in proportion to their weights, so that
+	// every client consistently sees the same variant. Requests are
+	// dispatched to the control if it is nil or returns "".
+	ClientID func(r *http.Request) string
+	// ResponseHeader is the name of the response header replying the variant
+	// a request was dispatched to. It defaults to "X-Experiment-Variant".
+	ResponseHeader string
+	// OnAssign, if not nil, is called for every request with the variant it
+	// is dispatched to, e.g. to count the requests of each variant.
+	OnAssign func(ctx context.Context, route RouteInfo, experiment, variant string)
+}
+
+// Variant is a variant of an Experiment.
+type Variant struct {
+	// Name is the name of the variant, e.g. "control" or "treatment".
+	Name string
+	// Weight is the relative share of the requests assigned by client ID to
+	// the variant. Variants with a zero weight are only dispatched to when
+	// requested by header or cookie.
+	Weight int
+	// Handler handles the requests dispatched to the variant, e.g. by
+	// forwarding them to another upstream. The requests are handled by the
+	// handler of the route if it is nil.
+	Handler HandlerFunc
+}
+
+// WithRouteExperiment returns a RouteOption dispatching the requests to the
+// route to the variants of the experiment "e". The variant a request is
+// dispatched to is replied in a response header, as "<experiment>=<variant>",
+// and available to the handlers with ExperimentVariantFromContext.
+func WithRouteExperiment(e Experiment) RouteOption {
+	return func(o *routeOptions) {
+		if len(e.Variants) == 0 {
+			return
+		}
+		if e.ResponseHeader == "" {
+			e.ResponseHeader = "X-Experiment-Variant"
+		}
+		o.experiment = &e
+	}
+}
+
+type experimentVariantKey struct{}
+
+type experimentVariant struct {
+	experiment, variant string
+}
+
+// ExperimentVariantFromContext returns the experiment of the route the
+// request of "ctx" was dispatched to, and the variant it was dispatched to.
+func ExperimentVariantFromContext(ctx context.Context) (experiment, variant string, ok bool) {
+	v, ok := ctx.Value(experimentVariantKey{}).(experimentVariant)
+	return v.experiment, v.variant, ok
+}
+
+// variantFor returns the variant of e which "r" is assigned to.
+func (e *Experiment) variantFor(r *http.Request) Variant {
+	if e.Header != "" {
+		if v, ok := e.variantNamed(r.Header.Get(e.Header)); ok {
+			return v
+		}
+	}
+	if e.Cookie != "" {
+		if c, err := r.Cookie(e.Cookie); err == nil {
+			if v, ok := e.variantNamed(c.Value); ok {
+				return v
+			}
+		}
+	}
+	if e.ClientID == nil {
+		return e.Variants[0]
+	}
+	id := e.ClientID(r)
+	if id == "" {
+		return e.Variants[0]
+	}
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+	if total <= 0 {
+		return e.Variants[0]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(e.Name))
+	h.Write([]byte{0})
+	h.Write([]byte(id))
+	n := int(h.Sum32() % uint32(total))
+	for _, v := range e.Variants {
+		if n < v.Weight {
+			return v
+		}
+		n -= v.Weight
+	}
+	return e.Variants[0]
+}
+
+func (e *Experiment) variantNamed(name string) (Variant, bool) {
+	if name == "" {
+		return Variant{}, false
+	}
+	for _, v := range e.Variants {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return Variant{}, false
+}
+
+// handleExperiment dispatches "r" to the variant of the experiment of the
+// route h it is assigned to, if any, or to h.
+func (s *ServeMux) handleExperiment(w http.ResponseWriter, r *http.Request, h handler, pathParams map[string]string) {
+	if h.opts == nil || h.opts.experiment == nil {
+		h.h(w, r, pathParams)
+		return
+	}
+	e := h.opts.experiment
+	v := e.variantFor(r)
+	w.Header().Set(e.ResponseHeader, e.Name+"="+v.Name)
+	r = r.WithContext(context.WithValue(r.Context(), experimentVariantKey{}, experimentVariant{experiment: e.Name, variant: v.Name}))
+	if e.OnAssign != nil {
+		e.OnAssign(r.Context(), h.routeInfo(r.Method), e.Name, v.Name)
+	}
+	if v.Handler != nil {
+		v.Handler(w, r, pathParams)
+		return
+	}
+	h.h(w, r, pathParams)
+}
@@ -0,0 +1,107 @@
+package runtime_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func newExperimentTestMux(t *testing.T, e runtime.Experiment) *runtime.ServeMuxDynamic {
+	mux := runtime.NewServeMuxDynamic()
+	err := mux.HandlePath("GET", "/v1/checkout", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, variant, _ := runtime.ExperimentVariantFromContext(r.Context())
+		fmt.Fprintf(w, "route %s", variant)
+	}, runtime.WithRouteExperiment(e))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mux
+}
+
+func TestRouteExperiment(t *testing.T) {
+	assigned := make(map[string]int)
+	mux := newExperimentTestMux(t, runtime.Experiment{
+		Name: "checkout-v2",
+		Variants: []runtime.Variant{
+			{Name: "control"},
+			{Name: "treatment", Handler: func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+				w.Write([]byte("treatment handler"))
+			}},
+		},
+		Header: "X-Variant",
+		Cookie: "variant",
+		OnAssign: func(_ context.Context, route runtime.RouteInfo, experiment, variant string) {
+			if route.Pattern != "/v1/checkout" || experiment != "checkout-v2" {
+				t.Errorf("OnAssign(%v, %q, %q); want the route and experiment", route, experiment, variant)
+			}
+			assigned[variant]++
+		},
+	})
+
+	for _, spec := range []struct {
+		name   string
+		header string
+		cookie string
+		body   string
+		want   string
+	}{
+		{name: "default", body: "route control", want: "checkout-v2=control"},
+		{name: "header", header: "treatment", body: "treatment handler", want: "checkout-v2=treatment"},
+		{name: "cookie", cookie: "treatment", body: "treatment handler", want: "checkout-v2=treatment"},
+		{name: "header over cookie", header: "control", cookie: "treatment", body: "route control", want: "checkout-v2=control"},
+		{name: "unknown variant", header: "unknown", body: "route control", want: "checkout-v2=control"},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/v1/checkout", nil)
+			if spec.header != "" {
+				r.Header.Set("X-Variant", spec.header)
+			}
+			if spec.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "variant", Value: spec.cookie})
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if got := w.Body.String(); got != spec.body {
+				t.Errorf("w.Body = %q; want %q", got, spec.body)
+			}
+			if got := w.Header().Get("X-Experiment-Variant"); got != spec.want {
+				t.Errorf("X-Experiment-Variant = %q; want %q", got, spec.want)
+			}
+		})
+	}
+	if assigned["control"] != 3 || assigned["treatment"] != 2 {
+		t.Errorf("assigned = %v; want 3 control and 2 treatment", assigned)
+	}
+}
+
+func TestRouteExperimentClientID(t *testing.T) {
+	mux := newExperimentTestMux(t, runtime.Experiment{
+		Name:     "checkout-v2",
+		Variants: []runtime.Variant{{Name: "a", Weight: 1}, {Name: "b", Weight: 1}, {Name: "forced"}},
+		ClientID: func(r *http.Request) string { return r.Header.Get("X-User") },
+	})
+
+	variantOf := func(user string) string {
+		r := httptest.NewRequest("GET", "/v1/checkout", nil)
+		r.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Header().Get("X-Experiment-Variant")
+	}
+	counts := make(map[string]int)
+	for i := 0; i < 200; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		v := variantOf(user)
+		if again := variantOf(user); again != v {
+			t.Fatalf("user %s was assigned %q then %q; want a stable variant", user, v, again)
+		}
+		counts[v]++
+	}
+	if counts["checkout-v2=a"] < 50 || counts["checkout-v2=b"] < 50 || counts["checkout-v2=forced"] != 0 {
+		t.Errorf("counts = %v; want an even split between a and b", counts)
+	}
+}
@@ -0,0 +1,92 @@
+package runtime
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// FakeMethod records the requests of a method of the fake servers generated by
+// protoc-gen-grpc-gateway with generate_fakes=true, and replies to them with a
+// canned response. The zero value replies with an empty response message.
+// A FakeMethod is safe for concurrent use.
+type FakeMethod struct {
+	mu       sync.Mutex
+	requests []proto.Message
+	resp     proto.Message
+	err      error
+	handler  func(context.Context, proto.Message) (proto.Message, error)
+}
+
+// SetResponse makes the method reply with a copy of "resp".
+func (f *FakeMethod) SetResponse(resp proto.Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resp, f.err, f.handler = resp, nil, nil
+}
+
+// SetError makes the method fail with "err", which should be a gRPC status
+// error to be replied with the corresponding HTTP status code.
+func (f *FakeMethod) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resp, f.err, f.handler = nil, err, nil
+}
+
+// SetHandler makes the method reply with the result of "handler", which is
+// called with the decoded request message.
+func (f *FakeMethod) SetHandler(handler func(ctx context.Context, req proto.Message) (proto.Message, error)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resp, f.err, f.handler = nil, nil, handler
+}
+
+// Requests returns copies of the request messages the method was called with,
+// in the order of the calls.
+func (f *FakeMethod) Requests() []proto.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reqs := make([]proto.Message, len(f.requests))
+	for i, req := range f.requests {
+		reqs[i] = proto.Clone(req)
+	}
+	return reqs
+}
+
+// LastRequest returns a copy of the request message of the last call of the
+// method, or nil if it was not called.
+func (f *FakeMethod) LastRequest() proto.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.requests) == 0 {
+		return nil
+	}
+	return proto.Clone(f.requests[len(f.requests)-1])
+}
+
+// Reset forgets the recorded requests and the canned response.
+func (f *FakeMethod) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests, f.resp, f.err, f.handler = nil, nil, nil, nil
+}
+
+// Call records "req" and returns the canned response, or a new message
+// returned by "newResp" if there is none. Generated fake servers call it.
+func (f *FakeMethod) Call(ctx context.Context, req proto.Message, newResp func() proto.Message) (proto.Message, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, proto.Clone(req))
+	resp, err, handler := f.resp, f.err, f.handler
+	f.mu.Unlock()
+
+	switch {
+	case handler != nil:
+		return handler(ctx, req)
+	case err != nil:
+		return nil, err
+	case resp != nil:
+		return proto.Clone(resp), nil
+	}
+	return newResp(), nil
+}
@@ -0,0 +1,80 @@
+package runtime_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestFakeMethod(t *testing.T) {
+	var f runtime.FakeMethod
+	ctx := context.Background()
+	newResp := func() proto.Message { return new(wrapperspb.StringValue) }
+	call := func(in string) (proto.Message, error) {
+		return f.Call(ctx, wrapperspb.String(in), newResp)
+	}
+
+	if got := f.LastRequest(); got != nil {
+		t.Errorf("f.LastRequest() = %v before any call; want nil", got)
+	}
+	resp, err := call("a")
+	if err != nil {
+		t.Fatalf("f.Call() failed with %v; want success", err)
+	}
+	if !proto.Equal(resp, new(wrapperspb.StringValue)) {
+		t.Errorf("f.Call() = %v; want an empty message", resp)
+	}
+
+	canned := wrapperspb.String("canned")
+	f.SetResponse(canned)
+	resp, err = call("b")
+	if err != nil {
+		t.Fatalf("f.Call() failed with %v; want success", err)
+	}
+	if !proto.Equal(resp, canned) || resp == canned {
+		t.Errorf("f.Call() = %v; want a copy of %v", resp, canned)
+	}
+
+	f.SetError(status.Error(codes.NotFound, "not found"))
+	if _, err := call("c"); status.Code(err) != codes.NotFound {
+		t.Errorf("f.Call() failed with %v; want code %v", err, codes.NotFound)
+	}
+
+	f.SetHandler(func(_ context.Context, req proto.Message) (proto.Message, error) {
+		return wrapperspb.String("re: " + req.(*wrapperspb.StringValue).GetValue()), nil
+	})
+	resp, err = call("d")
+	if err != nil {
+		t.Fatalf("f.Call() failed with %v; want success", err)
+	}
+	if want := wrapperspb.String("re: d"); !proto.Equal(resp, want) {
+		t.Errorf("f.Call() = %v; want %v", resp, want)
+	}
+
+	reqs := f.Requests()
+	if len(reqs) != 4 {
+		t.Fatalf("len(f.Requests()) = %d; want 4", len(reqs))
+	}
+	for i, want := range []string{"a", "b", "c", "d"} {
+		if got := reqs[i].(*wrapperspb.StringValue).GetValue(); got != want {
+			t.Errorf("f.Requests()[%d] = %q; want %q", i, got, want)
+		}
+	}
+	if want := wrapperspb.String("d"); !proto.Equal(f.LastRequest(), want) {
+		t.Errorf("f.LastRequest() = %v; want %v", f.LastRequest(), want)
+	}
+
+	f.Reset()
+	if got := f.Requests(); len(got) != 0 {
+		t.Errorf("f.Requests() = %v after f.Reset(); want none", got)
+	}
+	resp, err = call("e")
+	if err != nil || !proto.Equal(resp, new(wrapperspb.StringValue)) {
+		t.Errorf("f.Call() = %v, %v after f.Reset(); want an empty message", resp, err)
+	}
+}
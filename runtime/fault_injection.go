@@ -0,0 +1,123 @@
+package runtime
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/status"
+)
+
+// FaultRule is a rule of WithFaultInjection. It applies to the routes
+// matching all of Method, Pattern and Tag which are not empty.
+type FaultRule struct {
+	// Method is the HTTP method of the routes the rule applies to.
+	Method string
+	// Pattern is the path template of the routes the rule applies to, as
+	// registered, e.g. "/v1/{name=books/*}".
+	Pattern string
+	// Tag is a tag of the routes the rule applies to, see WithRouteTags.
+	Tag string
+	// Condition further restricts the requests the rule applies to, if it
+	// is not nil, e.g. to the requests carrying a header.
+	Condition func(ctx context.Context, route RouteInfo, r *http.Request) bool
+	// Percentage is the percentage of the requests the rule applies to into
+	// which the faults are injected, from 0 to 100.
+	Percentage float64
+	// Delay delays the requests before they are forwarded, or aborted.
+	Delay time.Duration
+	// Abort fails the requests with a status of this code through the error
+	// handler instead of forwarding them, unless it is codes.OK.
+	Abort codes.Code
+	// Corrupt cuts every write of the response bodies to its first half, so
+	// that clients receive malformed responses.
+	Corrupt bool
+}
+
+// WithFaultInjection returns a ServeMuxOption injecting faults into the
+// requests to the routes "rules" apply to, to test how clients cope with
+// slow and failing backends. The first of the rules applying to a route is
+// used. Rules with invalid patterns are logged and ignored.
+func WithFaultInjection(rules ...FaultRule) ServeMuxOption {
+	return func(mux *ServeMux) {
+		for i, rule := range rules {
+			if rule.Pattern != "" {
+				// Normalize the template into the form of Pattern.String.
+				pattern, err := parsePattern(rule.Pattern)
+				if err != nil {
+					grpclog.Errorf("Ignoring fault rule %d with invalid pattern %q: %v", i, rule.Pattern, err)
+					continue
+				}
+				rule.Pattern = pattern.String()
+			}
+			mux.faultRules = append(mux.faultRules, rule)
+		}
+	}
+}
+
+func (rule FaultRule) appliesTo(route RouteInfo) bool {
+	return (rule.Method == "" || rule.Method == route.Method) &&
+		(rule.Pattern == "" || rule.Pattern == route.Pattern) &&
+		(rule.Tag == "" || route.HasTag(rule.Tag))
+}
+
+// injectFaults injects the faults of the first fault rule applying to r,
+// dispatched to h. It returns the ResponseWriter to reply with, or false if
+// r was aborted.
+func (s *ServeMux) injectFaults(w http.ResponseWriter, r *http.Request, h handler) (http.ResponseWriter, bool) {
+	if len(s.faultRules) == 0 {
+		return w, true
+	}
+	route := h.routeInfo(r.Method)
+	for _, rule := range s.faultRules {
+		if !rule.appliesTo(route) || (rule.Condition != nil && !rule.Condition(r.Context(), route, r)) {
+			continue
+		}
+		if rand.Float64()*100 >= rule.Percentage {
+			return w, true
+		}
+		if rule.Delay > 0 {
+			t := time.NewTimer(rule.Delay)
+			select {
+			case <-t.C:
+			case <-r.Context().Done():
+				t.Stop()
+				_, outboundMarshaler := MarshalerForRequest(s, r)
+				s.errorHandler(r.Context(), s, outboundMarshaler, w, r, status.FromContextError(r.Context().Err()).Err())
+				return nil, false
+			}
+		}
+		if rule.Abort != codes.OK {
+			_, outboundMarshaler := MarshalerForRequest(s, r)
+			s.errorHandler(r.Context(), s, outboundMarshaler, w, r, status.Errorf(rule.Abort, "fault injected into %s %s", route.Method, route.Pattern))
+			return nil, false
+		}
+		if rule.Corrupt {
+			w.Header().Del("Content-Length")
+			return &corruptingResponseWriter{ResponseWriter: w}, true
+		}
+		return w, true
+	}
+	return w, true
+}
+
+// corruptingResponseWriter cuts every write to its first half.
+type corruptingResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *corruptingResponseWriter) Write(p []byte) (int, error) {
+	if _, err := w.ResponseWriter.Write(p[:len(p)/2]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *corruptingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
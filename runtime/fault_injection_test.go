@@ -0,0 +1,98 @@
+package runtime_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+)
+
+func newFaultInjectionTestMux(t *testing.T, rules ...runtime.FaultRule) *runtime.ServeMux {
+	mux := runtime.NewServeMux(runtime.WithFaultInjection(rules...))
+	for _, pattern := range []string{"/v1/books/{id}", "/v1/shelves/{id}"} {
+		err := mux.HandlePath("GET", pattern, func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+			w.Write([]byte(`{"id": "1"}`))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mux
+}
+
+func TestFaultInjectionAbort(t *testing.T) {
+	mux := newFaultInjectionTestMux(t, runtime.FaultRule{Pattern: "/v1/books/{id}", Percentage: 100, Abort: codes.Unavailable})
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/books/1", nil))
+	if got, want := w.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("w.Code = %d; want %d (%s)", got, want, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/shelves/1", nil))
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Errorf("w.Code = %d; want %d for a route without faults", got, want)
+	}
+}
+
+func TestFaultInjectionPercentage(t *testing.T) {
+	mux := newFaultInjectionTestMux(t, runtime.FaultRule{Method: "GET", Percentage: 0, Abort: codes.Internal})
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/books/1", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("w.Code = %d; want %d with a percentage of 0", w.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestFaultInjectionDelay(t *testing.T) {
+	mux := newFaultInjectionTestMux(t, runtime.FaultRule{Pattern: "/v1/books/{id}", Percentage: 100, Delay: 20 * time.Millisecond})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/books/1", nil))
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("request took %v; want at least 20ms", elapsed)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("w.Code = %d; want %d", w.Code, http.StatusOK)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/books/1", nil).WithContext(ctx))
+	if got, want := w.Code, runtime.HTTPStatusFromCode(codes.Canceled); got != want {
+		t.Errorf("w.Code = %d; want %d for a canceled request", got, want)
+	}
+}
+
+func TestFaultInjectionCorrupt(t *testing.T) {
+	mux := newFaultInjectionTestMux(t, runtime.FaultRule{
+		Percentage: 100,
+		Corrupt:    true,
+		Condition: func(_ context.Context, _ runtime.RouteInfo, r *http.Request) bool {
+			return r.Header.Get("X-Inject-Faults") != ""
+		},
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/v1/books/1", nil)
+	r.Header.Set("X-Inject-Faults", "1")
+	mux.ServeHTTP(w, r)
+	if got, want := w.Body.String(), `{"id"`; got != want {
+		t.Errorf("w.Body = %q; want %q", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/books/1", nil))
+	if got, want := w.Body.String(), `{"id": "1"}`; got != want {
+		t.Errorf("w.Body = %q; want %q without the header", got, want)
+	}
+}
@@ -0,0 +1,357 @@
+/*
+Package gatewaytest provides an in-memory gateway for testing gateway
+configurations: a ServeMux, or a ServeMuxDynamic, proxying to an in-process
+gRPC server over a bufconn listener, served by an httptest.Server.
+
+The gRPC server records the requests it receives, decoded into their proto
+messages, and can be made to fail methods with injected statuses instead of
+calling the registered services.
+
+	gw := gatewaytest.New(t,
+		func(s *grpc.Server) { pb.RegisterEchoServiceServer(s, &echoServer{}) },
+		pb.RegisterEchoServiceHandler,
+	)
+	resp, _ := gw.Do(t, "POST", "/v1/echo/1", `{"msg": "hello"}`)
+	gw.AssertRequest(t, "/example.EchoService/Echo", &pb.SimpleMessage{Id: "1", Msg: "hello"})
+*/
+package gatewaytest
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// bufSize is the size of the buffers of the in-memory connections.
+const bufSize = 1024 * 1024
+
+// RegisterServerFunc registers the services of the gRPC server, e.g. with
+// the generated RegisterXServer functions.
+type RegisterServerFunc func(s *grpc.Server)
+
+// RegisterGatewayFunc registers the handlers of the gateway proxying to
+// "conn", which has the signature of the generated RegisterXHandler
+// functions.
+type RegisterGatewayFunc func(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error
+
+// Option configures a Gateway.
+type Option func(*options)
+
+type options struct {
+	muxOpts    []runtime.ServeMuxOption
+	serverOpts []grpc.ServerOption
+	dialOpts   []grpc.DialOption
+	dynamic    bool
+}
+
+// WithServeMuxOptions configures the ServeMux of the gateway with "opts".
+func WithServeMuxOptions(opts ...runtime.ServeMuxOption) Option {
+	return func(o *options) {
+		o.muxOpts = append(o.muxOpts, opts...)
+	}
+}
+
+// WithServerOptions configures the gRPC server with "opts".
+func WithServerOptions(opts ...grpc.ServerOption) Option {
+	return func(o *options) {
+		o.serverOpts = append(o.serverOpts, opts...)
+	}
+}
+
+// WithDialOptions configures the connection of the gateway to the gRPC
+// server with "opts".
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dialOpts = append(o.dialOpts, opts...)
+	}
+}
+
+// WithDynamic makes the gateway serve a ServeMuxDynamic, which is available
+// as Gateway.Dynamic for adding and removing handlers while testing.
+func WithDynamic() Option {
+	return func(o *options) {
+		o.dynamic = true
+	}
+}
+
+// Call is a request received by the gRPC server.
+type Call struct {
+	// Method is the full gRPC method name, e.g. "/example.EchoService/Echo".
+	Method string
+	// Request is the request message, or the messages of the client stream
+	// in the order of receipt for client and bidirectional streaming
+	// methods.
+	Request []proto.Message
+	// Metadata is the incoming metadata of the call.
+	Metadata metadata.MD
+}
+
+// Gateway is a gateway proxying to an in-process gRPC server. The embedded
+// httptest.Server serves the gateway.
+type Gateway struct {
+	*httptest.Server
+
+	// Mux is the ServeMux of the gateway.
+	Mux *runtime.ServeMux
+	// Dynamic is the ServeMuxDynamic of the gateway, or nil without
+	// WithDynamic.
+	Dynamic *runtime.ServeMuxDynamic
+	// Conn is the connection of the gateway to the gRPC server.
+	Conn *grpc.ClientConn
+	// GRPCServer is the in-process gRPC server.
+	GRPCServer *grpc.Server
+
+	mu       sync.Mutex
+	calls    []*Call
+	statuses map[string]*status.Status
+}
+
+// New starts a gRPC server with the services registered by "registerServer"
+// and a gateway proxying to it with the handlers registered by
+// "registerGateway". They are stopped when the test finishes.
+func New(t testing.TB, registerServer RegisterServerFunc, registerGateway RegisterGatewayFunc, opts ...Option) *Gateway {
+	t.Helper()
+
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	g := &Gateway{statuses: make(map[string]*status.Status)}
+
+	lis := bufconn.Listen(bufSize)
+	serverOpts := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(g.unaryInterceptor),
+		grpc.ChainStreamInterceptor(g.streamInterceptor),
+	}, o.serverOpts...)
+	g.GRPCServer = grpc.NewServer(serverOpts...)
+	registerServer(g.GRPCServer)
+	go g.GRPCServer.Serve(lis)
+	t.Cleanup(g.GRPCServer.Stop)
+
+	ctx := context.Background()
+	dialOpts := append([]grpc.DialOption{
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithInsecure(),
+	}, o.dialOpts...)
+	conn, err := grpc.DialContext(ctx, "bufnet", dialOpts...)
+	if err != nil {
+		t.Fatalf("failed to dial the gRPC server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	g.Conn = conn
+
+	var handler http.Handler
+	if o.dynamic {
+		g.Dynamic = runtime.NewServeMuxDynamic(o.muxOpts...)
+		g.Mux = g.Dynamic.ServeMux
+		handler = g.Dynamic
+	} else {
+		g.Mux = runtime.NewServeMux(o.muxOpts...)
+		handler = g.Mux
+	}
+	if err := registerGateway(ctx, g.Mux, conn); err != nil {
+		t.Fatalf("failed to register the gateway: %v", err)
+	}
+
+	g.Server = httptest.NewServer(handler)
+	t.Cleanup(g.Server.Close)
+	return g
+}
+
+// InjectStatus makes the gRPC server fail the calls of "method", a full gRPC
+// method name, with "st" without calling the service. A nil "st" removes
+// the injected status.
+func (g *Gateway) InjectStatus(method string, st *status.Status) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if st == nil {
+		delete(g.statuses, method)
+		return
+	}
+	g.statuses[method] = st
+}
+
+// Calls returns the calls the gRPC server received, in the order of their
+// start.
+func (g *Gateway) Calls() []Call {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	calls := make([]Call, len(g.calls))
+	for i, c := range g.calls {
+		calls[i] = c.clone()
+	}
+	return calls
+}
+
+// LastCall returns the last call of "method", a full gRPC method name, the
+// gRPC server received.
+func (g *Gateway) LastCall(method string) (Call, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i := len(g.calls) - 1; i >= 0; i-- {
+		if g.calls[i].Method == method {
+			return g.calls[i].clone(), true
+		}
+	}
+	return Call{}, false
+}
+
+// Reset forgets the received calls and the injected statuses.
+func (g *Gateway) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls = nil
+	g.statuses = make(map[string]*status.Status)
+}
+
+// Do sends a request with "method", "path" and, unless empty, the JSON
+// "body" to the gateway, and returns the response with its read body.
+func (g *Gateway) Do(t testing.TB, method, path, body string) (*http.Response, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, g.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("http.NewRequest(%q, %q) failed with %v; want success", method, path, err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := g.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed with %v; want success", method, path, err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read the response of %s %s: %v", method, path, err)
+	}
+	return resp, b
+}
+
+// AssertRequest reports an error unless the last call of "method", a full
+// gRPC method name, received the request message "want".
+func (g *Gateway) AssertRequest(t testing.TB, method string, want proto.Message) {
+	t.Helper()
+
+	call, ok := g.LastCall(method)
+	if !ok {
+		t.Errorf("%s was not called; want a call with %v", method, want)
+		return
+	}
+	if len(call.Request) != 1 || !proto.Equal(call.Request[0], want) {
+		t.Errorf("%s was called with %v; want %v", method, call.Request, want)
+	}
+}
+
+// AssertNotCalled reports an error if the gRPC server received a call of
+// "method", a full gRPC method name.
+func (g *Gateway) AssertNotCalled(t testing.TB, method string) {
+	t.Helper()
+
+	if call, ok := g.LastCall(method); ok {
+		t.Errorf("%s was called with %v; want no call", method, call.Request)
+	}
+}
+
+// AssertCode reports an error unless "resp" and its body "body", as returned
+// by Do, are the error response of the gateway for "code": the status code
+// mapped by runtime.HTTPStatusFromCode and a JSON status with the code.
+func AssertCode(t testing.TB, resp *http.Response, body []byte, code codes.Code) {
+	t.Helper()
+
+	if got, want := resp.StatusCode, runtime.HTTPStatusFromCode(code); got != want {
+		t.Errorf("resp.StatusCode = %d; want %d for %v", got, want, code)
+	}
+	if code == codes.OK {
+		return
+	}
+	var st struct {
+		Code codes.Code `json:"code"`
+	}
+	if err := json.Unmarshal(body, &st); err != nil {
+		t.Errorf("json.Unmarshal(%s) failed with %v; want a status", body, err)
+		return
+	}
+	if st.Code != code {
+		t.Errorf("status code of %s = %v; want %v", body, st.Code, code)
+	}
+}
+
+func (g *Gateway) record(ctx context.Context, method string) (*Call, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	call := &Call{Method: method, Metadata: md.Copy()}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls = append(g.calls, call)
+	if st, ok := g.statuses[method]; ok {
+		return call, st.Err()
+	}
+	return call, nil
+}
+
+func (g *Gateway) addRequest(call *Call, req interface{}) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	call.Request = append(call.Request, proto.Clone(msg))
+}
+
+func (g *Gateway) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	call, err := g.record(ctx, info.FullMethod)
+	g.addRequest(call, req)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (g *Gateway) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	call, err := g.record(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &recordingStream{ServerStream: ss, g: g, call: call})
+}
+
+// recordingStream records the messages received on a server stream.
+type recordingStream struct {
+	grpc.ServerStream
+	g    *Gateway
+	call *Call
+}
+
+func (s *recordingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.g.addRequest(s.call, m)
+	return nil
+}
+
+func (c *Call) clone() Call {
+	reqs := make([]proto.Message, len(c.Request))
+	for i, req := range c.Request {
+		reqs[i] = proto.Clone(req)
+	}
+	return Call{Method: c.Method, Request: reqs, Metadata: c.Metadata.Copy()}
+}
@@ -0,0 +1,107 @@
+package gatewaytest_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime/gatewaytest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const checkMethod = "/grpc.health.v1.Health/Check"
+
+// registerHealthHandler registers a handler of GET /v1/health/{service}
+// proxying to the Check method of "conn", like the generated handlers do.
+func registerHealthHandler(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+	client := healthpb.NewHealthClient(conn)
+	return mux.HandlePath("GET", "/v1/health/{service}", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		_, outboundMarshaler := runtime.MarshalerForRequest(mux, r)
+		ctx, err := runtime.AnnotateContext(r.Context(), mux, r, checkMethod)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outboundMarshaler, w, r, err)
+			return
+		}
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: pathParams["service"]})
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outboundMarshaler, w, r, err)
+			return
+		}
+		runtime.ForwardResponseMessage(ctx, mux, outboundMarshaler, w, r, resp)
+	})
+}
+
+func newHealthGateway(t *testing.T, opts ...gatewaytest.Option) *gatewaytest.Gateway {
+	srv := health.NewServer()
+	srv.SetServingStatus("books", healthpb.HealthCheckResponse_SERVING)
+	return gatewaytest.New(t, func(s *grpc.Server) { healthpb.RegisterHealthServer(s, srv) }, registerHealthHandler, opts...)
+}
+
+func TestGateway(t *testing.T) {
+	gw := newHealthGateway(t)
+
+	resp, body := gw.Do(t, "GET", "/v1/health/books", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("resp.StatusCode = %d; want %d (%s)", resp.StatusCode, http.StatusOK, body)
+	}
+	if !strings.Contains(string(body), "SERVING") {
+		t.Errorf("body = %s; want the SERVING status", body)
+	}
+	gw.AssertRequest(t, checkMethod, &healthpb.HealthCheckRequest{Service: "books"})
+
+	call, ok := gw.LastCall(checkMethod)
+	if !ok {
+		t.Fatalf("gw.LastCall(%q) = _, false; want the call", checkMethod)
+	}
+	if got := call.Metadata.Get("x-forwarded-host"); len(got) == 0 {
+		t.Errorf("call.Metadata = %v; want the metadata annotated by the gateway", call.Metadata)
+	}
+}
+
+func TestGatewayInjectStatus(t *testing.T) {
+	gw := newHealthGateway(t)
+
+	gw.InjectStatus(checkMethod, status.New(codes.Unavailable, "injected"))
+	resp, body := gw.Do(t, "GET", "/v1/health/books", "")
+	gatewaytest.AssertCode(t, resp, body, codes.Unavailable)
+	gw.AssertRequest(t, checkMethod, &healthpb.HealthCheckRequest{Service: "books"})
+
+	gw.InjectStatus(checkMethod, nil)
+	resp, body = gw.Do(t, "GET", "/v1/health/unknown", "")
+	gatewaytest.AssertCode(t, resp, body, codes.NotFound)
+
+	gw.Reset()
+	gw.AssertNotCalled(t, checkMethod)
+	if calls := gw.Calls(); len(calls) != 0 {
+		t.Errorf("gw.Calls() = %v; want no calls after Reset", calls)
+	}
+}
+
+func TestGatewayDynamic(t *testing.T) {
+	gw := newHealthGateway(t, gatewaytest.WithDynamic())
+	if gw.Dynamic == nil {
+		t.Fatalf("gw.Dynamic = nil; want a ServeMuxDynamic")
+	}
+
+	pat := runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"ping"}, ""))
+	deregister := gw.Dynamic.HandleWithDeregister("GET", pat, func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	if resp, _ := gw.Do(t, "GET", "/ping", ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("resp.StatusCode = %d; want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	deregister()
+	if resp, _ := gw.Do(t, "GET", "/ping", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("resp.StatusCode = %d; want %d after deregistering", resp.StatusCode, http.StatusNotFound)
+	}
+
+	if resp, body := gw.Do(t, "GET", "/v1/health/books", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("resp.StatusCode = %d; want %d (%s)", resp.StatusCode, http.StatusOK, body)
+	}
+}
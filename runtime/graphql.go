@@ -0,0 +1,689 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// WithGraphQLPath returns a ServeMuxOption making a ServeMuxDynamic serve a
+// GraphQL endpoint at "path", e.g. "/graphql", whose schema is derived from
+// the services registered with ServeMuxDynamic.RegisterGraphQLService. It has
+// no effect on a ServeMux.
+//
+// Queries are accepted with GET and POST, and mutations with POST only.
+// Requests which cannot be executed, e.g. because of a syntax error or a
+// field missing from the schema, are rejected with http.StatusBadRequest.
+// The errors of the fields, e.g. the errors replied by the routes resolving
+// them, are replied in the "errors" member of the response along with the
+// "data", with the name of their gRPC code in "extensions.code".
+// Introspection and subscriptions are not supported. Request bodies are
+// limited to 1 MiB.
+func WithGraphQLPath(path string) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.graphQLPath = path
+	}
+}
+
+// graphQLMaxRequestSize bounds the size of the bodies of GraphQL requests.
+const graphQLMaxRequestSize = 1 << 20
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type graphQLResponse struct {
+	Data   interface{}    `json:"data,omitempty"`
+	Errors []graphQLError `json:"errors,omitempty"`
+}
+
+type graphQLError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// graphQLObject is an object of a GraphQL response, whose members are
+// marshaled in the order of the selection set.
+type graphQLObject []graphQLMember
+
+type graphQLMember struct {
+	key   string
+	value interface{}
+}
+
+func (o graphQLObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// serveGraphQL serves the GraphQL endpoint of s.
+func (s *ServeMuxDynamic) serveGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			dec := json.NewDecoder(strings.NewReader(v))
+			dec.UseNumber()
+			if err := dec.Decode(&req.Variables); err != nil {
+				writeGraphQLError(w, http.StatusBadRequest, fmt.Sprintf("invalid variables: %v", err))
+				return
+			}
+		}
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, graphQLMaxRequestSize)
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/graphql" {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				writeGraphQLError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			req.Query = string(body)
+			break
+		}
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&req); err != nil {
+			writeGraphQLError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if req.Query == "" {
+		writeGraphQLError(w, http.StatusBadRequest, "missing query")
+		return
+	}
+
+	doc, err := parseGraphQL(req.Query)
+	if err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if op.kind == "mutation" && r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeGraphQLError(w, http.StatusMethodNotAllowed, "mutations must be sent with POST")
+		return
+	}
+
+	p := &graphQLPlanner{schema: s.graphQLSchema(), doc: doc}
+	calls, err := p.plan(op, req.Variables)
+	if err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	data, errs := s.executeGraphQL(r, calls)
+	writeGraphQL(w, http.StatusOK, graphQLResponse{Data: data, Errors: errs})
+}
+
+func writeGraphQL(w http.ResponseWriter, code int, resp graphQLResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		grpclog.Infof("Failed to write GraphQL response: %v", err)
+	}
+}
+
+func writeGraphQLError(w http.ResponseWriter, code int, message string) {
+	writeGraphQL(w, code, graphQLResponse{Errors: []graphQLError{{Message: message}}})
+}
+
+// operation returns the operation named "name" of doc, or its only
+// operation if "name" is empty.
+func (doc *graphQLDocument) operation(name string) (*graphQLOperation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required for documents with several operations")
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("operation %q is not defined", name)
+}
+
+// graphQLCall is a field of the Query or Mutation type selected by an
+// operation, resolved by a call to the route of its method.
+type graphQLCall struct {
+	key string
+	// field is nil for __typename.
+	field    *graphQLRootField
+	typeName string
+	params   map[string]interface{}
+	// fields is the selection set of the result, which is nil for scalars.
+	fields []graphQLSelection
+}
+
+// graphQLSelection is a field of an object type selected by an operation.
+type graphQLSelection struct {
+	key string
+	// field is nil for __typename.
+	field    protoreflect.FieldDescriptor
+	typeName string
+	fields   []graphQLSelection
+}
+
+// graphQLPlanner validates the operations of a document against a schema,
+// and plans their execution.
+type graphQLPlanner struct {
+	schema    *graphQLSchema
+	doc       *graphQLDocument
+	variables map[string]interface{}
+}
+
+// plan returns the calls of the fields selected by "op" with "variables".
+func (p *graphQLPlanner) plan(op *graphQLOperation, variables map[string]interface{}) ([]graphQLCall, error) {
+	if err := p.checkFragmentCycles(); err != nil {
+		return nil, err
+	}
+
+	// The variables which are declared, but neither given nor defaulted, are
+	// null.
+	p.variables = make(map[string]interface{}, len(op.variables))
+	for _, v := range op.variables {
+		value, ok := variables[v.name]
+		if !ok && v.hasDefault {
+			var err error
+			if value, err = resolveGraphQLValue(v.defaultValue, nil); err != nil {
+				return nil, err
+			}
+		}
+		p.variables[v.name] = value
+	}
+
+	var roots []*graphQLRootField
+	typeName := "Query"
+	switch op.kind {
+	case "query":
+		roots = p.schema.query
+	case "mutation":
+		roots, typeName = p.schema.mutation, "Mutation"
+	default:
+		return nil, fmt.Errorf("%s operations are not supported", op.kind)
+	}
+
+	nodes, err := p.collectFields(typeName, op.selections)
+	if err != nil {
+		return nil, err
+	}
+	calls := make([]graphQLCall, 0, len(nodes))
+	for _, n := range nodes {
+		call := graphQLCall{key: n.responseKey(), typeName: typeName}
+		switch n.name {
+		case "__typename":
+			calls = append(calls, call)
+			continue
+		case "__schema", "__type":
+			return nil, fmt.Errorf("introspection is not supported")
+		}
+		for _, f := range roots {
+			if f.name == n.name {
+				call.field = f
+			}
+		}
+		if call.field == nil {
+			return nil, fmt.Errorf("field %q is not defined on type %s", n.name, typeName)
+		}
+
+		input := call.field.method.Input()
+		call.params = make(map[string]interface{})
+		for _, arg := range n.arguments {
+			fd := input.Fields().ByJSONName(arg.name)
+			if _, ok := graphQLScalarMessage(input); ok || fd == nil {
+				return nil, fmt.Errorf("unknown argument %q of field %q", arg.name, n.name)
+			}
+			value, err := resolveGraphQLValue(arg.value, p.variables)
+			if err != nil {
+				return nil, err
+			}
+			if value, err = p.inputValue(fd, value); err != nil {
+				return nil, fmt.Errorf("argument %q of field %q: %v", arg.name, n.name, err)
+			}
+			if value != nil {
+				call.params[string(fd.Name())] = value
+			}
+		}
+
+		output := call.field.method.Output()
+		if typ, ok := graphQLScalarMessage(output); ok {
+			if len(n.selections) > 0 {
+				return nil, fmt.Errorf("field %q of type %s must not have a selection set", n.name, typ)
+			}
+		} else {
+			if len(n.selections) == 0 {
+				return nil, fmt.Errorf("field %q of type %s must have a selection set", n.name, p.schema.names[output.FullName()])
+			}
+			if call.fields, err = p.planSelections(output, n.selections); err != nil {
+				return nil, err
+			}
+		}
+		calls = append(calls, call)
+	}
+	return calls, nil
+}
+
+// planSelections returns the fields of the message "md" selected by "nodes".
+func (p *graphQLPlanner) planSelections(md protoreflect.MessageDescriptor, nodes []graphQLSelectionNode) ([]graphQLSelection, error) {
+	typeName := p.schema.names[md.FullName()]
+	nodes, err := p.collectFields(typeName, nodes)
+	if err != nil {
+		return nil, err
+	}
+	selections := make([]graphQLSelection, 0, len(nodes))
+	for _, n := range nodes {
+		sel := graphQLSelection{key: n.responseKey(), typeName: typeName}
+		if n.name == "__typename" {
+			selections = append(selections, sel)
+			continue
+		}
+		sel.field = md.Fields().ByJSONName(n.name)
+		if sel.field == nil {
+			return nil, fmt.Errorf("field %q is not defined on type %s", n.name, typeName)
+		}
+		if len(n.arguments) > 0 {
+			return nil, fmt.Errorf("field %q of type %s has no arguments", n.name, typeName)
+		}
+
+		object := !sel.field.IsMap() && (sel.field.Kind() == protoreflect.MessageKind || sel.field.Kind() == protoreflect.GroupKind)
+		if object {
+			_, scalar := graphQLScalarMessage(sel.field.Message())
+			object = !scalar
+		}
+		switch {
+		case object && len(n.selections) == 0:
+			return nil, fmt.Errorf("field %q of type %s must have a selection set", n.name, typeName)
+		case !object && len(n.selections) > 0:
+			return nil, fmt.Errorf("field %q of type %s must not have a selection set", n.name, typeName)
+		case object:
+			if sel.fields, err = p.planSelections(sel.field.Message(), n.selections); err != nil {
+				return nil, err
+			}
+		}
+		selections = append(selections, sel)
+	}
+	return selections, nil
+}
+
+// collectFields returns the fields of "nodes" which apply to the type
+// "typeName", expanding the fragments and merging the fields of the same
+// response key.
+func (p *graphQLPlanner) collectFields(typeName string, nodes []graphQLSelectionNode) ([]graphQLSelectionNode, error) {
+	var fields []graphQLSelectionNode
+	var collect func(nodes []graphQLSelectionNode) error
+	collect = func(nodes []graphQLSelectionNode) error {
+		for _, n := range nodes {
+			include, err := p.included(n.directives)
+			if err != nil {
+				return err
+			}
+			if !include {
+				continue
+			}
+
+			switch {
+			case n.fragment != "":
+				f, ok := p.doc.fragments[n.fragment]
+				if !ok {
+					return fmt.Errorf("fragment %q is not defined", n.fragment)
+				}
+				if err := p.checkTypeCondition(f.typeCondition); err != nil {
+					return err
+				}
+				if f.typeCondition == typeName {
+					if err := collect(f.selections); err != nil {
+						return err
+					}
+				}
+			case n.inline:
+				if n.typeCondition != "" {
+					if err := p.checkTypeCondition(n.typeCondition); err != nil {
+						return err
+					}
+				}
+				if n.typeCondition == "" || n.typeCondition == typeName {
+					if err := collect(n.selections); err != nil {
+						return err
+					}
+				}
+			default:
+				merged := false
+				for i := range fields {
+					if fields[i].responseKey() != n.responseKey() {
+						continue
+					}
+					if fields[i].name != n.name {
+						return fmt.Errorf("fields %q and %q conflict because they are both replied as %q", fields[i].name, n.name, n.responseKey())
+					}
+					selections := fields[i].selections
+					fields[i].selections = append(selections[:len(selections):len(selections)], n.selections...)
+					merged = true
+				}
+				if !merged {
+					fields = append(fields, n)
+				}
+			}
+		}
+		return nil
+	}
+	if err := collect(nodes); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// checkTypeCondition checks that the type condition "typeName" of a fragment
+// is a type of the schema.
+func (p *graphQLPlanner) checkTypeCondition(typeName string) error {
+	if typeName == "Query" || typeName == "Mutation" {
+		return nil
+	}
+	for _, md := range p.schema.messages {
+		if p.schema.names[md.FullName()] == typeName {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown type %q", typeName)
+}
+
+// checkFragmentCycles checks that the fragments of the document do not
+// spread themselves.
+func (p *graphQLPlanner) checkFragmentCycles() error {
+	state := make(map[string]int) // 1 while visiting, 2 once visited.
+	var visit func(name string) error
+	var visitNodes func(nodes []graphQLSelectionNode) error
+	visit = func(name string) error {
+		f, ok := p.doc.fragments[name]
+		if !ok || state[name] == 2 {
+			return nil
+		}
+		if state[name] == 1 {
+			return fmt.Errorf("fragment %q spreads itself", name)
+		}
+		state[name] = 1
+		if err := visitNodes(f.selections); err != nil {
+			return err
+		}
+		state[name] = 2
+		return nil
+	}
+	visitNodes = func(nodes []graphQLSelectionNode) error {
+		for _, n := range nodes {
+			if n.fragment != "" {
+				if err := visit(n.fragment); err != nil {
+					return err
+				}
+			}
+			if err := visitNodes(n.selections); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	names := make([]string, 0, len(p.doc.fragments))
+	for name := range p.doc.fragments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// included reports whether a selection with "directives" is included by its
+// @skip and @include directives.
+func (p *graphQLPlanner) included(directives []graphQLDirective) (bool, error) {
+	for _, d := range directives {
+		if d.name != "skip" && d.name != "include" {
+			return false, fmt.Errorf("unknown directive @%s", d.name)
+		}
+		var cond interface{}
+		for _, arg := range d.arguments {
+			if arg.name == "if" {
+				var err error
+				if cond, err = resolveGraphQLValue(arg.value, p.variables); err != nil {
+					return false, err
+				}
+			}
+		}
+		b, ok := cond.(bool)
+		if !ok {
+			return false, fmt.Errorf("argument \"if\" of @%s must be a Boolean", d.name)
+		}
+		if b == (d.name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// inputValue returns the value of the field "fd" of a request message from
+// the GraphQL input value "v", with the fields of the input objects named
+// after the fields of the messages.
+func (p *graphQLPlanner) inputValue(fd protoreflect.FieldDescriptor, v interface{}) (interface{}, error) {
+	if v == nil || fd.IsMap() {
+		return v, nil
+	}
+	if fd.IsList() {
+		list, ok := v.([]interface{})
+		if !ok {
+			// Input values are coerced to lists of one item.
+			list = []interface{}{v}
+		}
+		values := make([]interface{}, len(list))
+		for i, item := range list {
+			value, err := p.inputItem(fd, item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	}
+	return p.inputItem(fd, v)
+}
+
+func (p *graphQLPlanner) inputItem(fd protoreflect.FieldDescriptor, v interface{}) (interface{}, error) {
+	if fd.Kind() != protoreflect.MessageKind && fd.Kind() != protoreflect.GroupKind {
+		return v, nil
+	}
+	md := fd.Message()
+	if _, ok := graphQLScalarMessage(md); ok || v == nil {
+		return v, nil
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an object of type %sInput", p.schema.names[md.FullName()])
+	}
+	values := make(map[string]interface{}, len(obj))
+	for k, item := range obj {
+		field := md.Fields().ByJSONName(k)
+		if field == nil {
+			return nil, fmt.Errorf("field %q is not defined on type %sInput", k, p.schema.names[md.FullName()])
+		}
+		value, err := p.inputValue(field, item)
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			values[string(field.Name())] = value
+		}
+	}
+	return values, nil
+}
+
+// executeGraphQL executes "calls", received in "r", in order, and returns
+// the data of the response and the errors of the fields.
+func (s *ServeMuxDynamic) executeGraphQL(r *http.Request, calls []graphQLCall) (graphQLObject, []graphQLError) {
+	data := make(graphQLObject, 0, len(calls))
+	var errs []graphQLError
+	for _, call := range calls {
+		if call.field == nil {
+			data = append(data, graphQLMember{key: call.key, value: call.typeName})
+			continue
+		}
+		value, err := s.resolveGraphQLCall(r, call)
+		if err != nil {
+			err.Path = append([]interface{}{call.key}, err.Path...)
+			errs = append(errs, *err)
+		}
+		data = append(data, graphQLMember{key: call.key, value: value})
+	}
+	return data, errs
+}
+
+// resolveGraphQLCall resolves the value of "call" by a request to the route
+// of its method.
+func (s *ServeMuxDynamic) resolveGraphQLCall(r *http.Request, call graphQLCall) (interface{}, *graphQLError) {
+	sub, err := call.field.binding.request(r, call.params)
+	if err != nil {
+		return nil, &graphQLError{Message: err.Error()}
+	}
+	code, body := s.callRoute(sub)
+	if code < 200 || code >= 300 {
+		st := statusFromErrorBody(code, body)
+		return nil, &graphQLError{Message: st.Message(), Extensions: map[string]interface{}{"code": st.Code().String()}}
+	}
+
+	var result interface{}
+	if len(body) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&result); err != nil {
+			return nil, &graphQLError{Message: fmt.Sprintf("invalid response: %v", err)}
+		}
+	}
+	if call.fields == nil {
+		return result, nil
+	}
+	return projectGraphQL(result, call.fields)
+}
+
+// projectGraphQL returns the fields of the message "v", as marshaled to
+// JSON, selected by "selections".
+func projectGraphQL(v interface{}, selections []graphQLSelection) (interface{}, *graphQLError) {
+	if v == nil {
+		return nil, nil
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, &graphQLError{Message: "invalid response: expected an object"}
+	}
+
+	result := make(graphQLObject, 0, len(selections))
+	for _, sel := range selections {
+		if sel.field == nil {
+			result = append(result, graphQLMember{key: sel.key, value: sel.typeName})
+			continue
+		}
+		// The field is named after the field of the message if the
+		// marshaler uses the names of the proto fields.
+		value, ok := obj[sel.field.JSONName()]
+		if !ok {
+			value, ok = obj[string(sel.field.Name())]
+		}
+		if !ok {
+			value = graphQLDefaultValue(sel.field)
+		}
+		if sel.fields != nil && value != nil {
+			var err *graphQLError
+			if value, err = projectGraphQLValue(sel.field, value, sel.fields); err != nil {
+				err.Path = append([]interface{}{sel.key}, err.Path...)
+				return nil, err
+			}
+		}
+		result = append(result, graphQLMember{key: sel.key, value: value})
+	}
+	return result, nil
+}
+
+func projectGraphQLValue(fd protoreflect.FieldDescriptor, v interface{}, selections []graphQLSelection) (interface{}, *graphQLError) {
+	if !fd.IsList() {
+		return projectGraphQL(v, selections)
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, &graphQLError{Message: "invalid response: expected a list"}
+	}
+	values := make([]interface{}, len(list))
+	for i, item := range list {
+		value, err := projectGraphQL(item, selections)
+		if err != nil {
+			err.Path = append([]interface{}{i}, err.Path...)
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// graphQLDefaultValue returns the value of the field "fd" omitted from a
+// message marshaled to JSON, as done for the fields which are not populated
+// unless the marshaler emits them.
+func graphQLDefaultValue(fd protoreflect.FieldDescriptor) interface{} {
+	switch {
+	case fd.IsList():
+		return []interface{}{}
+	case fd.IsMap():
+		return map[string]interface{}{}
+	case fd.HasPresence():
+		return nil
+	}
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return false
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		return ""
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return "0"
+	case protoreflect.EnumKind:
+		if values := fd.Enum().Values(); values.Len() > 0 {
+			return string(values.Get(0).Name())
+		}
+		return nil
+	}
+	return json.Number("0")
+}
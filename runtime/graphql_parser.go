@@ -0,0 +1,590 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The GraphQL documents served by ServeMuxDynamic are parsed by the small
+// parser below, which covers the executable definitions of the GraphQL
+// specification: operations, variables, fragments and the @skip and @include
+// directives. Type system definitions are rejected.
+
+// graphQLDocument is a parsed GraphQL document.
+type graphQLDocument struct {
+	operations []*graphQLOperation
+	fragments  map[string]*graphQLFragment
+}
+
+type graphQLOperation struct {
+	// kind is "query", "mutation" or "subscription".
+	kind       string
+	name       string
+	variables  []graphQLVariableDefinition
+	selections []graphQLSelectionNode
+}
+
+type graphQLVariableDefinition struct {
+	name         string
+	defaultValue interface{}
+	hasDefault   bool
+}
+
+type graphQLFragment struct {
+	name          string
+	typeCondition string
+	selections    []graphQLSelectionNode
+}
+
+// graphQLSelectionNode is a field, a fragment spread if fragment is set, or
+// an inline fragment if inline is set.
+type graphQLSelectionNode struct {
+	alias      string
+	name       string
+	arguments  []graphQLArgument
+	directives []graphQLDirective
+	selections []graphQLSelectionNode
+
+	fragment      string
+	inline        bool
+	typeCondition string
+}
+
+// responseKey returns the key of the field in the response.
+func (n graphQLSelectionNode) responseKey() string {
+	if n.alias != "" {
+		return n.alias
+	}
+	return n.name
+}
+
+type graphQLArgument struct {
+	name  string
+	value interface{}
+}
+
+type graphQLDirective struct {
+	name      string
+	arguments []graphQLArgument
+}
+
+// The values of the literals are nil, bool, json.Number, string,
+// []interface{}, map[string]interface{}, or the types below.
+type (
+	graphQLVariable string
+	graphQLEnum     string
+)
+
+// resolveGraphQLValue returns "v" with its variables replaced by their value
+// in "variables", and its enums by their name.
+func resolveGraphQLValue(v interface{}, variables map[string]interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case graphQLVariable:
+		value, ok := variables[string(v)]
+		if !ok {
+			return nil, fmt.Errorf("variable $%s is not defined", v)
+		}
+		return value, nil
+	case graphQLEnum:
+		return string(v), nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := resolveGraphQLValue(item, variables)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = resolved
+		}
+		return list, nil
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			resolved, err := resolveGraphQLValue(item, variables)
+			if err != nil {
+				return nil, err
+			}
+			obj[k] = resolved
+		}
+		return obj, nil
+	}
+	return v, nil
+}
+
+type graphQLTokenKind int
+
+const (
+	graphQLEOF graphQLTokenKind = iota
+	graphQLPunctuator
+	graphQLName
+	graphQLInt
+	graphQLFloat
+	graphQLString
+)
+
+type graphQLToken struct {
+	kind  graphQLTokenKind
+	value string
+	pos   int
+}
+
+// graphQLMaxDepth bounds the nesting of the selection sets and values of
+// parsed documents.
+const graphQLMaxDepth = 1000
+
+// graphQLParser is a recursive descent parser of GraphQL documents.
+type graphQLParser struct {
+	src   string
+	pos   int
+	tok   graphQLToken
+	depth int
+}
+
+// parseGraphQL parses the executable GraphQL document "src".
+func parseGraphQL(src string) (doc *graphQLDocument, err error) {
+	p := &graphQLParser{src: src}
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(graphQLSyntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, perr
+		}
+	}()
+	p.next()
+
+	doc = &graphQLDocument{fragments: make(map[string]*graphQLFragment)}
+	for p.tok.kind != graphQLEOF {
+		switch {
+		case p.peek(graphQLPunctuator, "{"):
+			doc.operations = append(doc.operations, &graphQLOperation{kind: "query", selections: p.parseSelectionSet()})
+		case p.peek(graphQLName, "query"), p.peek(graphQLName, "mutation"), p.peek(graphQLName, "subscription"):
+			doc.operations = append(doc.operations, p.parseOperation())
+		case p.peek(graphQLName, "fragment"):
+			f := p.parseFragment()
+			if _, ok := doc.fragments[f.name]; ok {
+				p.fail("fragment %q is defined more than once", f.name)
+			}
+			doc.fragments[f.name] = f
+		default:
+			p.fail("unexpected %s", p.describe())
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, graphQLSyntaxError("the document has no operation")
+	}
+	return doc, nil
+}
+
+type graphQLSyntaxError string
+
+func (e graphQLSyntaxError) Error() string {
+	return string(e)
+}
+
+func (p *graphQLParser) fail(format string, args ...interface{}) {
+	line, col := 1, 1
+	for _, c := range p.src[:p.tok.pos] {
+		if c == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	panic(graphQLSyntaxError(fmt.Sprintf("syntax error at %d:%d: %s", line, col, fmt.Sprintf(format, args...))))
+}
+
+// nest enters a nested selection set or value, failing beyond
+// graphQLMaxDepth, and returns a function leaving it.
+func (p *graphQLParser) nest() func() {
+	p.depth++
+	if p.depth > graphQLMaxDepth {
+		p.fail("exceeded max nesting depth")
+	}
+	return func() { p.depth-- }
+}
+
+func (p *graphQLParser) describe() string {
+	if p.tok.kind == graphQLEOF {
+		return "end of document"
+	}
+	return strconv.Quote(p.tok.value)
+}
+
+func (p *graphQLParser) peek(kind graphQLTokenKind, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+// accept consumes the current token if it is the punctuator "value".
+func (p *graphQLParser) accept(value string) bool {
+	if p.peek(graphQLPunctuator, value) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *graphQLParser) expect(value string) {
+	if !p.accept(value) {
+		p.fail("expected %q, found %s", value, p.describe())
+	}
+}
+
+func (p *graphQLParser) expectName() string {
+	if p.tok.kind != graphQLName {
+		p.fail("expected a name, found %s", p.describe())
+	}
+	name := p.tok.value
+	p.next()
+	return name
+}
+
+func (p *graphQLParser) parseOperation() *graphQLOperation {
+	op := &graphQLOperation{kind: p.expectName()}
+	if p.tok.kind == graphQLName {
+		op.name = p.expectName()
+	}
+	if p.accept("(") {
+		for !p.accept(")") {
+			p.expect("$")
+			v := graphQLVariableDefinition{name: p.expectName()}
+			p.expect(":")
+			p.parseType()
+			if p.accept("=") {
+				v.defaultValue, v.hasDefault = p.parseValue(true), true
+			}
+			op.variables = append(op.variables, v)
+		}
+	}
+	p.parseDirectives()
+	op.selections = p.parseSelectionSet()
+	return op
+}
+
+// parseType parses a type reference, which is not checked.
+func (p *graphQLParser) parseType() {
+	if p.accept("[") {
+		p.parseType()
+		p.expect("]")
+	} else {
+		p.expectName()
+	}
+	p.accept("!")
+}
+
+func (p *graphQLParser) parseFragment() *graphQLFragment {
+	p.next()
+	f := &graphQLFragment{name: p.expectName()}
+	if f.name == "on" {
+		p.fail("a fragment cannot be named \"on\"")
+	}
+	if p.tok.kind != graphQLName || p.tok.value != "on" {
+		p.fail("expected \"on\", found %s", p.describe())
+	}
+	p.next()
+	f.typeCondition = p.expectName()
+	p.parseDirectives()
+	f.selections = p.parseSelectionSet()
+	return f
+}
+
+func (p *graphQLParser) parseSelectionSet() []graphQLSelectionNode {
+	defer p.nest()()
+	p.expect("{")
+	var selections []graphQLSelectionNode
+	for !p.accept("}") {
+		selections = append(selections, p.parseSelection())
+	}
+	if len(selections) == 0 {
+		p.fail("empty selection set")
+	}
+	return selections
+}
+
+func (p *graphQLParser) parseSelection() graphQLSelectionNode {
+	if p.accept("...") {
+		if p.tok.kind == graphQLName && p.tok.value != "on" {
+			return graphQLSelectionNode{fragment: p.expectName(), directives: p.parseDirectives()}
+		}
+		n := graphQLSelectionNode{inline: true}
+		if p.peek(graphQLName, "on") {
+			p.next()
+			n.typeCondition = p.expectName()
+		}
+		n.directives = p.parseDirectives()
+		n.selections = p.parseSelectionSet()
+		return n
+	}
+
+	n := graphQLSelectionNode{name: p.expectName()}
+	if p.accept(":") {
+		n.alias, n.name = n.name, p.expectName()
+	}
+	n.arguments = p.parseArguments(false)
+	n.directives = p.parseDirectives()
+	if p.peek(graphQLPunctuator, "{") {
+		n.selections = p.parseSelectionSet()
+	}
+	return n
+}
+
+func (p *graphQLParser) parseArguments(constant bool) []graphQLArgument {
+	if !p.accept("(") {
+		return nil
+	}
+	var args []graphQLArgument
+	for !p.accept(")") {
+		arg := graphQLArgument{name: p.expectName()}
+		p.expect(":")
+		arg.value = p.parseValue(constant)
+		args = append(args, arg)
+	}
+	return args
+}
+
+func (p *graphQLParser) parseDirectives() []graphQLDirective {
+	var directives []graphQLDirective
+	for p.accept("@") {
+		directives = append(directives, graphQLDirective{name: p.expectName(), arguments: p.parseArguments(false)})
+	}
+	return directives
+}
+
+// parseValue parses a value literal, which cannot refer to variables if
+// "constant" is set.
+func (p *graphQLParser) parseValue(constant bool) interface{} {
+	tok := p.tok
+	switch tok.kind {
+	case graphQLInt, graphQLFloat:
+		p.next()
+		return json.Number(tok.value)
+	case graphQLString:
+		p.next()
+		return tok.value
+	case graphQLName:
+		p.next()
+		switch tok.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return graphQLEnum(tok.value)
+	}
+	switch {
+	case p.accept("$"):
+		if constant {
+			p.fail("variables are not allowed in constant values")
+		}
+		return graphQLVariable(p.expectName())
+	case p.accept("["):
+		defer p.nest()()
+		list := []interface{}{}
+		for !p.accept("]") {
+			list = append(list, p.parseValue(constant))
+		}
+		return list
+	case p.accept("{"):
+		defer p.nest()()
+		obj := map[string]interface{}{}
+		for !p.accept("}") {
+			name := p.expectName()
+			p.expect(":")
+			obj[name] = p.parseValue(constant)
+		}
+		return obj
+	}
+	p.fail("unexpected %s", p.describe())
+	return nil
+}
+
+// next reads the next token into p.tok, skipping the ignored tokens.
+func (p *graphQLParser) next() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+			continue
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		case strings.HasPrefix(p.src[p.pos:], "\ufeff"):
+			p.pos += len("\ufeff")
+			continue
+		}
+		break
+	}
+	start := p.pos
+	p.tok = graphQLToken{pos: start}
+	if p.pos >= len(p.src) {
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok.kind, p.tok.value = graphQLPunctuator, "..."
+	case strings.IndexByte("!$&()[]{}:=@|", c) >= 0:
+		p.pos++
+		p.tok.kind, p.tok.value = graphQLPunctuator, string(c)
+	case c == '_' || isASCIILetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isASCIILetter(p.src[p.pos]) || isASCIIDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok.kind, p.tok.value = graphQLName, p.src[start:p.pos]
+	case c == '-' || isASCIIDigit(c):
+		p.lexNumber()
+	case c == '"':
+		p.lexString()
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.fail("unexpected character %q", r)
+	}
+}
+
+func (p *graphQLParser) lexNumber() {
+	start := p.pos
+	digits := func() {
+		n := p.pos
+		for p.pos < len(p.src) && isASCIIDigit(p.src[p.pos]) {
+			p.pos++
+		}
+		if p.pos == n {
+			p.fail("invalid number %q", p.src[start:p.pos])
+		}
+	}
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	intStart := p.pos
+	digits()
+	if p.src[intStart] == '0' && p.pos-intStart > 1 {
+		p.fail("invalid number %q", p.src[start:p.pos])
+	}
+	p.tok.kind = graphQLInt
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		p.pos++
+		digits()
+		p.tok.kind = graphQLFloat
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+		p.tok.kind = graphQLFloat
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == '_' || p.src[p.pos] == '.' || isASCIILetter(p.src[p.pos])) {
+		p.fail("invalid number %q", p.src[start:p.pos+1])
+	}
+	p.tok.value = p.src[start:p.pos]
+}
+
+func (p *graphQLParser) lexString() {
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		p.lexBlockString()
+		return
+	}
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' || p.src[p.pos] == '\r' {
+			p.fail("unterminated string")
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			p.tok.kind, p.tok.value = graphQLString, b.String()
+			return
+		case c != '\\':
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		if p.pos+1 >= len(p.src) {
+			p.fail("unterminated string")
+		}
+		esc := p.src[p.pos+1]
+		p.pos += 2
+		switch esc {
+		case '"', '\\', '/':
+			b.WriteByte(esc)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+4 > len(p.src) {
+				p.fail("invalid unicode escape")
+			}
+			r, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				p.fail("invalid unicode escape %q", p.src[p.pos:p.pos+4])
+			}
+			b.WriteRune(rune(r))
+			p.pos += 4
+		default:
+			p.fail("invalid escape \\%c", esc)
+		}
+	}
+}
+
+// lexBlockString lexes a block string, whose common indentation and leading
+// and trailing blank lines are removed.
+func (p *graphQLParser) lexBlockString() {
+	p.pos += 3
+	end := strings.Index(strings.ReplaceAll(p.src[p.pos:], `\"""`, "\x00\x00\x00\x00"), `"""`)
+	if end < 0 {
+		p.fail("unterminated block string")
+	}
+	raw := strings.ReplaceAll(p.src[p.pos:p.pos+end], `\"""`, `"""`)
+	p.pos += end + 3
+
+	lines := strings.Split(strings.ReplaceAll(strings.ReplaceAll(raw, "\r\n", "\n"), "\r", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && (indent < 0 || len(line)-len(trimmed) < indent) {
+			indent = len(line) - len(trimmed)
+		}
+	}
+	for i := 1; i < len(lines) && indent > 0; i++ {
+		if len(lines[i]) >= indent {
+			lines[i] = lines[i][indent:]
+		} else {
+			lines[i] = ""
+		}
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[0], " \t") == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	p.tok.kind, p.tok.value = graphQLString, strings.Join(lines, "\n")
+}
+
+func isASCIILetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
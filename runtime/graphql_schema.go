@@ -0,0 +1,370 @@
+package runtime
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// graphQLServices holds the services registered on a ServeMuxDynamic with
+// RegisterGraphQLService, and the schema derived from them.
+type graphQLServices struct {
+	mu       sync.Mutex
+	lastID   uint64
+	services []graphQLService
+	schema   *graphQLSchema
+}
+
+type graphQLService struct {
+	id uint64
+	sd protoreflect.ServiceDescriptor
+}
+
+// RegisterGraphQLService adds the unary methods of "sd" with a
+// google.api.http option to the GraphQL schema served at the path given with
+// WithGraphQLPath. The methods bound to GET are fields of the Query type, and
+// the others fields of the Mutation type, named after the methods in
+// lowerCamelCase, e.g. "getBook", and taking the fields of their request
+// message as arguments. The fields are resolved by requests to the main
+// binding of the methods through the mux, so that they are served by the
+// same routes as the REST API. It returns a function deregistering the
+// service.
+func (s *ServeMuxDynamic) RegisterGraphQLService(sd protoreflect.ServiceDescriptor) (deregister func(), err error) {
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if !ok || rule == nil {
+			continue
+		}
+		if _, template := httpRuleRoute(rule); template != "" {
+			if _, err := parsePattern(template); err != nil {
+				return nil, fmt.Errorf("invalid pattern of %s: %v", md.FullName(), err)
+			}
+		}
+	}
+
+	s.graphQL.mu.Lock()
+	defer s.graphQL.mu.Unlock()
+
+	s.graphQL.lastID++
+	id := s.graphQL.lastID
+	s.graphQL.services = append(s.graphQL.services, graphQLService{id: id, sd: sd})
+	s.graphQL.schema = nil
+
+	return func() {
+		s.graphQL.mu.Lock()
+		defer s.graphQL.mu.Unlock()
+
+		for i, svc := range s.graphQL.services {
+			if svc.id == id {
+				s.graphQL.services = append(s.graphQL.services[:i:i], s.graphQL.services[i+1:]...)
+				s.graphQL.schema = nil
+				return
+			}
+		}
+	}, nil
+}
+
+// GraphQLSchema returns the GraphQL schema of the currently registered
+// services in the schema definition language. Messages are object types,
+// and input types suffixed with "Input" when they are arguments, named after
+// the messages, or after their full names with underscores if their names
+// collide. 64-bit integers are Strings, as in the JSON mapping of protocol
+// buffers, and maps, google.protobuf.Struct, Any and Empty are of the JSON
+// scalar type.
+func (s *ServeMuxDynamic) GraphQLSchema() string {
+	return s.graphQLSchema().sdl()
+}
+
+// graphQLSchema returns the schema of the currently registered services.
+func (s *ServeMuxDynamic) graphQLSchema() *graphQLSchema {
+	s.graphQL.mu.Lock()
+	defer s.graphQL.mu.Unlock()
+
+	if s.graphQL.schema == nil {
+		s.graphQL.schema = newGraphQLSchema(s.graphQL.services)
+	}
+	return s.graphQL.schema
+}
+
+// graphQLSchema is the GraphQL schema derived from service descriptors.
+type graphQLSchema struct {
+	query    []*graphQLRootField
+	mutation []*graphQLRootField
+	// names are the names of the object and enum types of the messages and
+	// enums.
+	names    map[protoreflect.FullName]string
+	messages []protoreflect.MessageDescriptor
+	inputs   []protoreflect.MessageDescriptor
+	enums    []protoreflect.EnumDescriptor
+}
+
+// graphQLRootField is a field of the Query or Mutation type.
+type graphQLRootField struct {
+	name    string
+	method  protoreflect.MethodDescriptor
+	binding routeBinding
+}
+
+func newGraphQLSchema(services []graphQLService) *graphQLSchema {
+	schema := &graphQLSchema{names: make(map[protoreflect.FullName]string)}
+
+	var fields []*graphQLRootField
+	for _, svc := range services {
+		methods := svc.sd.Methods()
+		for i := 0; i < methods.Len(); i++ {
+			md := methods.Get(i)
+			if md.IsStreamingClient() || md.IsStreamingServer() {
+				continue
+			}
+			rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+			if !ok || rule == nil {
+				continue
+			}
+			method, template := httpRuleRoute(rule)
+			if method == "" {
+				continue
+			}
+			fields = append(fields, &graphQLRootField{
+				method:  md,
+				binding: routeBinding{httpMethod: method, pattern: template, body: rule.GetBody()},
+			})
+		}
+	}
+
+	names := make(map[string]int)
+	for _, f := range fields {
+		names[string(f.method.Name())]++
+	}
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		name := string(f.method.Name())
+		if names[name] > 1 {
+			name = strings.ReplaceAll(string(f.method.FullName()), ".", "_")
+		}
+		f.name = strings.ToLower(name[:1]) + name[1:]
+		if seen[f.name] {
+			continue
+		}
+		seen[f.name] = true
+		if f.binding.httpMethod == "GET" {
+			schema.query = append(schema.query, f)
+		} else {
+			schema.mutation = append(schema.mutation, f)
+		}
+	}
+
+	// The types are the messages and enums reachable from the methods. The
+	// request messages are not types, but their fields are the arguments.
+	messages := make(map[protoreflect.FullName]protoreflect.MessageDescriptor)
+	inputs := make(map[protoreflect.FullName]bool)
+	outputs := make(map[protoreflect.FullName]bool)
+	enums := make(map[protoreflect.FullName]protoreflect.EnumDescriptor)
+	var visit, visitFields func(md protoreflect.MessageDescriptor, input bool)
+	visit = func(md protoreflect.MessageDescriptor, input bool) {
+		if _, ok := graphQLScalarMessage(md); ok {
+			return
+		}
+		types := outputs
+		if input {
+			types = inputs
+		}
+		if types[md.FullName()] {
+			return
+		}
+		types[md.FullName()] = true
+		messages[md.FullName()] = md
+		visitFields(md, input)
+	}
+	visitFields = func(md protoreflect.MessageDescriptor, input bool) {
+		fields := md.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			switch {
+			case fd.IsMap():
+			case fd.Kind() == protoreflect.EnumKind:
+				enums[fd.Enum().FullName()] = fd.Enum()
+			case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+				visit(fd.Message(), input)
+			}
+		}
+	}
+	for _, f := range fields {
+		if _, ok := graphQLScalarMessage(f.method.Input()); !ok {
+			visitFields(f.method.Input(), true)
+		}
+		visit(f.method.Output(), false)
+	}
+
+	shortNames := make(map[string]int)
+	for name := range messages {
+		shortNames[string(name.Name())]++
+	}
+	for name := range enums {
+		shortNames[string(name.Name())]++
+	}
+	typeName := func(name protoreflect.FullName) string {
+		if shortNames[string(name.Name())] > 1 {
+			return strings.ReplaceAll(string(name), ".", "_")
+		}
+		return string(name.Name())
+	}
+	for name, md := range messages {
+		schema.names[name] = typeName(name)
+		if outputs[name] {
+			schema.messages = append(schema.messages, md)
+		}
+		if inputs[name] {
+			schema.inputs = append(schema.inputs, md)
+		}
+	}
+	for name, ed := range enums {
+		schema.names[name] = typeName(name)
+		schema.enums = append(schema.enums, ed)
+	}
+	sort.Slice(schema.messages, func(i, j int) bool {
+		return schema.names[schema.messages[i].FullName()] < schema.names[schema.messages[j].FullName()]
+	})
+	sort.Slice(schema.inputs, func(i, j int) bool {
+		return schema.names[schema.inputs[i].FullName()] < schema.names[schema.inputs[j].FullName()]
+	})
+	sort.Slice(schema.enums, func(i, j int) bool {
+		return schema.names[schema.enums[i].FullName()] < schema.names[schema.enums[j].FullName()]
+	})
+	return schema
+}
+
+// graphQLScalarMessage returns the scalar type of the messages mapped onto
+// one, such as the well-known types, and whether "md" is one.
+func graphQLScalarMessage(md protoreflect.MessageDescriptor) (string, bool) {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp", "google.protobuf.Duration", "google.protobuf.FieldMask",
+		"google.protobuf.StringValue", "google.protobuf.BytesValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value":
+		return "String", true
+	case "google.protobuf.Int32Value", "google.protobuf.UInt32Value":
+		return "Int", true
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue":
+		return "Float", true
+	case "google.protobuf.BoolValue":
+		return "Boolean", true
+	case "google.protobuf.Struct", "google.protobuf.Value", "google.protobuf.ListValue", "google.protobuf.Any":
+		return "JSON", true
+	}
+	// Object types must have fields, so empty messages such as
+	// google.protobuf.Empty are JSON objects.
+	if md.Fields().Len() == 0 {
+		return "JSON", true
+	}
+	return "", false
+}
+
+// fieldType returns the type of the field "fd", of an input type if "input"
+// is set.
+func (schema *graphQLSchema) fieldType(fd protoreflect.FieldDescriptor, input bool) string {
+	var typ string
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		typ = "Boolean"
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		typ = "Int"
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		typ = "Float"
+	case protoreflect.EnumKind:
+		typ = schema.names[fd.Enum().FullName()]
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		typ = schema.messageType(fd.Message(), input)
+	default:
+		// 64-bit integers, strings and bytes.
+		typ = "String"
+	}
+	switch {
+	case fd.IsMap():
+		return "JSON"
+	case fd.IsList():
+		return "[" + typ + "!]"
+	}
+	return typ
+}
+
+// messageType returns the type of the message "md", of an input type if
+// "input" is set.
+func (schema *graphQLSchema) messageType(md protoreflect.MessageDescriptor, input bool) string {
+	if typ, ok := graphQLScalarMessage(md); ok {
+		return typ
+	}
+	if input {
+		return schema.names[md.FullName()] + "Input"
+	}
+	return schema.names[md.FullName()]
+}
+
+// sdl returns the schema in the schema definition language.
+func (schema *graphQLSchema) sdl() string {
+	var b strings.Builder
+	usesJSON := false
+	writeFields := func(kind, name string, md protoreflect.MessageDescriptor, input bool) {
+		fmt.Fprintf(&b, "%s %s {\n", kind, name)
+		fields := md.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			typ := schema.fieldType(fields.Get(i), input)
+			usesJSON = usesJSON || strings.Trim(typ, "[]!") == "JSON"
+			fmt.Fprintf(&b, "  %s: %s\n", fields.Get(i).JSONName(), typ)
+		}
+		b.WriteString("}\n\n")
+	}
+	writeRoot := func(name string, fields []*graphQLRootField) {
+		if len(fields) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "type %s {\n", name)
+		for _, f := range fields {
+			b.WriteString("  " + f.name)
+			var args []string
+			if _, ok := graphQLScalarMessage(f.method.Input()); !ok {
+				inFields := f.method.Input().Fields()
+				for i := 0; i < inFields.Len(); i++ {
+					typ := schema.fieldType(inFields.Get(i), true)
+					usesJSON = usesJSON || strings.Trim(typ, "[]!") == "JSON"
+					args = append(args, inFields.Get(i).JSONName()+": "+typ)
+				}
+			}
+			if len(args) > 0 {
+				b.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			typ := schema.messageType(f.method.Output(), false)
+			usesJSON = usesJSON || typ == "JSON"
+			b.WriteString(": " + typ + "\n")
+		}
+		b.WriteString("}\n\n")
+	}
+
+	writeRoot("Query", schema.query)
+	writeRoot("Mutation", schema.mutation)
+	for _, md := range schema.messages {
+		writeFields("type", schema.names[md.FullName()], md, false)
+	}
+	for _, md := range schema.inputs {
+		writeFields("input", schema.names[md.FullName()]+"Input", md, true)
+	}
+	for _, ed := range schema.enums {
+		fmt.Fprintf(&b, "enum %s {\n", schema.names[ed.FullName()])
+		values := ed.Values()
+		for i := 0; i < values.Len(); i++ {
+			fmt.Fprintf(&b, "  %s\n", values.Get(i).Name())
+		}
+		b.WriteString("}\n\n")
+	}
+	sdl := strings.TrimSuffix(b.String(), "\n")
+	if usesJSON {
+		sdl = "scalar JSON\n\n" + sdl
+	}
+	return sdl
+}
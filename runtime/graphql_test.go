@@ -0,0 +1,342 @@
+package runtime_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func newLibraryFile(t *testing.T) *descriptorpb.FileDescriptorProto {
+	t.Helper()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		fd := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   typ.Enum(),
+		}
+		if typeName != "" {
+			fd.TypeName = proto.String(typeName)
+		}
+		return fd
+	}
+	method := func(name, input, output string, rule *annotations.HttpRule) *descriptorpb.MethodDescriptorProto {
+		opts := &descriptorpb.MethodOptions{}
+		proto.SetExtension(opts, annotations.E_Http, rule)
+		return &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(name),
+			InputType:  proto.String(input),
+			OutputType: proto.String(output),
+			Options:    opts,
+		}
+	}
+	authors := field("authors", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".example.library.Author")
+	authors.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("library.proto"),
+		Package:    proto.String("example.library"),
+		Dependency: []string{"google/protobuf/empty.proto"},
+		Syntax:     proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Book"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					field("title", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					field("page_count", 3, descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+					field("status", 4, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".example.library.Status"),
+					authors,
+				},
+			},
+			{
+				Name: proto.String("Author"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("display_name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				},
+			},
+			{
+				Name: proto.String("GetBookRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				},
+			},
+			{
+				Name: proto.String("UpdateBookRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("book", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".example.library.Book"),
+				},
+			},
+		},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Status"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("STATUS_UNSPECIFIED"), Number: proto.Int32(0)},
+				{Name: proto.String("PUBLISHED"), Number: proto.Int32(1)},
+			},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("LibraryService"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("GetBook", ".example.library.GetBookRequest", ".example.library.Book", &annotations.HttpRule{
+					Pattern: &annotations.HttpRule_Get{Get: "/v1/{name=books/*}"},
+				}),
+				method("UpdateBook", ".example.library.UpdateBookRequest", ".example.library.Book", &annotations.HttpRule{
+					Pattern: &annotations.HttpRule_Patch{Patch: "/v1/{book.name=books/*}"},
+					Body:    "book",
+				}),
+				method("DeleteBook", ".example.library.GetBookRequest", ".google.protobuf.Empty", &annotations.HttpRule{
+					Pattern: &annotations.HttpRule_Delete{Delete: "/v1/{name=books/*}"},
+				}),
+			},
+		}},
+	}
+}
+
+// handleLibraryRoutes registers the routes of the methods of the library
+// service of newLibraryFile on mux, and returns the service.
+func handleLibraryRoutes(t *testing.T, mux *runtime.ServeMuxDynamic) protoreflect.ServiceDescriptor {
+	t.Helper()
+	err := mux.HandlePath("GET", "/v1/{name=books/*}", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		fmt.Fprintf(w, `{"name": %q, "title": "Dune", "pageCount": "412", "status": "PUBLISHED", "authors": [{"displayName": "Frank Herbert"}]}`, pathParams["name"])
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mux.HandlePath("PATCH", "/v1/{book.name=books/*}", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		var book map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&book); err != nil {
+			t.Errorf("json.Decode(...) failed with %v; want success", err)
+		}
+		book["name"] = pathParams["book.name"]
+		json.NewEncoder(w).Encode(book)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mux.HandlePath("DELETE", "/v1/{name=books/*}", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		_, outboundMarshaler := runtime.MarshalerForRequest(mux.ServeMux, r)
+		runtime.HTTPError(r.Context(), mux.ServeMux, outboundMarshaler, w, r, status.Errorf(codes.NotFound, "%s not found", pathParams["name"]))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := protodesc.NewFile(newLibraryFile(t), protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile(...) failed with %v; want success", err)
+	}
+	return file.Services().Get(0)
+}
+
+func newGraphQLTestMux(t *testing.T) (*runtime.ServeMuxDynamic, func()) {
+	t.Helper()
+	mux := runtime.NewServeMuxDynamic(runtime.WithGraphQLPath("/graphql"))
+	deregister, err := mux.RegisterGraphQLService(handleLibraryRoutes(t, mux))
+	if err != nil {
+		t.Fatalf("mux.RegisterGraphQLService(...) failed with %v; want success", err)
+	}
+	return mux, deregister
+}
+
+func postGraphQL(t *testing.T, mux http.Handler, body string) (int, interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/graphql", strings.NewReader(body)))
+	var resp interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json.Unmarshal(%s) failed with %v; want success", w.Body, err)
+	}
+	return w.Code, resp
+}
+
+func TestGraphQLSchema(t *testing.T) {
+	mux, deregister := newGraphQLTestMux(t)
+
+	want := `scalar JSON
+
+type Query {
+  getBook(name: String): Book
+}
+
+type Mutation {
+  updateBook(book: BookInput): Book
+  deleteBook(name: String): JSON
+}
+
+type Author {
+  displayName: String
+}
+
+type Book {
+  name: String
+  title: String
+  pageCount: String
+  status: Status
+  authors: [Author!]
+}
+
+input AuthorInput {
+  displayName: String
+}
+
+input BookInput {
+  name: String
+  title: String
+  pageCount: String
+  status: Status
+  authors: [AuthorInput!]
+}
+
+enum Status {
+  STATUS_UNSPECIFIED
+  PUBLISHED
+}
+`
+	if diff := cmp.Diff(want, mux.GraphQLSchema()); diff != "" {
+		t.Errorf("mux.GraphQLSchema() differs (-want +got):\n%s", diff)
+	}
+
+	deregister()
+	if got := mux.GraphQLSchema(); got != "" {
+		t.Errorf("mux.GraphQLSchema() = %q once deregistered; want empty", got)
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	mux, _ := newGraphQLTestMux(t)
+
+	for _, spec := range []struct {
+		name string
+		body string
+		code int
+		want string
+	}{
+		{
+			name: "query",
+			body: `{"query": "{ getBook(name: \"books/1\") { title pages: pageCount authors { displayName } } }"}`,
+			code: http.StatusOK,
+			want: `{"data": {"getBook": {"title": "Dune", "pages": "412", "authors": [{"displayName": "Frank Herbert"}]}}}`,
+		},
+		{
+			name: "variables and fragments",
+			body: `{
+				"query": "query Get($name: String!, $withStatus: Boolean = false) { __typename a: getBook(name: $name) { ...BookFields status @include(if: $withStatus) } b: getBook(name: \"books/2\") { ... on Book { name __typename } } } fragment BookFields on Book { name }",
+				"variables": {"name": "books/1"}
+			}`,
+			code: http.StatusOK,
+			want: `{"data": {"__typename": "Query", "a": {"name": "books/1"}, "b": {"name": "books/2", "__typename": "Book"}}}`,
+		},
+		{
+			name: "mutation",
+			body: `{"query": "mutation { updateBook(book: {name: \"books/1\", title: \"Dune Messiah\", status: PUBLISHED}) { name title pageCount status authors { displayName } } }"}`,
+			code: http.StatusOK,
+			want: `{"data": {"updateBook": {"name": "books/1", "title": "Dune Messiah", "pageCount": "0", "status": "PUBLISHED", "authors": []}}}`,
+		},
+		{
+			name: "field error",
+			body: `{"query": "mutation { deleteBook(name: \"books/404\") }"}`,
+			code: http.StatusOK,
+			want: `{
+				"data": {"deleteBook": null},
+				"errors": [{"message": "books/404 not found", "path": ["deleteBook"], "extensions": {"code": "NotFound"}}]
+			}`,
+		},
+		{
+			name: "unknown field",
+			body: `{"query": "{ getBook(name: \"books/1\") { isbn } }"}`,
+			code: http.StatusBadRequest,
+			want: `{"errors": [{"message": "field \"isbn\" is not defined on type Book"}]}`,
+		},
+		{
+			name: "unknown argument",
+			body: `{"query": "{ getBook(id: 1) { name } }"}`,
+			code: http.StatusBadRequest,
+			want: `{"errors": [{"message": "unknown argument \"id\" of field \"getBook\""}]}`,
+		},
+		{
+			name: "missing selection set",
+			body: `{"query": "{ getBook(name: \"books/1\") }"}`,
+			code: http.StatusBadRequest,
+			want: `{"errors": [{"message": "field \"getBook\" of type Book must have a selection set"}]}`,
+		},
+		{
+			name: "syntax error",
+			body: `{"query": "{ getBook(name: \"books/1\") { name }"}`,
+			code: http.StatusBadRequest,
+			want: `{"errors": [{"message": "syntax error at 1:36: expected a name, found end of document"}]}`,
+		},
+		{
+			name: "fragment cycle",
+			body: `{"query": "{ getBook(name: \"books/1\") { ...A } } fragment A on Book { authors { ...B } } fragment B on Author { ...A }"}`,
+			code: http.StatusBadRequest,
+			want: `{"errors": [{"message": "fragment \"A\" spreads itself"}]}`,
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			code, got := postGraphQL(t, mux, spec.body)
+			var want interface{}
+			if err := json.Unmarshal([]byte(spec.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if code != spec.code {
+				t.Errorf("code = %d; want %d", code, spec.code)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("response differs (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGraphQLGet(t *testing.T) {
+	mux, _ := newGraphQLTestMux(t)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/graphql?"+url.Values{"query": {query}}.Encode(), nil))
+		return w
+	}
+
+	w := get(`{ book: getBook(name: "books/1") { title } }`)
+	if body, _ := ioutil.ReadAll(w.Body); w.Code != http.StatusOK || strings.TrimSpace(string(body)) != `{"data":{"book":{"title":"Dune"}}}` {
+		t.Errorf("GET query replied %d %s; want %d with the book", w.Code, body, http.StatusOK)
+	}
+	w = get(`mutation { deleteBook(name: "books/1") }`)
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "POST" {
+		t.Errorf("GET mutation replied %d with Allow %q; want %d with Allow POST", w.Code, w.Header().Get("Allow"), http.StatusMethodNotAllowed)
+	}
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	mux, _ := newGraphQLTestMux(t)
+
+	for name, query := range map[string]string{
+		"nested selection sets": strings.Repeat("{a", 10000) + strings.Repeat("}", 10000),
+		"nested values":         "{ getBook(name: " + strings.Repeat("[", 10000) + ") { name } }",
+		"large body":            "{ getBook(name: \"" + strings.Repeat("a", 2<<20) + "\") { name } }",
+	} {
+		r := httptest.NewRequest("POST", "/graphql", strings.NewReader(query))
+		r.Header.Set("Content-Type", "application/graphql")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: code = %d; want %d", name, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -2,10 +2,10 @@ package runtime
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"net/http"
 	"net/textproto"
+	"time"
 
 	"google.golang.org/genproto/googleapis/api/httpbody"
 	"google.golang.org/grpc/codes"
@@ -16,7 +16,7 @@ import (
 
 // ForwardResponseStream forwards the stream from gRPC server to REST client.
 func ForwardResponseStream(ctx context.Context, mux *ServeMux, marshaler Marshaler, w http.ResponseWriter, req *http.Request, recv func() (proto.Message, error), opts ...func(context.Context, http.ResponseWriter, proto.Message) error) {
-	f, ok := w.(http.Flusher)
+	f, ok := flusherOf(w)
 	if !ok {
 		grpclog.Infof("Flush not supported in %T", w)
 		http.Error(w, "unexpected type of web server", http.StatusInternalServerError)
@@ -29,7 +29,16 @@ func ForwardResponseStream(ctx context.Context, mux *ServeMux, marshaler Marshal
 		http.Error(w, "unexpected error", http.StatusInternalServerError)
 		return
 	}
-	handleForwardResponseServerMetadata(w, mux, md)
+	handleForwardResponseServerMetadata(ctx, w, mux, md)
+
+	sel, err := mux.partialResponseSelection(req)
+	if err != nil {
+		HTTPError(ctx, mux, marshaler, w, req, err)
+		return
+	}
+	if sel != nil {
+		marshaler = partialResponseMarshaler(marshaler)
+	}
 
 	w.Header().Set("Transfer-Encoding", "chunked")
 	if err := handleForwardResponseOptions(ctx, w, nil, opts); err != nil {
@@ -44,6 +53,8 @@ func ForwardResponseStream(ctx context.Context, mux *ServeMux, marshaler Marshal
 		delimiter = []byte("\n")
 	}
 
+	buf := getBuffer()
+	defer putBuffer(buf)
 	var wroteHeader bool
 	for {
 		resp, err := recv()
@@ -59,24 +70,46 @@ func ForwardResponseStream(ctx context.Context, mux *ServeMux, marshaler Marshal
 			return
 		}
 
+		var body interface{} = resp
+		if rb, ok := resp.(responseBody); ok {
+			body = rb.XXX_ResponseBody()
+		}
+		// Messages, or their response body fields, of type HttpBody are
+		// streamed as is.
+		httpBody, isHTTPBody := body.(*httpbody.HttpBody)
 		if !wroteHeader {
-			w.Header().Set("Content-Type", marshaler.ContentType(resp))
+			if isHTTPBody {
+				w.Header().Set("Content-Type", marshaler.ContentType(httpBody))
+			} else {
+				w.Header().Set("Content-Type", marshaler.ContentType(resp))
+			}
 		}
 
-		var buf []byte
-		httpBody, isHTTPBody := resp.(*httpbody.HttpBody)
+		buf.Reset()
+		var chunk interface{}
 		switch {
 		case resp == nil:
-			buf, err = marshaler.Marshal(errorChunk(status.New(codes.Internal, "empty response")))
+			chunk = errorChunk(status.New(codes.Internal, "empty response"))
 		case isHTTPBody:
-			buf = httpBody.GetData()
 		default:
-			result := map[string]interface{}{"result": resp}
-			if rb, ok := resp.(responseBody); ok {
-				result["result"] = rb.XXX_ResponseBody()
+			if body, err = sel.apply(body); err != nil {
+				break
 			}
-
-			buf, err = marshaler.Marshal(result)
+			body = mux.responseRedaction.redact(body)
+			chunk = map[string]interface{}{"result": body}
+		}
+		if p, ok := marshaler.(StreamPreambler); ok && !wroteHeader && err == nil && chunk != nil {
+			var preamble []byte
+			if preamble, err = p.StreamPreamble(chunk); err == nil {
+				buf.Write(preamble)
+			}
+		}
+		if err == nil && chunk != nil {
+			err = marshalToBuffer(marshaler, buf, chunk)
+		}
+		out := buf.Bytes()
+		if isHTTPBody {
+			out = httpBody.GetData()
 		}
 
 		if err != nil {
@@ -84,7 +117,18 @@ func ForwardResponseStream(ctx context.Context, mux *ServeMux, marshaler Marshal
 			handleForwardResponseStreamError(ctx, wroteHeader, marshaler, w, req, mux, err)
 			return
 		}
-		if _, err = w.Write(buf); err != nil {
+		if framed, ok := marshaler.(Framed); ok {
+			if _, err = w.Write(framed.FramePrefix(len(out))); err != nil {
+				grpclog.Infof("Failed to send frame prefix: %v", err)
+				return
+			}
+		}
+		if isHTTPBody {
+			err = mux.writeHTTPBody(w, out)
+		} else {
+			_, err = w.Write(out)
+		}
+		if err != nil {
 			grpclog.Infof("Failed to send response chunk: %v", err)
 			return
 		}
@@ -97,9 +141,10 @@ func ForwardResponseStream(ctx context.Context, mux *ServeMux, marshaler Marshal
 	}
 }
 
-func handleForwardResponseServerMetadata(w http.ResponseWriter, mux *ServeMux, md ServerMetadata) {
+func handleForwardResponseServerMetadata(ctx context.Context, w http.ResponseWriter, mux *ServeMux, md ServerMetadata) {
+	outgoingHeaderMatcher := mux.outgoingHeaderMatcherFor(ctx)
 	for k, vs := range md.HeaderMD {
-		if h, ok := mux.outgoingHeaderMatcher(k); ok {
+		if h, ok := outgoingHeaderMatcher(k); ok {
 			for _, v := range vs {
 				w.Header().Add(h, v)
 			}
@@ -107,18 +152,22 @@ func handleForwardResponseServerMetadata(w http.ResponseWriter, mux *ServeMux, m
 	}
 }
 
-func handleForwardResponseTrailerHeader(w http.ResponseWriter, md ServerMetadata) {
+func handleForwardResponseTrailerHeader(ctx context.Context, w http.ResponseWriter, mux *ServeMux, md ServerMetadata) {
+	outgoingTrailerMatcher := mux.outgoingTrailerMatcherFor(ctx)
 	for k := range md.TrailerMD {
-		tKey := textproto.CanonicalMIMEHeaderKey(fmt.Sprintf("%s%s", MetadataTrailerPrefix, k))
-		w.Header().Add("Trailer", tKey)
+		if h, ok := outgoingTrailerMatcher(k); ok {
+			w.Header().Add("Trailer", textproto.CanonicalMIMEHeaderKey(h))
+		}
 	}
 }
 
-func handleForwardResponseTrailer(w http.ResponseWriter, md ServerMetadata) {
+func handleForwardResponseTrailer(ctx context.Context, w http.ResponseWriter, mux *ServeMux, md ServerMetadata) {
+	outgoingTrailerMatcher := mux.outgoingTrailerMatcherFor(ctx)
 	for k, vs := range md.TrailerMD {
-		tKey := fmt.Sprintf("%s%s", MetadataTrailerPrefix, k)
-		for _, v := range vs {
-			w.Header().Add(tKey, v)
+		if h, ok := outgoingTrailerMatcher(k); ok {
+			for _, v := range vs {
+				w.Header().Add(h, v)
+			}
 		}
 	}
 }
@@ -136,34 +185,57 @@ func ForwardResponseMessage(ctx context.Context, mux *ServeMux, marshaler Marsha
 		grpclog.Infof("Failed to extract ServerMetadata from context")
 	}
 
-	handleForwardResponseServerMetadata(w, mux, md)
-	handleForwardResponseTrailerHeader(w, md)
+	sel, err := mux.partialResponseSelection(req)
+	if err != nil {
+		HTTPError(ctx, mux, marshaler, w, req, err)
+		return
+	}
+	if sel != nil {
+		marshaler = partialResponseMarshaler(marshaler)
+	}
+
+	handleForwardResponseServerMetadata(ctx, w, mux, md)
+	handleForwardResponseTrailerHeader(ctx, w, mux, md)
 
 	contentType := marshaler.ContentType(resp)
 	w.Header().Set("Content-Type", contentType)
+	mux.writePaginationHeaders(w, req, resp)
 
 	if err := handleForwardResponseOptions(ctx, w, resp, opts); err != nil {
 		HTTPError(ctx, mux, marshaler, w, req, err)
 		return
 	}
-	var buf []byte
-	var err error
+	var body interface{} = resp
 	if rb, ok := resp.(responseBody); ok {
-		buf, err = marshaler.Marshal(rb.XXX_ResponseBody())
-	} else {
-		buf, err = marshaler.Marshal(resp)
+		body = rb.XXX_ResponseBody()
 	}
-	if err != nil {
+	if httpBody, ok := rawHTTPBody(marshaler, body); ok {
+		if err := mux.writeHTTPBody(w, httpBody.GetData()); err != nil {
+			grpclog.Infof("Failed to write response: %v", err)
+		}
+		handleForwardResponseTrailer(ctx, w, mux, md)
+		return
+	}
+	marshalStart := time.Now()
+	if body, err = sel.apply(body); err != nil {
+		HTTPError(ctx, mux, marshaler, w, req, err)
+		return
+	}
+	body = mux.responseRedaction.redact(body)
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := marshalToBuffer(marshaler, buf, body); err != nil {
 		grpclog.Infof("Marshal error: %v", err)
 		HTTPError(ctx, mux, marshaler, w, req, err)
 		return
 	}
+	serverTimingFromContext(ctx).markMarshal(marshalStart)
 
-	if _, err = w.Write(buf); err != nil {
+	if _, err = w.Write(buf.Bytes()); err != nil {
 		grpclog.Infof("Failed to write response: %v", err)
 	}
 
-	handleForwardResponseTrailer(w, md)
+	handleForwardResponseTrailer(ctx, w, mux, md)
 }
 
 func handleForwardResponseOptions(ctx context.Context, w http.ResponseWriter, resp proto.Message, opts []func(context.Context, http.ResponseWriter, proto.Message) error) error {
@@ -180,7 +252,7 @@ func handleForwardResponseOptions(ctx context.Context, w http.ResponseWriter, re
 }
 
 func handleForwardResponseStreamError(ctx context.Context, wroteHeader bool, marshaler Marshaler, w http.ResponseWriter, req *http.Request, mux *ServeMux, err error) {
-	st := mux.streamErrorHandler(ctx, err)
+	st := mux.logRedaction.Status(mux.streamErrorHandler(ctx, err))
 	if !wroteHeader {
 		w.WriteHeader(HTTPStatusFromCode(st.Code()))
 	}
@@ -6,10 +6,12 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	pb "github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/genproto/googleapis/api/httpbody"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
@@ -260,6 +262,43 @@ func TestForwardResponseStreamCustomMarshaler(t *testing.T) {
 	}
 }
 
+type fakeHTTPBodyResponseBodyWrapper struct {
+	proto.Message
+}
+
+// XXX_ResponseBody returns id of SimpleMessage as an HttpBody
+func (r fakeHTTPBodyResponseBodyWrapper) XXX_ResponseBody() interface{} {
+	resp := r.Message.(*pb.SimpleMessage)
+	return &httpbody.HttpBody{ContentType: "text/plain", Data: []byte(resp.Id)}
+}
+
+func TestForwardResponseStreamHTTPBodyResponseBody(t *testing.T) {
+	msgs := []proto.Message{
+		fakeHTTPBodyResponseBodyWrapper{&pb.SimpleMessage{Id: "One"}},
+		fakeHTTPBodyResponseBodyWrapper{&pb.SimpleMessage{Id: "Two"}},
+	}
+	recv := func() (proto.Message, error) {
+		if len(msgs) == 0 {
+			return nil, io.EOF
+		}
+		msg := msgs[0]
+		msgs = msgs[1:]
+		return msg, nil
+	}
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	resp := httptest.NewRecorder()
+
+	runtime.ForwardResponseStream(ctx, runtime.NewServeMux(), &runtime.HTTPBodyMarshaler{Marshaler: &runtime.JSONPb{}}, resp, req, recv)
+
+	if got, want := resp.Header().Get("Content-Type"), "text/plain"; got != want {
+		t.Errorf("Content-Type = %q; want %q", got, want)
+	}
+	if got, want := resp.Body.String(), "One\nTwo\n"; got != want {
+		t.Errorf("ForwardResponseStream() = %q; want %q", got, want)
+	}
+}
+
 func TestForwardResponseMessage(t *testing.T) {
 	msg := &pb.SimpleMessage{Id: "One"}
 	tests := []struct {
@@ -312,3 +351,99 @@ func TestForwardResponseMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestForwardResponseStreamRegisteredDelimiter(t *testing.T) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption("application/json-seq", &runtime.JSONPb{},
+			runtime.WithStreamRecordPrefix([]byte("\x1e")),
+			runtime.WithStreamDelimiter([]byte("\n")),
+		),
+		runtime.WithMarshalerOption("application/x-crlf", &runtime.JSONPb{},
+			runtime.WithStreamDelimiter([]byte("\r\n")),
+		),
+	)
+	for _, spec := range []struct {
+		accept string
+		want   string
+	}{
+		{
+			accept: "application/json-seq",
+			want:   "\x1e{\"result\":{\"id\":\"One\"}}\n\x1e{\"result\":{\"id\":\"Two\"}}\n",
+		},
+		{
+			accept: "application/x-crlf",
+			want:   "{\"result\":{\"id\":\"One\"}}\r\n{\"result\":{\"id\":\"Two\"}}\r\n",
+		},
+	} {
+		msgs := []proto.Message{&pb.SimpleMessage{Id: "One"}, &pb.SimpleMessage{Id: "Two"}}
+		recv := func() (proto.Message, error) {
+			if len(msgs) == 0 {
+				return nil, io.EOF
+			}
+			msg := msgs[0]
+			msgs = msgs[1:]
+			return msg, nil
+		}
+		req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+		req.Header.Set("Accept", spec.accept)
+		resp := httptest.NewRecorder()
+
+		ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+		_, marshaler := runtime.MarshalerForRequest(mux, req)
+		runtime.ForwardResponseStream(ctx, mux, marshaler, resp, req, recv)
+
+		if got := resp.Body.String(); got != spec.want {
+			t.Errorf("ForwardResponseStream() with Accept %q = %q; want %q", spec.accept, got, spec.want)
+		}
+	}
+}
+
+// writeRecorder records the individual writes to a ResponseRecorder.
+type writeRecorder struct {
+	*httptest.ResponseRecorder
+	writes []string
+}
+
+func (w *writeRecorder) Write(b []byte) (int, error) {
+	w.writes = append(w.writes, string(b))
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestForwardResponseHTTPBodyChunkSize(t *testing.T) {
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	marshaler := &runtime.HTTPBodyMarshaler{Marshaler: &runtime.JSONPb{}}
+	body := &httpbody.HttpBody{ContentType: "text/plain", Data: []byte("0123456789")}
+	wantWrites := []string{"0123", "4567", "89"}
+
+	for _, chunkSize := range []int{0, 4} {
+		mux := runtime.NewServeMux(runtime.WithHTTPBodyChunkSize(chunkSize))
+		want := []string{"0123456789"}
+		if chunkSize > 0 {
+			want = wantWrites
+		}
+
+		req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+		w := &writeRecorder{ResponseRecorder: httptest.NewRecorder()}
+		runtime.ForwardResponseMessage(ctx, mux, marshaler, w, req, body)
+		if got := w.Header().Get("Content-Type"); got != "text/plain" {
+			t.Errorf("Content-Type = %q; want %q", got, "text/plain")
+		}
+		if !reflect.DeepEqual(w.writes, want) {
+			t.Errorf("ForwardResponseMessage() with chunk size %d wrote %q; want %q", chunkSize, w.writes, want)
+		}
+
+		sent := false
+		recv := func() (proto.Message, error) {
+			if sent {
+				return nil, io.EOF
+			}
+			sent = true
+			return body, nil
+		}
+		w = &writeRecorder{ResponseRecorder: httptest.NewRecorder()}
+		runtime.ForwardResponseStream(ctx, mux, marshaler, w, req, recv)
+		if want := append(want, "\n"); !reflect.DeepEqual(w.writes, want) {
+			t.Errorf("ForwardResponseStream() with chunk size %d wrote %q; want %q", chunkSize, w.writes, want)
+		}
+	}
+}
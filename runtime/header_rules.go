@@ -0,0 +1,93 @@
+package runtime
+
+import (
+	"strings"
+)
+
+// OutgoingHeaderRules declares how gRPC response header and trailer metadata
+// is exposed to HTTP clients. Rules are evaluated in the order Drop, Promote,
+// Rename; keys matched by none of them are forwarded with the default
+// Grpc-Metadata- (or Grpc-Trailer-) prefix.
+//
+// All keys and prefixes are matched case-insensitively.
+type OutgoingHeaderRules struct {
+	// Drop lists metadata key prefixes which are never exposed, e.g. "x-internal-".
+	Drop []string
+	// Promote lists metadata keys which are exposed as first-class HTTP headers,
+	// i.e. without the default prefix.
+	Promote []string
+	// Rename maps metadata key prefixes to the HTTP header prefix replacing them,
+	// e.g. {"x-legacy-": "X-App-"}. Renamed keys are exposed without the default
+	// prefix. If several prefixes match a key, the longest one wins.
+	Rename map[string]string
+}
+
+// HeaderMatcher returns a HeaderMatcherFunc applying the rules to response header metadata.
+func (r OutgoingHeaderRules) HeaderMatcher() HeaderMatcherFunc {
+	return r.matcher(MetadataHeaderPrefix)
+}
+
+// TrailerMatcher returns a HeaderMatcherFunc applying the rules to response trailer metadata.
+func (r OutgoingHeaderRules) TrailerMatcher() HeaderMatcherFunc {
+	return r.matcher(MetadataTrailerPrefix)
+}
+
+func (r OutgoingHeaderRules) matcher(defaultPrefix string) HeaderMatcherFunc {
+	drop := lowerAll(r.Drop)
+	promote := make(map[string]bool, len(r.Promote))
+	for _, k := range r.Promote {
+		promote[strings.ToLower(k)] = true
+	}
+	rename := make(map[string]string, len(r.Rename))
+	for from, to := range r.Rename {
+		rename[strings.ToLower(from)] = to
+	}
+
+	return func(key string) (string, bool) {
+		key = strings.ToLower(key)
+		for _, p := range drop {
+			if strings.HasPrefix(key, p) {
+				return "", false
+			}
+		}
+		if promote[key] {
+			return key, true
+		}
+		var from string
+		for p := range rename {
+			if strings.HasPrefix(key, p) && len(p) > len(from) {
+				from = p
+			}
+		}
+		if from != "" {
+			return rename[from] + key[len(from):], true
+		}
+		return defaultPrefix + key, true
+	}
+}
+
+func lowerAll(ss []string) []string {
+	out := make([]string, len(ss))
+	for i, s := range ss {
+		out[i] = strings.ToLower(s)
+	}
+	return out
+}
+
+// WithOutgoingHeaderRules returns a ServeMuxOption configuring both the outgoing
+// header and trailer matchers from the given rules.
+func WithOutgoingHeaderRules(rules OutgoingHeaderRules) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.outgoingHeaderMatcher = rules.HeaderMatcher()
+		mux.outgoingTrailerMatcher = rules.TrailerMatcher()
+	}
+}
+
+// WithRouteOutgoingHeaderRules returns a RouteOption configuring both the outgoing
+// header and trailer matchers of this route from the given rules.
+func WithRouteOutgoingHeaderRules(rules OutgoingHeaderRules) RouteOption {
+	return func(o *routeOptions) {
+		o.outgoingHeaderMatcher = rules.HeaderMatcher()
+		o.outgoingTrailerMatcher = rules.TrailerMatcher()
+	}
+}
@@ -0,0 +1,78 @@
+package runtime_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	pb "github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestOutgoingHeaderRules(t *testing.T) {
+	rules := runtime.OutgoingHeaderRules{
+		Drop:    []string{"x-internal-"},
+		Promote: []string{"x-request-id"},
+		Rename: map[string]string{
+			"x-legacy-":     "X-App-",
+			"x-legacy-app-": "X-Old-",
+		},
+	}
+	matcher := rules.HeaderMatcher()
+	for _, spec := range []struct {
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{key: "x-internal-trace", wantOK: false},
+		{key: "X-Internal-Trace", wantOK: false},
+		{key: "x-request-id", want: "x-request-id", wantOK: true},
+		{key: "x-legacy-user", want: "X-App-user", wantOK: true},
+		{key: "x-legacy-app-name", want: "X-Old-name", wantOK: true},
+		{key: "foo", want: runtime.MetadataHeaderPrefix + "foo", wantOK: true},
+	} {
+		got, ok := matcher(spec.key)
+		if ok != spec.wantOK || got != spec.want {
+			t.Errorf("HeaderMatcher()(%q) = %q, %v; want %q, %v", spec.key, got, ok, spec.want, spec.wantOK)
+		}
+	}
+
+	if got, _ := rules.TrailerMatcher()("foo"); got != runtime.MetadataTrailerPrefix+"foo" {
+		t.Errorf("TrailerMatcher()(%q) = %q; want %q", "foo", got, runtime.MetadataTrailerPrefix+"foo")
+	}
+}
+
+func TestWithOutgoingHeaderRules(t *testing.T) {
+	mux := runtime.NewServeMux(runtime.WithOutgoingHeaderRules(runtime.OutgoingHeaderRules{
+		Drop:    []string{"x-internal-"},
+		Promote: []string{"x-request-id"},
+	}))
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		HeaderMD:  metadata.Pairs("x-internal-node", "n1", "x-request-id", "abc", "foo", "bar"),
+		TrailerMD: metadata.Pairs("x-internal-cost", "3", "baz", "qux"),
+	})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	runtime.ForwardResponseMessage(ctx, mux, &runtime.JSONPb{}, w, req, &pb.SimpleMessage{Id: "foo"})
+
+	want := http.Header{
+		"X-Request-Id":      {"abc"},
+		"Grpc-Metadata-Foo": {"bar"},
+		"Grpc-Trailer-Baz":  {"qux"},
+		"Trailer":           {"Grpc-Trailer-Baz"},
+		"Content-Type":      {"application/json"},
+	}
+	got := w.Header()
+	for k, v := range want {
+		if got.Get(k) != v[0] {
+			t.Errorf("header %s = %q; want %q", k, got.Get(k), v[0])
+		}
+	}
+	for k := range got {
+		if _, ok := want[k]; !ok {
+			t.Errorf("unexpected header %s = %q", k, got.Get(k))
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/grpclog"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// HealthConfig configures the health endpoints of a ServeMux, see
+// WithHealthEndpoints.
+type HealthConfig struct {
+	// LivenessPath is the path of the liveness endpoint. It defaults to
+	// "/healthz".
+	LivenessPath string
+	// ReadinessPath is the path of the readiness endpoint. It defaults to
+	// "/readyz".
+	ReadinessPath string
+	// RequireRouteSync makes the mux not ready until MarkRoutesSynced is
+	// called, e.g. once the initial routes of a ServeMuxDynamic are
+	// registered, so that no traffic is sent to an empty routing table.
+	RequireRouteSync bool
+	// UpstreamHealthPath is the path prefix of the endpoints passing health
+	// checks through to the grpc.health.v1.Health service of the upstreams
+	// added with AddUpstream, e.g. "/healthz/upstreams". GET
+	// <path>/<upstream> checks the overall health of the server of the
+	// upstream, and GET <path>/<upstream>/<service> the health of a service,
+	// replying 200 if it is SERVING and 503 otherwise. They are disabled if
+	// it is empty.
+	UpstreamHealthPath string
+	// UpstreamHealthTimeout limits the duration of the health checks of the
+	// upstreams. It defaults to 5 seconds.
+	UpstreamHealthTimeout time.Duration
+}
+
+// ReadinessCheck returns an error if a dependency of the gateway is not ready
+// to serve requests.
+type ReadinessCheck func(ctx context.Context) error
+
+// WithHealthEndpoints returns a ServeMuxOption serving a liveness endpoint,
+// which always replies 200, and a readiness endpoint, which replies 200 when
+// the routes are synced, see HealthConfig.RequireRouteSync, the upstream
+// connections added with AddUpstream are usable and the checks added with
+// AddReadinessCheck pass, and 503 otherwise, along with the states of the
+// upstreams, see ServeMux.UpstreamStates, as well as, if
+// HealthConfig.UpstreamHealthPath is set, an endpoint per upstream passing
+// health checks through to it. The endpoints are served before routing, so
+// they are neither authenticated nor subject to fault injection.
+func WithHealthEndpoints(config HealthConfig) ServeMuxOption {
+	return func(mux *ServeMux) {
+		if config.LivenessPath == "" {
+			config.LivenessPath = "/healthz"
+		}
+		if config.ReadinessPath == "" {
+			config.ReadinessPath = "/readyz"
+		}
+		if config.UpstreamHealthTimeout == 0 {
+			config.UpstreamHealthTimeout = 5 * time.Second
+		}
+		config.UpstreamHealthPath = strings.TrimSuffix(config.UpstreamHealthPath, "/")
+		mux.healthConfig = &config
+	}
+}
+
+// healthState is the state of the readiness of a ServeMux.
+type healthState struct {
+	mu        sync.Mutex
+	lastID    uint64
+	checks    map[string]healthCheck
+	upstreams map[string]upstream
+	synced    bool
+}
+
+type healthCheck struct {
+	id    uint64
+	check ReadinessCheck
+}
+
+type upstream struct {
+	id            uint64
+	conn          *grpc.ClientConn
+	lastError     string
+	lastErrorTime time.Time
+}
+
+// AddReadinessCheck adds "check" to the checks of the readiness endpoint,
+// replacing the check of the same name, and returns a function removing it.
+func (s *ServeMux) AddReadinessCheck(name string, check ReadinessCheck) (remove func()) {
+	s.health.mu.Lock()
+	defer s.health.mu.Unlock()
+	if s.health.checks == nil {
+		s.health.checks = make(map[string]healthCheck)
+	}
+	s.health.lastID++
+	id := s.health.lastID
+	s.health.checks[name] = healthCheck{id: id, check: check}
+	return func() {
+		s.health.mu.Lock()
+		defer s.health.mu.Unlock()
+		if c, ok := s.health.checks[name]; ok && c.id == id {
+			delete(s.health.checks, name)
+		}
+	}
+}
+
+// AddUpstream adds the connection to an upstream gRPC server, which the
+// readiness endpoint requires to be usable, replacing the upstream of the
+// same name, and returns a function removing it. Connections are usable
+// unless they are in the TRANSIENT_FAILURE or SHUTDOWN state.
+func (s *ServeMux) AddUpstream(name string, conn *grpc.ClientConn) (remove func()) {
+	s.health.mu.Lock()
+	defer s.health.mu.Unlock()
+	if s.health.upstreams == nil {
+		s.health.upstreams = make(map[string]upstream)
+	}
+	s.health.lastID++
+	id := s.health.lastID
+	s.health.upstreams[name] = upstream{id: id, conn: conn}
+	return func() {
+		s.health.mu.Lock()
+		defer s.health.mu.Unlock()
+		if u, ok := s.health.upstreams[name]; ok && u.id == id {
+			delete(s.health.upstreams, name)
+		}
+	}
+}
+
+// MarkRoutesSynced marks the routes of the mux as synced, see
+// HealthConfig.RequireRouteSync.
+func (s *ServeMux) MarkRoutesSynced() {
+	s.health.mu.Lock()
+	defer s.health.mu.Unlock()
+	s.health.synced = true
+}
+
+// readiness returns the failures of the readiness checks of s by name.
+func (s *ServeMux) readiness(ctx context.Context) map[string]string {
+	s.health.mu.Lock()
+	synced := s.health.synced
+	checks := make(map[string]ReadinessCheck, len(s.health.checks))
+	for name, c := range s.health.checks {
+		checks[name] = c.check
+	}
+	conns := make(map[string]*grpc.ClientConn, len(s.health.upstreams))
+	for name, u := range s.health.upstreams {
+		conns[name] = u.conn
+	}
+	s.health.mu.Unlock()
+
+	failures := make(map[string]string)
+	if s.healthConfig.RequireRouteSync && !synced {
+		failures["routes"] = "not synced"
+	}
+	for name, conn := range conns {
+		switch state := conn.GetState(); state {
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			failures["upstream/"+name] = state.String()
+		}
+	}
+	for name, check := range checks {
+		if err := check(ctx); err != nil {
+			failures[name] = err.Error()
+		}
+	}
+	return failures
+}
+
+// serveHealth serves the health endpoints of s, and reports whether r was
+// one of them.
+func (s *ServeMux) serveHealth(w http.ResponseWriter, r *http.Request) bool {
+	if s.healthConfig == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	var (
+		code = http.StatusOK
+		body = map[string]interface{}{"status": "ok"}
+	)
+	switch r.URL.Path {
+	case s.healthConfig.LivenessPath:
+	case s.healthConfig.ReadinessPath:
+		if failures := s.readiness(r.Context()); len(failures) > 0 {
+			code = http.StatusServiceUnavailable
+			body = map[string]interface{}{"status": "unavailable", "failures": failures}
+		}
+		if upstreams := s.upstreamStatesBody(); upstreams != nil {
+			body["upstreams"] = upstreams
+		}
+	default:
+		prefix := s.healthConfig.UpstreamHealthPath
+		if prefix == "" || !strings.HasPrefix(r.URL.Path, prefix+"/") {
+			return false
+		}
+		code, body = s.upstreamHealth(r.Context(), strings.TrimPrefix(r.URL.Path, prefix+"/"))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if r.Method == http.MethodHead {
+		return true
+	}
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		grpclog.Infof("Failed to write the health of the gateway: %v", err)
+	}
+	return true
+}
+
+// upstreamHealth checks the health of "target", an upstream optionally
+// followed by a slash and the name of a service, with the grpc.health.v1
+// service of the upstream.
+func (s *ServeMux) upstreamHealth(ctx context.Context, target string) (int, map[string]interface{}) {
+	name, service := target, ""
+	if i := strings.IndexByte(target, '/'); i >= 0 {
+		name, service = target[:i], target[i+1:]
+	}
+	s.health.mu.Lock()
+	u, ok := s.health.upstreams[name]
+	s.health.mu.Unlock()
+	if !ok {
+		return http.StatusNotFound, map[string]interface{}{"status": "unknown", "error": fmt.Sprintf("unknown upstream %q", name)}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.healthConfig.UpstreamHealthTimeout)
+	defer cancel()
+	resp, err := healthpb.NewHealthClient(u.conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		st, _ := status.FromError(err)
+		return http.StatusServiceUnavailable, map[string]interface{}{"status": "unavailable", "error": st.Message(), "code": st.Code().String()}
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return http.StatusServiceUnavailable, map[string]interface{}{"status": resp.GetStatus().String()}
+	}
+	return http.StatusOK, map[string]interface{}{"status": resp.GetStatus().String()}
+}
@@ -0,0 +1,203 @@
+package runtime_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func getHealth(t *testing.T, h http.Handler, path string) (int, map[string]interface{}) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("json.Unmarshal(%s) failed with %v; want success", w.Body, err)
+	}
+	return w.Code, body
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	mux := runtime.NewServeMuxDynamic(
+		runtime.WithHealthEndpoints(runtime.HealthConfig{RequireRouteSync: true}),
+		// Health endpoints are not subject to fault injection.
+		runtime.WithFaultInjection(runtime.FaultRule{Percentage: 100, Abort: codes.Unavailable}),
+	)
+
+	if code, _ := getHealth(t, mux, "/healthz"); code != http.StatusOK {
+		t.Errorf("GET /healthz replied %d; want %d", code, http.StatusOK)
+	}
+	code, body := getHealth(t, mux, "/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz replied %d; want %d before the routes are synced", code, http.StatusServiceUnavailable)
+	}
+	if failures, _ := body["failures"].(map[string]interface{}); failures["routes"] != "not synced" {
+		t.Errorf("GET /readyz replied %v; want the routes not synced", body)
+	}
+
+	mux.MarkRoutesSynced()
+	if code, body := getHealth(t, mux, "/readyz"); code != http.StatusOK {
+		t.Errorf("GET /readyz replied %d %v; want %d once the routes are synced", code, body, http.StatusOK)
+	}
+
+	remove := mux.AddReadinessCheck("cache", func(context.Context) error { return errors.New("warming up") })
+	code, body = getHealth(t, mux, "/readyz")
+	if failures, _ := body["failures"].(map[string]interface{}); code != http.StatusServiceUnavailable || failures["cache"] != "warming up" {
+		t.Errorf("GET /readyz replied %d %v; want the failure of the cache check", code, body)
+	}
+	remove()
+	if code, body := getHealth(t, mux, "/readyz"); code != http.StatusOK {
+		t.Errorf("GET /readyz replied %d %v; want %d once the check is removed", code, body, http.StatusOK)
+	}
+}
+
+// dialFailingUpstream returns a connection in the TRANSIENT_FAILURE state.
+func dialFailingUpstream(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	// Nothing listens on the port 1 of localhost, so the connection fails.
+	conn, err := grpc.Dial("localhost:1", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for state := conn.GetState(); state != connectivity.TransientFailure; state = conn.GetState() {
+		if !conn.WaitForStateChange(ctx, state) {
+			t.Fatalf("connection to localhost:1 is %v; want %v", state, connectivity.TransientFailure)
+		}
+	}
+	return conn
+}
+
+func TestHealthEndpointsUpstream(t *testing.T) {
+	mux := runtime.NewServeMux(runtime.WithHealthEndpoints(runtime.HealthConfig{ReadinessPath: "/ready"}))
+	conn := dialFailingUpstream(t)
+
+	remove := mux.AddUpstream("backend", conn)
+	code, body := getHealth(t, mux, "/ready")
+	if failures, _ := body["failures"].(map[string]interface{}); code != http.StatusServiceUnavailable || failures["upstream/backend"] != "TRANSIENT_FAILURE" {
+		t.Errorf("GET /ready replied %d %v; want the failure of the upstream", code, body)
+	}
+	remove()
+	if code, body := getHealth(t, mux, "/ready"); code != http.StatusOK {
+		t.Errorf("GET /ready replied %d %v; want %d once the upstream is removed", code, body, http.StatusOK)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /readyz replied %d; want %d with another readiness path", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestHealthEndpointsUpstreamPassthrough(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus("library.Books", healthpb.HealthCheckResponse_SERVING)
+	healthSrv.SetServingStatus("library.Shelves", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(srv, healthSrv)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	mux := runtime.NewServeMux(runtime.WithHealthEndpoints(runtime.HealthConfig{UpstreamHealthPath: "/healthz/upstreams/"}))
+	mux.AddUpstream("library", conn)
+
+	for _, spec := range []struct {
+		path   string
+		code   int
+		status string
+	}{
+		{path: "/healthz/upstreams/library", code: http.StatusOK, status: "SERVING"},
+		{path: "/healthz/upstreams/library/library.Books", code: http.StatusOK, status: "SERVING"},
+		{path: "/healthz/upstreams/library/library.Shelves", code: http.StatusServiceUnavailable, status: "NOT_SERVING"},
+		{path: "/healthz/upstreams/library/library.Unknown", code: http.StatusServiceUnavailable, status: "unavailable"},
+		{path: "/healthz/upstreams/unknown", code: http.StatusNotFound, status: "unknown"},
+	} {
+		code, body := getHealth(t, mux, spec.path)
+		if code != spec.code || body["status"] != spec.status {
+			t.Errorf("GET %s replied %d %v; want %d with status %q", spec.path, code, body, spec.code, spec.status)
+		}
+	}
+}
+
+func TestUnavailableUpstreams(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	go srv.Serve(lis)
+	defer srv.Stop()
+	up, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithBlock(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer up.Close()
+
+	mux := runtime.NewServeMuxDynamic(
+		runtime.WithHealthEndpoints(runtime.HealthConfig{}),
+		runtime.WithUnavailableUpstreamRetryAfter(1500*time.Millisecond),
+	)
+	mux.AddUpstream("down", dialFailingUpstream(t))
+	mux.AddUpstream("up", up)
+	fail := func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		runtime.HTTPError(r.Context(), mux.ServeMux, &runtime.JSONPb{}, w, r, status.Error(codes.Unavailable, "connection reset"))
+	}
+	if err := mux.HandlePath("GET", "/v1/down", fail, runtime.WithRouteUpstream("down")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.HandlePath("GET", "/v1/up", fail, runtime.WithRouteUpstream("up")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.HandlePath("GET", "/v1/either", fail, runtime.WithRouteUpstream("down"), runtime.WithRouteUpstream("up")); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{"/v1/down": "2", "/v1/up": "", "/v1/either": ""} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != want {
+			t.Errorf("GET %s replied %d with Retry-After %q; want %d with %q", path, w.Code, w.Header().Get("Retry-After"), http.StatusServiceUnavailable, want)
+		}
+	}
+
+	states := mux.UpstreamStates()
+	if st := states["down"]; st.State != connectivity.TransientFailure {
+		t.Errorf("state of down = %+v; want %v", st, connectivity.TransientFailure)
+	}
+	if st := states["up"]; st.State != connectivity.Ready || st.LastError != "connection reset" || st.LastErrorTime.IsZero() {
+		t.Errorf("state of up = %+v; want %v with the error of its route", st, connectivity.Ready)
+	}
+
+	_, body := getHealth(t, mux, "/readyz")
+	upstreams, _ := body["upstreams"].(map[string]interface{})
+	down, _ := upstreams["down"].(map[string]interface{})
+	upState, _ := upstreams["up"].(map[string]interface{})
+	if down["state"] != "TRANSIENT_FAILURE" || upState["state"] != "READY" || upState["last_error"] != "connection reset" {
+		t.Errorf("GET /readyz replied %v; want the states of the upstreams", body)
+	}
+}
@@ -0,0 +1,42 @@
+//go:build http3
+// +build http3
+
+package runtime
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// ListenAndServeHTTP3 serves "handler", e.g. a ServeMux, over HTTP/3 on the
+// UDP address "addr", e.g. ":443", with the certificates of "tlsConfig",
+// until ctx is done or the server fails. The gateway is usually served over
+// HTTP/1 and HTTP/2 on the TCP address "addr" too, with WithAltSvc
+// advertising the HTTP/3 endpoint, e.g. `h3=":443"; ma=86400`, so that
+// clients switch to it. Streamed responses are flushed as they are over
+// HTTP/2.
+//
+// It is only built with the "http3" build tag, so that the module does not
+// depend on quic-go otherwise: programs using it require
+// github.com/quic-go/quic-go (v0.34 or later) in their go.mod and are built
+// with -tags http3.
+func ListenAndServeHTTP3(ctx context.Context, addr string, tlsConfig *tls.Config, handler http.Handler) error {
+	srv := &http3.Server{Addr: addr, Handler: handler, TLSConfig: tlsConfig}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		if err := srv.Close(); err != nil {
+			return err
+		}
+		<-errc
+		return nil
+	}
+}
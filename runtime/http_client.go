@@ -0,0 +1,423 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// HTTPClient calls the REST API a gateway exposes, the way the typed HTTP
+// clients generated by protoc-gen-grpc-gateway with http_client=true do.
+// Requests carry the outgoing gRPC metadata of their context as
+// "Grpc-Metadata-" headers and its deadline as a "Grpc-Timeout" header.
+type HTTPClient struct {
+	baseURL      *url.URL
+	client       *http.Client
+	marshaler    Marshaler
+	header       http.Header
+	errorDecoder HTTPClientErrorDecoder
+}
+
+// HTTPClientOption is an option for NewHTTPClient.
+type HTTPClientOption func(*HTTPClient)
+
+// HTTPClientErrorDecoder returns the error of a response with a non-2xx status
+// code whose body is "body".
+type HTTPClientErrorDecoder func(resp *http.Response, body []byte, marshaler Marshaler) error
+
+// NewHTTPClient returns a client of the gateway at "baseURL", e.g.
+// "https://api.example.com/prefix". Path templates are resolved relative to
+// the path of "baseURL".
+func NewHTTPClient(baseURL string, opts ...HTTPClientOption) (*HTTPClient, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", baseURL, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme and host are required", baseURL)
+	}
+	c := &HTTPClient{
+		baseURL:      u,
+		client:       http.DefaultClient,
+		marshaler:    defaultMarshaler,
+		header:       make(http.Header),
+		errorDecoder: DefaultHTTPClientErrorDecoder,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// WithBaseHTTPClient sets the *http.Client sending the requests, instead of
+// http.DefaultClient.
+func WithBaseHTTPClient(client *http.Client) HTTPClientOption {
+	return func(c *HTTPClient) {
+		c.client = client
+	}
+}
+
+// WithHTTPClientMarshaler sets the marshaler encoding request bodies and
+// decoding response bodies. It should match the marshaler the gateway uses
+// for its content type; the default is the gateway's default marshaler.
+func WithHTTPClientMarshaler(marshaler Marshaler) HTTPClientOption {
+	return func(c *HTTPClient) {
+		c.marshaler = marshaler
+	}
+}
+
+// WithHTTPClientHeader adds a header sent with every request.
+func WithHTTPClientHeader(key, value string) HTTPClientOption {
+	return func(c *HTTPClient) {
+		c.header.Add(key, value)
+	}
+}
+
+// WithHTTPClientErrorDecoder sets the function returning the errors of
+// unsuccessful responses, e.g. for gateways with a custom error handler.
+func WithHTTPClientErrorDecoder(decoder HTTPClientErrorDecoder) HTTPClientOption {
+	return func(c *HTTPClient) {
+		c.errorDecoder = decoder
+	}
+}
+
+// DefaultHTTPClientErrorDecoder decodes the google.rpc.Status rendered by
+// DefaultHTTPErrorHandler into a gRPC status error. Bodies which are not a
+// status result in an error with the code corresponding to the HTTP status.
+func DefaultHTTPClientErrorDecoder(resp *http.Response, body []byte, marshaler Marshaler) error {
+	s := &spb.Status{}
+	if err := marshaler.Unmarshal(body, s); err == nil && s.GetCode() != int32(codes.OK) {
+		return status.ErrorProto(s)
+	}
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		msg = http.StatusText(resp.StatusCode)
+	}
+	return status.Error(codeFromHTTPStatus(resp.StatusCode), msg)
+}
+
+// codeFromHTTPStatus is the inverse of HTTPStatusFromCode, for responses not
+// carrying a status.
+func codeFromHTTPStatus(code int) codes.Code {
+	switch code {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusRequestTimeout:
+		return codes.Canceled
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Unknown
+}
+
+// Do sends a request with the HTTP method "method" to "path", which is
+// relative to the base URL, with the query parameters "query". "body", if not
+// nil, is marshaled into the request body, and a successful response body is
+// unmarshaled into "resp", if it is not nil.
+func (c *HTTPClient) Do(ctx context.Context, method, path string, query url.Values, body, resp interface{}) error {
+	ref, err := url.Parse(strings.TrimSuffix(c.baseURL.EscapedPath(), "/") + path)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid path %q: %v", path, err)
+	}
+	u := *c.baseURL
+	u.Path, u.RawPath, u.RawQuery = ref.Path, ref.RawPath, query.Encode()
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := c.marshaler.Marshal(body)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "failed to marshal request body: %v", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, u.String(), reqBody)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to create request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	for key, values := range c.header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", c.marshaler.ContentType(body))
+	}
+	req.Header.Set("Accept", c.marshaler.ContentType(resp))
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		for key, values := range md {
+			for _, v := range values {
+				req.Header.Add(MetadataHeaderPrefix+key, v)
+			}
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return status.Error(codes.DeadlineExceeded, context.DeadlineExceeded.Error())
+		}
+		req.Header.Set(metadataGrpcTimeout, strconv.FormatInt(int64(timeout/time.Millisecond)+1, 10)+"m")
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return status.FromContextError(ctxErr).Err()
+		}
+		return status.Errorf(codes.Unavailable, "%v", err)
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "failed to read response body: %v", err)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return c.errorDecoder(res, b, c.marshaler)
+	}
+	if resp == nil || len(b) == 0 {
+		return nil
+	}
+	if err := c.marshaler.Unmarshal(b, resp); err != nil {
+		return status.Errorf(codes.Internal, "failed to unmarshal response body: %v", err)
+	}
+	return nil
+}
+
+// ExpandPathTemplate returns the path matching the path template "tmpl" of
+// an HTTP rule, e.g. "/v1/{name=shelves/*}/books", with the variables set to
+// the fields of "msg" they refer to. Variables matching multiple segments keep
+// their slashes and repeated fields are joined by commas; anything else is
+// escaped.
+func ExpandPathTemplate(tmpl string, msg proto.Message) (string, error) {
+	var sb strings.Builder
+	for {
+		start := strings.IndexByte(tmpl, '{')
+		if start < 0 {
+			sb.WriteString(tmpl)
+			return sb.String(), nil
+		}
+		end := strings.IndexByte(tmpl[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("invalid path template %q: unterminated variable", tmpl)
+		}
+		end += start
+		sb.WriteString(tmpl[:start])
+
+		name, pattern := tmpl[start+1:end], "*"
+		if i := strings.IndexByte(name, '='); i >= 0 {
+			name, pattern = name[:i], name[i+1:]
+		}
+		value, err := pathParamValue(msg, name)
+		if err != nil {
+			return "", err
+		}
+		if value == "" {
+			return "", status.Errorf(codes.InvalidArgument, "missing path parameter %q", name)
+		}
+		sep := "/"
+		if pattern == "*" {
+			// Repeated fields are joined by commas, which stay literal.
+			sep = ","
+		}
+		segments := strings.Split(value, sep)
+		for i, s := range segments {
+			segments[i] = url.PathEscape(s)
+		}
+		sb.WriteString(strings.Join(segments, sep))
+		tmpl = tmpl[end+1:]
+	}
+}
+
+// pathParamValue returns the value of the field "fieldPath" of "msg" as a path
+// parameter. Repeated fields are joined by commas.
+func pathParamValue(msg proto.Message, fieldPath string) (string, error) {
+	m := msg.ProtoReflect()
+	names := strings.Split(fieldPath, ".")
+	for i, name := range names {
+		fd := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			return "", fmt.Errorf("no field %q in %q", fieldPath, msg.ProtoReflect().Descriptor().FullName())
+		}
+		if i < len(names)-1 {
+			if fd.Message() == nil || fd.Cardinality() == protoreflect.Repeated {
+				return "", fmt.Errorf("invalid path: %q is not a message", name)
+			}
+			m = m.Get(fd).Message()
+			continue
+		}
+		if fd.IsList() {
+			list := m.Get(fd).List()
+			values := make([]string, list.Len())
+			for j := range values {
+				s, err := formatQueryValue(fd, list.Get(j))
+				if err != nil {
+					return "", err
+				}
+				values[j] = s
+			}
+			return strings.Join(values, ","), nil
+		}
+		if fd.Message() != nil && !m.Has(fd) {
+			return "", nil
+		}
+		return formatQueryValue(fd, m.Get(fd))
+	}
+	return "", fmt.Errorf("no field path")
+}
+
+// QueryParametersFromMessage returns the query parameters encoding the
+// populated fields of "msg", as parsed by PopulateQueryParameters. Fields
+// whose path starts with one of the elements in "filter" are left out.
+func QueryParametersFromMessage(msg proto.Message, filter *utilities.DoubleArray) (url.Values, error) {
+	values := make(url.Values)
+	if err := appendQueryParameters(values, msg.ProtoReflect(), nil, filter); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func appendQueryParameters(values url.Values, m protoreflect.Message, prefix []string, filter *utilities.DoubleArray) error {
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		fieldPath := append(append([]string(nil), prefix...), string(fd.Name()))
+		if filter.HasCommonPrefix(fieldPath) {
+			return true
+		}
+		key := strings.Join(fieldPath, ".")
+		switch {
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				var s string
+				if s, err = formatQueryValue(fd, list.Get(i)); err != nil {
+					return false
+				}
+				values.Add(key, s)
+			}
+		case fd.IsMap():
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				var s string
+				if s, err = formatQueryValue(fd.MapValue(), mv); err != nil {
+					return false
+				}
+				values.Add(key+"["+k.String()+"]", s)
+				return true
+			})
+			if err != nil {
+				return false
+			}
+		case fd.Message() != nil && !isQueryScalarMessage(fd.Message()):
+			err = appendQueryParameters(values, v.Message(), fieldPath, filter)
+			if err != nil {
+				return false
+			}
+		default:
+			var s string
+			if s, err = formatQueryValue(fd, v); err != nil {
+				return false
+			}
+			values.Add(key, s)
+		}
+		return true
+	})
+	return err
+}
+
+// isQueryScalarMessage reports whether messages of type "md" are parsed from
+// a single query parameter by parseMessage.
+func isQueryScalarMessage(md protoreflect.MessageDescriptor) bool {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp", "google.protobuf.Duration", "google.protobuf.FieldMask",
+		"google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.Int32Value",
+		"google.protobuf.UInt64Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		return true
+	}
+	return false
+}
+
+// formatQueryValue formats "v", a value of the field "fd", as parsed by
+// parseField.
+func formatQueryValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) (string, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(v.Bool()), nil
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name()), nil
+		}
+		return strconv.Itoa(int(v.Enum())), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case protoreflect.FloatKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), nil
+	case protoreflect.DoubleKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	case protoreflect.StringKind:
+		return v.String(), nil
+	case protoreflect.BytesKind:
+		return base64.URLEncoding.EncodeToString(v.Bytes()), nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if !isQueryScalarMessage(fd.Message()) {
+			return "", fmt.Errorf("field %q of type %q cannot be encoded as a parameter", fd.FullName(), fd.Message().FullName())
+		}
+		msg := v.Message().Interface()
+		if fm, ok := msg.(*fieldmaskpb.FieldMask); ok {
+			return strings.Join(fm.GetPaths(), ","), nil
+		}
+		b, err := protojson.Marshal(msg)
+		if err != nil {
+			return "", err
+		}
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return string(b), nil
+		}
+		if fd.Message().FullName() == "google.protobuf.BytesValue" {
+			// protojson uses the standard encoding, parseMessage the URL-safe one.
+			raw, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return "", err
+			}
+			return base64.URLEncoding.EncodeToString(raw), nil
+		}
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown field kind: %v", fd.Kind())
+}
@@ -0,0 +1,182 @@
+package runtime_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestExpandPathTemplate(t *testing.T) {
+	msg := &examplepb.Proto3Message{
+		StringValue:    "shelves/1/books 2",
+		Int64Value:     42,
+		RepeatedEnum:   []examplepb.EnumValue{examplepb.EnumValue_X, examplepb.EnumValue_Y},
+		TimestampValue: timestamppb.New(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)),
+		Nested:         &examplepb.Proto3Message{StringValue: "nested"},
+	}
+	for _, spec := range []struct {
+		tmpl    string
+		want    string
+		wantErr bool
+	}{
+		{tmpl: "/v1/{int64_value}", want: "/v1/42"},
+		{tmpl: "/v1/{string_value}", want: "/v1/shelves%2F1%2Fbooks%202"},
+		{tmpl: "/v1/{string_value=shelves/*/books/*}:get", want: "/v1/shelves/1/books%202:get"},
+		{tmpl: "/v1/{string_value=**}", want: "/v1/shelves/1/books%202"},
+		{tmpl: "/v1/{nested.string_value}/{repeated_enum}", want: "/v1/nested/X,Y"},
+		{tmpl: "/v1/{timestamp_value}", want: "/v1/2020-01-02T03:04:05Z"},
+		{tmpl: "/v1/{uint64_value}", want: "/v1/0"},
+		{tmpl: "/v1/{nested.bytes_value}", wantErr: true},
+		{tmpl: "/v1/{unknown}", wantErr: true},
+		{tmpl: "/v1/{int64_value", wantErr: true},
+	} {
+		got, err := runtime.ExpandPathTemplate(spec.tmpl, msg)
+		if spec.wantErr {
+			if err == nil {
+				t.Errorf("runtime.ExpandPathTemplate(%q) = %q; want error", spec.tmpl, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("runtime.ExpandPathTemplate(%q) failed with %v; want success", spec.tmpl, err)
+			continue
+		}
+		if got != spec.want {
+			t.Errorf("runtime.ExpandPathTemplate(%q) = %q; want %q", spec.tmpl, got, spec.want)
+		}
+	}
+}
+
+func TestQueryParametersFromMessage(t *testing.T) {
+	msg := &examplepb.Proto3Message{
+		FloatValue:         1.5,
+		DoubleValue:        2.25,
+		Int64Value:         -3,
+		Uint32Value:        4,
+		BoolValue:          true,
+		StringValue:        "a b&c",
+		BytesValue:         []byte{0xfb, 0xff},
+		RepeatedValue:      []string{"x", "y"},
+		RepeatedMessage:    []*wrapperspb.UInt64Value{{Value: 5}, {Value: 6}},
+		RepeatedEnum:       []examplepb.EnumValue{examplepb.EnumValue_Y, examplepb.EnumValue_Z},
+		TimestampValue:     timestamppb.New(time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC)),
+		DurationValue:      durationpb.New(90 * time.Second),
+		FieldmaskValue:     &fieldmaskpb.FieldMask{Paths: []string{"float_value", "nested.string_value"}},
+		WrapperBytesValue:  wrapperspb.Bytes([]byte{0xfb, 0xff}),
+		WrapperStringValue: wrapperspb.String(""),
+		MapValue:           map[string]string{"k": "v"},
+		MapValue16:         map[string]*wrapperspb.UInt64Value{"k": {Value: 7}},
+		Nested: &examplepb.Proto3Message{
+			StringValue: "nested",
+			Int32Value:  8,
+		},
+	}
+	values, err := runtime.QueryParametersFromMessage(msg, utilities.NewDoubleArray([][]string{{"nested", "int32_value"}}))
+	if err != nil {
+		t.Fatalf("runtime.QueryParametersFromMessage(%v) failed with %v; want success", msg, err)
+	}
+	if got, want := values["nested.int32_value"], []string(nil); !cmp.Equal(got, want) {
+		t.Errorf("values[%q] = %q; want %q", "nested.int32_value", got, want)
+	}
+
+	got := &examplepb.Proto3Message{}
+	if err := runtime.PopulateQueryParameters(got, values, utilities.NewDoubleArray(nil)); err != nil {
+		t.Fatalf("runtime.PopulateQueryParameters(%v) failed with %v; want success", values, err)
+	}
+	msg.Nested.Int32Value = 0
+	if diff := cmp.Diff(got, msg, protocmp.Transform()); diff != "" {
+		t.Errorf("round trip of %v: %s", values, diff)
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	mux := runtime.NewServeMux()
+	pattern := runtime.MustPattern(runtime.NewPattern(1, []int{2, 0, 2, 1, 1, 0, 4, 1, 5, 2}, []string{"v1", "messages", "string_value"}, ""))
+	var gotHeader http.Header
+	var gotQuery url.Values
+	mux.Handle(http.MethodPost, pattern, func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		gotHeader, gotQuery = r.Header, r.URL.Query()
+		ctx := r.Context()
+		_, outbound := runtime.MarshalerForRequest(mux, r)
+		if pathParams["string_value"] == "missing" {
+			runtime.HTTPError(ctx, mux, outbound, w, r, status.Error(codes.NotFound, "no message missing"))
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in := &examplepb.Proto3Message{}
+		if err := outbound.Unmarshal(body, in); err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+		in.StringValue = pathParams["string_value"]
+		runtime.ForwardResponseMessage(ctx, mux, outbound, w, r, in)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := runtime.NewHTTPClient(server.URL+"/", runtime.WithHTTPClientHeader("X-Client", "test"))
+	if err != nil {
+		t.Fatalf("runtime.NewHTTPClient(%q) failed with %v; want success", server.URL, err)
+	}
+	ctx, cancel := context.WithTimeout(metadata.AppendToOutgoingContext(context.Background(), "tenant", "t1"), time.Minute)
+	defer cancel()
+
+	in := &examplepb.Proto3Message{StringValue: "a b", Int64Value: 3, Nested: &examplepb.Proto3Message{BoolValue: true}}
+	path, err := runtime.ExpandPathTemplate("/v1/messages/{string_value}", in)
+	if err != nil {
+		t.Fatalf("runtime.ExpandPathTemplate failed with %v; want success", err)
+	}
+	out := &examplepb.Proto3Message{}
+	if err := client.Do(ctx, http.MethodPost, path, url.Values{"q": {"1"}}, in.GetNested(), out); err != nil {
+		t.Fatalf("client.Do(%q) failed with %v; want success", path, err)
+	}
+	if want := (&examplepb.Proto3Message{StringValue: "a b", BoolValue: true}); !proto.Equal(out, want) {
+		t.Errorf("out = %v; want %v", out, want)
+	}
+	for key, want := range map[string]string{
+		"X-Client":             "test",
+		"Grpc-Metadata-Tenant": "t1",
+		"Content-Type":         "application/json",
+		"Accept":               "application/json",
+	} {
+		if got := gotHeader.Get(key); got != want {
+			t.Errorf("header %q = %q; want %q", key, got, want)
+		}
+	}
+	if gotHeader.Get("Grpc-Timeout") == "" {
+		t.Errorf("header %q is missing", "Grpc-Timeout")
+	}
+	if got, want := gotQuery.Get("q"), "1"; got != want {
+		t.Errorf("query %q = %q; want %q", "q", got, want)
+	}
+
+	err = client.Do(ctx, http.MethodPost, "/v1/messages/missing", nil, in, out)
+	if s := status.Convert(err); s.Code() != codes.NotFound || s.Message() != "no message missing" {
+		t.Errorf("client.Do(missing) failed with %v; want %v", err, status.Error(codes.NotFound, "no message missing"))
+	}
+	err = client.Do(ctx, http.MethodGet, "/v1/unknown", nil, nil, out)
+	if got, want := status.Code(err), codes.NotFound; got != want {
+		t.Errorf("client.Do(unknown) failed with %v; want code %v", err, want)
+	}
+}
@@ -0,0 +1,64 @@
+package runtime
+
+import (
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/api/httpbody"
+)
+
+// WithHTTPBodyChunkSize returns a ServeMuxOption which writes the data of
+// google.api.HttpBody responses to the client in chunks of at most size
+// bytes, flushing each chunk, instead of in a single write. It applies to
+// unary responses as well as to the messages of server streams.
+//
+// HttpBody responses are never copied into marshaling buffers: their data is
+// written to the http.ResponseWriter as is.
+func WithHTTPBodyChunkSize(size int) ServeMuxOption {
+	return func(serveMux *ServeMux) {
+		serveMux.httpBodyChunkSize = size
+	}
+}
+
+// rawHTTPBody returns v as a google.api.HttpBody if marshaler sends its data
+// as the response body.
+func rawHTTPBody(marshaler Marshaler, v interface{}) (*httpbody.HttpBody, bool) {
+	httpBody, ok := v.(*httpbody.HttpBody)
+	if !ok {
+		return nil, false
+	}
+	for {
+		switch m := marshaler.(type) {
+		case *HTTPBodyMarshaler:
+			return httpBody, true
+		case *delimitedMarshaler:
+			marshaler = m.Marshaler
+		case *envelopedMarshaler:
+			marshaler = m.Marshaler
+		default:
+			return nil, false
+		}
+	}
+}
+
+// writeHTTPBody writes data to w in chunks of the configured size.
+func (s *ServeMux) writeHTTPBody(w http.ResponseWriter, data []byte) error {
+	if s.httpBodyChunkSize <= 0 {
+		_, err := w.Write(data)
+		return err
+	}
+	f, _ := flusherOf(w)
+	for len(data) > 0 {
+		n := s.httpBodyChunkSize
+		if n > len(data) {
+			n = len(data)
+		}
+		if _, err := w.Write(data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+		if f != nil {
+			f.Flush()
+		}
+	}
+	return nil
+}
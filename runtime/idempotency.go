@@ -0,0 +1,88 @@
+package runtime
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/metadata"
+)
+
+// maxClientIdempotencyTokenLength is the maximum length of the idempotency
+// tokens accepted from clients.
+const maxClientIdempotencyTokenLength = 255
+
+// IdempotencyTokenConfig configures the idempotency tokens of a ServeMux, see
+// WithIdempotencyToken.
+type IdempotencyTokenConfig struct {
+	// MetadataKey is the key of the outgoing metadata carrying the token. It
+	// defaults to "x-idempotency-token".
+	MetadataKey string
+	// ResponseHeader is the name of the response header replying the token,
+	// for clients to correlate their requests with the logs of the backends.
+	// It defaults to "X-Idempotency-Token".
+	ResponseHeader string
+	// RequestHeader is the name of a request header, e.g. "Idempotency-Key",
+	// whose value is used as the token instead of a generated one, so that
+	// the retries of the clients are deduplicated too. Tokens longer than 255
+	// bytes are ignored.
+	RequestHeader string
+}
+
+// WithIdempotencyToken returns a ServeMuxOption attaching a token, generated
+// once per request, to the outgoing metadata of every request. Since the
+// metadata is sent with every attempt of a call, the backends can deduplicate
+// the attempts made when retries or hedging are enabled in the service config
+// of the connection.
+func WithIdempotencyToken(config IdempotencyTokenConfig) ServeMuxOption {
+	return func(mux *ServeMux) {
+		if config.MetadataKey == "" {
+			config.MetadataKey = "x-idempotency-token"
+		}
+		if config.ResponseHeader == "" {
+			config.ResponseHeader = "X-Idempotency-Token"
+		}
+		mux.idempotencyConfig = &config
+		mux.metadataAnnotators = append(mux.metadataAnnotators, func(ctx context.Context, req *http.Request) metadata.MD {
+			if token, ok := IdempotencyTokenFromContext(req.Context()); ok {
+				return metadata.Pairs(config.MetadataKey, token)
+			}
+			return nil
+		})
+	}
+}
+
+type idempotencyTokenKey struct{}
+
+// IdempotencyTokenFromContext returns the idempotency token of the request
+// attached by WithIdempotencyToken.
+func IdempotencyTokenFromContext(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(idempotencyTokenKey{}).(string)
+	return token, ok
+}
+
+// attachIdempotencyToken returns "r" carrying its idempotency token in its
+// context, and replies the token in the headers of "w".
+func (s *ServeMux) attachIdempotencyToken(w http.ResponseWriter, r *http.Request) *http.Request {
+	if s.idempotencyConfig == nil {
+		return r
+	}
+	var token string
+	if s.idempotencyConfig.RequestHeader != "" {
+		if v := r.Header.Get(s.idempotencyConfig.RequestHeader); len(v) <= maxClientIdempotencyTokenLength {
+			token = v
+		}
+	}
+	if token == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			grpclog.Errorf("Failed to generate an idempotency token: %v", err)
+			return r
+		}
+		token = base64.RawURLEncoding.EncodeToString(b)
+	}
+	w.Header().Set(s.idempotencyConfig.ResponseHeader, token)
+	return r.WithContext(context.WithValue(r.Context(), idempotencyTokenKey{}, token))
+}
@@ -0,0 +1,57 @@
+package runtime_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestIdempotencyToken(t *testing.T) {
+	mux := runtime.NewServeMux(runtime.WithIdempotencyToken(runtime.IdempotencyTokenConfig{RequestHeader: "Idempotency-Key"}))
+	var forwarded []string
+	err := mux.HandlePath("POST", "/v1/orders", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		ctx, err := runtime.AnnotateContext(r.Context(), mux, r, "/example.Orders/Create")
+		if err != nil {
+			t.Fatal(err)
+		}
+		md, _ := metadata.FromOutgoingContext(ctx)
+		forwarded = md.Get("x-idempotency-token")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", "/v1/orders", nil))
+		token := w.Header().Get("X-Idempotency-Token")
+		if token == "" || len(forwarded) != 1 || forwarded[0] != token {
+			t.Fatalf("X-Idempotency-Token = %q, forwarded %q; want the same generated token", token, forwarded)
+		}
+		tokens[token] = true
+	}
+	if len(tokens) != 2 {
+		t.Errorf("tokens = %v; want a new token per request", tokens)
+	}
+
+	for _, spec := range []struct {
+		key  string
+		kept bool
+	}{
+		{key: "order-42", kept: true},
+		{key: strings.Repeat("x", 256), kept: false},
+	} {
+		r := httptest.NewRequest("POST", "/v1/orders", nil)
+		r.Header.Set("Idempotency-Key", spec.key)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if got := w.Header().Get("X-Idempotency-Token"); (got == spec.key) != spec.kept || got == "" {
+			t.Errorf("X-Idempotency-Token = %q with the Idempotency-Key %q; want it kept: %v", got, spec.key, spec.kept)
+		}
+	}
+}
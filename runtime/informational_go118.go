@@ -0,0 +1,12 @@
+//go:build !go1.19
+// +build !go1.19
+
+package runtime
+
+import (
+	"net/http"
+)
+
+// writeInformational does nothing, since net/http only sends informational
+// responses from Go 1.19 on and treats them as final responses before.
+func writeInformational(w http.ResponseWriter, code int) {}
@@ -0,0 +1,13 @@
+//go:build go1.19
+// +build go1.19
+
+package runtime
+
+import (
+	"net/http"
+)
+
+// writeInformational sends an informational response with "code".
+func writeInformational(w http.ResponseWriter, code int) {
+	w.WriteHeader(code)
+}
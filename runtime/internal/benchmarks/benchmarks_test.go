@@ -0,0 +1,118 @@
+package benchmarks
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+var tableSizes = []int{100, 1000, 10000}
+
+func noopHandler(int) runtime.HandlerFunc {
+	return func(http.ResponseWriter, *http.Request, map[string]string) {}
+}
+
+// discardResponseWriter is an http.ResponseWriter which does not allocate.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+func requests(routes []Route) []*http.Request {
+	reqs := make([]*http.Request, len(routes))
+	for i, r := range routes {
+		reqs[i] = httptest.NewRequest(r.Method, r.Path, nil)
+	}
+	return reqs
+}
+
+func TestRouting(t *testing.T) {
+	routes := RoutingTable(100)
+	for _, m := range Muxes {
+		var served int
+		mux, err := m.New(routes, func(i int) runtime.HandlerFunc {
+			return func(http.ResponseWriter, *http.Request, map[string]string) {
+				served = i
+			}
+		})
+		if err != nil {
+			t.Fatalf("%s: New failed with %v; want success", m.Name, err)
+		}
+		// Twice, so that cached routes are served too.
+		for pass := 0; pass < 2; pass++ {
+			for i, req := range requests(routes) {
+				served = -1
+				w := httptest.NewRecorder()
+				mux.ServeHTTP(w, req)
+				if served != i {
+					t.Errorf("%s: %s %s served by route %d (status %d); want %d", m.Name, req.Method, req.URL.Path, served, w.Code, i)
+				}
+			}
+		}
+	}
+}
+
+// TestAllocations asserts the average number of allocations of serving the
+// routes of a table of 100 routes, so that regressions are caught.
+func TestAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not representative with the race detector")
+	}
+	maxAllocs := map[string]float64{
+		"ServeMux": 40,
+		// A cached route is served without matching any pattern; the
+		// remaining allocation is the lookup of the non-canonical
+		// X-HTTP-Method-Override header.
+		"ServeMuxRouteCache": 1,
+		"ServeMuxDynamic":    40,
+	}
+	routes := RoutingTable(100)
+	reqs := requests(routes)
+	for _, m := range Muxes {
+		max, ok := maxAllocs[m.Name]
+		if !ok {
+			continue
+		}
+		mux, err := m.New(routes, noopHandler)
+		if err != nil {
+			t.Fatalf("%s: New failed with %v; want success", m.Name, err)
+		}
+		w := &discardResponseWriter{header: make(http.Header)}
+		allocs := testing.AllocsPerRun(10, func() {
+			for _, req := range reqs {
+				mux.ServeHTTP(w, req)
+			}
+		}) / float64(len(reqs))
+		if allocs > max {
+			t.Errorf("%s: serving a route allocated %v times on average; want at most %v", m.Name, allocs, max)
+		}
+	}
+}
+
+func BenchmarkServeHTTP(b *testing.B) {
+	for _, n := range tableSizes {
+		routes := RoutingTable(n)
+		reqs := requests(routes)
+		for _, m := range Muxes {
+			mux, err := m.New(routes, noopHandler)
+			if err != nil {
+				b.Fatalf("%s: New failed with %v; want success", m.Name, err)
+			}
+			b.Run(fmt.Sprintf("%s/routes=%d", m.Name, n), func(b *testing.B) {
+				b.ReportAllocs()
+				b.RunParallel(func(pb *testing.PB) {
+					w := &discardResponseWriter{header: make(http.Header)}
+					for i := 0; pb.Next(); i++ {
+						mux.ServeHTTP(w, reqs[i%len(reqs)])
+					}
+				})
+			})
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !race
+// +build !race
+
+package benchmarks
+
+// raceEnabled reports whether the tests are built with the race detector,
+// which allocates on its own.
+const raceEnabled = false
@@ -0,0 +1,114 @@
+// Package benchmarks measures the routing performance of the runtime muxes
+// against realistic routing tables of 100, 1,000 and 10,000 routes.
+//
+// Run the benchmarks with
+//
+//	go test -run XXX -bench . -benchmem ./runtime/internal/benchmarks
+//
+// and compare the results of a change with benchstat. A new routing
+// implementation is benchmarked by adding it to Muxes.
+package benchmarks
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/httprule"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// Route is a route of a routing table.
+type Route struct {
+	// Method is the HTTP method of the route.
+	Method string
+	// Pattern is the path template of the route, e.g. "/v1/books/{id}".
+	Pattern string
+	// Path is a request path matched by the route.
+	Path string
+}
+
+// RoutingTable returns a routing table of n routes shaped like the ones
+// generated for REST APIs: collections and their resources, nested
+// collections, custom methods and deep wildcards.
+func RoutingTable(n int) []Route {
+	routes := make([]Route, 0, n)
+	for i := 0; i < n; i++ {
+		resource := fmt.Sprintf("resources%d", i/5)
+		var r Route
+		switch i % 5 {
+		case 0:
+			r = Route{"GET", "/v1/" + resource + "/{id}", "/v1/" + resource + "/1234"}
+		case 1:
+			r = Route{"GET", "/v1/" + resource, "/v1/" + resource}
+		case 2:
+			r = Route{"POST", "/v1/" + resource + "/{id}:cancel", "/v1/" + resource + "/1234:cancel"}
+		case 3:
+			r = Route{"GET", "/v1/" + resource + "/{parent}/items/{item}", "/v1/" + resource + "/1234/items/5678"}
+		case 4:
+			r = Route{"GET", "/v2/{name=" + resource + "/*/things/**}", "/v2/" + resource + "/1234/things/a/b/c"}
+		}
+		routes = append(routes, r)
+	}
+	return routes
+}
+
+// Mux is a routing implementation under benchmark.
+type Mux struct {
+	// Name identifies the implementation in benchmark names.
+	Name string
+	// New returns a mux serving routes, dispatching requests for routes[i]
+	// to handler(i).
+	New func(routes []Route, handler func(i int) runtime.HandlerFunc) (http.Handler, error)
+}
+
+// Muxes are the routing implementations which are benchmarked.
+var Muxes = []Mux{
+	{
+		Name: "ServeMux",
+		New: func(routes []Route, handler func(i int) runtime.HandlerFunc) (http.Handler, error) {
+			return newServeMux(routes, handler)
+		},
+	},
+	{
+		Name: "ServeMuxRouteCache",
+		New: func(routes []Route, handler func(i int) runtime.HandlerFunc) (http.Handler, error) {
+			return newServeMux(routes, handler,
+				runtime.WithRouteCache(len(routes)),
+				runtime.WithPooledPathParams(),
+			)
+		},
+	},
+	{
+		Name: "ServeMuxDynamic",
+		New: func(routes []Route, handler func(i int) runtime.HandlerFunc) (http.Handler, error) {
+			mux := runtime.NewServeMuxDynamic()
+			for i, r := range routes {
+				pat, err := pattern(r.Pattern)
+				if err != nil {
+					return nil, err
+				}
+				mux.Handle(r.Method, pat, handler(i))
+			}
+			return mux, nil
+		},
+	},
+}
+
+func newServeMux(routes []Route, handler func(i int) runtime.HandlerFunc, opts ...runtime.ServeMuxOption) (*runtime.ServeMux, error) {
+	mux := runtime.NewServeMux(opts...)
+	for i, r := range routes {
+		if err := mux.HandlePath(r.Method, r.Pattern, handler(i)); err != nil {
+			return nil, err
+		}
+	}
+	return mux, nil
+}
+
+func pattern(template string) (runtime.Pattern, error) {
+	compiler, err := httprule.Parse(template)
+	if err != nil {
+		return runtime.Pattern{}, err
+	}
+	tp := compiler.Compile()
+	return runtime.NewPattern(tp.Version, tp.OpCodes, tp.Pool, tp.Verb)
+}
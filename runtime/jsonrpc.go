@@ -0,0 +1,249 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// The error codes of JSON-RPC 2.0.
+const (
+	jsonRPCParseError     = -32700
+	jsonRPCInvalidRequest = -32600
+	jsonRPCMethodNotFound = -32601
+	jsonRPCInvalidParams  = -32602
+)
+
+// WithJSONRPCPath returns a ServeMuxOption making a ServeMuxDynamic serve a
+// JSON-RPC 2.0 endpoint at "path", e.g. "/rpc", whose methods are the routes
+// registered with ServeMuxDynamic.RegisterJSONRPCMethod. It has no effect on a
+// ServeMux.
+func WithJSONRPCPath(path string) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.jsonRPCPath = path
+	}
+}
+
+// JSONRPCMethod is the route a JSON-RPC method is mapped onto, as described
+// by a google.api.http option.
+type JSONRPCMethod struct {
+	// HTTPMethod is the HTTP method of the route, e.g. "POST".
+	HTTPMethod string
+	// Pattern is the path template of the route, e.g. "/v1/{name=books/*}".
+	// Its variables are taken from the params of the calls.
+	Pattern string
+	// Body is the field of the params sent as the body of the requests, or
+	// "*" for all the params which are not path variables. The other params
+	// are sent as query parameters.
+	Body string
+}
+
+// jsonRPCMethods holds the JSON-RPC methods registered on a ServeMuxDynamic.
+type jsonRPCMethods struct {
+	mu      sync.RWMutex
+	lastID  uint64
+	methods map[string]jsonRPCRegistration
+}
+
+type jsonRPCRegistration struct {
+	id     uint64
+	method JSONRPCMethod
+}
+
+// RegisterJSONRPCMethod maps the JSON-RPC method "name", e.g.
+// "library.v1.LibraryService.GetBook", onto the route "m", replacing the
+// method of the same name. Calls of the method are served as requests to the
+// route through the mux, so they are authenticated, authorized and marshaled
+// as such. It returns a function deregistering the method.
+func (s *ServeMuxDynamic) RegisterJSONRPCMethod(name string, m JSONRPCMethod) (deregister func(), err error) {
+	if _, err := parsePattern(m.Pattern); err != nil {
+		return nil, fmt.Errorf("invalid pattern of %s: %v", name, err)
+	}
+
+	s.jsonRPC.mu.Lock()
+	defer s.jsonRPC.mu.Unlock()
+
+	if s.jsonRPC.methods == nil {
+		s.jsonRPC.methods = make(map[string]jsonRPCRegistration)
+	}
+	s.jsonRPC.lastID++
+	id := s.jsonRPC.lastID
+	s.jsonRPC.methods[name] = jsonRPCRegistration{id: id, method: m}
+
+	return func() {
+		s.jsonRPC.mu.Lock()
+		defer s.jsonRPC.mu.Unlock()
+
+		if reg, ok := s.jsonRPC.methods[name]; ok && reg.id == id {
+			delete(s.jsonRPC.methods, name)
+		}
+	}, nil
+}
+
+// RegisterJSONRPCMethodsFromDescriptor registers a JSON-RPC method for every
+// method of "sd" with a google.api.http option, named after the full name of
+// the method, e.g. "library.v1.LibraryService.GetBook", and mapped onto its
+// main binding. It returns a function deregistering the methods.
+func (s *ServeMuxDynamic) RegisterJSONRPCMethodsFromDescriptor(sd protoreflect.ServiceDescriptor) (deregister func(), err error) {
+	var deregisters []func()
+	deregisterAll := func() {
+		for _, d := range deregisters {
+			d()
+		}
+	}
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if !ok || rule == nil {
+			continue
+		}
+		method, template := httpRuleRoute(rule)
+		if method == "" {
+			continue
+		}
+		d, err := s.RegisterJSONRPCMethod(string(md.FullName()), JSONRPCMethod{HTTPMethod: method, Pattern: template, Body: rule.GetBody()})
+		if err != nil {
+			deregisterAll()
+			return nil, err
+		}
+		deregisters = append(deregisters, d)
+	}
+	return deregisterAll, nil
+}
+
+type jsonRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type jsonRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonRPCError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type jsonRPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// serveJSONRPC serves the JSON-RPC endpoint of s.
+func (s *ServeMuxDynamic) serveJSONRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSONRPC(w, jsonRPCErrorResponse(nil, jsonRPCParseError, err.Error()))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		if resp, ok := s.callJSONRPC(r, body); ok {
+			writeJSONRPC(w, resp)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		writeJSONRPC(w, jsonRPCErrorResponse(nil, jsonRPCParseError, err.Error()))
+		return
+	}
+	if len(batch) == 0 {
+		writeJSONRPC(w, jsonRPCErrorResponse(nil, jsonRPCInvalidRequest, "empty batch"))
+		return
+	}
+	responses := make([]jsonRPCResponse, 0, len(batch))
+	for _, call := range batch {
+		if resp, ok := s.callJSONRPC(r, call); ok {
+			responses = append(responses, resp)
+		}
+	}
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSONRPC(w, responses)
+}
+
+func writeJSONRPC(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		grpclog.Infof("Failed to write JSON-RPC response: %v", err)
+	}
+}
+
+func jsonRPCErrorResponse(id json.RawMessage, code int, message string) jsonRPCResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return jsonRPCResponse{JSONRPC: "2.0", Error: &jsonRPCError{Code: code, Message: message}, ID: id}
+}
+
+// callJSONRPC calls the JSON-RPC method of "call", received in "r", and
+// returns its response, or false if the call is a notification.
+func (s *ServeMuxDynamic) callJSONRPC(r *http.Request, call json.RawMessage) (jsonRPCResponse, bool) {
+	var req jsonRPCRequest
+	if err := json.Unmarshal(call, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+		return jsonRPCErrorResponse(nil, jsonRPCInvalidRequest, "invalid request"), true
+	}
+	notification := req.ID == nil
+
+	s.jsonRPC.mu.RLock()
+	reg, ok := s.jsonRPC.methods[req.Method]
+	s.jsonRPC.mu.RUnlock()
+	if !ok {
+		return jsonRPCErrorResponse(req.ID, jsonRPCMethodNotFound, fmt.Sprintf("method %q not found", req.Method)), !notification
+	}
+
+	params := map[string]interface{}{}
+	if len(req.Params) > 0 && string(req.Params) != "null" {
+		dec := json.NewDecoder(bytes.NewReader(req.Params))
+		dec.UseNumber()
+		if err := dec.Decode(&params); err != nil {
+			return jsonRPCErrorResponse(req.ID, jsonRPCInvalidParams, "params must be an object"), !notification
+		}
+	}
+	binding := routeBinding{httpMethod: reg.method.HTTPMethod, pattern: reg.method.Pattern, body: reg.method.Body}
+	sub, err := binding.request(r, params)
+	if err != nil {
+		return jsonRPCErrorResponse(req.ID, jsonRPCInvalidParams, err.Error()), !notification
+	}
+
+	code, body := s.callRoute(sub)
+	if notification {
+		return jsonRPCResponse{}, false
+	}
+	if code >= 200 && code < 300 {
+		if len(body) == 0 || !json.Valid(body) {
+			body = []byte("null")
+		}
+		return jsonRPCResponse{JSONRPC: "2.0", Result: body, ID: req.ID}, true
+	}
+
+	// The code of the status of the error is the code of the JSON-RPC error.
+	st := statusFromErrorBody(code, body)
+	resp := jsonRPCErrorResponse(req.ID, int(st.Code()), st.Message())
+	if json.Valid(body) {
+		resp.Error.Data = body
+	}
+	return resp, true
+}
@@ -0,0 +1,216 @@
+package runtime_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func newJSONRPCTestMux(t *testing.T) *runtime.ServeMuxDynamic {
+	mux := runtime.NewServeMuxDynamic(runtime.WithJSONRPCPath("/rpc"))
+	echo := func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if len(body) == 0 {
+			body = []byte("null")
+		}
+		fmt.Fprintf(w, `{"method": %q, "name": %q, "query": %q, "body": %s}`, r.Method, pathParams["name"], r.URL.RawQuery, body)
+	}
+	if err := mux.HandlePath("GET", "/v1/{name=books/*}", echo); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.HandlePath("PATCH", "/v1/{name=books/*}", echo); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.HandlePath("GET", "/v1/shelves/{name}", echo); err != nil {
+		t.Fatal(err)
+	}
+	err := mux.HandlePath("DELETE", "/v1/{name=books/*}", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		_, outboundMarshaler := runtime.MarshalerForRequest(mux.ServeMux, r)
+		runtime.HTTPError(r.Context(), mux.ServeMux, outboundMarshaler, w, r, status.Errorf(codes.NotFound, "%s not found", pathParams["name"]))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, m := range map[string]runtime.JSONRPCMethod{
+		"example.Library.GetBook":    {HTTPMethod: "GET", Pattern: "/v1/{name=books/*}"},
+		"example.Library.UpdateBook": {HTTPMethod: "PATCH", Pattern: "/v1/{book.name=books/*}", Body: "book"},
+		"example.Library.DeleteBook": {HTTPMethod: "DELETE", Pattern: "/v1/{name=books/*}"},
+		"example.Library.GetShelf":   {HTTPMethod: "GET", Pattern: "/v1/shelves/{name}"},
+	} {
+		if _, err := mux.RegisterJSONRPCMethod(name, m); err != nil {
+			t.Fatalf("mux.RegisterJSONRPCMethod(%q, ...) failed with %v; want success", name, err)
+		}
+	}
+	return mux
+}
+
+func postJSONRPC(t *testing.T, mux http.Handler, body string) (int, interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/rpc", strings.NewReader(body)))
+	if w.Body.Len() == 0 {
+		return w.Code, nil
+	}
+	var resp interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json.Unmarshal(%s) failed with %v; want success", w.Body, err)
+	}
+	return w.Code, resp
+}
+
+func TestJSONRPC(t *testing.T) {
+	mux := newJSONRPCTestMux(t)
+
+	for _, spec := range []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "call",
+			body: `{"jsonrpc": "2.0", "id": 1, "method": "example.Library.GetBook", "params": {"name": "books/1", "view": "FULL", "fields": {"paths": ["a", "b"]}}}`,
+			want: `{"jsonrpc": "2.0", "id": 1, "result": {"method": "GET", "name": "books/1", "query": "fields.paths=a&fields.paths=b&view=FULL", "body": null}}`,
+		},
+		{
+			name: "body field",
+			body: `{"jsonrpc": "2.0", "id": "a", "method": "example.Library.UpdateBook", "params": {"book": {"name": "books/2", "title": "Dune"}, "update_mask": "title"}}`,
+			want: `{"jsonrpc": "2.0", "id": "a", "result": {"method": "PATCH", "name": "books/2", "query": "update_mask=title", "body": {"title": "Dune"}}}`,
+		},
+		{
+			name: "status error",
+			body: `{"jsonrpc": "2.0", "id": 2, "method": "example.Library.DeleteBook", "params": {"name": "books/3"}}`,
+			want: `{"jsonrpc": "2.0", "id": 2, "error": {"code": 5, "message": "books/3 not found", "data": {"code": 5, "message": "books/3 not found", "details": []}}}`,
+		},
+		{
+			name: "unknown method",
+			body: `{"jsonrpc": "2.0", "id": 3, "method": "example.Library.Unknown"}`,
+			want: `{"jsonrpc": "2.0", "id": 3, "error": {"code": -32601, "message": "method \"example.Library.Unknown\" not found"}}`,
+		},
+		{
+			name: "missing path param",
+			body: `{"jsonrpc": "2.0", "id": 4, "method": "example.Library.GetBook", "params": {}}`,
+			want: `{"jsonrpc": "2.0", "id": 4, "error": {"code": -32602, "message": "missing param \"name\""}}`,
+		},
+		{
+			name: "single segment param",
+			body: `{"jsonrpc": "2.0", "id": 5, "method": "example.Library.GetShelf", "params": {"name": "books/1"}}`,
+			want: `{"jsonrpc": "2.0", "id": 5, "result": {"method": "GET", "name": "books/1", "query": "", "body": null}}`,
+		},
+		{
+			name: "dot segment param",
+			body: `{"jsonrpc": "2.0", "id": 6, "method": "example.Library.GetBook", "params": {"name": "books/.."}}`,
+			want: `{"jsonrpc": "2.0", "id": 6, "error": {"code": -32602, "message": "invalid param \"name\""}}`,
+		},
+		{
+			name: "invalid request",
+			body: `{"method": "example.Library.GetBook"}`,
+			want: `{"jsonrpc": "2.0", "id": null, "error": {"code": -32600, "message": "invalid request"}}`,
+		},
+		{
+			name: "batch",
+			body: `[
+				{"jsonrpc": "2.0", "id": 1, "method": "example.Library.GetBook", "params": {"name": "books/1"}},
+				{"jsonrpc": "2.0", "method": "example.Library.GetBook", "params": {"name": "books/2"}},
+				{"jsonrpc": "2.0", "id": 3, "method": "example.Library.Unknown"}
+			]`,
+			want: `[
+				{"jsonrpc": "2.0", "id": 1, "result": {"method": "GET", "name": "books/1", "query": "", "body": null}},
+				{"jsonrpc": "2.0", "id": 3, "error": {"code": -32601, "message": "method \"example.Library.Unknown\" not found"}}
+			]`,
+		},
+		{
+			name: "empty batch",
+			body: `[]`,
+			want: `{"jsonrpc": "2.0", "id": null, "error": {"code": -32600, "message": "empty batch"}}`,
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			code, got := postJSONRPC(t, mux, spec.body)
+			var want interface{}
+			if err := json.Unmarshal([]byte(spec.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if code != http.StatusOK {
+				t.Errorf("code = %d; want %d", code, http.StatusOK)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("response differs (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if code, resp := postJSONRPC(t, mux, `{"jsonrpc": "2.0", "method": "example.Library.GetBook", "params": {"name": "books/1"}}`); code != http.StatusNoContent || resp != nil {
+		t.Errorf("notification replied %d %v; want %d", code, resp, http.StatusNoContent)
+	}
+	if code, resp := postJSONRPC(t, mux, `{`); code != http.StatusOK || resp.(map[string]interface{})["error"].(map[string]interface{})["code"] != float64(-32700) {
+		t.Errorf("invalid JSON replied %d %v; want a parse error", code, resp)
+	}
+}
+
+func TestJSONRPCDispatch(t *testing.T) {
+	var resolutions int
+	mux := runtime.NewServeMuxDynamic(
+		runtime.WithJSONRPCPath("/rpc"),
+		runtime.WithTenantResolution(runtime.TenantConfig{
+			Resolvers: []runtime.TenantResolver{func(r *http.Request) (string, error) {
+				resolutions++
+				return "acme", nil
+			}},
+		}),
+	)
+	err := mux.HandlePath("GET", "/v1/{name=books/*}", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		tenant, _ := runtime.TenantFromContext(r.Context())
+		fmt.Fprintf(w, "%q", tenant)
+	}, runtime.WithRouteTenants("acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mux.RegisterJSONRPCMethod("example.Library.GetBook", runtime.JSONRPCMethod{HTTPMethod: "GET", Pattern: "/v1/{name=books/*}"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, resp := postJSONRPC(t, mux, `{"jsonrpc": "2.0", "id": 1, "method": "example.Library.GetBook", "params": {"name": "books/1"}}`)
+	if got := resp.(map[string]interface{})["result"]; got != "acme" {
+		t.Errorf("result = %v; want %q", resp, "acme")
+	}
+	// The call is dispatched to its route without being served again.
+	if resolutions != 1 {
+		t.Errorf("resolutions = %d; want 1", resolutions)
+	}
+}
+
+func TestJSONRPCFromDescriptor(t *testing.T) {
+	file, err := protodesc.NewFile(newSecuredFile(t), protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile(...) failed with %v; want success", err)
+	}
+	mux := newJSONRPCTestMux(t)
+	deregister, err := mux.RegisterJSONRPCMethodsFromDescriptor(file.Services().Get(0))
+	if err != nil {
+		t.Fatalf("mux.RegisterJSONRPCMethodsFromDescriptor(...) failed with %v; want success", err)
+	}
+
+	body := `{"jsonrpc": "2.0", "id": 1, "method": "example.secured.BookService.GetBook", "params": {"name": "books/1"}}`
+	_, resp := postJSONRPC(t, mux, body)
+	if result, _ := resp.(map[string]interface{})["result"].(map[string]interface{}); result["name"] != "books/1" {
+		t.Errorf("response = %v; want the result of GET /v1/books/1", resp)
+	}
+
+	deregister()
+	_, resp = postJSONRPC(t, mux, body)
+	if e, _ := resp.(map[string]interface{})["error"].(map[string]interface{}); e["code"] != float64(-32601) {
+		t.Errorf("response = %v; want the method not found once deregistered", resp)
+	}
+}
@@ -0,0 +1,144 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// Listen listens on "address", which is one of:
+//
+//   - "unix:///path/to/socket" or "unix:path/to/socket", a Unix domain
+//     socket, replacing a stale socket file at the path,
+//   - "systemd:" or "systemd:<name>", the first socket passed by systemd
+//     socket activation, or the one named <name> with FileDescriptorName=,
+//   - "tcp://host:port" or "host:port", a TCP socket.
+func Listen(address string) (net.Listener, error) {
+	if path, ok := unixSocketPath(address); ok {
+		if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if conn, err := net.Dial("unix", path); err == nil {
+				conn.Close()
+				return nil, fmt.Errorf("unix socket %s is in use", path)
+			}
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+		return net.Listen("unix", path)
+	}
+	if strings.HasPrefix(address, "systemd:") {
+		return systemdListener(strings.TrimPrefix(address, "systemd:"))
+	}
+	return net.Listen("tcp", strings.TrimPrefix(address, "tcp://"))
+}
+
+// DialUpstream dials the upstream gRPC server at "target", which is either
+// "unix:///path/to/socket" or "unix:path/to/socket", a Unix domain socket, or
+// "tcp://host:port", or a gRPC target such as "dns:///host:port". Unix
+// domain sockets are dialed whichever the dial options, including a custom
+// dialer, which grpc.Dial would otherwise use instead.
+func DialUpstream(ctx context.Context, target string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	if path, ok := unixSocketPath(target); ok {
+		dialer := func(ctx context.Context, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+		opts = append(opts, grpc.WithContextDialer(dialer), grpc.WithAuthority("localhost"))
+		return grpc.DialContext(ctx, "passthrough:///"+path, opts...)
+	}
+	return grpc.DialContext(ctx, strings.TrimPrefix(target, "tcp://"), opts...)
+}
+
+// unixSocketPath returns the path of the Unix domain socket of "address",
+// and whether it is one.
+func unixSocketPath(address string) (string, bool) {
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		return strings.TrimPrefix(address, "unix://"), true
+	case strings.HasPrefix(address, "unix:"):
+		return strings.TrimPrefix(address, "unix:"), true
+	}
+	return "", false
+}
+
+// listenFDsStart is the first file descriptor passed by systemd.
+const listenFDsStart = 3
+
+var systemd struct {
+	once      sync.Once
+	listeners []net.Listener
+	names     []string
+	err       error
+}
+
+// SystemdListeners returns the sockets passed by systemd socket activation,
+// in the order of their declaration in the socket units, and their names. It
+// returns no listeners if the process was not socket activated. The
+// environment variables of socket activation are unset, so that child
+// processes do not inherit them.
+func SystemdListeners() ([]net.Listener, []string, error) {
+	systemd.once.Do(func() {
+		systemd.listeners, systemd.names, systemd.err = systemdListenersFrom(os.Getenv, listenFDsStart)
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	})
+	return systemd.listeners, systemd.names, systemd.err
+}
+
+// systemdListenersFrom returns the listeners of the file descriptors passed
+// in the environment "getenv" from "start" on, and their names.
+func systemdListenersFrom(getenv func(string) string, start int) ([]net.Listener, []string, error) {
+	if pid, err := strconv.Atoi(getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, nil, nil
+	}
+	n, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil, nil
+	}
+	names := strings.Split(getenv("LISTEN_FDNAMES"), ":")
+	listeners := make([]net.Listener, 0, n)
+	fdNames := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(start+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(start+i), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, nil, fmt.Errorf("socket %s passed by systemd: %w", name, err)
+		}
+		listeners = append(listeners, l)
+		fdNames = append(fdNames, name)
+	}
+	return listeners, fdNames, nil
+}
+
+// systemdListener returns the first socket passed by systemd if "name" is
+// empty, or the one named "name".
+func systemdListener(name string) (net.Listener, error) {
+	listeners, names, err := SystemdListeners()
+	if err != nil {
+		return nil, err
+	}
+	for i, l := range listeners {
+		if name == "" || names[i] == name {
+			return l, nil
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no socket passed by systemd")
+	}
+	return nil, fmt.Errorf("no socket named %q passed by systemd", name)
+}
@@ -0,0 +1,53 @@
+//go:build linux
+// +build linux
+
+package runtime
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestSystemdListenersFrom(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// The descriptor passed by systemd is closed by systemdListenersFrom.
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"LISTEN_PID":     strconv.Itoa(os.Getpid()),
+		"LISTEN_FDS":     "1",
+		"LISTEN_FDNAMES": "http",
+	}
+	getenv := func(k string) string { return env[k] }
+	listeners, names, err := systemdListenersFrom(getenv, fd)
+	if err != nil {
+		t.Fatalf("systemdListenersFrom(...) failed with %v; want success", err)
+	}
+	if len(listeners) != 1 || len(names) != 1 || names[0] != "http" {
+		t.Fatalf("systemdListenersFrom(...) = %v, %q; want a listener named %q", listeners, names, "http")
+	}
+	defer listeners[0].Close()
+	if got, want := listeners[0].Addr().String(), l.Addr().String(); got != want {
+		t.Errorf("listener address = %s; want %s", got, want)
+	}
+
+	env["LISTEN_PID"] = "1"
+	if listeners, _, err := systemdListenersFrom(getenv, -1); err != nil || len(listeners) != 0 {
+		t.Errorf("systemdListenersFrom(...) = %v, %v; want no listeners for another process", listeners, err)
+	}
+}
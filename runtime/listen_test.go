@@ -0,0 +1,77 @@
+package runtime_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestListenAndDialUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "upstream.sock")
+
+	lis, err := runtime.Listen("unix://" + path)
+	if err != nil {
+		t.Fatalf("runtime.Listen(%q) failed with %v; want success", "unix://"+path, err)
+	}
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	if _, err := runtime.Listen("unix:" + path); err == nil {
+		t.Errorf("runtime.Listen(%q) succeeded; want an error for a socket in use", "unix:"+path)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := runtime.DialUpstream(ctx, "unix://"+path, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("runtime.DialUpstream(%q) failed with %v; want success", "unix://"+path, err)
+	}
+	defer conn.Close()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Check() = %v, %v; want SERVING", resp, err)
+	}
+}
+
+func TestListenReplacesStaleUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "gateway.sock")
+
+	lis, err := runtime.Listen("unix:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Leave the socket file behind, as a crashed process would.
+	if ul, ok := lis.(interface{ SetUnlinkOnClose(bool) }); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+	lis.Close()
+
+	lis, err = runtime.Listen("unix:" + path)
+	if err != nil {
+		t.Fatalf("runtime.Listen(%q) failed with %v; want the stale socket replaced", "unix:"+path, err)
+	}
+	srv := &http.Server{Handler: runtime.NewServeMux()}
+	go srv.Serve(lis)
+	srv.Close()
+}
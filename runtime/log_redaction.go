@@ -0,0 +1,181 @@
+package runtime
+
+import (
+	"net/http"
+	"regexp"
+
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// defaultRedactedHeaders are the headers a Redactor always masks.
+var defaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// LogRedaction configures a Redactor, see WithLogRedaction.
+type LogRedaction struct {
+	// Headers are the names of the headers whose values are masked, in
+	// addition to Authorization, Proxy-Authorization, Cookie and Set-Cookie.
+	Headers []string
+	// FieldPaths are the dot separated paths of the message fields which are
+	// masked, made of proto field names relative to the root message, e.g.
+	// "credentials.password". Paths traverse repeated and map fields, so
+	// "users.email" masks the email of every element of users.
+	FieldPaths []string
+	// Patterns match the parts of strings which are masked, e.g. e-mail
+	// addresses or card numbers. They apply to free-form strings as well as
+	// to the string fields of messages.
+	Patterns []*regexp.Regexp
+	// Mask replaces redacted values. It defaults to "***".
+	Mask string
+}
+
+// Redactor masks sensitive data before it is written to logs or traces,
+// or rendered into error details. A nil *Redactor masks nothing.
+type Redactor struct {
+	headers  map[string]bool
+	paths    map[string]bool
+	patterns []*regexp.Regexp
+	mask     string
+	fields   *responseRedactor
+}
+
+// NewRedactor returns a Redactor configured by config.
+func NewRedactor(config LogRedaction) *Redactor {
+	if config.Mask == "" {
+		config.Mask = "***"
+	}
+	r := &Redactor{
+		headers:  make(map[string]bool, len(defaultRedactedHeaders)+len(config.Headers)),
+		paths:    make(map[string]bool, len(config.FieldPaths)),
+		patterns: config.Patterns,
+		mask:     config.Mask,
+		fields: &responseRedactor{
+			ResponseRedaction: ResponseRedaction{Mode: RedactMask, Mask: config.Mask},
+		},
+	}
+	for _, name := range append(defaultRedactedHeaders, config.Headers...) {
+		r.headers[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, path := range config.FieldPaths {
+		r.paths[path] = true
+	}
+	return r
+}
+
+// WithLogRedaction returns a ServeMuxOption which redacts sensitive data from
+// the status messages and details rendered by DefaultHTTPErrorHandler and by
+// the errors ending streamed responses. The lines the runtime logs through
+// grpclog are not redacted. The Redactor is available to access loggers,
+// tracing hooks and error handlers through ServeMux.Redactor, so that
+// credentials and PII never reach their output, even at verbose log levels.
+func WithLogRedaction(config LogRedaction) ServeMuxOption {
+	return func(serveMux *ServeMux) {
+		serveMux.logRedaction = NewRedactor(config)
+	}
+}
+
+// Redactor returns the Redactor configured with WithLogRedaction, or a
+// Redactor masking the default headers if there is none.
+func (s *ServeMux) Redactor() *Redactor {
+	if s.logRedaction == nil {
+		return NewRedactor(LogRedaction{})
+	}
+	return s.logRedaction
+}
+
+// Header returns a copy of h with the values of the redacted headers masked.
+func (r *Redactor) Header(h http.Header) http.Header {
+	if r == nil {
+		return h
+	}
+	redacted := make(http.Header, len(h))
+	for name, values := range h {
+		if !r.headers[http.CanonicalHeaderKey(name)] {
+			redacted[name] = append([]string(nil), values...)
+			continue
+		}
+		masked := make([]string, len(values))
+		for i := range masked {
+			masked[i] = r.mask
+		}
+		redacted[name] = masked
+	}
+	return redacted
+}
+
+// String returns s with the matches of the patterns masked.
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllLiteralString(s, r.mask)
+	}
+	return s
+}
+
+// Message returns a copy of m with the fields at the redacted paths masked
+// and the patterns masked in its string fields.
+func (r *Redactor) Message(m proto.Message) proto.Message {
+	if r == nil || m == nil {
+		return m
+	}
+	m = proto.Clone(m)
+	r.redactMessage(m.ProtoReflect(), "")
+	return m
+}
+
+// Status returns a copy of st with its message and details redacted. Details
+// of types which are not linked into the binary are left as is.
+func (r *Redactor) Status(st *status.Status) *status.Status {
+	if r == nil {
+		return st
+	}
+	pb := st.Proto()
+	pb.Message = r.String(pb.Message)
+	for i, detail := range pb.Details {
+		m, err := detail.UnmarshalNew()
+		if err != nil {
+			continue
+		}
+		if redacted, err := anypb.New(r.Message(m)); err == nil {
+			pb.Details[i] = redacted
+		}
+	}
+	return status.FromProto(pb)
+}
+
+func (r *Redactor) redactMessage(msg protoreflect.Message, prefix string) {
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		path := prefix + string(fd.Name())
+		switch {
+		case r.paths[path]:
+			r.fields.redactField(msg, fd)
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				break
+			}
+			v.Map().Range(func(_ protoreflect.MapKey, item protoreflect.Value) bool {
+				r.redactMessage(item.Message(), path+".")
+				return true
+			})
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				switch fd.Kind() {
+				case protoreflect.MessageKind, protoreflect.GroupKind:
+					r.redactMessage(list.Get(i).Message(), path+".")
+				case protoreflect.StringKind:
+					list.Set(i, protoreflect.ValueOfString(r.String(list.Get(i).String())))
+				}
+			}
+		case fd.Message() != nil:
+			r.redactMessage(v.Message(), path+".")
+		case fd.Kind() == protoreflect.StringKind && len(r.patterns) > 0:
+			msg.Set(fd, protoreflect.ValueOfString(r.String(v.String())))
+		}
+		return true
+	})
+}
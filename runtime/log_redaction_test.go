@@ -0,0 +1,171 @@
+package runtime_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+var emailPattern = regexp.MustCompile(`[a-z]+@example\.com`)
+
+func TestRedactorHeader(t *testing.T) {
+	r := runtime.NewRedactor(runtime.LogRedaction{Headers: []string{"x-api-key"}})
+	h := http.Header{
+		"Authorization": {"Bearer secret"},
+		"Cookie":        {"session=secret"},
+		"X-Api-Key":     {"secret", "secret"},
+		"Accept":        {"application/json"},
+	}
+	want := http.Header{
+		"Authorization": {"***"},
+		"Cookie":        {"***"},
+		"X-Api-Key":     {"***", "***"},
+		"Accept":        {"application/json"},
+	}
+	if diff := cmp.Diff(want, r.Header(h)); diff != "" {
+		t.Errorf("r.Header(%v) differed from want (-want, +got):\n%s", h, diff)
+	}
+	if got := h.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf(`h.Get("Authorization") = %q; want the original header to be left untouched`, got)
+	}
+}
+
+func TestRedactorMessage(t *testing.T) {
+	r := runtime.NewRedactor(runtime.LogRedaction{
+		FieldPaths: []string{"bytes_value", "single_nested.name", "nested.name", "mapped_nested_value.amount"},
+		Patterns:   []*regexp.Regexp{emailPattern},
+		Mask:       "[redacted]",
+	})
+	msg := &examplepb.ABitOfEverything{
+		Uuid:                "6EC2446F-7E89-4127-B3E6-5C05E6BECBA7",
+		StringValue:         "contact alice@example.com",
+		BytesValue:          []byte("secret"),
+		RepeatedStringValue: []string{"bob@example.com", "b"},
+		SingleNested:        &examplepb.ABitOfEverything_Nested{Name: "foo", Amount: 10},
+		Nested: []*examplepb.ABitOfEverything_Nested{
+			{Name: "bar", Amount: 20},
+		},
+		MappedNestedValue: map[string]*examplepb.ABitOfEverything_Nested{
+			"a": {Name: "baz", Amount: 30},
+		},
+	}
+	want := &examplepb.ABitOfEverything{
+		Uuid:                "6EC2446F-7E89-4127-B3E6-5C05E6BECBA7",
+		StringValue:         "contact [redacted]",
+		BytesValue:          []byte("[redacted]"),
+		RepeatedStringValue: []string{"[redacted]", "b"},
+		SingleNested:        &examplepb.ABitOfEverything_Nested{Name: "[redacted]", Amount: 10},
+		Nested: []*examplepb.ABitOfEverything_Nested{
+			{Name: "[redacted]", Amount: 20},
+		},
+		MappedNestedValue: map[string]*examplepb.ABitOfEverything_Nested{
+			"a": {Name: "baz"},
+		},
+	}
+	if diff := cmp.Diff(want, r.Message(msg), protocmp.Transform()); diff != "" {
+		t.Errorf("r.Message(%v) differed from want (-want, +got):\n%s", msg, diff)
+	}
+	if msg.StringValue != "contact alice@example.com" {
+		t.Errorf("msg.StringValue = %q; want the original message to be left untouched", msg.StringValue)
+	}
+}
+
+func TestWithLogRedaction(t *testing.T) {
+	st, err := status.New(codes.AlreadyExists, "alice@example.com is already registered").WithDetails(
+		&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "email", Description: "bob@example.com is already registered"},
+			},
+		},
+		&errdetails.DebugInfo{Detail: "token=secret"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, spec := range []struct {
+		name string
+		opts []runtime.ServeMuxOption
+		want []string
+	}{
+		{
+			name: "default",
+			want: []string{"alice@example.com", "bob@example.com", "token=secret"},
+		},
+		{
+			name: "redacted",
+			opts: []runtime.ServeMuxOption{
+				runtime.WithLogRedaction(runtime.LogRedaction{
+					FieldPaths: []string{"detail"},
+					Patterns:   []*regexp.Regexp{emailPattern},
+				}),
+			},
+			want: []string{"*** is already registered", `"detail":"***"`},
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			mux := runtime.NewServeMux(spec.opts...)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/", nil)
+			runtime.HTTPError(context.Background(), mux, &runtime.JSONPb{}, w, r, st.Err())
+
+			if w.Code != http.StatusConflict {
+				t.Errorf("w.Code = %d; want %d", w.Code, http.StatusConflict)
+			}
+			body := w.Body.String()
+			for _, want := range spec.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("w.Body = %s; want it to contain %q", body, want)
+				}
+			}
+			if len(spec.opts) > 0 && emailPattern.MatchString(body) {
+				t.Errorf("w.Body = %s; want e-mail addresses to be redacted", body)
+			}
+		})
+	}
+}
+
+func TestWithLogRedactionStream(t *testing.T) {
+	mux := runtime.NewServeMux(runtime.WithLogRedaction(runtime.LogRedaction{
+		Patterns: []*regexp.Regexp{emailPattern},
+	}))
+	var sent bool
+	recv := func() (proto.Message, error) {
+		if sent {
+			return nil, status.Error(codes.PermissionDenied, "alice@example.com cannot read the stream")
+		}
+		sent = true
+		return &examplepb.SimpleMessage{Id: "one"}, nil
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	runtime.ForwardResponseStream(ctx, mux, &runtime.JSONPb{}, w, r, recv)
+
+	body := w.Body.String()
+	if !strings.Contains(body, "*** cannot read the stream") || emailPattern.MatchString(body) {
+		t.Errorf("w.Body = %s; want the e-mail address of the stream error to be redacted", body)
+	}
+}
+
+func TestRedactorNil(t *testing.T) {
+	var r *runtime.Redactor
+	if got := r.String("alice@example.com"); got != "alice@example.com" {
+		t.Errorf(`r.String("alice@example.com") = %q; want the string as is`, got)
+	}
+	if got := runtime.NewServeMux().Redactor().Header(http.Header{"Authorization": {"Bearer secret"}}); got.Get("Authorization") != "***" {
+		t.Errorf(`mux.Redactor().Header(...).Get("Authorization") = %q; want "***"`, got.Get("Authorization"))
+	}
+}
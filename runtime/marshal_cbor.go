@@ -0,0 +1,430 @@
+package runtime
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// CBOR is a Marshaler which marshals/unmarshals into/from CBOR (RFC 8949).
+//
+// Messages are encoded as maps keyed by field name. Integers are encoded as
+// CBOR integers with their full 64-bit range (unlike JSON, no string
+// quoting is needed), bytes fields are encoded as byte strings and enums as
+// their numeric values. Output follows the core deterministic encoding
+// requirements: shortest integer and length arguments and map keys sorted by
+// their encoded form.
+//
+// CBOR data items are self-delimiting, so streamed messages are written
+// back-to-back without a delimiter.
+type CBOR struct {
+	// UseProtoNames uses proto field names instead of lowerCamelCase names as map keys.
+	UseProtoNames bool
+	// DiscardUnknown ignores unknown fields while unmarshaling instead of failing.
+	DiscardUnknown bool
+	// MaxMessageSize bounds the length of the strings read while
+	// unmarshaling, which cannot exceed the size of their message. It
+	// defaults to 4 MiB, the default maximum message size of gRPC servers.
+	MaxMessageSize int
+}
+
+// ContentType always returns "application/cbor".
+func (*CBOR) ContentType(_ interface{}) string {
+	return "application/cbor"
+}
+
+func (c *CBOR) options() structuredOptions {
+	return structuredOptions{useProtoNames: c.UseProtoNames, discardUnknown: c.DiscardUnknown}
+}
+
+// Marshal marshals "v" into CBOR.
+func (c *CBOR) Marshal(v interface{}) ([]byte, error) {
+	tree, err := c.options().toStructured(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := encodeCBOR(&buf, tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal unmarshals CBOR "data" into "v".
+func (c *CBOR) Unmarshal(data []byte, v interface{}) error {
+	r := bufio.NewReader(bytes.NewReader(data))
+	tree, err := decodeCBOR(r, 0, maxMessageSize(c.MaxMessageSize))
+	if err != nil {
+		return err
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		return errors.New("cbor: trailing data after data item")
+	}
+	return c.options().fromStructured(tree, v)
+}
+
+// NewDecoder returns a Decoder which reads a sequence of CBOR data items from "r".
+func (c *CBOR) NewDecoder(r io.Reader) Decoder {
+	br := bufio.NewReader(r)
+	return DecoderFunc(func(v interface{}) error {
+		tree, err := decodeCBOR(br, 0, maxMessageSize(c.MaxMessageSize))
+		if err != nil {
+			return err
+		}
+		return c.options().fromStructured(tree, v)
+	})
+}
+
+// NewEncoder returns an Encoder which writes a sequence of CBOR data items into "w".
+func (c *CBOR) NewEncoder(w io.Writer) Encoder {
+	return EncoderFunc(func(v interface{}) error {
+		buf, err := c.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(buf)
+		return err
+	})
+}
+
+// Delimiter returns an empty delimiter, CBOR data items are self-delimiting.
+func (*CBOR) Delimiter() []byte {
+	return []byte{}
+}
+
+const (
+	cborMajorUint   = 0
+	cborMajorNegInt = 1
+	cborMajorBytes  = 2
+	cborMajorText   = 3
+	cborMajorArray  = 4
+	cborMajorMap    = 5
+	cborMajorTag    = 6
+	cborMajorSimple = 7
+
+	cborFalse     = 0xf4
+	cborTrue      = 0xf5
+	cborNull      = 0xf6
+	cborUndefined = 0xf7
+	cborFloat16   = 0xf9
+	cborFloat32   = 0xfa
+	cborFloat64   = 0xfb
+	cborBreak     = 0xff
+
+	// cborMaxDepth bounds the nesting of decoded data items.
+	cborMaxDepth = 10000
+)
+
+func writeCBORHead(buf *bytes.Buffer, major byte, arg uint64) {
+	major <<= 5
+	switch {
+	case arg < 24:
+		buf.WriteByte(major | byte(arg))
+	case arg <= math.MaxUint8:
+		buf.Write([]byte{major | 24, byte(arg)})
+	case arg <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		_ = binary.Write(buf, binary.BigEndian, uint16(arg))
+	case arg <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		_ = binary.Write(buf, binary.BigEndian, uint32(arg))
+	default:
+		buf.WriteByte(major | 27)
+		_ = binary.Write(buf, binary.BigEndian, arg)
+	}
+}
+
+func encodeCBOR(buf *bytes.Buffer, tree interface{}) error {
+	switch v := tree.(type) {
+	case nil:
+		buf.WriteByte(cborNull)
+	case bool:
+		if v {
+			buf.WriteByte(cborTrue)
+		} else {
+			buf.WriteByte(cborFalse)
+		}
+	case int64:
+		if v < 0 {
+			writeCBORHead(buf, cborMajorNegInt, uint64(-(v + 1)))
+		} else {
+			writeCBORHead(buf, cborMajorUint, uint64(v))
+		}
+	case uint64:
+		writeCBORHead(buf, cborMajorUint, v)
+	case float32:
+		buf.WriteByte(cborFloat32)
+		_ = binary.Write(buf, binary.BigEndian, math.Float32bits(v))
+	case float64:
+		buf.WriteByte(cborFloat64)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case string:
+		writeCBORHead(buf, cborMajorText, uint64(len(v)))
+		buf.WriteString(v)
+	case []byte:
+		writeCBORHead(buf, cborMajorBytes, uint64(len(v)))
+		buf.Write(v)
+	case []interface{}:
+		writeCBORHead(buf, cborMajorArray, uint64(len(v)))
+		for _, item := range v {
+			if err := encodeCBOR(buf, item); err != nil {
+				return err
+			}
+		}
+	case structuredMap:
+		// Deterministic encoding requires map keys sorted by the bytewise
+		// lexicographic order of their encodings.
+		type encodedEntry struct {
+			key   []byte
+			value interface{}
+		}
+		entries := make([]encodedEntry, len(v))
+		for i, e := range v {
+			var kb bytes.Buffer
+			if err := encodeCBOR(&kb, e.key); err != nil {
+				return err
+			}
+			entries[i] = encodedEntry{key: kb.Bytes(), value: e.value}
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return bytes.Compare(entries[i].key, entries[j].key) < 0
+		})
+		writeCBORHead(buf, cborMajorMap, uint64(len(entries)))
+		for _, e := range entries {
+			buf.Write(e.key)
+			if err := encodeCBOR(buf, e.value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cbor: unsupported type %T", tree)
+	}
+	return nil
+}
+
+// readCBORArg reads the argument of a data item head whose additional
+// information is "info". Indefinite lengths are reported with ok == false.
+func readCBORArg(r *bufio.Reader, info byte) (arg uint64, ok bool, err error) {
+	switch {
+	case info < 24:
+		return uint64(info), true, nil
+	case info == 24:
+		b, err := r.ReadByte()
+		return uint64(b), true, err
+	case info == 25:
+		var v uint16
+		err := binary.Read(r, binary.BigEndian, &v)
+		return uint64(v), true, err
+	case info == 26:
+		var v uint32
+		err := binary.Read(r, binary.BigEndian, &v)
+		return uint64(v), true, err
+	case info == 27:
+		var v uint64
+		err := binary.Read(r, binary.BigEndian, &v)
+		return v, true, err
+	case info == 31:
+		return 0, false, nil
+	}
+	return 0, false, fmt.Errorf("cbor: invalid additional information %d", info)
+}
+
+// readCBORString reads a string of at most "limit" bytes.
+func readCBORString(r *bufio.Reader, major byte, info byte, limit int64) ([]byte, error) {
+	n, ok, err := readCBORArg(r, info)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if n > uint64(limit) {
+			return nil, fmt.Errorf("cbor: string of %d bytes exceeds the maximum message size", n)
+		}
+		return readFull(r, int64(n))
+	}
+	// Indefinite-length strings are a sequence of definite-length chunks.
+	var b []byte
+	for {
+		head, err := r.ReadByte()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if head == cborBreak {
+			return b, nil
+		}
+		if head>>5 != major || head&0x1f == 31 {
+			return nil, errors.New("cbor: invalid indefinite-length string chunk")
+		}
+		chunk, err := readCBORString(r, major, head&0x1f, limit-int64(len(b)))
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, chunk...)
+	}
+}
+
+// decodeCBOR decodes a data item whose strings are at most "limit" bytes long.
+func decodeCBOR(r *bufio.Reader, depth int, limit int64) (interface{}, error) {
+	if depth > cborMaxDepth {
+		return nil, errors.New("cbor: exceeded max nesting depth")
+	}
+	head, err := r.ReadByte()
+	if err != nil {
+		if depth > 0 {
+			return nil, unexpectedEOF(err)
+		}
+		return nil, err
+	}
+	major, info := head>>5, head&0x1f
+	switch major {
+	case cborMajorUint:
+		n, ok, err := readCBORArg(r, info)
+		if err != nil || !ok {
+			return nil, invalidCBORArg(err)
+		}
+		return n, nil
+	case cborMajorNegInt:
+		n, ok, err := readCBORArg(r, info)
+		if err != nil || !ok {
+			return nil, invalidCBORArg(err)
+		}
+		if n > math.MaxInt64 {
+			return nil, errors.New("cbor: negative integer overflows int64")
+		}
+		return -1 - int64(n), nil
+	case cborMajorBytes:
+		return readCBORString(r, major, info, limit)
+	case cborMajorText:
+		b, err := readCBORString(r, major, info, limit)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case cborMajorArray:
+		n, ok, err := readCBORArg(r, info)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		var l []interface{}
+		for i := uint64(0); !ok || i < n; i++ {
+			if !ok {
+				if b, err := r.Peek(1); err == nil && b[0] == cborBreak {
+					_, _ = r.ReadByte()
+					break
+				}
+			}
+			item, err := decodeCBOR(r, depth+1, limit)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			l = append(l, item)
+		}
+		if l == nil {
+			l = []interface{}{}
+		}
+		return l, nil
+	case cborMajorMap:
+		n, ok, err := readCBORArg(r, info)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		var m structuredMap
+		for i := uint64(0); !ok || i < n; i++ {
+			if !ok {
+				if b, err := r.Peek(1); err == nil && b[0] == cborBreak {
+					_, _ = r.ReadByte()
+					break
+				}
+			}
+			key, err := decodeCBOR(r, depth+1, limit)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			value, err := decodeCBOR(r, depth+1, limit)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			m = append(m, structuredEntry{key: key, value: value})
+		}
+		if m == nil {
+			m = structuredMap{}
+		}
+		return m, nil
+	case cborMajorTag:
+		// Tags only add semantics to the enclosed data item; decode it as is.
+		if _, _, err := readCBORArg(r, info); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return decodeCBOR(r, depth+1, limit)
+	}
+
+	switch head {
+	case cborFalse:
+		return false, nil
+	case cborTrue:
+		return true, nil
+	case cborNull, cborUndefined:
+		return nil, nil
+	case cborFloat16:
+		var v uint16
+		if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return float16ToFloat64(v), nil
+	case cborFloat32:
+		var v uint32
+		if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return math.Float32frombits(v), nil
+	case cborFloat64:
+		var v uint64
+		if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return math.Float64frombits(v), nil
+	}
+	return nil, fmt.Errorf("cbor: unsupported data item 0x%02x", head)
+}
+
+func float16ToFloat64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			v = math.Inf(1)
+		} else {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -v
+	}
+	return v
+}
+
+func invalidCBORArg(err error) error {
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	return errors.New("cbor: indefinite length not allowed for integers")
+}
+
+// unexpectedEOF reports a clean EOF inside a data item as io.ErrUnexpectedEOF,
+// so that decoders can tell a truncated item from the end of a stream.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,169 @@
+package runtime_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCBORMarshalUnmarshal(t *testing.T) {
+	m := &runtime.CBOR{}
+	for _, msg := range []proto.Message{
+		message,
+		&examplepb.Proto3Message{
+			Int64Value:   math.MinInt64,
+			Uint64Value:  math.MaxUint64,
+			Int32Value:   -24,
+			FloatValue:   1.5,
+			DoubleValue:  -2.25,
+			BytesValue:   []byte{0x00, 0xff, 0x10},
+			StringValue:  "héllo",
+			RepeatedEnum: []examplepb.EnumValue{examplepb.EnumValue_X, examplepb.EnumValue_Z},
+			MapValue:     map[string]string{"a": "b"},
+			MapValue9:    map[uint64]string{math.MaxUint64: "max"},
+			MapValue15:   map[bool]string{true: "yes"},
+		},
+	} {
+		buf, err := m.Marshal(msg)
+		if err != nil {
+			t.Fatalf("m.Marshal(%v) failed with %v; want success", msg, err)
+		}
+		got := msg.ProtoReflect().New().Interface()
+		if err := m.Unmarshal(buf, got); err != nil {
+			t.Fatalf("m.Unmarshal(%x) failed with %v; want success", buf, err)
+		}
+		if !proto.Equal(got, msg) {
+			t.Errorf("round trip = %v; want %v", got, msg)
+		}
+	}
+}
+
+func TestCBORMarshalEncoding(t *testing.T) {
+	m := &runtime.CBOR{UseProtoNames: true}
+	for _, spec := range []struct {
+		v    interface{}
+		want string
+	}{
+		{v: 0, want: "00"},
+		{v: 24, want: "1818"},
+		{v: -1, want: "20"},
+		{v: int64(math.MinInt64), want: "3b7fffffffffffffff"},
+		{v: uint64(math.MaxUint64), want: "1bffffffffffffffff"},
+		{v: "a", want: "6161"},
+		{v: []byte{1, 2}, want: "420102"},
+		{v: true, want: "f5"},
+		{v: nil, want: "f6"},
+		// Map keys are sorted by their encoded form, shortest first.
+		{v: map[string]int{"bb": 2, "a": 1}, want: "a2616101626262" + "02"},
+		{v: &examplepb.Proto3Message{Int64Value: -2, BytesValue: []byte{7}}, want: "a2" + "6b62797465735f76616c7565" + "4107" + "6b696e7436345f76616c7565" + "21"},
+	} {
+		buf, err := m.Marshal(spec.v)
+		if err != nil {
+			t.Errorf("m.Marshal(%v) failed with %v; want success", spec.v, err)
+			continue
+		}
+		if got := hex.EncodeToString(buf); got != spec.want {
+			t.Errorf("m.Marshal(%v) = %s; want %s", spec.v, got, spec.want)
+		}
+	}
+}
+
+func TestCBORUnmarshalIndefiniteLength(t *testing.T) {
+	// {_ "id": (_ "fo", "o")}
+	data, _ := hex.DecodeString("bf626964" + "7f62666f616fff" + "ff")
+	var got examplepb.SimpleMessage
+	if err := (&runtime.CBOR{}).Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal(%x) failed with %v; want success", data, err)
+	}
+	if got.Id != "foo" {
+		t.Errorf("got.Id = %q; want %q", got.Id, "foo")
+	}
+}
+
+func TestCBORUnmarshalErrors(t *testing.T) {
+	m := &runtime.CBOR{}
+	for _, data := range []string{
+		"",             // empty
+		"a1626964",     // truncated map
+		"a16269640a",   // wrong type for string field
+		"a1617a01",     // unknown field
+		"0000",         // trailing data
+		"1f",           // indefinite-length integer
+		"5a7fffffff",   // byte string longer than its data
+		"5f5affffffff", // chunk longer than the maximum message size
+	} {
+		b, _ := hex.DecodeString(data)
+		if err := m.Unmarshal(b, &examplepb.SimpleMessage{}); err == nil {
+			t.Errorf("m.Unmarshal(%s) succeeded; want failure", data)
+		}
+	}
+
+	b, _ := hex.DecodeString("a1617a01")
+	if err := (&runtime.CBOR{DiscardUnknown: true}).Unmarshal(b, &examplepb.SimpleMessage{}); err != nil {
+		t.Errorf("Unmarshal(%x) with DiscardUnknown failed with %v; want success", b, err)
+	}
+}
+
+func TestCBORMaxMessageSize(t *testing.T) {
+	m := &runtime.CBOR{MaxMessageSize: 3}
+	for _, data := range []string{
+		"a162696464616263",         // {"id": "abcd"}
+		"a16269647f626162626364ff", // {"id": "ab" "cd"}
+	} {
+		b, _ := hex.DecodeString(data)
+		if err := m.Unmarshal(b, &examplepb.SimpleMessage{}); err == nil {
+			t.Errorf("m.Unmarshal(%s) succeeded; want failure", data)
+		}
+	}
+	b, _ := hex.DecodeString("a1626964626162")
+	var got examplepb.SimpleMessage
+	if err := m.Unmarshal(b, &got); err != nil || got.Id != "ab" {
+		t.Errorf("m.Unmarshal(%x) = %v with Id %q; want success with %q", b, err, got.Id, "ab")
+	}
+}
+
+func TestCBORStream(t *testing.T) {
+	m := &runtime.CBOR{}
+	var buf bytes.Buffer
+	enc := m.NewEncoder(&buf)
+	for _, id := range []string{"one", "two"} {
+		if err := enc.Encode(&examplepb.SimpleMessage{Id: id}); err != nil {
+			t.Fatalf("enc.Encode(...) failed with %v; want success", err)
+		}
+	}
+
+	dec := m.NewDecoder(&buf)
+	for _, want := range []string{"one", "two"} {
+		var got examplepb.SimpleMessage
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("dec.Decode(...) failed with %v; want success", err)
+		}
+		if got.Id != want {
+			t.Errorf("got.Id = %q; want %q", got.Id, want)
+		}
+	}
+	if err := dec.Decode(&examplepb.SimpleMessage{}); err != io.EOF {
+		t.Errorf("dec.Decode(...) = %v; want %v", err, io.EOF)
+	}
+}
+
+func TestCBORNonProtoField(t *testing.T) {
+	m := &runtime.CBOR{}
+	buf, err := m.Marshal([]string{"a", "b"})
+	if err != nil {
+		t.Fatalf("m.Marshal(...) failed with %v; want success", err)
+	}
+	var got []string
+	if err := m.Unmarshal(buf, &got); err != nil {
+		t.Fatalf("m.Unmarshal(%x) failed with %v; want success", buf, err)
+	}
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("got = %v; want [a b]", got)
+	}
+}
@@ -0,0 +1,282 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// CSV is an output-only Marshaler which renders responses as CSV, intended
+// for export endpoints.
+//
+// Singular scalar fields become columns, singular message fields are
+// flattened into dotted column names (e.g. "author.name"), and repeated and
+// map fields are skipped. Well-known types such as google.protobuf.Timestamp
+// are rendered as a single column using their JSON representation.
+//
+// A unary response with exactly one repeated message field, like a typical
+// List response, is rendered as a header row followed by one row per element;
+// any other message is rendered as a header row and a single row. A server
+// stream is rendered as a header row followed by one row per streamed message.
+type CSV struct {
+	// Comma is the field delimiter. It defaults to ','.
+	Comma rune
+	// UseCRLF terminates rows with \r\n instead of \n.
+	UseCRLF bool
+	// UseProtoNames uses proto field names instead of lowerCamelCase names in the header row.
+	UseProtoNames bool
+	// OmitHeader disables the header row.
+	OmitHeader bool
+}
+
+// ContentType always returns "text/csv".
+func (*CSV) ContentType(_ interface{}) string {
+	return "text/csv"
+}
+
+// Marshal marshals "v" into CSV.
+func (c *CSV) Marshal(v interface{}) ([]byte, error) {
+	if rows, ok := c.streamRecord(v); ok {
+		return c.writeRecords(nil, rows)
+	}
+	md, rows, err := c.rows(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.writeRecords(c.header(md), rows)
+}
+
+// StreamPreamble returns the header row of a streamed response.
+func (c *CSV) StreamPreamble(first interface{}) ([]byte, error) {
+	rows, ok := c.streamRecord(first)
+	if !ok {
+		return nil, nil
+	}
+	return c.writeRecords(c.header(rows[0].Descriptor()), nil)
+}
+
+// streamRecord unwraps a chunk of a server stream, which ForwardResponseStream
+// marshals as a map with a single "result" or "error" entry.
+func (c *CSV) streamRecord(v interface{}) ([]protoreflect.Message, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String || rv.Len() != 1 {
+		return nil, false
+	}
+	for _, key := range []string{"result", "error"} {
+		item := rv.MapIndex(reflect.ValueOf(key))
+		if !item.IsValid() {
+			continue
+		}
+		m, ok := item.Interface().(proto.Message)
+		if !ok {
+			return nil, false
+		}
+		return []protoreflect.Message{m.ProtoReflect()}, true
+	}
+	return nil, false
+}
+
+// rows returns the descriptor of the rendered rows and the rows of v.
+func (c *CSV) rows(v interface{}) (protoreflect.MessageDescriptor, []protoreflect.Message, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, nil, errors.New("csv: unable to marshal non proto field")
+	}
+	msg := m.ProtoReflect()
+	var list protoreflect.FieldDescriptor
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !fd.IsList() || fd.Message() == nil || isWellKnownType(fd.Message()) {
+			continue
+		}
+		if list != nil {
+			// More than one repeated message field, not a list response.
+			return msg.Descriptor(), []protoreflect.Message{msg}, nil
+		}
+		list = fd
+	}
+	if list == nil {
+		return msg.Descriptor(), []protoreflect.Message{msg}, nil
+	}
+	items := msg.Get(list).List()
+	rows := make([]protoreflect.Message, items.Len())
+	for i := range rows {
+		rows[i] = items.Get(i).Message()
+	}
+	return list.Message(), rows, nil
+}
+
+func (c *CSV) writeRecords(header []string, rows []protoreflect.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if c.Comma != 0 {
+		w.Comma = c.Comma
+	}
+	w.UseCRLF = c.UseCRLF
+	if header != nil && !c.OmitHeader {
+		if err := w.Write(header); err != nil {
+			return nil, err
+		}
+	}
+	for _, row := range rows {
+		var record []string
+		c.appendValues(&record, row, nil)
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func (c *CSV) header(md protoreflect.MessageDescriptor) []string {
+	var header []string
+	c.appendColumns(&header, md, "", nil)
+	return header
+}
+
+// csvColumn reports whether fd is rendered in a column of its own and whether
+// it is a message which is flattened into several columns.
+func csvColumn(fd protoreflect.FieldDescriptor) (column bool, flatten bool) {
+	if fd.IsList() || fd.IsMap() {
+		return false, false
+	}
+	if md := fd.Message(); md != nil && !isWellKnownType(md) {
+		return false, true
+	}
+	return true, false
+}
+
+func isWellKnownType(md protoreflect.MessageDescriptor) bool {
+	return strings.HasPrefix(string(md.FullName()), "google.protobuf.")
+}
+
+// appendColumns appends the column names of md. Recursive message types are
+// only flattened once along a path.
+func (c *CSV) appendColumns(header *[]string, md protoreflect.MessageDescriptor, prefix string, seen []protoreflect.FullName) {
+	for _, name := range seen {
+		if name == md.FullName() {
+			return
+		}
+	}
+	seen = append(seen, md.FullName())
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		name := fd.JSONName()
+		if c.UseProtoNames {
+			name = fd.TextName()
+		}
+		switch column, flatten := csvColumn(fd); {
+		case column:
+			*header = append(*header, prefix+name)
+		case flatten:
+			c.appendColumns(header, fd.Message(), prefix+name+".", seen)
+		}
+	}
+}
+
+// appendValues appends the cells of msg in the order of appendColumns.
+// Unset nested messages produce empty cells.
+func (c *CSV) appendValues(record *[]string, msg protoreflect.Message, seen []protoreflect.FullName) {
+	md := msg.Descriptor()
+	for _, name := range seen {
+		if name == md.FullName() {
+			return
+		}
+	}
+	seen = append(seen, md.FullName())
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		switch column, flatten := csvColumn(fd); {
+		case column:
+			*record = append(*record, csvCell(fd, msg))
+		case flatten:
+			c.appendValues(record, msg.Get(fd).Message(), seen)
+		}
+	}
+}
+
+func csvCell(fd protoreflect.FieldDescriptor, msg protoreflect.Message) string {
+	if !msg.IsValid() {
+		return ""
+	}
+	if (fd.Message() != nil || fd.ContainingOneof() != nil) && !msg.Has(fd) {
+		return ""
+	}
+	v := msg.Get(fd)
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(v.Bool())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(v.Int(), 10)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(v.Uint(), 10)
+	case protoreflect.FloatKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32)
+	case protoreflect.DoubleKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case protoreflect.StringKind:
+		return v.String()
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(v.Bytes())
+	case protoreflect.MessageKind:
+		b, err := protojson.Marshal(v.Message().Interface())
+		if err != nil {
+			return ""
+		}
+		if s, err := strconv.Unquote(string(b)); err == nil {
+			return s
+		}
+		return string(b)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// Unmarshal is not supported by CSV.
+func (*CSV) Unmarshal(data []byte, v interface{}) error {
+	return errors.New("csv: unmarshaling is not supported")
+}
+
+// NewDecoder returns a Decoder which always fails, CSV is output-only.
+func (c *CSV) NewDecoder(r io.Reader) Decoder {
+	return DecoderFunc(func(v interface{}) error {
+		return c.Unmarshal(nil, v)
+	})
+}
+
+// NewEncoder returns an Encoder which writes CSV into "w".
+func (c *CSV) NewEncoder(w io.Writer) Encoder {
+	return EncoderFunc(func(v interface{}) error {
+		buf, err := c.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(buf)
+		return err
+	})
+}
+
+// Delimiter returns an empty delimiter, every row is already terminated.
+func (*CSV) Delimiter() []byte {
+	return []byte{}
+}
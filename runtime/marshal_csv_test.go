@@ -0,0 +1,110 @@
+package runtime_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCSVMarshal(t *testing.T) {
+	for _, spec := range []struct {
+		name string
+		m    *runtime.CSV
+		v    interface{}
+		want string
+	}{
+		{
+			name: "list response",
+			m:    &runtime.CSV{},
+			v: &examplepb.RepeatedResponseBodyOut{
+				Response: []*examplepb.RepeatedResponseBodyOut_Response{
+					{Data: "a", Type: examplepb.RepeatedResponseBodyOut_Response_A},
+					{Data: "b,c", Type: examplepb.RepeatedResponseBodyOut_Response_B},
+				},
+			},
+			want: "data,type\na,A\n\"b,c\",B\n",
+		},
+		{
+			name: "nested message",
+			m:    &runtime.CSV{Comma: ';', UseProtoNames: true},
+			v:    &examplepb.ResponseBodyOut{Response: &examplepb.ResponseBodyOut_Response{Data: "x"}},
+			want: "response.data\nx\n",
+		},
+		{
+			name: "unset nested message",
+			m:    &runtime.CSV{},
+			v:    &examplepb.ResponseBodyOut{},
+			want: "response.data\n\n",
+		},
+		{
+			name: "empty list",
+			m:    &runtime.CSV{},
+			v:    &examplepb.RepeatedResponseBodyOut{},
+			want: "data,type\n",
+		},
+		{
+			name: "crlf without header",
+			m:    &runtime.CSV{UseCRLF: true, OmitHeader: true},
+			v:    &examplepb.SimpleMessage{Id: "x"},
+			want: "x\r\n",
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			buf, err := spec.m.Marshal(spec.v)
+			if err != nil {
+				t.Fatalf("m.Marshal(%v) failed with %v; want success", spec.v, err)
+			}
+			if string(buf) != spec.want {
+				t.Errorf("m.Marshal(%v) = %q; want %q", spec.v, buf, spec.want)
+			}
+		})
+	}
+}
+
+func TestCSVMarshalWellKnownTypes(t *testing.T) {
+	m := &runtime.CSV{OmitHeader: true}
+	buf, err := m.Marshal(&examplepb.UpdateMessage{UpdateMask: &field_mask.FieldMask{Paths: []string{"a", "b.c"}}})
+	if err != nil {
+		t.Fatalf("m.Marshal(...) failed with %v; want success", err)
+	}
+	if want := `"a,b.c",`; !strings.HasPrefix(string(buf), want) {
+		t.Errorf("m.Marshal(...) = %q; want prefix %q", buf, want)
+	}
+}
+
+func TestCSVStream(t *testing.T) {
+	m := &runtime.CSV{}
+	msgs := []proto.Message{&examplepb.SimpleMessage{Id: "one"}, &examplepb.SimpleMessage{Id: "two"}}
+	recv := func() (proto.Message, error) {
+		if len(msgs) == 0 {
+			return nil, io.EOF
+		}
+		msg := msgs[0]
+		msgs = msgs[1:]
+		return msg, nil
+	}
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	resp := httptest.NewRecorder()
+	runtime.ForwardResponseStream(ctx, runtime.NewServeMux(), m, resp, req, recv)
+
+	if got, want := resp.Body.String(), "id\none\ntwo\n"; got != want {
+		t.Errorf("body = %q; want %q", got, want)
+	}
+	if got, want := resp.Header().Get("Content-Type"), "text/csv"; got != want {
+		t.Errorf("Content-Type = %q; want %q", got, want)
+	}
+}
+
+func TestCSVUnmarshal(t *testing.T) {
+	if err := (&runtime.CSV{}).Unmarshal([]byte("id\nx\n"), &examplepb.SimpleMessage{}); err == nil {
+		t.Errorf("Unmarshal(...) succeeded; want failure")
+	}
+}
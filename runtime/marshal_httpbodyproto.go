@@ -1,6 +1,8 @@
 package runtime
 
 import (
+	"bytes"
+
 	"google.golang.org/genproto/googleapis/api/httpbody"
 )
 
@@ -30,3 +32,13 @@ func (h *HTTPBodyMarshaler) Marshal(v interface{}) ([]byte, error) {
 	}
 	return h.Marshaler.Marshal(v)
 }
+
+// marshalBuffer appends the body bytes to buf if v is a google.api.HttpBody
+// message, and the marshaled form of v otherwise.
+func (h *HTTPBodyMarshaler) marshalBuffer(buf *bytes.Buffer, v interface{}) error {
+	if httpBody, ok := v.(*httpbody.HttpBody); ok {
+		buf.Write(httpBody.Data)
+		return nil
+	}
+	return marshalToBuffer(h.Marshaler, buf, v)
+}
@@ -20,8 +20,32 @@ import (
 type JSONPb struct {
 	protojson.MarshalOptions
 	protojson.UnmarshalOptions
+
+	// Int64Encoding controls how 64-bit integer fields are rendered.
+	Int64Encoding Int64Encoding
+	// TimeEncoding controls how timestamps and durations are rendered and parsed.
+	TimeEncoding TimeEncoding
+	// AnyRenderer, if set, renders google.protobuf.Any values.
+	AnyRenderer AnyRenderer
 }
 
+// Int64Encoding is how JSONPb renders 64-bit integers, i.e. int64, uint64,
+// sint64, fixed64 and sfixed64 fields as well as google.protobuf.Int64Value
+// and google.protobuf.UInt64Value. Both encodings are accepted when unmarshaling.
+type Int64Encoding int
+
+const (
+	// Int64AsString renders 64-bit integers as JSON strings, as specified by
+	// the proto3 JSON mapping.
+	Int64AsString Int64Encoding = iota
+	// Int64AsNumber renders 64-bit integers as JSON numbers. Clients parsing
+	// numbers as IEEE 754 doubles lose precision beyond 2^53.
+	Int64AsNumber
+	// Int64AsSafeNumber renders 64-bit integers as JSON numbers if they can be
+	// represented exactly by IEEE 754 doubles, and as strings otherwise.
+	Int64AsSafeNumber
+)
+
 // ContentType always returns "application/json".
 func (*JSONPb) ContentType(_ interface{}) string {
 	return "application/json"
@@ -29,15 +53,16 @@ func (*JSONPb) ContentType(_ interface{}) string {
 
 // Marshal marshals "v" into JSON.
 func (j *JSONPb) Marshal(v interface{}) ([]byte, error) {
-	if _, ok := v.(proto.Message); !ok {
+	p, ok := v.(proto.Message)
+	if !ok {
 		return j.marshalNonProtoField(v)
 	}
+	return j.marshalProto(p)
+}
 
-	var buf bytes.Buffer
-	if err := j.marshalTo(&buf, v); err != nil {
-		return nil, err
-	}
-	return buf.Bytes(), nil
+// marshalBuffer appends the JSON encoding of "v" to buf.
+func (j *JSONPb) marshalBuffer(buf *bytes.Buffer, v interface{}) error {
+	return j.marshalTo(buf, v)
 }
 
 func (j *JSONPb) marshalTo(w io.Writer, v interface{}) error {
@@ -50,7 +75,7 @@ func (j *JSONPb) marshalTo(w io.Writer, v interface{}) error {
 		_, err = w.Write(buf)
 		return err
 	}
-	b, err := j.MarshalOptions.Marshal(p)
+	b, err := j.marshalProto(p)
 	if err != nil {
 		return err
 	}
@@ -59,6 +84,21 @@ func (j *JSONPb) marshalTo(w io.Writer, v interface{}) error {
 	return err
 }
 
+func (j *JSONPb) marshalProto(p proto.Message) ([]byte, error) {
+	marshaler := j.MarshalOptions
+	if j.AnyRenderer != nil {
+		marshaler.Resolver = anyFallbackResolver{j.anyResolver()}
+	}
+	b, err := marshaler.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	if rw := j.jsonValueRewriter(); rw != nil || j.AnyRenderer != nil {
+		return j.rewriteJSON(p.ProtoReflect(), b, rw)
+	}
+	return b, nil
+}
+
 var (
 	// protoMessageType is stored to prevent constant lookup of the same type at runtime.
 	protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
@@ -137,7 +177,7 @@ func (j *JSONPb) marshalNonProtoField(v interface{}) ([]byte, error) {
 
 // Unmarshal unmarshals JSON "data" into "v"
 func (j *JSONPb) Unmarshal(data []byte, v interface{}) error {
-	return unmarshalJSONPb(data, j.UnmarshalOptions, v)
+	return unmarshalJSONPb(data, j.UnmarshalOptions, j.jsonUnmarshalRewriter(), v)
 }
 
 // NewDecoder returns a Decoder which reads JSON stream from "r".
@@ -146,6 +186,7 @@ func (j *JSONPb) NewDecoder(r io.Reader) Decoder {
 	return DecoderWrapper{
 		Decoder:          d,
 		UnmarshalOptions: j.UnmarshalOptions,
+		rewriter:         j.jsonUnmarshalRewriter(),
 	}
 }
 
@@ -154,12 +195,14 @@ func (j *JSONPb) NewDecoder(r io.Reader) Decoder {
 type DecoderWrapper struct {
 	*json.Decoder
 	protojson.UnmarshalOptions
+
+	rewriter jsonValueRewriter
 }
 
 // Decode wraps the embedded decoder's Decode method to support
 // protos using a jsonpb.Unmarshaler.
 func (d DecoderWrapper) Decode(v interface{}) error {
-	return decodeJSONPb(d.Decoder, d.UnmarshalOptions, v)
+	return decodeJSONPb(d.Decoder, d.UnmarshalOptions, d.rewriter, v)
 }
 
 // NewEncoder returns an Encoder which writes JSON stream into "w".
@@ -175,15 +218,15 @@ func (j *JSONPb) NewEncoder(w io.Writer) Encoder {
 	})
 }
 
-func unmarshalJSONPb(data []byte, unmarshaler protojson.UnmarshalOptions, v interface{}) error {
+func unmarshalJSONPb(data []byte, unmarshaler protojson.UnmarshalOptions, rw jsonValueRewriter, v interface{}) error {
 	d := json.NewDecoder(bytes.NewReader(data))
-	return decodeJSONPb(d, unmarshaler, v)
+	return decodeJSONPb(d, unmarshaler, rw, v)
 }
 
-func decodeJSONPb(d *json.Decoder, unmarshaler protojson.UnmarshalOptions, v interface{}) error {
+func decodeJSONPb(d *json.Decoder, unmarshaler protojson.UnmarshalOptions, rw jsonValueRewriter, v interface{}) error {
 	p, ok := v.(proto.Message)
 	if !ok {
-		return decodeNonProtoField(d, unmarshaler, v)
+		return decodeNonProtoField(d, unmarshaler, rw, v)
 	}
 
 	// Decode into bytes for marshalling
@@ -193,10 +236,10 @@ func decodeJSONPb(d *json.Decoder, unmarshaler protojson.UnmarshalOptions, v int
 		return err
 	}
 
-	return unmarshaler.Unmarshal([]byte(b), p)
+	return unmarshalProtoJSON(unmarshaler, rw, b, p)
 }
 
-func decodeNonProtoField(d *json.Decoder, unmarshaler protojson.UnmarshalOptions, v interface{}) error {
+func decodeNonProtoField(d *json.Decoder, unmarshaler protojson.UnmarshalOptions, rw jsonValueRewriter, v interface{}) error {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr {
 		return fmt.Errorf("%T is not a pointer", v)
@@ -213,7 +256,7 @@ func decodeNonProtoField(d *json.Decoder, unmarshaler protojson.UnmarshalOptions
 				return err
 			}
 
-			return unmarshaler.Unmarshal([]byte(b), rv.Interface().(proto.Message))
+			return unmarshalProtoJSON(unmarshaler, rw, b, rv.Interface().(proto.Message))
 		}
 		rv = rv.Elem()
 	}
@@ -237,7 +280,7 @@ func decodeNonProtoField(d *json.Decoder, unmarshaler protojson.UnmarshalOptions
 			}
 			bk := result[0]
 			bv := reflect.New(rv.Type().Elem())
-			if err := unmarshalJSONPb([]byte(*v), unmarshaler, bv.Interface()); err != nil {
+			if err := unmarshalJSONPb([]byte(*v), unmarshaler, rw, bv.Interface()); err != nil {
 				return err
 			}
 			rv.SetMapIndex(bk, bv.Elem())
@@ -254,7 +297,7 @@ func decodeNonProtoField(d *json.Decoder, unmarshaler protojson.UnmarshalOptions
 		}
 		for _, item := range sl {
 			bv := reflect.New(rv.Type().Elem())
-			if err := unmarshalJSONPb([]byte(item), unmarshaler, bv.Interface()); err != nil {
+			if err := unmarshalJSONPb([]byte(item), unmarshaler, rw, bv.Interface()); err != nil {
 				return err
 			}
 			rv.Set(reflect.Append(rv, bv.Elem()))
@@ -0,0 +1,187 @@
+package runtime
+
+import (
+	anypb "github.com/golang/protobuf/ptypes/any"
+	emptypb "github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// AnyRenderer renders a google.protobuf.Any value in the output of JSONPb.
+// rendered is its proto3 JSON rendering, e.g.
+//
+//	{"@type": "type.googleapis.com/example.Book", "title": "..."}
+//
+// after the other rendering options of the JSONPb have been applied, or nil if
+// the type of the embedded message cannot be resolved. The returned JSON value
+// replaces the rendering of the Any.
+//
+// An AnyRenderer can flatten Any values, rewrite the host of their type URLs or
+// render messages of unknown types, which JSONPb fails to marshal otherwise:
+//
+//	func(a *anypb.Any, rendered []byte) ([]byte, error) {
+//		if rendered == nil {
+//			return json.Marshal(map[string]interface{}{"@type": a.TypeUrl, "value": a.Value})
+//		}
+//		return rendered, nil
+//	}
+//
+// Types loaded at runtime, e.g. from descriptor sets, are resolved with
+// JSONPb.MarshalOptions.Resolver.
+type AnyRenderer func(a *anypb.Any, rendered []byte) ([]byte, error)
+
+// protoResolver is the type of protojson.MarshalOptions.Resolver.
+type protoResolver interface {
+	protoregistry.MessageTypeResolver
+	protoregistry.ExtensionTypeResolver
+}
+
+// anyFallbackResolver resolves the types of Any messages which are unknown
+// to the wrapped resolver as google.protobuf.Empty, so that protojson renders
+// them; their rendering is replaced by the AnyRenderer.
+type anyFallbackResolver struct {
+	protoResolver
+}
+
+func (r anyFallbackResolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	mt, err := r.protoResolver.FindMessageByURL(url)
+	if err != nil {
+		return (&emptypb.Empty{}).ProtoReflect().Type(), nil
+	}
+	return mt, nil
+}
+
+// anyResolver returns the resolver of the types of Any messages in j.
+func (j *JSONPb) anyResolver() protoResolver {
+	if j.MarshalOptions.Resolver != nil {
+		return j.MarshalOptions.Resolver
+	}
+	return protoregistry.GlobalTypes
+}
+
+// anyRendering renders the Any messages in the JSON rendering of a message
+// with an AnyRenderer.
+type anyRendering struct {
+	resolver protoResolver
+	render   AnyRenderer
+}
+
+// message renders the Any messages in v, the JSON value of msg.
+func (r anyRendering) message(msg protoreflect.Message, v interface{}) (interface{}, error) {
+	md := msg.Descriptor()
+	if md.FullName() == "google.protobuf.Any" {
+		return r.any(msg, v)
+	}
+	obj, ok := v.(jsonObject)
+	if !ok || isWellKnownType(md) {
+		return v, nil
+	}
+	fields := md.Fields()
+	for i, m := range obj {
+		fd := fields.ByJSONName(m.key)
+		if fd == nil {
+			fd = fields.ByTextName(m.key)
+		}
+		if fd == nil {
+			continue
+		}
+		value, err := r.field(fd, msg.Get(fd), m.value)
+		if err != nil {
+			return nil, err
+		}
+		obj[i].value = value
+	}
+	return obj, nil
+}
+
+// field renders the Any messages in v, the JSON value of the field fd.
+func (r anyRendering) field(fd protoreflect.FieldDescriptor, value protoreflect.Value, v interface{}) (interface{}, error) {
+	switch {
+	case fd.IsMap():
+		obj, ok := v.(jsonObject)
+		if !ok || fd.MapValue().Message() == nil {
+			return v, nil
+		}
+		items := make(map[string]protoreflect.Value)
+		value.Map().Range(func(k protoreflect.MapKey, item protoreflect.Value) bool {
+			items[k.String()] = item
+			return true
+		})
+		for i, m := range obj {
+			item, ok := items[m.key]
+			if !ok {
+				continue
+			}
+			rendered, err := r.message(item.Message(), m.value)
+			if err != nil {
+				return nil, err
+			}
+			obj[i].value = rendered
+		}
+		return obj, nil
+	case fd.IsList():
+		arr, ok := v.([]interface{})
+		if !ok || fd.Message() == nil {
+			return v, nil
+		}
+		list := value.List()
+		for i := 0; i < len(arr) && i < list.Len(); i++ {
+			rendered, err := r.message(list.Get(i).Message(), arr[i])
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = rendered
+		}
+		return arr, nil
+	case fd.Message() != nil:
+		return r.message(value.Message(), v)
+	}
+	return v, nil
+}
+
+// any renders v, the JSON value of the google.protobuf.Any msg.
+func (r anyRendering) any(msg protoreflect.Message, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	fields := msg.Descriptor().Fields()
+	a := &anypb.Any{
+		TypeUrl: msg.Get(fields.ByName("type_url")).String(),
+		Value:   msg.Get(fields.ByName("value")).Bytes(),
+	}
+
+	var rendered []byte
+	if mt, err := r.resolver.FindMessageByURL(a.TypeUrl); err == nil {
+		embedded := mt.New()
+		if err := (proto.UnmarshalOptions{AllowPartial: true, Resolver: r.resolver}).Unmarshal(a.Value, embedded.Interface()); err != nil {
+			return nil, err
+		}
+		if obj, ok := v.(jsonObject); ok {
+			if isWellKnownType(mt.Descriptor()) {
+				// Well-known types are embedded in the "value" member.
+				for i, m := range obj {
+					if m.key != "value" {
+						continue
+					}
+					value, err := r.message(embedded, m.value)
+					if err != nil {
+						return nil, err
+					}
+					obj[i].value = value
+				}
+			} else if _, err := r.message(embedded, obj); err != nil {
+				return nil, err
+			}
+		}
+		if rendered, err = encodeJSONTree(v, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := r.render(a, rendered)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSONTree(out)
+}
@@ -2,11 +2,14 @@ package runtime_test
 
 import (
 	"bytes"
+	"encoding/json"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
 	"testing"
 
+	anypb "github.com/golang/protobuf/ptypes/any"
 	durationpb "github.com/golang/protobuf/ptypes/duration"
 	emptypb "github.com/golang/protobuf/ptypes/empty"
 	structpb "github.com/golang/protobuf/ptypes/struct"
@@ -15,6 +18,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/testing/protocmp"
@@ -875,3 +879,232 @@ func TestJSONPbMarshalResponseBodies(t *testing.T) {
 		})
 	}
 }
+
+func TestJSONPbMarshalInt64Encoding(t *testing.T) {
+	msg := &examplepb.Proto3Message{
+		Int64Value:         -(1 << 53),
+		Uint64Value:        math.MaxUint64,
+		Int32Value:         7,
+		Nested:             &examplepb.Proto3Message{Int64Value: 42},
+		RepeatedMessage:    []*wrapperspb.UInt64Value{{Value: 1}, {Value: 1 << 60}},
+		WrapperInt64Value:  &wrapperspb.Int64Value{Value: 100},
+		WrapperUInt64Value: &wrapperspb.UInt64Value{Value: 200},
+		MapValue4:          map[string]int64{"a": 300},
+		MapValue5:          map[int64]string{400: "b"},
+	}
+	for _, spec := range []struct {
+		encoding runtime.Int64Encoding
+		want     map[string]interface{}
+	}{
+		{
+			encoding: runtime.Int64AsString,
+			want: map[string]interface{}{
+				"int64Value":         "-9007199254740992",
+				"uint64Value":        "18446744073709551615",
+				"int32Value":         json.Number("7"),
+				"nested":             map[string]interface{}{"int64Value": "42"},
+				"repeatedMessage":    []interface{}{"1", "1152921504606846976"},
+				"wrapperInt64Value":  "100",
+				"wrapperUInt64Value": "200",
+				"mapValue4":          map[string]interface{}{"a": "300"},
+				"mapValue5":          map[string]interface{}{"400": "b"},
+			},
+		},
+		{
+			encoding: runtime.Int64AsNumber,
+			want: map[string]interface{}{
+				"int64Value":         json.Number("-9007199254740992"),
+				"uint64Value":        json.Number("18446744073709551615"),
+				"int32Value":         json.Number("7"),
+				"nested":             map[string]interface{}{"int64Value": json.Number("42")},
+				"repeatedMessage":    []interface{}{json.Number("1"), json.Number("1152921504606846976")},
+				"wrapperInt64Value":  json.Number("100"),
+				"wrapperUInt64Value": json.Number("200"),
+				"mapValue4":          map[string]interface{}{"a": json.Number("300")},
+				"mapValue5":          map[string]interface{}{"400": "b"},
+			},
+		},
+		{
+			encoding: runtime.Int64AsSafeNumber,
+			want: map[string]interface{}{
+				"int64Value":         "-9007199254740992",
+				"uint64Value":        "18446744073709551615",
+				"int32Value":         json.Number("7"),
+				"nested":             map[string]interface{}{"int64Value": json.Number("42")},
+				"repeatedMessage":    []interface{}{json.Number("1"), "1152921504606846976"},
+				"wrapperInt64Value":  json.Number("100"),
+				"wrapperUInt64Value": json.Number("200"),
+				"mapValue4":          map[string]interface{}{"a": json.Number("300")},
+				"mapValue5":          map[string]interface{}{"400": "b"},
+			},
+		},
+	} {
+		m := runtime.JSONPb{Int64Encoding: spec.encoding}
+		buf, err := m.Marshal(msg)
+		if err != nil {
+			t.Fatalf("m.Marshal(%v) failed with %v; want success", msg, err)
+		}
+		d := json.NewDecoder(bytes.NewReader(buf))
+		d.UseNumber()
+		var got map[string]interface{}
+		if err := d.Decode(&got); err != nil {
+			t.Fatalf("json.Decode(%s) failed with %v; want success", buf, err)
+		}
+		if diff := cmp.Diff(got, spec.want); diff != "" {
+			t.Errorf("encoding %d: %s", spec.encoding, diff)
+		}
+
+		// Both encodings are accepted when unmarshaling.
+		got2 := new(examplepb.Proto3Message)
+		if err := m.Unmarshal(buf, got2); err != nil {
+			t.Fatalf("m.Unmarshal(%s) failed with %v; want success", buf, err)
+		}
+		if diff := cmp.Diff(got2, msg, protocmp.Transform()); diff != "" {
+			t.Errorf("encoding %d: %s", spec.encoding, diff)
+		}
+	}
+}
+
+func TestJSONPbMarshalInt64EncodingIndent(t *testing.T) {
+	m := runtime.JSONPb{
+		MarshalOptions: protojson.MarshalOptions{Indent: "\t"},
+		Int64Encoding:  runtime.Int64AsNumber,
+	}
+	buf, err := m.Marshal(&examplepb.Proto3Message{StringValue: "<a&b>", Int64Value: 1})
+	if err != nil {
+		t.Fatalf("m.Marshal failed with %v; want success", err)
+	}
+	if got, want := string(buf), "{\n\t\"int64Value\": 1,\n\t\"stringValue\": \"<a&b>\"\n}"; got != want {
+		t.Errorf("m.Marshal = %q; want %q", got, want)
+	}
+}
+
+func TestJSONPbTimeEncoding(t *testing.T) {
+	msg := &examplepb.Proto3Message{
+		TimestampValue: &timestamppb.Timestamp{Seconds: 1600000000, Nanos: 123456789},
+		DurationValue:  &durationpb.Duration{Seconds: -90, Nanos: -500000000},
+		Nested: &examplepb.Proto3Message{
+			TimestampValue: &timestamppb.Timestamp{Seconds: -2, Nanos: 500000000},
+		},
+	}
+	for _, spec := range []struct {
+		encoding runtime.TimeEncoding
+		want     string
+		// roundTrip is the message decoded from want, for lossy encodings.
+		roundTrip *examplepb.Proto3Message
+	}{
+		{
+			want: `{"nested":{"timestampValue":"1969-12-31T23:59:58.500Z"},"timestampValue":"2020-09-13T12:26:40.123456789Z","durationValue":"-90.500s"}`,
+		},
+		{
+			encoding: runtime.TimeEncoding{Timestamp: runtime.TimestampUnixSeconds, Duration: runtime.DurationSeconds},
+			want:     `{"nested":{"timestampValue":-1.5},"timestampValue":1600000000.123456789,"durationValue":-90.5}`,
+		},
+		{
+			encoding: runtime.TimeEncoding{Timestamp: runtime.TimestampUnixMillis, Duration: runtime.DurationMillis},
+			want:     `{"nested":{"timestampValue":-1500},"timestampValue":1600000000123,"durationValue":-90500}`,
+			roundTrip: &examplepb.Proto3Message{
+				TimestampValue: &timestamppb.Timestamp{Seconds: 1600000000, Nanos: 123000000},
+				DurationValue:  &durationpb.Duration{Seconds: -90, Nanos: -500000000},
+				Nested: &examplepb.Proto3Message{
+					TimestampValue: &timestamppb.Timestamp{Seconds: -2, Nanos: 500000000},
+				},
+			},
+		},
+		{
+			encoding: runtime.TimeEncoding{Timestamp: runtime.TimestampLayout, Layout: "2006-01-02 15:04:05.000"},
+			want:     `{"nested":{"timestampValue":"1969-12-31 23:59:58.500"},"timestampValue":"2020-09-13 12:26:40.123","durationValue":"-90.500s"}`,
+			roundTrip: &examplepb.Proto3Message{
+				TimestampValue: &timestamppb.Timestamp{Seconds: 1600000000, Nanos: 123000000},
+				DurationValue:  &durationpb.Duration{Seconds: -90, Nanos: -500000000},
+				Nested: &examplepb.Proto3Message{
+					TimestampValue: &timestamppb.Timestamp{Seconds: -2, Nanos: 500000000},
+				},
+			},
+		},
+	} {
+		m := runtime.JSONPb{TimeEncoding: spec.encoding}
+		buf, err := m.Marshal(msg)
+		if err != nil {
+			t.Fatalf("m.Marshal(%v) failed with %v; want success", msg, err)
+		}
+		var got, want interface{}
+		if err := json.Unmarshal(buf, &got); err != nil {
+			t.Fatalf("json.Unmarshal(%s) failed with %v; want success", buf, err)
+		}
+		if err := json.Unmarshal([]byte(spec.want), &want); err != nil {
+			t.Fatalf("json.Unmarshal(%s) failed with %v; want success", spec.want, err)
+		}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("m.Marshal(%v) = %s: %s", msg, buf, diff)
+		}
+
+		decoded := new(examplepb.Proto3Message)
+		if err := m.NewDecoder(strings.NewReader(spec.want)).Decode(decoded); err != nil {
+			t.Fatalf("m.NewDecoder(%s).Decode failed with %v; want success", spec.want, err)
+		}
+		wantDecoded := spec.roundTrip
+		if wantDecoded == nil {
+			wantDecoded = msg
+		}
+		if diff := cmp.Diff(decoded, wantDecoded, protocmp.Transform()); diff != "" {
+			t.Errorf("m.NewDecoder(%s).Decode: %s", spec.want, diff)
+		}
+
+		// The proto3 JSON mapping is accepted too.
+		decoded = new(examplepb.Proto3Message)
+		standard := `{"timestampValue":"2020-09-13T12:26:40.123456789Z","durationValue":"1.5s"}`
+		if err := m.Unmarshal([]byte(standard), decoded); err != nil {
+			t.Errorf("m.Unmarshal(%s) failed with %v; want success", standard, err)
+		}
+	}
+}
+
+func TestJSONPbAnyRenderer(t *testing.T) {
+	newAny := func(url string, m proto.Message) *anypb.Any {
+		b, err := proto.Marshal(m)
+		if err != nil {
+			t.Fatalf("proto.Marshal(%v) failed with %v; want success", m, err)
+		}
+		return &anypb.Any{TypeUrl: url, Value: b}
+	}
+	msg := &statuspb.Status{
+		Code: 3,
+		Details: []*anypb.Any{
+			newAny("type.googleapis.com/grpc.gateway.runtime.internal.examplepb.Proto3Message", &examplepb.Proto3Message{Int64Value: 5}),
+			newAny("type.googleapis.com/google.protobuf.StringValue", &wrapperspb.StringValue{Value: "foo"}),
+			newAny("type.googleapis.com/example.Unknown", &wrapperspb.StringValue{Value: "bar"}),
+		},
+	}
+
+	var unresolved []string
+	m := runtime.JSONPb{
+		Int64Encoding: runtime.Int64AsNumber,
+		AnyRenderer: func(a *anypb.Any, rendered []byte) ([]byte, error) {
+			if rendered == nil {
+				unresolved = append(unresolved, a.TypeUrl)
+				return json.Marshal(map[string]interface{}{"@type": a.TypeUrl, "value": a.Value})
+			}
+			return bytes.Replace(rendered, []byte("type.googleapis.com/"), []byte("types.example.com/"), 1), nil
+		},
+	}
+	buf, err := m.Marshal(msg)
+	if err != nil {
+		t.Fatalf("m.Marshal(%v) failed with %v; want success", msg, err)
+	}
+	want := `{"code":3,"details":[` +
+		`{"@type":"types.example.com/grpc.gateway.runtime.internal.examplepb.Proto3Message","int64Value":5},` +
+		`{"@type":"types.example.com/google.protobuf.StringValue","value":"foo"},` +
+		`{"@type":"type.googleapis.com/example.Unknown","value":"CgNiYXI="}]}`
+	if got := string(buf); got != want {
+		t.Errorf("m.Marshal(%v) = %s; want %s", msg, got, want)
+	}
+	if want := []string{"type.googleapis.com/example.Unknown"}; !reflect.DeepEqual(unresolved, want) {
+		t.Errorf("unresolved types = %q; want %q", unresolved, want)
+	}
+
+	// Without a renderer, unknown types cannot be marshaled.
+	if buf, err := (&runtime.JSONPb{}).Marshal(msg); err == nil {
+		t.Errorf("(&runtime.JSONPb{}).Marshal(%v) = %s; want error", msg, buf)
+	}
+}
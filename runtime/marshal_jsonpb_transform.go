@@ -0,0 +1,397 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// The JSON rendering of a message can be customized beyond what protojson
+// supports by rewriting its output: it is decoded into a tree preserving the
+// order of object members, the values of fields are rewritten guided by the
+// message descriptor, and the tree is encoded again.
+
+// jsonObject is a decoded JSON object which keeps the order of its members.
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	key   string
+	value interface{}
+}
+
+// get returns the value of the member key of o.
+func (o jsonObject) get(key string) (interface{}, bool) {
+	for _, m := range o {
+		if m.key == key {
+			return m.value, true
+		}
+	}
+	return nil, false
+}
+
+// decodeJSONTree decodes data into a tree of jsonObject, []interface{},
+// json.Number, string, bool and nil values.
+func decodeJSONTree(data []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	v, err := decodeJSONTreeValue(d)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after top-level JSON value")
+	}
+	return v, nil
+}
+
+func decodeJSONTreeValue(d *json.Decoder) (interface{}, error) {
+	tok, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := make(jsonObject, 0)
+		for d.More() {
+			key, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONTreeValue(d)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, jsonMember{key: key.(string), value: value})
+		}
+		_, err := d.Token()
+		return obj, err
+	case json.Delim('['):
+		arr := make([]interface{}, 0)
+		for d.More() {
+			value, err := decodeJSONTreeValue(d)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		_, err := d.Token()
+		return arr, err
+	}
+	return tok, nil
+}
+
+// encodeJSONTree encodes a tree decoded by decodeJSONTree. The output is
+// indented with indent if it is not empty.
+func encodeJSONTree(v interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSONTree(&buf, v); err != nil {
+		return nil, err
+	}
+	if indent == "" {
+		return buf.Bytes(), nil
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", indent); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func writeJSONTree(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case jsonObject:
+		buf.WriteByte('{')
+		for i, m := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONString(buf, m.key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeJSONTree(buf, m.value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONTree(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case json.Number:
+		buf.WriteString(v.String())
+	case string:
+		return writeJSONString(buf, v)
+	case bool:
+		if v {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case nil:
+		buf.WriteString("null")
+	default:
+		return fmt.Errorf("unsupported JSON value %T", v)
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	// Encode appends a newline.
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+// jsonValueRewriter rewrites the JSON value v of a singular field, or of an
+// element of a repeated or map field, of the given kind. md is the message
+// type of message values, and nil otherwise. It returns false if it leaves
+// the value as is, in which case the fields of a message value are rewritten
+// in turn.
+type jsonValueRewriter func(kind protoreflect.Kind, md protoreflect.MessageDescriptor, v interface{}) (interface{}, bool, error)
+
+// rewriteJSONMessage rewrites the fields of v, the JSON value of a message of
+// type md, with rw.
+func rewriteJSONMessage(md protoreflect.MessageDescriptor, v interface{}, resolver protoregistry.MessageTypeResolver, rw jsonValueRewriter) (interface{}, error) {
+	obj, ok := v.(jsonObject)
+	if !ok {
+		// Well-known types with a special JSON representation, or null.
+		return v, nil
+	}
+	if md.FullName() == "google.protobuf.Any" {
+		return rewriteJSONAny(obj, resolver, rw)
+	}
+	if isWellKnownType(md) {
+		return v, nil
+	}
+	fields := md.Fields()
+	for i, m := range obj {
+		fd := fields.ByJSONName(m.key)
+		if fd == nil {
+			fd = fields.ByTextName(m.key)
+		}
+		if fd == nil {
+			continue
+		}
+		value, err := rewriteJSONField(fd, m.value, resolver, rw)
+		if err != nil {
+			return nil, err
+		}
+		obj[i].value = value
+	}
+	return obj, nil
+}
+
+func rewriteJSONField(fd protoreflect.FieldDescriptor, v interface{}, resolver protoregistry.MessageTypeResolver, rw jsonValueRewriter) (interface{}, error) {
+	switch {
+	case fd.IsMap():
+		obj, ok := v.(jsonObject)
+		if !ok {
+			return v, nil
+		}
+		for i, m := range obj {
+			value, err := rewriteJSONValue(fd.MapValue().Kind(), fd.MapValue().Message(), m.value, resolver, rw)
+			if err != nil {
+				return nil, err
+			}
+			obj[i].value = value
+		}
+		return obj, nil
+	case fd.IsList():
+		arr, ok := v.([]interface{})
+		if !ok {
+			return v, nil
+		}
+		for i, item := range arr {
+			value, err := rewriteJSONValue(fd.Kind(), fd.Message(), item, resolver, rw)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = value
+		}
+		return arr, nil
+	}
+	return rewriteJSONValue(fd.Kind(), fd.Message(), v, resolver, rw)
+}
+
+func rewriteJSONValue(kind protoreflect.Kind, md protoreflect.MessageDescriptor, v interface{}, resolver protoregistry.MessageTypeResolver, rw jsonValueRewriter) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	value, ok, err := rw(kind, md, v)
+	if err != nil || ok {
+		return value, err
+	}
+	if md != nil {
+		return rewriteJSONMessage(md, v, resolver, rw)
+	}
+	return v, nil
+}
+
+// rewriteJSONAny rewrites the embedded message of a google.protobuf.Any.
+// Messages of unknown types are left as is.
+func rewriteJSONAny(obj jsonObject, resolver protoregistry.MessageTypeResolver, rw jsonValueRewriter) (interface{}, error) {
+	typeURL, ok := obj.get("@type")
+	if !ok {
+		return obj, nil
+	}
+	url, ok := typeURL.(string)
+	if !ok {
+		return obj, nil
+	}
+	if resolver == nil {
+		resolver = protoregistry.GlobalTypes
+	}
+	mt, err := resolver.FindMessageByURL(url)
+	if err != nil {
+		return obj, nil
+	}
+	md := mt.Descriptor()
+	if !isWellKnownType(md) {
+		return rewriteJSONMessage(md, obj, resolver, rw)
+	}
+	// Well-known types are embedded in the "value" member.
+	for i, m := range obj {
+		if m.key != "value" {
+			continue
+		}
+		value, err := rewriteJSONValue(protoreflect.MessageKind, md, m.value, resolver, rw)
+		if err != nil {
+			return nil, err
+		}
+		obj[i].value = value
+	}
+	return obj, nil
+}
+
+// jsonValueRewriter returns the rewriter applying the rendering options of j
+// which protojson does not support, or nil if there are none.
+func (j *JSONPb) jsonValueRewriter() jsonValueRewriter {
+	if j.Int64Encoding == Int64AsString && j.TimeEncoding.isDefault() {
+		return nil
+	}
+	return func(kind protoreflect.Kind, md protoreflect.MessageDescriptor, v interface{}) (interface{}, bool, error) {
+		if isInt64Value(kind, md) {
+			return j.Int64Encoding.encode(v), true, nil
+		}
+		if md != nil {
+			return j.TimeEncoding.encode(md, v)
+		}
+		return nil, false, nil
+	}
+}
+
+// jsonUnmarshalRewriter returns the rewriter turning the values rendered with
+// the options of j which protojson does not support back into their proto3
+// JSON mapping, or nil if there are none.
+func (j *JSONPb) jsonUnmarshalRewriter() jsonValueRewriter {
+	if j.TimeEncoding.isDefault() {
+		return nil
+	}
+	return func(_ protoreflect.Kind, md protoreflect.MessageDescriptor, v interface{}) (interface{}, bool, error) {
+		if md == nil {
+			return nil, false, nil
+		}
+		return j.TimeEncoding.decode(md, v)
+	}
+}
+
+// unmarshalProtoJSON unmarshals the JSON value b into m, rewriting it with rw
+// first if it is not nil.
+func unmarshalProtoJSON(unmarshaler protojson.UnmarshalOptions, rw jsonValueRewriter, b []byte, m proto.Message) error {
+	if rw != nil {
+		tree, err := decodeJSONTree(b)
+		if err != nil {
+			return err
+		}
+		if tree, err = rewriteJSONValue(protoreflect.MessageKind, m.ProtoReflect().Descriptor(), tree, unmarshaler.Resolver, rw); err != nil {
+			return err
+		}
+		if b, err = encodeJSONTree(tree, ""); err != nil {
+			return err
+		}
+	}
+	return unmarshaler.Unmarshal(b, m)
+}
+
+// rewriteJSON rewrites b, the protojson rendering of msg, with rw if it is
+// not nil and renders its Any messages with j.AnyRenderer if it is set.
+func (j *JSONPb) rewriteJSON(msg protoreflect.Message, b []byte, rw jsonValueRewriter) ([]byte, error) {
+	tree, err := decodeJSONTree(b)
+	if err != nil {
+		return nil, err
+	}
+	if rw != nil {
+		var resolver protoregistry.MessageTypeResolver
+		if j.MarshalOptions.Resolver != nil {
+			resolver = j.MarshalOptions.Resolver
+		}
+		if tree, err = rewriteJSONValue(protoreflect.MessageKind, msg.Descriptor(), tree, resolver, rw); err != nil {
+			return nil, err
+		}
+	}
+	if j.AnyRenderer != nil {
+		r := anyRendering{resolver: j.anyResolver(), render: j.AnyRenderer}
+		if tree, err = r.message(msg, tree); err != nil {
+			return nil, err
+		}
+	}
+	indent := j.Indent
+	if indent == "" && j.Multiline {
+		indent = "  "
+	}
+	return encodeJSONTree(tree, indent)
+}
+
+func isInt64Value(kind protoreflect.Kind, md protoreflect.MessageDescriptor) bool {
+	switch kind {
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return true
+	case protoreflect.MessageKind:
+		switch md.FullName() {
+		case "google.protobuf.Int64Value", "google.protobuf.UInt64Value":
+			return true
+		}
+	}
+	return false
+}
+
+// maxSafeInteger is the largest integer represented exactly by an IEEE 754 double.
+const maxSafeInteger = 1<<53 - 1
+
+// encode renders the JSON string s of a 64-bit integer.
+func (e Int64Encoding) encode(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	switch e {
+	case Int64AsNumber:
+		return json.Number(s)
+	case Int64AsSafeNumber:
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil && n >= -maxSafeInteger && n <= maxSafeInteger {
+			return json.Number(s)
+		}
+	}
+	return s
+}
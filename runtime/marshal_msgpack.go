@@ -0,0 +1,362 @@
+package runtime
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// MessagePack is a Marshaler which marshals/unmarshals into/from MessagePack.
+//
+// Messages are encoded as maps keyed by field name, integers keep their full
+// 64-bit range, bytes fields are encoded with the bin family and enums as
+// their numeric values.
+//
+// MessagePack objects are self-delimiting, so by default streamed messages
+// are written back-to-back. With LengthPrefixed set, every streamed message
+// (and every message read by NewDecoder) is framed by a 4-byte big-endian
+// length instead, which lets clients split the stream without a MessagePack
+// parser.
+type MessagePack struct {
+	// UseProtoNames uses proto field names instead of lowerCamelCase names as map keys.
+	UseProtoNames bool
+	// DiscardUnknown ignores unknown fields while unmarshaling instead of failing.
+	DiscardUnknown bool
+	// LengthPrefixed frames streamed messages with a 4-byte big-endian length.
+	LengthPrefixed bool
+	// MaxMessageSize bounds the length of the frames read by NewDecoder with
+	// LengthPrefixed, and of the strings read while unmarshaling. It
+	// defaults to 4 MiB, the default maximum message size of gRPC servers.
+	MaxMessageSize int
+}
+
+// ContentType always returns "application/msgpack".
+func (*MessagePack) ContentType(_ interface{}) string {
+	return "application/msgpack"
+}
+
+func (m *MessagePack) options() structuredOptions {
+	return structuredOptions{useProtoNames: m.UseProtoNames, discardUnknown: m.DiscardUnknown}
+}
+
+// Marshal marshals "v" into MessagePack.
+func (m *MessagePack) Marshal(v interface{}) ([]byte, error) {
+	tree, err := m.options().toStructured(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := encodeMsgpack(&buf, tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal unmarshals MessagePack "data" into "v".
+func (m *MessagePack) Unmarshal(data []byte, v interface{}) error {
+	r := bufio.NewReader(bytes.NewReader(data))
+	tree, err := decodeMsgpack(r, 0, maxMessageSize(m.MaxMessageSize))
+	if err != nil {
+		return err
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		return errors.New("msgpack: trailing data after object")
+	}
+	return m.options().fromStructured(tree, v)
+}
+
+// NewDecoder returns a Decoder which reads a sequence of MessagePack objects from "r".
+func (m *MessagePack) NewDecoder(r io.Reader) Decoder {
+	br := bufio.NewReader(r)
+	return DecoderFunc(func(v interface{}) error {
+		if m.LengthPrefixed {
+			var n uint32
+			if err := binary.Read(br, binary.BigEndian, &n); err != nil {
+				return err
+			}
+			if int64(n) > maxMessageSize(m.MaxMessageSize) {
+				return fmt.Errorf("msgpack: frame of %d bytes exceeds the maximum message size", n)
+			}
+			data, err := readFull(br, int64(n))
+			if err != nil {
+				return err
+			}
+			return m.Unmarshal(data, v)
+		}
+		tree, err := decodeMsgpack(br, 0, maxMessageSize(m.MaxMessageSize))
+		if err != nil {
+			return err
+		}
+		return m.options().fromStructured(tree, v)
+	})
+}
+
+// NewEncoder returns an Encoder which writes a sequence of MessagePack objects into "w".
+func (m *MessagePack) NewEncoder(w io.Writer) Encoder {
+	return EncoderFunc(func(v interface{}) error {
+		buf, err := m.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(m.FramePrefix(len(buf))); err != nil {
+			return err
+		}
+		_, err = w.Write(buf)
+		return err
+	})
+}
+
+// Delimiter returns an empty delimiter, MessagePack objects are self-delimiting.
+func (*MessagePack) Delimiter() []byte {
+	return []byte{}
+}
+
+// FramePrefix returns the 4-byte big-endian length of a streamed message if
+// LengthPrefixed is set.
+func (m *MessagePack) FramePrefix(n int) []byte {
+	if !m.LengthPrefixed {
+		return nil
+	}
+	prefix := make([]byte, 4)
+	binary.BigEndian.PutUint32(prefix, uint32(n))
+	return prefix
+}
+
+func writeMsgpackLen(buf *bytes.Buffer, n int, fix byte, fixMax int, op8, op16, op32 byte) {
+	switch {
+	case fix != 0 && n <= fixMax:
+		buf.WriteByte(fix | byte(n))
+	case op8 != 0 && n <= math.MaxUint8:
+		buf.Write([]byte{op8, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(op16)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(op32)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func writeMsgpackUint(buf *bytes.Buffer, v uint64) {
+	switch {
+	case v <= 0x7f:
+		buf.WriteByte(byte(v))
+	case v <= math.MaxUint8:
+		buf.Write([]byte{0xcc, byte(v)})
+	case v <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		_ = binary.Write(buf, binary.BigEndian, uint16(v))
+	case v <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		_ = binary.Write(buf, binary.BigEndian, uint32(v))
+	default:
+		buf.WriteByte(0xcf)
+		_ = binary.Write(buf, binary.BigEndian, v)
+	}
+}
+
+func encodeMsgpack(buf *bytes.Buffer, tree interface{}) error {
+	switch v := tree.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case int64:
+		switch {
+		case v >= 0:
+			writeMsgpackUint(buf, uint64(v))
+		case v >= -32:
+			buf.WriteByte(byte(v))
+		case v >= math.MinInt8:
+			buf.Write([]byte{0xd0, byte(v)})
+		case v >= math.MinInt16:
+			buf.WriteByte(0xd1)
+			_ = binary.Write(buf, binary.BigEndian, int16(v))
+		case v >= math.MinInt32:
+			buf.WriteByte(0xd2)
+			_ = binary.Write(buf, binary.BigEndian, int32(v))
+		default:
+			buf.WriteByte(0xd3)
+			_ = binary.Write(buf, binary.BigEndian, v)
+		}
+	case uint64:
+		writeMsgpackUint(buf, v)
+	case float32:
+		buf.WriteByte(0xca)
+		_ = binary.Write(buf, binary.BigEndian, math.Float32bits(v))
+	case float64:
+		buf.WriteByte(0xcb)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case string:
+		writeMsgpackLen(buf, len(v), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		buf.WriteString(v)
+	case []byte:
+		writeMsgpackLen(buf, len(v), 0, 0, 0xc4, 0xc5, 0xc6)
+		buf.Write(v)
+	case []interface{}:
+		writeMsgpackLen(buf, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := encodeMsgpack(buf, item); err != nil {
+				return err
+			}
+		}
+	case structuredMap:
+		writeMsgpackLen(buf, len(v), 0x80, 15, 0, 0xde, 0xdf)
+		for _, e := range v {
+			if err := encodeMsgpack(buf, e.key); err != nil {
+				return err
+			}
+			if err := encodeMsgpack(buf, e.value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %T", tree)
+	}
+	return nil
+}
+
+// msgpackMaxDepth bounds the nesting of decoded objects.
+const msgpackMaxDepth = 10000
+
+func readMsgpackUint(r *bufio.Reader, size int) (uint64, error) {
+	var err error
+	var v uint64
+	switch size {
+	case 1:
+		var b uint8
+		err = binary.Read(r, binary.BigEndian, &b)
+		v = uint64(b)
+	case 2:
+		var b uint16
+		err = binary.Read(r, binary.BigEndian, &b)
+		v = uint64(b)
+	case 4:
+		var b uint32
+		err = binary.Read(r, binary.BigEndian, &b)
+		v = uint64(b)
+	default:
+		err = binary.Read(r, binary.BigEndian, &v)
+	}
+	return v, unexpectedEOF(err)
+}
+
+func readMsgpackBytes(r *bufio.Reader, n uint64, limit int64) ([]byte, error) {
+	if n > uint64(limit) {
+		return nil, fmt.Errorf("msgpack: string of %d bytes exceeds the maximum message size", n)
+	}
+	return readFull(r, int64(n))
+}
+
+// decodeMsgpack decodes an object whose strings are at most "limit" bytes long.
+func decodeMsgpack(r *bufio.Reader, depth int, limit int64) (interface{}, error) {
+	if depth > msgpackMaxDepth {
+		return nil, errors.New("msgpack: exceeded max nesting depth")
+	}
+	head, err := r.ReadByte()
+	if err != nil {
+		if depth > 0 {
+			return nil, unexpectedEOF(err)
+		}
+		return nil, err
+	}
+
+	var n uint64
+	switch {
+	case head <= 0x7f:
+		return int64(head), nil
+	case head >= 0xe0:
+		return int64(int8(head)), nil
+	case head&0xe0 == 0xa0:
+		b, err := readMsgpackBytes(r, uint64(head&0x1f), limit)
+		return string(b), err
+	case head&0xf0 == 0x90:
+		return decodeMsgpackArray(r, uint64(head&0x0f), depth, limit)
+	case head&0xf0 == 0x80:
+		return decodeMsgpackMap(r, uint64(head&0x0f), depth, limit)
+	}
+
+	switch head {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return readMsgpackUint(r, 1<<(head-0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (head - 0xd0)
+		u, err := readMsgpackUint(r, size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign-extend from the encoded width.
+		shift := uint(64 - 8*size)
+		return int64(u<<shift) >> shift, nil
+	case 0xca:
+		u, err := readMsgpackUint(r, 4)
+		return math.Float32frombits(uint32(u)), err
+	case 0xcb:
+		u, err := readMsgpackUint(r, 8)
+		return math.Float64frombits(u), err
+	case 0xd9, 0xda, 0xdb:
+		if n, err = readMsgpackUint(r, 1<<(head-0xd9)); err != nil {
+			return nil, err
+		}
+		b, err := readMsgpackBytes(r, n, limit)
+		return string(b), err
+	case 0xc4, 0xc5, 0xc6:
+		if n, err = readMsgpackUint(r, 1<<(head-0xc4)); err != nil {
+			return nil, err
+		}
+		return readMsgpackBytes(r, n, limit)
+	case 0xdc, 0xdd:
+		if n, err = readMsgpackUint(r, 2<<(head-0xdc)); err != nil {
+			return nil, err
+		}
+		return decodeMsgpackArray(r, n, depth, limit)
+	case 0xde, 0xdf:
+		if n, err = readMsgpackUint(r, 2<<(head-0xde)); err != nil {
+			return nil, err
+		}
+		return decodeMsgpackMap(r, n, depth, limit)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%02x", head)
+}
+
+func decodeMsgpackArray(r *bufio.Reader, n uint64, depth int, limit int64) (interface{}, error) {
+	l := make([]interface{}, 0)
+	for i := uint64(0); i < n; i++ {
+		item, err := decodeMsgpack(r, depth+1, limit)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, item)
+	}
+	return l, nil
+}
+
+func decodeMsgpackMap(r *bufio.Reader, n uint64, depth int, limit int64) (interface{}, error) {
+	m := make(structuredMap, 0)
+	for i := uint64(0); i < n; i++ {
+		key, err := decodeMsgpack(r, depth+1, limit)
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeMsgpack(r, depth+1, limit)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, structuredEntry{key: key, value: value})
+	}
+	return m, nil
+}
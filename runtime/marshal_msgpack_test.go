@@ -0,0 +1,178 @@
+package runtime_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"math"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMessagePackMarshalUnmarshal(t *testing.T) {
+	m := &runtime.MessagePack{}
+	for _, msg := range []proto.Message{
+		message,
+		&examplepb.Proto3Message{
+			Int64Value:   math.MinInt64,
+			Uint64Value:  math.MaxUint64,
+			Int32Value:   -200,
+			Uint32Value:  70000,
+			FloatValue:   1.5,
+			DoubleValue:  -2.25,
+			BytesValue:   bytes.Repeat([]byte{0xab}, 300),
+			StringValue:  string(bytes.Repeat([]byte("x"), 40)),
+			RepeatedEnum: []examplepb.EnumValue{examplepb.EnumValue_X, examplepb.EnumValue_Z},
+			MapValue5:    map[int64]string{-5: "neg"},
+		},
+	} {
+		buf, err := m.Marshal(msg)
+		if err != nil {
+			t.Fatalf("m.Marshal(%v) failed with %v; want success", msg, err)
+		}
+		got := msg.ProtoReflect().New().Interface()
+		if err := m.Unmarshal(buf, got); err != nil {
+			t.Fatalf("m.Unmarshal(%x) failed with %v; want success", buf, err)
+		}
+		if !proto.Equal(got, msg) {
+			t.Errorf("round trip = %v; want %v", got, msg)
+		}
+	}
+}
+
+func TestMessagePackMarshalEncoding(t *testing.T) {
+	m := &runtime.MessagePack{UseProtoNames: true}
+	for _, spec := range []struct {
+		v    interface{}
+		want string
+	}{
+		{v: 1, want: "01"},
+		{v: -1, want: "ff"},
+		{v: -33, want: "d0df"},
+		{v: 256, want: "cd0100"},
+		{v: int64(math.MinInt64), want: "d38000000000000000"},
+		{v: uint64(math.MaxUint64), want: "cfffffffffffffffff"},
+		{v: "a", want: "a161"},
+		{v: []byte{1, 2}, want: "c4020102"},
+		{v: nil, want: "c0"},
+		{v: &examplepb.SimpleMessage{Id: "x"}, want: "81" + "a26964" + "a178"},
+	} {
+		buf, err := m.Marshal(spec.v)
+		if err != nil {
+			t.Errorf("m.Marshal(%v) failed with %v; want success", spec.v, err)
+			continue
+		}
+		if got := hex.EncodeToString(buf); got != spec.want {
+			t.Errorf("m.Marshal(%v) = %s; want %s", spec.v, got, spec.want)
+		}
+	}
+}
+
+func TestMessagePackUnmarshalErrors(t *testing.T) {
+	m := &runtime.MessagePack{}
+	for _, data := range []string{
+		"",           // empty
+		"81a26964",   // truncated map
+		"81a2696401", // wrong type for string field
+		"81a17a01",   // unknown field
+		"0101",       // trailing data
+		"d4",         // ext
+	} {
+		b, _ := hex.DecodeString(data)
+		if err := m.Unmarshal(b, &examplepb.SimpleMessage{}); err == nil {
+			t.Errorf("m.Unmarshal(%s) succeeded; want failure", data)
+		}
+	}
+}
+
+func TestMessagePackLengthPrefixedStream(t *testing.T) {
+	m := &runtime.MessagePack{LengthPrefixed: true}
+	msgs := []proto.Message{&examplepb.SimpleMessage{Id: "one"}, &examplepb.SimpleMessage{Id: "two"}}
+	recv := func() (proto.Message, error) {
+		if len(msgs) == 0 {
+			return nil, io.EOF
+		}
+		msg := msgs[0]
+		msgs = msgs[1:]
+		return msg, nil
+	}
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	resp := httptest.NewRecorder()
+	runtime.ForwardResponseStream(ctx, runtime.NewServeMux(), m, resp, req, recv)
+
+	body := resp.Body.Bytes()
+	for _, want := range []string{"one", "two"} {
+		if len(body) < 4 {
+			t.Fatalf("body too short: %x", body)
+		}
+		n := binary.BigEndian.Uint32(body)
+		frame := body[4 : 4+n]
+		body = body[4+n:]
+
+		var chunk map[string]*examplepb.SimpleMessage
+		if err := m.Unmarshal(frame, &chunk); err != nil {
+			t.Fatalf("m.Unmarshal(%x) failed with %v; want success", frame, err)
+		}
+		if got := chunk["result"].GetId(); got != want {
+			t.Errorf("chunk[result].Id = %q; want %q", got, want)
+		}
+	}
+	if len(body) != 0 {
+		t.Errorf("trailing data %x", body)
+	}
+}
+
+func TestMessagePackLengthPrefixedDecoder(t *testing.T) {
+	m := &runtime.MessagePack{LengthPrefixed: true}
+	var buf bytes.Buffer
+	enc := m.NewEncoder(&buf)
+	for _, id := range []string{"one", "two"} {
+		if err := enc.Encode(&examplepb.SimpleMessage{Id: id}); err != nil {
+			t.Fatalf("enc.Encode(...) failed with %v; want success", err)
+		}
+	}
+	dec := m.NewDecoder(&buf)
+	for _, want := range []string{"one", "two"} {
+		var got examplepb.SimpleMessage
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("dec.Decode(...) failed with %v; want success", err)
+		}
+		if got.Id != want {
+			t.Errorf("got.Id = %q; want %q", got.Id, want)
+		}
+	}
+	if err := dec.Decode(&examplepb.SimpleMessage{}); err != io.EOF {
+		t.Errorf("dec.Decode(...) = %v; want %v", err, io.EOF)
+	}
+}
+
+func TestMessagePackMaxMessageSize(t *testing.T) {
+	m := &runtime.MessagePack{LengthPrefixed: true, MaxMessageSize: 8}
+	for _, data := range []string{
+		"ffffffff",                   // frame of 4 GiB
+		"0000000981a26964a461626364", // frame longer than the maximum message size
+		"00000005c6ffffffff",         // string longer than the maximum message size
+	} {
+		b, _ := hex.DecodeString(data)
+		if err := m.NewDecoder(bytes.NewReader(b)).Decode(&examplepb.SimpleMessage{}); err == nil {
+			t.Errorf("dec.Decode(%s) succeeded; want failure", data)
+		}
+	}
+
+	b, _ := hex.DecodeString("81a26964db7fffffff")
+	if err := (&runtime.MessagePack{}).Unmarshal(b, &examplepb.SimpleMessage{}); err == nil {
+		t.Errorf("m.Unmarshal(%x) succeeded; want failure", b)
+	}
+	b, _ = hex.DecodeString("0000000781a26964a26162")
+	var got examplepb.SimpleMessage
+	if err := m.NewDecoder(bytes.NewReader(b)).Decode(&got); err != nil || got.Id != "ab" {
+		t.Errorf("dec.Decode(%x) = %v with Id %q; want success with %q", b, err, got.Id, "ab")
+	}
+}
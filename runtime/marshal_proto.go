@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"bytes"
 	"io"
 
 	"errors"
@@ -26,6 +27,24 @@ func (*ProtoMarshaller) Marshal(value interface{}) ([]byte, error) {
 	return proto.Marshal(message)
 }
 
+// marshalBuffer appends the proto encoding of "value" to buf.
+func (*ProtoMarshaller) marshalBuffer(buf *bytes.Buffer, value interface{}) error {
+	message, ok := value.(proto.Message)
+	if !ok {
+		return errors.New("unable to marshal non proto field")
+	}
+	n := buf.Len()
+	buf.Grow(proto.Size(message))
+	// MarshalAppend fills the capacity reserved by Grow, so writing the
+	// result back into buf does not move it.
+	b, err := proto.MarshalOptions{}.MarshalAppend(buf.Bytes(), message)
+	if err != nil {
+		return err
+	}
+	buf.Write(b[n:])
+	return nil
+}
+
 // Unmarshal unmarshals proto "data" into "value"
 func (*ProtoMarshaller) Unmarshal(data []byte, value interface{}) error {
 	message, ok := value.(proto.Message)
@@ -0,0 +1,188 @@
+package runtime
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// ProtoText is a Marshaler which marshals/unmarshals into/from the protobuf
+// text format with the "google.golang.org/protobuf/encoding/prototext" package.
+//
+// It is meant for debugging, e.g. to inspect complex messages with curl by
+// registering it for its MIME type and sending a matching Accept header:
+//
+//	mux := runtime.NewServeMux(
+//		runtime.WithMarshalerOption(runtime.MIMEProtoText, &runtime.ProtoText{
+//			MarshalOptions: prototext.MarshalOptions{Multiline: true},
+//		}),
+//	)
+//
+// The text format is not stable across protobuf library versions and must
+// not be relied on by clients.
+type ProtoText struct {
+	prototext.MarshalOptions
+	prototext.UnmarshalOptions
+}
+
+// MIMEProtoText is the MIME type used by ProtoText.
+const MIMEProtoText = "text/x-protobuf"
+
+// ContentType always returns "text/x-protobuf".
+func (*ProtoText) ContentType(_ interface{}) string {
+	return MIMEProtoText
+}
+
+// Marshal marshals "v" into the protobuf text format.
+//
+// Values which are not messages, like the chunks of a server stream, are
+// rendered as if they were messages whose fields are the map keys.
+func (p *ProtoText) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return p.MarshalOptions.Marshal(m)
+	}
+	var buf bytes.Buffer
+	if err := p.marshalNonProtoField(&buf, reflect.ValueOf(v), ""); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (p *ProtoText) marshalNonProtoField(buf *bytes.Buffer, rv reflect.Value, indent string) error {
+	for rv.IsValid() && (rv.Kind() == reflect.Interface || rv.Kind() == reflect.Ptr && !rv.Type().Implements(protoMessageType)) {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	if m, ok := rv.Interface().(proto.Message); ok {
+		b, err := p.MarshalOptions.Marshal(m)
+		if err != nil {
+			return err
+		}
+		p.writeMessage(buf, b, indent)
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			fmt.Fprintf(buf, "%s%v: ", indent, k.Interface())
+			if err := p.marshalNonProtoField(buf, rv.MapIndex(k), indent); err != nil {
+				return err
+			}
+			buf.WriteByte('\n')
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			buf.WriteString(strconv.Quote(string(rv.Bytes())))
+			return nil
+		}
+		buf.WriteByte('[')
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if err := p.marshalNonProtoField(buf, rv.Index(i), indent); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case reflect.String:
+		buf.WriteString(strconv.Quote(rv.String()))
+		return nil
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if enum, ok := rv.Interface().(protoEnum); ok {
+			buf.WriteString(enum.String())
+			return nil
+		}
+		fmt.Fprint(buf, rv.Interface())
+		return nil
+	}
+	return fmt.Errorf("unsupported type: %v", rv.Type())
+}
+
+// writeMessage writes the text of a nested message in braces, indenting it
+// for multiline output.
+func (p *ProtoText) writeMessage(buf *bytes.Buffer, text []byte, indent string) {
+	text = bytes.TrimSpace(text)
+	if !p.Multiline {
+		buf.WriteByte('{')
+		buf.Write(text)
+		buf.WriteByte('}')
+		return
+	}
+	step := p.Indent
+	if step == "" {
+		step = "  "
+	}
+	buf.WriteString("{\n")
+	for _, line := range strings.Split(string(text), "\n") {
+		if line != "" {
+			buf.WriteString(indent + step + line)
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(indent + "}")
+}
+
+// Unmarshal unmarshals text format "data" into "v".
+func (p *ProtoText) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return errors.New("unable to unmarshal non proto field")
+	}
+	return p.UnmarshalOptions.Unmarshal(data, m)
+}
+
+// NewDecoder returns a Decoder which reads text format from "r".
+//
+// The text format is not self-delimiting, so the decoder consumes all of "r".
+func (p *ProtoText) NewDecoder(r io.Reader) Decoder {
+	return DecoderFunc(func(v interface{}) error {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return p.Unmarshal(data, v)
+	})
+}
+
+// NewEncoder returns an Encoder which writes text format into "w".
+func (p *ProtoText) NewEncoder(w io.Writer) Encoder {
+	return EncoderFunc(func(v interface{}) error {
+		buf, err := p.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+		_, err = w.Write(p.Delimiter())
+		return err
+	})
+}
+
+// Delimiter for newline separated text streams.
+func (*ProtoText) Delimiter() []byte {
+	return []byte("\n")
+}
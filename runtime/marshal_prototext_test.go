@@ -0,0 +1,94 @@
+package runtime_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProtoTextMarshalUnmarshal(t *testing.T) {
+	m := &runtime.ProtoText{}
+	buf, err := m.Marshal(message)
+	if err != nil {
+		t.Fatalf("m.Marshal(%v) failed with %v; want success", message, err)
+	}
+	got := &examplepb.ABitOfEverything{}
+	if err := m.Unmarshal(buf, got); err != nil {
+		t.Fatalf("m.Unmarshal(%q) failed with %v; want success", buf, err)
+	}
+	if !proto.Equal(got, message) {
+		t.Errorf("round trip = %v; want %v", got, message)
+	}
+
+	var s string
+	if err := m.Unmarshal(buf, &s); err == nil {
+		t.Errorf("m.Unmarshal(%q, &s) succeeded; want failure", buf)
+	}
+}
+
+func TestProtoTextMarshalNonProtoField(t *testing.T) {
+	m := &runtime.ProtoText{}
+	for _, spec := range []struct {
+		v    interface{}
+		want string
+	}{
+		{v: "foo", want: `"foo"`},
+		{v: int64(5), want: `5`},
+		{v: examplepb.NumericEnum_ONE, want: `ONE`},
+		{v: []string{"a", "b"}, want: `["a", "b"]`},
+	} {
+		buf, err := m.Marshal(spec.v)
+		if err != nil {
+			t.Errorf("m.Marshal(%v) failed with %v; want success", spec.v, err)
+			continue
+		}
+		if string(buf) != spec.want {
+			t.Errorf("m.Marshal(%v) = %q; want %q", spec.v, buf, spec.want)
+		}
+	}
+}
+
+func TestProtoTextStream(t *testing.T) {
+	m := &runtime.ProtoText{MarshalOptions: prototext.MarshalOptions{Multiline: true}}
+	msgs := []proto.Message{&examplepb.SimpleMessage{Id: "one"}, &examplepb.SimpleMessage{Id: "two"}}
+	recv := func() (proto.Message, error) {
+		if len(msgs) == 0 {
+			return nil, io.EOF
+		}
+		msg := msgs[0]
+		msgs = msgs[1:]
+		return msg, nil
+	}
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	resp := httptest.NewRecorder()
+	runtime.ForwardResponseStream(ctx, runtime.NewServeMux(), m, resp, req, recv)
+
+	if got := resp.Header().Get("Content-Type"); got != runtime.MIMEProtoText {
+		t.Errorf("Content-Type = %q; want %q", got, runtime.MIMEProtoText)
+	}
+	records := strings.Split(strings.TrimSpace(resp.Body.String()), "\n\n")
+	if len(records) != 2 {
+		t.Fatalf("got %d records in %q; want 2", len(records), resp.Body.String())
+	}
+	for i, want := range []string{"one", "two"} {
+		if !strings.HasPrefix(records[i], "result: {\n") || !strings.HasSuffix(records[i], "\n}") {
+			t.Errorf("record %d = %q; want a multiline result block", i, records[i])
+		}
+		text := strings.TrimSuffix(strings.TrimPrefix(records[i], "result: {"), "}")
+		var got examplepb.SimpleMessage
+		if err := m.Unmarshal([]byte(text), &got); err != nil {
+			t.Fatalf("m.Unmarshal(%q) failed with %v; want success", text, err)
+		}
+		if got.Id != want {
+			t.Errorf("record %d id = %q; want %q", i, got.Id, want)
+		}
+	}
+}
@@ -0,0 +1,563 @@
+package runtime
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// This file converts between protobuf messages (or the non-message values the
+// gateway marshals, such as stream chunks) and a small tree of Go values which
+// binary self-describing encodings like CBOR and MessagePack serialize.
+//
+// The tree consists of nil, bool, int64, uint64, float32, float64, string,
+// []byte, []interface{} and structuredMap values. Integers keep their
+// signedness and full 64-bit range, and bytes fields stay raw bytes, which is
+// the point of using these encodings instead of JSON.
+
+// structuredEntry is a single key/value pair of a structuredMap.
+type structuredEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+// structuredMap is an ordered map. Keys are string, int64, uint64 or bool.
+type structuredMap []structuredEntry
+
+// structuredOptions configures the conversion between messages and trees.
+type structuredOptions struct {
+	// useProtoNames uses proto field names instead of lowerCamelCase JSON names as map keys.
+	useProtoNames bool
+	// discardUnknown ignores unknown fields while decoding instead of failing.
+	discardUnknown bool
+}
+
+// toStructured converts v into a structured tree.
+func (o structuredOptions) toStructured(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if m, ok := v.(proto.Message); ok {
+		return o.messageToStructured(m.ProtoReflect()), nil
+	}
+	return o.reflectToStructured(reflect.ValueOf(v))
+}
+
+func (o structuredOptions) reflectToStructured(rv reflect.Value) (interface{}, error) {
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	if rv.Type().Implements(protoMessageType) {
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil, nil
+		}
+		return o.messageToStructured(rv.Interface().(proto.Message).ProtoReflect()), nil
+	}
+	if e, ok := rv.Interface().(protoreflect.Enum); ok {
+		return int64(e.Number()), nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return o.reflectToStructured(rv.Elem())
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint(), nil
+	case reflect.Float32:
+		return float32(rv.Float()), nil
+	case reflect.Float64:
+		return rv.Float(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			if rv.Kind() == reflect.Slice {
+				return rv.Bytes(), nil
+			}
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return b, nil
+		}
+		l := make([]interface{}, rv.Len())
+		for i := range l {
+			item, err := o.reflectToStructured(rv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			l[i] = item
+		}
+		return l, nil
+	case reflect.Map:
+		m := make(structuredMap, 0, rv.Len())
+		for _, k := range rv.MapKeys() {
+			key, err := o.reflectToStructured(k)
+			if err != nil {
+				return nil, err
+			}
+			switch key.(type) {
+			case string, int64, uint64, bool:
+			default:
+				return nil, fmt.Errorf("unsupported type of map key: %v", k.Type())
+			}
+			value, err := o.reflectToStructured(rv.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, structuredEntry{key: key, value: value})
+		}
+		sortStructuredMap(m)
+		return m, nil
+	}
+	return nil, fmt.Errorf("unsupported type: %v", rv.Type())
+}
+
+// sortStructuredMap orders the entries of a map built from a Go map so that
+// the output does not depend on map iteration order.
+func sortStructuredMap(m structuredMap) {
+	sort.Slice(m, func(i, j int) bool {
+		return fmt.Sprint(m[i].key) < fmt.Sprint(m[j].key)
+	})
+}
+
+func (o structuredOptions) fieldKey(fd protoreflect.FieldDescriptor) string {
+	if o.useProtoNames {
+		return fd.TextName()
+	}
+	return fd.JSONName()
+}
+
+func (o structuredOptions) messageToStructured(msg protoreflect.Message) structuredMap {
+	fields := msg.Descriptor().Fields()
+	m := make(structuredMap, 0, fields.Len())
+	// Range visits fields in an undefined order, so walk the descriptor instead.
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !msg.Has(fd) {
+			continue
+		}
+		m = append(m, structuredEntry{key: o.fieldKey(fd), value: o.fieldToStructured(fd, msg.Get(fd))})
+	}
+	return m
+}
+
+func (o structuredOptions) fieldToStructured(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		l := make([]interface{}, list.Len())
+		for i := range l {
+			l[i] = o.singularToStructured(fd, list.Get(i))
+		}
+		return l
+	case fd.IsMap():
+		mp := v.Map()
+		m := make(structuredMap, 0, mp.Len())
+		mp.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			m = append(m, structuredEntry{
+				key:   o.singularToStructured(fd.MapKey(), k.Value()),
+				value: o.singularToStructured(fd.MapValue(), v),
+			})
+			return true
+		})
+		sortStructuredMap(m)
+		return m
+	}
+	return o.singularToStructured(fd, v)
+}
+
+func (o structuredOptions) singularToStructured(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return v.Bool()
+	case protoreflect.EnumKind:
+		return int64(v.Enum())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return v.Int()
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return v.Uint()
+	case protoreflect.FloatKind:
+		return float32(v.Float())
+	case protoreflect.DoubleKind:
+		return v.Float()
+	case protoreflect.StringKind:
+		return v.String()
+	case protoreflect.BytesKind:
+		return v.Bytes()
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return o.messageToStructured(v.Message())
+	}
+	return nil
+}
+
+// fromStructured populates v, which must be a pointer, from a structured tree.
+func (o structuredOptions) fromStructured(tree interface{}, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return o.structuredToMessage(tree, m.ProtoReflect())
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("%T is not a pointer", v)
+	}
+	return o.structuredToReflect(tree, rv.Elem())
+}
+
+func (o structuredOptions) structuredToReflect(tree interface{}, rv reflect.Value) error {
+	if rv.Kind() == reflect.Ptr {
+		if tree == nil {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		if m, ok := rv.Interface().(proto.Message); ok {
+			return o.structuredToMessage(tree, m.ProtoReflect())
+		}
+		return o.structuredToReflect(tree, rv.Elem())
+	}
+	if tree == nil {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		b, ok := tree.(bool)
+		if !ok {
+			return fmt.Errorf("cannot assign %T into %v", tree, rv.Type())
+		}
+		rv.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := structuredInt(tree, int64(math.MinInt64), math.MaxInt64)
+		if err != nil {
+			return err
+		}
+		if rv.OverflowInt(i) {
+			return fmt.Errorf("%d overflows %v", i, rv.Type())
+		}
+		rv.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := structuredUint(tree, math.MaxUint64)
+		if err != nil {
+			return err
+		}
+		if rv.OverflowUint(u) {
+			return fmt.Errorf("%d overflows %v", u, rv.Type())
+		}
+		rv.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := structuredFloat(tree)
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(f)
+		return nil
+	case reflect.String:
+		s, ok := tree.(string)
+		if !ok {
+			return fmt.Errorf("cannot assign %T into %v", tree, rv.Type())
+		}
+		rv.SetString(s)
+		return nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b, ok := tree.([]byte)
+			if !ok {
+				return fmt.Errorf("cannot assign %T into %v", tree, rv.Type())
+			}
+			rv.SetBytes(b)
+			return nil
+		}
+		l, ok := tree.([]interface{})
+		if !ok {
+			return fmt.Errorf("cannot assign %T into %v", tree, rv.Type())
+		}
+		s := reflect.MakeSlice(rv.Type(), len(l), len(l))
+		for i, item := range l {
+			if err := o.structuredToReflect(item, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(s)
+		return nil
+	case reflect.Map:
+		m, ok := tree.(structuredMap)
+		if !ok {
+			return fmt.Errorf("cannot assign %T into %v", tree, rv.Type())
+		}
+		mp := reflect.MakeMapWithSize(rv.Type(), len(m))
+		for _, e := range m {
+			k := reflect.New(rv.Type().Key()).Elem()
+			if err := o.structuredToReflect(e.key, k); err != nil {
+				return err
+			}
+			v := reflect.New(rv.Type().Elem()).Elem()
+			if err := o.structuredToReflect(e.value, v); err != nil {
+				return err
+			}
+			mp.SetMapIndex(k, v)
+		}
+		rv.Set(mp)
+		return nil
+	case reflect.Interface:
+		rv.Set(reflect.ValueOf(tree))
+		return nil
+	}
+	return fmt.Errorf("unsupported type: %v", rv.Type())
+}
+
+func (o structuredOptions) structuredToMessage(tree interface{}, msg protoreflect.Message) error {
+	if tree == nil {
+		return nil
+	}
+	m, ok := tree.(structuredMap)
+	if !ok {
+		return fmt.Errorf("cannot assign %T into message %s", tree, msg.Descriptor().FullName())
+	}
+	fields := msg.Descriptor().Fields()
+	for _, e := range m {
+		name, ok := e.key.(string)
+		if !ok {
+			return fmt.Errorf("invalid field name %v in message %s", e.key, msg.Descriptor().FullName())
+		}
+		fd := fields.ByJSONName(name)
+		if fd == nil {
+			fd = fields.ByTextName(name)
+		}
+		if fd == nil {
+			if o.discardUnknown {
+				continue
+			}
+			return fmt.Errorf("unknown field %q in message %s", name, msg.Descriptor().FullName())
+		}
+		if e.value == nil {
+			continue
+		}
+		if err := o.structuredToField(e.value, fd, msg); err != nil {
+			return fmt.Errorf("field %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (o structuredOptions) structuredToField(tree interface{}, fd protoreflect.FieldDescriptor, msg protoreflect.Message) error {
+	switch {
+	case fd.IsList():
+		l, ok := tree.([]interface{})
+		if !ok {
+			return fmt.Errorf("cannot assign %T into repeated field", tree)
+		}
+		list := msg.Mutable(fd).List()
+		for _, item := range l {
+			v, err := o.structuredToSingular(item, fd, list.NewElement)
+			if err != nil {
+				return err
+			}
+			list.Append(v)
+		}
+		return nil
+	case fd.IsMap():
+		m, ok := tree.(structuredMap)
+		if !ok {
+			return fmt.Errorf("cannot assign %T into map field", tree)
+		}
+		mp := msg.Mutable(fd).Map()
+		for _, e := range m {
+			k, err := o.structuredToSingular(structuredMapKey(e.key, fd.MapKey()), fd.MapKey(), nil)
+			if err != nil {
+				return err
+			}
+			v, err := o.structuredToSingular(e.value, fd.MapValue(), mp.NewValue)
+			if err != nil {
+				return err
+			}
+			mp.Set(k.MapKey(), v)
+		}
+		return nil
+	}
+	v, err := o.structuredToSingular(tree, fd, func() protoreflect.Value { return msg.NewField(fd) })
+	if err != nil {
+		return err
+	}
+	msg.Set(fd, v)
+	return nil
+}
+
+// structuredMapKey converts string map keys into the key kind of a map field,
+// since some encoders and clients only produce string keys.
+func structuredMapKey(key interface{}, fd protoreflect.FieldDescriptor) interface{} {
+	s, ok := key.(string)
+	if !ok || fd.Kind() == protoreflect.StringKind {
+		return key
+	}
+	v, err := parseField(fd, s, nil)
+	if err != nil {
+		return key
+	}
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return v.Bool()
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return v.Uint()
+	}
+	return v.Int()
+}
+
+func (o structuredOptions) structuredToSingular(tree interface{}, fd protoreflect.FieldDescriptor, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		b, ok := tree.(bool)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("cannot assign %T into bool", tree)
+		}
+		return protoreflect.ValueOfBool(b), nil
+	case protoreflect.EnumKind:
+		i, err := structuredInt(tree, math.MinInt32, math.MaxInt32)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		i, err := structuredInt(tree, math.MinInt32, math.MaxInt32)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfInt32(int32(i)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		i, err := structuredInt(tree, math.MinInt64, math.MaxInt64)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfInt64(i), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		u, err := structuredUint(tree, math.MaxUint32)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfUint32(uint32(u)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		u, err := structuredUint(tree, math.MaxUint64)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfUint64(u), nil
+	case protoreflect.FloatKind:
+		f, err := structuredFloat(tree)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfFloat32(float32(f)), nil
+	case protoreflect.DoubleKind:
+		f, err := structuredFloat(tree)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	case protoreflect.StringKind:
+		s, ok := tree.(string)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("cannot assign %T into string", tree)
+		}
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BytesKind:
+		switch b := tree.(type) {
+		case []byte:
+			return protoreflect.ValueOfBytes(b), nil
+		case string:
+			return protoreflect.ValueOfBytes([]byte(b)), nil
+		}
+		return protoreflect.Value{}, fmt.Errorf("cannot assign %T into bytes", tree)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		v := newMessage()
+		if err := o.structuredToMessage(tree, v.Message()); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return v, nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported field kind %v", fd.Kind())
+}
+
+var errStructuredRange = errors.New("integer out of range")
+
+func structuredInt(tree interface{}, min, max int64) (int64, error) {
+	switch v := tree.(type) {
+	case int64:
+		if v < min || v > max {
+			return 0, errStructuredRange
+		}
+		return v, nil
+	case uint64:
+		if v > uint64(max) {
+			return 0, errStructuredRange
+		}
+		return int64(v), nil
+	}
+	return 0, fmt.Errorf("cannot assign %T into integer", tree)
+}
+
+func structuredUint(tree interface{}, max uint64) (uint64, error) {
+	switch v := tree.(type) {
+	case int64:
+		if v < 0 || uint64(v) > max {
+			return 0, errStructuredRange
+		}
+		return uint64(v), nil
+	case uint64:
+		if v > max {
+			return 0, errStructuredRange
+		}
+		return v, nil
+	}
+	return 0, fmt.Errorf("cannot assign %T into unsigned integer", tree)
+}
+
+func structuredFloat(tree interface{}) (float64, error) {
+	switch v := tree.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	}
+	return 0, fmt.Errorf("cannot assign %T into float", tree)
+}
+
+// defaultMaxMessageSize is the default bound of the strings and frames read
+// by the binary marshalers, the default maximum message size of gRPC servers.
+const defaultMaxMessageSize = 4 << 20
+
+func maxMessageSize(size int) int64 {
+	if size <= 0 {
+		return defaultMaxMessageSize
+	}
+	return int64(size)
+}
+
+// readFull reads "n" bytes from "r" into a buffer growing as they arrive, so
+// that a length read from a client only costs memory once its data is read.
+func readFull(r io.Reader, n int64) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, n); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return buf.Bytes(), nil
+}
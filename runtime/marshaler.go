@@ -48,3 +48,19 @@ type Delimited interface {
 	// Delimiter returns the record separator for the stream.
 	Delimiter() []byte
 }
+
+// Framed defines a per-message framing for streams. It is used by marshalers
+// whose stream records cannot be separated by a fixed delimiter, e.g. when
+// every record is prefixed by its length.
+type Framed interface {
+	// FramePrefix returns the bytes written before a stream record of n bytes.
+	FramePrefix(n int) []byte
+}
+
+// StreamPreambler is implemented by marshalers which write a preamble, such as
+// a header row, before the first record of a stream.
+type StreamPreambler interface {
+	// StreamPreamble returns the bytes written before the first stream record.
+	// "first" is the value marshaled as that record.
+	StreamPreamble(first interface{}) ([]byte, error)
+}
@@ -0,0 +1,90 @@
+package runtime
+
+import "sync/atomic"
+
+const (
+	// marshalerCacheSize is the number of slots of a marshalerCache.
+	marshalerCacheSize = 64
+	// maxCachedHeaderSize bounds the total size of the header values cached
+	// in a slot, so that large headers do not pin memory.
+	maxCachedHeaderSize = 512
+)
+
+// marshalerCache caches the marshalers resolved for the Accept and
+// Content-Type headers of requests. It is a direct-mapped cache: the header
+// values hash to a single slot, which holds the most recently resolved values
+// with that hash. Lookups and updates are lock-free, and lookups which hit
+// the cache do not allocate.
+type marshalerCache struct {
+	slots [marshalerCacheSize]atomic.Value // of *marshalerCacheEntry
+}
+
+type marshalerCacheEntry struct {
+	accept, contentType []string
+	inbound, outbound   Marshaler
+}
+
+// get returns the marshalers cached for the header values.
+func (c *marshalerCache) get(accept, contentType []string) (inbound, outbound Marshaler, ok bool) {
+	e, _ := c.slots[marshalerCacheSlot(accept, contentType)].Load().(*marshalerCacheEntry)
+	if e == nil || !equalStrings(e.accept, accept) || !equalStrings(e.contentType, contentType) {
+		return nil, nil, false
+	}
+	return e.inbound, e.outbound, true
+}
+
+// put caches the marshalers resolved for the header values.
+func (c *marshalerCache) put(accept, contentType []string, inbound, outbound Marshaler) {
+	size := 0
+	for _, v := range accept {
+		size += len(v)
+	}
+	for _, v := range contentType {
+		size += len(v)
+	}
+	if size > maxCachedHeaderSize {
+		return
+	}
+	c.slots[marshalerCacheSlot(accept, contentType)].Store(&marshalerCacheEntry{
+		accept:      append([]string(nil), accept...),
+		contentType: append([]string(nil), contentType...),
+		inbound:     inbound,
+		outbound:    outbound,
+	})
+}
+
+// reset empties the cache.
+func (c *marshalerCache) reset() {
+	for i := range c.slots {
+		c.slots[i].Store((*marshalerCacheEntry)(nil))
+	}
+}
+
+// marshalerCacheSlot returns the slot of the header values, using the FNV-1a hash.
+func marshalerCacheSlot(accept, contentType []string) int {
+	h := uint32(2166136261)
+	hash := func(values []string) {
+		for _, v := range values {
+			for i := 0; i < len(v); i++ {
+				h = (h ^ uint32(v[i])) * 16777619
+			}
+			h = (h ^ 0xff) * 16777619
+		}
+		h = (h ^ 0xfe) * 16777619
+	}
+	hash(accept)
+	hash(contentType)
+	return int(h % marshalerCacheSize)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
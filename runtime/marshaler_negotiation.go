@@ -0,0 +1,318 @@
+package runtime
+
+import (
+	"context"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mediaRange is a single element of an Accept header (RFC 9110, section 12.5.1).
+type mediaRange struct {
+	typ, subtype string
+	params       map[string]string
+	q            float64
+	// index is the position of the range in the header, used to break ties.
+	index int
+}
+
+// parseAccept parses the values of the Accept headers of a request. Invalid
+// elements are skipped.
+func parseAccept(values []string) []mediaRange {
+	var ranges []mediaRange
+	for _, value := range values {
+		for _, elem := range strings.Split(value, ",") {
+			elem = strings.TrimSpace(elem)
+			if elem == "" {
+				continue
+			}
+			mediatype, params, err := mime.ParseMediaType(elem)
+			if err != nil {
+				continue
+			}
+			typ, subtype, ok := splitMediaType(mediatype)
+			if !ok || typ == "*" && subtype != "*" {
+				continue
+			}
+			q := 1.0
+			if qs, ok := params["q"]; ok {
+				q, err = strconv.ParseFloat(qs, 64)
+				if err != nil || q < 0 || q > 1 {
+					continue
+				}
+				delete(params, "q")
+			}
+			ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, params: params, q: q, index: len(ranges)})
+		}
+	}
+	return ranges
+}
+
+func splitMediaType(mediatype string) (typ, subtype string, ok bool) {
+	i := strings.IndexByte(mediatype, '/')
+	if i <= 0 || i == len(mediatype)-1 {
+		return "", "", false
+	}
+	return mediatype[:i], mediatype[i+1:], true
+}
+
+// specificity returns how specifically r matches the registered media type
+// mt, or -1 if it does not match. For a versioned vendor type, it also returns
+// the version requested by r, if any.
+func (r mediaRange) specificity(mt registeredMediaType) (int, string) {
+	switch {
+	case r.typ == "*":
+		return 0, ""
+	case r.typ != mt.typ:
+		return -1, ""
+	case r.subtype == "*":
+		return 1, ""
+	}
+	version, ok := mt.matchSubtype(r.subtype)
+	if !ok {
+		return -1, ""
+	}
+	for k, v := range r.params {
+		if mt.params[k] != v {
+			return -1, ""
+		}
+	}
+	// An exact registration is more specific than a versioned vendor type.
+	if mt.versioned {
+		return 2 + len(r.params), version
+	}
+	return 3 + len(r.params), version
+}
+
+// registeredMediaType is a MIME type of the registry parsed for negotiation.
+//
+// A subtype containing a "*", like "vnd.myco.v*+json", is a versioned vendor
+// type: the "*" matches any version made of digits and dots.
+type registeredMediaType struct {
+	mime         string
+	typ, subtype string
+	params       map[string]string
+	// versionPrefix and versionSuffix surround the version of a versioned vendor type.
+	versioned                    bool
+	versionPrefix, versionSuffix string
+}
+
+// matchSubtype reports whether subtype matches mt, and returns the version of
+// a versioned vendor type.
+func (mt registeredMediaType) matchSubtype(subtype string) (string, bool) {
+	if !mt.versioned {
+		return "", subtype == mt.subtype
+	}
+	if len(subtype) <= len(mt.versionPrefix)+len(mt.versionSuffix) ||
+		!strings.HasPrefix(subtype, mt.versionPrefix) || !strings.HasSuffix(subtype, mt.versionSuffix) {
+		return "", false
+	}
+	version := subtype[len(mt.versionPrefix) : len(subtype)-len(mt.versionSuffix)]
+	if !isMediaTypeVersion(version) {
+		return "", false
+	}
+	return version, true
+}
+
+func isMediaTypeVersion(version string) bool {
+	if version[0] == '.' || version[len(version)-1] == '.' {
+		return false
+	}
+	for _, c := range version {
+		if (c < '0' || c > '9') && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func parseRegisteredMediaType(m string) (registeredMediaType, bool) {
+	mediatype, params, err := mime.ParseMediaType(m)
+	if err != nil {
+		return registeredMediaType{}, false
+	}
+	typ, subtype, ok := splitMediaType(mediatype)
+	if !ok {
+		return registeredMediaType{}, false
+	}
+	mt := registeredMediaType{mime: m, typ: typ, subtype: subtype, params: params}
+	if i := strings.IndexByte(subtype, '*'); i >= 0 {
+		if typ == "*" || strings.Count(subtype, "*") != 1 || subtype == "*" {
+			return registeredMediaType{}, false
+		}
+		mt.versioned, mt.versionPrefix, mt.versionSuffix = true, subtype[:i], subtype[i+1:]
+	}
+	return mt, true
+}
+
+// negotiate selects the outbound marshaler for the given Accept header values.
+//
+// It returns the registered marshaler the client prefers, honoring q-values,
+// wildcards and media type parameters. A nil marshaler with ok set means the
+// client has no preference among the registered marshalers or prefers the
+// default one, e.g. because it did not send an Accept header or sent "*/*".
+// ok is false if none of the registered marshalers is acceptable.
+//
+// If the selected marshaler is registered for a versioned vendor type, the
+// version requested by the client is returned too.
+func (m marshalerRegistry) negotiate(accept []string) (marshaler Marshaler, version string, ok bool) {
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return nil, "", true
+	}
+
+	type candidate struct {
+		mime        string
+		version     string
+		q           float64
+		specificity int
+		index       int
+	}
+	var best *candidate
+	better := func(c candidate) bool {
+		switch {
+		case best == nil:
+			return true
+		case c.q != best.q:
+			return c.q > best.q
+		case c.specificity != best.specificity:
+			return c.specificity > best.specificity
+		case c.index != best.index:
+			return c.index < best.index
+		}
+		return c.mime < best.mime
+	}
+
+	defaultQ := m.defaultQuality(ranges)
+	for _, mt := range mediaTypesOf(m.mimeMap) {
+		// The most specific matching range determines the quality of a type.
+		match := candidate{mime: mt.mime, specificity: -1}
+		for _, r := range ranges {
+			s, version := r.specificity(mt)
+			if s > match.specificity {
+				match.q, match.specificity, match.index, match.version = r.q, s, r.index, version
+			}
+		}
+		// Types only matched by "*/*" are no better than the default marshaler.
+		if match.specificity <= 0 || match.q == 0 {
+			continue
+		}
+		if better(match) {
+			c := match
+			best = &c
+		}
+	}
+
+	switch {
+	case best != nil && best.q >= defaultQ:
+		return m.mimeMap[best.mime], best.version, true
+	case defaultQ > 0:
+		return nil, "", true
+	}
+	return nil, "", false
+}
+
+// defaultQuality returns the quality of the default marshaler for the
+// ranges: the quality of the most specific range matching its content type,
+// or of "*/*" if its content type cannot be parsed.
+func (m marshalerRegistry) defaultQuality(ranges []mediaRange) float64 {
+	var q float64
+	if def, ok := m.mimeMap[MIMEWildcard]; ok {
+		if mt, ok := parseRegisteredMediaType(def.ContentType(nil)); ok && !mt.versioned {
+			specificity := -1
+			for _, r := range ranges {
+				if s, _ := r.specificity(mt); s > specificity {
+					q, specificity = r.q, s
+				}
+			}
+			return q
+		}
+	}
+	for _, r := range ranges {
+		if r.typ == "*" && r.q > q {
+			q = r.q
+		}
+	}
+	return q
+}
+
+// lookupVersioned returns the marshaler registered for the versioned vendor
+// type matching mediatype, and the version of mediatype.
+func (m marshalerRegistry) lookupVersioned(mediatype string, params map[string]string) (Marshaler, string, bool) {
+	typ, subtype, ok := splitMediaType(mediatype)
+	if !ok {
+		return nil, "", false
+	}
+	for _, mt := range mediaTypesOf(m.mimeMap) {
+		if !mt.versioned || mt.typ != typ {
+			continue
+		}
+		version, ok := mt.matchSubtype(subtype)
+		if !ok {
+			continue
+		}
+		match := true
+		for k, v := range mt.params {
+			if params[k] != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			return m.mimeMap[mt.mime], version, true
+		}
+	}
+	return nil, "", false
+}
+
+// mediaTypesOf returns the parsed, sorted MIME types of a registry.
+func mediaTypesOf(mimeMap map[string]Marshaler) []registeredMediaType {
+	var mts []registeredMediaType
+	for m := range mimeMap {
+		if m == MIMEWildcard {
+			continue
+		}
+		if mt, ok := parseRegisteredMediaType(m); ok {
+			mts = append(mts, mt)
+		}
+	}
+	sort.Slice(mts, func(i, j int) bool { return mts[i].mime < mts[j].mime })
+	return mts
+}
+
+// contentTypeVersion returns the version of the first Content-Type matching a
+// versioned vendor type.
+func (m marshalerRegistry) contentTypeVersion(contentTypes []string) string {
+	for _, contentTypeVal := range contentTypes {
+		contentType, params, err := mime.ParseMediaType(contentTypeVal)
+		if err != nil {
+			continue
+		}
+		if _, ok := m.mimeMap[contentType]; ok {
+			return ""
+		}
+		if _, version, ok := m.lookupVersioned(contentType, params); ok {
+			return version
+		}
+	}
+	return ""
+}
+
+type mediaTypeVersionKey struct{}
+
+func withMediaTypeVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, mediaTypeVersionKey{}, version)
+}
+
+// MediaTypeVersionFromContext returns the version of the vendor media type
+// requested by the client, e.g. "2" for "application/vnd.myco.v2+json", when
+// it matched a marshaler registered for a versioned vendor type such as
+// "application/vnd.myco.v*+json".
+//
+// The version is taken from the negotiated Accept header, or from the
+// Content-Type header if the Accept header does not request a version.
+func MediaTypeVersionFromContext(ctx context.Context) (string, bool) {
+	version, ok := ctx.Value(mediaTypeVersionKey{}).(string)
+	return version, ok
+}
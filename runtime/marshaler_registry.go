@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"bytes"
 	"errors"
 	"mime"
 	"net/http"
@@ -35,49 +36,97 @@ var (
 // If there are multiple Content-Type headers set, choose the first one that it can
 // exactly match in the registry.
 // Otherwise, it follows the above logic for "*"/InboundMarshaler/OutboundMarshaler.
+//
+// The outbound marshaler is negotiated from the Accept header following RFC 9110,
+// honoring q-values, wildcards such as "application/*" and media type parameters.
+// An Accept header value which exactly matches a registered MIME type always wins.
+// If the client prefers none of the registered MIME types, the outbound marshaler
+// follows the inbound one; see WithStrictAcceptNegotiation to reject such requests.
+//
+// With WithQueryRenderingOptions, the outbound marshaler honors the JSON
+// rendering options requested by the query parameters of the request.
+// With WithResponseEnvelope, it wraps JSON responses in the envelope.
+//
+// The marshalers of a route registered with WithRouteMarshaler or
+// WithRouteMarshalerOption are selected by the route instead of the mux.
 func MarshalerForRequest(mux *ServeMux, r *http.Request) (inbound Marshaler, outbound Marshaler) {
-	for _, acceptVal := range r.Header[acceptHeader] {
-		if m, ok := mux.marshalers.mimeMap[acceptVal]; ok {
-			outbound = m
+	if o := routeOptionsFromContext(r.Context()); o != nil && o.marshaler != nil {
+		inbound, outbound = o.marshaler, o.marshaler
+	} else {
+		inbound, outbound = mux.marshalersFor(r.Context()).lookup(r.Header[acceptHeader], r.Header[contentTypeHeader])
+	}
+	outbound = mux.renderingMarshaler(r, outbound)
+	outbound = mux.envelopeMarshaler(r, outbound)
+
+	return inbound, outbound
+}
+
+// lookup returns the inbound/outbound marshalers for the Accept and
+// Content-Type header values of a request, resolving them with resolve if
+// they are not cached.
+func (m marshalerRegistry) lookup(accept, contentType []string) (inbound Marshaler, outbound Marshaler) {
+	if inbound, outbound, ok := m.cache.get(accept, contentType); ok {
+		return inbound, outbound
+	}
+	inbound, outbound = m.resolve(accept, contentType)
+	m.cache.put(accept, contentType, inbound, outbound)
+	return inbound, outbound
+}
+
+// resolve returns the inbound/outbound marshalers for the Accept and
+// Content-Type header values of a request, see MarshalerForRequest.
+func (m marshalerRegistry) resolve(acceptVals, contentTypeVals []string) (inbound Marshaler, outbound Marshaler) {
+	for _, acceptVal := range acceptVals {
+		if marshaler, ok := m.mimeMap[acceptVal]; ok {
+			outbound = marshaler
 			break
 		}
 	}
+	if outbound == nil {
+		outbound, _, _ = m.negotiate(acceptVals)
+	}
 
-	for _, contentTypeVal := range r.Header[contentTypeHeader] {
-		contentType, _, err := mime.ParseMediaType(contentTypeVal)
+	for _, contentTypeVal := range contentTypeVals {
+		contentType, params, err := mime.ParseMediaType(contentTypeVal)
 		if err != nil {
 			grpclog.Infof("Failed to parse Content-Type %s: %v", contentTypeVal, err)
 			continue
 		}
-		if m, ok := mux.marshalers.mimeMap[contentType]; ok {
-			inbound = m
+		if marshaler, ok := m.mimeMap[contentType]; ok {
+			inbound = marshaler
+			break
+		}
+		if marshaler, _, ok := m.lookupVersioned(contentType, params); ok {
+			inbound = marshaler
 			break
 		}
 	}
 
 	if inbound == nil {
-		inbound = mux.marshalers.mimeMap[MIMEWildcard]
+		inbound = m.mimeMap[MIMEWildcard]
 	}
 	if outbound == nil {
 		outbound = inbound
 	}
-
 	return inbound, outbound
 }
 
 // marshalerRegistry is a mapping from MIME types to Marshalers.
 type marshalerRegistry struct {
 	mimeMap map[string]Marshaler
+	cache   *marshalerCache
 }
 
 // add adds a marshaler for a case-sensitive MIME type string ("*" to match any
-// MIME type).
+// MIME type). A "*" in place of the version of a vendor type, like
+// "application/vnd.myco.v*+json", matches any version of that type.
 func (m marshalerRegistry) add(mime string, marshaler Marshaler) error {
 	if len(mime) == 0 {
 		return errors.New("empty MIME type")
 	}
 
 	m.mimeMap[mime] = marshaler
+	m.cache.reset()
 
 	return nil
 }
@@ -95,15 +144,107 @@ func makeMarshalerMIMERegistry() marshalerRegistry {
 		mimeMap: map[string]Marshaler{
 			MIMEWildcard: defaultMarshaler,
 		},
+		cache: new(marshalerCache),
 	}
 }
 
 // WithMarshalerOption returns a ServeMuxOption which associates inbound and outbound
-// Marshalers to a MIME type in mux.
-func WithMarshalerOption(mime string, marshaler Marshaler) ServeMuxOption {
+// Marshalers to a MIME type in mux. The options configure how the marshaler
+// writes server streams.
+func WithMarshalerOption(mime string, marshaler Marshaler, opts ...MarshalerRegistrationOption) ServeMuxOption {
 	return func(mux *ServeMux) {
-		if err := mux.marshalers.add(mime, marshaler); err != nil {
+		if err := mux.marshalers.add(mime, registeredMarshaler(marshaler, opts)); err != nil {
 			panic(err)
 		}
 	}
 }
+
+// registeredMarshaler returns marshaler configured with opts.
+func registeredMarshaler(marshaler Marshaler, opts []MarshalerRegistrationOption) Marshaler {
+	if len(opts) == 0 {
+		return marshaler
+	}
+	d := &delimitedMarshaler{Marshaler: marshaler}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// MarshalerRegistrationOption configures a marshaler registered with
+// WithMarshalerOption or WithRouteMarshalerOption.
+type MarshalerRegistrationOption func(*delimitedMarshaler)
+
+// WithStreamDelimiter returns a MarshalerRegistrationOption which sets the
+// delimiter written after each message of a server stream, e.g. "\r\n",
+// instead of the Delimiter of the marshaler.
+func WithStreamDelimiter(delimiter []byte) MarshalerRegistrationOption {
+	return func(d *delimitedMarshaler) {
+		d.delimiter = delimiter
+	}
+}
+
+// WithStreamRecordPrefix returns a MarshalerRegistrationOption which sets the
+// bytes written before each message of a server stream. JSON text sequences
+// (RFC 7464) can be streamed with
+//
+//	runtime.WithMarshalerOption("application/json-seq", &runtime.JSONPb{},
+//		runtime.WithStreamRecordPrefix([]byte("\x1e")),
+//		runtime.WithStreamDelimiter([]byte("\n")),
+//	)
+func WithStreamRecordPrefix(prefix []byte) MarshalerRegistrationOption {
+	return func(d *delimitedMarshaler) {
+		d.prefix = prefix
+	}
+}
+
+// delimitedMarshaler is a Marshaler with the stream framing configured by
+// MarshalerRegistrationOptions.
+type delimitedMarshaler struct {
+	Marshaler
+	delimiter []byte
+	prefix    []byte
+}
+
+// Delimiter returns the configured delimiter, or the one of the wrapped Marshaler.
+func (d *delimitedMarshaler) Delimiter() []byte {
+	if d.delimiter != nil {
+		return d.delimiter
+	}
+	if w, ok := d.Marshaler.(Delimited); ok {
+		return w.Delimiter()
+	}
+	return []byte("\n")
+}
+
+// FramePrefix returns the configured record prefix, or the frame prefix of
+// the wrapped Marshaler.
+func (d *delimitedMarshaler) FramePrefix(n int) []byte {
+	if d.prefix != nil {
+		return d.prefix
+	}
+	if f, ok := d.Marshaler.(Framed); ok {
+		return f.FramePrefix(n)
+	}
+	return nil
+}
+
+// StreamPreamble returns the stream preamble of the wrapped Marshaler, if any.
+func (d *delimitedMarshaler) StreamPreamble(first interface{}) ([]byte, error) {
+	if p, ok := d.Marshaler.(StreamPreambler); ok {
+		return p.StreamPreamble(first)
+	}
+	return nil, nil
+}
+
+// marshalBuffer appends the marshaled form of v to buf.
+func (d *delimitedMarshaler) marshalBuffer(buf *bytes.Buffer, v interface{}) error {
+	return marshalToBuffer(d.Marshaler, buf, v)
+}
+
+// withMarshaler returns a copy of d wrapping m.
+func (d *delimitedMarshaler) withMarshaler(m Marshaler) *delimitedMarshaler {
+	c := *d
+	c.Marshaler = m
+	return &c
+}
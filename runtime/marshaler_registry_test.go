@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
@@ -91,6 +92,208 @@ func TestMarshalerForRequest(t *testing.T) {
 	}
 }
 
+func TestMarshalerForRequest_AcceptNegotiation(t *testing.T) {
+	marshalers := []dummyMarshaler{0, 1, 2, 3}
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &marshalers[0]),
+		runtime.WithMarshalerOption("application/json", &marshalers[1]),
+		runtime.WithMarshalerOption("application/xml", &marshalers[2]),
+		runtime.WithMarshalerOption("text/plain; charset=utf-8", &marshalers[3]),
+	)
+
+	for _, spec := range []struct {
+		accept  string
+		wantOut runtime.Marshaler
+	}{
+		{accept: "", wantOut: &marshalers[0]},
+		{accept: "*/*", wantOut: &marshalers[0]},
+		{accept: "application/json", wantOut: &marshalers[1]},
+		{accept: "application/xml, application/json", wantOut: &marshalers[2]},
+		{accept: "application/xml;q=0.5, application/json", wantOut: &marshalers[1]},
+		{accept: "application/json;q=0.2, */*;q=0.8", wantOut: &marshalers[0]},
+		{accept: "application/json;q=0.8, */*;q=0.8", wantOut: &marshalers[1]},
+		{accept: "application/*", wantOut: &marshalers[1]},
+		{accept: "application/*, application/xml;q=0.9", wantOut: &marshalers[1]},
+		{accept: "application/*, application/json;q=0", wantOut: &marshalers[2]},
+		{accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", wantOut: &marshalers[2]},
+		{accept: "text/plain", wantOut: &marshalers[3]},
+		{accept: "text/plain; charset=utf-8", wantOut: &marshalers[3]},
+		{accept: "text/plain; charset=latin1", wantOut: &marshalers[0]},
+		{accept: "application/json;q=invalid, application/xml", wantOut: &marshalers[2]},
+		{accept: "image/png", wantOut: &marshalers[0]},
+	} {
+		r, err := http.NewRequest("GET", "http://example.com", nil)
+		if err != nil {
+			t.Fatalf(`http.NewRequest("GET", "http://example.com", nil) failed with %v; want success`, err)
+		}
+		if spec.accept != "" {
+			r.Header.Set("Accept", spec.accept)
+		}
+		_, out := runtime.MarshalerForRequest(mux, r)
+		if got, want := out, spec.wantOut; got != want {
+			t.Errorf("Accept %q: out = %#v; want %#v", spec.accept, got, want)
+		}
+	}
+}
+
+func TestServeMux_StrictAcceptNegotiation(t *testing.T) {
+	mux := runtime.NewServeMux(
+		runtime.WithStrictAcceptNegotiation(),
+		runtime.WithMarshalerOption("application/json", &runtime.JSONPb{}),
+	)
+	if err := mux.HandlePath("GET", "/v1/resource", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		w.WriteHeader(http.StatusOK)
+	}); err != nil {
+		t.Fatalf("mux.HandlePath failed with %v; want success", err)
+	}
+
+	for _, spec := range []struct {
+		accept     string
+		wantStatus int
+	}{
+		{accept: "", wantStatus: http.StatusOK},
+		{accept: "application/json", wantStatus: http.StatusOK},
+		{accept: "application/*;q=0.5", wantStatus: http.StatusOK},
+		{accept: "image/png, */*;q=0.1", wantStatus: http.StatusOK},
+		{accept: "image/png", wantStatus: http.StatusNotAcceptable},
+		{accept: "application/json;q=0", wantStatus: http.StatusNotAcceptable},
+	} {
+		r := httptest.NewRequest("GET", "/v1/resource", nil)
+		if spec.accept != "" {
+			r.Header.Set("Accept", spec.accept)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if got, want := w.Code, spec.wantStatus; got != want {
+			t.Errorf("Accept %q: w.Code = %d; want %d", spec.accept, got, want)
+		}
+	}
+}
+
+func TestServeMux_StrictAcceptNegotiationDefaultMarshaler(t *testing.T) {
+	mux := runtime.NewServeMux(runtime.WithStrictAcceptNegotiation())
+	if err := mux.HandlePath("GET", "/v1/resource", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		w.WriteHeader(http.StatusOK)
+	}); err != nil {
+		t.Fatalf("mux.HandlePath failed with %v; want success", err)
+	}
+
+	for _, spec := range []struct {
+		accept     string
+		wantStatus int
+	}{
+		{accept: "", wantStatus: http.StatusOK},
+		{accept: "*/*", wantStatus: http.StatusOK},
+		{accept: "application/json", wantStatus: http.StatusOK},
+		{accept: "image/png, application/*;q=0.5", wantStatus: http.StatusOK},
+		{accept: "image/png", wantStatus: http.StatusNotAcceptable},
+		{accept: "application/json;q=0, */*", wantStatus: http.StatusNotAcceptable},
+	} {
+		r := httptest.NewRequest("GET", "/v1/resource", nil)
+		if spec.accept != "" {
+			r.Header.Set("Accept", spec.accept)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if got, want := w.Code, spec.wantStatus; got != want {
+			t.Errorf("Accept %q: w.Code = %d; want %d", spec.accept, got, want)
+		}
+	}
+}
+
+func TestServeMux_VendorMediaTypeVersion(t *testing.T) {
+	marshalers := []dummyMarshaler{0, 1, 2}
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &marshalers[0]),
+		runtime.WithMarshalerOption("application/vnd.myco.v*+json", &marshalers[1]),
+		runtime.WithMarshalerOption("application/vnd.myco.v1+json", &marshalers[2]),
+	)
+	var (
+		gotVersion string
+		gotOK      bool
+		gotIn      runtime.Marshaler
+		gotOut     runtime.Marshaler
+	)
+	if err := mux.HandlePath("POST", "/v1/resource", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		gotVersion, gotOK = runtime.MediaTypeVersionFromContext(r.Context())
+		gotIn, gotOut = runtime.MarshalerForRequest(mux, r)
+	}); err != nil {
+		t.Fatalf("mux.HandlePath failed with %v; want success", err)
+	}
+
+	for _, spec := range []struct {
+		accept, contentType string
+
+		wantVersion string
+		wantIn      runtime.Marshaler
+		wantOut     runtime.Marshaler
+	}{
+		{
+			accept:      "application/vnd.myco.v2+json",
+			wantVersion: "2",
+			wantIn:      &marshalers[0],
+			wantOut:     &marshalers[1],
+		},
+		{
+			accept:      "application/vnd.myco.v2.1+json, */*;q=0.1",
+			wantVersion: "2.1",
+			wantIn:      &marshalers[0],
+			wantOut:     &marshalers[1],
+		},
+		// An exact registration takes precedence over the versioned one.
+		{
+			accept:  "application/vnd.myco.v1+json",
+			wantIn:  &marshalers[0],
+			wantOut: &marshalers[2],
+		},
+		{
+			contentType: "application/vnd.myco.v3+json; charset=utf-8",
+			wantVersion: "3",
+			wantIn:      &marshalers[1],
+			wantOut:     &marshalers[1],
+		},
+		{
+			accept:      "application/vnd.myco.v4+json",
+			contentType: "application/vnd.myco.v3+json",
+			wantVersion: "4",
+			wantIn:      &marshalers[1],
+			wantOut:     &marshalers[1],
+		},
+		{
+			accept:  "application/vnd.myco.vnext+json",
+			wantIn:  &marshalers[0],
+			wantOut: &marshalers[0],
+		},
+		{
+			accept:  "application/vnd.myco.v+json",
+			wantIn:  &marshalers[0],
+			wantOut: &marshalers[0],
+		},
+	} {
+		r := httptest.NewRequest("POST", "/v1/resource", nil)
+		if spec.accept != "" {
+			r.Header.Set("Accept", spec.accept)
+		}
+		if spec.contentType != "" {
+			r.Header.Set("Content-Type", spec.contentType)
+		}
+		gotVersion, gotOK = "", false
+		mux.ServeHTTP(httptest.NewRecorder(), r)
+		if got, want := gotVersion, spec.wantVersion; got != want {
+			t.Errorf("Accept %q, Content-Type %q: version = %q; want %q", spec.accept, spec.contentType, got, want)
+		}
+		if got, want := gotOK, spec.wantVersion != ""; got != want {
+			t.Errorf("Accept %q, Content-Type %q: ok = %v; want %v", spec.accept, spec.contentType, got, want)
+		}
+		if got, want := gotIn, spec.wantIn; got != want {
+			t.Errorf("Accept %q, Content-Type %q: in = %#v; want %#v", spec.accept, spec.contentType, got, want)
+		}
+		if got, want := gotOut, spec.wantOut; got != want {
+			t.Errorf("Accept %q, Content-Type %q: out = %#v; want %#v", spec.accept, spec.contentType, got, want)
+		}
+	}
+}
+
 type dummyMarshaler int
 
 func (dummyMarshaler) ContentType(_ interface{}) string { return "" }
@@ -124,3 +327,45 @@ type dummyEncoder struct{}
 func (dummyEncoder) Encode(interface{}) error {
 	return errors.New("not implemented")
 }
+
+func TestMarshalerForRequest_Cached(t *testing.T) {
+	marshalers := []dummyMarshaler{0, 1, 2}
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &marshalers[0]),
+		runtime.WithMarshalerOption("application/json", &marshalers[1]),
+		runtime.WithMarshalerOption("application/xml", &marshalers[2]),
+	)
+	newRequest := func(accept, contentType string) *http.Request {
+		r, err := http.NewRequest("POST", "http://example.com", nil)
+		if err != nil {
+			t.Fatalf(`http.NewRequest("POST", "http://example.com", nil) failed with %v; want success`, err)
+		}
+		r.Header.Set("Accept", accept)
+		r.Header.Set("Content-Type", contentType)
+		return r
+	}
+
+	// Repeated lookups return the same marshalers as the first one, and
+	// distinct header values are not confused with each other.
+	for i := 0; i < 2; i++ {
+		for _, spec := range []struct {
+			accept, contentType string
+			wantIn, wantOut     runtime.Marshaler
+		}{
+			{"application/json", "application/xml", &marshalers[2], &marshalers[1]},
+			{"application/xml", "application/json", &marshalers[1], &marshalers[2]},
+			{"application/xml;q=0.5, application/json", "text/plain", &marshalers[0], &marshalers[1]},
+		} {
+			in, out := runtime.MarshalerForRequest(mux, newRequest(spec.accept, spec.contentType))
+			if in != spec.wantIn || out != spec.wantOut {
+				t.Errorf("Accept %q, Content-Type %q: in, out = %#v, %#v; want %#v, %#v", spec.accept, spec.contentType, in, out, spec.wantIn, spec.wantOut)
+			}
+		}
+	}
+
+	r := newRequest("application/xml;q=0.5, application/json", "application/json; charset=utf-8")
+	runtime.MarshalerForRequest(mux, r)
+	if allocs := testing.AllocsPerRun(100, func() { runtime.MarshalerForRequest(mux, r) }); allocs != 0 {
+		t.Errorf("MarshalerForRequest allocated %v times per call; want 0", allocs)
+	}
+}
@@ -0,0 +1,37 @@
+package runtime
+
+import (
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// MessagePool is a pool of messages of one type. The handlers generated by
+// protoc-gen-grpc-gateway with pool_request_messages take their request
+// messages from pools, saving the allocation of a message per call, which is
+// measurable for large request messages at high request rates.
+type MessagePool struct {
+	pool sync.Pool
+}
+
+// NewMessagePool returns a pool of the messages allocated by newMessage.
+func NewMessagePool(newMessage func() proto.Message) *MessagePool {
+	return &MessagePool{
+		pool: sync.Pool{
+			New: func() interface{} {
+				return newMessage()
+			},
+		},
+	}
+}
+
+// Get returns an empty message from p.
+func (p *MessagePool) Get() proto.Message {
+	return p.pool.Get().(proto.Message)
+}
+
+// Put resets m and returns it to p. m must not be used afterwards.
+func (p *MessagePool) Put(m proto.Message) {
+	proto.Reset(m)
+	p.pool.Put(m)
+}
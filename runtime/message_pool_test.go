@@ -0,0 +1,24 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	pb "github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMessagePool(t *testing.T) {
+	pool := runtime.NewMessagePool(func() proto.Message { return new(pb.SimpleMessage) })
+	for i := 0; i < 10; i++ {
+		msg, ok := pool.Get().(*pb.SimpleMessage)
+		if !ok {
+			t.Fatalf("pool.Get() = %T; want *examplepb.SimpleMessage", msg)
+		}
+		if !proto.Equal(msg, new(pb.SimpleMessage)) {
+			t.Fatalf("pool.Get() = %v; want an empty message", msg)
+		}
+		msg.Id = "foo"
+		pool.Put(msg)
+	}
+}
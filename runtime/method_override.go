@@ -0,0 +1,149 @@
+package runtime
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MethodOverridePolicy restricts the X-HTTP-Method-Override header of the
+// form-encoded POST requests, which changes the method they are routed with,
+// see WithMethodOverridePolicy.
+type MethodOverridePolicy struct {
+	// Disabled ignores the header, so that requests are routed with POST.
+	Disabled bool
+	// Methods are the methods requests may be overridden to, e.g. DELETE and
+	// PATCH. All methods are allowed if it is empty.
+	Methods []string
+	// RequireSameOrigin requires overridden requests to come from the origin
+	// of the gateway: the host of their Origin header, or of their Referer
+	// header if they have none, must be their Host.
+	RequireSameOrigin bool
+	// CSRF, if not nil, requires overridden requests to pass the check of
+	// CSRF.Mode, whichever the route they are dispatched to. CSRF.Tags is
+	// ignored.
+	CSRF *CSRFConfig
+}
+
+// WithMethodOverridePolicy returns a ServeMuxOption restricting the
+// X-HTTP-Method-Override header according to "policy". Overridden requests
+// violating it are rejected with codes.PermissionDenied through the error
+// handler. By default, the header of every form-encoded POST request is
+// honored, unless WithDisablePathLengthFallback is given.
+func WithMethodOverridePolicy(policy MethodOverridePolicy) ServeMuxOption {
+	return func(mux *ServeMux) {
+		methods := make([]string, 0, len(policy.Methods))
+		for _, m := range policy.Methods {
+			methods = append(methods, strings.ToUpper(m))
+		}
+		policy.Methods = methods
+		mux.methodOverridePolicy = policy
+		if policy.CSRF != nil {
+			mux.methodOverrideCSRF = newCSRFProtection(*policy.CSRF)
+		}
+	}
+}
+
+// WithRouteDisableMethodOverride returns a RouteOption rejecting the requests
+// routed to the route with the method of their X-HTTP-Method-Override header
+// with http.StatusMethodNotAllowed through the routing error handler.
+func WithRouteDisableMethodOverride() RouteOption {
+	return func(o *routeOptions) {
+		o.disableMethodOverride = true
+	}
+}
+
+type methodOverrideKey struct{}
+
+// isMethodOverridden reports whether the request of ctx is routed with the
+// method of its X-HTTP-Method-Override header.
+func isMethodOverridden(ctx context.Context) bool {
+	overridden, _ := ctx.Value(methodOverrideKey{}).(bool)
+	return overridden
+}
+
+// applyMethodOverride routes "r" with the method of its
+// X-HTTP-Method-Override header if it is a path length fallback request and
+// the method override policy of s allows it. It replies with an error and
+// returns false if the override is rejected.
+func (s *ServeMux) applyMethodOverride(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	override := r.Header.Get("X-HTTP-Method-Override")
+	if override == "" || !s.isPathLengthFallback(r) || s.methodOverridePolicy.Disabled {
+		return r, true
+	}
+	method := strings.ToUpper(override)
+	if err := s.checkMethodOverride(r, method); err != nil {
+		_, outboundMarshaler := MarshalerForRequest(s, r)
+		s.errorHandler(r.Context(), s, outboundMarshaler, w, r, err)
+		return nil, false
+	}
+	r.Method = method
+	if err := r.ParseForm(); err != nil {
+		_, outboundMarshaler := MarshalerForRequest(s, r)
+		sterr := status.Error(codes.InvalidArgument, err.Error())
+		s.errorHandler(r.Context(), s, outboundMarshaler, w, r, sterr)
+		return nil, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), methodOverrideKey{}, true)), true
+}
+
+// RoutingMethod returns the method "r" is routed with: the method of its
+// X-HTTP-Method-Override header if it is a form-encoded POST request and the
+// header is not disabled, or else its method. The mux rejects the requests
+// whose override violates the MethodOverridePolicy instead of routing them.
+func (s *ServeMux) RoutingMethod(r *http.Request) string {
+	override := r.Header.Get("X-HTTP-Method-Override")
+	if override == "" || !s.isPathLengthFallback(r) || s.methodOverridePolicy.Disabled {
+		return r.Method
+	}
+	return strings.ToUpper(override)
+}
+
+// checkMethodOverride checks the override of the method of "r" with "method"
+// against the method override policy of s.
+func (s *ServeMux) checkMethodOverride(r *http.Request, method string) error {
+	policy := s.methodOverridePolicy
+	if len(policy.Methods) > 0 {
+		allowed := false
+		for _, m := range policy.Methods {
+			allowed = allowed || m == method
+		}
+		if !allowed {
+			return status.Errorf(codes.PermissionDenied, "method override to %s is not allowed", method)
+		}
+	}
+	if policy.RequireSameOrigin && !isSameOrigin(r) {
+		return status.Error(codes.PermissionDenied, "method override from another origin is not allowed")
+	}
+	if s.methodOverrideCSRF != nil {
+		var cookie string
+		if ck, err := r.Cookie(s.methodOverrideCSRF.config.CookieName); err == nil {
+			cookie = ck.Value
+		}
+		if err := s.methodOverrideCSRF.verify(r, cookie); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isSameOrigin reports whether the Origin header of r, or its Referer header
+// if it has none, has the host of r.
+func isSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,130 @@
+package runtime_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func newMethodOverrideTestMux(t *testing.T, policy runtime.MethodOverridePolicy) *runtime.ServeMuxDynamic {
+	mux := runtime.NewServeMuxDynamic(runtime.WithMethodOverridePolicy(policy))
+	for _, route := range []struct {
+		method string
+		opts   []runtime.RouteOption
+	}{
+		{method: "POST"},
+		{method: "DELETE"},
+		{method: "PATCH"},
+		{method: "PUT", opts: []runtime.RouteOption{runtime.WithRouteDisableMethodOverride()}},
+	} {
+		method := route.method
+		err := mux.HandlePath(method, "/v1/books/{id}", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+			w.Write([]byte(method))
+		}, route.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mux
+}
+
+func overrideRequest(method string, header map[string]string) *http.Request {
+	r := httptest.NewRequest("POST", "/v1/books/1", strings.NewReader("title=x"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-HTTP-Method-Override", method)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	return r
+}
+
+func TestMethodOverridePolicy(t *testing.T) {
+	for _, spec := range []struct {
+		name   string
+		policy runtime.MethodOverridePolicy
+		method string
+		header map[string]string
+		code   int
+		body   string
+	}{
+		{name: "default", method: "delete", code: http.StatusOK, body: "DELETE"},
+		{name: "disabled", policy: runtime.MethodOverridePolicy{Disabled: true}, method: "DELETE", code: http.StatusOK, body: "POST"},
+		{name: "allowed method", policy: runtime.MethodOverridePolicy{Methods: []string{"delete", "PATCH"}}, method: "PATCH", code: http.StatusOK, body: "PATCH"},
+		{name: "forbidden method", policy: runtime.MethodOverridePolicy{Methods: []string{"DELETE"}}, method: "PATCH", code: http.StatusForbidden},
+		// The default routing error handler replies 501 for 405.
+		{name: "route disabled", method: "PUT", code: http.StatusNotImplemented},
+		{
+			name:   "same origin",
+			policy: runtime.MethodOverridePolicy{RequireSameOrigin: true},
+			method: "DELETE",
+			header: map[string]string{"Origin": "http://example.com"},
+			code:   http.StatusOK,
+			body:   "DELETE",
+		},
+		{
+			name:   "same origin referer",
+			policy: runtime.MethodOverridePolicy{RequireSameOrigin: true},
+			method: "DELETE",
+			header: map[string]string{"Referer": "http://example.com/books"},
+			code:   http.StatusOK,
+			body:   "DELETE",
+		},
+		{
+			name:   "cross origin",
+			policy: runtime.MethodOverridePolicy{RequireSameOrigin: true},
+			method: "DELETE",
+			header: map[string]string{"Origin": "https://evil.example.org"},
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "no origin",
+			policy: runtime.MethodOverridePolicy{RequireSameOrigin: true},
+			method: "DELETE",
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "csrf header",
+			policy: runtime.MethodOverridePolicy{CSRF: &runtime.CSRFConfig{Mode: runtime.CSRFCustomHeader}},
+			method: "DELETE",
+			header: map[string]string{"X-CSRF-Token": "1"},
+			code:   http.StatusOK,
+			body:   "DELETE",
+		},
+		{
+			name:   "missing csrf header",
+			policy: runtime.MethodOverridePolicy{CSRF: &runtime.CSRFConfig{Mode: runtime.CSRFCustomHeader}},
+			method: "DELETE",
+			code:   http.StatusForbidden,
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			mux := newMethodOverrideTestMux(t, spec.policy)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, overrideRequest(spec.method, spec.header))
+			if w.Code != spec.code {
+				t.Errorf("w.Code = %d; want %d (%s)", w.Code, spec.code, w.Body)
+			}
+			if spec.body != "" && w.Body.String() != spec.body {
+				t.Errorf("w.Body = %q; want %q", w.Body, spec.body)
+			}
+		})
+	}
+}
+
+func TestRoutingMethod(t *testing.T) {
+	mux := runtime.NewServeMux()
+	if got := mux.RoutingMethod(overrideRequest("delete", nil)); got != "DELETE" {
+		t.Errorf("mux.RoutingMethod(<overridden form POST>) = %q; want %q", got, "DELETE")
+	}
+	r := overrideRequest("DELETE", map[string]string{"Content-Type": "application/json"})
+	if got := mux.RoutingMethod(r); got != "POST" {
+		t.Errorf("mux.RoutingMethod(<overridden JSON POST>) = %q; want %q", got, "POST")
+	}
+	disabled := runtime.NewServeMux(runtime.WithMethodOverridePolicy(runtime.MethodOverridePolicy{Disabled: true}))
+	if got := disabled.RoutingMethod(overrideRequest("DELETE", nil)); got != "POST" {
+		t.Errorf("mux.RoutingMethod(...) = %q with overrides disabled; want %q", got, "POST")
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/textproto"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/httprule"
 	"google.golang.org/grpc/codes"
@@ -26,11 +28,59 @@ type ServeMux struct {
 	marshalers                marshalerRegistry
 	incomingHeaderMatcher     HeaderMatcherFunc
 	outgoingHeaderMatcher     HeaderMatcherFunc
+	outgoingTrailerMatcher    HeaderMatcherFunc
 	metadataAnnotators        []func(context.Context, *http.Request) metadata.MD
 	errorHandler              ErrorHandlerFunc
 	streamErrorHandler        StreamErrorHandlerFunc
 	routingErrorHandler       RoutingErrorHandlerFunc
 	disablePathLengthFallback bool
+	strictAcceptNegotiation   bool
+	queryRenderingOptions     bool
+	partialResponses          bool
+	responseEnvelope          *ResponseEnvelope
+	responseRedaction         *responseRedactor
+	httpBodyChunkSize         int
+	pooledPathParams          bool
+	routeCache                *routeCache
+	authenticators            []authenticator
+	gatewayMetadata           []string
+	authorizer                Authorizer
+	clientIP                  *ClientIPConfig
+	logRedaction              *Redactor
+	securityEventHandlers     []SecurityEventHandler
+	requestValidator          RequestValidator
+	deprecations              map[string]Deprecation
+	deprecatedMethodHook      DeprecatedMethodHook
+	mergedOpenAPIPath         string
+	recorder                  *Recorder
+	faultRules                []FaultRule
+	healthConfig              *HealthConfig
+	health                    healthState
+	serverTiming              bool
+	tenantConfig              *TenantConfig
+	idempotencyConfig         *IdempotencyTokenConfig
+	methodOverridePolicy      MethodOverridePolicy
+	methodOverrideCSRF        *csrfProtection
+	expectContinue            bool
+	altSvc                    string
+	jsonRPCPath               string
+	graphQLPath               string
+	twirpPrefix               string
+	webSocketProxyCompat      bool
+	webSocketOrigins          []string
+	reflectionPath            string
+	bodyTransformers          []BodyTransformer
+	pagination                *PaginationConfig
+	requestDefaults           RequestDefaults
+	upstreamRetryAfter        time.Duration
+	admission                 []*admissionController
+	adaptiveConcurrency       *adaptiveConcurrency
+	requestMetrics            RequestMetricsRecorder
+	traceCorrelation          *TraceCorrelation
+	slowRequests              *SlowRequestWatchdog
+	slo                       *sloState
+	requestInfo               bool
+	routeAudit                RouteAuditSink
 }
 
 // ServeMuxOption is an option that can be given to a ServeMux on construction.
@@ -94,6 +144,17 @@ func WithOutgoingHeaderMatcher(fn HeaderMatcherFunc) ServeMuxOption {
 	}
 }
 
+// WithOutgoingTrailerMatcher returns a ServeMuxOption representing a headerMatcher for outgoing response trailers from gateway.
+//
+// This matcher will be called with each key in response trailer metadata. If matcher returns true, that key will be
+// passed to http response returned from gateway as a trailer. To transform the key before passing to response,
+// matcher should return modified key.
+func WithOutgoingTrailerMatcher(fn HeaderMatcherFunc) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.outgoingTrailerMatcher = fn
+	}
+}
+
 // WithMetadata returns a ServeMuxOption for passing metadata to a gRPC context.
 //
 // This can be used by services that need to read from http.Request and modify gRPC context. A common use case
@@ -130,7 +191,7 @@ func WithStreamErrorHandler(fn StreamErrorHandlerFunc) ServeMuxOption {
 // WithRoutingErrorHandler returns a ServeMuxOption for configuring a custom error handler to  handle http routing errors.
 //
 // Method called for errors which can happen before gRPC route selected or executed.
-// The following error codes: StatusMethodNotAllowed StatusNotFound StatusBadRequest
+// The following error codes: StatusMethodNotAllowed StatusNotFound StatusBadRequest StatusNotAcceptable
 func WithRoutingErrorHandler(fn RoutingErrorHandlerFunc) ServeMuxOption {
 	return func(serveMux *ServeMux) {
 		serveMux.routingErrorHandler = fn
@@ -144,6 +205,57 @@ func WithDisablePathLengthFallback() ServeMuxOption {
 	}
 }
 
+// WithStrictAcceptNegotiation returns a ServeMuxOption which rejects requests
+// whose Accept header matches none of the registered marshalers with
+// http.StatusNotAcceptable, instead of replying with the default marshaler.
+func WithStrictAcceptNegotiation() ServeMuxOption {
+	return func(serveMux *ServeMux) {
+		serveMux.strictAcceptNegotiation = true
+	}
+}
+
+// WithPooledPathParams returns a ServeMuxOption which reuses the maps of path
+// parameters passed to handlers across requests instead of allocating one per
+// request. Handlers must not retain the map, nor modify it, after they return.
+func WithPooledPathParams() ServeMuxOption {
+	return func(serveMux *ServeMux) {
+		serveMux.pooledPathParams = true
+	}
+}
+
+var pathParamsPool = sync.Pool{
+	New: func() interface{} {
+		return make(map[string]string)
+	},
+}
+
+// matchPath matches path and verb against pat. With WithPooledPathParams, the
+// path parameters are stored in a pooled map which must be released with
+// releasePathParams.
+func (s *ServeMux) matchPath(pat Pattern, path, verb string) (map[string]string, error) {
+	if !s.pooledPathParams {
+		return pat.MatchPath(path, verb)
+	}
+	pathParams := pathParamsPool.Get().(map[string]string)
+	if err := pat.MatchPathTo(path, verb, pathParams); err != nil {
+		pathParamsPool.Put(pathParams)
+		return nil, err
+	}
+	return pathParams, nil
+}
+
+// releasePathParams returns the path parameters returned by matchPath to the
+// pool once they are no longer used.
+func (s *ServeMux) releasePathParams(pathParams map[string]string) {
+	if !s.pooledPathParams {
+		return
+	}
+	for k := range pathParams {
+		delete(pathParams, k)
+	}
+	pathParamsPool.Put(pathParams)
+}
+
 // NewServeMux returns a new ServeMux whose internal mapping is empty.
 func NewServeMux(opts ...ServeMuxOption) *ServeMux {
 	serveMux := &ServeMux{
@@ -153,6 +265,7 @@ func NewServeMux(opts ...ServeMuxOption) *ServeMux {
 		errorHandler:           DefaultHTTPErrorHandler,
 		streamErrorHandler:     DefaultStreamErrorHandler,
 		routingErrorHandler:    DefaultRoutingErrorHandler,
+		deprecatedMethodHook:   DefaultDeprecatedMethodHook,
 	}
 
 	for _, opt := range opts {
@@ -169,12 +282,19 @@ func NewServeMux(opts ...ServeMuxOption) *ServeMux {
 		}
 	}
 
+	if serveMux.outgoingTrailerMatcher == nil {
+		serveMux.outgoingTrailerMatcher = func(key string) (string, bool) {
+			return fmt.Sprintf("%s%s", MetadataTrailerPrefix, key), true
+		}
+	}
+
 	return serveMux
 }
 
 // Handle associates "h" to the pair of HTTP method and path pattern.
 func (s *ServeMux) Handle(meth string, pat Pattern, h HandlerFunc) {
-	s.handlers[meth] = append([]handler{{pat: pat, h: h}}, s.handlers[meth]...)
+	s.handlers[meth] = insertHandler(s.handlers[meth], handler{pat: pat, h: h})
+	s.routeCache.reset()
 }
 
 // HandlePath allows users to configure custom path handlers.
@@ -195,6 +315,27 @@ func (s *ServeMux) HandlePath(meth string, pathPattern string, h HandlerFunc) er
 
 // ServeHTTP dispatches the request to the first handler whose pattern matches to r.Method and r.Path.
 func (s *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.advertiseAltSvc(w, r)
+	if s.serveHealth(w, r) {
+		return
+	}
+	if s.recorder != nil {
+		if rw := s.recorder.startRecording(w, r); rw != nil {
+			defer s.recorder.finishRecording(s, rw)
+			w = rw
+		}
+	}
+	r = s.startRequestInfo(r)
+	r = s.correlateTrace(r)
+	w, finishMetrics := s.startRequestMetrics(w, r)
+	defer finishMetrics()
+	w, r = s.startServerTiming(w, r)
+	r, ok := s.resolveTenant(w, r)
+	if !ok {
+		return
+	}
+	r = s.adaptWebSocketRequest(r)
+
 	ctx := r.Context()
 
 	path := r.URL.Path
@@ -204,21 +345,25 @@ func (s *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	components := strings.Split(path[1:], "/")
+	// matchPath is the path without its leading slash, and without the verb
+	// once it is found below.
+	matchPath := path[1:]
 
-	if override := r.Header.Get("X-HTTP-Method-Override"); override != "" && s.isPathLengthFallback(r) {
-		r.Method = strings.ToUpper(override)
-		if err := r.ParseForm(); err != nil {
-			_, outboundMarshaler := MarshalerForRequest(s, r)
-			sterr := status.Error(codes.InvalidArgument, err.Error())
-			s.errorHandler(ctx, s, outboundMarshaler, w, r, sterr)
-			return
-		}
+	if r, ok = s.applyMethodOverride(w, r); !ok {
+		return
 	}
 
 	// Verb out here is to memoize for the fallback case below
 	var verb string
 
+	route, generation := s.routeCache.get(r.Method, path)
+	if route != nil {
+		pathParams := s.cachedPathParams(route)
+		defer s.releasePathParams(pathParams)
+		s.dispatch(w, r, route.h, pathParams)
+		return
+	}
+
 	for _, h := range s.handlers[r.Method] {
 		// If the pattern has a verb, explicitly look for a suffix in the last
 		// component that matches a colon plus the verb. This allows us to
@@ -228,27 +373,23 @@ func (s *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// parser because we know what verb we're looking for, however, there
 		// are still some cases that the parser itself cannot disambiguate. See
 		// the comment there if interested.
-		patVerb := h.pat.Verb()
-		l := len(components)
-		lastComponent := components[l-1]
-		var idx int = -1
-		if patVerb != "" && strings.HasSuffix(lastComponent, ":"+patVerb) {
-			idx = len(lastComponent) - len(patVerb) - 1
-		}
+		lastComponent, idx := verbIndex(matchPath, h.pat.Verb())
 		if idx == 0 {
 			_, outboundMarshaler := MarshalerForRequest(s, r)
 			s.routingErrorHandler(ctx, s, outboundMarshaler, w, r, http.StatusNotFound)
 			return
 		}
 		if idx > 0 {
-			components[l-1], verb = lastComponent[:idx], lastComponent[idx+1:]
+			matchPath, verb = matchPath[:len(matchPath)-len(lastComponent)+idx], lastComponent[idx+1:]
 		}
 
-		pathParams, err := h.pat.Match(components, verb)
+		pathParams, err := s.matchPath(h.pat, matchPath, verb)
 		if err != nil {
 			continue
 		}
-		h.h(w, r, pathParams)
+		defer s.releasePathParams(pathParams)
+		s.routeCache.put(generation, r.Method, path, h, pathParams)
+		s.dispatch(w, r, h, pathParams)
 		return
 	}
 
@@ -259,10 +400,11 @@ func (s *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		for _, h := range handlers {
-			pathParams, err := h.pat.Match(components, verb)
+			pathParams, err := s.matchPath(h.pat, matchPath, verb)
 			if err != nil {
 				continue
 			}
+			defer s.releasePathParams(pathParams)
 			// X-HTTP-Method-Override is optional. Always allow fallback to POST.
 			if s.isPathLengthFallback(r) {
 				if err := r.ParseForm(); err != nil {
@@ -271,7 +413,7 @@ func (s *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 					s.errorHandler(ctx, s, outboundMarshaler, w, r, sterr)
 					return
 				}
-				h.h(w, r, pathParams)
+				s.dispatch(w, r, h, pathParams)
 				return
 			}
 			_, outboundMarshaler := MarshalerForRequest(s, r)
@@ -289,11 +431,116 @@ func (s *ServeMux) GetForwardResponseOptions() []func(context.Context, http.Resp
 	return s.forwardResponseOptions
 }
 
+// dispatch serves r with the handler h of the route it matched, once it
+// passed content negotiation, authentication and authorization.
+func (s *ServeMux) dispatch(w http.ResponseWriter, r *http.Request, h handler, pathParams map[string]string) {
+	timing := serverTimingFromContext(r.Context())
+	timing.markMatched()
+	h = s.warmUp(h)
+	if s.requestInfo || s.requestMetrics != nil {
+		markRouted(r.Context(), h.routeInfo(r.Method))
+	}
+	if s.slowRequests != nil {
+		defer s.watchSlowRequest(r, h)()
+	}
+	if s.slo != nil && h.opts != nil && h.opts.slo != nil {
+		var recordSLO func()
+		w, recordSLO = s.trackSLO(w, r, h)
+		defer recordSLO()
+	}
+	if h.opts != nil && h.opts.disableMethodOverride && isMethodOverridden(r.Context()) {
+		_, outboundMarshaler := MarshalerForRequest(s, r)
+		s.routingErrorHandler(r.Context(), s, outboundMarshaler, w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	r, ok := s.negotiateRequest(w, h.requestFor(r))
+	if !ok {
+		return
+	}
+	if r, ok = s.authenticate(w, r, h); !ok {
+		return
+	}
+	if !s.authorize(w, r, h) {
+		return
+	}
+	if !s.checkUpstreams(w, r, h) {
+		return
+	}
+	if h.opts != nil && h.opts.timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), h.opts.timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+	release, ok := s.admit(w, r, h)
+	if !ok {
+		return
+	}
+	defer release()
+	done, ok := s.acquireConcurrency(w, r, h)
+	if !ok {
+		return
+	}
+	defer done()
+	if h.opts != nil {
+		WriteEarlyHints(w, h.opts.earlyHints...)
+	}
+	r = s.attachIdempotencyToken(w, r)
+	r = pinBackends(w, r, h)
+	if w, ok = s.injectFaults(w, r, h); !ok {
+		return
+	}
+	s.sendContinue(w, r)
+	if r, ok = s.transformBody(w, r, h); !ok {
+		return
+	}
+	timing.markHandlerStart()
+	s.handleExperiment(w, r, h, pathParams)
+}
+
+// negotiateRequest prepares r for its handler: it stores the media type
+// version requested by the client in the context of r. If strict Accept
+// negotiation is enabled and no registered marshaler is acceptable for r, it
+// replies with http.StatusNotAcceptable and returns false.
+func (s *ServeMux) negotiateRequest(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	marshalers := s.marshalersFor(r.Context())
+	_, version, ok := marshalers.negotiate(r.Header[acceptHeader])
+	if o := routeOptionsFromContext(r.Context()); o != nil && o.marshaler != nil {
+		// The marshaler pinned to the route is used whatever the client accepts.
+		ok = true
+	}
+	if !ok && s.strictAcceptNegotiation {
+		_, outboundMarshaler := MarshalerForRequest(s, r)
+		s.routingErrorHandler(r.Context(), s, outboundMarshaler, w, r, http.StatusNotAcceptable)
+		return nil, false
+	}
+	if version == "" {
+		version = marshalers.contentTypeVersion(r.Header[contentTypeHeader])
+	}
+	if version != "" {
+		r = r.WithContext(withMediaTypeVersion(r.Context(), version))
+	}
+	return r, true
+}
+
+// verbIndex returns the last component of matchPath, a path without its
+// leading slash, and the index of the colon of the verb "patVerb" of a
+// pattern in it, or -1 if it does not end with the verb.
+func verbIndex(matchPath, patVerb string) (lastComponent string, idx int) {
+	lastComponent = matchPath[strings.LastIndexByte(matchPath, '/')+1:]
+	if patVerb != "" && strings.HasSuffix(lastComponent, ":"+patVerb) {
+		return lastComponent, len(lastComponent) - len(patVerb) - 1
+	}
+	return lastComponent, -1
+}
+
 func (s *ServeMux) isPathLengthFallback(r *http.Request) bool {
 	return !s.disablePathLengthFallback && r.Method == "POST" && r.Header.Get("Content-Type") == "application/x-www-form-urlencoded"
 }
 
 type handler struct {
-	pat Pattern
-	h   HandlerFunc
+	pat  Pattern
+	h    HandlerFunc
+	opts *routeOptions
+	// id identifies the registrations of ServeMuxDynamic.HandleWithDeregister.
+	id uint64
 }
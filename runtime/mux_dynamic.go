@@ -1,10 +1,12 @@
 package runtime
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 	"sync"
 
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/httprule"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -12,24 +14,112 @@ import (
 type ServeMuxDynamic struct {
 	*ServeMux
 
-	mu sync.RWMutex
+	mu     sync.RWMutex
+	lastID uint64
+
+	openAPI openAPIDocuments
+	jsonRPC jsonRPCMethods
+	graphQL graphQLServices
+	twirp   twirpMethods
+
+	reflection reflectionSources
 }
 
 // Handle associates "h" to the pair of HTTP method and path pattern.
-func (s *ServeMuxDynamic) Handle(meth string, pat Pattern, h HandlerFunc) {
+// The given RouteOptions only apply to requests dispatched to "h".
+func (s *ServeMuxDynamic) Handle(meth string, pat Pattern, h HandlerFunc, opts ...RouteOption) {
+	s.handle(routeChangeBy{}, meth, pat, h, opts)
+}
+
+func (s *ServeMuxDynamic) handle(by routeChangeBy, meth string, pat Pattern, h HandlerFunc, opts []RouteOption) {
+	s.mu.Lock()
+	o := newRouteOptions(opts)
+	o.takeOver(s.handlerFor(meth, pat))
+	s.handlers[meth] = insertHandler(s.handlers[meth], handler{pat: pat, h: h, opts: o})
+	s.routeCache.reset()
+	s.mu.Unlock()
+
+	s.auditRouteChange(by, RouteAuditHandle, meth, pat.String())
+}
+
+// HandlePath is the same as ServeMux.HandlePath, but registers the handler
+// with Handle, so that it is safe to call while serving.
+func (s *ServeMuxDynamic) HandlePath(meth string, pathPattern string, h HandlerFunc, opts ...RouteOption) error {
+	return s.handlePath(routeChangeBy{}, meth, pathPattern, h, opts)
+}
+
+func (s *ServeMuxDynamic) handlePath(by routeChangeBy, meth string, pathPattern string, h HandlerFunc, opts []RouteOption) error {
+	compiler, err := httprule.Parse(pathPattern)
+	if err != nil {
+		return fmt.Errorf("parsing path pattern: %w", err)
+	}
+	tp := compiler.Compile()
+	pattern, err := NewPattern(tp.Version, tp.OpCodes, tp.Pool, tp.Verb)
+	if err != nil {
+		return fmt.Errorf("creating new pattern: %w", err)
+	}
+	s.handle(by, meth, pattern, h, opts)
+	return nil
+}
+
+// HandleWithDeregister is the same as Handle, but returns a function which
+// deregisters exactly this registration, leaving other handlers registered
+// for the same method and pattern in place. Calling it more than once is a
+// no-op.
+func (s *ServeMuxDynamic) HandleWithDeregister(meth string, pat Pattern, h HandlerFunc, opts ...RouteOption) (deregister func()) {
+	return s.handleWithDeregister(routeChangeBy{}, meth, pat, h, opts)
+}
+
+func (s *ServeMuxDynamic) handleWithDeregister(by routeChangeBy, meth string, pat Pattern, h HandlerFunc, opts []RouteOption) (deregister func()) {
+	s.mu.Lock()
+	s.lastID++
+	id := s.lastID
+	o := newRouteOptions(opts)
+	o.takeOver(s.handlerFor(meth, pat))
+	s.handlers[meth] = insertHandler(s.handlers[meth], handler{pat: pat, h: h, opts: o, id: id})
+	s.routeCache.reset()
+	s.mu.Unlock()
+
+	s.auditRouteChange(by, RouteAuditHandle, meth, pat.String())
+	return func() {
+		if s.deregisterID(meth, id) {
+			s.auditRouteChange(by, RouteAuditDeregister, meth, pat.String())
+		}
+	}
+}
+
+// deregisterID deregisters the handler of "meth" with "id", and reports
+// whether it was registered.
+func (s *ServeMuxDynamic) deregisterID(meth string, id uint64) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.handlers[meth] = append([]handler{{pat: pat, h: h}}, s.handlers[meth]...)
+	handlers := s.handlers[meth]
+	for idx, h := range handlers {
+		if h.id == id {
+			s.handlers[meth] = append(handlers[:idx:idx], handlers[idx+1:]...)
+			s.routeCache.reset()
+			for _, other := range s.handlers[meth] {
+				if other.opts != nil && other.opts.warmUp != nil {
+					other.opts.warmUp.forget(id)
+				}
+			}
+			return true
+		}
+	}
+	return false
 }
 
 // Handler deregister with method and path pattern.
 func (s *ServeMuxDynamic) HandlerDeregister(meth string, pat Pattern) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.handlerDeregister(routeChangeBy{}, meth, pat)
+}
 
+func (s *ServeMuxDynamic) handlerDeregister(by routeChangeBy, meth string, pat Pattern) {
+	s.mu.Lock()
 	handlers := s.handlers[meth]
 	if len(handlers) == 0 {
+		s.mu.Unlock()
 		return
 	}
 
@@ -44,10 +134,37 @@ func (s *ServeMuxDynamic) HandlerDeregister(meth string, pat Pattern) {
 	newHandlers = append(newHandlers, handlers[offset:]...)
 
 	s.handlers[meth] = newHandlers
+	s.routeCache.reset()
+	s.mu.Unlock()
+
+	if len(newHandlers) < len(handlers) {
+		s.auditRouteChange(by, RouteAuditDeregister, meth, pat.String())
+	}
 }
 
 // ServeHTTP dispatches the request to the first handler whose pattern matches to r.Method and r.Path.
 func (s *ServeMuxDynamic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.advertiseAltSvc(w, r)
+	if s.serveHealth(w, r) {
+		return
+	}
+	if s.recorder != nil {
+		if rw := s.recorder.startRecording(w, r); rw != nil {
+			defer s.recorder.finishRecording(s.ServeMux, rw)
+			w = rw
+		}
+	}
+	r = s.startRequestInfo(r)
+	r = s.correlateTrace(r)
+	w, finishMetrics := s.startRequestMetrics(w, r)
+	defer finishMetrics()
+	w, r = s.startServerTiming(w, r)
+	r, ok := s.resolveTenant(w, r)
+	if !ok {
+		return
+	}
+	r = s.adaptWebSocketRequest(r)
+
 	ctx := r.Context()
 
 	path := r.URL.Path
@@ -57,23 +174,63 @@ func (s *ServeMuxDynamic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	components := strings.Split(path[1:], "/")
+	if s.mergedOpenAPIPath != "" && path == s.mergedOpenAPIPath && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		s.serveMergedOpenAPI(w, r)
+		return
+	}
 
-	if override := r.Header.Get("X-HTTP-Method-Override"); override != "" && s.isPathLengthFallback(r) {
-		r.Method = strings.ToUpper(override)
-		if err := r.ParseForm(); err != nil {
-			_, outboundMarshaler := MarshalerForRequest(s.ServeMux, r)
-			sterr := status.Error(codes.InvalidArgument, err.Error())
-			s.errorHandler(ctx, s.ServeMux, outboundMarshaler, w, r, sterr)
-			return
-		}
+	if s.jsonRPCPath != "" && path == s.jsonRPCPath {
+		s.serveJSONRPC(w, r)
+		return
+	}
+
+	if s.graphQLPath != "" && path == s.graphQLPath {
+		s.serveGraphQL(w, r)
+		return
+	}
+
+	if s.twirpPrefix != "" && strings.HasPrefix(path, s.twirpPrefix+"/") {
+		s.serveTwirp(w, r, path[len(s.twirpPrefix)+1:])
+		return
+	}
+
+	if endpoint, ok := s.reflectionEndpoint(path); ok {
+		s.serveReflection(w, r, endpoint)
+		return
+	}
+
+	// matchPath is the path without its leading slash, and without the verb
+	// once it is found below.
+	matchPath := path[1:]
+
+	if r, ok = s.applyMethodOverride(w, r); !ok {
+		return
 	}
 
 	// Verb out here is to memoize for the fallback case below
 	var verb string
 
+	// Routes are cached per tenant, since they may be restricted to some.
+	tenant, _ := TenantFromContext(ctx)
+	cacheKey := path
+	if tenant != "" {
+		cacheKey = tenant + "\x00" + path
+	}
+
 	s.mu.RLock()
+	route, generation := s.routeCache.get(r.Method, cacheKey)
+	if route != nil {
+		s.mu.RUnlock()
+		pathParams := s.cachedPathParams(route)
+		defer s.releasePathParams(pathParams)
+		s.dispatch(w, r, route.h, pathParams)
+		return
+	}
+
 	for _, h := range s.handlers[r.Method] {
+		if !h.servesTenant(tenant) {
+			continue
+		}
 		// If the pattern has a verb, explicitly look for a suffix in the last
 		// component that matches a colon plus the verb. This allows us to
 		// handle some cases that otherwise can't be correctly handled by the
@@ -82,13 +239,7 @@ func (s *ServeMuxDynamic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// parser because we know what verb we're looking for, however, there
 		// are still some cases that the parser itself cannot disambiguate. See
 		// the comment there if interested.
-		patVerb := h.pat.Verb()
-		l := len(components)
-		lastComponent := components[l-1]
-		var idx int = -1
-		if patVerb != "" && strings.HasSuffix(lastComponent, ":"+patVerb) {
-			idx = len(lastComponent) - len(patVerb) - 1
-		}
+		lastComponent, idx := verbIndex(matchPath, h.pat.Verb())
 		if idx == 0 {
 			s.mu.RUnlock()
 			_, outboundMarshaler := MarshalerForRequest(s.ServeMux, r)
@@ -96,15 +247,17 @@ func (s *ServeMuxDynamic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if idx > 0 {
-			components[l-1], verb = lastComponent[:idx], lastComponent[idx+1:]
+			matchPath, verb = matchPath[:len(matchPath)-len(lastComponent)+idx], lastComponent[idx+1:]
 		}
 
-		pathParams, err := h.pat.Match(components, verb)
+		pathParams, err := s.matchPath(h.pat, matchPath, verb)
 		if err != nil {
 			continue
 		}
+		defer s.releasePathParams(pathParams)
+		s.routeCache.put(generation, r.Method, cacheKey, h, pathParams)
 		s.mu.RUnlock()
-		h.h(w, r, pathParams)
+		s.dispatch(w, r, h, pathParams)
 		return
 	}
 
@@ -115,10 +268,14 @@ func (s *ServeMuxDynamic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		for _, h := range handlers {
-			pathParams, err := h.pat.Match(components, verb)
+			if !h.servesTenant(tenant) {
+				continue
+			}
+			pathParams, err := s.matchPath(h.pat, matchPath, verb)
 			if err != nil {
 				continue
 			}
+			defer s.releasePathParams(pathParams)
 			s.mu.RUnlock()
 
 			// X-HTTP-Method-Override is optional. Always allow fallback to POST.
@@ -129,7 +286,7 @@ func (s *ServeMuxDynamic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 					s.errorHandler(ctx, s.ServeMux, outboundMarshaler, w, r, sterr)
 					return
 				}
-				h.h(w, r, pathParams)
+				s.dispatch(w, r, h, pathParams)
 				return
 			}
 			_, outboundMarshaler := MarshalerForRequest(s.ServeMux, r)
@@ -0,0 +1,309 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// WithMergedOpenAPIPath returns a ServeMuxOption making a ServeMuxDynamic
+// serve the OpenAPI document merging the documents of its registered services
+// at "path", e.g. "/swagger.json", see ServeMuxDynamic.RegisterOpenAPI.
+// Requests for it are authenticated by the authenticators of the mux, e.g.
+// WithJWTAuth or WithIPFilter. It has no effect on a ServeMux.
+func WithMergedOpenAPIPath(path string) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.mergedOpenAPIPath = path
+	}
+}
+
+// openAPIDocuments holds the OpenAPI documents registered on a
+// ServeMuxDynamic, and caches their merge until they change.
+type openAPIDocuments struct {
+	mu     sync.Mutex
+	lastID uint64
+	docs   []openAPIDocument
+	merged []byte
+}
+
+type openAPIDocument struct {
+	id   uint64
+	name string
+	raw  []byte
+}
+
+// RegisterOpenAPI registers the OpenAPI document "doc", in JSON, of the
+// service "name", e.g. generated by protoc-gen-openapiv2, to be merged into
+// the document served at the path of WithMergedOpenAPIPath. It returns a
+// function deregistering the document, e.g. along with the handlers of the
+// service.
+func (s *ServeMuxDynamic) RegisterOpenAPI(name string, doc []byte) (deregister func(), err error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(doc, &obj); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document of %s: %v", name, err)
+	}
+
+	s.openAPI.mu.Lock()
+	defer s.openAPI.mu.Unlock()
+
+	s.openAPI.lastID++
+	id := s.openAPI.lastID
+	s.openAPI.docs = append(s.openAPI.docs, openAPIDocument{id: id, name: name, raw: doc})
+	s.openAPI.merged = nil
+
+	return func() {
+		s.openAPI.mu.Lock()
+		defer s.openAPI.mu.Unlock()
+
+		for idx, d := range s.openAPI.docs {
+			if d.id == id {
+				s.openAPI.docs = append(s.openAPI.docs[:idx:idx], s.openAPI.docs[idx+1:]...)
+				s.openAPI.merged = nil
+				return
+			}
+		}
+	}, nil
+}
+
+// RegisterOpenAPIFromDescriptor is the same as RegisterOpenAPI, with an
+// OpenAPI document generated from the google.api.http options of the methods
+// of "sd". The document describes the operations of the methods and their path
+// parameters, but not the schemas of their messages, which only
+// protoc-gen-openapiv2 generates.
+func (s *ServeMuxDynamic) RegisterOpenAPIFromDescriptor(sd protoreflect.ServiceDescriptor) (deregister func(), err error) {
+	doc, err := openAPIFromServiceDescriptor(sd)
+	if err != nil {
+		return nil, err
+	}
+	return s.RegisterOpenAPI(string(sd.FullName()), doc)
+}
+
+// MergedOpenAPI returns the OpenAPI document merging the documents of the
+// currently registered services, in the order of their names. The paths,
+// definitions and tags of the documents are merged, and other members are
+// taken from the first document which has them. Conflicting definitions of
+// the same path operation or definition are logged, and the first one is
+// kept.
+func (s *ServeMuxDynamic) MergedOpenAPI() ([]byte, error) {
+	s.openAPI.mu.Lock()
+	defer s.openAPI.mu.Unlock()
+
+	if s.openAPI.merged != nil {
+		return s.openAPI.merged, nil
+	}
+	docs := append([]openAPIDocument(nil), s.openAPI.docs...)
+	sort.SliceStable(docs, func(i, j int) bool { return docs[i].name < docs[j].name })
+
+	merged := map[string]interface{}{}
+	for _, d := range docs {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(d.raw, &obj); err != nil {
+			return nil, err
+		}
+		for key, value := range obj {
+			switch key {
+			case "paths", "components":
+				mergeOpenAPIObject(merged, key, value, 2, d.name)
+			case "definitions", "securityDefinitions", "parameters", "responses":
+				mergeOpenAPIObject(merged, key, value, 1, d.name)
+			case "tags":
+				merged[key] = mergeOpenAPITags(merged[key], value)
+			default:
+				if _, ok := merged[key]; !ok {
+					merged[key] = value
+				}
+			}
+		}
+	}
+	if len(merged) == 0 {
+		merged["swagger"] = "2.0"
+		merged["paths"] = map[string]interface{}{}
+	}
+	buf, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	s.openAPI.merged = buf
+	return buf, nil
+}
+
+// mergeOpenAPIObject merges the object "value" into the member "key" of
+// "dst", recursing "depth" levels into the members of both objects.
+func mergeOpenAPIObject(dst map[string]interface{}, key string, value interface{}, depth int, source string) {
+	existing, ok := dst[key]
+	if !ok {
+		dst[key] = value
+		return
+	}
+	dstObj, dstOK := existing.(map[string]interface{})
+	srcObj, srcOK := value.(map[string]interface{})
+	if depth == 0 || !dstOK || !srcOK {
+		if !reflect.DeepEqual(existing, value) {
+			grpclog.Warningf("Conflicting OpenAPI %q of %s ignored in the merged document", key, source)
+		}
+		return
+	}
+	for k, v := range srcObj {
+		mergeOpenAPIObject(dstObj, k, v, depth-1, source)
+	}
+}
+
+// mergeOpenAPITags appends the tags of "value" missing from "existing".
+func mergeOpenAPITags(existing, value interface{}) interface{} {
+	tags, _ := existing.([]interface{})
+	names := make(map[interface{}]bool)
+	for _, tag := range tags {
+		if obj, ok := tag.(map[string]interface{}); ok {
+			names[obj["name"]] = true
+		}
+	}
+	more, _ := value.([]interface{})
+	for _, tag := range more {
+		obj, ok := tag.(map[string]interface{})
+		if !ok || names[obj["name"]] {
+			continue
+		}
+		names[obj["name"]] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+func (s *ServeMuxDynamic) serveMergedOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authenticate(w, r, handler{}); !ok {
+		return
+	}
+	doc, err := s.MergedOpenAPI()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(doc); err != nil {
+		grpclog.Infof("Failed to write the merged OpenAPI document: %v", err)
+	}
+}
+
+// openAPIFromServiceDescriptor generates an OpenAPI v2 document describing
+// the routes of the google.api.http options of the methods of "sd".
+func openAPIFromServiceDescriptor(sd protoreflect.ServiceDescriptor) ([]byte, error) {
+	tag := string(sd.Name())
+	paths := map[string]interface{}{}
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if !ok || rule == nil {
+			continue
+		}
+		for idx, rule := range append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+			method, template := httpRuleRoute(rule)
+			switch method {
+			case "GET", "PUT", "POST", "DELETE", "PATCH":
+			default:
+				continue
+			}
+			if _, err := parsePattern(template); err != nil {
+				return nil, fmt.Errorf("invalid pattern of %s: %v", md.FullName(), err)
+			}
+			path, params := openAPIPath(template)
+			operationID := tag + "_" + string(md.Name())
+			if idx > 0 {
+				operationID += fmt.Sprint(idx + 1)
+			}
+			var parameters []interface{}
+			for _, param := range params {
+				parameters = append(parameters, map[string]interface{}{
+					"name":     param,
+					"in":       "path",
+					"required": true,
+					"type":     "string",
+				})
+			}
+			if rule.GetBody() != "" {
+				parameters = append(parameters, map[string]interface{}{
+					"name":     "body",
+					"in":       "body",
+					"required": true,
+					"schema":   map[string]interface{}{"type": "object"},
+				})
+			}
+			op := map[string]interface{}{
+				"operationId": operationID,
+				"tags":        []interface{}{tag},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "A successful response.",
+						"schema":      map[string]interface{}{"type": "object"},
+					},
+				},
+			}
+			if parameters != nil {
+				op["parameters"] = parameters
+			}
+			if opts, ok := md.Options().(*descriptorpb.MethodOptions); ok && opts.GetDeprecated() {
+				op["deprecated"] = true
+			}
+			item, _ := paths[path].(map[string]interface{})
+			if item == nil {
+				item = map[string]interface{}{}
+				paths[path] = item
+			}
+			item[strings.ToLower(method)] = op
+		}
+	}
+	return json.Marshal(map[string]interface{}{
+		"swagger": "2.0",
+		"info": map[string]interface{}{
+			"title":   sd.ParentFile().Path(),
+			"version": "version not set",
+		},
+		"tags":     []interface{}{map[string]interface{}{"name": tag}},
+		"consumes": []interface{}{"application/json"},
+		"produces": []interface{}{"application/json"},
+		"paths":    paths,
+	})
+}
+
+// openAPIPath returns the OpenAPI path of the path template "template", and
+// the names of its variables, e.g. "/v1/{name}" and "name" for
+// "/v1/{name=messages/*}".
+func openAPIPath(template string) (string, []string) {
+	var (
+		b      strings.Builder
+		params []string
+	)
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			b.WriteString(template)
+			return b.String(), params
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			b.WriteString(template)
+			return b.String(), params
+		}
+		name := template[start+1 : start+end]
+		if i := strings.IndexByte(name, '='); i >= 0 {
+			name = name[:i]
+		}
+		params = append(params, name)
+		b.WriteString(template[:start])
+		b.WriteString("{" + name + "}")
+		template = template[start+end+1:]
+	}
+}
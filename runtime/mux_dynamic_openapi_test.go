@@ -0,0 +1,119 @@
+package runtime_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func TestServeMuxDynamic_MergedOpenAPI(t *testing.T) {
+	file, err := protodesc.NewFile(newSecuredFile(t), protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile(...) failed with %v; want success", err)
+	}
+	mux := runtime.NewServeMuxDynamic(runtime.WithMergedOpenAPIPath("/swagger.json"))
+	if _, err := mux.RegisterOpenAPIFromDescriptor(file.Services().Get(0)); err != nil {
+		t.Fatalf("mux.RegisterOpenAPIFromDescriptor(...) failed with %v; want success", err)
+	}
+	deregister, err := mux.RegisterOpenAPI("example.shelf.OtherService", []byte(`{
+		"swagger": "2.0",
+		"info": {"title": "other.proto", "version": "1.0"},
+		"tags": [{"name": "OtherService"}, {"name": "BookService"}],
+		"paths": {
+			"/v1/other": {"get": {"operationId": "OtherService_GetOther"}},
+			"/v1/{name}": {"get": {"operationId": "OtherService_Conflict"}}
+		},
+		"definitions": {"otherOther": {"type": "object"}}
+	}`))
+	if err != nil {
+		t.Fatalf("mux.RegisterOpenAPI(...) failed with %v; want success", err)
+	}
+	if _, err := mux.RegisterOpenAPI("invalid", []byte("{")); err == nil {
+		t.Errorf("mux.RegisterOpenAPI(%q, %q) succeeded; want failure", "invalid", "{")
+	}
+
+	type document struct {
+		Info struct {
+			Title string `json:"title"`
+		} `json:"info"`
+		Tags []struct {
+			Name string `json:"name"`
+		} `json:"tags"`
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+		} `json:"paths"`
+		Definitions map[string]interface{} `json:"definitions"`
+	}
+	get := func() document {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/swagger.json", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("w.Code = %d; want %d", w.Code, http.StatusOK)
+		}
+		if got, want := w.Header().Get("Content-Type"), "application/json"; got != want {
+			t.Errorf("Content-Type = %q; want %q", got, want)
+		}
+		var doc document
+		if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+			t.Fatalf("json.Unmarshal(%s) failed with %v; want success", w.Body, err)
+		}
+		return doc
+	}
+
+	doc := get()
+	if got, want := doc.Info.Title, "secured.proto"; got != want {
+		t.Errorf("info.title = %q; want %q", got, want)
+	}
+	if got, want := len(doc.Tags), 2; got != want {
+		t.Errorf("len(tags) = %d; want %d", got, want)
+	}
+	for _, spec := range []struct {
+		path, method, operationID string
+	}{
+		{"/v1/{name}", "get", "BookService_GetBook"},
+		{"/v1/{name}", "delete", "BookService_DeleteBook"},
+		{"/v1/books", "get", "BookService_ListBooks"},
+		{"/v1/other", "get", "OtherService_GetOther"},
+	} {
+		if got := doc.Paths[spec.path][spec.method].OperationID; got != spec.operationID {
+			t.Errorf("paths[%q][%q].operationId = %q; want %q", spec.path, spec.method, got, spec.operationID)
+		}
+	}
+	if params := doc.Paths["/v1/{name}"]["delete"].Parameters; len(params) != 1 || params[0].Name != "name" || params[0].In != "path" {
+		t.Errorf("paths[%q][%q].parameters = %+v; want the path parameter name", "/v1/{name}", "delete", params)
+	}
+	if _, ok := doc.Definitions["otherOther"]; !ok {
+		t.Errorf("definitions = %v; want otherOther", doc.Definitions)
+	}
+
+	deregister()
+	doc = get()
+	if _, ok := doc.Paths["/v1/other"]; ok {
+		t.Errorf("paths = %v; want no %q after deregistration", doc.Paths, "/v1/other")
+	}
+	if _, ok := doc.Paths["/v1/books"]; !ok {
+		t.Errorf("paths = %v; want %q", doc.Paths, "/v1/books")
+	}
+}
+
+func TestServeMuxDynamic_MergedOpenAPIAuthentication(t *testing.T) {
+	mux := runtime.NewServeMuxDynamic(
+		runtime.WithMergedOpenAPIPath("/swagger.json"),
+		runtime.WithIPFilter(runtime.IPFilterConfig{Deny: mustParseCIDRs(t, "192.0.2.0/24")}),
+	)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/swagger.json", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("GET /swagger.json from a denied client = %d; want %d", w.Code, http.StatusForbidden)
+	}
+}
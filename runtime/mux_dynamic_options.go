@@ -0,0 +1,219 @@
+package runtime
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RouteOption is an option that can be given to a single registration on a
+// ServeMuxDynamic. Route options take precedence over the ServeMuxOptions the
+// mux was constructed with, but only for requests dispatched to that route.
+type RouteOption func(*routeOptions)
+
+// routeOptions holds the per-registration configuration of a handler.
+type routeOptions struct {
+	incomingHeaderMatcher  HeaderMatcherFunc
+	outgoingHeaderMatcher  HeaderMatcherFunc
+	outgoingTrailerMatcher HeaderMatcherFunc
+	metadataAnnotators     []func(context.Context, *http.Request) metadata.MD
+	tags                   []string
+	authenticators         []authenticator
+	gatewayMetadata        []string
+	requiredScopes         []string
+	earlyHints             []string
+	experiment             *Experiment
+	tenants                []string
+	disableMethodOverride  bool
+	marshaler              Marshaler
+	marshalers             *marshalerRegistry
+	bodyTransformers       []BodyTransformer
+	requestDefaults        map[string]string
+	backendGroups          []*BackendGroup
+	callOptions            []grpc.CallOption
+	upstreams              []string
+	warmUp                 *routeWarmUp
+	slo                    *sloTracker
+	owner                  string
+	timeout                time.Duration
+	priority               int
+	firstMatchWins         bool
+}
+
+// WithRouteIncomingHeaderMatcher returns a RouteOption overriding the mux-wide
+// incoming header matcher for this route.
+//
+// See WithIncomingHeaderMatcher for the semantics of the matcher.
+func WithRouteIncomingHeaderMatcher(fn HeaderMatcherFunc) RouteOption {
+	return func(o *routeOptions) {
+		o.incomingHeaderMatcher = fn
+	}
+}
+
+// WithRouteOutgoingHeaderMatcher returns a RouteOption overriding the mux-wide
+// outgoing header matcher for this route.
+//
+// See WithOutgoingHeaderMatcher for the semantics of the matcher.
+func WithRouteOutgoingHeaderMatcher(fn HeaderMatcherFunc) RouteOption {
+	return func(o *routeOptions) {
+		o.outgoingHeaderMatcher = fn
+	}
+}
+
+// WithRouteOutgoingTrailerMatcher returns a RouteOption overriding the mux-wide
+// outgoing trailer matcher for this route.
+//
+// See WithOutgoingTrailerMatcher for the semantics of the matcher.
+func WithRouteOutgoingTrailerMatcher(fn HeaderMatcherFunc) RouteOption {
+	return func(o *routeOptions) {
+		o.outgoingTrailerMatcher = fn
+	}
+}
+
+// WithRouteMetadata returns a RouteOption for passing metadata to a gRPC context
+// for this route only.
+//
+// Route annotators run after the mux-wide annotators registered with WithMetadata,
+// so they can extend or override the metadata those produce.
+func WithRouteMetadata(annotator func(context.Context, *http.Request) metadata.MD) RouteOption {
+	return func(o *routeOptions) {
+		o.metadataAnnotators = append(o.metadataAnnotators, annotator)
+	}
+}
+
+// WithRouteTags returns a RouteOption tagging this route. Tags select routes
+// in the configuration of mux-wide features, e.g. JWTConfig.SkipTags.
+func WithRouteTags(tags ...string) RouteOption {
+	return func(o *routeOptions) {
+		o.tags = append(o.tags, tags...)
+	}
+}
+
+// WithRouteTimeout returns a RouteOption limiting the time spent serving the
+// requests to this route, including their wait for admission, see
+// WithAdmissionControl, to "timeout": their context, and the one of their
+// upstream calls, is canceled once it is over.
+func WithRouteTimeout(timeout time.Duration) RouteOption {
+	return func(o *routeOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithRouteRequiredScopes returns a RouteOption requiring the OAuth 2.0 access
+// tokens of requests to this route to be granted all of scopes, see
+// WithTokenIntrospection.
+func WithRouteRequiredScopes(scopes ...string) RouteOption {
+	return func(o *routeOptions) {
+		o.requiredScopes = append(o.requiredScopes, scopes...)
+	}
+}
+
+// WithRouteMarshaler returns a RouteOption pinning marshaler to this route:
+// it decodes the requests and encodes the responses of the route whatever
+// their Accept and Content-Type headers, e.g. an HTTPBodyMarshaler for a
+// download route. Strict Accept negotiation does not apply to the route.
+func WithRouteMarshaler(marshaler Marshaler) RouteOption {
+	return func(o *routeOptions) {
+		o.marshaler = marshaler
+	}
+}
+
+// WithRouteMarshalerOption returns a RouteOption associating marshaler to a
+// MIME type for this route, as WithMarshalerOption does for the mux. The
+// route negotiates its marshalers with its own registry instead of the
+// mux-wide one, which starts with the default marshaler for "*".
+func WithRouteMarshalerOption(mime string, marshaler Marshaler, opts ...MarshalerRegistrationOption) RouteOption {
+	return func(o *routeOptions) {
+		if o.marshalers == nil {
+			marshalers := makeMarshalerMIMERegistry()
+			o.marshalers = &marshalers
+		}
+		if err := o.marshalers.add(mime, registeredMarshaler(marshaler, opts)); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func newRouteOptions(opts []RouteOption) *routeOptions {
+	if len(opts) == 0 {
+		return nil
+	}
+	o := &routeOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+type routeOptionsKey struct{}
+
+func withRouteOptions(ctx context.Context, o *routeOptions) context.Context {
+	return context.WithValue(ctx, routeOptionsKey{}, o)
+}
+
+// routeOptionsFromContext returns the options of the route the request was
+// dispatched to, or nil if the route was registered without options.
+func routeOptionsFromContext(ctx context.Context) *routeOptions {
+	o, _ := ctx.Value(routeOptionsKey{}).(*routeOptions)
+	return o
+}
+
+// routeHasTag reports whether the route the request was dispatched to is
+// tagged with one of tags.
+func routeHasTag(ctx context.Context, tags []string) bool {
+	o := routeOptionsFromContext(ctx)
+	if o == nil {
+		return false
+	}
+	for _, tag := range o.tags {
+		for _, t := range tags {
+			if tag == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// incomingHeaderMatcherFor returns the incoming header matcher in effect for ctx.
+func (s *ServeMux) incomingHeaderMatcherFor(ctx context.Context) HeaderMatcherFunc {
+	if o := routeOptionsFromContext(ctx); o != nil && o.incomingHeaderMatcher != nil {
+		return o.incomingHeaderMatcher
+	}
+	return s.incomingHeaderMatcher
+}
+
+// outgoingHeaderMatcherFor returns the outgoing header matcher in effect for ctx.
+func (s *ServeMux) outgoingHeaderMatcherFor(ctx context.Context) HeaderMatcherFunc {
+	if o := routeOptionsFromContext(ctx); o != nil && o.outgoingHeaderMatcher != nil {
+		return o.outgoingHeaderMatcher
+	}
+	return s.outgoingHeaderMatcher
+}
+
+// outgoingTrailerMatcherFor returns the outgoing trailer matcher in effect for ctx.
+func (s *ServeMux) outgoingTrailerMatcherFor(ctx context.Context) HeaderMatcherFunc {
+	if o := routeOptionsFromContext(ctx); o != nil && o.outgoingTrailerMatcher != nil {
+		return o.outgoingTrailerMatcher
+	}
+	return s.outgoingTrailerMatcher
+}
+
+// marshalersFor returns the marshaler registry in effect for ctx.
+func (s *ServeMux) marshalersFor(ctx context.Context) marshalerRegistry {
+	if o := routeOptionsFromContext(ctx); o != nil && o.marshalers != nil {
+		return *o.marshalers
+	}
+	return s.marshalers
+}
+
+// requestFor returns r annotated with the options of the route h, if any.
+func (h handler) requestFor(r *http.Request) *http.Request {
+	if h.opts == nil {
+		return r
+	}
+	return r.WithContext(withRouteOptions(r.Context(), h.opts))
+}
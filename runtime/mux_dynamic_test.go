@@ -3,10 +3,14 @@ package runtime
 import (
 	"context"
 	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 func TestServeMuxMutex_Deregister(t *testing.T) {
@@ -119,3 +123,233 @@ func TestServeMuxMutex_Deregister(t *testing.T) {
 		})
 	}
 }
+
+func TestServeMuxDynamic_RouteHeaderMatchers(t *testing.T) {
+	mux := NewServeMuxDynamic()
+
+	forwardAll := func(key string) (string, bool) { return key, true }
+	patInternal := MustPattern(NewPattern(1, []int{2, 0}, []string{"internal"}, ""))
+	patPublic := MustPattern(NewPattern(1, []int{2, 0}, []string{"public"}, ""))
+
+	var got metadata.MD
+	h := func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		ctx, err := AnnotateContext(r.Context(), mux.ServeMux, r, "/svc/Method")
+		if err != nil {
+			t.Fatalf("AnnotateContext(...) failed with %v; want success", err)
+		}
+		got, _ = metadata.FromOutgoingContext(ctx)
+		ctx = NewServerMetadataContext(ctx, ServerMetadata{HeaderMD: metadata.Pairs("x-upstream", "1")})
+		ForwardResponseMessage(ctx, mux.ServeMux, &JSONPb{}, w, r, &emptypb.Empty{})
+	}
+	mux.Handle("GET", patInternal, h,
+		WithRouteIncomingHeaderMatcher(forwardAll),
+		WithRouteOutgoingHeaderMatcher(forwardAll),
+	)
+	mux.Handle("GET", patPublic, h)
+
+	for _, spec := range []struct {
+		path        string
+		wantMD      bool
+		wantRespHdr string
+	}{
+		{path: "/internal", wantMD: true, wantRespHdr: "X-Upstream"},
+		{path: "/public", wantMD: false, wantRespHdr: "Grpc-Metadata-X-Upstream"},
+	} {
+		got = nil
+		r := httptest.NewRequest("GET", spec.path, nil)
+		r.Header.Set("X-Custom", "value")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+
+		if _, ok := got["x-custom"]; ok != spec.wantMD {
+			t.Errorf("%s: metadata x-custom present = %v; want %v", spec.path, ok, spec.wantMD)
+		}
+		if v := w.Header().Get(spec.wantRespHdr); v != "1" {
+			t.Errorf("%s: response header %s = %q; want %q", spec.path, spec.wantRespHdr, v, "1")
+		}
+	}
+}
+
+func TestServeMuxDynamic_RouteMetadata(t *testing.T) {
+	var calls []string
+	mux := NewServeMuxDynamic(WithMetadata(func(context.Context, *http.Request) metadata.MD {
+		calls = append(calls, "global")
+		return metadata.Pairs("x-global", "1")
+	}))
+
+	var got metadata.MD
+	h := func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		ctx, err := AnnotateContext(r.Context(), mux.ServeMux, r, "/svc/Method")
+		if err != nil {
+			t.Fatalf("AnnotateContext(...) failed with %v; want success", err)
+		}
+		got, _ = metadata.FromOutgoingContext(ctx)
+	}
+	mux.Handle("GET", MustPattern(NewPattern(1, []int{2, 0}, []string{"legacy"}, "")), h,
+		WithRouteMetadata(func(context.Context, *http.Request) metadata.MD {
+			calls = append(calls, "route")
+			return metadata.Pairs("x-legacy-tenant", "acme")
+		}),
+	)
+	mux.Handle("GET", MustPattern(NewPattern(1, []int{2, 0}, []string{"modern"}, "")), h)
+
+	for _, spec := range []struct {
+		path      string
+		wantCalls []string
+		wantMD    bool
+	}{
+		{path: "/legacy", wantCalls: []string{"global", "route"}, wantMD: true},
+		{path: "/modern", wantCalls: []string{"global"}, wantMD: false},
+	} {
+		calls, got = nil, nil
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", spec.path, nil))
+
+		if len(calls) != len(spec.wantCalls) {
+			t.Fatalf("%s: annotator calls = %v; want %v", spec.path, calls, spec.wantCalls)
+		}
+		for i := range calls {
+			if calls[i] != spec.wantCalls[i] {
+				t.Errorf("%s: annotator calls = %v; want %v", spec.path, calls, spec.wantCalls)
+			}
+		}
+		if got.Get("x-global") == nil {
+			t.Errorf("%s: metadata x-global missing", spec.path)
+		}
+		if v := got.Get("x-legacy-tenant"); (v != nil) != spec.wantMD {
+			t.Errorf("%s: metadata x-legacy-tenant = %v; want present = %v", spec.path, v, spec.wantMD)
+		}
+	}
+}
+
+func TestServeMuxDynamic_RouteMarshaler(t *testing.T) {
+	mux := NewServeMuxDynamic(
+		WithStrictAcceptNegotiation(),
+		WithMarshalerOption("application/json", &JSONPb{}),
+	)
+	h := func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, outbound := MarshalerForRequest(mux.ServeMux, r)
+		ForwardResponseMessage(r.Context(), mux.ServeMux, outbound, w, r, &emptypb.Empty{})
+	}
+	mux.Handle("GET", MustPattern(NewPattern(1, []int{2, 0}, []string{"pinned"}, "")), h,
+		WithRouteMarshaler(&ProtoMarshaller{}),
+	)
+	mux.Handle("GET", MustPattern(NewPattern(1, []int{2, 0}, []string{"registry"}, "")), h,
+		WithRouteMarshalerOption("application/x-protobuf", &ProtoMarshaller{}),
+	)
+	mux.Handle("GET", MustPattern(NewPattern(1, []int{2, 0}, []string{"plain"}, "")), h)
+
+	for _, spec := range []struct {
+		path            string
+		accept          string
+		wantCode        int
+		wantContentType string
+	}{
+		{path: "/pinned", accept: "application/json", wantCode: http.StatusOK, wantContentType: "application/octet-stream"},
+		{path: "/pinned", accept: "image/png", wantCode: http.StatusOK, wantContentType: "application/octet-stream"},
+		{path: "/registry", accept: "application/x-protobuf", wantCode: http.StatusOK, wantContentType: "application/octet-stream"},
+		{path: "/registry", wantCode: http.StatusOK, wantContentType: "application/json"},
+		{path: "/registry", accept: "application/json", wantCode: http.StatusOK, wantContentType: "application/json"},
+		{path: "/registry", accept: "image/png", wantCode: http.StatusNotAcceptable},
+		{path: "/plain", accept: "application/json", wantCode: http.StatusOK, wantContentType: "application/json"},
+		{path: "/plain", accept: "application/x-protobuf", wantCode: http.StatusNotAcceptable},
+	} {
+		r := httptest.NewRequest("GET", spec.path, nil)
+		if spec.accept != "" {
+			r.Header.Set("Accept", spec.accept)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+
+		if w.Code != spec.wantCode {
+			t.Errorf("%s with Accept %q: code = %d; want %d", spec.path, spec.accept, w.Code, spec.wantCode)
+			continue
+		}
+		if spec.wantContentType == "" {
+			continue
+		}
+		if got := w.Header().Get("Content-Type"); got != spec.wantContentType {
+			t.Errorf("%s with Accept %q: Content-Type = %q; want %q", spec.path, spec.accept, got, spec.wantContentType)
+		}
+	}
+}
+
+func TestServeMuxDynamic_RouteCache(t *testing.T) {
+	mux := NewServeMuxDynamic(WithRouteCache(1))
+	var served []string
+	handle := func(name string, pat Pattern) {
+		mux.Handle("GET", pat, func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+			served = append(served, name+" "+pathParams["id"])
+			// Handlers may modify their path parameters.
+			pathParams["id"] = "modified"
+		})
+	}
+	generic := MustPattern(NewPattern(1, []int{
+		int(utilities.OpLitPush), 0,
+		int(utilities.OpPush), 0,
+		int(utilities.OpConcatN), 1,
+		int(utilities.OpCapture), 1,
+	}, []string{"a", "id"}, ""))
+	specific := MustPattern(NewPattern(1, []int{
+		int(utilities.OpLitPush), 0,
+		int(utilities.OpLitPush), 1,
+	}, []string{"a", "b"}, ""))
+	serve := func(path string) {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	handle("generic", generic)
+	serve("/a/b")
+	serve("/a/b")
+	serve("/a/c")
+	serve("/a/b")
+	handle("specific", specific)
+	serve("/a/b")
+	serve("/a/b")
+	mux.HandlerDeregister("GET", specific)
+	serve("/a/b")
+
+	want := []string{
+		"generic b", "generic b", "generic c", "generic b",
+		"specific ", "specific ",
+		"generic b",
+	}
+	if !reflect.DeepEqual(served, want) {
+		t.Errorf("served = %q; want %q", served, want)
+	}
+	if got := mux.routeCache.lru.Len(); got != 1 {
+		t.Errorf("mux.routeCache.lru.Len() = %d; want 1", got)
+	}
+}
+
+func TestServeMuxDynamic_HandleWithDeregister(t *testing.T) {
+	mux := NewServeMuxDynamic(WithRouteCache(1))
+	var served []string
+	handle := func(name string) func() {
+		pat := MustPattern(NewPattern(1, []int{int(utilities.OpLitPush), 0}, []string{"a"}, ""))
+		return mux.HandleWithDeregister("GET", pat, func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+			served = append(served, name)
+		})
+	}
+	serve := func() int {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/a", nil))
+		return w.Code
+	}
+
+	deregisterFirst := handle("first")
+	deregisterSecond := handle("second")
+	serve()
+	deregisterSecond()
+	serve()
+	deregisterSecond()
+	serve()
+	deregisterFirst()
+	if code := serve(); code != http.StatusNotFound {
+		t.Errorf("serve() = %d after deregistering all handlers; want %d", code, http.StatusNotFound)
+	}
+
+	want := []string{"second", "first", "first"}
+	if !reflect.DeepEqual(served, want) {
+		t.Errorf("served = %q; want %q", served, want)
+	}
+}
@@ -0,0 +1,77 @@
+//go:build go1.18
+// +build go1.18
+
+package runtime_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// FuzzMethodOverride sends form POST requests with X-HTTP-Method-Override to
+// a ServeMux and a ServeMuxDynamic serving conformanceRoutes, and reports the
+// differences of their responses. Requests falling back to the handlers of
+// other methods may be served by any of them, so only their status codes are
+// compared.
+func FuzzMethodOverride(f *testing.F) {
+	f.Add("/v1/shelves/fiction", "GET", "filter=long")
+	f.Add("/v1/shelves/fiction", "delete", "")
+	f.Add("/v1/shelves/fiction/books/dune:read", "GET", "a=b&a=c")
+	f.Add("/v1/files/a/b/c.txt", "GET", "%zz")
+	f.Add("/v1/shelves/fiction:", "POST", "")
+	f.Add("/v2/shelves", "PATCH", "")
+
+	mux := runtime.NewServeMux()
+	dynamic := runtime.NewServeMuxDynamic()
+	for _, route := range conformanceRoutes {
+		if err := mux.HandlePath(route.method, route.pattern, conformanceHandler(route)); err != nil {
+			f.Fatalf("mux.HandlePath(%q, %q) failed with %v; want success", route.method, route.pattern, err)
+		}
+		if err := dynamic.HandlePath(route.method, route.pattern, conformanceHandler(route)); err != nil {
+			f.Fatalf("dynamic.HandlePath(%q, %q) failed with %v; want success", route.method, route.pattern, err)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, path, override, body string) {
+		if override == "" {
+			return
+		}
+		serve := func(h http.Handler) *httptest.ResponseRecorder {
+			r := &http.Request{
+				Method: "POST",
+				URL:    &url.URL{Path: path},
+				Header: http.Header{
+					"Content-Type":           {"application/x-www-form-urlencoded"},
+					"X-Http-Method-Override": {override},
+				},
+				Body: ioutil.NopCloser(strings.NewReader(body)),
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			return w
+		}
+		want := serve(mux)
+		got := serve(dynamic)
+		if got.Code != want.Code {
+			t.Errorf("ServeMuxDynamic replied %d %s to %s with override %q; ServeMux replied %d %s", got.Code, got.Body, path, override, want.Code, want.Body)
+			return
+		}
+		var reply struct {
+			Route  string `json:"route"`
+			Method string `json:"method"`
+		}
+		if json.Unmarshal(want.Body.Bytes(), &reply) == nil && !strings.HasPrefix(reply.Route, reply.Method+" ") {
+			return
+		}
+		if got.Body.String() != want.Body.String() {
+			t.Errorf("ServeMuxDynamic replied %d %s to %s with override %q; ServeMux replied %d %s", got.Code, got.Body, path, override, want.Code, want.Body)
+		}
+	})
+}
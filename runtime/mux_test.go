@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strconv"
 	"testing"
 
@@ -460,3 +461,34 @@ func TestServeMux_HandlePath(t *testing.T) {
 		})
 	}
 }
+
+func TestServeMux_PooledPathParams(t *testing.T) {
+	mux := runtime.NewServeMux(runtime.WithPooledPathParams())
+	var got []map[string]string
+	handler := func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		params := make(map[string]string, len(pathParams))
+		for k, v := range pathParams {
+			params[k] = v
+		}
+		got = append(got, params)
+	}
+	if err := mux.HandlePath("GET", "/shelves/{shelf}/books/{book}", handler); err != nil {
+		t.Fatalf("mux.HandlePath failed with %v; want success", err)
+	}
+	if err := mux.HandlePath("GET", "/authors/{author}", handler); err != nil {
+		t.Fatalf("mux.HandlePath failed with %v; want success", err)
+	}
+
+	for _, path := range []string{"/shelves/1/books/2", "/authors/3", "/authors/4", "/shelves/5/books/6"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	want := []map[string]string{
+		{"shelf": "1", "book": "2"},
+		{"author": "3"},
+		{"author": "4"},
+		{"shelf": "5", "book": "6"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("path parameters = %v; want %v", got, want)
+	}
+}
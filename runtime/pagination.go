@@ -0,0 +1,101 @@
+package runtime
+
+import (
+	"net/http"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// PaginationConfig configures the pagination headers of list responses, see
+// WithPaginationHeaders. Field names may be given either as proto names or as
+// lowerCamelCase JSON names.
+type PaginationConfig struct {
+	// NextPageTokenField is the field of the responses holding the token of
+	// the next page. It defaults to "next_page_token", as in AIP-158.
+	NextPageTokenField string
+	// PrevPageTokenField is the field of the responses holding the token of
+	// the previous page. It defaults to "prev_page_token".
+	PrevPageTokenField string
+	// TotalSizeField is the field of the responses holding the total number
+	// of items. It defaults to "total_size", as in AIP-158.
+	TotalSizeField string
+	// PageTokenParam is the query parameter of the requests holding the page
+	// token. It defaults to "page_token", as in AIP-158.
+	PageTokenParam string
+}
+
+// WithPaginationHeaders returns a ServeMuxOption which emits the pagination
+// affordances of REST clients for paginated list responses: RFC 8288 Link
+// headers with the "next" and "prev" relations, pointing at the request URL
+// with the page token of the response, and an X-Total-Count header holding
+// the total size of the response, when they are set.
+func WithPaginationHeaders(config PaginationConfig) ServeMuxOption {
+	if config.NextPageTokenField == "" {
+		config.NextPageTokenField = "next_page_token"
+	}
+	if config.PrevPageTokenField == "" {
+		config.PrevPageTokenField = "prev_page_token"
+	}
+	if config.TotalSizeField == "" {
+		config.TotalSizeField = "total_size"
+	}
+	if config.PageTokenParam == "" {
+		config.PageTokenParam = "page_token"
+	}
+	return func(serveMux *ServeMux) {
+		serveMux.pagination = &config
+	}
+}
+
+// writePaginationHeaders writes the pagination headers of resp, a response
+// to req, if pagination headers are enabled.
+func (s *ServeMux) writePaginationHeaders(w http.ResponseWriter, req *http.Request, resp proto.Message) {
+	if s.pagination == nil || req.URL == nil || resp == nil {
+		return
+	}
+	m := resp.ProtoReflect()
+	for _, link := range []struct {
+		field string
+		rel   string
+	}{
+		{field: s.pagination.NextPageTokenField, rel: "next"},
+		{field: s.pagination.PrevPageTokenField, rel: "prev"},
+	} {
+		fd := paginationField(m, link.field)
+		if fd == nil || fd.Kind() != protoreflect.StringKind || !m.Has(fd) {
+			continue
+		}
+		u := *req.URL
+		q := u.Query()
+		q.Set(s.pagination.PageTokenParam, m.Get(fd).String())
+		u.RawQuery = q.Encode()
+		w.Header().Add("Link", "<"+u.RequestURI()+`>; rel="`+link.rel+`"`)
+	}
+
+	fd := paginationField(m, s.pagination.TotalSizeField)
+	if fd == nil || !m.Has(fd) {
+		return
+	}
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		w.Header().Set("X-Total-Count", strconv.FormatInt(m.Get(fd).Int(), 10))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		w.Header().Set("X-Total-Count", strconv.FormatUint(m.Get(fd).Uint(), 10))
+	}
+}
+
+// paginationField returns the singular field of m named name, or nil.
+func paginationField(m protoreflect.Message, name string) protoreflect.FieldDescriptor {
+	fields := m.Descriptor().Fields()
+	fd := fields.ByName(protoreflect.Name(name))
+	if fd == nil {
+		fd = fields.ByJSONName(name)
+	}
+	if fd == nil || fd.Cardinality() == protoreflect.Repeated {
+		return nil
+	}
+	return fd
+}
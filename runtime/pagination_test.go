@@ -0,0 +1,117 @@
+package runtime_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func newListResponse(t *testing.T, fields map[string]interface{}) proto.Message {
+	t.Helper()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   typ.Enum(),
+		}
+	}
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("list.proto"),
+		Package: proto.String("example.list"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("ListBooksResponse"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("next_page_token", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("total_size", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+				field("before", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			},
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile(...) failed with %v; want success", err)
+	}
+	md := file.Messages().Get(0)
+	msg := dynamicpb.NewMessage(md)
+	for name, v := range fields {
+		msg.Set(md.Fields().ByName(protoreflect.Name(name)), protoreflect.ValueOf(v))
+	}
+	return msg
+}
+
+func TestPaginationHeaders(t *testing.T) {
+	for _, spec := range []struct {
+		name      string
+		config    runtime.PaginationConfig
+		url       string
+		fields    map[string]interface{}
+		wantLinks []string
+		wantTotal string
+	}{
+		{
+			name:      "next page",
+			url:       "/v1/books?page_size=10",
+			fields:    map[string]interface{}{"next_page_token": "abc", "total_size": int32(42)},
+			wantLinks: []string{`</v1/books?page_size=10&page_token=abc>; rel="next"`},
+			wantTotal: "42",
+		},
+		{
+			name:      "replaced page token",
+			url:       "/v1/books?page_token=abc",
+			fields:    map[string]interface{}{"next_page_token": "def"},
+			wantLinks: []string{`</v1/books?page_token=def>; rel="next"`},
+		},
+		{
+			name: "last page",
+			url:  "/v1/books?page_token=def",
+		},
+		{
+			name: "custom fields",
+			config: runtime.PaginationConfig{
+				PrevPageTokenField: "before",
+				PageTokenParam:     "cursor",
+			},
+			url:    "/v1/books?cursor=def",
+			fields: map[string]interface{}{"next_page_token": "ghi", "before": "abc"},
+			wantLinks: []string{
+				`</v1/books?cursor=ghi>; rel="next"`,
+				`</v1/books?cursor=abc>; rel="prev"`,
+			},
+		},
+		{
+			name:      "JSON names",
+			config:    runtime.PaginationConfig{TotalSizeField: "totalSize"},
+			url:       "/v1/books",
+			fields:    map[string]interface{}{"total_size": int32(7)},
+			wantTotal: "7",
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			mux := runtime.NewServeMux(runtime.WithPaginationHeaders(spec.config))
+			r := httptest.NewRequest("GET", spec.url, nil)
+			w := httptest.NewRecorder()
+			runtime.ForwardResponseMessage(context.Background(), mux, &runtime.JSONPb{}, w, r, newListResponse(t, spec.fields))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("code = %d; want %d", w.Code, http.StatusOK)
+			}
+			if diff := cmp.Diff(spec.wantLinks, w.Header()["Link"]); diff != "" {
+				t.Errorf("Link headers differ (-want +got):\n%s", diff)
+			}
+			if got := w.Header().Get("X-Total-Count"); got != spec.wantTotal {
+				t.Errorf("X-Total-Count = %q; want %q", got, spec.wantTotal)
+			}
+		})
+	}
+}
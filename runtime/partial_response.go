@@ -0,0 +1,274 @@
+package runtime
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// partialResponseParam is the query parameter selecting the fields of a partial response.
+const partialResponseParam = "fields"
+
+// WithPartialResponses returns a ServeMuxOption which enables partial
+// responses: a "fields" query parameter selects the fields of the response
+// which are sent to the client, e.g.
+//
+//	?fields=name,author(name,email),items/id
+//
+// Fields are separated by commas, a path of nested fields is separated by
+// slashes and parentheses select several fields of a nested message. A
+// selection on a repeated or map field applies to each of its elements. Field
+// names may be given either as lowerCamelCase JSON names or as proto names.
+//
+// The fields which are not selected are cleared from a copy of the response
+// before it is marshaled, so selected fields which are unset are omitted too,
+// even if the marshaler emits unpopulated fields.
+func WithPartialResponses() ServeMuxOption {
+	return func(serveMux *ServeMux) {
+		serveMux.partialResponses = true
+	}
+}
+
+// fieldSelection is a parsed "fields" query parameter. A nil selection for a
+// field selects the whole field.
+type fieldSelection map[string]fieldSelection
+
+// partialResponseSelection returns the field selection requested by req, or
+// nil if partial responses are disabled or the client did not request one.
+func (s *ServeMux) partialResponseSelection(req *http.Request) (fieldSelection, error) {
+	if !s.partialResponses || req.URL == nil {
+		return nil, nil
+	}
+	fields := req.URL.Query().Get(partialResponseParam)
+	if fields == "" {
+		return nil, nil
+	}
+	sel, err := parseFieldSelection(fields)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s parameter: %v", partialResponseParam, err)
+	}
+	return sel, nil
+}
+
+// parseFieldSelection parses a comma separated list of field paths.
+func parseFieldSelection(fields string) (fieldSelection, error) {
+	p := fieldSelectionParser{input: fields}
+	sel, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.input[p.pos], p.pos)
+	}
+	return sel, nil
+}
+
+type fieldSelectionParser struct {
+	input string
+	pos   int
+}
+
+func (p *fieldSelectionParser) parseList() (fieldSelection, error) {
+	sel := make(fieldSelection)
+	for {
+		if err := p.parseItem(sel); err != nil {
+			return nil, err
+		}
+		if p.pos == len(p.input) || p.input[p.pos] != ',' {
+			return sel, nil
+		}
+		p.pos++
+	}
+}
+
+// parseItem parses a path of field names optionally followed by a
+// parenthesized sub-selection, and merges it into sel.
+func (p *fieldSelectionParser) parseItem(sel fieldSelection) error {
+	var path []string
+	for {
+		name := p.parseName()
+		if name == "" {
+			if p.pos == len(p.input) {
+				return fmt.Errorf("missing field name at end of input")
+			}
+			return fmt.Errorf("missing field name at offset %d", p.pos)
+		}
+		path = append(path, name)
+		if p.pos == len(p.input) || p.input[p.pos] != '/' {
+			break
+		}
+		p.pos++
+	}
+
+	var sub fieldSelection
+	if p.pos < len(p.input) && p.input[p.pos] == '(' {
+		p.pos++
+		var err error
+		if sub, err = p.parseList(); err != nil {
+			return err
+		}
+		if p.pos == len(p.input) || p.input[p.pos] != ')' {
+			return fmt.Errorf("missing ')' at offset %d", p.pos)
+		}
+		p.pos++
+	}
+
+	for i, name := range path {
+		if i == len(path)-1 {
+			sel.merge(name, sub)
+			return nil
+		}
+		next, ok := sel[name]
+		if ok && next == nil {
+			// The whole field is already selected.
+			return nil
+		}
+		if next == nil {
+			next = make(fieldSelection)
+			sel[name] = next
+		}
+		sel = next
+	}
+	return nil
+}
+
+func (p *fieldSelectionParser) parseName() string {
+	start := p.pos
+	for p.pos < len(p.input) && !strings.ContainsRune(",/()", rune(p.input[p.pos])) {
+		p.pos++
+	}
+	return strings.TrimSpace(p.input[start:p.pos])
+}
+
+// merge adds the selection sub of name to sel.
+func (sel fieldSelection) merge(name string, sub fieldSelection) {
+	existing, ok := sel[name]
+	switch {
+	case !ok:
+		sel[name] = sub
+	case existing == nil:
+	case sub == nil:
+		sel[name] = nil
+	default:
+		for k, v := range sub {
+			existing.merge(k, v)
+		}
+	}
+}
+
+// apply returns a copy of v with the fields which are not selected cleared.
+// Values which are not messages are returned as is.
+func (sel fieldSelection) apply(v interface{}) (interface{}, error) {
+	if sel == nil {
+		return v, nil
+	}
+	m, ok := v.(proto.Message)
+	if !ok {
+		return v, nil
+	}
+	if err := sel.validate(m.ProtoReflect().Descriptor()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s parameter: %v", partialResponseParam, err)
+	}
+	m = proto.Clone(m)
+	sel.prune(m.ProtoReflect())
+	return m, nil
+}
+
+// selectedField returns the field of md named name and the message type a
+// sub-selection of the field applies to.
+func selectedField(md protoreflect.MessageDescriptor, name string) (protoreflect.FieldDescriptor, protoreflect.MessageDescriptor) {
+	fields := md.Fields()
+	fd := fields.ByJSONName(name)
+	if fd == nil {
+		fd = fields.ByTextName(name)
+	}
+	if fd == nil {
+		return nil, nil
+	}
+	if fd.IsMap() {
+		return fd, fd.MapValue().Message()
+	}
+	return fd, fd.Message()
+}
+
+// validate checks that the selection only refers to fields of md.
+func (sel fieldSelection) validate(md protoreflect.MessageDescriptor) error {
+	for name, sub := range sel {
+		fd, subMD := selectedField(md, name)
+		if fd == nil {
+			return fmt.Errorf("unknown field %q in %s", name, md.FullName())
+		}
+		if sub == nil {
+			continue
+		}
+		if subMD == nil {
+			return fmt.Errorf("field %q of %s is not a message", name, md.FullName())
+		}
+		if err := sub.validate(subMD); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prune clears the fields of msg which are not selected. The selection must
+// have been validated against the type of msg.
+func (sel fieldSelection) prune(msg protoreflect.Message) {
+	selected := make(map[protoreflect.FieldNumber]fieldSelection, len(sel))
+	for name, sub := range sel {
+		fd, _ := selectedField(msg.Descriptor(), name)
+		selected[fd.Number()] = sub
+	}
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		sub, ok := selected[fd.Number()]
+		switch {
+		case !ok:
+			msg.Clear(fd)
+		case sub == nil:
+		case fd.IsMap():
+			v.Map().Range(func(_ protoreflect.MapKey, item protoreflect.Value) bool {
+				sub.prune(item.Message())
+				return true
+			})
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				sub.prune(list.Get(i).Message())
+			}
+		default:
+			sub.prune(v.Message())
+		}
+		return true
+	})
+}
+
+// partialResponseMarshaler returns a copy of marshaler which does not emit
+// unpopulated fields, so that pruned fields are left out of the response.
+func partialResponseMarshaler(marshaler Marshaler) Marshaler {
+	switch m := marshaler.(type) {
+	case *envelopedMarshaler:
+		e := *m
+		e.Marshaler = partialResponseMarshaler(m.Marshaler)
+		return &e
+	case *delimitedMarshaler:
+		return m.withMarshaler(partialResponseMarshaler(m.Marshaler))
+	case *JSONPb:
+		if m.EmitUnpopulated {
+			j := *m
+			j.EmitUnpopulated = false
+			return &j
+		}
+	case *HTTPBodyMarshaler:
+		if inner, ok := m.Marshaler.(*JSONPb); ok && inner.EmitUnpopulated {
+			j := *inner
+			j.EmitUnpopulated = false
+			return &HTTPBodyMarshaler{Marshaler: &j}
+		}
+	}
+	return marshaler
+}
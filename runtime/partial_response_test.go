@@ -0,0 +1,144 @@
+package runtime_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestForwardResponseMessage_PartialResponse(t *testing.T) {
+	msg := &examplepb.ABitOfEverything{
+		Uuid:        "6EC2446F-7E89-4127-B3E6-5C05E6BECBA7",
+		StringValue: "strprefix/foo",
+		SingleNested: &examplepb.ABitOfEverything_Nested{
+			Name:   "foo",
+			Amount: 10,
+		},
+		Nested: []*examplepb.ABitOfEverything_Nested{
+			{Name: "bar", Amount: 20},
+			{Name: "baz", Amount: 30},
+		},
+		MappedNestedValue: map[string]*examplepb.ABitOfEverything_Nested{
+			"a": {Name: "qux", Amount: 40},
+		},
+	}
+
+	for _, spec := range []struct {
+		fields string
+		want   *examplepb.ABitOfEverything
+	}{
+		{
+			fields: "uuid",
+			want:   &examplepb.ABitOfEverything{Uuid: msg.Uuid},
+		},
+		{
+			fields: "uuid,string_value,singleNested",
+			want: &examplepb.ABitOfEverything{
+				Uuid:         msg.Uuid,
+				StringValue:  msg.StringValue,
+				SingleNested: msg.SingleNested,
+			},
+		},
+		{
+			fields: "singleNested/amount,nested(name)",
+			want: &examplepb.ABitOfEverything{
+				SingleNested: &examplepb.ABitOfEverything_Nested{Amount: 10},
+				Nested: []*examplepb.ABitOfEverything_Nested{
+					{Name: "bar"},
+					{Name: "baz"},
+				},
+			},
+		},
+		{
+			fields: "mappedNestedValue(amount),nested/name,nested",
+			want: &examplepb.ABitOfEverything{
+				Nested: msg.Nested,
+				MappedNestedValue: map[string]*examplepb.ABitOfEverything_Nested{
+					"a": {Amount: 40},
+				},
+			},
+		},
+		{
+			// Selecting unset fields is not an error.
+			fields: "nestedAnnotation(name),timestampValue",
+			want:   &examplepb.ABitOfEverything{},
+		},
+	} {
+		mux := runtime.NewServeMux(runtime.WithPartialResponses())
+		req := httptest.NewRequest("GET", "/v1/resource?fields="+url.QueryEscape(spec.fields), nil)
+		w := httptest.NewRecorder()
+		ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+		_, marshaler := runtime.MarshalerForRequest(mux, req)
+		runtime.ForwardResponseMessage(ctx, mux, marshaler, w, req, msg)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("%q: w.Code = %d; want %d: %s", spec.fields, got, want, w.Body)
+			continue
+		}
+		got := new(examplepb.ABitOfEverything)
+		if err := protojson.Unmarshal(w.Body.Bytes(), got); err != nil {
+			t.Errorf("%q: protojson.Unmarshal(%s) failed with %v; want success", spec.fields, w.Body, err)
+			continue
+		}
+		if diff := cmp.Diff(got, spec.want, protocmp.Transform()); diff != "" {
+			t.Errorf("%q: %s", spec.fields, diff)
+		}
+		if containsKey(w.Body.String(), "floatValue") {
+			t.Errorf("%q: body = %s; want unselected fields omitted", spec.fields, w.Body)
+		}
+	}
+	if msg.SingleNested.Name != "foo" || len(msg.Nested) != 2 || msg.Uuid == "" {
+		t.Errorf("response message was modified: %v", msg)
+	}
+}
+
+func containsKey(body, key string) bool {
+	var fields map[string]interface{}
+	_ = json.Unmarshal([]byte(body), &fields)
+	_, ok := fields[key]
+	return ok
+}
+
+func TestForwardResponseMessage_PartialResponseErrors(t *testing.T) {
+	for _, fields := range []string{
+		"unknown",
+		"uuid(name)",
+		"singleNested(unknown)",
+		"nested(name",
+		"uuid,,nested",
+		"uuid)",
+	} {
+		mux := runtime.NewServeMux(runtime.WithPartialResponses())
+		req := httptest.NewRequest("GET", "/v1/resource?fields="+url.QueryEscape(fields), nil)
+		w := httptest.NewRecorder()
+		ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+		_, marshaler := runtime.MarshalerForRequest(mux, req)
+		runtime.ForwardResponseMessage(ctx, mux, marshaler, w, req, &examplepb.ABitOfEverything{})
+
+		if got, want := w.Code, http.StatusBadRequest; got != want {
+			t.Errorf("%q: w.Code = %d; want %d: %s", fields, got, want, w.Body)
+		}
+	}
+}
+
+func TestForwardResponseMessage_PartialResponseDisabled(t *testing.T) {
+	mux := runtime.NewServeMux()
+	req := httptest.NewRequest("GET", "/v1/resource?fields=uuid", nil)
+	w := httptest.NewRecorder()
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	_, marshaler := runtime.MarshalerForRequest(mux, req)
+	runtime.ForwardResponseMessage(ctx, mux, marshaler, w, req, &examplepb.ABitOfEverything{Uuid: "foo", StringValue: "bar"})
+
+	if !containsKey(w.Body.String(), "stringValue") {
+		t.Errorf("body = %s; want the full response", w.Body)
+	}
+}
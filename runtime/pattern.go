@@ -3,6 +3,7 @@ package runtime
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
@@ -36,6 +37,8 @@ type Pattern struct {
 	tailLen int
 	// verb is the VERB part of the path pattern. It is empty if the pattern does not have VERB part.
 	verb string
+	// matcher is the compiled form of ops, or nil if ops are interpreted.
+	matcher pathMatcher
 }
 
 // NewPattern returns a new Pattern from the given definition values.
@@ -129,6 +132,7 @@ func NewPattern(version int, ops []int, pool []string, verb string) (Pattern, er
 		stacksize: maxstack,
 		tailLen:   tailLen,
 		verb:      verb,
+		matcher:   compilePathMatcher(typedOps, pool),
 	}, nil
 }
 
@@ -144,46 +148,88 @@ func MustPattern(p Pattern, err error) Pattern {
 // If it matches, the function returns a mapping from field paths to their captured values.
 // If otherwise, the function returns an error.
 func (p Pattern) Match(components []string, verb string) (map[string]string, error) {
+	return p.match(pathComponents{list: components, n: len(components)}, verb, nil)
+}
+
+// MatchPath examines path if it matches to the Pattern, like Match does with
+// the components of path. path is a URL path without its leading slash and
+// without its verb, its components are separated by slashes. Unlike
+// splitting path into components, MatchPath does not allocate for them.
+func (p Pattern) MatchPath(path, verb string) (map[string]string, error) {
+	return p.match(newPathComponents(path), verb, nil)
+}
+
+// MatchPathTo examines path like MatchPath, and stores the captured values in
+// bindings instead of a new map. bindings is left untouched if path does not
+// match.
+func (p Pattern) MatchPathTo(path, verb string, bindings map[string]string) error {
+	_, err := p.match(newPathComponents(path), verb, bindings)
+	return err
+}
+
+// MatchRequest examines the path of r if it matches to the Pattern, splitting
+// its verb off as ServeMux does, so that tests and tools can reuse the
+// matching semantics of the mux. The path is r.URL.Path, which net/http
+// unescapes. Patterns have no method; ServeMux.RoutingMethod returns the
+// method r is routed with.
+func (p Pattern) MatchRequest(r *http.Request) (map[string]string, error) {
+	path := r.URL.Path
+	if !strings.HasPrefix(path, "/") {
+		return nil, ErrNotMatch
+	}
+	matchPath, verb := path[1:], ""
+	lastComponent, idx := verbIndex(matchPath, p.verb)
+	if idx == 0 {
+		return nil, ErrNotMatch
+	}
+	if idx > 0 {
+		matchPath, verb = matchPath[:len(matchPath)-len(lastComponent)+idx], lastComponent[idx+1:]
+	}
+	return p.MatchPath(matchPath, verb)
+}
+
+// match stores the values captured from components in bindings, or in a new
+// map if it is nil.
+func (p Pattern) match(components pathComponents, verb string, bindings map[string]string) (map[string]string, error) {
 	if p.verb != verb {
 		if p.verb != "" {
 			return nil, ErrNotMatch
 		}
-		if len(components) == 0 {
-			components = []string{":" + verb}
-		} else {
-			components = append([]string{}, components...)
-			components[len(components)-1] += ":" + verb
+		components = components.withVerb(verb)
+	}
+
+	captured := make([]string, len(p.vars))
+	if p.matcher != nil && components.list == nil && components.n > 0 {
+		if !p.matcher(components.path, captured) {
+			return nil, ErrNotMatch
 		}
+		return p.bind(captured, bindings), nil
 	}
 
-	var pos int
 	stack := make([]string, 0, p.stacksize)
-	captured := make([]string, len(p.vars))
-	l := len(components)
+	l := components.n
 	for _, op := range p.ops {
 		switch op.code {
 		case utilities.OpNop:
 			continue
 		case utilities.OpPush, utilities.OpLitPush:
-			if pos >= l {
+			if components.pos >= l {
 				return nil, ErrNotMatch
 			}
-			c := components[pos]
+			c := components.next()
 			if op.code == utilities.OpLitPush {
 				if lit := p.pool[op.operand]; c != lit {
 					return nil, ErrNotMatch
 				}
 			}
 			stack = append(stack, c)
-			pos++
 		case utilities.OpPushM:
-			end := len(components)
-			if end < pos+p.tailLen {
+			end := l
+			if end < components.pos+p.tailLen {
 				return nil, ErrNotMatch
 			}
 			end -= p.tailLen
-			stack = append(stack, strings.Join(components[pos:end], "/"))
-			pos = end
+			stack = append(stack, components.take(end-components.pos))
 		case utilities.OpConcatN:
 			n := op.operand
 			l := len(stack) - n
@@ -194,14 +240,89 @@ func (p Pattern) Match(components []string, verb string) (map[string]string, err
 			stack = stack[:n]
 		}
 	}
-	if pos < l {
+	if components.pos < l {
 		return nil, ErrNotMatch
 	}
-	bindings := make(map[string]string)
+	return p.bind(captured, bindings), nil
+}
+
+// bind stores the captured values of the variables in bindings, or in a new
+// map if it is nil.
+func (p Pattern) bind(captured []string, bindings map[string]string) map[string]string {
+	if bindings == nil {
+		bindings = make(map[string]string, len(captured))
+	}
 	for i, val := range captured {
 		bindings[p.vars[i]] = val
 	}
-	return bindings, nil
+	return bindings
+}
+
+// pathComponents iterates over the slash separated components of a path
+// without splitting it, or over a list of components.
+type pathComponents struct {
+	path string
+	list []string
+	// n is the number of components.
+	n int
+	// pos is the index of the next component, off its offset in path.
+	pos, off int
+}
+
+func newPathComponents(path string) pathComponents {
+	return pathComponents{path: path, n: strings.Count(path, "/") + 1}
+}
+
+// withVerb returns the components with ":verb" appended to the last one.
+func (c pathComponents) withVerb(verb string) pathComponents {
+	if c.list == nil {
+		if c.n == 0 {
+			return pathComponents{path: ":" + verb, n: 1}
+		}
+		c.path += ":" + verb
+		return c
+	}
+	if c.n == 0 {
+		return pathComponents{list: []string{":" + verb}, n: 1}
+	}
+	c.list = append([]string{}, c.list...)
+	c.list[c.n-1] += ":" + verb
+	return c
+}
+
+// next returns the next component.
+func (c *pathComponents) next() string {
+	if c.list != nil {
+		c.pos++
+		return c.list[c.pos-1]
+	}
+	rest := c.path[c.off:]
+	c.pos++
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		c.off += i + 1
+		return rest[:i]
+	}
+	c.off = len(c.path)
+	return rest
+}
+
+// take returns the next k components joined by slashes.
+func (c *pathComponents) take(k int) string {
+	if c.list != nil {
+		c.pos += k
+		return strings.Join(c.list[c.pos-k:c.pos], "/")
+	}
+	if k == 0 {
+		return ""
+	}
+	start := c.off
+	for i := 0; i < k; i++ {
+		c.next()
+	}
+	if c.pos == c.n {
+		return c.path[start:]
+	}
+	return c.path[start : c.off-1]
 }
 
 // Verb returns the verb part of the Pattern.
@@ -0,0 +1,104 @@
+package runtime
+
+import (
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+)
+
+// pathMatcher matches a path without its leading slash and verb, storing the
+// values of the variables of a Pattern in captured.
+type pathMatcher func(path string, captured []string) bool
+
+// pathRange is the range [from, to) of the components of a path.
+type pathRange struct {
+	from, to int
+}
+
+// pathCapture is a variable capturing a range of components.
+type pathCapture struct {
+	pathRange
+	// v is the index of the variable.
+	v int
+}
+
+// compilePathMatcher compiles ops into a pathMatcher, which matches paths
+// without interpreting ops: it checks the literal components of the path and
+// slices the values of the variables out of it. Since the matcher tracks
+// components by their index, patterns with a deep wildcard ("**") are not
+// compiled; it returns nil for them and for patterns matching the empty path.
+func compilePathMatcher(ops []op, pool []string) pathMatcher {
+	var (
+		stack    []pathRange
+		literals []string
+		isLit    []bool
+		captures []pathCapture
+	)
+	for _, op := range ops {
+		switch op.code {
+		case utilities.OpPush, utilities.OpLitPush:
+			n := len(literals)
+			stack = append(stack, pathRange{from: n, to: n + 1})
+			if op.code == utilities.OpLitPush {
+				literals, isLit = append(literals, pool[op.operand]), append(isLit, true)
+			} else {
+				literals, isLit = append(literals, ""), append(isLit, false)
+			}
+		case utilities.OpPushM:
+			return nil
+		case utilities.OpConcatN:
+			l := len(stack) - op.operand
+			for i := l + 1; i < len(stack); i++ {
+				if stack[i-1].to != stack[i].from {
+					return nil
+				}
+			}
+			stack = append(stack[:l], pathRange{from: stack[l].from, to: stack[len(stack)-1].to})
+		case utilities.OpCapture:
+			n := len(stack) - 1
+			captures = append(captures, pathCapture{pathRange: stack[n], v: op.operand})
+			stack = stack[:n]
+		}
+	}
+	n := len(literals)
+	if n == 0 {
+		return nil
+	}
+	for i := 1; i < len(captures); i++ {
+		// The matcher slices the variables out of the path in a single pass.
+		if captures[i].from < captures[i-1].to {
+			return nil
+		}
+	}
+
+	return func(path string, captured []string) bool {
+		var next, start, off int
+		for i := 0; i < n; i++ {
+			end := strings.IndexByte(path[off:], '/')
+			if last := end < 0; last != (i == n-1) {
+				// The path has fewer or more components than the pattern.
+				return false
+			}
+			if end < 0 {
+				end = len(path)
+			} else {
+				end += off
+			}
+			if isLit[i] && path[off:end] != literals[i] {
+				return false
+			}
+			if next < len(captures) {
+				c := captures[next]
+				if c.from == i {
+					start = off
+				}
+				if c.to-1 == i {
+					captured[c.v] = path[start:end]
+					next++
+				}
+			}
+			off = end + 1
+		}
+		return true
+	}
+}
@@ -0,0 +1,135 @@
+//go:build go1.18
+// +build go1.18
+
+package runtime
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/httprule"
+)
+
+// fuzzTemplates and fuzzPaths seed the fuzz targets with the templates and
+// paths of the pattern, mux and conformance tests.
+var (
+	fuzzTemplates = []string{
+		"/",
+		"/v1",
+		"/v1/shelves",
+		"/v1/shelves/{shelf}",
+		"/v1/shelves/{shelf}/books/{book}",
+		"/v1/{name=shelves/*/books/*}",
+		"/v1/{name=shelves/*/books/*}:read",
+		"/v1/shelves/{shelf}:archive",
+		"/v1/files/{path=**}",
+		"/v1/{name=**}",
+		"/v1/users/{user}:verb:with:colons",
+		"/{a}/{b}:verb",
+		"/{a}/b/{c}",
+		"/*/b/**",
+		"/foo/{id=bar/*}/**:verb",
+		"/v1/{name=projects/*/locations/*}/instances",
+	}
+	fuzzPaths = []string{
+		"",
+		"v1",
+		"v1/shelves",
+		"v1/shelves/fiction",
+		"v1/shelves/fiction/books/dune",
+		"v1/shelves/fiction/books/dune:read",
+		"v1/shelves/fiction:archive",
+		"v1/shelves/fiction:",
+		"v1/files/a/b/c.txt",
+		"v1/users/alice:verb:with:colons",
+		"v1/shelves//books/",
+		"x/y:verb",
+		"x/:verb",
+		"x/b/y:verb",
+		"abc/def/tail/extra",
+	}
+)
+
+func newFuzzPattern(template string) (Pattern, bool) {
+	compiler, err := httprule.Parse(template)
+	if err != nil {
+		return Pattern{}, false
+	}
+	tp := compiler.Compile()
+	pat, err := NewPattern(tp.Version, tp.OpCodes, tp.Pool, tp.Verb)
+	if err != nil {
+		return Pattern{}, false
+	}
+	return pat, true
+}
+
+func FuzzNewPattern(f *testing.F) {
+	for _, template := range fuzzTemplates {
+		f.Add(template)
+	}
+	f.Fuzz(func(t *testing.T, template string) {
+		pat, ok := newFuzzPattern(template)
+		if !ok {
+			return
+		}
+		// The string of a pattern is a template of the same pattern.
+		again, ok := newFuzzPattern(pat.String())
+		if !ok {
+			t.Fatalf("pattern %s of %q does not parse", pat, template)
+		}
+		if !reflect.DeepEqual(again.ops, pat.ops) || !reflect.DeepEqual(again.pool, pat.pool) || again.verb != pat.verb {
+			t.Errorf("pattern %s of %q parses into %s", pat, template, again)
+		}
+	})
+}
+
+func FuzzNewPatternOpCodes(f *testing.F) {
+	f.Add([]byte{2, 0, 3, 1, 2, 0, 5, 2, 4, 2}, "v1/name", "")
+	f.Add([]byte{1, 0, 1, 1, 4, 1}, "v1/a", "verb")
+	f.Add([]byte{3, 0, 5, 0}, "", "")
+	f.Fuzz(func(t *testing.T, opcodes []byte, pool, verb string) {
+		ops := make([]int, len(opcodes))
+		for i, op := range opcodes {
+			ops[i] = int(int8(op))
+		}
+		var components []string
+		if pool != "" {
+			components = strings.Split(pool, "/")
+		}
+		// Invalid op codes must be reported, not panic.
+		pat, err := NewPattern(1, ops, components, verb)
+		if err != nil {
+			return
+		}
+		for _, path := range fuzzPaths {
+			pat.MatchPath(splitVerb(path))
+		}
+	})
+}
+
+func FuzzMatch(f *testing.F) {
+	for _, template := range fuzzTemplates {
+		for _, path := range fuzzPaths {
+			f.Add(template, path)
+		}
+	}
+	f.Fuzz(func(t *testing.T, template, path string) {
+		pat, ok := newFuzzPattern(template)
+		if !ok {
+			return
+		}
+		interpreted := pat
+		interpreted.matcher = nil
+
+		want, wantErr := interpreted.Match(segments(path))
+		got, err := interpreted.MatchPath(splitVerb(path))
+		if err != wantErr || !reflect.DeepEqual(got, want) {
+			t.Errorf("interpreted pat.MatchPath(%q) = %q, %v; want %q, %v; pattern = %s", path, got, err, want, wantErr, pat)
+		}
+		got, err = pat.MatchPath(splitVerb(path))
+		if err != wantErr || !reflect.DeepEqual(got, want) {
+			t.Errorf("pat.MatchPath(%q) = %q, %v; want %q, %v; pattern = %s", path, got, err, want, wantErr, pat)
+		}
+	})
+}
@@ -2,10 +2,12 @@ package runtime
 
 import (
 	"fmt"
+	"net/http/httptest"
 	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/httprule"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
 )
 
@@ -346,6 +348,13 @@ func TestMatch(t *testing.T) {
 			if err != nil {
 				t.Errorf("pat.Match(%q) failed with %v; want success; pattern = (%v, %q)", path, err, spec.ops, spec.pool)
 			}
+			if path == "" {
+				// An empty path has one empty component, unlike the empty list of segments.
+				continue
+			}
+			if _, err = pat.MatchPath(splitVerb(path)); err != nil {
+				t.Errorf("pat.MatchPath(%q) failed with %v; want success; pattern = (%v, %q)", path, err, spec.ops, spec.pool)
+			}
 		}
 
 		for _, path := range spec.notMatch {
@@ -357,6 +366,12 @@ func TestMatch(t *testing.T) {
 			if err != ErrNotMatch {
 				t.Errorf("pat.Match(%q) failed with %v; want failure with %v; pattern = (%v, %q)", spec.notMatch, err, ErrNotMatch, spec.ops, spec.pool)
 			}
+			if path == "" {
+				continue
+			}
+			if _, err = pat.MatchPath(splitVerb(path)); err != ErrNotMatch {
+				t.Errorf("pat.MatchPath(%q) = %v; want failure with %v; pattern = (%v, %q)", path, err, ErrNotMatch, spec.ops, spec.pool)
+			}
 		}
 	}
 }
@@ -491,9 +506,29 @@ func TestMatchWithBinding(t *testing.T) {
 		if !reflect.DeepEqual(got, spec.want) {
 			t.Errorf("pat.Match(%q) = %q; want %q; pattern = (%v, %q)", spec.path, got, spec.want, spec.ops, spec.pool)
 		}
+
+		if spec.path == "" {
+			continue
+		}
+		got, err = pat.MatchPath(splitVerb(spec.path))
+		if err != nil {
+			t.Errorf("pat.MatchPath(%q) failed with %v; want success; pattern = (%v, %q)", spec.path, err, spec.ops, spec.pool)
+		}
+		if !reflect.DeepEqual(got, spec.want) {
+			t.Errorf("pat.MatchPath(%q) = %q; want %q; pattern = (%v, %q)", spec.path, got, spec.want, spec.ops, spec.pool)
+		}
 	}
 }
 
+// splitVerb splits path like segments, without splitting its components.
+func splitVerb(path string) (string, string) {
+	last := strings.LastIndex(path, "/") + 1
+	if idx := strings.LastIndex(path[last:], ":"); idx >= 0 {
+		return path[:last+idx], path[last+idx+1:]
+	}
+	return path, ""
+}
+
 func segments(path string) (components []string, verb string) {
 	if path == "" {
 		return nil, ""
@@ -588,3 +623,184 @@ func TestPatternString(t *testing.T) {
 		}
 	}
 }
+
+func TestCompilePathMatcher(t *testing.T) {
+	for _, spec := range []struct {
+		ops      []int
+		pool     []string
+		verb     string
+		compiled bool
+
+		paths []string
+	}{
+		{
+			// /v1/{name=shelves/*/books/*}
+			ops: []int{
+				int(utilities.OpLitPush), 0,
+				int(utilities.OpLitPush), 1,
+				int(utilities.OpPush), anything,
+				int(utilities.OpLitPush), 2,
+				int(utilities.OpPush), anything,
+				int(utilities.OpConcatN), 4,
+				int(utilities.OpCapture), 3,
+			},
+			pool:     []string{"v1", "shelves", "books", "name"},
+			compiled: true,
+			paths:    []string{"v1/shelves/1/books/2", "v1/shelves/1/books", "v1/shelves/1/books/2/3", "v1/shelves//books/", "v2/shelves/1/books/2"},
+		},
+		{
+			// /{a}/{b}:verb
+			ops: []int{
+				int(utilities.OpPush), anything,
+				int(utilities.OpCapture), 0,
+				int(utilities.OpPush), anything,
+				int(utilities.OpCapture), 1,
+			},
+			pool:     []string{"a", "b"},
+			verb:     "verb",
+			compiled: true,
+			paths:    []string{"x/y:verb", "x/y", "x:verb", "x/y/z:verb", "x/:verb"},
+		},
+		{
+			// /{a}/b/{c}
+			ops: []int{
+				int(utilities.OpPush), anything,
+				int(utilities.OpCapture), 0,
+				int(utilities.OpLitPush), 1,
+				int(utilities.OpPush), anything,
+				int(utilities.OpCapture), 2,
+			},
+			pool:     []string{"a", "b", "c"},
+			compiled: true,
+			paths:    []string{"x/b/y", "x/c/y", "x/b", "x/b/y:verb"},
+		},
+		{
+			// /v1/{name=**}
+			ops: []int{
+				int(utilities.OpLitPush), 0,
+				int(utilities.OpPushM), anything,
+				int(utilities.OpConcatN), 1,
+				int(utilities.OpCapture), 1,
+			},
+			pool:  []string{"v1", "name"},
+			paths: []string{"v1", "v1/a", "v1/a/b"},
+		},
+	} {
+		pat, err := NewPattern(validVersion, spec.ops, spec.pool, spec.verb)
+		if err != nil {
+			t.Errorf("NewPattern(%d, %v, %q, %q) failed with %v; want success", validVersion, spec.ops, spec.pool, spec.verb, err)
+			continue
+		}
+		if got := pat.matcher != nil; got != spec.compiled {
+			t.Errorf("pattern %s compiled = %t; want %t", pat, got, spec.compiled)
+		}
+		for _, path := range spec.paths {
+			want, wantErr := pat.Match(segments(path))
+			got, err := pat.MatchPath(splitVerb(path))
+			if err != wantErr || !reflect.DeepEqual(got, want) {
+				t.Errorf("pat.MatchPath(%q) = %q, %v; want %q, %v; pattern = %s", path, got, err, want, wantErr, pat)
+			}
+		}
+	}
+}
+
+func BenchmarkMatchPath(b *testing.B) {
+	// /v1/{name=shelves/*/books/*}
+	pat, err := NewPattern(validVersion, []int{
+		int(utilities.OpLitPush), 0,
+		int(utilities.OpLitPush), 1,
+		int(utilities.OpPush), anything,
+		int(utilities.OpLitPush), 2,
+		int(utilities.OpPush), anything,
+		int(utilities.OpConcatN), 4,
+		int(utilities.OpCapture), 3,
+	}, []string{"v1", "shelves", "books", "name"}, "")
+	if err != nil {
+		b.Fatalf("NewPattern failed with %v; want success", err)
+	}
+	interpreted := pat
+	interpreted.matcher = nil
+
+	for _, bm := range []struct {
+		name string
+		pat  Pattern
+	}{
+		{"compiled", pat},
+		{"interpreted", interpreted},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := bm.pat.MatchPath("v1/shelves/1/books/2", ""); err != nil {
+					b.Fatalf("MatchPath failed with %v; want success", err)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchRequest(t *testing.T) {
+	newPattern := func(template string) Pattern {
+		t.Helper()
+		compiler, err := httprule.Parse(template)
+		if err != nil {
+			t.Fatalf("httprule.Parse(%q) failed with %v; want success", template, err)
+		}
+		tp := compiler.Compile()
+		pat, err := NewPattern(tp.Version, tp.OpCodes, tp.Pool, tp.Verb)
+		if err != nil {
+			t.Fatalf("NewPattern(...) failed with %v; want success", err)
+		}
+		return pat
+	}
+	for _, spec := range []struct {
+		template string
+		url      string
+		want     map[string]string
+	}{
+		{
+			template: "/v1/{name=books/*}",
+			url:      "http://example.com/v1/books/1?view=full",
+			want:     map[string]string{"name": "books/1"},
+		},
+		{
+			template: "/v1/{name=books/*}:publish",
+			url:      "/v1/books/1:publish",
+			want:     map[string]string{"name": "books/1"},
+		},
+		{
+			template: "/v1/{name=books/*}",
+			url:      "/v1/books/1:publish",
+			want:     map[string]string{"name": "books/1:publish"},
+		},
+		{
+			template: "/v1/{name=books/*}",
+			url:      "/v1/books/a%2Fb",
+		},
+		{
+			template: "/v1/{name=books/*}",
+			url:      "/v1/books/a%3Ab",
+			want:     map[string]string{"name": "books/a:b"},
+		},
+		{
+			template: "/v1/books:publish",
+			url:      "/v1/books/:publish",
+		},
+		{
+			template: "/v1/{name=books/*}:publish",
+			url:      "/v1/books/1",
+		},
+	} {
+		pat := newPattern(spec.template)
+		got, err := pat.MatchRequest(httptest.NewRequest("GET", spec.url, nil))
+		if spec.want == nil {
+			if err != ErrNotMatch {
+				t.Errorf("%s.MatchRequest(%s) = %v, %v; want ErrNotMatch", spec.template, spec.url, got, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, spec.want) {
+			t.Errorf("%s.MatchRequest(%s) = %v, %v; want %v", spec.template, spec.url, got, err, spec.want)
+		}
+	}
+}
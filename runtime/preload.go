@@ -0,0 +1,128 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/internal/httprule"
+)
+
+// Route is a route of a ServeMuxDynamic, see RouteSource.
+type Route struct {
+	// Method is the HTTP method of the route, e.g. "GET".
+	Method string
+	// Pattern is the path pattern of the route, e.g. "/v1/{name=books/*}".
+	Pattern string
+	Handler HandlerFunc
+	Options []RouteOption
+}
+
+// RouteSource is a source of the routes of a ServeMuxDynamic, e.g. loading
+// them from a descriptor set or a config file, see ServeMuxDynamic.Preload.
+type RouteSource interface {
+	Routes() ([]Route, error)
+}
+
+// RouteSourceFunc is a function implementing RouteSource.
+type RouteSourceFunc func() ([]Route, error)
+
+// Routes calls f.
+func (f RouteSourceFunc) Routes() ([]Route, error) {
+	return f()
+}
+
+// StaticRoutes returns a RouteSource of "routes".
+func StaticRoutes(routes ...Route) RouteSource {
+	return RouteSourceFunc(func() ([]Route, error) {
+		return routes, nil
+	})
+}
+
+// Preload loads the routes of all the sources and validates them, compiling
+// their patterns and resolving their upstreams, see WithRouteUpstream,
+// against the ones added with AddUpstream, before replacing all the routes of
+// the mux with them at once and marking them as synced, see
+// MarkRoutesSynced. It is meant to be called on startup, so that a
+// misconfiguration fails the boot instead of routes missing in production:
+// if any source or route is invalid, it returns an error listing all the
+// failures and leaves the mux untouched. Routes loaded later take
+// precedence over the ones loaded earlier for the same method and pattern,
+// as with Handle.
+func (s *ServeMuxDynamic) Preload(sources ...RouteSource) error {
+	return s.preload(routeChangeBy{}, sources)
+}
+
+func (s *ServeMuxDynamic) preload(by routeChangeBy, sources []RouteSource) error {
+	handlers, err := s.loadRoutes(sources)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.handlers = handlers
+	s.routeCache.reset()
+	s.mu.Unlock()
+	s.MarkRoutesSynced()
+	s.auditRouteChange(by, RouteAuditSwap, "", "")
+	return nil
+}
+
+// loadRoutes loads and validates the routes of "sources", and returns their
+// handlers by method.
+func (s *ServeMuxDynamic) loadRoutes(sources []RouteSource) (map[string][]handler, error) {
+	var failures []string
+	handlers := make(map[string][]handler)
+	for i, source := range sources {
+		routes, err := source.Routes()
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("source %d: %v", i, err))
+			continue
+		}
+		for _, route := range routes {
+			h, err := s.preloadedHandler(route)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("source %d: %s %s: %v", i, route.Method, route.Pattern, err))
+				continue
+			}
+			handlers[route.Method] = insertHandler(handlers[route.Method], h)
+		}
+	}
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("preloading routes: %s", strings.Join(failures, "; "))
+	}
+	return handlers, nil
+}
+
+// preloadedHandler validates "route" and returns its handler.
+func (s *ServeMuxDynamic) preloadedHandler(route Route) (handler, error) {
+	if route.Method == "" {
+		return handler{}, fmt.Errorf("missing method")
+	}
+	if route.Handler == nil {
+		return handler{}, fmt.Errorf("missing handler")
+	}
+	compiler, err := httprule.Parse(route.Pattern)
+	if err != nil {
+		return handler{}, fmt.Errorf("parsing path pattern: %w", err)
+	}
+	tp := compiler.Compile()
+	pattern, err := NewPattern(tp.Version, tp.OpCodes, tp.Pool, tp.Verb)
+	if err != nil {
+		return handler{}, fmt.Errorf("creating new pattern: %w", err)
+	}
+	opts := newRouteOptions(route.Options)
+	if opts != nil {
+		s.health.mu.Lock()
+		for _, name := range opts.upstreams {
+			if _, ok := s.health.upstreams[name]; !ok {
+				err = fmt.Errorf("unknown upstream %q", name)
+				break
+			}
+		}
+		s.health.mu.Unlock()
+		if err != nil {
+			return handler{}, err
+		}
+	}
+	return handler{pat: pattern, h: route.Handler, opts: opts}, nil
+}
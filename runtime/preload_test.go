@@ -0,0 +1,89 @@
+package runtime_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+)
+
+func TestServeMuxDynamic_Preload(t *testing.T) {
+	mux := runtime.NewServeMuxDynamic(runtime.WithHealthEndpoints(runtime.HealthConfig{RequireRouteSync: true}))
+	conn, err := grpc.Dial("passthrough:///upstream", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	mux.AddUpstream("library", conn)
+
+	reply := func(body string) runtime.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+			w.Write([]byte(body))
+		}
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	routesSynced := func() bool {
+		_, body := getHealth(t, mux, "/readyz")
+		failures, _ := body["failures"].(map[string]interface{})
+		_, ok := failures["routes"]
+		return !ok
+	}
+	if err := mux.HandlePath("GET", "/v1/old", reply("old")); err != nil {
+		t.Fatal(err)
+	}
+
+	err = mux.Preload(
+		runtime.StaticRoutes(
+			runtime.Route{Method: "GET", Pattern: "/v1/books", Handler: reply("books")},
+			runtime.Route{Method: "GET", Pattern: "/v1/{name=books/*", Handler: reply("book")},
+			runtime.Route{Method: "GET", Pattern: "/v1/shelves", Handler: reply("shelves"), Options: []runtime.RouteOption{runtime.WithRouteUpstream("unknown")}},
+		),
+		runtime.RouteSourceFunc(func() ([]runtime.Route, error) {
+			return nil, errors.New("descriptor set not found")
+		}),
+	)
+	if err == nil {
+		t.Fatalf("mux.Preload(...) succeeded with invalid routes; want failure")
+	}
+	for _, want := range []string{"/v1/{name=books/*", `unknown upstream "unknown"`, "descriptor set not found"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("mux.Preload(...) failed with %v; want an error mentioning %q", err, want)
+		}
+	}
+	if w := get("/v1/old"); w.Code != http.StatusOK || w.Body.String() != "old" {
+		t.Errorf("GET /v1/old replied %d %q after a failed preload; want the old route", w.Code, w.Body)
+	}
+	if routesSynced() {
+		t.Errorf("routes synced after a failed preload")
+	}
+
+	err = mux.Preload(
+		runtime.StaticRoutes(
+			runtime.Route{Method: "GET", Pattern: "/v1/books", Handler: reply("books")},
+			runtime.Route{Method: "GET", Pattern: "/v1/shelves", Handler: reply("shelves"), Options: []runtime.RouteOption{runtime.WithRouteUpstream("library")}},
+		),
+		runtime.StaticRoutes(runtime.Route{Method: "GET", Pattern: "/v1/books", Handler: reply("overridden")}),
+	)
+	if err != nil {
+		t.Fatalf("mux.Preload(...) failed with %v; want success", err)
+	}
+	for path, want := range map[string]string{"/v1/books": "overridden", "/v1/shelves": "shelves"} {
+		if w := get(path); w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("GET %s replied %d %q; want %d %q", path, w.Code, w.Body, http.StatusOK, want)
+		}
+	}
+	if w := get("/v1/old"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /v1/old replied %d after the preload; want %d", w.Code, http.StatusNotFound)
+	}
+	if !routesSynced() {
+		t.Errorf("routes not synced after the preload")
+	}
+}
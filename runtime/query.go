@@ -40,6 +40,12 @@ type defaultQueryParser struct{}
 // Parse populates "values" into "msg".
 // A value is ignored if its key starts with one of the elements in "filter".
 func (*defaultQueryParser) Parse(msg proto.Message, values url.Values, filter *utilities.DoubleArray) error {
+	return parseQueryParameters(msg, values, filter, nil)
+}
+
+// parseQueryParameters populates "values" into "msg", parsing timestamps and
+// durations in "timeEncoding" if it is not nil.
+func parseQueryParameters(msg proto.Message, values url.Values, filter *utilities.DoubleArray, timeEncoding *TimeEncoding) error {
 	for key, values := range values {
 		match := valuesKeyRegexp.FindStringSubmatch(key)
 		if len(match) == 3 {
@@ -50,7 +56,7 @@ func (*defaultQueryParser) Parse(msg proto.Message, values url.Values, filter *u
 		if filter.HasCommonPrefix(fieldPath) {
 			continue
 		}
-		if err := populateFieldValueFromPath(msg.ProtoReflect(), fieldPath, values); err != nil {
+		if err := populateFieldValueFromPath(msg.ProtoReflect(), fieldPath, values, timeEncoding); err != nil {
 			return err
 		}
 	}
@@ -60,10 +66,10 @@ func (*defaultQueryParser) Parse(msg proto.Message, values url.Values, filter *u
 // PopulateFieldFromPath sets a value in a nested Protobuf structure.
 func PopulateFieldFromPath(msg proto.Message, fieldPathString string, value string) error {
 	fieldPath := strings.Split(fieldPathString, ".")
-	return populateFieldValueFromPath(msg.ProtoReflect(), fieldPath, []string{value})
+	return populateFieldValueFromPath(msg.ProtoReflect(), fieldPath, []string{value}, nil)
 }
 
-func populateFieldValueFromPath(msgValue protoreflect.Message, fieldPath []string, values []string) error {
+func populateFieldValueFromPath(msgValue protoreflect.Message, fieldPath []string, values []string, timeEncoding *TimeEncoding) error {
 	if len(fieldPath) < 1 {
 		return errors.New("no field path")
 	}
@@ -110,20 +116,20 @@ func populateFieldValueFromPath(msgValue protoreflect.Message, fieldPath []strin
 
 	switch {
 	case fieldDescriptor.IsList():
-		return populateRepeatedField(fieldDescriptor, msgValue.Mutable(fieldDescriptor).List(), values)
+		return populateRepeatedField(fieldDescriptor, msgValue.Mutable(fieldDescriptor).List(), values, timeEncoding)
 	case fieldDescriptor.IsMap():
-		return populateMapField(fieldDescriptor, msgValue.Mutable(fieldDescriptor).Map(), values)
+		return populateMapField(fieldDescriptor, msgValue.Mutable(fieldDescriptor).Map(), values, timeEncoding)
 	}
 
 	if len(values) > 1 {
 		return fmt.Errorf("too many values for field %q: %s", fieldDescriptor.FullName().Name(), strings.Join(values, ", "))
 	}
 
-	return populateField(fieldDescriptor, msgValue, values[0])
+	return populateField(fieldDescriptor, msgValue, values[0], timeEncoding)
 }
 
-func populateField(fieldDescriptor protoreflect.FieldDescriptor, msgValue protoreflect.Message, value string) error {
-	v, err := parseField(fieldDescriptor, value)
+func populateField(fieldDescriptor protoreflect.FieldDescriptor, msgValue protoreflect.Message, value string, timeEncoding *TimeEncoding) error {
+	v, err := parseField(fieldDescriptor, value, timeEncoding)
 	if err != nil {
 		return fmt.Errorf("parsing field %q: %w", fieldDescriptor.FullName().Name(), err)
 	}
@@ -132,9 +138,9 @@ func populateField(fieldDescriptor protoreflect.FieldDescriptor, msgValue protor
 	return nil
 }
 
-func populateRepeatedField(fieldDescriptor protoreflect.FieldDescriptor, list protoreflect.List, values []string) error {
+func populateRepeatedField(fieldDescriptor protoreflect.FieldDescriptor, list protoreflect.List, values []string, timeEncoding *TimeEncoding) error {
 	for _, value := range values {
-		v, err := parseField(fieldDescriptor, value)
+		v, err := parseField(fieldDescriptor, value, timeEncoding)
 		if err != nil {
 			return fmt.Errorf("parsing list %q: %w", fieldDescriptor.FullName().Name(), err)
 		}
@@ -144,17 +150,17 @@ func populateRepeatedField(fieldDescriptor protoreflect.FieldDescriptor, list pr
 	return nil
 }
 
-func populateMapField(fieldDescriptor protoreflect.FieldDescriptor, mp protoreflect.Map, values []string) error {
+func populateMapField(fieldDescriptor protoreflect.FieldDescriptor, mp protoreflect.Map, values []string, timeEncoding *TimeEncoding) error {
 	if len(values) != 2 {
 		return fmt.Errorf("more than one value provided for key %q in map %q", values[0], fieldDescriptor.FullName())
 	}
 
-	key, err := parseField(fieldDescriptor.MapKey(), values[0])
+	key, err := parseField(fieldDescriptor.MapKey(), values[0], nil)
 	if err != nil {
 		return fmt.Errorf("parsing map key %q: %w", fieldDescriptor.FullName().Name(), err)
 	}
 
-	value, err := parseField(fieldDescriptor.MapValue(), values[1])
+	value, err := parseField(fieldDescriptor.MapValue(), values[1], timeEncoding)
 	if err != nil {
 		return fmt.Errorf("parsing map value %q: %w", fieldDescriptor.FullName().Name(), err)
 	}
@@ -164,7 +170,7 @@ func populateMapField(fieldDescriptor protoreflect.FieldDescriptor, mp protorefl
 	return nil
 }
 
-func parseField(fieldDescriptor protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+func parseField(fieldDescriptor protoreflect.FieldDescriptor, value string, timeEncoding *TimeEncoding) (protoreflect.Value, error) {
 	switch fieldDescriptor.Kind() {
 	case protoreflect.BoolKind:
 		v, err := strconv.ParseBool(value)
@@ -239,13 +245,19 @@ func parseField(fieldDescriptor protoreflect.FieldDescriptor, value string) (pro
 		}
 		return protoreflect.ValueOfBytes(v), nil
 	case protoreflect.MessageKind, protoreflect.GroupKind:
-		return parseMessage(fieldDescriptor.Message(), value)
+		return parseMessage(fieldDescriptor.Message(), value, timeEncoding)
 	default:
 		panic(fmt.Sprintf("unknown field kind: %v", fieldDescriptor.Kind()))
 	}
 }
 
-func parseMessage(msgDescriptor protoreflect.MessageDescriptor, value string) (protoreflect.Value, error) {
+func parseMessage(msgDescriptor protoreflect.MessageDescriptor, value string, timeEncoding *TimeEncoding) (protoreflect.Value, error) {
+	if timeEncoding != nil {
+		var err error
+		if value, err = timeEncoding.queryValue(msgDescriptor, value); err != nil {
+			return protoreflect.Value{}, err
+		}
+	}
 	var msg proto.Message
 	switch msgDescriptor.FullName() {
 	case "google.protobuf.Timestamp":
@@ -0,0 +1,44 @@
+//go:build go1.18
+// +build go1.18
+
+package runtime_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+	"google.golang.org/protobuf/proto"
+)
+
+func FuzzPopulateQueryParameters(f *testing.F) {
+	for _, query := range []string{
+		"float_value=1.5&double_value=2.5&int64_value=-1&int32_value=-2",
+		"uint64_value=3&uint32_value=4&bool_value=true&string_value=str",
+		"bytes_value=YWJjMTIzIT8kKiYoKSctPUB-",
+		"repeated_value=a&repeated_value=b&repeated_message=1&repeated_message=2",
+		"enum_value=1&repeated_enum=1&repeated_enum=2&repeated_enum=0",
+		"timestamp_value=2016-12-15T12:23:32.000000049Z&duration_value=13h0m0s",
+		"fieldmask_value=float_value,double_value",
+		"wrapper_float_value=1.5&wrapper_u_int64_value=3&wrapper_bool_value=true",
+		"map_value[key]=value&map_value2[key]=-2&map_value15[true]=value",
+		"nested.nested.string_value=str&nested.repeated_value=a",
+		"oneof_string_value=str&oneof_bool_value=true",
+		"nestedProtoField.string_value=str&optional_string_value=str",
+	} {
+		f.Add(query)
+	}
+	filter := utilities.NewDoubleArray([][]string{{"bool_value"}, {"repeated_value"}})
+	f.Fuzz(func(t *testing.T, query string) {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return
+		}
+		// Invalid parameters must be reported, not panic.
+		for _, msg := range []proto.Message{&examplepb.Proto3Message{}, &examplepb.Proto2Message{}} {
+			runtime.PopulateQueryParameters(msg, values, filter)
+		}
+	})
+}
@@ -608,3 +608,44 @@ func TestPopulateQueryParametersWithInvalidNestedParameters(t *testing.T) {
 		}
 	}
 }
+
+func TestTimeEncodingQueryParser(t *testing.T) {
+	timePb, err := ptypes.TimestampProto(time.Unix(1600000000, 500000000))
+	if err != nil {
+		t.Fatalf("ptypes.TimestampProto failed with %v; want success", err)
+	}
+	durationPb := ptypes.DurationProto(-1500 * time.Millisecond)
+	want := &examplepb.Proto3Message{TimestampValue: timePb, DurationValue: durationPb}
+	for _, spec := range []struct {
+		encoding runtime.TimeEncoding
+		values   url.Values
+	}{
+		{
+			encoding: runtime.TimeEncoding{Timestamp: runtime.TimestampUnixSeconds, Duration: runtime.DurationSeconds},
+			values:   url.Values{"timestamp_value": {"1600000000.5"}, "duration_value": {"-1.5"}},
+		},
+		{
+			encoding: runtime.TimeEncoding{Timestamp: runtime.TimestampUnixMillis, Duration: runtime.DurationMillis},
+			values:   url.Values{"timestamp_value": {"1600000000500"}, "duration_value": {"-1500"}},
+		},
+		{
+			encoding: runtime.TimeEncoding{Timestamp: runtime.TimestampLayout, Layout: "2006-01-02 15:04:05.000"},
+			values:   url.Values{"timestamp_value": {"2020-09-13 12:26:40.500"}, "duration_value": {"-1.5s"}},
+		},
+		{
+			// The default encodings are accepted too.
+			encoding: runtime.TimeEncoding{Timestamp: runtime.TimestampUnixMillis, Duration: runtime.DurationMillis},
+			values:   url.Values{"timestamp_value": {"2020-09-13T12:26:40.5Z"}, "duration_value": {"-1.5s"}},
+		},
+	} {
+		parser := &runtime.TimeEncodingQueryParser{TimeEncoding: spec.encoding}
+		msg := new(examplepb.Proto3Message)
+		if err := parser.Parse(msg, spec.values, utilities.NewDoubleArray(nil)); err != nil {
+			t.Errorf("parser.Parse(msg, %v) failed with %v; want success", spec.values, err)
+			continue
+		}
+		if !proto.Equal(msg, want) {
+			t.Errorf("parser.Parse(msg, %v): msg = %v; want %v", spec.values, msg, want)
+		}
+	}
+}
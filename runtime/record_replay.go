@@ -0,0 +1,365 @@
+package runtime
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// defaultRecordedBodySize is the default maximum size of the recorded bodies.
+const defaultRecordedBodySize = 64 << 10
+
+// RecordedExchange is a request served by a ServeMux and its response, as
+// written by a Recorder. Recordings are JSON Lines of RecordedExchanges.
+type RecordedExchange struct {
+	Time time.Time `json:"time"`
+	// Method and URL are those of the request as received, before method
+	// overrides. URL is the request URI, the path and the query.
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestHeader  http.Header `json:"requestHeader,omitempty"`
+	RequestBody    []byte      `json:"requestBody,omitempty"`
+	Status         int         `json:"status"`
+	ResponseHeader http.Header `json:"responseHeader,omitempty"`
+	ResponseBody   []byte      `json:"responseBody,omitempty"`
+	// Truncated reports that a body was cut at the maximum body size of the
+	// Recorder.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Recorder writes the requests served by a ServeMux and their responses to a
+// recording, see WithRecorder and Replayer. Request bodies are recorded as
+// read by the handlers, so the bodies of the requests failing before their
+// handlers read them are empty. WebSocket upgrades are not recorded.
+// A Recorder is safe for concurrent use.
+type Recorder struct {
+	mu          sync.Mutex
+	enc         *json.Encoder
+	err         error
+	redactor    *Redactor
+	maxBodySize int
+	filter      func(*http.Request) bool
+}
+
+// RecorderOption configures a Recorder.
+type RecorderOption func(*Recorder)
+
+// WithRecorderRedactor redacts the recorded headers with the headers of "r",
+// and the recorded bodies with its patterns. It defaults to the Redactor of
+// the ServeMux, see ServeMux.Redactor.
+func WithRecorderRedactor(r *Redactor) RecorderOption {
+	return func(rec *Recorder) {
+		rec.redactor = r
+	}
+}
+
+// WithRecorderMaxBodySize limits the recorded bodies to "size" bytes. It
+// defaults to 64KiB.
+func WithRecorderMaxBodySize(size int) RecorderOption {
+	return func(rec *Recorder) {
+		rec.maxBodySize = size
+	}
+}
+
+// WithRecorderFilter only records the requests for which "filter" returns
+// true.
+func WithRecorderFilter(filter func(*http.Request) bool) RecorderOption {
+	return func(rec *Recorder) {
+		rec.filter = filter
+	}
+}
+
+// NewRecorder returns a Recorder writing to "w".
+func NewRecorder(w io.Writer, opts ...RecorderOption) *Recorder {
+	rec := &Recorder{enc: json.NewEncoder(w), maxBodySize: defaultRecordedBodySize}
+	for _, opt := range opts {
+		opt(rec)
+	}
+	return rec
+}
+
+// Err returns the first error writing the recording, if any.
+func (rec *Recorder) Err() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.err
+}
+
+// WithRecorder returns a ServeMuxOption which records the requests served by
+// the ServeMux, and their responses, with "rec".
+func WithRecorder(rec *Recorder) ServeMuxOption {
+	return func(serveMux *ServeMux) {
+		serveMux.recorder = rec
+	}
+}
+
+// startRecording starts recording "r" if it is to be recorded, and returns
+// the ResponseWriter recording the response, or nil.
+func (rec *Recorder) startRecording(w http.ResponseWriter, r *http.Request) *recordingResponseWriter {
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || (rec.filter != nil && !rec.filter(r)) {
+		return nil
+	}
+	rw := &recordingResponseWriter{
+		ResponseWriter: w,
+		exchange: RecordedExchange{
+			Time:          time.Now(),
+			Method:        r.Method,
+			URL:           r.URL.RequestURI(),
+			RequestHeader: r.Header.Clone(),
+		},
+		max: rec.maxBodySize,
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		rw.reqBody = &recordingBody{ReadCloser: r.Body, max: rec.maxBodySize}
+		r.Body = rw.reqBody
+	}
+	return rw
+}
+
+// finishRecording writes the exchange recorded by "rw".
+func (rec *Recorder) finishRecording(s *ServeMux, rw *recordingResponseWriter) {
+	redactor := rec.redactor
+	if redactor == nil {
+		redactor = s.Redactor()
+	}
+	ex := rw.exchange
+	ex.RequestHeader = redactor.Header(ex.RequestHeader)
+	if rw.reqBody != nil {
+		ex.RequestBody = []byte(redactor.String(rw.reqBody.buf.String()))
+		ex.Truncated = rw.reqBody.truncated
+	}
+	ex.Status = rw.status
+	if ex.Status == 0 {
+		ex.Status = http.StatusOK
+	}
+	header := rw.header
+	if header == nil {
+		header = rw.Header()
+	}
+	ex.ResponseHeader = redactor.Header(header)
+	ex.ResponseBody = []byte(redactor.String(rw.body.String()))
+	ex.Truncated = ex.Truncated || rw.truncated
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if err := rec.enc.Encode(&ex); err != nil {
+		if rec.err == nil {
+			rec.err = err
+		}
+		grpclog.Infof("Failed to record %s %s: %v", ex.Method, ex.URL, err)
+	}
+}
+
+// recordingBody records a request body as it is read.
+type recordingBody struct {
+	io.ReadCloser
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.truncated = appendLimited(&b.buf, p[:n], b.max) || b.truncated
+	return n, err
+}
+
+// recordingResponseWriter records a response as it is written.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	exchange  RecordedExchange
+	reqBody   *recordingBody
+	status    int
+	header    http.Header
+	body      bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (w *recordingResponseWriter) WriteHeader(code int) {
+	if w.status == 0 && !isInformational(code) {
+		w.status = code
+		w.header = w.ResponseWriter.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.truncated = appendLimited(&w.body, p, w.max) || w.truncated
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *recordingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// appendLimited appends p to buf up to "max" bytes, and reports whether p
+// was cut.
+func appendLimited(buf *bytes.Buffer, p []byte, max int) bool {
+	if room := max - buf.Len(); len(p) > room {
+		if room > 0 {
+			buf.Write(p[:room])
+		}
+		return true
+	}
+	buf.Write(p)
+	return false
+}
+
+// ReadRecording reads the exchanges of a recording written by a Recorder.
+func ReadRecording(r io.Reader) ([]RecordedExchange, error) {
+	var exchanges []RecordedExchange
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var ex RecordedExchange
+		if err := json.Unmarshal(scanner.Bytes(), &ex); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		exchanges = append(exchanges, ex)
+	}
+	return exchanges, scanner.Err()
+}
+
+// Replayer sends recorded requests to a handler, e.g. a ServeMux after
+// changes of its routes or marshalers, and compares its responses to the
+// recorded ones.
+type Replayer struct {
+	// Handler serves the replayed requests.
+	Handler http.Handler
+	// Header is set on the replayed requests, e.g. to replace the redacted
+	// credentials of the recording.
+	Header http.Header
+	// CompareHeaders are the names of the response headers compared in
+	// addition to the status codes and bodies.
+	CompareHeaders []string
+	// BodyEqual reports whether a replayed response body equals the recorded
+	// one. By default, JSON bodies are compared by value and other bodies
+	// byte by byte. Truncated recorded bodies are compared to the start of
+	// the replayed bodies.
+	BodyEqual func(recorded, replayed []byte) bool
+}
+
+// ReplayResult is the response of a replayed request which differs from the
+// recorded one.
+type ReplayResult struct {
+	Exchange RecordedExchange
+	// Status, Header and Body are those of the replayed response.
+	Status int
+	Header http.Header
+	Body   []byte
+	// Differences describe how the replayed response differs from the
+	// recorded one.
+	Differences []string
+}
+
+// Replay replays "exchanges", in their order, and returns the results of
+// the replayed requests whose responses differ from the recorded ones.
+func (p *Replayer) Replay(exchanges []RecordedExchange) ([]ReplayResult, error) {
+	var results []ReplayResult
+	for _, ex := range exchanges {
+		res, err := p.replay(ex)
+		if err != nil {
+			return results, fmt.Errorf("%s %s: %v", ex.Method, ex.URL, err)
+		}
+		if len(res.Differences) > 0 {
+			results = append(results, res)
+		}
+	}
+	return results, nil
+}
+
+func (p *Replayer) replay(ex RecordedExchange) (ReplayResult, error) {
+	req, err := http.NewRequest(ex.Method, ex.URL, bytes.NewReader(ex.RequestBody))
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	req.RequestURI = ex.URL
+	for name, values := range ex.RequestHeader {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	for name, values := range p.Header {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	w := &replayResponseWriter{header: make(http.Header)}
+	p.Handler.ServeHTTP(w, req)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	res := ReplayResult{Exchange: ex, Status: w.status, Header: w.header, Body: w.body.Bytes()}
+	if res.Status != ex.Status {
+		res.Differences = append(res.Differences, fmt.Sprintf("status %d, recorded %d", res.Status, ex.Status))
+	}
+	for _, name := range p.CompareHeaders {
+		got, want := res.Header.Values(name), ex.ResponseHeader.Values(name)
+		if strings.Join(got, ", ") != strings.Join(want, ", ") {
+			res.Differences = append(res.Differences, fmt.Sprintf("header %s %q, recorded %q", name, got, want))
+		}
+	}
+	body := res.Body
+	if ex.Truncated && len(body) > len(ex.ResponseBody) {
+		body = body[:len(ex.ResponseBody)]
+	}
+	equal := p.BodyEqual
+	if equal == nil {
+		equal = replayBodyEqual
+	}
+	if !equal(ex.ResponseBody, body) {
+		res.Differences = append(res.Differences, fmt.Sprintf("body %q, recorded %q", body, ex.ResponseBody))
+	}
+	return res, nil
+}
+
+func replayBodyEqual(recorded, replayed []byte) bool {
+	if bytes.Equal(recorded, replayed) {
+		return true
+	}
+	var a, b interface{}
+	if json.Unmarshal(recorded, &a) != nil || json.Unmarshal(replayed, &b) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return bytes.Equal(ja, jb)
+}
+
+// replayResponseWriter buffers the response of a replayed request.
+type replayResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *replayResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *replayResponseWriter) WriteHeader(code int) {
+	if w.status == 0 && !isInformational(code) {
+		w.status = code
+	}
+}
+
+func (w *replayResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+func (w *replayResponseWriter) Flush() {}
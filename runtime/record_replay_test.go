@@ -0,0 +1,129 @@
+package runtime_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func newRecordingTestMux(t *testing.T, greeting string, opts ...runtime.ServeMuxOption) *runtime.ServeMux {
+	mux := runtime.NewServeMux(opts...)
+	err := mux.HandlePath("POST", "/v1/users/{id}", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("ioutil.ReadAll(r.Body) failed with %v; want success", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"greeting": %q, "id": %q, "size": %d}`, greeting, pathParams["id"], len(body))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mux
+}
+
+func TestRecorder(t *testing.T) {
+	var recording bytes.Buffer
+	rec := runtime.NewRecorder(&recording, runtime.WithRecorderRedactor(runtime.NewRedactor(runtime.LogRedaction{
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`[a-z]+@example\.com`)},
+	})))
+	mux := newRecordingTestMux(t, "hello", runtime.WithRecorder(rec))
+
+	r := httptest.NewRequest("POST", "/v1/users/42?view=full", strings.NewReader(`{"email": "alice@example.com"}`))
+	r.Header.Set("Authorization", "Bearer secret")
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/unknown", nil))
+	if err := rec.Err(); err != nil {
+		t.Fatalf("rec.Err() = %v; want nil", err)
+	}
+
+	exchanges, err := runtime.ReadRecording(&recording)
+	if err != nil {
+		t.Fatalf("runtime.ReadRecording(...) failed with %v; want success", err)
+	}
+	if len(exchanges) != 2 {
+		t.Fatalf("runtime.ReadRecording(...) = %v; want 2 exchanges", exchanges)
+	}
+	ex := exchanges[0]
+	if ex.Method != "POST" || ex.URL != "/v1/users/42?view=full" || ex.Status != http.StatusOK {
+		t.Errorf("exchange = %s %s %d; want POST /v1/users/42?view=full 200", ex.Method, ex.URL, ex.Status)
+	}
+	if got, want := ex.RequestHeader.Get("Authorization"), "***"; got != want {
+		t.Errorf("recorded Authorization = %q; want %q", got, want)
+	}
+	if got, want := string(ex.RequestBody), `{"email": "***"}`; got != want {
+		t.Errorf("recorded request body = %q; want %q", got, want)
+	}
+	if got, want := string(ex.ResponseBody), `{"greeting": "hello", "id": "42", "size": 30}`; got != want {
+		t.Errorf("recorded response body = %q; want %q", got, want)
+	}
+	if got, want := ex.ResponseHeader.Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("recorded Content-Type = %q; want %q", got, want)
+	}
+	if got := exchanges[1].Status; got != http.StatusNotFound {
+		t.Errorf("recorded status = %d; want %d", got, http.StatusNotFound)
+	}
+}
+
+func TestRecorderTruncatesBodies(t *testing.T) {
+	var recording bytes.Buffer
+	rec := runtime.NewRecorder(&recording, runtime.WithRecorderMaxBodySize(8))
+	mux := newRecordingTestMux(t, "hello", runtime.WithRecorder(rec))
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/v1/users/42", strings.NewReader("0123456789")))
+
+	exchanges, err := runtime.ReadRecording(&recording)
+	if err != nil || len(exchanges) != 1 {
+		t.Fatalf("runtime.ReadRecording(...) = %v, %v; want 1 exchange", exchanges, err)
+	}
+	ex := exchanges[0]
+	if !ex.Truncated || string(ex.RequestBody) != "01234567" || len(ex.ResponseBody) != 8 {
+		t.Errorf("exchange = %+v; want bodies truncated to 8 bytes", ex)
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	var recording bytes.Buffer
+	rec := runtime.NewRecorder(&recording)
+	mux := newRecordingTestMux(t, "hello", runtime.WithRecorder(rec))
+	for _, path := range []string{"/v1/users/1", "/v1/users/2"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", path, strings.NewReader(`{}`)))
+	}
+	exchanges, err := runtime.ReadRecording(&recording)
+	if err != nil {
+		t.Fatalf("runtime.ReadRecording(...) failed with %v; want success", err)
+	}
+
+	replayer := &runtime.Replayer{Handler: newRecordingTestMux(t, "hello"), CompareHeaders: []string{"Content-Type"}}
+	results, err := replayer.Replay(exchanges)
+	if err != nil {
+		t.Fatalf("replayer.Replay(...) failed with %v; want success", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("replayer.Replay(...) = %+v; want no differences", results)
+	}
+
+	replayer.Handler = newRecordingTestMux(t, "hi")
+	results, err = replayer.Replay(exchanges)
+	if err != nil {
+		t.Fatalf("replayer.Replay(...) failed with %v; want success", err)
+	}
+	if len(results) != 2 || !strings.HasPrefix(results[0].Differences[0], "body ") {
+		t.Errorf("replayer.Replay(...) = %+v; want body differences", results)
+	}
+
+	replayer.Handler = runtime.NewServeMux()
+	results, err = replayer.Replay(exchanges[:1])
+	if err != nil {
+		t.Fatalf("replayer.Replay(...) failed with %v; want success", err)
+	}
+	if len(results) != 1 || results[0].Differences[0] != "status 404, recorded 200" {
+		t.Errorf("replayer.Replay(...) = %+v; want a status difference", results)
+	}
+}
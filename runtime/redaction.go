@@ -0,0 +1,152 @@
+package runtime
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// RedactionMode is how ResponseRedaction redacts a field.
+type RedactionMode int
+
+const (
+	// RedactOmit clears redacted fields, so they are omitted from responses
+	// or rendered with their default value.
+	RedactOmit RedactionMode = iota
+	// RedactMask replaces the value of redacted string and bytes fields with
+	// ResponseRedaction.Mask. Redacted fields of other kinds are cleared.
+	RedactMask
+)
+
+// ResponseRedaction configures the fields which are redacted from HTTP
+// responses, see WithResponseRedaction.
+type ResponseRedaction struct {
+	// Fields are the full names of the redacted fields, e.g. "example.User.email".
+	Fields []protoreflect.FullName
+	// Redact reports whether a field is redacted, in addition to Fields. It
+	// can be used to redact fields annotated with a custom field option:
+	//
+	//	Redact: func(fd protoreflect.FieldDescriptor) bool {
+	//		return proto.GetExtension(fd.Options(), gatewaypb.E_Redact).(bool)
+	//	},
+	Redact func(protoreflect.FieldDescriptor) bool
+	// Mode is how redacted fields are redacted. It defaults to RedactOmit.
+	Mode RedactionMode
+	// Mask replaces redacted string and bytes fields with RedactMask. It
+	// defaults to "***".
+	Mask string
+}
+
+// WithResponseRedaction returns a ServeMuxOption which redacts fields from the
+// messages forwarded to HTTP clients, in unary responses as well as in server
+// streams. Redaction applies to fields of nested messages, including the
+// elements of repeated and map fields and the messages packed in
+// google.protobuf.Any fields; the responses of the gRPC server are left
+// untouched. Any fields whose type is not linked into the binary cannot be
+// inspected, and are forwarded as is.
+func WithResponseRedaction(redaction ResponseRedaction) ServeMuxOption {
+	return func(serveMux *ServeMux) {
+		if redaction.Mask == "" {
+			redaction.Mask = "***"
+		}
+		fields := make(map[protoreflect.FullName]bool, len(redaction.Fields))
+		for _, name := range redaction.Fields {
+			fields[name] = true
+		}
+		serveMux.responseRedaction = &responseRedactor{ResponseRedaction: redaction, fields: fields}
+	}
+}
+
+type responseRedactor struct {
+	ResponseRedaction
+	fields map[protoreflect.FullName]bool
+}
+
+// redact returns a copy of v with the redacted fields redacted. Values which
+// are not messages are returned as is.
+func (r *responseRedactor) redact(v interface{}) interface{} {
+	if r == nil {
+		return v
+	}
+	m, ok := v.(proto.Message)
+	if !ok {
+		return v
+	}
+	m = proto.Clone(m)
+	r.redactMessage(m.ProtoReflect())
+	return m
+}
+
+func (r *responseRedactor) redacted(fd protoreflect.FieldDescriptor) bool {
+	return r.fields[fd.FullName()] || r.Redact != nil && r.Redact(fd)
+}
+
+func (r *responseRedactor) redactMessage(msg protoreflect.Message) {
+	if a, ok := msg.Interface().(*anypb.Any); ok {
+		r.redactAny(a)
+		return
+	}
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case r.redacted(fd):
+			r.redactField(msg, fd)
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				break
+			}
+			v.Map().Range(func(_ protoreflect.MapKey, item protoreflect.Value) bool {
+				r.redactMessage(item.Message())
+				return true
+			})
+		case fd.IsList():
+			if fd.Message() == nil {
+				break
+			}
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				r.redactMessage(list.Get(i).Message())
+			}
+		case fd.Message() != nil:
+			r.redactMessage(v.Message())
+		}
+		return true
+	})
+}
+
+// redactAny redacts the message packed in "a", if its type is linked into the
+// binary.
+func (r *responseRedactor) redactAny(a *anypb.Any) {
+	m, err := a.UnmarshalNew()
+	if err != nil {
+		return
+	}
+	r.redactMessage(m.ProtoReflect())
+	if value, err := proto.Marshal(m); err == nil {
+		a.Value = value
+	}
+}
+
+func (r *responseRedactor) redactField(msg protoreflect.Message, fd protoreflect.FieldDescriptor) {
+	if r.Mode != RedactMask || fd.IsMap() {
+		msg.Clear(fd)
+		return
+	}
+	var mask protoreflect.Value
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		mask = protoreflect.ValueOfString(r.Mask)
+	case protoreflect.BytesKind:
+		mask = protoreflect.ValueOfBytes([]byte(r.Mask))
+	default:
+		msg.Clear(fd)
+		return
+	}
+	if !fd.IsList() {
+		msg.Set(fd, mask)
+		return
+	}
+	list := msg.Mutable(fd).List()
+	for i := 0; i < list.Len(); i++ {
+		list.Set(i, mask)
+	}
+}
@@ -0,0 +1,139 @@
+package runtime_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestWithResponseRedaction(t *testing.T) {
+	newMessage := func() *examplepb.ABitOfEverything {
+		return &examplepb.ABitOfEverything{
+			Uuid:                "6EC2446F-7E89-4127-B3E6-5C05E6BECBA7",
+			StringValue:         "secret",
+			BytesValue:          []byte("secret"),
+			Int64Value:          42,
+			RepeatedStringValue: []string{"a", "b"},
+			SingleNested:        &examplepb.ABitOfEverything_Nested{Name: "foo", Amount: 10},
+			Nested: []*examplepb.ABitOfEverything_Nested{
+				{Name: "bar", Amount: 20},
+			},
+			MappedNestedValue: map[string]*examplepb.ABitOfEverything_Nested{
+				"a": {Name: "baz", Amount: 30},
+			},
+		}
+	}
+	redactedFields := []protoreflect.FullName{
+		"grpc.gateway.runtime.internal.examplepb.ABitOfEverything.string_value",
+		"grpc.gateway.runtime.internal.examplepb.ABitOfEverything.bytes_value",
+		"grpc.gateway.runtime.internal.examplepb.ABitOfEverything.int64_value",
+		"grpc.gateway.runtime.internal.examplepb.ABitOfEverything.repeated_string_value",
+	}
+	redactNestedName := func(fd protoreflect.FieldDescriptor) bool {
+		return fd.FullName() == "grpc.gateway.runtime.internal.examplepb.ABitOfEverything.Nested.name"
+	}
+
+	for _, spec := range []struct {
+		name      string
+		redaction runtime.ResponseRedaction
+		want      *examplepb.ABitOfEverything
+	}{
+		{
+			name:      "omit",
+			redaction: runtime.ResponseRedaction{Fields: redactedFields, Redact: redactNestedName},
+			want: &examplepb.ABitOfEverything{
+				Uuid:         "6EC2446F-7E89-4127-B3E6-5C05E6BECBA7",
+				SingleNested: &examplepb.ABitOfEverything_Nested{Amount: 10},
+				Nested: []*examplepb.ABitOfEverything_Nested{
+					{Amount: 20},
+				},
+				MappedNestedValue: map[string]*examplepb.ABitOfEverything_Nested{
+					"a": {Amount: 30},
+				},
+			},
+		},
+		{
+			name: "mask",
+			redaction: runtime.ResponseRedaction{
+				Fields: redactedFields,
+				Redact: redactNestedName,
+				Mode:   runtime.RedactMask,
+			},
+			want: &examplepb.ABitOfEverything{
+				Uuid:                "6EC2446F-7E89-4127-B3E6-5C05E6BECBA7",
+				StringValue:         "***",
+				BytesValue:          []byte("***"),
+				RepeatedStringValue: []string{"***", "***"},
+				SingleNested:        &examplepb.ABitOfEverything_Nested{Name: "***", Amount: 10},
+				Nested: []*examplepb.ABitOfEverything_Nested{
+					{Name: "***", Amount: 20},
+				},
+				MappedNestedValue: map[string]*examplepb.ABitOfEverything_Nested{
+					"a": {Name: "***", Amount: 30},
+				},
+			},
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			mux := runtime.NewServeMux(runtime.WithResponseRedaction(spec.redaction))
+			req := httptest.NewRequest("GET", "/v1/resource", nil)
+			w := httptest.NewRecorder()
+			ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+			_, marshaler := runtime.MarshalerForRequest(mux, req)
+			msg := newMessage()
+			runtime.ForwardResponseMessage(ctx, mux, marshaler, w, req, msg)
+
+			got := new(examplepb.ABitOfEverything)
+			if err := protojson.Unmarshal(w.Body.Bytes(), got); err != nil {
+				t.Fatalf("protojson.Unmarshal(%s) failed with %v; want success", w.Body, err)
+			}
+			if diff := cmp.Diff(got, spec.want, protocmp.Transform()); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(msg, newMessage(), protocmp.Transform()); diff != "" {
+				t.Errorf("response message was modified: %s", diff)
+			}
+		})
+	}
+}
+
+func TestWithResponseRedactionAny(t *testing.T) {
+	newMessage := func(detail string) *statuspb.Status {
+		debugInfo, err := anypb.New(&errdetails.DebugInfo{Detail: detail, StackEntries: []string{"main.go:1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &statuspb.Status{Code: 3, Details: []*anypb.Any{debugInfo}}
+	}
+	mux := runtime.NewServeMux(runtime.WithResponseRedaction(runtime.ResponseRedaction{
+		Fields: []protoreflect.FullName{"google.rpc.DebugInfo.detail"},
+		Mode:   runtime.RedactMask,
+	}))
+	req := httptest.NewRequest("GET", "/v1/resource", nil)
+	w := httptest.NewRecorder()
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	_, marshaler := runtime.MarshalerForRequest(mux, req)
+	msg := newMessage("token=secret")
+	runtime.ForwardResponseMessage(ctx, mux, marshaler, w, req, msg)
+
+	got := new(statuspb.Status)
+	if err := protojson.Unmarshal(w.Body.Bytes(), got); err != nil {
+		t.Fatalf("protojson.Unmarshal(%s) failed with %v; want success", w.Body, err)
+	}
+	if diff := cmp.Diff(got, newMessage("***"), protocmp.Transform()); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff(msg, newMessage("token=secret"), protocmp.Transform()); diff != "" {
+		t.Errorf("response message was modified: %s", diff)
+	}
+}
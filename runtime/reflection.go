@@ -0,0 +1,338 @@
+package runtime
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/grpclog"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// WithReflectionPath returns a ServeMuxOption making a ServeMuxDynamic serve
+// the descriptors of its services under "path", e.g. "/$reflection", so that
+// API explorers can introspect them through the gateway:
+//
+//   - GET <path>/services replies a grpc.reflection.v1alpha.ListServiceResponse
+//     listing the services,
+//   - GET <path>/file?symbol=<name> replies a google.protobuf.FileDescriptorSet
+//     of the file defining the fully-qualified symbol <name>, e.g. a service,
+//     method or message, and of its dependencies,
+//   - GET <path>/file?filename=<path> replies the same for the file <path>.
+//
+// The descriptors are those of the services registered with
+// ServeMuxDynamic.RegisterReflectionService, then those served by the
+// upstreams registered with ServeMuxDynamic.RegisterReflectionUpstream. The
+// responses are marshaled with the outbound marshaler of the requests, and
+// the requests are authenticated by the authenticators of the mux, e.g.
+// WithJWTAuth or WithIPFilter. It has no effect on a ServeMux.
+func WithReflectionPath(path string) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.reflectionPath = strings.TrimSuffix(path, "/")
+	}
+}
+
+// reflectionSources holds the sources of the descriptors served by the
+// reflection endpoint of a ServeMuxDynamic.
+type reflectionSources struct {
+	mu        sync.Mutex
+	lastID    uint64
+	services  []reflectionService
+	upstreams []reflectionUpstream
+}
+
+type reflectionService struct {
+	id uint64
+	sd protoreflect.ServiceDescriptor
+}
+
+type reflectionUpstream struct {
+	id uint64
+	cc grpc.ClientConnInterface
+}
+
+// RegisterReflectionService adds "sd" to the services whose descriptors are
+// served at the path given with WithReflectionPath. It returns a function
+// deregistering the service.
+func (s *ServeMuxDynamic) RegisterReflectionService(sd protoreflect.ServiceDescriptor) (deregister func()) {
+	s.reflection.mu.Lock()
+	defer s.reflection.mu.Unlock()
+
+	s.reflection.lastID++
+	id := s.reflection.lastID
+	s.reflection.services = append(s.reflection.services, reflectionService{id: id, sd: sd})
+
+	return func() {
+		s.reflection.mu.Lock()
+		defer s.reflection.mu.Unlock()
+
+		for i, svc := range s.reflection.services {
+			if svc.id == id {
+				s.reflection.services = append(s.reflection.services[:i:i], s.reflection.services[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// RegisterReflectionUpstream adds the upstream gRPC server of "cc", which
+// serves the gRPC server reflection service, to the sources of the
+// descriptors served at the path given with WithReflectionPath. It returns a
+// function deregistering the upstream.
+func (s *ServeMuxDynamic) RegisterReflectionUpstream(cc grpc.ClientConnInterface) (deregister func()) {
+	s.reflection.mu.Lock()
+	defer s.reflection.mu.Unlock()
+
+	s.reflection.lastID++
+	id := s.reflection.lastID
+	s.reflection.upstreams = append(s.reflection.upstreams, reflectionUpstream{id: id, cc: cc})
+
+	return func() {
+		s.reflection.mu.Lock()
+		defer s.reflection.mu.Unlock()
+
+		for i, u := range s.reflection.upstreams {
+			if u.id == id {
+				s.reflection.upstreams = append(s.reflection.upstreams[:i:i], s.reflection.upstreams[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// reflectionSnapshot returns the currently registered sources.
+func (s *ServeMuxDynamic) reflectionSnapshot() ([]protoreflect.ServiceDescriptor, []grpc.ClientConnInterface) {
+	s.reflection.mu.Lock()
+	defer s.reflection.mu.Unlock()
+
+	services := make([]protoreflect.ServiceDescriptor, len(s.reflection.services))
+	for i, svc := range s.reflection.services {
+		services[i] = svc.sd
+	}
+	upstreams := make([]grpc.ClientConnInterface, len(s.reflection.upstreams))
+	for i, u := range s.reflection.upstreams {
+		upstreams[i] = u.cc
+	}
+	return services, upstreams
+}
+
+// serveReflection serves the request to "endpoint", e.g. "services", of the
+// reflection endpoint of s.
+func (s *ServeMuxDynamic) serveReflection(w http.ResponseWriter, r *http.Request, endpoint string) {
+	ctx := r.Context()
+	_, outboundMarshaler := MarshalerForRequest(s.ServeMux, r)
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		s.routingErrorHandler(ctx, s.ServeMux, outboundMarshaler, w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	r, ok := s.authenticate(w, r, handler{})
+	if !ok {
+		return
+	}
+	ctx = r.Context()
+
+	var resp proto.Message
+	var err error
+	switch endpoint {
+	case "services":
+		resp, err = s.reflectionServices(ctx)
+	case "file":
+		q := r.URL.Query()
+		switch symbol, filename := q.Get("symbol"), q.Get("filename"); {
+		case symbol != "":
+			resp, err = s.reflectionFile(ctx, &rpb.ServerReflectionRequest{
+				MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol},
+			})
+		case filename != "":
+			resp, err = s.reflectionFile(ctx, &rpb.ServerReflectionRequest{
+				MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: filename},
+			})
+		default:
+			err = status.Error(codes.InvalidArgument, "either symbol or filename is required")
+		}
+	default:
+		s.routingErrorHandler(ctx, s.ServeMux, outboundMarshaler, w, r, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.errorHandler(ctx, s.ServeMux, outboundMarshaler, w, r, err)
+		return
+	}
+
+	buf, err := outboundMarshaler.Marshal(resp)
+	if err != nil {
+		s.errorHandler(ctx, s.ServeMux, outboundMarshaler, w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", outboundMarshaler.ContentType(resp))
+	if _, err := w.Write(buf); err != nil {
+		grpclog.Infof("Failed to write reflection response: %v", err)
+	}
+}
+
+// reflectionServices returns the services of the registered sources.
+func (s *ServeMuxDynamic) reflectionServices(ctx context.Context) (*rpb.ListServiceResponse, error) {
+	services, upstreams := s.reflectionSnapshot()
+	names := make(map[string]bool)
+	for _, sd := range services {
+		names[string(sd.FullName())] = true
+	}
+	for _, cc := range upstreams {
+		resp, err := callReflection(ctx, cc, &rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, svc := range resp.GetListServicesResponse().GetService() {
+			names[svc.GetName()] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	resp := &rpb.ListServiceResponse{Service: make([]*rpb.ServiceResponse, len(sorted))}
+	for i, name := range sorted {
+		resp.Service[i] = &rpb.ServiceResponse{Name: name}
+	}
+	return resp, nil
+}
+
+// reflectionFile returns the file of "req", a request of a file by symbol or
+// filename, and its dependencies, from the first source which has it.
+func (s *ServeMuxDynamic) reflectionFile(ctx context.Context, req *rpb.ServerReflectionRequest) (*descriptorpb.FileDescriptorSet, error) {
+	services, upstreams := s.reflectionSnapshot()
+	if fd := findReflectionFile(services, req); fd != nil {
+		return fileDescriptorSet(fd), nil
+	}
+
+	for _, cc := range upstreams {
+		resp, err := callReflection(ctx, cc, req)
+		if status.Code(err) == codes.NotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		set := &descriptorpb.FileDescriptorSet{}
+		for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			file := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(raw, file); err != nil {
+				return nil, status.Errorf(codes.Internal, "invalid file descriptor from upstream: %v", err)
+			}
+			set.File = append(set.File, file)
+		}
+		return set, nil
+	}
+
+	switch m := req.MessageRequest.(type) {
+	case *rpb.ServerReflectionRequest_FileContainingSymbol:
+		return nil, status.Errorf(codes.NotFound, "symbol %q not found", m.FileContainingSymbol)
+	case *rpb.ServerReflectionRequest_FileByFilename:
+		return nil, status.Errorf(codes.NotFound, "file %q not found", m.FileByFilename)
+	}
+	return nil, status.Error(codes.InvalidArgument, "invalid reflection request")
+}
+
+// findReflectionFile returns the file of "req" among the files of "services"
+// and their dependencies, or nil.
+func findReflectionFile(services []protoreflect.ServiceDescriptor, req *rpb.ServerReflectionRequest) protoreflect.FileDescriptor {
+	files := new(protoregistry.Files)
+	var register func(fd protoreflect.FileDescriptor)
+	register = func(fd protoreflect.FileDescriptor) {
+		if _, err := files.FindFileByPath(fd.Path()); err == nil {
+			return
+		}
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			register(imports.Get(i).FileDescriptor)
+		}
+		if err := files.RegisterFile(fd); err != nil {
+			grpclog.Infof("Failed to register %s for reflection: %v", fd.Path(), err)
+		}
+	}
+	for _, sd := range services {
+		register(sd.ParentFile())
+	}
+
+	switch m := req.MessageRequest.(type) {
+	case *rpb.ServerReflectionRequest_FileContainingSymbol:
+		if d, err := files.FindDescriptorByName(protoreflect.FullName(m.FileContainingSymbol)); err == nil {
+			return d.ParentFile()
+		}
+	case *rpb.ServerReflectionRequest_FileByFilename:
+		if fd, err := files.FindFileByPath(m.FileByFilename); err == nil {
+			return fd
+		}
+	}
+	return nil
+}
+
+// fileDescriptorSet returns the set of "fd" and its dependencies, with the
+// dependencies first.
+func fileDescriptorSet(fd protoreflect.FileDescriptor) *descriptorpb.FileDescriptorSet {
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	add(fd)
+	return set
+}
+
+// callReflection sends "req" to the reflection service of the upstream of
+// "cc", and returns its response. Error responses are returned as errors.
+func callReflection(ctx context.Context, cc grpc.ClientConnInterface, req *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := rpb.NewServerReflectionClient(cc).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(req); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if e := resp.GetErrorResponse(); e != nil {
+		return nil, status.Error(codes.Code(e.GetErrorCode()), e.GetErrorMessage())
+	}
+	return resp, nil
+}
+
+// reflectionEndpoint returns the endpoint of the reflection path of s that
+// "path" is a request to, and whether it is one.
+func (s *ServeMux) reflectionEndpoint(path string) (string, bool) {
+	if s.reflectionPath == "" || !strings.HasPrefix(path, s.reflectionPath+"/") {
+		return "", false
+	}
+	return path[len(s.reflectionPath)+1:], true
+}
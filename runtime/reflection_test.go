@@ -0,0 +1,137 @@
+package runtime_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestReflection(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	reflection.Register(srv)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	file, err := protodesc.NewFile(newLibraryFile(t), protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile(...) failed with %v; want success", err)
+	}
+	mux := runtime.NewServeMuxDynamic(runtime.WithReflectionPath("/$reflection"))
+	deregister := mux.RegisterReflectionService(file.Services().Get(0))
+	mux.RegisterReflectionUpstream(conn)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	files := func(w *httptest.ResponseRecorder) []string {
+		t.Helper()
+		set := &descriptorpb.FileDescriptorSet{}
+		if err := protojson.Unmarshal(w.Body.Bytes(), set); err != nil {
+			t.Fatalf("protojson.Unmarshal(%s) failed with %v; want success", w.Body, err)
+		}
+		var names []string
+		for _, f := range set.GetFile() {
+			names = append(names, f.GetName())
+		}
+		return names
+	}
+
+	w := get("/$reflection/services")
+	var services struct {
+		Service []struct {
+			Name string `json:"name"`
+		} `json:"service"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &services); err != nil {
+		t.Fatalf("json.Unmarshal(%s) failed with %v; want success", w.Body, err)
+	}
+	var names []string
+	for _, svc := range services.Service {
+		names = append(names, svc.Name)
+	}
+	want := []string{"example.library.LibraryService", "grpc.health.v1.Health", "grpc.reflection.v1alpha.ServerReflection"}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Errorf("services differ (-want +got):\n%s", diff)
+	}
+
+	for _, spec := range []struct {
+		path string
+		want []string
+	}{
+		{
+			path: "/$reflection/file?symbol=example.library.LibraryService.GetBook",
+			want: []string{"google/protobuf/empty.proto", "library.proto"},
+		},
+		{
+			path: "/$reflection/file?filename=library.proto",
+			want: []string{"google/protobuf/empty.proto", "library.proto"},
+		},
+		{
+			path: "/$reflection/file?symbol=grpc.health.v1.HealthCheckRequest",
+			want: []string{"grpc/health/v1/health.proto"},
+		},
+	} {
+		w := get(spec.path)
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s = %d; want %d", spec.path, w.Code, http.StatusOK)
+			continue
+		}
+		if diff := cmp.Diff(spec.want, files(w)); diff != "" {
+			t.Errorf("GET %s files differ (-want +got):\n%s", spec.path, diff)
+		}
+	}
+
+	for path, code := range map[string]int{
+		"/$reflection/file?symbol=example.Unknown": http.StatusNotFound,
+		"/$reflection/file":                        http.StatusBadRequest,
+		"/$reflection/unknown":                     http.StatusNotFound,
+	} {
+		if w := get(path); w.Code != code {
+			t.Errorf("GET %s = %d; want %d", path, w.Code, code)
+		}
+	}
+
+	deregister()
+	if w := get("/$reflection/file?symbol=example.library.Book"); w.Code != http.StatusNotFound {
+		t.Errorf("GET of a deregistered symbol = %d; want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestReflectionAuthentication(t *testing.T) {
+	mux := runtime.NewServeMuxDynamic(
+		runtime.WithReflectionPath("/$reflection"),
+		runtime.WithIPFilter(runtime.IPFilterConfig{Deny: mustParseCIDRs(t, "192.0.2.0/24")}),
+	)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/$reflection/services", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("GET /$reflection/services from a denied client = %d; want %d", w.Code, http.StatusForbidden)
+	}
+}
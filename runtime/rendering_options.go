@@ -0,0 +1,84 @@
+package runtime
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Query parameters toggling the JSON rendering of a response, see
+// WithQueryRenderingOptions.
+const (
+	renderPrettyParam      = "pretty"
+	renderEnumAsIntParam   = "enum_as_int"
+	renderEmitDefaultParam = "emit_default"
+)
+
+// WithQueryRenderingOptions returns a ServeMuxOption which lets clients tune the
+// JSON rendering of a response with query parameters:
+//
+//	?pretty=true        indents the response
+//	?enum_as_int=true   renders enums as numbers
+//	?emit_default=true  renders fields with their default values
+//
+// Each parameter can also be set to false to turn the behavior off for a
+// request. The parameters only apply when the outbound marshaler is a JSONPb,
+// possibly wrapped by a HTTPBodyMarshaler; the marshaler registered on the mux
+// is copied, never modified. The parameters are still passed to the query
+// parameter parser: they are ignored if the request message has no field of
+// the same name, and populate it as well otherwise, so they should not be
+// enabled for services whose requests have such fields.
+func WithQueryRenderingOptions() ServeMuxOption {
+	return func(serveMux *ServeMux) {
+		serveMux.queryRenderingOptions = true
+	}
+}
+
+// renderingMarshaler returns a copy of m with the rendering options requested
+// by the query parameters of r applied, or m if there are none.
+func (s *ServeMux) renderingMarshaler(r *http.Request, m Marshaler) Marshaler {
+	if !s.queryRenderingOptions || r.URL == nil || r.URL.RawQuery == "" {
+		return m
+	}
+	switch m := m.(type) {
+	case *delimitedMarshaler:
+		return m.withMarshaler(s.renderingMarshaler(r, m.Marshaler))
+	case *JSONPb:
+		if j, ok := renderingJSONPb(r, m); ok {
+			return j
+		}
+	case *HTTPBodyMarshaler:
+		if inner, ok := m.Marshaler.(*JSONPb); ok {
+			if j, ok := renderingJSONPb(r, inner); ok {
+				return &HTTPBodyMarshaler{Marshaler: j}
+			}
+		}
+	}
+	return m
+}
+
+func renderingJSONPb(r *http.Request, m *JSONPb) (*JSONPb, bool) {
+	query := r.URL.Query()
+	j := *m
+	var changed bool
+	if pretty, ok := queryBool(query.Get(renderPrettyParam)); ok {
+		j.Multiline, changed = pretty, true
+		if pretty && j.Indent == "" {
+			j.Indent = "  "
+		}
+	}
+	if enumAsInt, ok := queryBool(query.Get(renderEnumAsIntParam)); ok {
+		j.UseEnumNumbers, changed = enumAsInt, true
+	}
+	if emitDefault, ok := queryBool(query.Get(renderEmitDefaultParam)); ok {
+		j.EmitUnpopulated, changed = emitDefault, true
+	}
+	return &j, changed
+}
+
+func queryBool(value string) (bool, bool) {
+	if value == "" {
+		return false, false
+	}
+	b, err := strconv.ParseBool(value)
+	return b, err == nil
+}
@@ -0,0 +1,77 @@
+package runtime_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+)
+
+func TestWithQueryRenderingOptions(t *testing.T) {
+	msg := &examplepb.ABitOfEverything{
+		Uuid:      "6EC2446F-7E89-4127-B3E6-5C05E6BECBA7",
+		EnumValue: examplepb.NumericEnum_ONE,
+	}
+	registered := &runtime.JSONPb{}
+	mux := runtime.NewServeMux(
+		runtime.WithQueryRenderingOptions(),
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, registered),
+	)
+
+	for _, spec := range []struct {
+		query string
+
+		wantPretty   bool
+		wantEnum     interface{}
+		wantDefaults bool
+	}{
+		{query: "", wantEnum: "ONE"},
+		{query: "?pretty=true", wantPretty: true, wantEnum: "ONE"},
+		{query: "?enum_as_int=true", wantEnum: float64(1)},
+		{query: "?emit_default=1&enum_as_int=false", wantEnum: "ONE", wantDefaults: true},
+		{query: "?pretty=invalid", wantEnum: "ONE"},
+	} {
+		r := httptest.NewRequest("GET", "/v1/resource"+spec.query, nil)
+		_, out := runtime.MarshalerForRequest(mux, r)
+		buf, err := out.Marshal(msg)
+		if err != nil {
+			t.Fatalf("out.Marshal(%v) failed with %v; want success", msg, err)
+		}
+		if got, want := bytes.Contains(buf, []byte("\n")), spec.wantPretty; got != want {
+			t.Errorf("%q: pretty = %v; want %v: %s", spec.query, got, want, buf)
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(buf, &fields); err != nil {
+			t.Fatalf("json.Unmarshal(%s) failed with %v; want success", buf, err)
+		}
+		if got, want := fields["enumValue"], spec.wantEnum; got != want {
+			t.Errorf("%q: enumValue = %#v; want %#v", spec.query, got, want)
+		}
+		if _, got := fields["singleNested"]; got != spec.wantDefaults {
+			t.Errorf("%q: emitted defaults = %v; want %v: %s", spec.query, got, spec.wantDefaults, buf)
+		}
+	}
+
+	if registered.Multiline || registered.UseEnumNumbers || registered.EmitUnpopulated {
+		t.Errorf("registered marshaler was modified: %#v", registered)
+	}
+}
+
+func TestWithQueryRenderingOptions_Disabled(t *testing.T) {
+	mux := runtime.NewServeMux()
+	r := httptest.NewRequest("GET", "/v1/resource?pretty=true", nil)
+	_, out := runtime.MarshalerForRequest(mux, r)
+	if _, ok := out.(*runtime.HTTPBodyMarshaler); !ok {
+		t.Fatalf("out = %#v; want a runtime.HTTPBodyMarshaler", out)
+	}
+	buf, err := out.Marshal(&examplepb.ABitOfEverything{Uuid: "foo"})
+	if err != nil {
+		t.Fatalf("out.Marshal failed with %v; want success", err)
+	}
+	if bytes.Contains(buf, []byte("\n")) {
+		t.Errorf("out.Marshal = %s; want compact output", buf)
+	}
+}
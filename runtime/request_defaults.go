@@ -0,0 +1,160 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// RequestDefaults holds the default values of the fields of request
+// messages, by the gRPC method they are sent to, e.g.
+// "/example.LibraryService/ListBooks", then by the path of the fields, e.g.
+// "page_size" or "filter.state". The values are parsed as query parameters
+// are.
+type RequestDefaults map[string]map[string]string
+
+// ParseRequestDefaults parses RequestDefaults from a JSON configuration file,
+// an object of the defaults of each method, e.g.
+//
+//	{"/example.LibraryService/ListBooks": {"page_size": 50, "view": "BASIC"}}
+//
+// Default values are strings, numbers or booleans.
+func ParseRequestDefaults(data []byte) (RequestDefaults, error) {
+	var raw map[string]map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid request defaults: %v", err)
+	}
+	defaults := make(RequestDefaults, len(raw))
+	for method, fields := range raw {
+		defaults[method] = make(map[string]string, len(fields))
+		for path, v := range fields {
+			switch v := v.(type) {
+			case string:
+				defaults[method][path] = v
+			case json.Number:
+				defaults[method][path] = v.String()
+			case bool:
+				defaults[method][path] = strconv.FormatBool(v)
+			default:
+				return nil, fmt.Errorf("invalid default of %s in %s: %v is not a string, number or boolean", path, method, v)
+			}
+		}
+	}
+	return defaults, nil
+}
+
+// WithRequestDefaults returns a ServeMuxOption setting the fields of the
+// requests to the methods of defaults to their default values, when they are
+// not set by the HTTP request. It applies to the handlers generated with
+// request_defaults.
+func WithRequestDefaults(defaults RequestDefaults) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.requestDefaults = defaults
+	}
+}
+
+// WithRouteRequestDefaults returns a RouteOption setting the fields of the
+// requests to this route to the values of defaults, by the path of the
+// fields, when they are not set by the HTTP request. They take precedence
+// over the defaults of WithRequestDefaults.
+func WithRouteRequestDefaults(defaults map[string]string) RouteOption {
+	return func(o *routeOptions) {
+		if o.requestDefaults == nil {
+			o.requestDefaults = make(map[string]string, len(defaults))
+		}
+		for path, v := range defaults {
+			o.requestDefaults[path] = v
+		}
+	}
+}
+
+type requestDefaultsKey struct{}
+
+func withRequestDefaults(ctx context.Context, defaults RequestDefaults) context.Context {
+	return context.WithValue(ctx, requestDefaultsKey{}, defaults)
+}
+
+// PopulateRequestDefaults sets the fields of msg which are unset, once it was
+// populated from the body, the path and the query parameters of the HTTP
+// request, to the defaults configured with WithRouteRequestDefaults and
+// WithRequestDefaults for the route and the method of ctx. A repeated or map
+// field is unset if it is empty. It is called by the handlers generated with
+// request_defaults.
+func PopulateRequestDefaults(ctx context.Context, msg proto.Message) error {
+	defaults := map[string]string{}
+	if method, ok := RPCMethod(ctx); ok {
+		mux, _ := ctx.Value(requestDefaultsKey{}).(RequestDefaults)
+		for path, v := range mux[method] {
+			defaults[path] = v
+		}
+	}
+	if o := routeOptionsFromContext(ctx); o != nil {
+		for path, v := range o.requestDefaults {
+			defaults[path] = v
+		}
+	}
+	if len(defaults) == 0 {
+		return nil
+	}
+
+	paths := make([]string, 0, len(defaults))
+	for path := range defaults {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	m := msg.ProtoReflect()
+	for _, path := range paths {
+		fieldPath := strings.Split(path, ".")
+		if fieldPathSet(m, fieldPath) {
+			continue
+		}
+		if err := populateFieldValueFromPath(m, fieldPath, []string{defaults[path]}, nil); err != nil {
+			return status.Errorf(codes.Internal, "invalid default of %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// fieldPathSet reports whether the field at fieldPath in m, or another field
+// of its oneof, is set.
+func fieldPathSet(m protoreflect.Message, fieldPath []string) bool {
+	for i, name := range fieldPath {
+		fields := m.Descriptor().Fields()
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = fields.ByJSONName(name)
+		}
+		if fd == nil {
+			return false
+		}
+		if i < len(fieldPath)-1 {
+			if fd.Message() == nil || fd.Cardinality() == protoreflect.Repeated || !m.Has(fd) {
+				return false
+			}
+			m = m.Get(fd).Message()
+			continue
+		}
+		switch {
+		case fd.IsList():
+			return m.Get(fd).List().Len() > 0
+		case fd.IsMap():
+			return m.Get(fd).Map().Len() > 0
+		}
+		if of := fd.ContainingOneof(); of != nil && m.WhichOneof(of) != nil {
+			return true
+		}
+		return m.Has(fd)
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package runtime_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	pb "github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestParseRequestDefaults(t *testing.T) {
+	got, err := runtime.ParseRequestDefaults([]byte(`{"/example.Example/List": {"page_size": 50, "view": "BASIC", "deleted": false}}`))
+	if err != nil {
+		t.Fatalf("runtime.ParseRequestDefaults(...) failed with %v; want success", err)
+	}
+	want := runtime.RequestDefaults{"/example.Example/List": {"page_size": "50", "view": "BASIC", "deleted": "false"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("defaults differ (-want +got):\n%s", diff)
+	}
+
+	for _, data := range []string{`[]`, `{"/example.Example/List": {"page_size": [1]}}`} {
+		if _, err := runtime.ParseRequestDefaults([]byte(data)); err == nil {
+			t.Errorf("runtime.ParseRequestDefaults(%s) succeeded; want failure", data)
+		}
+	}
+}
+
+func TestPopulateRequestDefaults(t *testing.T) {
+	defaults, err := runtime.ParseRequestDefaults([]byte(`{
+		"/example.Example/List": {
+			"int32_value": 50,
+			"stringValue": "all",
+			"nested.bool_value": true,
+			"repeated_value": "a"
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := runtime.NewServeMuxDynamic(runtime.WithRequestDefaults(defaults))
+
+	var populate func(ctx context.Context) error
+	h := func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		ctx, err := runtime.AnnotateContext(r.Context(), mux.ServeMux, r, "/example.Example/List")
+		if err != nil {
+			t.Fatalf("runtime.AnnotateContext() failed with %v; want success", err)
+		}
+		if err := populate(ctx); err != nil {
+			runtime.HTTPError(ctx, mux.ServeMux, &runtime.JSONPb{}, w, r, err)
+		}
+	}
+	if err := mux.HandlePath("GET", "/v1/list", h); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.HandlePath("GET", "/v2/list", h, runtime.WithRouteRequestDefaults(map[string]string{"int32_value": "10"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.HandlePath("GET", "/v3/list", h, runtime.WithRouteRequestDefaults(map[string]string{"int32_value": "ten"})); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, spec := range []struct {
+		path     string
+		msg      *pb.Proto3Message
+		want     *pb.Proto3Message
+		wantCode codes.Code
+	}{
+		{
+			path: "/v1/list",
+			msg:  &pb.Proto3Message{},
+			want: &pb.Proto3Message{
+				Int32Value:    50,
+				StringValue:   "all",
+				Nested:        &pb.Proto3Message{BoolValue: true},
+				RepeatedValue: []string{"a"},
+			},
+		},
+		{
+			path: "/v1/list",
+			msg: &pb.Proto3Message{
+				Int32Value:    7,
+				Nested:        &pb.Proto3Message{Int64Value: 1},
+				RepeatedValue: []string{"b", "c"},
+			},
+			want: &pb.Proto3Message{
+				Int32Value:    7,
+				StringValue:   "all",
+				Nested:        &pb.Proto3Message{Int64Value: 1, BoolValue: true},
+				RepeatedValue: []string{"b", "c"},
+			},
+		},
+		{
+			path: "/v2/list",
+			msg:  &pb.Proto3Message{},
+			want: &pb.Proto3Message{
+				Int32Value:    10,
+				StringValue:   "all",
+				Nested:        &pb.Proto3Message{BoolValue: true},
+				RepeatedValue: []string{"a"},
+			},
+		},
+		{
+			path:     "/v3/list",
+			msg:      &pb.Proto3Message{},
+			wantCode: codes.Internal,
+		},
+	} {
+		var err error
+		populate = func(ctx context.Context) error {
+			err = runtime.PopulateRequestDefaults(ctx, spec.msg)
+			return err
+		}
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", spec.path, nil))
+
+		if got := status.Code(err); got != spec.wantCode {
+			t.Errorf("%s: runtime.PopulateRequestDefaults(...) failed with %v; want code %v", spec.path, err, spec.wantCode)
+			continue
+		}
+		if spec.want == nil {
+			continue
+		}
+		if diff := cmp.Diff(spec.want, spec.msg, protocmp.Transform()); diff != "" {
+			t.Errorf("%s: message differs (-want +got):\n%s", spec.path, diff)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package runtime
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// WithRequestInfo returns a ServeMuxOption making the information of the
+// requests available from their context, to middlewares, handlers and error
+// handlers, with RouteFromContext, StartTimeFromContext and
+// UpstreamTargetFromContext. It costs a few allocations per request, so it is
+// disabled by default; WithRequestMetrics enables it too.
+func WithRequestInfo() ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.requestInfo = true
+	}
+}
+
+// WithRouteOwner returns a RouteOption naming the owner of the route, e.g.
+// the team or service it belongs to, see RouteInfo.Owner.
+func WithRouteOwner(owner string) RouteOption {
+	return func(o *routeOptions) {
+		o.owner = owner
+	}
+}
+
+// requestInfo is the information of a request in progress.
+type requestInfo struct {
+	start time.Time
+
+	mu       sync.Mutex
+	route    RouteInfo
+	routed   bool
+	upstream string
+}
+
+type requestInfoKey struct{}
+
+func requestInfoFromContext(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// startRequestInfo returns r carrying its information in its context if
+// WithRequestInfo or WithRequestMetrics is enabled.
+func (s *ServeMux) startRequestInfo(r *http.Request) *http.Request {
+	if !s.requestInfo && s.requestMetrics == nil {
+		return r
+	}
+	info := &requestInfo{start: time.Now()}
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+}
+
+// markRouted records the route the request of "ctx" was dispatched to.
+func markRouted(ctx context.Context, route RouteInfo) {
+	info := requestInfoFromContext(ctx)
+	if info == nil {
+		return
+	}
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	info.route, info.routed = route, true
+}
+
+// markUpstreamTarget records the upstream the request of "ctx" was sent to.
+func markUpstreamTarget(ctx context.Context, target string) {
+	info := requestInfoFromContext(ctx)
+	if info == nil {
+		return
+	}
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	info.upstream = target
+}
+
+// RouteFromContext returns the route the request of "ctx" was dispatched to,
+// see WithRequestInfo.
+func RouteFromContext(ctx context.Context) (RouteInfo, bool) {
+	info := requestInfoFromContext(ctx)
+	if info == nil {
+		return RouteInfo{}, false
+	}
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	return info.route, info.routed
+}
+
+// StartTimeFromContext returns the time the mux started serving the request
+// of "ctx", see WithRequestInfo.
+func StartTimeFromContext(ctx context.Context) (time.Time, bool) {
+	info := requestInfoFromContext(ctx)
+	if info == nil {
+		return time.Time{}, false
+	}
+	return info.start, true
+}
+
+// UpstreamTargetFromContext returns the upstream the request of "ctx" was
+// last sent to, see WithRequestInfo: the target of the connection if it was
+// dialed with UpstreamTargetUnaryClientInterceptor and
+// UpstreamTargetStreamClientInterceptor, the name of the replica if it was
+// sent through a BackendGroup, or else the upstream of its route if it has a
+// single one, see WithRouteUpstream.
+func UpstreamTargetFromContext(ctx context.Context) (string, bool) {
+	info := requestInfoFromContext(ctx)
+	if info == nil {
+		return "", false
+	}
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	if info.upstream != "" {
+		return info.upstream, true
+	}
+	if len(info.route.Upstreams) == 1 {
+		return info.route.Upstreams[0], true
+	}
+	return "", false
+}
+
+// UpstreamTargetUnaryClientInterceptor returns a grpc.UnaryClientInterceptor
+// recording the target of the connection of the upstream calls for
+// UpstreamTargetFromContext.
+func UpstreamTargetUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		markUpstreamTarget(ctx, cc.Target())
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// UpstreamTargetStreamClientInterceptor returns a grpc.StreamClientInterceptor
+// recording the target of the connection of the upstream streams for
+// UpstreamTargetFromContext.
+func UpstreamTargetStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		markUpstreamTarget(ctx, cc.Target())
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
@@ -0,0 +1,85 @@
+package runtime_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRequestInfo(t *testing.T) {
+	var (
+		calls     []string
+		errRoute  runtime.RouteInfo
+		errTarget string
+	)
+	g := runtime.NewBackendGroup([]runtime.Backend{{Name: "replica-1", Conn: recordingConn{name: "replica-1", calls: &calls}}})
+	mux := runtime.NewServeMuxDynamic(
+		runtime.WithRequestInfo(),
+		runtime.WithErrorHandler(func(ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+			errRoute, _ = runtime.RouteFromContext(ctx)
+			errTarget, _ = runtime.UpstreamTargetFromContext(ctx)
+			runtime.DefaultHTTPErrorHandler(ctx, mux, m, w, r, err)
+		}),
+	)
+
+	before := time.Now()
+	var (
+		route  runtime.RouteInfo
+		start  time.Time
+		target string
+	)
+	if err := mux.HandlePath("GET", "/v1/{name=books/*}", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		route, _ = runtime.RouteFromContext(r.Context())
+		start, _ = runtime.StartTimeFromContext(r.Context())
+		target, _ = runtime.UpstreamTargetFromContext(r.Context())
+	}, runtime.WithRouteOwner("catalog-team"), runtime.WithRouteTags("public"), runtime.WithRouteUpstream("library")); err != nil {
+		t.Fatal(err)
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/books/1", nil))
+
+	want := runtime.RouteInfo{Method: "GET", Pattern: "/v1/{name=books/*}", Tags: []string{"public"}, Upstreams: []string{"library"}, Owner: "catalog-team"}
+	if diff := cmp.Diff(want, route); diff != "" {
+		t.Errorf("runtime.RouteFromContext(...) differs (-want +got):\n%s", diff)
+	}
+	if start.Before(before) || start.After(time.Now()) {
+		t.Errorf("runtime.StartTimeFromContext(...) = %v; want the time the request was served", start)
+	}
+	if target != "library" {
+		t.Errorf("runtime.UpstreamTargetFromContext(...) = %q; want the upstream of the route %q", target, "library")
+	}
+
+	// The replica of a backend group is available to the error handler.
+	if err := mux.HandlePath("DELETE", "/v1/{name=books/*}", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, outbound := runtime.MarshalerForRequest(mux.ServeMux, r)
+		if err := g.Invoke(r.Context(), "/example.Library/DeleteBook", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		runtime.HTTPError(r.Context(), mux.ServeMux, outbound, w, r, status.Error(codes.NotFound, "not found"))
+	}, runtime.WithRouteOwner("catalog-team")); err != nil {
+		t.Fatal(err)
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/v1/books/1", nil))
+	if errRoute.Method != "DELETE" || errRoute.Owner != "catalog-team" || errTarget != "replica-1" {
+		t.Errorf("error handler got route %+v and upstream %q; want DELETE of catalog-team sent to replica-1", errRoute, errTarget)
+	}
+
+	// The information is not available unless enabled.
+	plain := runtime.NewServeMuxDynamic()
+	ok := true
+	if err := plain.HandlePath("GET", "/v1/books", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, ok = runtime.RouteFromContext(r.Context())
+	}); err != nil {
+		t.Fatal(err)
+	}
+	plain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/books", nil))
+	if ok {
+		t.Errorf("runtime.RouteFromContext(...) succeeded without WithRequestInfo; want failure")
+	}
+}
@@ -0,0 +1,116 @@
+package runtime
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RequestMetric is the measurement of a request served by a mux, see
+// WithRequestMetrics.
+type RequestMetric struct {
+	// Route is the route the request was dispatched to, whose Pattern is
+	// empty if it was not dispatched.
+	Route RouteInfo
+	// Method is the HTTP method of the request.
+	Method string
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Duration is the time spent serving the request.
+	Duration time.Duration
+	// RequestSize is the size of the request body, or -1 if unknown, and
+	// ResponseSize the size of the response body written.
+	RequestSize  int64
+	ResponseSize int64
+	// TraceID is the trace ID of the request, see WithTraceCorrelation, to
+	// attach as an exemplar to the latency histograms.
+	TraceID string
+}
+
+// RequestMetricsRecorder records the metrics of the requests served by a mux.
+// It is shaped after the OpenTelemetry metrics API, so that deployments
+// standardized on OTLP collectors can record them into OpenTelemetry
+// instruments, e.g. Duration in seconds into an
+// "http.server.request.duration" histogram, with the attributes
+// "http.request.method" (Method), "http.route" (Route.Pattern) and
+// "http.response.status_code" (StatusCode), and export them with an OTLP
+// exporter, without a Prometheus scrape path.
+type RequestMetricsRecorder interface {
+	RecordRequest(ctx context.Context, metric RequestMetric)
+}
+
+// RequestMetricsRecorderFunc is a function implementing
+// RequestMetricsRecorder.
+type RequestMetricsRecorderFunc func(ctx context.Context, metric RequestMetric)
+
+// RecordRequest calls f.
+func (f RequestMetricsRecorderFunc) RecordRequest(ctx context.Context, metric RequestMetric) {
+	f(ctx, metric)
+}
+
+// WithRequestMetrics returns a ServeMuxOption recording the metrics of every
+// request served into "recorder" once it is served. The requests to the
+// health endpoints and WebSocket upgrades are not recorded.
+func WithRequestMetrics(recorder RequestMetricsRecorder) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.requestMetrics = recorder
+	}
+}
+
+// startRequestMetrics starts measuring "r", carrying its information, if
+// WithRequestMetrics is enabled, and returns the ResponseWriter to serve it
+// with and a function recording its metric once served.
+func (s *ServeMux) startRequestMetrics(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	if s.requestMetrics == nil || strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return w, func() {}
+	}
+	mw := &metricsResponseWriter{ResponseWriter: w}
+	return mw, func() {
+		status := mw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		start, _ := StartTimeFromContext(r.Context())
+		route, _ := RouteFromContext(r.Context())
+		traceID, _ := TraceIDFromContext(r.Context())
+		s.requestMetrics.RecordRequest(r.Context(), RequestMetric{
+			Route:        route,
+			Method:       r.Method,
+			StatusCode:   status,
+			Duration:     time.Since(start),
+			RequestSize:  r.ContentLength,
+			ResponseSize: mw.size,
+			TraceID:      traceID,
+		})
+	}
+}
+
+// metricsResponseWriter records the status and size of a response.
+type metricsResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *metricsResponseWriter) WriteHeader(code int) {
+	if w.status == 0 && !isInformational(code) {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *metricsResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *metricsResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,67 @@
+package runtime_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestRequestMetrics(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		metrics []runtime.RequestMetric
+	)
+	recorder := runtime.RequestMetricsRecorderFunc(func(ctx context.Context, metric runtime.RequestMetric) {
+		mu.Lock()
+		defer mu.Unlock()
+		metrics = append(metrics, metric)
+	})
+	mux := runtime.NewServeMuxDynamic(
+		runtime.WithRequestMetrics(recorder),
+		runtime.WithHealthEndpoints(runtime.HealthConfig{}),
+	)
+	if err := mux.HandlePath("POST", "/v1/{parent=shelves/*}/books", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"name": "books/1"}`))
+	}, runtime.WithRouteTags("write")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest("POST", "/v1/shelves/1/books", strings.NewReader(`{"title": "Dune"}`)),
+		httptest.NewRequest("GET", "/v1/unknown", nil),
+		httptest.NewRequest("GET", "/healthz", nil),
+	} {
+		mux.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	want := []runtime.RequestMetric{
+		{
+			Route:       runtime.RouteInfo{Method: "POST", Pattern: "/v1/{parent=shelves/*}/books", Tags: []string{"write"}},
+			Method:      "POST",
+			StatusCode:  http.StatusCreated,
+			RequestSize: 17,
+		},
+		{
+			Method:     "GET",
+			StatusCode: http.StatusNotFound,
+		},
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if diff := cmp.Diff(want, metrics, cmpopts.IgnoreFields(runtime.RequestMetric{}, "Duration", "ResponseSize")); diff != "" {
+		t.Errorf("metrics differ (-want +got):\n%s", diff)
+	}
+	if len(metrics) == 2 {
+		if metrics[0].ResponseSize != 19 || metrics[0].Duration <= 0 {
+			t.Errorf("metric = %+v; want a response size of 19 and a positive duration", metrics[0])
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+)
+
+// ResponseEnvelope configures the envelope wrapping JSON responses, see
+// WithResponseEnvelope.
+type ResponseEnvelope struct {
+	// DataKey is the key of a successful response. It defaults to "data".
+	DataKey string
+	// ErrorKey is the key of an error. It defaults to "error".
+	ErrorKey string
+	// RequestIDKey is the key of the request ID. It defaults to "request_id".
+	RequestIDKey string
+	// RequestID returns the ID of a request, the key is omitted if it returns
+	// an empty string. It defaults to the value of the X-Request-Id header.
+	RequestID func(*http.Request) string
+}
+
+// WithResponseEnvelope returns a ServeMuxOption which wraps every JSON response
+// in an envelope. A successful response is rendered as
+//
+//	{"data": <response>, "request_id": "..."}
+//
+// and an error as
+//
+//	{"error": <status>, "request_id": "..."}
+//
+// Only marshalers whose content type is JSON are wrapped; google.api.HttpBody
+// responses and the chunks of server streams, which already carry "result"
+// and "error" keys, are sent as is.
+func WithResponseEnvelope(envelope ResponseEnvelope) ServeMuxOption {
+	return func(serveMux *ServeMux) {
+		if envelope.DataKey == "" {
+			envelope.DataKey = "data"
+		}
+		if envelope.ErrorKey == "" {
+			envelope.ErrorKey = "error"
+		}
+		if envelope.RequestIDKey == "" {
+			envelope.RequestIDKey = "request_id"
+		}
+		if envelope.RequestID == nil {
+			envelope.RequestID = func(r *http.Request) string {
+				return r.Header.Get("X-Request-Id")
+			}
+		}
+		serveMux.responseEnvelope = &envelope
+	}
+}
+
+// envelopeMarshaler returns m wrapped in the response envelope of the mux, or
+// m if there is none.
+func (s *ServeMux) envelopeMarshaler(r *http.Request, m Marshaler) Marshaler {
+	if s.responseEnvelope == nil {
+		return m
+	}
+	return &envelopedMarshaler{
+		Marshaler: m,
+		envelope:  s.responseEnvelope,
+		requestID: s.responseEnvelope.RequestID(r),
+	}
+}
+
+// envelopedMarshaler is a Marshaler wrapping the output of another one in a
+// ResponseEnvelope.
+type envelopedMarshaler struct {
+	Marshaler
+	envelope  *ResponseEnvelope
+	requestID string
+}
+
+// Marshal marshals "v" with the wrapped Marshaler and wraps the result in the envelope.
+func (e *envelopedMarshaler) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := e.marshalBuffer(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalBuffer appends the enveloped marshaled form of "v" to buf.
+func (e *envelopedMarshaler) marshalBuffer(buf *bytes.Buffer, v interface{}) error {
+	if !e.wraps(v) {
+		return marshalToBuffer(e.Marshaler, buf, v)
+	}
+
+	key := e.envelope.DataKey
+	if _, ok := v.(*statuspb.Status); ok {
+		key = e.envelope.ErrorKey
+	}
+	buf.WriteByte('{')
+	if err := writeEnvelopeKey(buf, key); err != nil {
+		return err
+	}
+	start := buf.Len()
+	if err := marshalToBuffer(e.Marshaler, buf, v); err != nil {
+		return err
+	}
+	value := buf.Bytes()[start:]
+	if trimmed := bytes.TrimSpace(value); len(trimmed) != len(value) {
+		buf.Truncate(start)
+		buf.Write(trimmed)
+	}
+	if e.requestID != "" {
+		requestID, err := json.Marshal(e.requestID)
+		if err != nil {
+			return err
+		}
+		buf.WriteByte(',')
+		if err := writeEnvelopeKey(buf, e.envelope.RequestIDKey); err != nil {
+			return err
+		}
+		buf.Write(requestID)
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// wraps reports whether the marshaled form of v is wrapped in the envelope.
+func (e *envelopedMarshaler) wraps(v interface{}) bool {
+	if _, ok := v.(*httpbody.HttpBody); ok {
+		return false
+	}
+	if _, ok := v.(map[string]interface{}); ok {
+		// A chunk of a server stream.
+		return false
+	}
+	return isJSONContentType(e.Marshaler.ContentType(v))
+}
+
+func writeEnvelopeKey(buf *bytes.Buffer, key string) error {
+	k, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	buf.Write(k)
+	buf.WriteByte(':')
+	return nil
+}
+
+func isJSONContentType(contentType string) bool {
+	mediatype, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediatype == "application/json" || strings.HasSuffix(mediatype, "+json")
+}
+
+// NewEncoder returns an Encoder which writes enveloped responses into "w".
+func (e *envelopedMarshaler) NewEncoder(w io.Writer) Encoder {
+	return EncoderFunc(func(v interface{}) error {
+		buf, err := e.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(buf)
+		return err
+	})
+}
+
+// Delimiter returns the delimiter of the wrapped Marshaler.
+func (e *envelopedMarshaler) Delimiter() []byte {
+	if d, ok := e.Marshaler.(Delimited); ok {
+		return d.Delimiter()
+	}
+	return []byte("\n")
+}
+
+// FramePrefix returns the frame prefix of the wrapped Marshaler, if any.
+func (e *envelopedMarshaler) FramePrefix(n int) []byte {
+	if f, ok := e.Marshaler.(Framed); ok {
+		return f.FramePrefix(n)
+	}
+	return nil
+}
+
+// StreamPreamble returns the stream preamble of the wrapped Marshaler, if any.
+func (e *envelopedMarshaler) StreamPreamble(first interface{}) ([]byte, error) {
+	if p, ok := e.Marshaler.(StreamPreambler); ok {
+		return p.StreamPreamble(first)
+	}
+	return nil, nil
+}
@@ -0,0 +1,142 @@
+package runtime_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWithResponseEnvelope(t *testing.T) {
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	msg := &examplepb.SimpleMessage{Id: "foo"}
+
+	for _, spec := range []struct {
+		name     string
+		envelope runtime.ResponseEnvelope
+		err      error
+		want     map[string]interface{}
+	}{
+		{
+			name: "success",
+			want: map[string]interface{}{
+				"data":       map[string]interface{}{"id": "foo"},
+				"request_id": "abc",
+			},
+		},
+		{
+			name: "error",
+			err:  status.Error(codes.NotFound, "not found"),
+			want: map[string]interface{}{
+				"error": map[string]interface{}{
+					"code":    float64(codes.NotFound),
+					"message": "not found",
+				},
+				"request_id": "abc",
+			},
+		},
+		{
+			name: "custom keys",
+			envelope: runtime.ResponseEnvelope{
+				DataKey:      "result",
+				RequestIDKey: "trace",
+				RequestID: func(r *http.Request) string {
+					return "custom-" + r.Header.Get("X-Request-Id")
+				},
+			},
+			want: map[string]interface{}{
+				"result": map[string]interface{}{"id": "foo"},
+				"trace":  "custom-abc",
+			},
+		},
+		{
+			name: "no request ID",
+			envelope: runtime.ResponseEnvelope{
+				RequestID: func(*http.Request) string { return "" },
+			},
+			want: map[string]interface{}{
+				"data": map[string]interface{}{"id": "foo"},
+			},
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			mux := runtime.NewServeMux(
+				runtime.WithResponseEnvelope(spec.envelope),
+				runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{}),
+			)
+			req := httptest.NewRequest("GET", "/v1/resource", nil)
+			req.Header.Set("X-Request-Id", "abc")
+			w := httptest.NewRecorder()
+			_, marshaler := runtime.MarshalerForRequest(mux, req)
+			if spec.err != nil {
+				runtime.HTTPError(ctx, mux, marshaler, w, req, spec.err)
+			} else {
+				runtime.ForwardResponseMessage(ctx, mux, marshaler, w, req, msg)
+			}
+
+			var got map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("json.Unmarshal(%s) failed with %v; want success", w.Body, err)
+			}
+			if spec.err != nil {
+				delete(got["error"].(map[string]interface{}), "details")
+			}
+			if diff := cmp.Diff(got, spec.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestWithResponseEnvelope_Passthrough(t *testing.T) {
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	mux := runtime.NewServeMux(
+		runtime.WithResponseEnvelope(runtime.ResponseEnvelope{}),
+		runtime.WithMarshalerOption("application/octet-stream", &runtime.ProtoMarshaller{}),
+	)
+	msg := &examplepb.SimpleMessage{Id: "foo"}
+
+	// Non-JSON marshalers are not wrapped.
+	req := httptest.NewRequest("GET", "/v1/resource", nil)
+	req.Header.Set("Accept", "application/octet-stream")
+	w := httptest.NewRecorder()
+	_, marshaler := runtime.MarshalerForRequest(mux, req)
+	runtime.ForwardResponseMessage(ctx, mux, marshaler, w, req, msg)
+	got := new(examplepb.SimpleMessage)
+	if err := proto.Unmarshal(w.Body.Bytes(), got); err != nil {
+		t.Fatalf("proto.Unmarshal failed with %v; want success", err)
+	}
+	if !proto.Equal(got, msg) {
+		t.Errorf("got %v; want %v", got, msg)
+	}
+
+	// Stream chunks already have "result" and "error" keys.
+	req = httptest.NewRequest("GET", "/v1/resource", nil)
+	w = httptest.NewRecorder()
+	_, marshaler = runtime.MarshalerForRequest(mux, req)
+	count := 0
+	recv := func() (proto.Message, error) {
+		if count > 0 {
+			return nil, io.EOF
+		}
+		count++
+		return msg, nil
+	}
+	runtime.ForwardResponseStream(ctx, mux, marshaler, w, req, recv)
+	var chunk map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &chunk); err != nil {
+		t.Fatalf("json.Unmarshal(%s) failed with %v; want success", w.Body, err)
+	}
+	if _, ok := chunk["result"]; !ok {
+		t.Errorf("chunk = %s; want a result", w.Body)
+	}
+}
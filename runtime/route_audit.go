@@ -0,0 +1,201 @@
+package runtime
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// The operations of RouteAuditEvent.
+const (
+	// RouteAuditHandle is the registration of a route.
+	RouteAuditHandle = "handle"
+	// RouteAuditDeregister is the deregistration of a route.
+	RouteAuditDeregister = "deregister"
+	// RouteAuditSwap is the replacement of all the routes, see
+	// ServeMuxDynamic.Preload.
+	RouteAuditSwap = "swap"
+)
+
+// RouteAuditEvent is a change of the routes of a ServeMuxDynamic.
+type RouteAuditEvent struct {
+	Time time.Time `json:"time"`
+	// Operation is one of RouteAuditHandle, RouteAuditDeregister and
+	// RouteAuditSwap.
+	Operation string `json:"operation"`
+	// Actor is who made the change, e.g. the authenticated user of an admin
+	// API, and Source what the change came from, e.g. "reflection",
+	// "config" or "api", see ServeMuxDynamic.EditAs. They are empty for the
+	// changes made on the mux directly.
+	Actor  string `json:"actor,omitempty"`
+	Source string `json:"source,omitempty"`
+	// Method and Pattern are the method and path template of the route
+	// changed, empty for RouteAuditSwap.
+	Method  string `json:"method,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// RouteAuditSink records the changes of the routes of a ServeMuxDynamic, see
+// WithRouteAuditSink. It is called synchronously once a change is applied,
+// and must be safe for concurrent use.
+type RouteAuditSink interface {
+	RecordRouteChange(event RouteAuditEvent)
+}
+
+// WithRouteAuditSink returns a ServeMuxOption recording every change of the
+// routes of a ServeMuxDynamic into "sink", e.g. a RouteAuditLog, for the
+// audit of who changed the API served and when. It has no effect on a
+// ServeMux.
+func WithRouteAuditSink(sink RouteAuditSink) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.routeAudit = sink
+	}
+}
+
+// routeChangeBy is the actor and source of a change of routes.
+type routeChangeBy struct {
+	actor, source string
+}
+
+func (s *ServeMuxDynamic) auditRouteChange(by routeChangeBy, operation, method, pattern string) {
+	if s.routeAudit == nil {
+		return
+	}
+	s.routeAudit.RecordRouteChange(RouteAuditEvent{
+		Time:      time.Now(),
+		Operation: operation,
+		Actor:     by.actor,
+		Source:    by.source,
+		Method:    method,
+		Pattern:   pattern,
+	})
+}
+
+// RouteEditor changes the routes of a ServeMuxDynamic on behalf of an actor,
+// see ServeMuxDynamic.EditAs.
+type RouteEditor struct {
+	mux *ServeMuxDynamic
+	by  routeChangeBy
+}
+
+// EditAs returns a RouteEditor attributing the changes it makes to "actor",
+// e.g. the authenticated user of an admin API, and "source", e.g.
+// "reflection", "config" or "api", in the events of the route audit sink,
+// see WithRouteAuditSink.
+func (s *ServeMuxDynamic) EditAs(actor, source string) RouteEditor {
+	return RouteEditor{mux: s, by: routeChangeBy{actor: actor, source: source}}
+}
+
+// Handle is the same as ServeMuxDynamic.Handle.
+func (e RouteEditor) Handle(meth string, pat Pattern, h HandlerFunc, opts ...RouteOption) {
+	e.mux.handle(e.by, meth, pat, h, opts)
+}
+
+// HandlePath is the same as ServeMuxDynamic.HandlePath.
+func (e RouteEditor) HandlePath(meth string, pathPattern string, h HandlerFunc, opts ...RouteOption) error {
+	return e.mux.handlePath(e.by, meth, pathPattern, h, opts)
+}
+
+// HandleWithDeregister is the same as ServeMuxDynamic.HandleWithDeregister.
+// The deregistration is attributed to the actor of the registration too.
+func (e RouteEditor) HandleWithDeregister(meth string, pat Pattern, h HandlerFunc, opts ...RouteOption) (deregister func()) {
+	return e.mux.handleWithDeregister(e.by, meth, pat, h, opts)
+}
+
+// HandlerDeregister is the same as ServeMuxDynamic.HandlerDeregister.
+func (e RouteEditor) HandlerDeregister(meth string, pat Pattern) {
+	e.mux.handlerDeregister(e.by, meth, pat)
+}
+
+// Preload is the same as ServeMuxDynamic.Preload.
+func (e RouteEditor) Preload(sources ...RouteSource) error {
+	return e.mux.preload(e.by, sources)
+}
+
+// RouteAuditFilter selects events of a RouteAuditLog. Its empty fields match
+// all the events.
+type RouteAuditFilter struct {
+	Actor, Source   string
+	Method, Pattern string
+	Since, Until    time.Time
+}
+
+func (f RouteAuditFilter) matches(e RouteAuditEvent) bool {
+	return (f.Actor == "" || f.Actor == e.Actor) &&
+		(f.Source == "" || f.Source == e.Source) &&
+		(f.Method == "" || f.Method == e.Method) &&
+		(f.Pattern == "" || f.Pattern == e.Pattern) &&
+		(f.Since.IsZero() || !e.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || e.Time.Before(f.Until))
+}
+
+// RouteAuditLog is a RouteAuditSink keeping the last events in memory. It is
+// an http.Handler serving them as JSON, e.g. on an admin endpoint, filtered
+// by the "actor", "source", "method", "pattern", "since" and "until" query
+// parameters, the times in RFC 3339 format.
+type RouteAuditLog struct {
+	size int
+
+	mu     sync.Mutex
+	events []RouteAuditEvent
+}
+
+// NewRouteAuditLog returns a RouteAuditLog keeping the last "size" events.
+func NewRouteAuditLog(size int) *RouteAuditLog {
+	return &RouteAuditLog{size: size}
+}
+
+// RecordRouteChange implements RouteAuditSink.
+func (l *RouteAuditLog) RecordRouteChange(event RouteAuditEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+	if len(l.events) > l.size {
+		l.events = append(l.events[:0:0], l.events[len(l.events)-l.size:]...)
+	}
+}
+
+// Events returns the events matching "filter", from the oldest one.
+func (l *RouteAuditLog) Events(filter RouteAuditFilter) []RouteAuditEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var events []RouteAuditEvent
+	for _, e := range l.events {
+		if filter.matches(e) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func (l *RouteAuditLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := RouteAuditFilter{
+		Actor:   query.Get("actor"),
+		Source:  query.Get("source"),
+		Method:  query.Get("method"),
+		Pattern: query.Get("pattern"),
+	}
+	for param, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		v := query.Get(param)
+		if v == "" {
+			continue
+		}
+		var err error
+		if *t, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "invalid "+param+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	events := l.Events(filter)
+	if events == nil {
+		events = []RouteAuditEvent{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"events": events}); err != nil {
+		grpclog.Infof("Failed to write the route audit log: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package runtime_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestRouteAudit(t *testing.T) {
+	log := runtime.NewRouteAuditLog(4)
+	mux := runtime.NewServeMuxDynamic(runtime.WithRouteAuditSink(log))
+	noop := func(w http.ResponseWriter, r *http.Request, _ map[string]string) {}
+
+	start := time.Now()
+	if err := mux.HandlePath("GET", "/v1/books", noop); err != nil {
+		t.Fatal(err)
+	}
+	alice := mux.EditAs("alice", "api")
+	if err := alice.HandlePath("GET", "/v1/shelves", noop); err != nil {
+		t.Fatal(err)
+	}
+	pat := runtime.MustPattern(runtime.NewPattern(1, []int{2, 0, 2, 1}, []string{"v1", "authors"}, ""))
+	deregister := mux.EditAs("bob", "reflection").HandleWithDeregister("GET", pat, noop)
+	deregister()
+	deregister()
+	// Deregistering an unknown route changes nothing.
+	alice.HandlerDeregister("DELETE", pat)
+	if err := mux.EditAs("carol", "config").Preload(runtime.StaticRoutes(runtime.Route{Method: "GET", Pattern: "/v1/books", Handler: noop})); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []runtime.RouteAuditEvent{
+		{Operation: runtime.RouteAuditHandle, Actor: "alice", Source: "api", Method: "GET", Pattern: "/v1/shelves"},
+		{Operation: runtime.RouteAuditHandle, Actor: "bob", Source: "reflection", Method: "GET", Pattern: "/v1/authors"},
+		{Operation: runtime.RouteAuditDeregister, Actor: "bob", Source: "reflection", Method: "GET", Pattern: "/v1/authors"},
+		{Operation: runtime.RouteAuditSwap, Actor: "carol", Source: "config"},
+	}
+	ignoreTime := cmpopts.IgnoreFields(runtime.RouteAuditEvent{}, "Time")
+	events := log.Events(runtime.RouteAuditFilter{})
+	if diff := cmp.Diff(want, events, ignoreTime); diff != "" {
+		t.Errorf("log.Events(...) differ (-want +got):\n%s", diff)
+	}
+	for _, e := range events {
+		if e.Time.Before(start) {
+			t.Errorf("event %+v recorded at %v; want after %v", e, e.Time, start)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	log.ServeHTTP(w, httptest.NewRequest("GET", "/audit?actor=bob&since="+start.Add(-time.Second).Format(time.RFC3339), nil))
+	var body struct {
+		Events []runtime.RouteAuditEvent `json:"events"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("json.Unmarshal(%s) failed with %v; want success", w.Body, err)
+	}
+	if diff := cmp.Diff(want[1:3], body.Events, ignoreTime); diff != "" {
+		t.Errorf("events of bob differ (-want +got):\n%s", diff)
+	}
+
+	w = httptest.NewRecorder()
+	log.ServeHTTP(w, httptest.NewRequest("GET", "/audit?until=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("GET with an invalid time replied %d; want %d", w.Code, http.StatusBadRequest)
+	}
+}
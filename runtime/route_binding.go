@@ -0,0 +1,225 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// routeBinding is a route of a ServeMuxDynamic which the calls of an RPC
+// facade, e.g. JSON-RPC, are mapped onto, as described by a google.api.http
+// option.
+type routeBinding struct {
+	httpMethod string
+	pattern    string
+	// body is the field of the params sent as the body of the requests, or
+	// "*" for all the params which are not path variables. The other params
+	// are sent as query parameters.
+	body string
+}
+
+// callRoute serves "sub" with the route it matches, and returns the status
+// and the body of its response. The request "sub" is made for was already
+// recorded, measured and had its tenant resolved by ServeHTTP, so sub is
+// dispatched to the route directly rather than served again.
+func (s *ServeMuxDynamic) callRoute(sub *http.Request) (int, []byte) {
+	rw := &replayResponseWriter{header: make(http.Header)}
+	if h, pathParams, ok := s.matchRoute(sub); ok {
+		s.dispatch(rw, sub, h, pathParams)
+		s.releasePathParams(pathParams)
+	} else {
+		_, outboundMarshaler := MarshalerForRequest(s.ServeMux, sub)
+		s.routingErrorHandler(sub.Context(), s.ServeMux, outboundMarshaler, rw, sub, http.StatusNotFound)
+	}
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	return rw.status, bytes.TrimSpace(rw.body.Bytes())
+}
+
+// matchRoute returns the handler of the route of the tenant of "r" matching
+// its method and path, and its path parameters. The escaped path is matched,
+// so that the escaped slashes of a parameter do not split it in segments.
+func (s *ServeMuxDynamic) matchRoute(r *http.Request) (handler, map[string]string, bool) {
+	tenant, _ := TenantFromContext(r.Context())
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/")
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, h := range s.handlers[r.Method] {
+		if !h.servesTenant(tenant) {
+			continue
+		}
+		matchPath, verb := path, ""
+		if v := h.pat.Verb(); v != "" && strings.HasSuffix(matchPath, ":"+v) {
+			matchPath, verb = matchPath[:len(matchPath)-len(v)-1], v
+		}
+		pathParams, err := s.matchPath(h.pat, matchPath, verb)
+		if err != nil {
+			continue
+		}
+		for k, v := range pathParams {
+			if pathParams[k], err = url.PathUnescape(v); err != nil {
+				s.releasePathParams(pathParams)
+				return handler{}, nil, false
+			}
+		}
+		return h, pathParams, true
+	}
+	return handler{}, nil, false
+}
+
+// statusFromErrorBody returns the status of an error response with "code"
+// and "body", as replied by the error handler in a google.rpc.Status message.
+func statusFromErrorBody(code int, body []byte) *status.Status {
+	var st struct {
+		Code    codes.Code `json:"code"`
+		Message string     `json:"message"`
+	}
+	if err := json.Unmarshal(body, &st); err != nil || st.Code == codes.OK {
+		return status.New(codes.Unknown, http.StatusText(code))
+	}
+	return status.New(st.Code, st.Message)
+}
+
+// request returns the request to the route of b for the call with "params"
+// received in "r".
+func (b routeBinding) request(r *http.Request, params map[string]interface{}) (*http.Request, error) {
+	path, err := expandPathTemplate(b.pattern, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var body interface{}
+	switch b.body {
+	case "":
+	case "*":
+		body, params = params, nil
+	default:
+		body = params[b.body]
+		delete(params, b.body)
+	}
+	query := url.Values{}
+	for _, k := range sortedKeys(params) {
+		addQueryValues(query, k, params[k])
+	}
+
+	unescaped, err := url.PathUnescape(path)
+	if err != nil {
+		return nil, err
+	}
+	u := &url.URL{Path: unescaped, RawPath: path, RawQuery: query.Encode()}
+	var content []byte
+	if body != nil {
+		if content, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	sub, err := http.NewRequest(b.httpMethod, u.String(), bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	sub = sub.WithContext(r.Context())
+	sub.Header = r.Header.Clone()
+	sub.Header.Del("Content-Length")
+	sub.Header.Set("Content-Type", "application/json")
+	sub.Header.Set("Accept", "application/json")
+	sub.Host, sub.RemoteAddr, sub.TLS = r.Host, r.RemoteAddr, r.TLS
+	return sub, nil
+}
+
+// expandPathTemplate returns the path of the path template "template" with
+// its variables set to the fields of "params", which are removed from it.
+// The values of single segment variables, e.g. {name}, are escaped as one
+// segment, and "." and ".." segments are rejected, so that params cannot
+// address another route.
+func expandPathTemplate(template string, params map[string]interface{}) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			b.WriteString(template)
+			return b.String(), nil
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("invalid path template %q", template)
+		}
+		b.WriteString(template[:start])
+		name, segmentTemplate := template[start+1:start+end], "*"
+		if i := strings.IndexByte(name, '='); i >= 0 {
+			name, segmentTemplate = name[:i], name[i+1:]
+		}
+		v, ok := popField(params, name)
+		if !ok {
+			return "", fmt.Errorf("missing param %q", name)
+		}
+		segments := []string{fmt.Sprint(v)}
+		if segmentTemplate != "*" {
+			segments = strings.Split(segments[0], "/")
+		}
+		for i, seg := range segments {
+			if seg == "." || seg == ".." {
+				return "", fmt.Errorf("invalid param %q", name)
+			}
+			segments[i] = url.PathEscape(seg)
+		}
+		b.WriteString(strings.Join(segments, "/"))
+		template = template[start+end+1:]
+	}
+}
+
+// popField removes the field at the dotted path "name" from "params", and
+// returns its value if it is a scalar.
+func popField(params map[string]interface{}, name string) (interface{}, bool) {
+	parts := strings.Split(name, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := params[part].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		params = next
+	}
+	last := parts[len(parts)-1]
+	v, ok := params[last]
+	switch v.(type) {
+	case string, json.Number, bool:
+	default:
+		return nil, false
+	}
+	delete(params, last)
+	return v, ok
+}
+
+// addQueryValues adds the query parameters of the field "key" of value "v"
+// to "query", with dotted keys for the fields of objects.
+func addQueryValues(query url.Values, key string, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			addQueryValues(query, key+"."+k, v[k])
+		}
+	case []interface{}:
+		for _, item := range v {
+			addQueryValues(query, key, item)
+		}
+	case nil:
+	default:
+		query.Add(key, fmt.Sprint(v))
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,126 @@
+package runtime
+
+import (
+	"container/list"
+	"sync"
+)
+
+// WithRouteCache returns a ServeMuxOption which memoizes the routing of
+// requests: the handler matched by the method and path of a request, along
+// with its path parameters, is kept in an LRU cache of at most size entries,
+// so that requests for the same path are not matched against the patterns
+// again. The cache is invalidated whenever a handler is registered or
+// deregistered.
+//
+// Only requests dispatched to a handler registered for their own method are
+// cached; fallbacks and routing errors are resolved as usual.
+func WithRouteCache(size int) ServeMuxOption {
+	return func(serveMux *ServeMux) {
+		if size <= 0 {
+			serveMux.routeCache = nil
+			return
+		}
+		serveMux.routeCache = &routeCache{
+			size:    size,
+			entries: make(map[routeKey]*list.Element),
+			lru:     list.New(),
+		}
+	}
+}
+
+type routeKey struct {
+	method, path string
+}
+
+type routeCacheEntry struct {
+	key        routeKey
+	h          handler
+	pathParams map[string]string
+}
+
+// routeCache is an LRU cache of matched routes. A nil *routeCache caches nothing.
+type routeCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[routeKey]*list.Element
+	lru     *list.List
+	// generation is incremented whenever the cache is invalidated, so that
+	// routes matched before are not stored afterwards.
+	generation uint64
+}
+
+// get returns the route cached for method and path, and the generation of
+// the cache to store the route with if there is none.
+func (c *routeCache) get(method, path string) (*routeCacheEntry, uint64) {
+	if c == nil {
+		return nil, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[routeKey{method: method, path: path}]
+	if !ok {
+		return nil, c.generation
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*routeCacheEntry), c.generation
+}
+
+// put caches the route matched for method and path, unless the cache was
+// invalidated since generation.
+func (c *routeCache) put(generation uint64, method, path string, h handler, pathParams map[string]string) {
+	if c == nil {
+		return
+	}
+	entry := &routeCacheEntry{
+		key:        routeKey{method: method, path: path},
+		h:          h,
+		pathParams: make(map[string]string, len(pathParams)),
+	}
+	for k, v := range pathParams {
+		entry.pathParams[k] = v
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != generation {
+		return
+	}
+	if e, ok := c.entries[entry.key]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*routeCacheEntry).key)
+	}
+}
+
+// reset invalidates the cache.
+func (c *routeCache) reset() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.entries = make(map[routeKey]*list.Element)
+	c.lru.Init()
+}
+
+// cachedPathParams returns a copy of the path parameters of a cached route,
+// which must be released with releasePathParams.
+func (s *ServeMux) cachedPathParams(route *routeCacheEntry) map[string]string {
+	var pathParams map[string]string
+	if s.pooledPathParams {
+		pathParams = pathParamsPool.Get().(map[string]string)
+	} else {
+		pathParams = make(map[string]string, len(route.pathParams))
+	}
+	for k, v := range route.pathParams {
+		pathParams[k] = v
+	}
+	return pathParams
+}
@@ -0,0 +1,49 @@
+package runtime
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// WithRouteCallOptions returns a RouteOption adding opts to the upstream
+// calls of the requests to this route, e.g. grpc.MaxCallRecvMsgSize,
+// grpc.UseCompressor or grpc.WaitForReady. They are added after the options
+// of the handler, so they take precedence over them.
+//
+// The options are added to the calls made through a BackendGroup, or on
+// connections dialed with RouteCallOptionsUnaryClientInterceptor and
+// RouteCallOptionsStreamClientInterceptor.
+func WithRouteCallOptions(opts ...grpc.CallOption) RouteOption {
+	return func(o *routeOptions) {
+		o.callOptions = append(o.callOptions, opts...)
+	}
+}
+
+// routeCallOptions returns opts followed by the call options of the route of
+// ctx.
+func routeCallOptions(ctx context.Context, opts []grpc.CallOption) []grpc.CallOption {
+	o := routeOptionsFromContext(ctx)
+	if o == nil || len(o.callOptions) == 0 {
+		return opts
+	}
+	return append(opts[:len(opts):len(opts)], o.callOptions...)
+}
+
+// RouteCallOptionsUnaryClientInterceptor returns a
+// grpc.UnaryClientInterceptor adding the call options of WithRouteCallOptions
+// to the upstream calls of the requests to the route.
+func RouteCallOptionsUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(ctx, method, req, reply, cc, routeCallOptions(ctx, opts)...)
+	}
+}
+
+// RouteCallOptionsStreamClientInterceptor returns a
+// grpc.StreamClientInterceptor adding the call options of
+// WithRouteCallOptions to the upstream streams of the requests to the route.
+func RouteCallOptionsStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(ctx, desc, cc, method, routeCallOptions(ctx, opts)...)
+	}
+}
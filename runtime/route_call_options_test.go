@@ -0,0 +1,69 @@
+package runtime_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestRouteCallOptions(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	dial := func(opts ...grpc.DialOption) *grpc.ClientConn {
+		opts = append(opts, grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}))
+		conn, err := grpc.Dial("bufnet", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	intercepted := dial(
+		grpc.WithUnaryInterceptor(runtime.RouteCallOptionsUnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(runtime.RouteCallOptionsStreamClientInterceptor()),
+	)
+	group := runtime.NewBackendGroup([]runtime.Backend{{Name: "a", Conn: dial()}})
+
+	for name, cc := range map[string]grpc.ClientConnInterface{"interceptor": intercepted, "backend group": group} {
+		client := healthpb.NewHealthClient(cc)
+		mux := runtime.NewServeMuxDynamic()
+		check := func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+			_, err := client.Check(r.Context(), &healthpb.HealthCheckRequest{})
+			w.WriteHeader(runtime.HTTPStatusFromCode(status.Code(err)))
+		}
+		if err := mux.HandlePath("GET", "/v1/check", check); err != nil {
+			t.Fatal(err)
+		}
+		// No response fits in a single byte.
+		if err := mux.HandlePath("GET", "/v1/tiny", check, runtime.WithRouteCallOptions(grpc.MaxCallRecvMsgSize(1))); err != nil {
+			t.Fatal(err)
+		}
+
+		for path, want := range map[string]codes.Code{
+			"/v1/check": codes.OK,
+			"/v1/tiny":  codes.ResourceExhausted,
+		} {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+			if w.Code != runtime.HTTPStatusFromCode(want) {
+				t.Errorf("%s: GET %s = %d; want %d", name, path, w.Code, runtime.HTTPStatusFromCode(want))
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package runtime
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// RouteDiff is the difference between two routing tables, see
+// ServeMuxDynamic.DiffRoutes.
+type RouteDiff struct {
+	// Added are the routes of the new table for a method and pattern
+	// without a route in the current one.
+	Added []RouteInfo
+	// Removed are the routes of the current table for a method and pattern
+	// without a route in the new one.
+	Removed []RouteInfo
+	// Changed are the routes of both tables whose tags, upstreams or owner
+	// differ.
+	Changed []RouteChange
+}
+
+// RouteChange is a route changed by a new routing table.
+type RouteChange struct {
+	Old, New RouteInfo
+}
+
+// Empty reports whether the tables are the same.
+func (d RouteDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String returns the diff as lines of routes prefixed with "+" if added,
+// "-" if removed and "~" if changed, for review.
+func (d RouteDiff) String() string {
+	var b strings.Builder
+	for _, route := range d.Added {
+		fmt.Fprintf(&b, "+ %s\n", describeRoute(route))
+	}
+	for _, route := range d.Removed {
+		fmt.Fprintf(&b, "- %s\n", describeRoute(route))
+	}
+	for _, change := range d.Changed {
+		fmt.Fprintf(&b, "~ %s -> %s\n", describeRoute(change.Old), describeRoute(change.New))
+	}
+	return b.String()
+}
+
+func describeRoute(route RouteInfo) string {
+	s := route.Method + " " + route.Pattern
+	if len(route.Tags) > 0 {
+		s += " tags=" + strings.Join(route.Tags, ",")
+	}
+	if len(route.Upstreams) > 0 {
+		s += " upstreams=" + strings.Join(route.Upstreams, ",")
+	}
+	if route.Owner != "" {
+		s += " owner=" + route.Owner
+	}
+	return s
+}
+
+// Routes returns the routes of the mux serving requests, i.e. the last one
+// registered for every method and pattern, sorted by pattern and method.
+func (s *ServeMuxDynamic) Routes() []RouteInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return activeRoutes(s.handlers)
+}
+
+// DiffRoutes loads and validates the routes of "sources" as Preload does,
+// and returns the difference between the routes of the mux and them without
+// applying them, e.g. for the review of a change of configuration (dry run).
+func (s *ServeMuxDynamic) DiffRoutes(sources ...RouteSource) (RouteDiff, error) {
+	handlers, err := s.loadRoutes(sources)
+	if err != nil {
+		return RouteDiff{}, err
+	}
+	return diffRoutes(s.Routes(), activeRoutes(handlers)), nil
+}
+
+// activeRoutes returns the routes of "handlers" serving requests, sorted by
+// pattern and method.
+func activeRoutes(handlers map[string][]handler) []RouteInfo {
+	var routes []RouteInfo
+	for method, hs := range handlers {
+		seen := make(map[string]bool)
+		for _, h := range hs {
+			pattern := h.pat.String()
+			if seen[pattern] {
+				continue
+			}
+			seen[pattern] = true
+			routes = append(routes, h.routeInfo(method))
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// diffRoutes returns the difference between the sorted routes "old" and
+// "new".
+func diffRoutes(old, new []RouteInfo) RouteDiff {
+	key := func(route RouteInfo) string {
+		return route.Method + " " + route.Pattern
+	}
+	olds := make(map[string]RouteInfo, len(old))
+	for _, route := range old {
+		olds[key(route)] = route
+	}
+	var diff RouteDiff
+	for _, route := range new {
+		prev, ok := olds[key(route)]
+		delete(olds, key(route))
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, route)
+		case !sameStrings(prev.Tags, route.Tags) || !sameStrings(prev.Upstreams, route.Upstreams) || prev.Owner != route.Owner:
+			diff.Changed = append(diff.Changed, RouteChange{Old: prev, New: route})
+		}
+	}
+	for _, route := range old {
+		if _, ok := olds[key(route)]; ok {
+			diff.Removed = append(diff.Removed, route)
+		}
+	}
+	return diff
+}
+
+func sameStrings(a, b []string) bool {
+	return len(a) == len(b) && (len(a) == 0 || reflect.DeepEqual(a, b))
+}
@@ -0,0 +1,68 @@
+package runtime_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestServeMuxDynamic_DiffRoutes(t *testing.T) {
+	mux := runtime.NewServeMuxDynamic()
+	noop := func(w http.ResponseWriter, r *http.Request, _ map[string]string) {}
+	for _, route := range []struct {
+		method, pattern string
+		opts            []runtime.RouteOption
+	}{
+		{method: "GET", pattern: "/v1/books"},
+		{method: "GET", pattern: "/v1/{name=books/*}", opts: []runtime.RouteOption{runtime.WithRouteTags("public")}},
+		{method: "DELETE", pattern: "/v1/{name=books/*}"},
+	} {
+		if err := mux.HandlePath(route.method, route.pattern, noop, route.opts...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diff, err := mux.DiffRoutes(runtime.StaticRoutes(
+		runtime.Route{Method: "GET", Pattern: "/v1/books", Handler: noop},
+		runtime.Route{Method: "GET", Pattern: "/v1/{name=books/*}", Handler: noop, Options: []runtime.RouteOption{runtime.WithRouteTags("internal")}},
+		runtime.Route{Method: "POST", Pattern: "/v1/books", Handler: noop},
+	))
+	if err != nil {
+		t.Fatalf("mux.DiffRoutes(...) failed with %v; want success", err)
+	}
+	want := runtime.RouteDiff{
+		Added:   []runtime.RouteInfo{{Method: "POST", Pattern: "/v1/books"}},
+		Removed: []runtime.RouteInfo{{Method: "DELETE", Pattern: "/v1/{name=books/*}"}},
+		Changed: []runtime.RouteChange{{
+			Old: runtime.RouteInfo{Method: "GET", Pattern: "/v1/{name=books/*}", Tags: []string{"public"}},
+			New: runtime.RouteInfo{Method: "GET", Pattern: "/v1/{name=books/*}", Tags: []string{"internal"}},
+		}},
+	}
+	if d := cmp.Diff(want, diff); d != "" {
+		t.Errorf("mux.DiffRoutes(...) differs (-want +got):\n%s", d)
+	}
+	wantText := "+ POST /v1/books\n" +
+		"- DELETE /v1/{name=books/*}\n" +
+		"~ GET /v1/{name=books/*} tags=public -> GET /v1/{name=books/*} tags=internal\n"
+	if got := diff.String(); got != wantText {
+		t.Errorf("diff.String() = %q; want %q", got, wantText)
+	}
+
+	// The diff is not applied.
+	if got := len(mux.Routes()); got != 3 {
+		t.Errorf("len(mux.Routes()) = %d after a dry run; want 3", got)
+	}
+	if _, err := mux.DiffRoutes(runtime.StaticRoutes(runtime.Route{Method: "GET", Pattern: "/v1/{", Handler: noop})); err == nil {
+		t.Errorf("mux.DiffRoutes(...) succeeded with an invalid route; want failure")
+	}
+	diff, err = mux.DiffRoutes(runtime.StaticRoutes(
+		runtime.Route{Method: "GET", Pattern: "/v1/books", Handler: noop},
+		runtime.Route{Method: "GET", Pattern: "/v1/{name=books/*}", Handler: noop, Options: []runtime.RouteOption{runtime.WithRouteTags("public")}},
+		runtime.Route{Method: "DELETE", Pattern: "/v1/{name=books/*}", Handler: noop},
+	))
+	if err != nil || !diff.Empty() {
+		t.Errorf("mux.DiffRoutes(<current routes>) = %v, %v; want an empty diff", diff, err)
+	}
+}
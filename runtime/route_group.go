@@ -0,0 +1,83 @@
+package runtime
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// RouteGroup registers routes on a ServeMuxDynamic under a common path prefix
+// and with common RouteOptions, see ServeMuxDynamic.Group.
+type RouteGroup struct {
+	mux    *ServeMuxDynamic
+	prefix string
+	opts   []RouteOption
+
+	mu          sync.Mutex
+	unmounted   bool
+	deregisters []func()
+}
+
+// Group returns a RouteGroup registering routes whose path patterns are
+// prefixed with "prefix", e.g. "/v1/admin", and which have the options
+// "opts" before their own, so that they override them. The routes of the
+// group are all deregistered at once with RouteGroup.Unmount.
+func (s *ServeMuxDynamic) Group(prefix string, opts ...RouteOption) *RouteGroup {
+	return &RouteGroup{mux: s, prefix: strings.TrimSuffix(prefix, "/"), opts: opts}
+}
+
+// Group returns a RouteGroup nested in g: its prefix and options are appended
+// to the ones of g, and its routes are deregistered when g is unmounted.
+func (g *RouteGroup) Group(prefix string, opts ...RouteOption) *RouteGroup {
+	nested := g.mux.Group(g.prefix+prefix, append(g.options(nil), opts...)...)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.deregisters = append(g.deregisters, nested.Unmount)
+	return nested
+}
+
+// Handle registers "h" for "meth" and "pat" prefixed with the prefix of the
+// group, with the options of the group followed by "opts".
+func (g *RouteGroup) Handle(meth string, pat Pattern, h HandlerFunc, opts ...RouteOption) error {
+	return g.HandlePath(meth, pat.String(), h, opts...)
+}
+
+// HandlePath registers "h" for "meth" and "pathPattern" prefixed with the
+// prefix of the group, e.g. "/users/{id}" as "/v1/admin/users/{id}", with the
+// options of the group followed by "opts".
+func (g *RouteGroup) HandlePath(meth string, pathPattern string, h HandlerFunc, opts ...RouteOption) error {
+	pattern := g.prefix + pathPattern
+	if pathPattern == "/" && g.prefix != "" {
+		pattern = g.prefix
+	}
+	pat, err := parsePattern(pattern)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.unmounted {
+		return errors.New("the route group is unmounted")
+	}
+	g.deregisters = append(g.deregisters, g.mux.handleWithDeregister(routeChangeBy{}, meth, pat, h, g.options(opts)))
+	return nil
+}
+
+// options returns the options of the group followed by "opts".
+func (g *RouteGroup) options(opts []RouteOption) []RouteOption {
+	return append(append([]RouteOption(nil), g.opts...), opts...)
+}
+
+// Unmount deregisters all the routes of the group, and of its nested groups.
+// The group registers no route afterwards.
+func (g *RouteGroup) Unmount() {
+	g.mu.Lock()
+	deregisters := g.deregisters
+	g.deregisters, g.unmounted = nil, true
+	g.mu.Unlock()
+
+	for _, deregister := range deregisters {
+		deregister()
+	}
+}
@@ -0,0 +1,98 @@
+package runtime_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestRouteGroup(t *testing.T) {
+	mux := runtime.NewServeMuxDynamic(runtime.WithRequestInfo())
+	routes := map[string]runtime.RouteInfo{}
+	deadlines := map[string]bool{}
+	handler := func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		routes[r.URL.Path], _ = runtime.RouteFromContext(r.Context())
+		_, deadlines[r.URL.Path] = r.Context().Deadline()
+	}
+
+	admin := mux.Group("/v1/admin/", runtime.WithRouteTimeout(time.Minute), runtime.WithRouteTags("internal"))
+	if err := admin.HandlePath("GET", "/users/{id}", handler, runtime.WithRouteTags("users")); err != nil {
+		t.Fatal(err)
+	}
+	pat := runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"settings"}, ""))
+	if err := admin.Handle("PUT", pat, handler); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.Group("/reports", runtime.WithRouteOwner("finance")).HandlePath("GET", "/", handler); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.HandlePath("GET", "/{", handler); err == nil {
+		t.Errorf("admin.HandlePath(...) succeeded with an invalid pattern; want failure")
+	}
+	if err := mux.HandlePath("GET", "/v1/public", handler); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path string) int {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code
+	}
+	for _, spec := range []struct {
+		method, path string
+		want         runtime.RouteInfo
+		deadline     bool
+	}{
+		{
+			method:   "GET",
+			path:     "/v1/admin/users/1",
+			want:     runtime.RouteInfo{Method: "GET", Pattern: "/v1/admin/users/{id=*}", Tags: []string{"internal", "users"}},
+			deadline: true,
+		},
+		{
+			method:   "PUT",
+			path:     "/v1/admin/settings",
+			want:     runtime.RouteInfo{Method: "PUT", Pattern: "/v1/admin/settings", Tags: []string{"internal"}},
+			deadline: true,
+		},
+		{
+			method:   "GET",
+			path:     "/v1/admin/reports",
+			want:     runtime.RouteInfo{Method: "GET", Pattern: "/v1/admin/reports", Tags: []string{"internal"}, Owner: "finance"},
+			deadline: true,
+		},
+		{
+			method: "GET",
+			path:   "/v1/public",
+			want:   runtime.RouteInfo{Method: "GET", Pattern: "/v1/public"},
+		},
+	} {
+		if code := serve(spec.method, spec.path); code != http.StatusOK {
+			t.Errorf("%s %s replied %d; want %d", spec.method, spec.path, code, http.StatusOK)
+			continue
+		}
+		if diff := cmp.Diff(spec.want, routes[spec.path]); diff != "" {
+			t.Errorf("route of %s %s differs (-want +got):\n%s", spec.method, spec.path, diff)
+		}
+		if deadlines[spec.path] != spec.deadline {
+			t.Errorf("%s %s has a deadline: %t; want %t", spec.method, spec.path, deadlines[spec.path], spec.deadline)
+		}
+	}
+
+	admin.Unmount()
+	for path, method := range map[string]string{"/v1/admin/users/1": "GET", "/v1/admin/settings": "PUT", "/v1/admin/reports": "GET"} {
+		if code := serve(method, path); code != http.StatusNotFound {
+			t.Errorf("%s %s replied %d once unmounted; want %d", method, path, code, http.StatusNotFound)
+		}
+	}
+	if code := serve("GET", "/v1/public"); code != http.StatusOK {
+		t.Errorf("GET /v1/public replied %d once the group is unmounted; want %d", code, http.StatusOK)
+	}
+	if err := admin.HandlePath("GET", "/users", handler); err == nil {
+		t.Errorf("admin.HandlePath(...) succeeded once unmounted; want failure")
+	}
+}
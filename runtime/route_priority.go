@@ -0,0 +1,53 @@
+package runtime
+
+// WithRoutePriority returns a RouteOption setting the priority of the route.
+// Requests are matched against the routes of higher priority first, whatever
+// the order they were registered in. Routes have priority 0 by default, as
+// the routes registered by the generated code do, so that a route with a
+// positive priority overrides the generated routes it overlaps, and a route
+// with a negative priority only serves the requests none of them matches.
+func WithRoutePriority(priority int) RouteOption {
+	return func(o *routeOptions) {
+		o.priority = priority
+	}
+}
+
+// WithRouteLastMatchWins returns a RouteOption setting whether the route is
+// matched before the routes of the same priority registered before it, so
+// that it overrides them, which is the default. With false, it is matched
+// after them, and only serves the requests they do not match.
+func WithRouteLastMatchWins(lastMatchWins bool) RouteOption {
+	return func(o *routeOptions) {
+		o.firstMatchWins = !lastMatchWins
+	}
+}
+
+// matchOrder returns the priority of the route, and whether it wins over the
+// routes of the same priority registered before it.
+func (o *routeOptions) matchOrder() (priority int, lastMatchWins bool) {
+	if o == nil {
+		return 0, true
+	}
+	return o.priority, !o.firstMatchWins
+}
+
+// insertHandler returns a copy of "handlers" with "h" inserted in match
+// order: after the handlers of higher priority, and before or after the
+// handlers of the same priority depending on whether the last match wins.
+// Deregistrations remove handlers without reordering the others, so the
+// order only depends on the registrations still in place.
+func insertHandler(handlers []handler, h handler) []handler {
+	priority, lastMatchWins := h.opts.matchOrder()
+	idx := len(handlers)
+	for i, other := range handlers {
+		p, _ := other.opts.matchOrder()
+		if p < priority || (p == priority && lastMatchWins) {
+			idx = i
+			break
+		}
+	}
+	inserted := make([]handler, 0, len(handlers)+1)
+	inserted = append(inserted, handlers[:idx]...)
+	inserted = append(inserted, h)
+	return append(inserted, handlers[idx:]...)
+}
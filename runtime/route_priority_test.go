@@ -0,0 +1,61 @@
+package runtime_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestRoutePriority(t *testing.T) {
+	mux := runtime.NewServeMuxDynamic()
+	handler := func(name string) runtime.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+			w.Write([]byte(name))
+		}
+	}
+	serve := func(path string) string {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w.Body.String()
+	}
+
+	// Generated routes are registered without options, at priority 0.
+	generated := runtime.MustPattern(runtime.NewPattern(1, []int{2, 0, 1, 0, 4, 1, 5, 1}, []string{"v1", "name"}, ""))
+	mux.Handle("GET", generated, handler("generated"))
+
+	if err := mux.HandlePath("GET", "/v1/fallback", handler("fallback"), runtime.WithRoutePriority(-1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := serve("/v1/fallback"); got != "generated" {
+		t.Errorf("GET /v1/fallback served by %q; want the generated route to win over a lower priority", got)
+	}
+	if err := mux.HandlePath("GET", "/v1/override", handler("override"), runtime.WithRoutePriority(1)); err != nil {
+		t.Fatal(err)
+	}
+	pat := runtime.MustPattern(runtime.NewPattern(1, []int{2, 0, 2, 1}, []string{"v1", "override"}, ""))
+	mux.Handle("GET", generated, handler("regenerated"))
+	if got := serve("/v1/override"); got != "override" {
+		t.Errorf("GET /v1/override served by %q; want the higher priority route to win over later registrations", got)
+	}
+
+	deregisterShadowed := mux.HandleWithDeregister("GET", pat, handler("shadowed"), runtime.WithRoutePriority(1), runtime.WithRouteLastMatchWins(false))
+	if got := serve("/v1/override"); got != "override" {
+		t.Errorf("GET /v1/override served by %q; want the earlier route to win without last match wins", got)
+	}
+	deregisterLatest := mux.HandleWithDeregister("GET", pat, handler("latest"), runtime.WithRoutePriority(1))
+	if got := serve("/v1/override"); got != "latest" {
+		t.Errorf("GET /v1/override served by %q; want the last route to win by default", got)
+	}
+
+	deregisterLatest()
+	if got := serve("/v1/override"); got != "override" {
+		t.Errorf("GET /v1/override served by %q after deregistration; want %q", got, "override")
+	}
+	mux.HandlerDeregister("GET", pat)
+	if got := serve("/v1/override"); got != "regenerated" {
+		t.Errorf("GET /v1/override served by %q once the overrides are deregistered; want %q", got, "regenerated")
+	}
+	deregisterShadowed()
+}
@@ -0,0 +1,137 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SecurityEventType is the type of a SecurityEvent.
+type SecurityEventType string
+
+const (
+	// SecurityEventAuthFailure is emitted when a request fails authentication,
+	// e.g. with a missing or invalid token, API key or client certificate.
+	SecurityEventAuthFailure SecurityEventType = "auth_failure"
+	// SecurityEventAuthzDenial is emitted when the Authorizer of the mux
+	// denies an authenticated request.
+	SecurityEventAuthzDenial SecurityEventType = "authz_denial"
+	// SecurityEventRateLimited is emitted when a request is rejected with
+	// codes.ResourceExhausted by an authenticator, or is reported as such
+	// with ServeMux.ReportSecurityEvent.
+	SecurityEventRateLimited SecurityEventType = "rate_limited"
+	// SecurityEventSignatureMismatch is emitted when the HMAC signature of a
+	// request is missing or invalid, see WithHMACAuth.
+	SecurityEventSignatureMismatch SecurityEventType = "signature_mismatch"
+)
+
+// SecurityEvent is a structured record of a request rejected for security
+// reasons, see WithSecurityEventHandler.
+type SecurityEvent struct {
+	// Type is the type of the event.
+	Type SecurityEventType
+	// Time is when the request was rejected.
+	Time time.Time
+	// Route is the route the request was dispatched to. Its Pattern is empty
+	// for events reported with ServeMux.ReportSecurityEvent.
+	Route RouteInfo
+	// Path is the path of the request URL.
+	Path string
+	// RemoteAddr is the network address of the peer of the request.
+	RemoteAddr string
+	// ClientIP is the IP address of the client resolved by WithClientIP, or
+	// nil if the mux does not resolve client addresses.
+	ClientIP net.IP
+	// Code is the gRPC code of the error the request was rejected with.
+	Code codes.Code
+	// Reason is the message of that error, redacted by ServeMux.Redactor.
+	Reason string
+}
+
+// SecurityEventHandler handles the security events of a mux. It is called
+// synchronously before the error is replied, so it should hand events off,
+// e.g. to a buffered channel, rather than block on shipping them.
+type SecurityEventHandler func(ctx context.Context, event SecurityEvent)
+
+// WithSecurityEventHandler returns a ServeMuxOption which calls handler with
+// the security events of the mux: authentication failures, authorization
+// denials, rate-limit trips and signature mismatches. Unlike access logs,
+// events only describe rejected requests, so they can be shipped to a SIEM on
+// their own. Handlers are called in the order they were registered.
+func WithSecurityEventHandler(handler SecurityEventHandler) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.securityEventHandlers = append(mux.securityEventHandlers, handler)
+	}
+}
+
+// ReportSecurityEvent calls the security event handlers of s with an event of
+// type t for r, rejected with err. It lets middleware outside of the mux,
+// e.g. a rate limiter, report its rejections alongside those of the mux.
+func (s *ServeMux) ReportSecurityEvent(r *http.Request, t SecurityEventType, err error) {
+	route := RouteInfo{Method: r.Method}
+	if o := routeOptionsFromContext(r.Context()); o != nil {
+		route.Tags = o.tags
+	}
+	s.emitSecurityEvent(r, t, route, err)
+}
+
+func (s *ServeMux) emitSecurityEvent(r *http.Request, t SecurityEventType, route RouteInfo, err error) {
+	if len(s.securityEventHandlers) == 0 {
+		return
+	}
+	st := s.logRedaction.Status(status.Convert(err))
+	event := SecurityEvent{
+		Type:       t,
+		Time:       time.Now(),
+		Route:      route,
+		Path:       r.URL.Path,
+		RemoteAddr: r.RemoteAddr,
+		Code:       st.Code(),
+		Reason:     st.Message(),
+	}
+	if s.clientIP != nil {
+		event.ClientIP, _ = ClientIPFromContext(r.Context())
+	}
+	for _, handler := range s.securityEventHandlers {
+		handler(r.Context(), event)
+	}
+}
+
+// securityEventError is an error with a gRPC status which determines the type
+// of the security event emitted when an authenticator fails with it.
+type securityEventError struct {
+	typ SecurityEventType
+	err error
+}
+
+func (e *securityEventError) Error() string {
+	return e.err.Error()
+}
+
+// GRPCStatus returns the status of the wrapped error.
+func (e *securityEventError) GRPCStatus() *status.Status {
+	return status.Convert(e.err)
+}
+
+// Unwrap returns the wrapped error.
+func (e *securityEventError) Unwrap() error {
+	return e.err
+}
+
+// authenticationEventType returns the type of the security event emitted
+// when an authenticator fails with err.
+func authenticationEventType(err error) SecurityEventType {
+	var se *securityEventError
+	if errors.As(err, &se) {
+		return se.typ
+	}
+	if status.Code(err) == codes.ResourceExhausted {
+		return SecurityEventRateLimited
+	}
+	return SecurityEventAuthFailure
+}
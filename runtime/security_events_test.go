@@ -0,0 +1,135 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWithSecurityEventHandler(t *testing.T) {
+	var events []runtime.SecurityEvent
+	mux := runtime.NewServeMuxDynamic(
+		runtime.WithSecurityEventHandler(func(ctx context.Context, event runtime.SecurityEvent) {
+			events = append(events, event)
+		}),
+		runtime.WithLogRedaction(runtime.LogRedaction{Patterns: []*regexp.Regexp{regexp.MustCompile(`key-[0-9]+`)}}),
+		runtime.WithAPIKeyAuth(runtime.APIKeyConfig{
+			Header: "X-API-Key",
+			Validator: runtime.APIKeyValidatorFunc(func(ctx context.Context, key string) (string, error) {
+				if key != "key-1" {
+					return "", errors.New("unknown key " + key)
+				}
+				return "alice", nil
+			}),
+			SkipTags: []string{"webhook"},
+		}),
+		runtime.WithAuthorizer(runtime.AuthorizerFunc(func(ctx context.Context, route runtime.RouteInfo, r *http.Request) error {
+			if route.HasTag("admin") {
+				return errors.New("admins only")
+			}
+			return nil
+		})),
+	)
+	handler := func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {}
+	mux.Handle("GET", mustPattern(t, "/v1/books"), handler)
+	mux.Handle("GET", mustPattern(t, "/v1/admin"), handler, runtime.WithRouteTags("admin"))
+	mux.Handle("POST", mustPattern(t, "/v1/hooks"), handler, runtime.WithRouteTags("webhook"),
+		runtime.WithRouteHMACAuth(runtime.HMACConfig{Key: []byte("secret")}))
+
+	for _, spec := range []struct {
+		method, path, key string
+		status            int
+		want              []runtime.SecurityEvent
+	}{
+		{method: "GET", path: "/v1/books", key: "key-1", status: http.StatusOK},
+		{
+			method: "GET", path: "/v1/books", key: "key-2",
+			status: http.StatusUnauthorized,
+			want: []runtime.SecurityEvent{{
+				Type:  runtime.SecurityEventAuthFailure,
+				Route: runtime.RouteInfo{Method: "GET", Pattern: "/v1/books"},
+				Path:  "/v1/books",
+				Code:  codes.Unauthenticated,
+			}},
+		},
+		{
+			method: "GET", path: "/v1/admin", key: "key-1",
+			status: http.StatusForbidden,
+			want: []runtime.SecurityEvent{{
+				Type:   runtime.SecurityEventAuthzDenial,
+				Route:  runtime.RouteInfo{Method: "GET", Pattern: "/v1/admin", Tags: []string{"admin"}},
+				Path:   "/v1/admin",
+				Code:   codes.PermissionDenied,
+				Reason: "admins only",
+			}},
+		},
+		{
+			method: "POST", path: "/v1/hooks",
+			status: http.StatusUnauthorized,
+			want: []runtime.SecurityEvent{{
+				Type:   runtime.SecurityEventSignatureMismatch,
+				Route:  runtime.RouteInfo{Method: "POST", Pattern: "/v1/hooks", Tags: []string{"webhook"}},
+				Path:   "/v1/hooks",
+				Code:   codes.Unauthenticated,
+				Reason: "invalid request signature: missing signature",
+			}},
+		},
+	} {
+		events = nil
+		r := httptest.NewRequest(spec.method, spec.path, strings.NewReader("{}"))
+		if spec.key != "" {
+			r.Header.Set("X-API-Key", spec.key)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != spec.status {
+			t.Errorf("%s %s: w.Code = %d; want %d", spec.method, spec.path, w.Code, spec.status)
+		}
+		for i := range events {
+			if events[i].Time.IsZero() || events[i].RemoteAddr != r.RemoteAddr {
+				t.Errorf("%s %s: events[%d] = %+v; want its time and remote address to be set", spec.method, spec.path, i, events[i])
+			}
+			if strings.Contains(events[i].Reason, spec.key) && spec.key != "" {
+				t.Errorf("%s %s: events[%d].Reason = %q; want the key to be redacted", spec.method, spec.path, i, events[i].Reason)
+			}
+		}
+		ignore := cmpopts.IgnoreFields(runtime.SecurityEvent{}, "Time", "RemoteAddr")
+		if spec.want != nil && spec.want[0].Type == runtime.SecurityEventAuthFailure {
+			ignore = cmpopts.IgnoreFields(runtime.SecurityEvent{}, "Time", "RemoteAddr", "Reason")
+		}
+		if diff := cmp.Diff(spec.want, events, ignore); diff != "" {
+			t.Errorf("%s %s: events differed from want (-want, +got):\n%s", spec.method, spec.path, diff)
+		}
+	}
+}
+
+func TestReportSecurityEvent(t *testing.T) {
+	var got []runtime.SecurityEvent
+	mux := runtime.NewServeMux(runtime.WithSecurityEventHandler(func(ctx context.Context, event runtime.SecurityEvent) {
+		got = append(got, event)
+	}))
+	r := httptest.NewRequest("GET", "/v1/books", nil)
+	mux.ReportSecurityEvent(r, runtime.SecurityEventRateLimited, status.Error(codes.ResourceExhausted, "too many requests"))
+
+	want := []runtime.SecurityEvent{{
+		Type:       runtime.SecurityEventRateLimited,
+		Route:      runtime.RouteInfo{Method: "GET"},
+		Path:       "/v1/books",
+		RemoteAddr: r.RemoteAddr,
+		Code:       codes.ResourceExhausted,
+		Reason:     "too many requests",
+	}}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(runtime.SecurityEvent{}, "Time")); diff != "" {
+		t.Errorf("events differed from want (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,195 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// WithServerTiming returns a ServeMuxOption emitting a Server-Timing header,
+// which browser developer tools display, breaking the time spent on each
+// request down into:
+//
+//   - match: routing the request to its handler,
+//   - unmarshal: preparing the upstream call, mostly decoding the request,
+//   - upstream: the upstream call, until its response or stream is received,
+//   - marshal: encoding the response.
+//
+// The upstream call is only timed on connections dialed with
+// ServerTimingUnaryClientInterceptor and ServerTimingStreamClientInterceptor.
+// Otherwise, upstream also covers the time spent decoding the request, and
+// unmarshal is not emitted. The header is emitted when the response header
+// is written, so the time spent writing the response body is not included,
+// and marshal is not emitted for streamed responses.
+func WithServerTiming() ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.serverTiming = true
+	}
+}
+
+// serverTiming records the timings of a request.
+type serverTiming struct {
+	mu            sync.Mutex
+	start         time.Time
+	matched       time.Time
+	handlerStart  time.Time
+	upstreamStart time.Time
+	upstreamEnd   time.Time
+	marshal       time.Duration
+}
+
+type serverTimingKey struct{}
+
+func serverTimingFromContext(ctx context.Context) *serverTiming {
+	t, _ := ctx.Value(serverTimingKey{}).(*serverTiming)
+	return t
+}
+
+// startServerTiming starts timing "r" if WithServerTiming is enabled, and
+// returns the ResponseWriter emitting the timings and the request carrying
+// them in its context.
+func (s *ServeMux) startServerTiming(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+	if !s.serverTiming || strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return w, r
+	}
+	t := &serverTiming{start: time.Now()}
+	return &serverTimingResponseWriter{ResponseWriter: w, timing: t}, r.WithContext(context.WithValue(r.Context(), serverTimingKey{}, t))
+}
+
+// markMatched records that the request was routed to its handler.
+func (t *serverTiming) markMatched() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.matched = time.Now()
+}
+
+// markHandlerStart records that the handler of the request is called.
+func (t *serverTiming) markHandlerStart() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlerStart = time.Now()
+}
+
+// markUpstream records the upstream call from "start" until now.
+func (t *serverTiming) markUpstream(start time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.upstreamStart.IsZero() {
+		t.upstreamStart, t.upstreamEnd = start, time.Now()
+	}
+}
+
+// markMarshal records the marshaling of the response from "start" until now.
+func (t *serverTiming) markMarshal(start time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.marshal = time.Since(start)
+}
+
+// header returns the value of the Server-Timing header at "now".
+func (t *serverTiming) header(now time.Time) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var metrics []string
+	add := func(name string, d time.Duration) {
+		metrics = append(metrics, fmt.Sprintf("%s;dur=%.3f", name, float64(d)/float64(time.Millisecond)))
+	}
+	if t.matched.IsZero() {
+		return ""
+	}
+	add("match", t.matched.Sub(t.start))
+	if t.handlerStart.IsZero() {
+		return strings.Join(metrics, ", ")
+	}
+	switch {
+	case !t.upstreamStart.IsZero():
+		add("unmarshal", t.upstreamStart.Sub(t.handlerStart))
+		add("upstream", t.upstreamEnd.Sub(t.upstreamStart))
+	default:
+		add("upstream", now.Add(-t.marshal).Sub(t.handlerStart))
+	}
+	if t.marshal > 0 {
+		add("marshal", t.marshal)
+	}
+	return strings.Join(metrics, ", ")
+}
+
+// serverTimingResponseWriter emits the Server-Timing header along with the
+// response header.
+type serverTimingResponseWriter struct {
+	http.ResponseWriter
+	timing      *serverTiming
+	wroteHeader bool
+}
+
+func (w *serverTimingResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader && !isInformational(code) {
+		w.wroteHeader = true
+		if v := w.timing.header(time.Now()); v != "" {
+			w.Header().Set("Server-Timing", v)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *serverTimingResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *serverTimingResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ServerTimingUnaryClientInterceptor returns a grpc.UnaryClientInterceptor
+// timing the upstream calls of the requests served with WithServerTiming.
+func ServerTimingUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		t := serverTimingFromContext(ctx)
+		if t == nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		start := time.Now()
+		defer t.markUpstream(start)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// ServerTimingStreamClientInterceptor returns a grpc.StreamClientInterceptor
+// timing the establishment of the upstream streams of the requests served
+// with WithServerTiming.
+func ServerTimingStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		t := serverTimingFromContext(ctx)
+		if t == nil {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		start := time.Now()
+		defer t.markUpstream(start)
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
@@ -0,0 +1,80 @@
+package runtime_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestServerTiming(t *testing.T) {
+	interceptor := runtime.ServerTimingUnaryClientInterceptor()
+	mux := runtime.NewServeMux(runtime.WithServerTiming())
+	for _, pattern := range []string{"/v1/intercepted", "/v1/direct"} {
+		intercepted := pattern == "/v1/intercepted"
+		err := mux.HandlePath("GET", pattern, func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+			ctx := runtime.NewServerMetadataContext(r.Context(), runtime.ServerMetadata{})
+			invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+				time.Sleep(5 * time.Millisecond)
+				return nil
+			}
+			if intercepted {
+				if err := interceptor(ctx, "/test.Service/Method", nil, nil, nil, invoker); err != nil {
+					t.Errorf("interceptor(...) failed with %v; want success", err)
+				}
+			} else {
+				invoker(ctx, "/test.Service/Method", nil, nil, nil)
+			}
+			_, outboundMarshaler := runtime.MarshalerForRequest(mux, r)
+			runtime.ForwardResponseMessage(ctx, mux, outboundMarshaler, w, r, &emptypb.Empty{})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, spec := range []struct {
+		path string
+		want *regexp.Regexp
+	}{
+		{
+			path: "/v1/intercepted",
+			want: regexp.MustCompile(`^match;dur=[0-9.]+, unmarshal;dur=[0-9.]+, upstream;dur=([5-9]|[0-9]{2,})\.[0-9]{3}, marshal;dur=[0-9.]+$`),
+		},
+		{
+			path: "/v1/direct",
+			want: regexp.MustCompile(`^match;dur=[0-9.]+, upstream;dur=([5-9]|[0-9]{2,})\.[0-9]{3}, marshal;dur=[0-9.]+$`),
+		},
+		{
+			path: "/v1/unknown",
+			want: regexp.MustCompile(`^$`),
+		},
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", spec.path, nil))
+		if got := w.Header().Get("Server-Timing"); !spec.want.MatchString(got) {
+			t.Errorf("GET %s: Server-Timing = %q; want a match of %q", spec.path, got, spec.want)
+		}
+	}
+}
+
+func TestServerTimingDisabled(t *testing.T) {
+	mux := runtime.NewServeMux()
+	err := mux.HandlePath("GET", "/v1/direct", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		w.Write([]byte("{}"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/direct", nil))
+	if got := w.Header().Get("Server-Timing"); got != "" {
+		t.Errorf("Server-Timing = %q; want none without WithServerTiming", got)
+	}
+}
@@ -0,0 +1,242 @@
+package runtime
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RouteSLO is the service level objective of a route, see WithRouteSLO.
+type RouteSLO struct {
+	// AvailabilityTarget is the share of the requests to reply without a
+	// 5xx status, e.g. 0.999. The availability is not tracked if it is 0.
+	AvailabilityTarget float64
+	// LatencyTarget is the share of the requests to serve within
+	// LatencyThreshold, e.g. 0.99. The latency is not tracked if it is 0.
+	LatencyTarget    float64
+	LatencyThreshold time.Duration
+}
+
+// WithRouteSLO returns a RouteOption declaring the SLO of the route, whose
+// burn rates are computed if WithSLOBurnRates is enabled.
+func WithRouteSLO(slo RouteSLO) RouteOption {
+	return func(o *routeOptions) {
+		o.slo = &sloTracker{slo: slo}
+	}
+}
+
+// SLOConfig configures the computation of the burn rates of the SLOs of the
+// routes, see WithSLOBurnRates.
+type SLOConfig struct {
+	// Windows are the rolling windows the burn rates are computed over. They
+	// default to 5 minutes and 1 hour, for multiwindow alerts.
+	Windows []time.Duration
+	// EvaluationInterval is the minimum interval between two calls of
+	// OnBurnRates. It defaults to 30 seconds.
+	EvaluationInterval time.Duration
+	// OnBurnRates, if not nil, is called with the burn rates of all the
+	// routes with an SLO and requests in the longest window, at most every
+	// EvaluationInterval, once a request completes, e.g. to alert on them or
+	// record them into gauges.
+	OnBurnRates func(rates []SLOBurnRate)
+}
+
+// SLOBurnRate is the rate at which a route consumes the error budget of its
+// SLO over a window: 1 consumes it exactly over the window, and 10 ten times
+// faster.
+type SLOBurnRate struct {
+	Route  RouteInfo
+	Window time.Duration
+	// Requests is the number of requests in the window.
+	Requests int64
+	// Availability and Latency are the burn rates of the availability and
+	// latency budgets, 0 if they are not tracked.
+	Availability float64
+	Latency      float64
+}
+
+// WithSLOBurnRates returns a ServeMuxOption computing the rolling burn rates
+// of the SLOs declared with WithRouteSLO, available with
+// ServeMux.SLOBurnRates and reported to config.OnBurnRates, so that alerts
+// can be route-aware without parsing logs.
+func WithSLOBurnRates(config SLOConfig) ServeMuxOption {
+	if len(config.Windows) == 0 {
+		config.Windows = []time.Duration{5 * time.Minute, time.Hour}
+	}
+	windows := append([]time.Duration(nil), config.Windows...)
+	sort.Slice(windows, func(i, j int) bool { return windows[i] < windows[j] })
+	config.Windows = windows
+	if config.EvaluationInterval <= 0 {
+		config.EvaluationInterval = 30 * time.Second
+	}
+	return func(mux *ServeMux) {
+		mux.slo = &sloState{
+			config:   config,
+			width:    windows[0] / 10,
+			trackers: make(map[*sloTracker]RouteInfo),
+		}
+	}
+}
+
+// sloState holds the SLO trackers of the routes of a ServeMux.
+type sloState struct {
+	config SLOConfig
+	// width is the width of the buckets of the trackers.
+	width time.Duration
+
+	mu        sync.Mutex
+	trackers  map[*sloTracker]RouteInfo
+	evaluated time.Time
+}
+
+// sloTracker counts the requests of a route in rolling buckets.
+type sloTracker struct {
+	slo RouteSLO
+
+	mu      sync.Mutex
+	buckets []sloBucket
+}
+
+type sloBucket struct {
+	start                  time.Time
+	requests, errors, slow int64
+}
+
+// record records a request replied with "status" after "latency" at "now".
+func (t *sloTracker) record(state *sloState, now time.Time, status int, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.buckets == nil {
+		windows := state.config.Windows
+		t.buckets = make([]sloBucket, int(windows[len(windows)-1]/state.width)+1)
+	}
+	start := now.Truncate(state.width)
+	b := &t.buckets[int(start.UnixNano()/int64(state.width))%len(t.buckets)]
+	if !b.start.Equal(start) {
+		*b = sloBucket{start: start}
+	}
+	b.requests++
+	if status >= 500 {
+		b.errors++
+	}
+	if t.slo.LatencyThreshold > 0 && latency > t.slo.LatencyThreshold {
+		b.slow++
+	}
+}
+
+// burnRate returns the burn rates of t over "window" at "now".
+func (t *sloTracker) burnRate(state *sloState, route RouteInfo, now time.Time, window time.Duration) SLOBurnRate {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rate := SLOBurnRate{Route: route, Window: window}
+	var errors, slow int64
+	since := now.Truncate(state.width).Add(-window)
+	for _, b := range t.buckets {
+		if b.start.After(since) && !b.start.After(now) {
+			rate.Requests += b.requests
+			errors += b.errors
+			slow += b.slow
+		}
+	}
+	if rate.Requests == 0 {
+		return rate
+	}
+	if target := t.slo.AvailabilityTarget; target > 0 && target < 1 {
+		rate.Availability = float64(errors) / float64(rate.Requests) / (1 - target)
+	}
+	if target := t.slo.LatencyTarget; target > 0 && target < 1 {
+		rate.Latency = float64(slow) / float64(rate.Requests) / (1 - target)
+	}
+	return rate
+}
+
+// SLOBurnRates returns the current burn rates of the routes with an SLO and
+// requests in the longest window, for every window, see WithSLOBurnRates.
+func (s *ServeMux) SLOBurnRates() []SLOBurnRate {
+	if s.slo == nil {
+		return nil
+	}
+	return s.slo.burnRates(time.Now())
+}
+
+func (state *sloState) burnRates(now time.Time) []SLOBurnRate {
+	state.mu.Lock()
+	trackers := make(map[*sloTracker]RouteInfo, len(state.trackers))
+	for t, route := range state.trackers {
+		trackers[t] = route
+	}
+	state.mu.Unlock()
+
+	windows := state.config.Windows
+	var rates []SLOBurnRate
+	for t, route := range trackers {
+		longest := t.burnRate(state, route, now, windows[len(windows)-1])
+		if longest.Requests == 0 {
+			// The route got no requests lately, e.g. it was deregistered.
+			state.mu.Lock()
+			delete(state.trackers, t)
+			state.mu.Unlock()
+			continue
+		}
+		for _, window := range windows[:len(windows)-1] {
+			rates = append(rates, t.burnRate(state, route, now, window))
+		}
+		rates = append(rates, longest)
+	}
+	sort.SliceStable(rates, func(i, j int) bool {
+		a, b := rates[i].Route, rates[j].Route
+		if a.Pattern != b.Pattern {
+			return a.Pattern < b.Pattern
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return rates[i].Window < rates[j].Window
+	})
+	return rates
+}
+
+// trackSLO starts tracking "r", dispatched to h, against the SLO of h, and
+// returns the ResponseWriter to serve it with and a function recording it
+// once served.
+func (s *ServeMux) trackSLO(w http.ResponseWriter, r *http.Request, h handler) (http.ResponseWriter, func()) {
+	t := h.opts.slo
+	state := s.slo
+	state.mu.Lock()
+	if _, ok := state.trackers[t]; !ok {
+		state.trackers[t] = h.routeInfo(r.Method)
+	}
+	state.mu.Unlock()
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return w, func() {}
+	}
+
+	start := time.Now()
+	mw := &metricsResponseWriter{ResponseWriter: w}
+	return mw, func() {
+		now := time.Now()
+		status := mw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		t.record(state, now, status, now.Sub(start))
+		state.evaluate(now)
+	}
+}
+
+// evaluate reports the burn rates to the hook, unless it did recently.
+func (state *sloState) evaluate(now time.Time) {
+	if state.config.OnBurnRates == nil {
+		return
+	}
+	state.mu.Lock()
+	if now.Sub(state.evaluated) < state.config.EvaluationInterval {
+		state.mu.Unlock()
+		return
+	}
+	state.evaluated = now
+	state.mu.Unlock()
+	state.config.OnBurnRates(state.burnRates(now))
+}
@@ -0,0 +1,69 @@
+package runtime_test
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestSLOBurnRates(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		reported []runtime.SLOBurnRate
+	)
+	mux := runtime.NewServeMuxDynamic(runtime.WithSLOBurnRates(runtime.SLOConfig{
+		Windows:            []time.Duration{time.Minute, time.Second},
+		EvaluationInterval: time.Nanosecond,
+		OnBurnRates: func(rates []runtime.SLOBurnRate) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = rates
+		},
+	}))
+	if err := mux.HandlePath("GET", "/v1/{name=books/*}", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		switch params["name"] {
+		case "books/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "books/slow":
+			time.Sleep(30 * time.Millisecond)
+		}
+	}, runtime.WithRouteSLO(runtime.RouteSLO{
+		AvailabilityTarget: 0.9,
+		LatencyTarget:      0.5,
+		LatencyThreshold:   20 * time.Millisecond,
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.HandlePath("GET", "/v1/shelves", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"error", "slow", "slow", "1", "2", "3", "4", "5", "6", "7"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/books/"+name, nil))
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/shelves", nil))
+
+	rates := mux.SLOBurnRates()
+	if len(rates) != 2 {
+		t.Fatalf("mux.SLOBurnRates() = %+v; want the rates of /v1/{name=books/*} over 2 windows", rates)
+	}
+	for i, window := range []time.Duration{time.Second, time.Minute} {
+		rate := rates[i]
+		if rate.Route.Pattern != "/v1/{name=books/*}" || rate.Window != window || rate.Requests != 10 {
+			t.Errorf("rate = %+v; want 10 requests to /v1/{name=books/*} over %v", rate, window)
+		}
+		if math.Abs(rate.Availability-1) > 1e-9 || math.Abs(rate.Latency-0.4) > 1e-9 {
+			t.Errorf("burn rates over %v = %v availability, %v latency; want 1 and 0.4", window, rate.Availability, rate.Latency)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reported) != 2 || reported[1].Requests != 10 {
+		t.Errorf("reported burn rates = %+v; want the rates after the last request", reported)
+	}
+}
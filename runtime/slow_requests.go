@@ -0,0 +1,89 @@
+package runtime
+
+import (
+	"context"
+	"net/http"
+	goruntime "runtime"
+	"time"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// SlowRequestWatchdog configures the watchdog of WithSlowRequestWatchdog.
+type SlowRequestWatchdog struct {
+	// Threshold is the duration after which a request in progress is slow.
+	// It defaults to 10 seconds.
+	Threshold time.Duration
+	// CaptureStacks makes the watchdog capture the stacks of all the
+	// goroutines when a request is slow, e.g. to find the handler or
+	// upstream stream it is stuck in.
+	CaptureStacks bool
+	// MaxStackSize is the maximum size of the stacks captured. It defaults
+	// to 1 MiB.
+	MaxStackSize int
+	// OnSlowRequest is called with every slow request. It defaults to
+	// logging it, along with the stacks if captured.
+	OnSlowRequest func(ctx context.Context, slow SlowRequest)
+}
+
+// SlowRequest is a request reported by the watchdog of
+// WithSlowRequestWatchdog.
+type SlowRequest struct {
+	// Route is the route the request was dispatched to.
+	Route RouteInfo
+	// Method and Path are the method and the path of the request, redacted
+	// with the Redactor of the mux.
+	Method, Path string
+	// Elapsed is the time elapsed since the request was dispatched.
+	Elapsed time.Duration
+	// Stacks are the stacks of all the goroutines if captured, see
+	// SlowRequestWatchdog.CaptureStacks.
+	Stacks []byte
+}
+
+// WithSlowRequestWatchdog returns a ServeMuxOption reporting the requests
+// still in progress config.Threshold after their dispatch, labeled with their
+// route, to diagnose stuck upstream streams and deadlocked handlers. A
+// request is reported once, while it is in progress.
+func WithSlowRequestWatchdog(config SlowRequestWatchdog) ServeMuxOption {
+	if config.Threshold <= 0 {
+		config.Threshold = 10 * time.Second
+	}
+	if config.MaxStackSize <= 0 {
+		config.MaxStackSize = 1 << 20
+	}
+	if config.OnSlowRequest == nil {
+		config.OnSlowRequest = func(_ context.Context, slow SlowRequest) {
+			grpclog.Warningf("Slow request %s %s to %s %s in progress for %v", slow.Method, slow.Path, slow.Route.Method, slow.Route.Pattern, slow.Elapsed)
+			if len(slow.Stacks) > 0 {
+				grpclog.Warningf("Goroutines of slow request %s %s:\n%s", slow.Method, slow.Path, slow.Stacks)
+			}
+		}
+	}
+	return func(mux *ServeMux) {
+		mux.slowRequests = &config
+	}
+}
+
+// watchSlowRequest starts watching "r", dispatched to h, with the watchdog of
+// s, and returns a function stopping once it is served.
+func (s *ServeMux) watchSlowRequest(r *http.Request, h handler) (stop func()) {
+	config := s.slowRequests
+	start := time.Now()
+	timer := time.AfterFunc(config.Threshold, func() {
+		slow := SlowRequest{
+			Route:   h.routeInfo(r.Method),
+			Method:  r.Method,
+			Path:    s.logRedaction.String(r.URL.Path),
+			Elapsed: time.Since(start),
+		}
+		if config.CaptureStacks {
+			buf := make([]byte, config.MaxStackSize)
+			slow.Stacks = buf[:goruntime.Stack(buf, true)]
+		}
+		config.OnSlowRequest(r.Context(), slow)
+	})
+	return func() {
+		timer.Stop()
+	}
+}
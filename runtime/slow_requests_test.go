@@ -0,0 +1,60 @@
+package runtime_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestSlowRequestWatchdog(t *testing.T) {
+	slow := make(chan runtime.SlowRequest, 2)
+	mux := runtime.NewServeMuxDynamic(runtime.WithSlowRequestWatchdog(runtime.SlowRequestWatchdog{
+		Threshold:     20 * time.Millisecond,
+		CaptureStacks: true,
+		OnSlowRequest: func(ctx context.Context, s runtime.SlowRequest) {
+			slow <- s
+		},
+	}))
+	unblock := make(chan struct{})
+	if err := mux.HandlePath("GET", "/v1/{name=books/*}", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		if params["name"] == "books/stuck" {
+			<-unblock
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	get("/v1/books/fast")
+	done := make(chan struct{})
+	go func() {
+		get("/v1/books/stuck")
+		close(done)
+	}()
+
+	select {
+	case s := <-slow:
+		if s.Path != "/v1/books/stuck" || s.Route.Pattern != "/v1/{name=books/*}" || s.Elapsed < 20*time.Millisecond {
+			t.Errorf("slow request = %s %s to %s after %v; want /v1/books/stuck to /v1/{name=books/*} after 20ms", s.Method, s.Path, s.Route.Pattern, s.Elapsed)
+		}
+		if !bytes.Contains(s.Stacks, []byte("TestSlowRequestWatchdog")) {
+			t.Errorf("stacks do not contain the stuck handler:\n%s", s.Stacks)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stuck request was not reported")
+	}
+	close(unblock)
+	<-done
+	select {
+	case s := <-slow:
+		t.Errorf("%s reported; want only the stuck request", s.Path)
+	default:
+	}
+}
@@ -0,0 +1,168 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// eventStreamContentType is the media type of Server-Sent Events.
+const eventStreamContentType = "text/event-stream"
+
+// IsEventStreamRequest reports whether the client of r accepts Server-Sent
+// Events, as EventSource clients do.
+func IsEventStreamRequest(r *http.Request) bool {
+	for _, accept := range r.Header[acceptHeader] {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err == nil && mediaType == eventStreamContentType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ForwardResponseStreamOrSSE forwards the stream from gRPC server to REST
+// client as Server-Sent Events if the client accepts them, and like
+// ForwardResponseStream otherwise.
+func ForwardResponseStreamOrSSE(ctx context.Context, mux *ServeMux, marshaler Marshaler, w http.ResponseWriter, req *http.Request, recv func() (proto.Message, error), opts ...func(context.Context, http.ResponseWriter, proto.Message) error) {
+	if IsEventStreamRequest(req) {
+		ForwardResponseStreamSSE(ctx, mux, marshaler, w, req, recv, opts...)
+		return
+	}
+	ForwardResponseStream(ctx, mux, marshaler, w, req, recv, opts...)
+}
+
+// ForwardResponseStreamSSE forwards the stream from gRPC server to REST client
+// as Server-Sent Events: every message is the data of an event, marshaled by
+// "marshaler", and an error ending the stream is the data of an "error" event.
+// Errors before the first message are replied with the mux error handler
+// instead, so that EventSource clients do not reconnect.
+func ForwardResponseStreamSSE(ctx context.Context, mux *ServeMux, marshaler Marshaler, w http.ResponseWriter, req *http.Request, recv func() (proto.Message, error), opts ...func(context.Context, http.ResponseWriter, proto.Message) error) {
+	f, ok := flusherOf(w)
+	if !ok {
+		grpclog.Infof("Flush not supported in %T", w)
+		http.Error(w, "unexpected type of web server", http.StatusInternalServerError)
+		return
+	}
+
+	md, ok := ServerMetadataFromContext(ctx)
+	if !ok {
+		grpclog.Infof("Failed to extract ServerMetadata from context")
+		http.Error(w, "unexpected error", http.StatusInternalServerError)
+		return
+	}
+	handleForwardResponseServerMetadata(ctx, w, mux, md)
+
+	sel, err := mux.partialResponseSelection(req)
+	if err != nil {
+		HTTPError(ctx, mux, marshaler, w, req, err)
+		return
+	}
+	if sel != nil {
+		marshaler = partialResponseMarshaler(marshaler)
+	}
+	if err := handleForwardResponseOptions(ctx, w, nil, opts); err != nil {
+		HTTPError(ctx, mux, marshaler, w, req, err)
+		return
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	var wroteHeader bool
+	fail := func(err error) {
+		if !wroteHeader {
+			HTTPError(ctx, mux, marshaler, w, req, err)
+			return
+		}
+		st := mux.streamErrorHandler(ctx, err)
+		data, merr := marshaler.Marshal(st.Proto())
+		if merr != nil {
+			grpclog.Infof("Failed to marshal an error: %v", merr)
+			return
+		}
+		if werr := writeServerSentEvent(w, "error", data); werr != nil {
+			grpclog.Infof("Failed to notify error to client: %v", werr)
+			return
+		}
+		f.Flush()
+	}
+	for {
+		resp, err := recv()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			fail(err)
+			return
+		}
+		if err := handleForwardResponseOptions(ctx, w, resp, opts); err != nil {
+			fail(err)
+			return
+		}
+
+		var body interface{} = resp
+		if rb, ok := resp.(responseBody); ok {
+			body = rb.XXX_ResponseBody()
+		}
+		buf.Reset()
+		if httpBody, ok := body.(*httpbody.HttpBody); ok {
+			buf.Write(httpBody.GetData())
+		} else {
+			if resp == nil {
+				err = status.Error(codes.Internal, "empty response")
+			}
+			if err == nil {
+				body, err = sel.apply(body)
+			}
+			if err == nil {
+				err = marshalToBuffer(marshaler, buf, mux.responseRedaction.redact(body))
+			}
+			if err != nil {
+				grpclog.Infof("Failed to marshal response chunk: %v", err)
+				fail(err)
+				return
+			}
+		}
+
+		if !wroteHeader {
+			w.Header().Set("Content-Type", eventStreamContentType)
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			wroteHeader = true
+		}
+		if err := writeServerSentEvent(w, "", buf.Bytes()); err != nil {
+			grpclog.Infof("Failed to send response chunk: %v", err)
+			return
+		}
+		f.Flush()
+	}
+}
+
+// writeServerSentEvent writes an event of type "event", or of the default
+// type if it is empty, with "data", which is split into data lines.
+func writeServerSentEvent(w io.Writer, event string, data []byte) error {
+	var buf bytes.Buffer
+	if event != "" {
+		buf.WriteString("event: " + event + "\n")
+	}
+	data = bytes.TrimRight(data, "\r\n")
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(bytes.TrimSuffix(line, []byte("\r")))
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
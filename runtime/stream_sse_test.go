@@ -0,0 +1,115 @@
+package runtime_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	pb "github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestIsEventStreamRequest(t *testing.T) {
+	for _, spec := range []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "", want: false},
+		{accept: "application/json", want: false},
+		{accept: "text/event-stream", want: true},
+		{accept: "application/json;q=0.9, text/event-stream;q=1", want: true},
+	} {
+		r := httptest.NewRequest("GET", "http://example.com/foo", nil)
+		if spec.accept != "" {
+			r.Header.Set("Accept", spec.accept)
+		}
+		if got := runtime.IsEventStreamRequest(r); got != spec.want {
+			t.Errorf("runtime.IsEventStreamRequest(Accept: %q) = %v; want %v", spec.accept, got, spec.want)
+		}
+	}
+}
+
+func TestForwardResponseStreamSSE(t *testing.T) {
+	type msg struct {
+		pb  proto.Message
+		err error
+	}
+	marshaler := &runtime.JSONPb{}
+	errData, err := marshaler.Marshal(status.New(codes.OutOfRange, "400").Proto())
+	if err != nil {
+		t.Fatalf("marshaler.Marshal() failed with %v; want success", err)
+	}
+	for _, spec := range []struct {
+		name       string
+		msgs       []msg
+		statusCode int
+		want       string
+	}{
+		{
+			name: "encoding",
+			msgs: []msg{
+				{&pb.SimpleMessage{Id: "One"}, nil},
+				{&pb.SimpleMessage{Id: "Two"}, nil},
+			},
+			statusCode: http.StatusOK,
+			want:       "data: {\"id\":\"One\"}\n\ndata: {\"id\":\"Two\"}\n\n",
+		},
+		{
+			name: "response body",
+			msgs: []msg{
+				{fakeReponseBodyWrapper{&pb.SimpleMessage{Id: "One"}}, nil},
+			},
+			statusCode: http.StatusOK,
+			want:       "data: \"One\"\n\n",
+		},
+		{
+			name:       "error",
+			msgs:       []msg{{nil, status.Error(codes.OutOfRange, "400")}},
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name: "stream error",
+			msgs: []msg{
+				{&pb.SimpleMessage{Id: "One"}, nil},
+				{nil, status.Error(codes.OutOfRange, "400")},
+			},
+			statusCode: http.StatusOK,
+			want:       "data: {\"id\":\"One\"}\n\nevent: error\ndata: " + string(errData) + "\n\n",
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			var count int
+			recv := func() (proto.Message, error) {
+				if count == len(spec.msgs) {
+					return nil, io.EOF
+				}
+				count++
+				return spec.msgs[count-1].pb, spec.msgs[count-1].err
+			}
+			ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+			req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+			req.Header.Set("Accept", "text/event-stream")
+			resp := httptest.NewRecorder()
+
+			runtime.ForwardResponseStreamOrSSE(ctx, runtime.NewServeMux(), marshaler, resp, req, recv)
+
+			if got, want := resp.Code, spec.statusCode; got != want {
+				t.Errorf("resp.Code = %d; want %d", got, want)
+			}
+			if spec.statusCode != http.StatusOK {
+				return
+			}
+			if got, want := resp.Header().Get("Content-Type"), "text/event-stream"; got != want {
+				t.Errorf("Content-Type = %q; want %q", got, want)
+			}
+			if got := resp.Body.String(); got != spec.want {
+				t.Errorf("resp.Body = %q; want %q", got, spec.want)
+			}
+		})
+	}
+}
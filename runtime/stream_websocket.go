@@ -0,0 +1,526 @@
+package runtime
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// webSocketGUID is the GUID the accept key of the WebSocket opening handshake
+// is derived with, see RFC 6455, section 1.3.
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebSocketMessageSize is the size limit of messages received over
+// WebSocket connections.
+const maxWebSocketMessageSize = 32 << 20
+
+// WebSocket opcodes and close codes, see RFC 6455, sections 5.2 and 7.4.1.
+const (
+	webSocketContinuation = 0x0
+	webSocketText         = 0x1
+	webSocketBinary       = 0x2
+	webSocketClose        = 0x8
+	webSocketPing         = 0x9
+	webSocketPong         = 0xa
+
+	webSocketCloseNormal        = 1000
+	webSocketCloseProtocolError = 1002
+	webSocketCloseTooBig        = 1009
+	webSocketCloseInternalError = 1011
+)
+
+var errWebSocketClosed = errors.New("websocket: connection closed")
+
+// IsWebSocketRequest reports whether r asks to upgrade the connection to the
+// WebSocket protocol.
+func IsWebSocketRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+func headerContainsToken(h http.Header, key, token string) bool {
+	for _, v := range h[key] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WithWebSocketProxyCompatibility returns a ServeMuxOption making the
+// WebSocket upgrades served by WebSocketHandler wire-compatible with
+// grpc-websocket-proxy, so that its clients can switch to the mux unchanged:
+//
+//   - a "Bearer, <token>" Sec-WebSocket-Protocol header is forwarded as the
+//     "Authorization: Bearer <token>" header, before the request is
+//     authenticated, and the "Bearer" subprotocol is selected,
+//   - the "method" query parameter, which selects the method of the
+//     proxied requests, is removed, since the routes registered with
+//     HandleWithWebSocket serve the upgrades whichever their method,
+//   - every line of the response is sent as its own text message.
+func WithWebSocketProxyCompatibility() ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.webSocketProxyCompat = true
+	}
+}
+
+// WithWebSocketOrigins returns a ServeMuxOption allowing the WebSocket
+// upgrades served by WebSocketHandler from the pages of "origins", e.g.
+// "https://app.example.com", or of any origin with "*". Browsers send the
+// cookies of the gateway with the upgrades of any page, so upgrades from
+// other origins than the gateway are rejected by default, to prevent
+// cross-site WebSocket hijacking. Upgrades without an Origin header, which
+// only browsers send, are always allowed.
+func WithWebSocketOrigins(origins ...string) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.webSocketOrigins = append(mux.webSocketOrigins, origins...)
+	}
+}
+
+type (
+	webSocketProxyKey   struct{}
+	webSocketOriginsKey struct{}
+)
+
+// adaptWebSocketRequest returns the WebSocket upgrade request "r" with the
+// origins allowed by s in its context, and as grpc-websocket-proxy forwards
+// it, if s is compatible with it.
+func (s *ServeMux) adaptWebSocketRequest(r *http.Request) *http.Request {
+	if (!s.webSocketProxyCompat && len(s.webSocketOrigins) == 0) || !IsWebSocketRequest(r) {
+		return r
+	}
+	if len(s.webSocketOrigins) > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), webSocketOriginsKey{}, s.webSocketOrigins))
+	}
+	if !s.webSocketProxyCompat {
+		return r
+	}
+	r = r.WithContext(context.WithValue(r.Context(), webSocketProxyKey{}, true))
+	if token, ok := webSocketBearerToken(r); ok {
+		r.Header = r.Header.Clone()
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	if q := r.URL.Query(); q.Get("method") != "" {
+		q.Del("method")
+		u := *r.URL
+		u.RawQuery = q.Encode()
+		r.URL = &u
+	}
+	return r
+}
+
+// webSocketBearerToken returns the token of the "Bearer, <token>"
+// Sec-WebSocket-Protocol header of "r", through which browsers, which cannot
+// set the Authorization header of WebSocket connections, pass their token.
+func webSocketBearerToken(r *http.Request) (string, bool) {
+	protocols := strings.Split(r.Header.Get("Sec-WebSocket-Protocol"), ",")
+	if len(protocols) != 2 || strings.TrimSpace(protocols[0]) != "Bearer" {
+		return "", false
+	}
+	token := strings.TrimSpace(protocols[1])
+	return token, token != ""
+}
+
+// WebSocketHandler returns a handler serving WebSocket upgrade requests with
+// the streaming handler h, and passing any other request to h unchanged.
+//
+// The messages received over the connection make up the request body, one
+// per line, and every flush of the response is sent as a message, i.e. every
+// message of a stream forwarded by ForwardResponseStream. The connection is
+// closed once h returns, with the close code 1011 if it replied an error
+// status. Upgrades from the pages of other origins than the gateway are
+// rejected with http.StatusForbidden, unless allowed with
+// WithWebSocketOrigins. See WithWebSocketProxyCompatibility for the
+// differences with grpc-websocket-proxy.
+func WebSocketHandler(h HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		if !IsWebSocketRequest(r) {
+			h(w, r, pathParams)
+			return
+		}
+		proxyCompat, _ := r.Context().Value(webSocketProxyKey{}).(bool)
+		var subprotocol string
+		if _, ok := webSocketBearerToken(r); ok && proxyCompat {
+			subprotocol = "Bearer"
+		}
+		conn, err := upgradeWebSocket(w, r, subprotocol)
+		if err != nil {
+			grpclog.Infof("Failed to upgrade to websocket: %v", err)
+			return
+		}
+		defer conn.close()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		req := r.WithContext(ctx)
+		req.Body = &webSocketReader{conn: conn, cancel: cancel}
+		req.ContentLength = -1
+		rw := &webSocketResponseWriter{conn: conn, header: make(http.Header), status: http.StatusOK, lines: proxyCompat}
+		h(rw, req, pathParams)
+		rw.Flush()
+
+		code := webSocketCloseNormal
+		if rw.status >= 400 {
+			code = webSocketCloseInternalError
+		}
+		conn.writeClose(code, "")
+	}
+}
+
+// HandleWithWebSocket registers a new handler like Handle, which also serves
+// WebSocket upgrade requests to "pat" through WebSocketHandler. Browsers can
+// thus call bidirectional streaming methods with "meth" other than GET.
+func (s *ServeMux) HandleWithWebSocket(meth string, pat Pattern, h HandlerFunc) {
+	if meth == http.MethodGet {
+		s.Handle(meth, pat, WebSocketHandler(h))
+		return
+	}
+	s.Handle(meth, pat, h)
+	s.HandleWebSocket(pat, h)
+}
+
+// HandleWebSocket registers a new handler serving only WebSocket upgrade
+// requests to "pat" with h, through WebSocketHandler. Other requests to "pat"
+// are passed to the routing error handler with http.StatusMethodNotAllowed.
+func (s *ServeMux) HandleWebSocket(pat Pattern, h HandlerFunc) {
+	ws := WebSocketHandler(h)
+	s.Handle(http.MethodGet, pat, func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		if !IsWebSocketRequest(r) {
+			_, outboundMarshaler := MarshalerForRequest(s, r)
+			s.routingErrorHandler(r.Context(), s, outboundMarshaler, w, r, http.StatusMethodNotAllowed)
+			return
+		}
+		ws(w, r, pathParams)
+	})
+}
+
+// upgradeWebSocket completes the opening handshake of the WebSocket upgrade
+// request r, selecting "subprotocol" unless it is empty, and replying with
+// http.StatusBadRequest if it is invalid or http.StatusForbidden if its
+// origin is not allowed.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, subprotocol string) (*webSocketConn, error) {
+	if !webSocketOriginAllowed(r) {
+		http.Error(w, "websocket origin not allowed", http.StatusForbidden)
+		return nil, fmt.Errorf("websocket origin %q not allowed", r.Header.Get("Origin"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return nil, errors.New("unsupported websocket version or missing key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "unexpected type of web server", http.StatusInternalServerError)
+		return nil, fmt.Errorf("hijack not supported in %T", w)
+	}
+	netConn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+	resp := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + accept + "\r\n"
+	if subprotocol != "" {
+		resp += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	if _, err := brw.WriteString(resp + "\r\n"); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	if err := brw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return &webSocketConn{conn: netConn, br: brw.Reader}, nil
+}
+
+// webSocketOriginAllowed reports whether the upgrade request r comes from
+// the same origin as the gateway, from an origin allowed with
+// WithWebSocketOrigins, or from a client other than a browser.
+func webSocketOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	allowed, _ := r.Context().Value(webSocketOriginsKey{}).([]string)
+	for _, o := range allowed {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// webSocketConn is the server side of a WebSocket connection.
+type webSocketConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	mu         sync.Mutex // guards writes and closeSent
+	closeSent  bool
+	readClosed bool
+}
+
+// readMessage returns the payload of the next data message, answering pings
+// on the way. It returns io.EOF once the peer closed the connection.
+func (c *webSocketConn) readMessage() ([]byte, error) {
+	if c.readClosed {
+		return nil, io.EOF
+	}
+	var msg []byte
+	var inMessage bool
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case webSocketPing:
+			if err := c.writeFrame(webSocketPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case webSocketPong:
+			continue
+		case webSocketClose:
+			c.readClosed = true
+			return nil, io.EOF
+		case webSocketText, webSocketBinary:
+			if inMessage {
+				return nil, c.fail(webSocketCloseProtocolError, "unexpected data frame")
+			}
+			inMessage = true
+		case webSocketContinuation:
+			if !inMessage {
+				return nil, c.fail(webSocketCloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return nil, c.fail(webSocketCloseProtocolError, "unknown opcode")
+		}
+		if len(msg)+len(payload) > maxWebSocketMessageSize {
+			return nil, c.fail(webSocketCloseTooBig, "message too big")
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+func (c *webSocketConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode = head[0]&0x80 != 0, head[0]&0x0f
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(webSocketCloseProtocolError, "reserved bits set")
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, c.fail(webSocketCloseProtocolError, "unmasked client frame")
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= webSocketClose && (n > 125 || !fin) {
+		return false, 0, nil, c.fail(webSocketCloseProtocolError, "invalid control frame")
+	}
+	if n > maxWebSocketMessageSize {
+		return false, 0, nil, c.fail(webSocketCloseTooBig, "message too big")
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeMessage sends data as a text message if it is valid UTF-8, and as a
+// binary message otherwise.
+func (c *webSocketConn) writeMessage(data []byte) error {
+	if utf8.Valid(data) {
+		return c.writeFrame(webSocketText, data)
+	}
+	return c.writeFrame(webSocketBinary, data)
+}
+
+func (c *webSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closeSent {
+		return errWebSocketClosed
+	}
+	return c.writeFrameLocked(opcode, payload)
+}
+
+func (c *webSocketConn) writeFrameLocked(opcode byte, payload []byte) error {
+	head := make([]byte, 2, 10)
+	head[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n < 126:
+		head[1] = byte(n)
+	case n <= 0xffff:
+		head[1] = 126
+		head = append(head, byte(n>>8), byte(n))
+	default:
+		head[1] = 127
+		head = head[:10]
+		binary.BigEndian.PutUint64(head[2:], uint64(n))
+	}
+	if _, err := c.conn.Write(append(head, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeClose sends a close frame with "code" and "reason", once.
+func (c *webSocketConn) writeClose(code int, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closeSent {
+		return
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if err := c.writeFrameLocked(webSocketClose, payload); err != nil {
+		grpclog.Infof("Failed to send websocket close frame: %v", err)
+	}
+	c.closeSent = true
+}
+
+// fail closes the connection after a protocol violation of the peer.
+func (c *webSocketConn) fail(code int, reason string) error {
+	c.writeClose(code, reason)
+	return fmt.Errorf("websocket: %s", reason)
+}
+
+func (c *webSocketConn) close() {
+	if err := c.conn.Close(); err != nil {
+		grpclog.Infof("Failed to close websocket connection: %v", err)
+	}
+}
+
+// webSocketReader is the request body of a WebSocket connection: its
+// messages, one per line. Reading errors other than the peer closing the
+// connection cancel the request context.
+type webSocketReader struct {
+	conn   *webSocketConn
+	cancel context.CancelFunc
+	buf    []byte
+	err    error
+}
+
+func (r *webSocketReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		msg, err := r.conn.readMessage()
+		if err != nil {
+			if err != io.EOF {
+				r.cancel()
+			}
+			r.err = err
+			continue
+		}
+		r.buf = append(msg, '\n')
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *webSocketReader) Close() error {
+	return nil
+}
+
+// webSocketResponseWriter sends what is written between flushes as one
+// message, without its trailing newline, or every line of it as a text
+// message if lines is set.
+type webSocketResponseWriter struct {
+	conn        *webSocketConn
+	header      http.Header
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	lines       bool
+}
+
+func (w *webSocketResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *webSocketResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader && !isInformational(code) {
+		w.status, w.wroteHeader = code, true
+	}
+}
+
+func (w *webSocketResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.buf.Write(p)
+}
+
+func (w *webSocketResponseWriter) Flush() {
+	if w.lines {
+		for _, line := range bytes.Split(w.buf.Bytes(), []byte("\n")) {
+			if line = bytes.TrimRight(line, "\r"); len(line) == 0 {
+				continue
+			}
+			if err := w.conn.writeFrame(webSocketText, line); err != nil {
+				grpclog.Infof("Failed to send websocket message: %v", err)
+				break
+			}
+		}
+		w.buf.Reset()
+		return
+	}
+	msg := bytes.TrimRight(w.buf.Bytes(), "\r\n")
+	if len(msg) == 0 {
+		w.buf.Reset()
+		return
+	}
+	if err := w.conn.writeMessage(msg); err != nil {
+		grpclog.Infof("Failed to send websocket message: %v", err)
+	}
+	w.buf.Reset()
+}
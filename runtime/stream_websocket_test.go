@@ -0,0 +1,234 @@
+package runtime_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// dialWebSocket opens a WebSocket connection to "path" of server.
+func dialWebSocket(t *testing.T, server *httptest.Server, path string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, br, _ := dialWebSocketWithHeader(t, server, path, nil)
+	return conn, br
+}
+
+// dialWebSocketWithHeader opens a WebSocket connection to "path" of server
+// with the additional request headers "header", and returns the handshake
+// response.
+func dialWebSocketWithHeader(t *testing.T, server *httptest.Server, path string, header http.Header) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("net.Dial(%q) failed with %v; want success", server.URL, err)
+	}
+	var extra strings.Builder
+	if err := header.Write(&extra); err != nil {
+		t.Fatal(err)
+	}
+	req := "GET " + path + " HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" + extra.String() + "\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatalf("conn.Write(%q) failed with %v; want success", req, err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("http.ReadResponse() failed with %v; want success", err)
+	}
+	if got, want := resp.StatusCode, http.StatusSwitchingProtocols; got != want {
+		t.Fatalf("resp.StatusCode = %d; want %d", got, want)
+	}
+	if got, want := resp.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("Sec-WebSocket-Accept = %q; want %q", got, want)
+	}
+	return conn, br, resp
+}
+
+func writeClientFrame(t *testing.T, conn net.Conn, opcode byte, payload []byte) {
+	t.Helper()
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatalf("conn.Write(%q) failed with %v; want success", frame, err)
+	}
+}
+
+func readServerFrame(t *testing.T, br *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		t.Fatalf("reading frame failed with %v; want success", err)
+	}
+	payload := make([]byte, head[1]&0x7f)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatalf("reading frame failed with %v; want success", err)
+	}
+	return head[0] & 0x0f, payload
+}
+
+func TestHandleWithWebSocket(t *testing.T) {
+	mux := runtime.NewServeMux()
+	pattern := runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"echo"}, ""))
+	mux.HandleWithWebSocket(http.MethodPost, pattern, func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			if scanner.Text() == "fail" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Write(bytes.ToUpper(scanner.Bytes()))
+			w.Write([]byte("\n"))
+			w.(http.Flusher).Flush()
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/echo", "text/plain", strings.NewReader("a\nb\n"))
+	if err != nil {
+		t.Fatalf("http.Post failed with %v; want success", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if got, want := string(body), "A\nB\n"; got != want {
+		t.Errorf("POST /echo = %q; want %q", got, want)
+	}
+
+	for _, spec := range []struct {
+		name      string
+		messages  []string
+		want      []string
+		closeCode uint16
+	}{
+		{
+			name:      "echo",
+			messages:  []string{"hello", "world"},
+			want:      []string{"HELLO", "WORLD"},
+			closeCode: 1000,
+		},
+		{
+			name:      "error",
+			messages:  []string{"fail"},
+			closeCode: 1011,
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			conn, br := dialWebSocket(t, server, "/echo")
+			defer conn.Close()
+
+			writeClientFrame(t, conn, 0x9, []byte("ping"))
+			if opcode, payload := readServerFrame(t, br); opcode != 0xa || string(payload) != "ping" {
+				t.Errorf("reply to ping = (%#x, %q); want (0xa, %q)", opcode, payload, "ping")
+			}
+			for _, msg := range spec.messages {
+				writeClientFrame(t, conn, 0x1, []byte(msg))
+			}
+			writeClientFrame(t, conn, 0x8, []byte{0x03, 0xe8})
+			for _, want := range spec.want {
+				if opcode, payload := readServerFrame(t, br); opcode != 0x1 || string(payload) != want {
+					t.Errorf("message = (%#x, %q); want (0x1, %q)", opcode, payload, want)
+				}
+			}
+			opcode, payload := readServerFrame(t, br)
+			if opcode != 0x8 || len(payload) < 2 || binary.BigEndian.Uint16(payload) != spec.closeCode {
+				t.Errorf("close frame = (%#x, %q); want close code %d", opcode, payload, spec.closeCode)
+			}
+		})
+	}
+
+	resp, err = http.Get(server.URL + "/echo")
+	if err != nil {
+		t.Fatalf("http.Get failed with %v; want success", err)
+	}
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusNotImplemented; got != want {
+		t.Errorf("GET /echo without upgrade = %d; want %d", got, want)
+	}
+}
+
+func TestWebSocketProxyCompatibility(t *testing.T) {
+	mux := runtime.NewServeMux(runtime.WithWebSocketProxyCompatibility())
+	pattern := runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"echo"}, ""))
+	mux.HandleWithWebSocket(http.MethodPost, pattern, func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			// Both messages are flushed at once, but sent separately.
+			fmt.Fprintf(w, "%s\n%s %s\n", scanner.Text(), r.Header.Get("Authorization"), r.URL.RawQuery)
+			w.(http.Flusher).Flush()
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	header := http.Header{"Sec-Websocket-Protocol": {"Bearer, token"}}
+	conn, br, resp := dialWebSocketWithHeader(t, server, "/echo?method=POST&a=b", header)
+	defer conn.Close()
+	if got, want := resp.Header.Get("Sec-WebSocket-Protocol"), "Bearer"; got != want {
+		t.Errorf("Sec-WebSocket-Protocol = %q; want %q", got, want)
+	}
+
+	writeClientFrame(t, conn, 0x2, []byte(`{"msg": "hello"}`))
+	for _, want := range []string{`{"msg": "hello"}`, "Bearer token a=b"} {
+		if opcode, payload := readServerFrame(t, br); opcode != 0x1 || string(payload) != want {
+			t.Errorf("message = (%#x, %q); want (0x1, %q)", opcode, payload, want)
+		}
+	}
+	writeClientFrame(t, conn, 0x8, []byte{0x03, 0xe8})
+	if opcode, _ := readServerFrame(t, br); opcode != 0x8 {
+		t.Errorf("opcode = %#x; want a close frame", opcode)
+	}
+}
+
+func TestWebSocketOrigins(t *testing.T) {
+	pattern := runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"echo"}, ""))
+	upgrade := func(mux *runtime.ServeMux, origin string) int {
+		r := httptest.NewRequest("GET", "http://example.com/echo", nil)
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		r.Header.Set("Sec-WebSocket-Version", "13")
+		r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+	handler := func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {}
+
+	mux := runtime.NewServeMux()
+	mux.HandleWebSocket(pattern, handler)
+	if code := upgrade(mux, "https://evil.example.org"); code != http.StatusForbidden {
+		t.Errorf("cross-origin upgrade = %d; want %d", code, http.StatusForbidden)
+	}
+
+	mux = runtime.NewServeMux(runtime.WithWebSocketOrigins("https://app.example.org"))
+	mux.HandleWebSocket(pattern, handler)
+	if code := upgrade(mux, "https://evil.example.org"); code != http.StatusForbidden {
+		t.Errorf("upgrade from an origin not allowed = %d; want %d", code, http.StatusForbidden)
+	}
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	for _, origin := range []string{"", "http://example.com", "https://app.example.org"} {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, _, _ := dialWebSocketWithHeader(t, server, "/echo", header)
+		conn.Close()
+	}
+}
@@ -0,0 +1,177 @@
+package runtime
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TenantResolver returns the tenant of a request, or "" if the request does
+// not identify one. Errors are replied through the error handler, so they
+// should be created with the status package.
+type TenantResolver func(r *http.Request) (string, error)
+
+// TenantConfig configures the tenant resolution of a ServeMux, see
+// WithTenantResolution.
+type TenantConfig struct {
+	// Resolvers resolve the tenant of the requests. The first tenant
+	// resolved is used.
+	Resolvers []TenantResolver
+	// Required rejects the requests whose tenant is not resolved with
+	// codes.InvalidArgument.
+	Required bool
+	// MetadataKey is the key of the outgoing metadata the tenant is
+	// forwarded in. It defaults to "x-tenant-id". Request headers forwarded
+	// in this key are dropped, so that clients cannot forge it.
+	MetadataKey string
+}
+
+// WithTenantResolution returns a ServeMuxOption resolving the tenant of every
+// request before it is routed. The tenant is available to handlers with
+// TenantFromContext, forwarded in the outgoing metadata, and scopes the
+// routes of a ServeMuxDynamic registered with WithRouteTenants.
+func WithTenantResolution(config TenantConfig) ServeMuxOption {
+	return func(mux *ServeMux) {
+		if config.MetadataKey == "" {
+			config.MetadataKey = "x-tenant-id"
+		}
+		mux.tenantConfig = &config
+		mux.metadataAnnotators = append(mux.metadataAnnotators, func(ctx context.Context, req *http.Request) metadata.MD {
+			if tenant, ok := TenantFromContext(req.Context()); ok {
+				return metadata.Pairs(config.MetadataKey, tenant)
+			}
+			return nil
+		})
+		mux.gatewayMetadata = append(mux.gatewayMetadata, config.MetadataKey)
+	}
+}
+
+// WithRouteTenants returns a RouteOption restricting the route to the
+// requests of "tenants", resolved by WithTenantResolution, so that each
+// tenant can have its own routes. Routes registered without it serve all the
+// tenants.
+func WithRouteTenants(tenants ...string) RouteOption {
+	return func(o *routeOptions) {
+		o.tenants = append(o.tenants, tenants...)
+	}
+}
+
+type tenantKey struct{}
+
+// TenantFromContext returns the tenant of the request resolved by
+// WithTenantResolution.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
+
+// TenantFromHeader returns a TenantResolver reading the tenant from the
+// request header "name".
+func TenantFromHeader(name string) TenantResolver {
+	return func(r *http.Request) (string, error) {
+		return r.Header.Get(name), nil
+	}
+}
+
+// TenantFromHost returns a TenantResolver reading the tenant from the
+// subdomain of the requests to the hosts ending with "suffix", e.g. "acme"
+// from "acme.api.example.com" with the suffix ".api.example.com".
+func TenantFromHost(suffix string) TenantResolver {
+	return func(r *http.Request) (string, error) {
+		host := r.Host
+		if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+			host = host[:i]
+		}
+		if len(host) <= len(suffix) || !strings.EqualFold(host[len(host)-len(suffix):], suffix) {
+			return "", nil
+		}
+		return strings.ToLower(host[:len(host)-len(suffix)]), nil
+	}
+}
+
+// TenantFromPathPrefix returns a TenantResolver reading the tenant from the
+// path segment following "prefix", e.g. "acme" from "/tenants/acme/v1/books"
+// with the prefix "/tenants". The prefix and the tenant are removed from the
+// path of the request, so that it is routed as "/v1/books".
+func TenantFromPathPrefix(prefix string) TenantResolver {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	return func(r *http.Request) (string, error) {
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			return "", nil
+		}
+		rest := r.URL.Path[len(prefix):]
+		i := strings.IndexByte(rest, '/')
+		if i <= 0 {
+			return "", nil
+		}
+		u := *r.URL
+		u.Path, u.RawPath = rest[i:], ""
+		r.URL = &u
+		return rest[:i], nil
+	}
+}
+
+// TenantFromJWTClaim returns a TenantResolver reading the tenant from the
+// claim "claim" of the JSON Web Token in the "Authorization: Bearer" header,
+// verified as by WithJWTAuth with "config". Requests with an invalid token
+// are rejected with codes.Unauthenticated.
+func TenantFromJWTClaim(config JWTConfig, claim string) TenantResolver {
+	a := newJWTAuth(config)
+	return func(r *http.Request) (string, error) {
+		token := bearerToken(r)
+		if token == "" {
+			return "", nil
+		}
+		claims, err := a.verify(r.Context(), token)
+		if err != nil {
+			return "", status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+		}
+		if values := jwtClaimValues(claims[claim]); len(values) == 1 {
+			return values[0], nil
+		}
+		return "", nil
+	}
+}
+
+// resolveTenant resolves the tenant of "r" and returns "r" carrying it in its
+// context. It replies with an error and returns false if the tenant cannot be
+// resolved.
+func (s *ServeMux) resolveTenant(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if s.tenantConfig == nil {
+		return r, true
+	}
+	for _, resolve := range s.tenantConfig.Resolvers {
+		tenant, err := resolve(r)
+		if err != nil {
+			_, outboundMarshaler := MarshalerForRequest(s, r)
+			s.errorHandler(r.Context(), s, outboundMarshaler, w, r, err)
+			return nil, false
+		}
+		if tenant != "" {
+			return r.WithContext(context.WithValue(r.Context(), tenantKey{}, tenant)), true
+		}
+	}
+	if s.tenantConfig.Required {
+		_, outboundMarshaler := MarshalerForRequest(s, r)
+		s.errorHandler(r.Context(), s, outboundMarshaler, w, r, status.Error(codes.InvalidArgument, "missing tenant"))
+		return nil, false
+	}
+	return r, true
+}
+
+// servesTenant reports whether h serves the requests of "tenant".
+func (h handler) servesTenant(tenant string) bool {
+	if h.opts == nil || len(h.opts.tenants) == 0 {
+		return true
+	}
+	for _, t := range h.opts.tenants {
+		if t == tenant {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package runtime_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTenantResolution(t *testing.T) {
+	mux := runtime.NewServeMuxDynamic(runtime.WithTenantResolution(runtime.TenantConfig{
+		Resolvers: []runtime.TenantResolver{
+			runtime.TenantFromHeader("X-Tenant"),
+			runtime.TenantFromPathPrefix("/tenants"),
+			runtime.TenantFromHost(".api.example.com"),
+		},
+	}))
+	handler := func(name string) runtime.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+			ctx, err := runtime.AnnotateContext(r.Context(), mux.ServeMux, r, "/example.Service/Method")
+			if err != nil {
+				t.Fatal(err)
+			}
+			md, _ := metadata.FromOutgoingContext(ctx)
+			tenant, _ := runtime.TenantFromContext(r.Context())
+			fmt.Fprintf(w, "%s tenant=%s metadata=%v", name, tenant, md.Get("x-tenant-id"))
+		}
+	}
+	if err := mux.HandlePath("GET", "/v1/books", handler("global")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.HandlePath("GET", "/v1/books", handler("acme"), runtime.WithRouteTenants("acme")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, spec := range []struct {
+		name   string
+		host   string
+		path   string
+		header string
+		want   string
+	}{
+		{name: "no tenant", path: "/v1/books", want: "global tenant= metadata=[]"},
+		{name: "header", path: "/v1/books", header: "acme", want: "acme tenant=acme metadata=[acme]"},
+		{name: "other tenant", path: "/v1/books", header: "initech", want: "global tenant=initech metadata=[initech]"},
+		{name: "path prefix", path: "/tenants/acme/v1/books", want: "acme tenant=acme metadata=[acme]"},
+		{name: "host", host: "ACME.api.example.com:8080", path: "/v1/books", want: "acme tenant=acme metadata=[acme]"},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", spec.path, nil)
+			if spec.host != "" {
+				r.Host = spec.host
+			}
+			if spec.header != "" {
+				r.Header.Set("X-Tenant", spec.header)
+			}
+			r.Header.Set("Grpc-Metadata-X-Tenant-Id", "umbrella")
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if got := w.Body.String(); got != spec.want {
+				t.Errorf("w.Body = %q; want %q", got, spec.want)
+			}
+		})
+	}
+}
+
+func TestTenantResolutionRequired(t *testing.T) {
+	mux := runtime.NewServeMux(runtime.WithTenantResolution(runtime.TenantConfig{
+		Resolvers: []runtime.TenantResolver{runtime.TenantFromHeader("X-Tenant")},
+		Required:  true,
+	}))
+	err := mux.HandlePath("GET", "/v1/books", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/books", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("w.Code = %d; want %d without a tenant", w.Code, http.StatusBadRequest)
+	}
+
+	r := httptest.NewRequest("GET", "/v1/books", nil)
+	r.Header.Set("X-Tenant", "acme")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("w.Code = %d; want %d with a tenant", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,242 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TimestampFormat is how google.protobuf.Timestamp values are encoded, see TimeEncoding.
+type TimestampFormat int
+
+const (
+	// TimestampRFC3339 encodes timestamps as RFC 3339 strings, as specified by
+	// the proto3 JSON mapping.
+	TimestampRFC3339 TimestampFormat = iota
+	// TimestampUnixSeconds encodes timestamps as numbers of seconds since the
+	// Unix epoch, with a fractional part for sub-second precision.
+	TimestampUnixSeconds
+	// TimestampUnixMillis encodes timestamps as integer numbers of milliseconds
+	// since the Unix epoch. Sub-millisecond precision is truncated.
+	TimestampUnixMillis
+	// TimestampLayout encodes timestamps as strings in UTC formatted with
+	// TimeEncoding.Layout.
+	TimestampLayout
+)
+
+// DurationFormat is how google.protobuf.Duration values are encoded, see TimeEncoding.
+type DurationFormat int
+
+const (
+	// DurationString encodes durations as strings of seconds with an "s"
+	// suffix, e.g. "1.5s", as specified by the proto3 JSON mapping.
+	DurationString DurationFormat = iota
+	// DurationSeconds encodes durations as numbers of seconds, with a
+	// fractional part for sub-second precision.
+	DurationSeconds
+	// DurationMillis encodes durations as integer numbers of milliseconds.
+	// Sub-millisecond precision is truncated.
+	DurationMillis
+)
+
+// TimeEncoding configures alternative encodings of google.protobuf.Timestamp
+// and google.protobuf.Duration values. It is used by JSONPb for request and
+// response bodies, and by TimeEncodingQueryParser for query parameters.
+//
+// Values in the encodings of the proto3 JSON mapping are accepted when
+// decoding in any case.
+type TimeEncoding struct {
+	// Timestamp is the encoding of timestamps.
+	Timestamp TimestampFormat
+	// Layout is the time.Format layout of timestamps encoded with TimestampLayout.
+	Layout string
+	// Duration is the encoding of durations.
+	Duration DurationFormat
+}
+
+func (e TimeEncoding) isDefault() bool {
+	return e.Timestamp == TimestampRFC3339 && e.Duration == DurationString
+}
+
+// encode rewrites the proto3 JSON value v of a timestamp or duration of type
+// md into its configured encoding.
+func (e TimeEncoding) encode(md protoreflect.MessageDescriptor, v interface{}) (interface{}, bool, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, false, nil
+	}
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		if e.Timestamp == TimestampRFC3339 {
+			return nil, false, nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, false, err
+		}
+		switch e.Timestamp {
+		case TimestampUnixSeconds:
+			return json.Number(formatSecondsNanos(t.Unix(), int32(t.Nanosecond()))), true, nil
+		case TimestampUnixMillis:
+			return json.Number(strconv.FormatInt(t.Unix()*1000+int64(t.Nanosecond())/1e6, 10)), true, nil
+		case TimestampLayout:
+			return t.UTC().Format(e.Layout), true, nil
+		}
+	case "google.protobuf.Duration":
+		if e.Duration == DurationString {
+			return nil, false, nil
+		}
+		seconds, nanos, err := parseSecondsNanos(strings.TrimSuffix(s, "s"))
+		if err != nil {
+			return nil, false, err
+		}
+		switch e.Duration {
+		case DurationSeconds:
+			return json.Number(formatSecondsNanos(seconds, nanos)), true, nil
+		case DurationMillis:
+			return json.Number(strconv.FormatInt(seconds*1000+int64(nanos)/1e6, 10)), true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// decode rewrites v, a timestamp or duration of type md in its configured
+// encoding, into its proto3 JSON value.
+func (e TimeEncoding) decode(md protoreflect.MessageDescriptor, v interface{}) (interface{}, bool, error) {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		switch v := v.(type) {
+		case json.Number:
+			seconds, nanos, err := parseTimeNumber(string(v), e.Timestamp == TimestampUnixMillis)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid timestamp %q: %v", v, err)
+			}
+			return time.Unix(seconds, int64(nanos)).UTC().Format(time.RFC3339Nano), true, nil
+		case string:
+			if e.Timestamp != TimestampLayout {
+				return nil, false, nil
+			}
+			if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return nil, false, nil
+			}
+			t, err := time.Parse(e.Layout, v)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid timestamp %q: %v", v, err)
+			}
+			return t.UTC().Format(time.RFC3339Nano), true, nil
+		}
+	case "google.protobuf.Duration":
+		if v, ok := v.(json.Number); ok {
+			seconds, nanos, err := parseTimeNumber(string(v), e.Duration == DurationMillis)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid duration %q: %v", v, err)
+			}
+			return formatSecondsNanos(seconds, nanos) + "s", true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// parseTimeNumber parses a number of seconds, or of milliseconds if millis is set.
+func parseTimeNumber(s string, millis bool) (int64, int32, error) {
+	if !millis {
+		return parseSecondsNanos(s)
+	}
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return ms / 1000, int32(ms%1000) * 1e6, nil
+}
+
+// formatSecondsNanos formats seconds and nanos, which have the same sign, as
+// a decimal number of seconds.
+func formatSecondsNanos(seconds int64, nanos int32) string {
+	if seconds < 0 && nanos > 0 {
+		// Timestamps are floored, nanos are always positive.
+		seconds, nanos = seconds+1, nanos-1e9
+	}
+	sign := ""
+	if seconds < 0 || nanos < 0 {
+		sign, seconds, nanos = "-", -seconds, -nanos
+	}
+	if nanos == 0 {
+		return sign + strconv.FormatInt(seconds, 10)
+	}
+	return sign + strconv.FormatInt(seconds, 10) + "." + strings.TrimRight(fmt.Sprintf("%09d", nanos), "0")
+}
+
+// parseSecondsNanos parses a decimal number of seconds into seconds and nanos
+// with the same sign.
+func parseSecondsNanos(s string) (int64, int32, error) {
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		if f >= math.MaxInt64 || f <= math.MinInt64 {
+			return 0, 0, fmt.Errorf("%s out of range", s)
+		}
+		seconds := int64(f)
+		return seconds, int32(math.Round((f - float64(seconds)) * 1e9)), nil
+	}
+	neg := strings.HasPrefix(s, "-")
+	intPart, fracPart := strings.TrimPrefix(s, "-"), ""
+	if i := strings.IndexByte(intPart, '.'); i >= 0 {
+		intPart, fracPart = intPart[:i], intPart[i+1:]
+	}
+	if len(fracPart) > 9 {
+		fracPart = fracPart[:9]
+	}
+	seconds, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	var nanos int64
+	if fracPart != "" {
+		if nanos, err = strconv.ParseInt(fracPart+strings.Repeat("0", 9-len(fracPart)), 10, 32); err != nil {
+			return 0, 0, err
+		}
+	}
+	if neg {
+		return -seconds, int32(-nanos), nil
+	}
+	return seconds, int32(nanos), nil
+}
+
+// queryValue converts the query parameter value of a timestamp or duration
+// of type md in its configured encoding into the format parsed by the
+// default query parser.
+func (e TimeEncoding) queryValue(md protoreflect.MessageDescriptor, value string) (string, error) {
+	var v interface{} = value
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		v = json.Number(value)
+	}
+	decoded, ok, err := e.decode(md, v)
+	if err != nil || !ok {
+		return value, err
+	}
+	return decoded.(string), nil
+}
+
+// TimeEncodingQueryParser is a QueryParameterParser which parses
+// google.protobuf.Timestamp and google.protobuf.Duration query parameters in
+// the configured TimeEncoding, and otherwise behaves like the default parser.
+// Register it with SetQueryParameterParser.
+type TimeEncodingQueryParser struct {
+	TimeEncoding
+}
+
+// Parse populates "values" into "msg".
+// A value is ignored if its key starts with one of the elements in "filter".
+func (p *TimeEncodingQueryParser) Parse(msg proto.Message, values url.Values, filter *utilities.DoubleArray) error {
+	return parseQueryParameters(msg, values, filter, &p.TimeEncoding)
+}
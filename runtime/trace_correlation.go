@@ -0,0 +1,102 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// TraceCorrelation configures the correlation of the requests with their
+// traces, see WithTraceCorrelation.
+type TraceCorrelation struct {
+	// TraceID returns the trace ID of a request, e.g. from the span of a
+	// tracing middleware in its context, or "" if it is not traced. It
+	// defaults to the trace ID of its W3C traceparent header.
+	TraceID func(ctx context.Context, r *http.Request) string
+	// ErrorField is the field of the JSON error bodies rendered by
+	// DefaultHTTPErrorHandler carrying the trace ID. It defaults to
+	// "trace_id", and is not added if it is "-".
+	ErrorField string
+}
+
+// WithTraceCorrelation returns a ServeMuxOption correlating the requests
+// with their traces, so that metrics, logs and errors can be tied together:
+// the trace ID of a request is available to access loggers with
+// TraceIDFromContext, is the exemplar of its metric, see
+// RequestMetric.TraceID, and is added to its JSON error body.
+func WithTraceCorrelation(config TraceCorrelation) ServeMuxOption {
+	if config.TraceID == nil {
+		config.TraceID = func(_ context.Context, r *http.Request) string {
+			return traceparentTraceID(r.Header.Get("traceparent"))
+		}
+	}
+	if config.ErrorField == "" {
+		config.ErrorField = "trace_id"
+	}
+	return func(mux *ServeMux) {
+		mux.traceCorrelation = &config
+	}
+}
+
+type traceIDKey struct{}
+
+// TraceIDFromContext returns the trace ID of the request of "ctx", see
+// WithTraceCorrelation.
+func TraceIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(traceIDKey{}).(string)
+	return id, ok
+}
+
+// correlateTrace returns r carrying its trace ID in its context, if any.
+func (s *ServeMux) correlateTrace(r *http.Request) *http.Request {
+	if s.traceCorrelation == nil {
+		return r
+	}
+	id := s.traceCorrelation.TraceID(r.Context(), r)
+	if id == "" {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), traceIDKey{}, id))
+}
+
+// traceparentTraceID returns the trace ID of the W3C traceparent header
+// "traceparent", or "" if it is invalid.
+func traceparentTraceID(traceparent string) string {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 {
+		return ""
+	}
+	id := parts[1]
+	if strings.Trim(id, "0") == "" || strings.Trim(id, "0123456789abcdef") != "" {
+		return ""
+	}
+	return id
+}
+
+// withTraceIDField returns the JSON object "body" with the trace ID of the
+// request of "ctx" added, if any.
+func (s *ServeMux) withTraceIDField(ctx context.Context, body []byte, contentType string) []byte {
+	if s.traceCorrelation == nil || s.traceCorrelation.ErrorField == "-" || !isJSONContentType(contentType) {
+		return body
+	}
+	id, ok := TraceIDFromContext(ctx)
+	if !ok {
+		return body
+	}
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) < 2 || trimmed[0] != '{' || trimmed[len(trimmed)-1] != '}' {
+		return body
+	}
+	field, err := json.Marshal(map[string]string{s.traceCorrelation.ErrorField: id})
+	if err != nil {
+		return body
+	}
+	out := make([]byte, 0, len(trimmed)+len(field))
+	out = append(out, trimmed[:len(trimmed)-1]...)
+	if len(bytes.TrimSpace(trimmed[1:len(trimmed)-1])) > 0 {
+		out = append(out, ',')
+	}
+	return append(out, field[1:]...)
+}
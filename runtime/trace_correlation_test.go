@@ -0,0 +1,98 @@
+package runtime_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTraceCorrelation(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	var (
+		metricTraceID  string
+		handlerTraceID string
+	)
+	recorder := runtime.RequestMetricsRecorderFunc(func(ctx context.Context, metric runtime.RequestMetric) {
+		metricTraceID = metric.TraceID
+	})
+
+	for _, spec := range []struct {
+		name        string
+		config      runtime.TraceCorrelation
+		traceparent string
+		wantTraceID string
+		wantField   string
+	}{
+		{
+			name:        "traceparent",
+			traceparent: "00-" + traceID + "-00f067aa0ba902b7-01",
+			wantTraceID: traceID,
+			wantField:   "trace_id",
+		},
+		{
+			name:        "custom field",
+			config:      runtime.TraceCorrelation{ErrorField: "traceId"},
+			traceparent: "00-" + traceID + "-00f067aa0ba902b7-01",
+			wantTraceID: traceID,
+			wantField:   "traceId",
+		},
+		{
+			name:        "no field",
+			config:      runtime.TraceCorrelation{ErrorField: "-"},
+			traceparent: "00-" + traceID + "-00f067aa0ba902b7-01",
+			wantTraceID: traceID,
+		},
+		{
+			name:        "invalid traceparent",
+			traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		},
+		{
+			name: "custom extractor",
+			config: runtime.TraceCorrelation{TraceID: func(ctx context.Context, r *http.Request) string {
+				return "span-" + r.Header.Get("X-Span")
+			}},
+			wantTraceID: "span-1",
+			wantField:   "trace_id",
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			metricTraceID, handlerTraceID = "", ""
+			mux := runtime.NewServeMuxDynamic(runtime.WithTraceCorrelation(spec.config), runtime.WithRequestMetrics(recorder))
+			if err := mux.HandlePath("GET", "/v1/books", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+				handlerTraceID, _ = runtime.TraceIDFromContext(r.Context())
+				_, outbound := runtime.MarshalerForRequest(mux.ServeMux, r)
+				runtime.HTTPError(r.Context(), mux.ServeMux, outbound, w, r, status.Error(codes.NotFound, "not found"))
+			}); err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest("GET", "/v1/books", nil)
+			r.Header.Set("traceparent", spec.traceparent)
+			r.Header.Set("X-Span", "1")
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+
+			if handlerTraceID != spec.wantTraceID || metricTraceID != spec.wantTraceID {
+				t.Errorf("trace ID = %q in the handler and %q in the metric; want %q", handlerTraceID, metricTraceID, spec.wantTraceID)
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("json.Unmarshal(%s) failed with %v; want success", w.Body, err)
+			}
+			if body["message"] != "not found" {
+				t.Errorf("error body = %s; want the status", w.Body)
+			}
+			for _, field := range []string{"trace_id", "traceId"} {
+				got, ok := body[field]
+				if want := field == spec.wantField; ok != want || (want && got != spec.wantTraceID) {
+					t.Errorf("error body = %s; want %q = %q", w.Body, spec.wantField, spec.wantTraceID)
+				}
+			}
+		})
+	}
+}
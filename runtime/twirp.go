@@ -0,0 +1,232 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// WithTwirpPrefix returns a ServeMuxOption making a ServeMuxDynamic serve the
+// Twirp protocol at "prefix", e.g. "/twirp", for the services registered with
+// ServeMuxDynamic.RegisterTwirpService, so that Twirp clients can call them
+// at "<prefix>/<package>.<Service>/<Method>". It has no effect on a ServeMux.
+func WithTwirpPrefix(prefix string) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.twirpPrefix = strings.TrimSuffix(prefix, "/")
+	}
+}
+
+// twirpMethods holds the Twirp methods registered on a ServeMuxDynamic.
+type twirpMethods struct {
+	mu      sync.RWMutex
+	lastID  uint64
+	methods map[string]twirpRegistration
+}
+
+type twirpRegistration struct {
+	id      uint64
+	method  protoreflect.MethodDescriptor
+	binding routeBinding
+}
+
+// RegisterTwirpService exposes the unary methods of "sd" with a
+// google.api.http option through the Twirp protocol, with JSON and protobuf
+// bodies. Calls of the methods are served as requests to their main binding
+// through the mux, so they are authenticated, authorized and forwarded to
+// the backends as the REST API is. It returns a function deregistering the
+// service.
+func (s *ServeMuxDynamic) RegisterTwirpService(sd protoreflect.ServiceDescriptor) (deregister func(), err error) {
+	regs := make(map[string]twirpRegistration)
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		if md.IsStreamingClient() || md.IsStreamingServer() {
+			continue
+		}
+		rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if !ok || rule == nil {
+			continue
+		}
+		method, template := httpRuleRoute(rule)
+		if method == "" {
+			continue
+		}
+		if _, err := parsePattern(template); err != nil {
+			return nil, fmt.Errorf("invalid pattern of %s: %v", md.FullName(), err)
+		}
+		regs[string(sd.FullName())+"/"+string(md.Name())] = twirpRegistration{
+			method:  md,
+			binding: routeBinding{httpMethod: method, pattern: template, body: rule.GetBody()},
+		}
+	}
+
+	s.twirp.mu.Lock()
+	defer s.twirp.mu.Unlock()
+
+	if s.twirp.methods == nil {
+		s.twirp.methods = make(map[string]twirpRegistration)
+	}
+	s.twirp.lastID++
+	id := s.twirp.lastID
+	for name, reg := range regs {
+		reg.id = id
+		s.twirp.methods[name] = reg
+	}
+
+	return func() {
+		s.twirp.mu.Lock()
+		defer s.twirp.mu.Unlock()
+
+		for name := range regs {
+			if reg, ok := s.twirp.methods[name]; ok && reg.id == id {
+				delete(s.twirp.methods, name)
+			}
+		}
+	}, nil
+}
+
+// twirpError is an error of the Twirp protocol.
+type twirpError struct {
+	Code string            `json:"code"`
+	Msg  string            `json:"msg"`
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+// twirpCodes are the Twirp error codes of the gRPC codes, and their HTTP
+// statuses.
+var twirpCodes = map[codes.Code]struct {
+	code   string
+	status int
+}{
+	codes.Canceled:           {"canceled", http.StatusRequestTimeout},
+	codes.Unknown:            {"unknown", http.StatusInternalServerError},
+	codes.InvalidArgument:    {"invalid_argument", http.StatusBadRequest},
+	codes.DeadlineExceeded:   {"deadline_exceeded", http.StatusRequestTimeout},
+	codes.NotFound:           {"not_found", http.StatusNotFound},
+	codes.AlreadyExists:      {"already_exists", http.StatusConflict},
+	codes.PermissionDenied:   {"permission_denied", http.StatusForbidden},
+	codes.ResourceExhausted:  {"resource_exhausted", http.StatusTooManyRequests},
+	codes.FailedPrecondition: {"failed_precondition", http.StatusPreconditionFailed},
+	codes.Aborted:            {"aborted", http.StatusConflict},
+	codes.OutOfRange:         {"out_of_range", http.StatusBadRequest},
+	codes.Unimplemented:      {"unimplemented", http.StatusNotImplemented},
+	codes.Internal:           {"internal", http.StatusInternalServerError},
+	codes.Unavailable:        {"unavailable", http.StatusServiceUnavailable},
+	codes.DataLoss:           {"dataloss", http.StatusInternalServerError},
+	codes.Unauthenticated:    {"unauthenticated", http.StatusUnauthorized},
+}
+
+func writeTwirpError(w http.ResponseWriter, code string, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(twirpError{Code: code, Msg: msg}); err != nil {
+		grpclog.Infof("Failed to write Twirp error: %v", err)
+	}
+}
+
+// writeTwirpStatus writes the error with the gRPC code "c".
+func writeTwirpStatus(w http.ResponseWriter, c codes.Code, msg string) {
+	tc, ok := twirpCodes[c]
+	if !ok {
+		tc = twirpCodes[codes.Unknown]
+	}
+	writeTwirpError(w, tc.code, tc.status, msg)
+}
+
+// serveTwirp serves the call of the Twirp method "name", e.g.
+// "library.v1.LibraryService/GetBook".
+func (s *ServeMuxDynamic) serveTwirp(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		writeTwirpError(w, "bad_route", http.StatusNotFound, fmt.Sprintf("unsupported method %q (only POST is allowed)", r.Method))
+		return
+	}
+	s.twirp.mu.RLock()
+	reg, ok := s.twirp.methods[name]
+	s.twirp.mu.RUnlock()
+	if !ok {
+		writeTwirpError(w, "bad_route", http.StatusNotFound, fmt.Sprintf("no handler for path %q", r.URL.Path))
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != "application/json" && contentType != "application/protobuf" {
+		writeTwirpError(w, "bad_route", http.StatusNotFound, fmt.Sprintf("unexpected Content-Type: %q", r.Header.Get("Content-Type")))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeTwirpError(w, "malformed", http.StatusBadRequest, fmt.Sprintf("failed to read request body: %v", err))
+		return
+	}
+	in := dynamicpb.NewMessage(reg.method.Input())
+	if contentType == "application/json" {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, in)
+	} else {
+		err = proto.Unmarshal(body, in)
+	}
+	if err != nil {
+		writeTwirpError(w, "malformed", http.StatusBadRequest, fmt.Sprintf("the request could not be decoded: %v", err))
+		return
+	}
+
+	// The params are named after the fields, as the variables of the path
+	// templates and the body fields of the bindings are.
+	raw, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(in)
+	if err != nil {
+		writeTwirpStatus(w, codes.Internal, err.Error())
+		return
+	}
+	params := map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&params); err != nil {
+		writeTwirpStatus(w, codes.Internal, err.Error())
+		return
+	}
+	sub, err := reg.binding.request(r, params)
+	if err != nil {
+		writeTwirpStatus(w, codes.InvalidArgument, err.Error())
+		return
+	}
+
+	code, respBody := s.callRoute(sub)
+	if code < 200 || code >= 300 {
+		st := statusFromErrorBody(code, respBody)
+		writeTwirpStatus(w, st.Code(), st.Message())
+		return
+	}
+	out := dynamicpb.NewMessage(reg.method.Output())
+	if len(respBody) > 0 {
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(respBody, out); err != nil {
+			writeTwirpStatus(w, codes.Internal, fmt.Sprintf("the response could not be decoded: %v", err))
+			return
+		}
+	}
+	var content []byte
+	if contentType == "application/json" {
+		content, err = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(out)
+	} else {
+		content, err = proto.Marshal(out)
+	}
+	if err != nil {
+		writeTwirpStatus(w, codes.Internal, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	if _, err := w.Write(content); err != nil {
+		grpclog.Infof("Failed to write Twirp response: %v", err)
+	}
+}
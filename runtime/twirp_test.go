@@ -0,0 +1,172 @@
+package runtime_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func newTwirpTestMux(t *testing.T) (*runtime.ServeMuxDynamic, protoreflect.ServiceDescriptor, func()) {
+	t.Helper()
+	mux := runtime.NewServeMuxDynamic(runtime.WithTwirpPrefix("/twirp"))
+	sd := handleLibraryRoutes(t, mux)
+	deregister, err := mux.RegisterTwirpService(sd)
+	if err != nil {
+		t.Fatalf("mux.RegisterTwirpService(...) failed with %v; want success", err)
+	}
+	return mux, sd, deregister
+}
+
+func TestTwirpJSON(t *testing.T) {
+	mux, _, deregister := newTwirpTestMux(t)
+
+	for _, spec := range []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		code        int
+		want        string
+	}{
+		{
+			name: "call",
+			path: "/twirp/example.library.LibraryService/GetBook",
+			body: `{"name": "books/1"}`,
+			code: http.StatusOK,
+			want: `{"name": "books/1", "title": "Dune", "page_count": "412", "status": "PUBLISHED", "authors": [{"display_name": "Frank Herbert"}]}`,
+		},
+		{
+			name: "body field",
+			path: "/twirp/example.library.LibraryService/UpdateBook",
+			body: `{"book": {"name": "books/2", "title": "Dune Messiah"}}`,
+			code: http.StatusOK,
+			want: `{"name": "books/2", "title": "Dune Messiah", "page_count": "0", "status": "STATUS_UNSPECIFIED", "authors": []}`,
+		},
+		{
+			name: "status error",
+			path: "/twirp/example.library.LibraryService/DeleteBook",
+			body: `{"name": "books/3"}`,
+			code: http.StatusNotFound,
+			want: `{"code": "not_found", "msg": "books/3 not found"}`,
+		},
+		{
+			name: "missing path param",
+			path: "/twirp/example.library.LibraryService/GetBook",
+			body: `{}`,
+			code: http.StatusBadRequest,
+			want: `{"code": "invalid_argument", "msg": "missing param \"name\""}`,
+		},
+		{
+			name: "unknown method",
+			path: "/twirp/example.library.LibraryService/ListBooks",
+			body: `{}`,
+			code: http.StatusNotFound,
+			want: `{"code": "bad_route", "msg": "no handler for path \"/twirp/example.library.LibraryService/ListBooks\""}`,
+		},
+		{
+			name:   "GET",
+			method: "GET",
+			path:   "/twirp/example.library.LibraryService/GetBook",
+			code:   http.StatusNotFound,
+			want:   `{"code": "bad_route", "msg": "unsupported method \"GET\" (only POST is allowed)"}`,
+		},
+		{
+			name:        "unsupported content type",
+			path:        "/twirp/example.library.LibraryService/GetBook",
+			contentType: "text/plain",
+			body:        `{"name": "books/1"}`,
+			code:        http.StatusNotFound,
+			want:        `{"code": "bad_route", "msg": "unexpected Content-Type: \"text/plain\""}`,
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			method, contentType := spec.method, spec.contentType
+			if method == "" {
+				method = "POST"
+			}
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			r := httptest.NewRequest(method, spec.path, strings.NewReader(spec.body))
+			r.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+
+			if w.Code != spec.code {
+				t.Errorf("code = %d; want %d", w.Code, spec.code)
+			}
+			var got, want interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("json.Unmarshal(%s) failed with %v; want success", w.Body, err)
+			}
+			if err := json.Unmarshal([]byte(spec.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("response differs (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// The messages of the decoding errors are not stable, so only their code
+	// is checked.
+	r := httptest.NewRequest("POST", "/twirp/example.library.LibraryService/GetBook", strings.NewReader(`{"name": 1}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	var twerr struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &twerr); err != nil || w.Code != http.StatusBadRequest || twerr.Code != "malformed" {
+		t.Errorf("invalid request replied %d %s; want %d with a malformed error", w.Code, w.Body, http.StatusBadRequest)
+	}
+
+	deregister()
+	r = httptest.NewRequest("POST", "/twirp/example.library.LibraryService/GetBook", strings.NewReader(`{"name": "books/1"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("code = %d once deregistered; want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestTwirpProtobuf(t *testing.T) {
+	mux, sd, _ := newTwirpTestMux(t)
+	md := sd.Methods().ByName("GetBook")
+
+	in := dynamicpb.NewMessage(md.Input())
+	in.Set(md.Input().Fields().ByName("name"), protoreflect.ValueOfString("books/1"))
+	body, err := proto.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("POST", "/twirp/example.library.LibraryService/GetBook", strings.NewReader(string(body)))
+	r.Header.Set("Content-Type", "application/protobuf")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/protobuf" {
+		t.Fatalf("response = %d with Content-Type %q; want %d with application/protobuf", w.Code, w.Header().Get("Content-Type"), http.StatusOK)
+	}
+	out := dynamicpb.NewMessage(md.Output())
+	if err := proto.Unmarshal(w.Body.Bytes(), out); err != nil {
+		t.Fatalf("proto.Unmarshal(...) failed with %v; want success", err)
+	}
+	fields := md.Output().Fields()
+	if got := out.Get(fields.ByName("title")).String(); got != "Dune" {
+		t.Errorf("title = %q; want %q", got, "Dune")
+	}
+	if got := out.Get(fields.ByName("page_count")).Int(); got != 412 {
+		t.Errorf("page_count = %d; want %d", got, 412)
+	}
+}
@@ -0,0 +1,166 @@
+package runtime
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// UpstreamConfig configures the connection to a replica of an upstream gRPC
+// server, see DialBackendGroup.
+type UpstreamConfig struct {
+	// Name identifies the replica in its BackendGroup. It defaults to
+	// Target.
+	Name string `json:"name,omitempty"`
+	// Target is the target of the replica, as given to DialUpstream.
+	Target string `json:"target"`
+	// TLS secures the connection to the replica. The connection is in
+	// plaintext if it is nil.
+	TLS *UpstreamTLSConfig `json:"tls,omitempty"`
+	// Token is a static bearer token sent in the "authorization" metadata
+	// of the calls to the replica.
+	Token string `json:"token,omitempty"`
+	// TokenSource returns the bearer tokens sent in the "authorization"
+	// metadata of the calls to the replica, e.g. OAuth 2.0 access tokens.
+	// It takes precedence over Token.
+	TokenSource oauth2.TokenSource `json:"-"`
+}
+
+// UpstreamTLSConfig configures the TLS connection to an upstream gRPC server.
+type UpstreamTLSConfig struct {
+	// CAFile is the PEM file of the certificate authorities verifying the
+	// certificate of the server, instead of the system ones.
+	CAFile string `json:"ca_file,omitempty"`
+	// CertFile and KeyFile are the PEM files of the client certificate
+	// presented to the server, and of its key.
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	// ServerName is the name of the server sent with SNI and verified
+	// against its certificate, instead of the host of the target.
+	ServerName string `json:"server_name,omitempty"`
+	// InsecureSkipVerify disables the verification of the certificate of
+	// the server. It is meant for tests.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// ParseUpstreamConfigs parses the configurations of the replicas of an
+// upstream server from a JSON configuration file, an array of the JSON
+// encodings of UpstreamConfig, e.g.
+//
+//	[{"name": "a", "target": "a.internal:443", "tls": {"ca_file": "ca.pem"}, "token": "secret"}]
+func ParseUpstreamConfigs(data []byte) ([]UpstreamConfig, error) {
+	var configs []UpstreamConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("invalid upstream configs: %v", err)
+	}
+	return configs, nil
+}
+
+// DialOptions returns the options dialing the replica of c.
+func (c UpstreamConfig) DialOptions() ([]grpc.DialOption, error) {
+	var opts []grpc.DialOption
+	if c.TLS == nil {
+		opts = append(opts, grpc.WithInsecure())
+	} else {
+		tlsConfig, err := c.TLS.tlsConfig()
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS config of upstream %s: %v", c.Target, err)
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	}
+
+	var source oauth2.TokenSource
+	switch {
+	case c.TokenSource != nil:
+		source = c.TokenSource
+	case c.Token != "":
+		source = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: c.Token})
+	}
+	if source != nil {
+		if _, ok := unixSocketPath(c.Target); !ok && c.TLS == nil {
+			return nil, fmt.Errorf("the token of upstream %s requires TLS", c.Target)
+		}
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials{source: source, requireTLS: c.TLS != nil}))
+	}
+	return opts, nil
+}
+
+func (c *UpstreamTLSConfig) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in %s", c.CAFile)
+		}
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// tokenCredentials sends the tokens of a token source as bearer tokens.
+type tokenCredentials struct {
+	source     oauth2.TokenSource
+	requireTLS bool
+}
+
+func (c tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := c.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": token.Type() + " " + token.AccessToken}, nil
+}
+
+func (c tokenCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
+
+// DialBackendGroup dials the replicas of configs, with their TLS and
+// authentication configuration and opts, and returns the BackendGroup of the
+// replicas. The connections are closed by BackendGroup.Close.
+func DialBackendGroup(ctx context.Context, configs []UpstreamConfig, opts []grpc.DialOption, groupOpts ...BackendGroupOption) (*BackendGroup, error) {
+	if len(configs) == 0 {
+		return nil, errors.New("no upstream to dial")
+	}
+	g := NewBackendGroup(nil, groupOpts...)
+	for _, c := range configs {
+		dialOpts, err := c.DialOptions()
+		if err != nil {
+			g.Close()
+			return nil, err
+		}
+		conn, err := DialUpstream(ctx, c.Target, append(dialOpts, opts...)...)
+		if err != nil {
+			g.Close()
+			return nil, err
+		}
+		name := c.Name
+		if name == "" {
+			name = c.Target
+		}
+		g.backends = append(g.backends, Backend{Name: name, Conn: conn})
+		g.conns = append(g.conns, conn)
+	}
+	return g, nil
+}
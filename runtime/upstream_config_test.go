@@ -0,0 +1,206 @@
+package runtime_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+// testPKI is a certificate authority issuing the certificates of a test.
+type testPKI struct {
+	t    *testing.T
+	dir  string
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+	pool *x509.CertPool
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "pki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	p := &testPKI{t: t, dir: dir, pool: x509.NewCertPool()}
+	p.key, p.cert, _ = p.issue("ca", &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	p.pool.AddCert(p.cert)
+	return p
+}
+
+// issue issues the certificate of template named name, writes it and its key
+// to <name>.pem and <name>-key.pem, and returns them.
+func (p *testPKI) issue(name string, template *x509.Certificate) (*ecdsa.PrivateKey, *x509.Certificate, tls.Certificate) {
+	p.t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	parent, signer := template, key
+	if p.cert != nil {
+		parent, signer = p.cert, p.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(filepath.Join(p.dir, name+".pem"), certPEM, 0600); err != nil {
+		p.t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(p.dir, name+"-key.pem"), keyPEM, 0600); err != nil {
+		p.t.Fatal(err)
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return key, cert, pair
+}
+
+func (p *testPKI) path(name string) string {
+	return filepath.Join(p.dir, name)
+}
+
+type tokenSourceFunc func() (*oauth2.Token, error)
+
+func (f tokenSourceFunc) Token() (*oauth2.Token, error) { return f() }
+
+func TestDialBackendGroup_TLS(t *testing.T) {
+	pki := newTestPKI(t)
+	_, _, serverCert := pki.issue("server", &x509.Certificate{
+		DNSNames:    []string{"upstream.internal"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	pki.issue("client", &x509.Certificate{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var authorization atomic.Value
+	srv := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientCAs:    pki.pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		})),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			authorization.Store(md["authorization"])
+			return handler(ctx, req)
+		}),
+	)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	configs, err := runtime.ParseUpstreamConfigs([]byte(fmt.Sprintf(`[{
+		"name": "a",
+		"target": %q,
+		"tls": {"ca_file": %q, "cert_file": %q, "key_file": %q, "server_name": "upstream.internal"},
+		"token": "secret"
+	}]`, lis.Addr().String(), pki.path("ca.pem"), pki.path("client.pem"), pki.path("client-key.pem"))))
+	if err != nil {
+		t.Fatalf("runtime.ParseUpstreamConfigs(...) failed with %v; want success", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	check := func(configs []runtime.UpstreamConfig) error {
+		g, err := runtime.DialBackendGroup(ctx, configs, nil)
+		if err != nil {
+			t.Fatalf("runtime.DialBackendGroup(...) failed with %v; want success", err)
+		}
+		defer g.Close()
+		_, err = healthpb.NewHealthClient(g).Check(ctx, &healthpb.HealthCheckRequest{})
+		return err
+	}
+
+	if err := check(configs); err != nil {
+		t.Fatalf("Check() failed with %v; want success", err)
+	}
+	if diff := cmp.Diff([]string{"Bearer secret"}, authorization.Load()); diff != "" {
+		t.Errorf("authorization differs (-want +got):\n%s", diff)
+	}
+
+	configs[0].TokenSource = tokenSourceFunc(func() (*oauth2.Token, error) {
+		return &oauth2.Token{AccessToken: "fresh", TokenType: "Bearer"}, nil
+	})
+	if err := check(configs); err != nil {
+		t.Fatalf("Check() with a token source failed with %v; want success", err)
+	}
+	if diff := cmp.Diff([]string{"Bearer fresh"}, authorization.Load()); diff != "" {
+		t.Errorf("authorization differs (-want +got):\n%s", diff)
+	}
+
+	wrongName := append([]runtime.UpstreamConfig(nil), configs...)
+	tlsConfig := *wrongName[0].TLS
+	tlsConfig.ServerName = "other.internal"
+	wrongName[0].TLS = &tlsConfig
+	if err := check(wrongName); err == nil {
+		t.Errorf("Check() with a server name not matching the certificate succeeded; want failure")
+	}
+}
+
+func TestUpstreamConfig_DialOptions(t *testing.T) {
+	for _, spec := range []struct {
+		name    string
+		config  runtime.UpstreamConfig
+		wantErr bool
+	}{
+		{name: "plaintext", config: runtime.UpstreamConfig{Target: "localhost:9090"}},
+		{name: "token over plaintext", config: runtime.UpstreamConfig{Target: "localhost:9090", Token: "secret"}, wantErr: true},
+		{name: "token over unix socket", config: runtime.UpstreamConfig{Target: "unix:///run/upstream.sock", Token: "secret"}},
+		{name: "token over TLS", config: runtime.UpstreamConfig{Target: "localhost:9090", TLS: &runtime.UpstreamTLSConfig{}, Token: "secret"}},
+		{name: "missing CA file", config: runtime.UpstreamConfig{Target: "localhost:9090", TLS: &runtime.UpstreamTLSConfig{CAFile: "/nonexistent/ca.pem"}}, wantErr: true},
+		{name: "missing key file", config: runtime.UpstreamConfig{Target: "localhost:9090", TLS: &runtime.UpstreamTLSConfig{CertFile: "/nonexistent/cert.pem"}}, wantErr: true},
+	} {
+		_, err := spec.config.DialOptions()
+		if (err != nil) != spec.wantErr {
+			t.Errorf("%s: DialOptions() failed with %v; want error %v", spec.name, err, spec.wantErr)
+		}
+	}
+}
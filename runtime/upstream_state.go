@@ -0,0 +1,137 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+// UpstreamState is the state of the connection to an upstream added with
+// ServeMux.AddUpstream.
+type UpstreamState struct {
+	// State is the connectivity state of the connection.
+	State connectivity.State
+	// LastError is the message of the last codes.Unavailable or
+	// codes.DeadlineExceeded error of the calls of the routes to the
+	// upstream, see WithRouteUpstream, and LastErrorTime the time it was
+	// replied at. They are empty if there was no such error.
+	LastError     string
+	LastErrorTime time.Time
+}
+
+// UpstreamStates returns the state of the upstreams added with AddUpstream,
+// by name. The states are also reported by the readiness endpoint, see
+// WithHealthEndpoints.
+func (s *ServeMux) UpstreamStates() map[string]UpstreamState {
+	s.health.mu.Lock()
+	defer s.health.mu.Unlock()
+
+	states := make(map[string]UpstreamState, len(s.health.upstreams))
+	for name, u := range s.health.upstreams {
+		states[name] = UpstreamState{State: u.conn.GetState(), LastError: u.lastError, LastErrorTime: u.lastErrorTime}
+	}
+	return states
+}
+
+// upstreamStatesBody returns the states of the upstreams of s as reported by
+// the readiness endpoint, or nil if there is no upstream.
+func (s *ServeMux) upstreamStatesBody() map[string]interface{} {
+	states := s.UpstreamStates()
+	if len(states) == 0 {
+		return nil
+	}
+	body := make(map[string]interface{}, len(states))
+	for name, st := range states {
+		state := map[string]interface{}{"state": st.State.String()}
+		if st.LastError != "" {
+			state["last_error"] = st.LastError
+			state["last_error_time"] = st.LastErrorTime.UTC().Format(time.RFC3339)
+		}
+		body[name] = state
+	}
+	return body
+}
+
+// WithRouteUpstream returns a RouteOption declaring that this route calls the
+// upstream "name" added with ServeMux.AddUpstream. The route reports the
+// errors of its calls to the state of the upstream, and is unavailable while
+// all its upstreams are down if WithUnavailableUpstreamRetryAfter is set.
+func WithRouteUpstream(name string) RouteOption {
+	return func(o *routeOptions) {
+		o.upstreams = append(o.upstreams, name)
+	}
+}
+
+// WithUnavailableUpstreamRetryAfter returns a ServeMuxOption which
+// temporarily removes the routes whose upstreams, see WithRouteUpstream, are
+// all down, i.e. their connections are in the TRANSIENT_FAILURE or SHUTDOWN
+// state: their requests are replied at once with codes.Unavailable and a
+// Retry-After header of "retryAfter", instead of waiting for the upstream
+// until their deadline.
+func WithUnavailableUpstreamRetryAfter(retryAfter time.Duration) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.upstreamRetryAfter = retryAfter
+	}
+}
+
+// recordUpstreamError records err as the last error of the upstreams of the
+// route of ctx if it tells that they are unavailable.
+func (s *ServeMux) recordUpstreamError(ctx context.Context, err error) {
+	o := routeOptionsFromContext(ctx)
+	if o == nil || len(o.upstreams) == 0 {
+		return
+	}
+	st, ok := status.FromError(err)
+	if !ok || (st.Code() != codes.Unavailable && st.Code() != codes.DeadlineExceeded) {
+		return
+	}
+
+	s.health.mu.Lock()
+	defer s.health.mu.Unlock()
+	now := time.Now()
+	for _, name := range o.upstreams {
+		if u, ok := s.health.upstreams[name]; ok {
+			u.lastError, u.lastErrorTime = st.Message(), now
+			s.health.upstreams[name] = u
+		}
+	}
+}
+
+// checkUpstreams replies codes.Unavailable with a Retry-After header and
+// returns false if all the upstreams of the route h are down and
+// WithUnavailableUpstreamRetryAfter is set.
+func (s *ServeMux) checkUpstreams(w http.ResponseWriter, r *http.Request, h handler) bool {
+	if s.upstreamRetryAfter <= 0 || h.opts == nil || len(h.opts.upstreams) == 0 {
+		return true
+	}
+	s.health.mu.Lock()
+	down := true
+	for _, name := range h.opts.upstreams {
+		u, ok := s.health.upstreams[name]
+		if !ok {
+			down = false
+			break
+		}
+		if state := u.conn.GetState(); state != connectivity.TransientFailure && state != connectivity.Shutdown {
+			down = false
+			break
+		}
+	}
+	s.health.mu.Unlock()
+	if !down {
+		return true
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(s.upstreamRetryAfter.Seconds()))))
+	_, outboundMarshaler := MarshalerForRequest(s, r)
+	err := status.Error(codes.Unavailable, fmt.Sprintf("upstream %s is unavailable", h.opts.upstreams[0]))
+	s.errorHandler(r.Context(), s, outboundMarshaler, w, r, err)
+	return false
+}
@@ -0,0 +1,120 @@
+package runtime
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// RequestValidator validates a request message once the generated handlers
+// have populated it from the HTTP request, and before they call the gRPC
+// method. A returned gRPC status error is replied as is, while any other
+// error is converted as by ValidateRequest.
+type RequestValidator func(ctx context.Context, msg proto.Message) error
+
+// WithRequestValidator returns a ServeMuxOption validating request messages
+// with v instead of DefaultRequestValidator, e.g. with a protovalidate
+// validator. It applies to the handlers generated with validate_requests.
+func WithRequestValidator(v RequestValidator) ServeMuxOption {
+	return func(mux *ServeMux) {
+		mux.requestValidator = v
+	}
+}
+
+type requestValidatorKey struct{}
+
+func withRequestValidator(ctx context.Context, v RequestValidator) context.Context {
+	return context.WithValue(ctx, requestValidatorKey{}, v)
+}
+
+// DefaultRequestValidator validates messages generated by
+// protoc-gen-validate, with their ValidateAll method, or with their Validate
+// method if they have no ValidateAll method. Other messages are valid.
+func DefaultRequestValidator(ctx context.Context, msg proto.Message) error {
+	switch v := msg.(type) {
+	case interface{ ValidateAll() error }:
+		return v.ValidateAll()
+	case interface{ Validate() error }:
+		return v.Validate()
+	}
+	return nil
+}
+
+// ValidateRequest validates msg with the RequestValidator of the ServeMux the
+// context was annotated by, or with DefaultRequestValidator. It is called by
+// the handlers generated with validate_requests.
+//
+// Validation errors which are not gRPC status errors are converted to
+// codes.InvalidArgument errors, detailing an errdetails.BadRequest with the
+// field violations of the protoc-gen-validate errors they are made of.
+func ValidateRequest(ctx context.Context, msg proto.Message) error {
+	v, ok := ctx.Value(requestValidatorKey{}).(RequestValidator)
+	if !ok {
+		v = DefaultRequestValidator
+	}
+	err := v(ctx, msg)
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	st := status.New(codes.InvalidArgument, err.Error())
+	if violations := fieldViolations(err); len(violations) > 0 {
+		if detailed, derr := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); derr == nil {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
+
+// fieldViolations returns the field violations of the protoc-gen-validate
+// error err, which is either a single validation error or a multi error.
+func fieldViolations(err error) []*errdetails.BadRequest_FieldViolation {
+	switch err := err.(type) {
+	case interface{ AllErrors() []error }:
+		var violations []*errdetails.BadRequest_FieldViolation
+		for _, err := range err.AllErrors() {
+			violations = append(violations, fieldViolations(err)...)
+		}
+		return violations
+	case interface {
+		Field() string
+		Reason() string
+	}:
+		field := err.Field()
+		// Errors of embedded messages are causes of the error of their field.
+		if cause, ok := err.(interface{ Cause() error }); ok && cause.Cause() != nil {
+			if nested := fieldViolations(cause.Cause()); len(nested) > 0 {
+				for _, v := range nested {
+					v.Field = field + "." + v.Field
+				}
+				return nested
+			}
+		}
+		return []*errdetails.BadRequest_FieldViolation{{Field: field, Description: err.Reason()}}
+	}
+	return nil
+}
+
+// RejectRequestBody returns a codes.InvalidArgument error if req carries a
+// body. It is called by the generated handlers of bindings declaring no body
+// of the methods matched by reject_body_methods.
+func RejectRequestBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return nil
+	}
+	if req.ContentLength < 0 {
+		// The length of chunked bodies is unknown until they are read.
+		var b [1]byte
+		if n, err := req.Body.Read(b[:]); n == 0 && (err == io.EOF || err == nil) {
+			return nil
+		}
+	}
+	return status.Errorf(codes.InvalidArgument, "request body is not allowed for %s %s", req.Method, req.URL.Path)
+}
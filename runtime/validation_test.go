@@ -0,0 +1,170 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	pb "github.com/grpc-ecosystem/grpc-gateway/v2/runtime/internal/examplepb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+// fakeValidationError mimics the validation errors of protoc-gen-validate.
+type fakeValidationError struct {
+	field  string
+	reason string
+	cause  error
+}
+
+func (e fakeValidationError) Error() string  { return "invalid " + e.field + ": " + e.reason }
+func (e fakeValidationError) Field() string  { return e.field }
+func (e fakeValidationError) Reason() string { return e.reason }
+func (e fakeValidationError) Cause() error   { return e.cause }
+
+type fakeMultiError []error
+
+func (m fakeMultiError) Error() string      { return m[0].Error() }
+func (m fakeMultiError) AllErrors() []error { return m }
+
+type fakeValidatedMessage struct {
+	*pb.SimpleMessage
+}
+
+func (m fakeValidatedMessage) ValidateAll() error {
+	if m.Id != "" {
+		return nil
+	}
+	return fakeMultiError{
+		fakeValidationError{field: "Id", reason: "value is required"},
+		fakeValidationError{
+			field:  "Nested",
+			reason: "embedded message failed validation",
+			cause:  fakeMultiError{fakeValidationError{field: "Amount", reason: "value must be positive"}},
+		},
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	ctx := context.Background()
+	if err := runtime.ValidateRequest(ctx, &pb.SimpleMessage{}); err != nil {
+		t.Errorf("runtime.ValidateRequest() of a message without validation failed with %v; want success", err)
+	}
+	if err := runtime.ValidateRequest(ctx, fakeValidatedMessage{&pb.SimpleMessage{Id: "foo"}}); err != nil {
+		t.Errorf("runtime.ValidateRequest() of a valid message failed with %v; want success", err)
+	}
+
+	err := runtime.ValidateRequest(ctx, fakeValidatedMessage{&pb.SimpleMessage{}})
+	st := status.Convert(err)
+	if got, want := st.Code(), codes.InvalidArgument; got != want {
+		t.Fatalf("runtime.ValidateRequest() of an invalid message failed with %v; want code %v", err, want)
+	}
+	want := &errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "Id", Description: "value is required"},
+			{Field: "Nested.Amount", Description: "value must be positive"},
+		},
+	}
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("st.Details() = %v; want %v", details, want)
+	}
+	if diff := cmp.Diff(details[0], want, protocmp.Transform()); diff != "" {
+		t.Errorf("st.Details()[0] differs: %s", diff)
+	}
+}
+
+func TestWithRequestValidator(t *testing.T) {
+	wantErr := status.Error(codes.FailedPrecondition, "rejected")
+	mux := runtime.NewServeMux(runtime.WithRequestValidator(func(ctx context.Context, msg proto.Message) error {
+		if msg.(*pb.SimpleMessage).Id == "reject" {
+			return wantErr
+		}
+		if msg.(*pb.SimpleMessage).Id == "" {
+			return errors.New("id is required")
+		}
+		return nil
+	}))
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	ctx, err := runtime.AnnotateContext(context.Background(), mux, req, "/example.Example/Get")
+	if err != nil {
+		t.Fatalf("runtime.AnnotateContext() failed with %v; want success", err)
+	}
+
+	for _, spec := range []struct {
+		msg  *pb.SimpleMessage
+		want error
+	}{
+		{msg: &pb.SimpleMessage{Id: "foo"}},
+		{msg: &pb.SimpleMessage{Id: "reject"}, want: wantErr},
+		{msg: &pb.SimpleMessage{}, want: status.Error(codes.InvalidArgument, "id is required")},
+	} {
+		err := runtime.ValidateRequest(ctx, spec.msg)
+		if spec.want == nil {
+			if err != nil {
+				t.Errorf("runtime.ValidateRequest(%v) failed with %v; want success", spec.msg, err)
+			}
+			continue
+		}
+		if !proto.Equal(status.Convert(err).Proto(), status.Convert(spec.want).Proto()) {
+			t.Errorf("runtime.ValidateRequest(%v) failed with %v; want %v", spec.msg, err, spec.want)
+		}
+	}
+}
+
+func TestRejectRequestBody(t *testing.T) {
+	for _, spec := range []struct {
+		name    string
+		req     func() *http.Request
+		wantErr bool
+	}{
+		{
+			name: "no body",
+			req:  func() *http.Request { return httptest.NewRequest("DELETE", "http://example.com/foo", nil) },
+		},
+		{
+			name: "body",
+			req: func() *http.Request {
+				return httptest.NewRequest("DELETE", "http://example.com/foo", strings.NewReader("{}"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty chunked body",
+			req: func() *http.Request {
+				r := httptest.NewRequest("GET", "http://example.com/foo", nil)
+				r.Body, r.ContentLength = ioutil.NopCloser(strings.NewReader("")), -1
+				return r
+			},
+		},
+		{
+			name: "chunked body",
+			req: func() *http.Request {
+				r := httptest.NewRequest("GET", "http://example.com/foo", nil)
+				r.Body, r.ContentLength = ioutil.NopCloser(strings.NewReader("{}")), -1
+				return r
+			},
+			wantErr: true,
+		},
+	} {
+		err := runtime.RejectRequestBody(spec.req())
+		if spec.wantErr {
+			if got, want := status.Code(err), codes.InvalidArgument; got != want {
+				t.Errorf("runtime.RejectRequestBody() with %s failed with %v; want code %v", spec.name, err, want)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("runtime.RejectRequestBody() with %s failed with %v; want success", spec.name, err)
+		}
+	}
+}
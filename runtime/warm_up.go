@@ -0,0 +1,170 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/grpclog"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// WarmUp configures the warm-up of a route, see WithRouteWarmUp.
+type WarmUp struct {
+	// Duration is the duration over which the share of the requests
+	// dispatched to the route grows from none to all of them, once its
+	// probe succeeded.
+	Duration time.Duration
+	// Probe, if not nil, verifies the upstream of the route, e.g. with
+	// HealthProbe. The route gets no requests until it succeeds.
+	Probe func(ctx context.Context) error
+	// ProbeInterval is the minimum interval between two probes. It defaults
+	// to 1 second.
+	ProbeInterval time.Duration
+	// ProbeTimeout is the timeout of a probe. It defaults to 5 seconds.
+	ProbeTimeout time.Duration
+}
+
+// WithRouteWarmUp returns a RouteOption warming up the route before it takes
+// over from the route previously registered on a ServeMuxDynamic for the same
+// method and pattern, if any: the requests are dispatched to the previous
+// route until config.Probe succeeds, then a share of them growing linearly
+// over config.Duration is dispatched to the new route (slow start). The
+// probe runs while requests are served, at most every config.ProbeInterval.
+// A route replacing none serves all the requests right away.
+func WithRouteWarmUp(config WarmUp) RouteOption {
+	if config.ProbeInterval <= 0 {
+		config.ProbeInterval = time.Second
+	}
+	if config.ProbeTimeout <= 0 {
+		config.ProbeTimeout = 5 * time.Second
+	}
+	return func(o *routeOptions) {
+		o.warmUp = &routeWarmUp{config: config, verified: time.Now()}
+		if config.Probe != nil {
+			o.warmUp.verified = time.Time{}
+		}
+	}
+}
+
+// HealthProbe returns a WarmUp.Probe checking that "service" of the upstream
+// "conn" is serving with its grpc.health.v1 service.
+func HealthProbe(conn grpc.ClientConnInterface, service string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return err
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("service %q is %v", service, resp.GetStatus())
+		}
+		return nil
+	}
+}
+
+// routeWarmUp is the warm-up state of a registration.
+type routeWarmUp struct {
+	config WarmUp
+
+	mu sync.Mutex
+	// previous is the route taking the requests not dispatched to the
+	// warming up one, or nil once the warm-up is over.
+	previous  *handler
+	verified  time.Time
+	probing   bool
+	lastProbe time.Time
+}
+
+// takeOver makes the route warm up before taking over from "previous", if it
+// has a warm-up.
+func (o *routeOptions) takeOver(previous handler, ok bool) {
+	if o == nil || o.warmUp == nil || !ok {
+		return
+	}
+	o.warmUp.previous = &previous
+}
+
+// forget stops diverting requests to the route with "id", once it is
+// deregistered.
+func (w *routeWarmUp) forget(id uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.previous != nil && w.previous.id == id {
+		w.previous = nil
+	}
+}
+
+// divert returns the route which a request to the warming up route is
+// dispatched to instead, if any.
+func (w *routeWarmUp) divert() (handler, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.previous == nil {
+		return handler{}, false
+	}
+	if w.verified.IsZero() {
+		w.probe()
+		return *w.previous, true
+	}
+	elapsed := time.Since(w.verified)
+	if elapsed >= w.config.Duration {
+		w.previous = nil
+		return handler{}, false
+	}
+	if rand.Float64()*float64(w.config.Duration) < float64(elapsed) {
+		return handler{}, false
+	}
+	return *w.previous, true
+}
+
+// probe starts a probe of the route, unless one is running or ran recently.
+// It is called with w.mu held.
+func (w *routeWarmUp) probe() {
+	if w.probing || time.Since(w.lastProbe) < w.config.ProbeInterval {
+		return
+	}
+	w.probing = true
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), w.config.ProbeTimeout)
+		defer cancel()
+		err := w.config.Probe(ctx)
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.probing = false
+		w.lastProbe = time.Now()
+		if err != nil {
+			grpclog.Infof("Warm-up probe failed: %v", err)
+			return
+		}
+		w.verified = w.lastProbe
+	}()
+}
+
+// warmUp returns the route serving a request dispatched to h, which is
+// another one while h warms up.
+func (s *ServeMux) warmUp(h handler) handler {
+	if h.opts == nil || h.opts.warmUp == nil {
+		return h
+	}
+	if previous, ok := h.opts.warmUp.divert(); ok {
+		return s.warmUp(previous)
+	}
+	return h
+}
+
+// handlerFor returns the route currently serving the requests for "meth" and
+// "pat". It is called with s.mu held.
+func (s *ServeMuxDynamic) handlerFor(meth string, pat Pattern) (handler, bool) {
+	for _, h := range s.handlers[meth] {
+		if h.pat.String() == pat.String() {
+			return h, true
+		}
+	}
+	return handler{}, false
+}
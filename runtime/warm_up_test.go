@@ -0,0 +1,106 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestRouteWarmUp(t *testing.T) {
+	mux := runtime.NewServeMuxDynamic()
+	reply := func(body string) runtime.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+			w.Write([]byte(body))
+		}
+	}
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w.Body.String()
+	}
+	var healthy, probes int32
+	warmUp := runtime.WithRouteWarmUp(runtime.WarmUp{
+		Duration: 50 * time.Millisecond,
+		Probe: func(ctx context.Context) error {
+			atomic.AddInt32(&probes, 1)
+			if atomic.LoadInt32(&healthy) == 0 {
+				return errors.New("not ready")
+			}
+			return nil
+		},
+		ProbeInterval: time.Millisecond,
+	})
+
+	if err := mux.HandlePath("GET", "/v1/books", reply("old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.HandlePath("GET", "/v1/books", reply("new"), warmUp); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if got := get("/v1/books"); got != "old" {
+			t.Fatalf("GET /v1/books = %q before the probe succeeded; want %q", got, "old")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadInt32(&probes) == 0 {
+		t.Errorf("the new route was not probed")
+	}
+
+	atomic.StoreInt32(&healthy, 1)
+	deadline := time.Now().Add(5 * time.Second)
+	for get("/v1/books") != "new" {
+		if time.Now().After(deadline) {
+			t.Fatalf("GET /v1/books was not dispatched to the new route once its probe succeeded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		if got := get("/v1/books"); got != "new" {
+			t.Fatalf("GET /v1/books = %q once warmed up; want %q", got, "new")
+		}
+	}
+
+	// A route replacing none serves right away.
+	if err := mux.HandlePath("GET", "/v1/shelves", reply("shelves"), warmUp); err != nil {
+		t.Fatal(err)
+	}
+	if got := get("/v1/shelves"); got != "shelves" {
+		t.Errorf("GET /v1/shelves = %q; want %q", got, "shelves")
+	}
+}
+
+func TestRouteWarmUpDeregisteredPrevious(t *testing.T) {
+	mux := runtime.NewServeMuxDynamic()
+	reply := func(body string) runtime.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+			w.Write([]byte(body))
+		}
+	}
+	get := func() string {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/books", nil))
+		return w.Body.String()
+	}
+	pat := runtime.MustPattern(runtime.NewPattern(1, []int{2, 0, 2, 1}, []string{"v1", "books"}, ""))
+
+	deregister := mux.HandleWithDeregister("GET", pat, reply("old"))
+	mux.Handle("GET", pat, reply("new"), runtime.WithRouteWarmUp(runtime.WarmUp{
+		Duration: time.Hour,
+		Probe:    func(ctx context.Context) error { return errors.New("not ready") },
+	}))
+	if got := get(); got != "old" {
+		t.Fatalf("GET /v1/books = %q while warming up; want %q", got, "old")
+	}
+	deregister()
+	if got := get(); got != "new" {
+		t.Errorf("GET /v1/books = %q once the previous route is deregistered; want %q", got, "new")
+	}
+}